@@ -0,0 +1,129 @@
+package maxminddb
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/netip"
+)
+
+// ParseIP parses s as an IPv4 or IPv6 address for use with Lookup and the
+// other lookup methods. Unlike net.ParseIP, it accepts IPv6 addresses with a
+// zone identifier, e.g., "fe80::1%eth0", as they are reported for sockets
+// with a link-local address. The zone is dropped as it identifies a local
+// interface and has no bearing on the records of the address.
+//
+// Zones are ignored wherever an address is passed as a netip.Addr, e.g., to
+// Index.LookupString, so lookups of an address give the same result with or
+// without a zone. To avoid looking up link-local and other non-global
+// addresses at all, use WithSkipReserved, which treats fe80::/10 and the
+// other reserved networks as not found.
+func ParseIP(s string) (net.IP, error) {
+	addr, err := netip.ParseAddr(s)
+	if err != nil {
+		return nil, fmt.Errorf("maxminddb: invalid IP address: %w", err)
+	}
+	return addr.WithZone("").AsSlice(), nil
+}
+
+// LookupNetip retrieves the database record for ip and stores it in the
+// value pointed to by result, as Lookup does for the equivalent net.IP. The
+// search tree is walked from the address itself, without converting it to a
+// net.IP. As with Lookup, IPv4-mapped IPv6 addresses such as
+// ::ffff:81.2.69.160 are looked up as IPv4 addresses. The zone of an IPv6
+// address, if any, is ignored, as described for ParseIP. An error is
+// returned for the zero Addr.
+//
+// Use With to look up ip with LookupOptions.
+func (r *Reader) LookupNetip(ip netip.Addr, result any) error {
+	return r.With().LookupNetip(ip, result)
+}
+
+// LookupNetip retrieves the database record for ip and stores it in the
+// value pointed to by result. See Reader.LookupNetip.
+func (l Lookuper) LookupNetip(ip netip.Addr, result any) error {
+	r := l.reader
+	if r.buffer == nil {
+		return errors.New("cannot call LookupNetip on a closed database")
+	}
+	offset, _, _, err := r.lookupAddr(ip)
+	if offset == NotFound || err != nil {
+		return err
+	}
+	return r.decodeWithOptions(offset, result, l.options)
+}
+
+// LookupNetworkPrefix retrieves the database record for ip and stores it in
+// the value pointed to by result, as LookupNetwork does for the equivalent
+// net.IP, and returns the network of the record as a netip.Prefix. Unlike
+// the *net.IPNet returned by LookupNetwork, the prefix is not allocated.
+//
+// The prefix is the network LookupNetwork returns. In particular, the
+// network of an IPv4 address, including an IPv4-mapped IPv6 address such as
+// ::ffff:1.1.1.1, is an IPv4 prefix, e.g., 1.1.1.0/24 rather than
+// ::ffff:1.1.1.0/120, even in an IPv6 database. Zones are ignored as
+// described for LookupNetip. If an error is returned because ip cannot be
+// looked up, the prefix is the zero Prefix.
+//
+// Use With to look up ip with LookupOptions.
+func (r *Reader) LookupNetworkPrefix(ip netip.Addr, result any) (netip.Prefix, bool, error) {
+	return r.With().LookupNetworkPrefix(ip, result)
+}
+
+// LookupNetworkPrefix retrieves the database record for ip and stores it in
+// the value pointed to by result. It also returns the network of the record.
+// See Reader.LookupNetworkPrefix.
+func (l Lookuper) LookupNetworkPrefix(ip netip.Addr, result any) (prefix netip.Prefix, ok bool, err error) {
+	r := l.reader
+	if r.buffer == nil {
+		return netip.Prefix{}, false, errors.New("cannot call LookupNetworkPrefix on a closed database")
+	}
+	offset, prefixLength, ip, err := r.lookupAddr(ip)
+	if !ip.IsValid() {
+		return netip.Prefix{}, false, err
+	}
+
+	prefix = r.prefix(ip, prefixLength)
+	if offset == NotFound || err != nil {
+		return prefix, false, err
+	}
+	return prefix, true, r.decodeWithOptions(offset, result, l.options)
+}
+
+// lookupAddr is lookupRecord for ip. It returns ip in the form used for the
+// lookup, i.e., without a zone and with IPv4-mapped addresses unmapped, or
+// the zero Addr if ip cannot be looked up. It does not allocate.
+func (r *Reader) lookupAddr(ip netip.Addr) (uintptr, int, netip.Addr, error) {
+	if !ip.IsValid() {
+		return NotFound, 0, netip.Addr{}, errors.New("IP passed to LookupNetip is invalid")
+	}
+	if err := r.verification.lookupError(); err != nil {
+		return NotFound, 0, netip.Addr{}, err
+	}
+
+	ip = ip.WithZone("").Unmap()
+	if ip.Is4() {
+		b := ip.As4()
+		offset, prefixLength, err := r.lookupNormalized(b[:])
+		return offset, prefixLength, ip, err
+	}
+	if r.Metadata.IPVersion == 4 {
+		return NotFound, 0, netip.Addr{}, ipv6AddressInIPv4DatabaseError(ip.String())
+	}
+	b := ip.As16()
+	offset, prefixLength, err := r.lookupNormalized(b[:])
+	return offset, prefixLength, ip, err
+}
+
+// prefix is cidrInto for an address as returned by lookupAddr.
+func (r *Reader) prefix(ip netip.Addr, prefixLength int) netip.Prefix {
+	// See cidrInto.
+	if r.Metadata.IPVersion == 6 &&
+		ip.Is4() &&
+		r.ipv4StartBitDepth != 96 &&
+		prefixLength == 0 {
+		return netip.PrefixFrom(netip.IPv6Unspecified(), r.ipv4StartBitDepth)
+	}
+	prefix, _ := ip.Prefix(prefixLength)
+	return prefix
+}
@@ -0,0 +1,266 @@
+package maxminddb
+
+import (
+	"net"
+	"net/netip"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseIP(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected net.IP
+	}{
+		{input: "1.2.3.4", expected: net.IP{1, 2, 3, 4}},
+		{input: "2001:db8::1", expected: net.ParseIP("2001:db8::1")},
+		{input: "fe80::1%eth0", expected: net.ParseIP("fe80::1")},
+		{input: "fe80::1%25", expected: net.ParseIP("fe80::1")},
+		{input: "::ffff:1.2.3.4%eth0", expected: net.ParseIP("::ffff:1.2.3.4")},
+	}
+	for _, test := range tests {
+		ip, err := ParseIP(test.input)
+		require.NoError(t, err, test.input)
+		assert.True(t, test.expected.Equal(ip), "%s: got %v", test.input, ip)
+	}
+
+	for _, input := range []string{"", "1.2.3.4%eth0", "fe80::1%", "not an address"} {
+		_, err := ParseIP(input)
+		assert.ErrorContains(t, err, "maxminddb: invalid IP address", input)
+	}
+}
+
+func TestLookupZonedAddress(t *testing.T) {
+	reader, err := Open(testFile("GeoIP2-City-Test.mmdb"))
+	require.NoError(t, err)
+	defer reader.Close()
+
+	ip, err := ParseIP("2001:218::%eth0")
+	require.NoError(t, err)
+	var zoned, unzoned any
+	require.NoError(t, reader.Lookup(ip, &zoned))
+	require.NoError(t, reader.Lookup(net.ParseIP("2001:218::"), &unzoned))
+	assert.NotNil(t, zoned)
+	assert.Equal(t, unzoned, zoned)
+
+	reserved, err := Open(testFile("GeoIP2-City-Test.mmdb"), WithSkipReserved())
+	require.NoError(t, err)
+	defer reserved.Close()
+
+	ip, err = ParseIP("fe80::1%eth0")
+	require.NoError(t, err)
+	var record any
+	network, ok, err := reserved.LookupNetwork(ip, &record)
+	require.NoError(t, err)
+	assert.False(t, ok)
+	assert.Equal(t, "fe80::/10", network.String())
+}
+
+func TestLookupNetip(t *testing.T) {
+	for _, file := range []string{
+		"GeoIP2-City-Test.mmdb",
+		"MaxMind-DB-test-ipv4-24.mmdb",
+		"MaxMind-DB-test-mixed-24.mmdb",
+	} {
+		reader, err := Open(testFile(file))
+		require.NoError(t, err)
+
+		for _, s := range []string{
+			"1.1.1.1",
+			"1.1.1.3",
+			"81.2.69.160",
+			"::ffff:81.2.69.160",
+			"::ffff:1.1.1.1",
+			"10.0.0.1",
+			"2001:218::",
+			"::1.1.1.1",
+		} {
+			addr := netip.MustParseAddr(s)
+			var expected, record any
+			expectedErr := reader.Lookup(net.ParseIP(s), &expected)
+			err := reader.LookupNetip(addr, &record)
+			if expectedErr != nil {
+				assert.EqualError(t, err, expectedErr.Error(), "%s in %s", s, file)
+				continue
+			}
+			require.NoError(t, err, "%s in %s", s, file)
+			assert.Equal(t, expected, record, "%s in %s", s, file)
+		}
+		require.NoError(t, reader.Close())
+	}
+}
+
+func TestLookupNetipZonesAndErrors(t *testing.T) {
+	reader, err := Open(testFile("GeoIP2-City-Test.mmdb"))
+	require.NoError(t, err)
+
+	var zoned, unzoned any
+	require.NoError(t, reader.LookupNetip(netip.MustParseAddr("2001:218::%eth0"), &zoned))
+	require.NoError(t, reader.LookupNetip(netip.MustParseAddr("2001:218::"), &unzoned))
+	assert.NotNil(t, zoned)
+	assert.Equal(t, unzoned, zoned)
+
+	var record any
+	require.NoError(t, reader.LookupNetip(netip.MustParseAddr("::ffff:81.2.69.160%eth0"), &record))
+	assert.NotNil(t, record)
+
+	assert.EqualError(t, reader.LookupNetip(netip.Addr{}, &record), "IP passed to LookupNetip is invalid")
+	require.NoError(t, reader.Close())
+	assert.EqualError(
+		t,
+		reader.LookupNetip(netip.MustParseAddr("81.2.69.160"), &record),
+		"cannot call LookupNetip on a closed database",
+	)
+
+	ipv4, err := Open(testFile("MaxMind-DB-test-ipv4-24.mmdb"))
+	require.NoError(t, err)
+	defer ipv4.Close()
+	assert.EqualError(
+		t,
+		ipv4.LookupNetip(netip.MustParseAddr("2001:db8::1%eth0"), &record),
+		"error looking up '2001:db8::1': you attempted to look up an IPv6 address in an IPv4-only database",
+	)
+}
+
+func TestLookupAddrAllocations(t *testing.T) {
+	reader, err := Open(testFile("GeoIP2-City-Test.mmdb"))
+	require.NoError(t, err)
+	defer reader.Close()
+
+	addrs := []netip.Addr{
+		netip.MustParseAddr("81.2.69.160"),
+		netip.MustParseAddr("::ffff:81.2.69.160"),
+		netip.MustParseAddr("2001:218::"),
+		netip.MustParseAddr("10.0.0.1"),
+	}
+	allocs := testing.AllocsPerRun(100, func() {
+		for _, addr := range addrs {
+			_, _, _, _ = reader.lookupAddr(addr)
+		}
+	})
+	assert.Zero(t, allocs)
+}
+
+func TestLookupNetworkPrefix(t *testing.T) {
+	for _, test := range []struct {
+		file string
+		ips  []string
+	}{
+		{
+			file: "GeoIP2-City-Test.mmdb",
+			ips:  []string{"81.2.69.160", "::ffff:81.2.69.160", "2001:218::", "10.0.0.1", "1.1.1.1"},
+		},
+		{
+			file: "MaxMind-DB-test-ipv4-24.mmdb",
+			ips:  []string{"1.1.1.1", "1.1.1.3", "::ffff:1.1.1.1", "200.0.2.1"},
+		},
+		{
+			file: "MaxMind-DB-test-mixed-24.mmdb",
+			ips:  []string{"1.1.1.1", "::1.1.1.1", "::ffff:1.1.1.1", "::2:0:40", "9.9.9.9"},
+		},
+		{
+			// A database without an IPv4 subtree, where the network of an
+			// IPv4 address is ::/64.
+			file: "MaxMind-DB-no-ipv4-search-tree.mmdb",
+			ips:  []string{"1.1.1.1", "::ffff:1.1.1.1", "::1:ffff:ffff"},
+		},
+	} {
+		reader, err := Open(testFile(test.file))
+		require.NoError(t, err)
+		for _, ip := range test.ips {
+			var expected, record any
+			network, expectedOK, err := reader.LookupNetwork(net.ParseIP(ip), &expected)
+			require.NoError(t, err)
+
+			prefix, ok, err := reader.LookupNetworkPrefix(netip.MustParseAddr(ip), &record)
+			require.NoError(t, err)
+			assert.Equal(t, network.String(), prefix.String(), "%s in %s", ip, test.file)
+			assert.Equal(t, expectedOK, ok, "%s in %s", ip, test.file)
+			assert.Equal(t, expected, record, "%s in %s", ip, test.file)
+		}
+		require.NoError(t, reader.Close())
+	}
+}
+
+func TestLookupNetworkPrefixErrors(t *testing.T) {
+	reader, err := Open(testFile("MaxMind-DB-test-ipv4-24.mmdb"))
+	require.NoError(t, err)
+
+	var record any
+	prefix, ok, err := reader.LookupNetworkPrefix(netip.Addr{}, &record)
+	assert.EqualError(t, err, "IP passed to LookupNetip is invalid")
+	assert.False(t, ok)
+	assert.Equal(t, netip.Prefix{}, prefix)
+
+	prefix, ok, err = reader.LookupNetworkPrefix(netip.MustParseAddr("2001:db8::1%eth0"), &record)
+	assert.EqualError(
+		t,
+		err,
+		"error looking up '2001:db8::1': you attempted to look up an IPv6 address in an IPv4-only database",
+	)
+	assert.False(t, ok)
+	assert.Equal(t, netip.Prefix{}, prefix)
+
+	// The zone is ignored.
+	prefix, ok, err = reader.LookupNetworkPrefix(netip.MustParseAddr("::ffff:1.1.1.1%eth0"), &record)
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, netip.MustParsePrefix("1.1.1.1/32"), prefix)
+
+	require.NoError(t, reader.Close())
+	_, _, err = reader.LookupNetworkPrefix(netip.MustParseAddr("1.1.1.1"), &record)
+	assert.EqualError(t, err, "cannot call LookupNetworkPrefix on a closed database")
+}
+
+type networkRecord struct {
+	Country struct {
+		GeoNameID uint `maxminddb:"geoname_id"`
+	} `maxminddb:"country"`
+}
+
+func TestLookupNetworkPrefixAllocations(t *testing.T) {
+	reader, err := Open(testFile("GeoIP2-City-Test.mmdb"))
+	require.NoError(t, err)
+	defer reader.Close()
+
+	var record networkRecord
+	ip := "81.2.69.160"
+	netIP := net.ParseIP(ip)
+	ipNetAllocs := testing.AllocsPerRun(100, func() {
+		_, _, _ = reader.LookupNetwork(netIP, &record)
+	})
+	addr := netip.MustParseAddr(ip)
+	prefixAllocs := testing.AllocsPerRun(100, func() {
+		_, _, _ = reader.LookupNetworkPrefix(addr, &record)
+	})
+	assert.Less(t, prefixAllocs, ipNetAllocs)
+}
+
+func BenchmarkLookupNetworkPrefix(b *testing.B) {
+	reader, err := Open(testFile("GeoIP2-City-Test.mmdb"))
+	require.NoError(b, err)
+	defer reader.Close()
+
+	var record networkRecord
+	ip := "81.2.69.160"
+	b.Run("IPNet", func(b *testing.B) {
+		netIP := net.ParseIP(ip)
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			if _, _, err := reader.LookupNetwork(netIP, &record); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	b.Run("Prefix", func(b *testing.B) {
+		addr := netip.MustParseAddr(ip)
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			if _, _, err := reader.LookupNetworkPrefix(addr, &record); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
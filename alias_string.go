@@ -0,0 +1,19 @@
+//go:build !purego
+// +build !purego
+
+package maxminddb
+
+import "unsafe"
+
+// aliasesStrings is whether aliasString returns a string sharing the memory
+// of its argument.
+const aliasesStrings = true
+
+// aliasString returns a string sharing the memory of b, which must not be
+// modified while the string is in use.
+func aliasString(b []byte) string {
+	if len(b) == 0 {
+		return ""
+	}
+	return unsafe.String(unsafe.SliceData(b), len(b))
+}
@@ -0,0 +1,14 @@
+//go:build purego
+// +build purego
+
+package maxminddb
+
+// aliasesStrings is whether aliasString returns a string sharing the memory
+// of its argument.
+const aliasesStrings = false
+
+// aliasString returns a copy of b as a string, as aliasing it requires
+// unsafe.
+func aliasString(b []byte) string {
+	return string(b)
+}
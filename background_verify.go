@@ -0,0 +1,183 @@
+package maxminddb
+
+import (
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"time"
+)
+
+// VerifyState is the state of the background verification of a Reader.
+type VerifyState int
+
+const (
+	// VerifyNotStarted means that the Reader was opened without
+	// WithBackgroundVerify.
+	VerifyNotStarted VerifyState = iota
+	// VerifyRunning means that the verification has not finished yet.
+	VerifyRunning
+	// VerifyPassed means that Verify found no problems.
+	VerifyPassed
+	// VerifyFailed means that Verify returned an error.
+	VerifyFailed
+	// VerifyCanceled means that the Reader was closed before the
+	// verification finished.
+	VerifyCanceled
+)
+
+// String returns the name of the state, e.g., "passed".
+func (s VerifyState) String() string {
+	switch s {
+	case VerifyNotStarted:
+		return "not started"
+	case VerifyRunning:
+		return "running"
+	case VerifyPassed:
+		return "passed"
+	case VerifyFailed:
+		return "failed"
+	case VerifyCanceled:
+		return "canceled"
+	default:
+		return fmt.Sprintf("VerifyState(%d)", int(s))
+	}
+}
+
+// VerifyResult reports the outcome of the background verification started
+// with WithBackgroundVerify.
+type VerifyResult struct {
+	State VerifyState
+	// Err is the error returned by Verify if State is VerifyFailed.
+	Err error
+	// Duration is how long the verification ran. It is zero while the
+	// verification is running.
+	Duration time.Duration
+}
+
+// VerificationFailedError is returned by lookups once the background
+// verification has failed if WithFailOnVerifyError is set.
+type VerificationFailedError struct {
+	// Err is the error returned by Verify.
+	Err error
+}
+
+func (e VerificationFailedError) Error() string {
+	return fmt.Sprintf("maxminddb: the database failed verification: %v", e.Err)
+}
+
+func (e VerificationFailedError) Unwrap() error {
+	return e.Err
+}
+
+// errVerifyCanceled is returned by verify when it is stopped.
+var errVerifyCanceled = errors.New("maxminddb: verification canceled")
+
+// WithBackgroundVerify is an option for Open, FromBytes, and FromReader that
+// runs Verify on the database on a background goroutine, so that the Reader
+// is returned without waiting for the verification of the whole database.
+// The Reader may be used in the meantime.
+//
+// Once the verification is done, callback, if not nil, is called with the
+// result on the background goroutine. The result is also returned by
+// Reader.VerifyStatus. Closing the Reader stops the verification, in which
+// case callback is called with a VerifyCanceled result; Close waits for
+// the verification to stop but not for callback to return.
+func WithBackgroundVerify(callback func(VerifyResult)) ReaderOption {
+	return func(o *readerOptions) {
+		o.backgroundVerify = true
+		o.verifyCallback = callback
+	}
+}
+
+// WithFailOnVerifyError is an option for Open, FromBytes, and FromReader
+// that makes lookups return a VerificationFailedError once the verification
+// started with WithBackgroundVerify has failed. Lookups made before then
+// are not affected. Without WithBackgroundVerify, it has no effect.
+func WithFailOnVerifyError() ReaderOption {
+	return func(o *readerOptions) {
+		o.failOnVerifyError = true
+	}
+}
+
+// backgroundVerify tracks the verification started with
+// WithBackgroundVerify.
+type backgroundVerify struct {
+	// stop is closed by Close to stop the verification, which closes done
+	// once it has stopped reading the database.
+	stop chan struct{}
+	done chan struct{}
+
+	// result is nil while the verification is running.
+	result atomic.Pointer[VerifyResult]
+
+	failLookups bool
+}
+
+// startBackgroundVerify starts verifying the database on a new goroutine.
+func (r *Reader) startBackgroundVerify(callback func(VerifyResult), failLookups bool) {
+	v := &backgroundVerify{
+		stop:        make(chan struct{}),
+		done:        make(chan struct{}),
+		failLookups: failLookups,
+	}
+	r.verification = v
+
+	go func() {
+		start := time.Now()
+		err := r.verify(v.stop)
+		result := VerifyResult{State: VerifyPassed, Duration: time.Since(start)}
+		switch {
+		case errors.Is(err, errVerifyCanceled):
+			result.State = VerifyCanceled
+		case err != nil:
+			result.State = VerifyFailed
+			result.Err = err
+		}
+		v.result.Store(&result)
+		close(v.done)
+
+		if callback != nil {
+			callback(result)
+		}
+	}()
+}
+
+// VerifyStatus returns the state of the verification started with
+// WithBackgroundVerify and, once it is done, its result.
+func (r *Reader) VerifyStatus() VerifyResult {
+	v := r.verification
+	if v == nil {
+		return VerifyResult{State: VerifyNotStarted}
+	}
+	if result := v.result.Load(); result != nil {
+		return *result
+	}
+	return VerifyResult{State: VerifyRunning}
+}
+
+// lookupError returns the error lookups return because of the
+// verification, if any.
+func (v *backgroundVerify) lookupError() error {
+	if v == nil || !v.failLookups {
+		return nil
+	}
+	if result := v.result.Load(); result != nil && result.State == VerifyFailed {
+		return VerificationFailedError{Err: result.Err}
+	}
+	return nil
+}
+
+// stopBackgroundVerify stops the verification, if it is running, and waits
+// for it to stop reading the database.
+func (r *Reader) stopBackgroundVerify() {
+	v := r.verification
+	if v == nil {
+		return
+	}
+	select {
+	case <-v.stop:
+	default:
+		close(v.stop)
+	}
+	<-v.done
+}
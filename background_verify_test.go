@@ -0,0 +1,136 @@
+package maxminddb
+
+import (
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func openVerifying(t *testing.T, file string, options ...ReaderOption) (*Reader, <-chan VerifyResult) {
+	t.Helper()
+	results := make(chan VerifyResult, 1)
+	options = append(options, WithBackgroundVerify(func(result VerifyResult) {
+		results <- result
+	}))
+	reader, err := Open(testFile(file), options...)
+	require.NoError(t, err)
+	return reader, results
+}
+
+func waitForVerify(t *testing.T, results <-chan VerifyResult) VerifyResult {
+	t.Helper()
+	select {
+	case result := <-results:
+		return result
+	case <-time.After(time.Minute):
+		require.FailNow(t, "the verification did not finish")
+		return VerifyResult{}
+	}
+}
+
+func TestBackgroundVerify(t *testing.T) {
+	reader, results := openVerifying(t, "GeoIP2-City-Test.mmdb")
+
+	// The Reader may be used while the verification runs.
+	var country struct {
+		Country struct {
+			IsoCode string `maxminddb:"iso_code"`
+		} `maxminddb:"country"`
+	}
+	require.NoError(t, reader.Lookup(net.ParseIP("81.2.69.160"), &country))
+	assert.Equal(t, "GB", country.Country.IsoCode)
+
+	result := waitForVerify(t, results)
+	assert.Equal(t, VerifyPassed, result.State)
+	assert.NoError(t, result.Err)
+	assert.Positive(t, result.Duration)
+	assert.Equal(t, result, reader.VerifyStatus())
+
+	require.NoError(t, reader.Close())
+	assert.Equal(t, result, reader.VerifyStatus(), "Close keeps the result")
+}
+
+func TestBackgroundVerifyFailure(t *testing.T) {
+	for _, database := range []string{
+		"GeoIP2-City-Test-Broken-Double-Format.mmdb",
+		"MaxMind-DB-test-broken-pointers-24.mmdb",
+	} {
+		reader, results := openVerifying(t, database)
+		result := waitForVerify(t, results)
+		assert.Equal(t, VerifyFailed, result.State, database)
+		assert.Equal(t, reader.Verify(), result.Err, database)
+		assert.Equal(t, result, reader.VerifyStatus(), database)
+
+		// Without WithFailOnVerifyError, lookups are not affected.
+		_, err := reader.LookupOffset(net.ParseIP("1.1.1.1"))
+		var verifyErr VerificationFailedError
+		assert.False(t, errors.As(err, &verifyErr), database)
+		require.NoError(t, reader.Close())
+	}
+}
+
+func TestFailOnVerifyError(t *testing.T) {
+	reader, results := openVerifying(
+		t,
+		"GeoIP2-City-Test-Broken-Double-Format.mmdb",
+		WithFailOnVerifyError(),
+	)
+	defer reader.Close()
+	result := waitForVerify(t, results)
+	require.Equal(t, VerifyFailed, result.State)
+
+	var record any
+	err := reader.Lookup(net.ParseIP("81.2.69.160"), &record)
+	var verifyErr VerificationFailedError
+	require.ErrorAs(t, err, &verifyErr)
+	assert.Equal(t, result.Err, verifyErr.Err)
+	assert.ErrorIs(t, err, result.Err)
+	assert.EqualError(t, err, "maxminddb: the database failed verification: "+result.Err.Error())
+
+	_, err = reader.LookupOffset(net.ParseIP("81.2.69.160"))
+	assert.ErrorAs(t, err, &verifyErr)
+	_, err = reader.LookupValue(net.ParseIP("81.2.69.160"))
+	assert.ErrorAs(t, err, &verifyErr)
+
+	// Passing verifications do not affect lookups.
+	valid, results := openVerifying(t, "GeoIP2-City-Test.mmdb", WithFailOnVerifyError())
+	defer valid.Close()
+	require.Equal(t, VerifyPassed, waitForVerify(t, results).State)
+	assert.NoError(t, valid.Lookup(net.ParseIP("81.2.69.160"), &record))
+}
+
+func TestBackgroundVerifyClose(t *testing.T) {
+	reader, results := openVerifying(t, "GeoIP2-City-Test.mmdb")
+	require.NoError(t, reader.Close())
+
+	// Close may race with the end of the verification, but it waits for it
+	// to stop either way.
+	result := reader.VerifyStatus()
+	assert.Contains(t, []VerifyState{VerifyPassed, VerifyCanceled}, result.State)
+	assert.Equal(t, result, waitForVerify(t, results))
+	assert.NoError(t, reader.Close())
+}
+
+func TestVerifyCanceled(t *testing.T) {
+	reader, err := Open(testFile("GeoIP2-City-Test.mmdb"))
+	require.NoError(t, err)
+	defer reader.Close()
+
+	stop := make(chan struct{})
+	close(stop)
+	assert.ErrorIs(t, reader.verify(stop), errVerifyCanceled)
+	assert.NoError(t, reader.verify(nil))
+}
+
+func TestVerifyStatusWithoutBackgroundVerify(t *testing.T) {
+	reader, err := Open(testFile("GeoIP2-City-Test.mmdb"), WithFailOnVerifyError())
+	require.NoError(t, err)
+	defer reader.Close()
+
+	assert.Equal(t, VerifyResult{State: VerifyNotStarted}, reader.VerifyStatus())
+	assert.Equal(t, "not started", reader.VerifyStatus().State.String())
+}
@@ -0,0 +1,157 @@
+package maxminddb
+
+import (
+	"errors"
+	"fmt"
+	"net/netip"
+	"sort"
+	"strings"
+
+	"github.com/3JoB/go-reflect"
+)
+
+// LookupError describes an address passed to LookupMany that could not be
+// looked up or whose record could not be decoded.
+type LookupError struct {
+	// IP is the address as passed to LookupMany.
+	IP  netip.Addr
+	Err error
+	// Index is the index of IP in the addresses passed to LookupMany and of
+	// its result.
+	Index int
+}
+
+func (e LookupError) Error() string {
+	return fmt.Sprintf("maxminddb: error looking up %s (index %d): %v", e.IP, e.Index, e.Err)
+}
+
+func (e LookupError) Unwrap() error {
+	return e.Err
+}
+
+// LookupErrors is returned by LookupMany when one or more of the addresses
+// could not be looked up or decoded. It holds one LookupError per such
+// address, in the order of their indexes. The other addresses were looked
+// up successfully.
+type LookupErrors []LookupError
+
+func (e LookupErrors) Error() string {
+	messages := make([]string, len(e))
+	for i, err := range e {
+		messages[i] = err.Error()
+	}
+	return strings.Join(messages, "\n")
+}
+
+// Unwrap returns the individual LookupError values so that errors.Is and
+// errors.As match any of them.
+func (e LookupErrors) Unwrap() []error {
+	errs := make([]error, len(e))
+	for i, err := range e {
+		errs[i] = err
+	}
+	return errs
+}
+
+// LookupMany looks up each of ips, as LookupNetip does, and stores its
+// record in the element with the same index of the slice results points to,
+// e.g., a *[]City. The slice is resized to the length of ips, reusing its
+// memory if it is large enough, and the elements of addresses without a
+// record are left as zero values.
+//
+// The work is shared across the batch rather than repeated for each
+// address: the addresses are looked up in sorted order, which visits the
+// search tree sequentially, each distinct address is looked up once, and
+// each distinct record is decoded once and copied into the results of the
+// other addresses with that record. As the copies are shallow, results with
+// the same record share the maps, slices, and pointers they hold.
+//
+// An address that cannot be looked up, e.g., an IPv6 address in an IPv4
+// database, or whose record cannot be decoded does not stop the batch. The
+// error of each such address is returned in LookupErrors.
+//
+// Use With to look up ips with LookupOptions.
+func (r *Reader) LookupMany(ips []netip.Addr, results any) error {
+	return r.With().LookupMany(ips, results)
+}
+
+// LookupMany looks up each of ips and stores its record in the element with
+// the same index of the slice results points to. See Reader.LookupMany.
+func (l Lookuper) LookupMany(ips []netip.Addr, results any) error {
+	r := l.reader
+	if r.buffer == nil {
+		return errors.New("cannot call LookupMany on a closed database")
+	}
+	rv := reflect.ValueOf(results)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() || rv.Elem().Kind() != reflect.Slice {
+		return errors.New("results param must be a pointer to a slice")
+	}
+	slice := rv.Elem()
+	if slice.Cap() < len(ips) {
+		slice.Set(reflect.MakeSlice(slice.Type(), len(ips), len(ips)))
+	} else {
+		slice.SetLen(len(ips))
+		for i := 0; i < len(ips); i++ {
+			reflectSetZero(slice.Index(i))
+		}
+	}
+
+	order := make([]int, len(ips))
+	for i := range order {
+		order[i] = i
+	}
+	sort.Slice(order, func(i, j int) bool {
+		return ips[order[i]].Less(ips[order[j]])
+	})
+
+	// batchRecord is the result of decoding a record: the index of the
+	// result it was decoded into and the error, if any.
+	type batchRecord struct {
+		err   error
+		index int
+	}
+	records := map[uintptr]batchRecord{}
+	var (
+		errs     LookupErrors
+		previous netip.Addr
+		offset   uintptr
+		err      error
+	)
+	for n, i := range order {
+		ip := ips[i]
+		// Equal addresses are adjacent once sorted.
+		if n == 0 || ip != previous {
+			offset, _, _, err = r.lookupAddr(ip)
+			previous = ip
+		}
+		if err != nil {
+			errs = append(errs, LookupError{IP: ip, Err: err, Index: i})
+			continue
+		}
+		if offset == NotFound {
+			continue
+		}
+
+		record, ok := records[offset]
+		if ok {
+			slice.Index(i).Set(slice.Index(record.index))
+		} else {
+			record = batchRecord{
+				err:   r.decodeValueWithOptions(offset, slice.Index(i), l.options),
+				index: i,
+			}
+			records[offset] = record
+		}
+		if record.err != nil {
+			errs = append(errs, LookupError{IP: ip, Err: record.err, Index: i})
+		}
+	}
+
+	if len(errs) == 0 {
+		return nil
+	}
+	sort.Slice(errs, func(i, j int) bool {
+		return errs[i].Index < errs[j].Index
+	})
+	return errs
+}
@@ -0,0 +1,190 @@
+package maxminddb
+
+import (
+	"errors"
+	"math/rand"
+	"net/netip"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLookupMany(t *testing.T) {
+	reader, err := Open(testFile("GeoIP2-City-Test.mmdb"))
+	require.NoError(t, err)
+	defer reader.Close()
+
+	var ips []netip.Addr
+	for _, ip := range []string{
+		"81.2.69.160",
+		"2001:218::",
+		"10.0.0.1",
+		"81.2.69.142",
+		"::ffff:81.2.69.160",
+		"81.2.69.160",
+		"216.160.83.56",
+		"2001:218::",
+		"fe80::1%eth0",
+	} {
+		ips = append(ips, netip.MustParseAddr(ip))
+	}
+
+	var results []fullCity
+	require.NoError(t, reader.LookupMany(ips, &results))
+	require.Len(t, results, len(ips))
+	for i, ip := range ips {
+		var expected fullCity
+		require.NoError(t, reader.LookupNetip(ip, &expected))
+		assert.Equal(t, expected, results[i], ip)
+	}
+	assert.NotZero(t, results[0].City.GeoNameID)
+	assert.Zero(t, results[2], "10.0.0.1 has no record")
+
+	// The slice is reused and its old elements are cleared.
+	results = append(results[:0], fullCity{})
+	results[0].City.GeoNameID = 1
+	memory := &results[0]
+	require.NoError(t, reader.LookupMany(ips[2:4], &results))
+	assert.Same(t, memory, &results[0])
+	assert.Zero(t, results[0])
+
+	var maps []map[string]any
+	require.NoError(t, reader.LookupMany(ips, &maps))
+	for i, ip := range ips {
+		var expected map[string]any
+		require.NoError(t, reader.LookupNetip(ip, &expected))
+		assert.Equal(t, expected, maps[i], ip)
+	}
+
+	require.NoError(t, reader.LookupMany(nil, &results))
+	assert.Empty(t, results)
+
+	assert.EqualError(t, reader.LookupMany(ips, results), "results param must be a pointer to a slice")
+	var notSlice fullCity
+	assert.EqualError(t, reader.LookupMany(ips, &notSlice), "results param must be a pointer to a slice")
+}
+
+func TestLookupManyErrors(t *testing.T) {
+	reader, err := Open(testFile("GeoIP2-City-Test.mmdb"))
+	require.NoError(t, err)
+
+	// 81.2.69.160 and 81.2.69.161 are in the same network, so their record
+	// is decoded once, but its error is reported for each of them.
+	ips := []netip.Addr{
+		netip.MustParseAddr("81.2.69.160"),
+		{},
+		netip.MustParseAddr("10.0.0.1"),
+		netip.MustParseAddr("81.2.69.161"),
+	}
+	var results []struct {
+		City struct {
+			GeoNameID string `maxminddb:"geoname_id"`
+			Names     map[string]string
+		} `maxminddb:"city"`
+		Country struct {
+			IsoCode string `maxminddb:"iso_code"`
+		} `maxminddb:"country"`
+	}
+	err = reader.LookupMany(ips, &results)
+	var lookupErrs LookupErrors
+	require.True(t, errors.As(err, &lookupErrs))
+	require.Len(t, lookupErrs, 3)
+	for i, index := range []int{0, 1, 3} {
+		assert.Equal(t, index, lookupErrs[i].Index)
+		assert.Equal(t, ips[index], lookupErrs[i].IP)
+	}
+	assert.EqualError(t, lookupErrs[1].Err, "IP passed to LookupNetip is invalid")
+
+	var typeErr UnmarshalTypeError
+	require.True(t, errors.As(err, &typeErr))
+	assert.Equal(t, "city.geoname_id", typeErr.Path)
+
+	// The rest of the records are decoded.
+	assert.Equal(t, "GB", results[0].Country.IsoCode)
+	assert.Equal(t, "GB", results[3].Country.IsoCode)
+	assert.Zero(t, results[2])
+
+	require.NoError(t, reader.Close())
+	assert.EqualError(t, reader.LookupMany(ips, &results), "cannot call LookupMany on a closed database")
+
+	ipv4Reader, err := Open(testFile("MaxMind-DB-test-ipv4-24.mmdb"))
+	require.NoError(t, err)
+	defer ipv4Reader.Close()
+	var records []map[string]any
+	err = ipv4Reader.LookupMany(
+		[]netip.Addr{netip.MustParseAddr("1.1.1.1"), netip.MustParseAddr("2001:218::")},
+		&records,
+	)
+	require.True(t, errors.As(err, &lookupErrs))
+	require.Len(t, lookupErrs, 1)
+	assert.Equal(t, 1, lookupErrs[0].Index)
+	assert.NotEmpty(t, records[0])
+}
+
+func TestLookuperLookupMany(t *testing.T) {
+	reader, err := Open(testFile("GeoIP2-City-Test.mmdb"))
+	require.NoError(t, err)
+	defer reader.Close()
+
+	var results []map[string]any
+	err = reader.With(MaxDecodedBytes(1)).LookupMany(
+		[]netip.Addr{netip.MustParseAddr("81.2.69.160"), netip.MustParseAddr("10.0.0.1")},
+		&results,
+	)
+	var lookupErrs LookupErrors
+	require.True(t, errors.As(err, &lookupErrs))
+	require.Len(t, lookupErrs, 1)
+	assert.Equal(t, 0, lookupErrs[0].Index)
+	var limitErr DecodedSizeLimitError
+	assert.True(t, errors.As(err, &limitErr))
+}
+
+// skewedIPv4Addresses returns n addresses drawn from a pool of 10,000
+// random IPv4 addresses with a Zipf distribution, as in typical traffic,
+// where a few clients account for most requests.
+func skewedIPv4Addresses(n int) []netip.Addr {
+	//nolint:gosec // this is a test
+	r := rand.New(rand.NewSource(0))
+	pool := make([]netip.Addr, 10000)
+	ip := make([]byte, 4)
+	for i := range pool {
+		randomIPv4Address(r, ip)
+		pool[i] = netip.AddrFrom4([4]byte(ip))
+	}
+	zipf := rand.NewZipf(r, 1.1, 1, uint64(len(pool)-1))
+	ips := make([]netip.Addr, n)
+	for i := range ips {
+		ips[i] = pool[zipf.Uint64()]
+	}
+	return ips
+}
+
+func BenchmarkLookupMany(b *testing.B) {
+	db, err := Open("GeoLite2-City.mmdb")
+	require.NoError(b, err)
+	ips := skewedIPv4Addresses(100000)
+
+	b.Run("loop", func(b *testing.B) {
+		b.ReportAllocs()
+		results := make([]fullCity, len(ips))
+		for i := 0; i < b.N; i++ {
+			for j, ip := range ips {
+				results[j] = fullCity{}
+				if err := db.LookupNetip(ip, &results[j]); err != nil {
+					b.Error(err)
+				}
+			}
+		}
+	})
+	b.Run("LookupMany", func(b *testing.B) {
+		b.ReportAllocs()
+		var results []fullCity
+		for i := 0; i < b.N; i++ {
+			if err := db.LookupMany(ips, &results); err != nil {
+				b.Error(err)
+			}
+		}
+	})
+	assert.NoError(b, db.Close(), "error on close")
+}
@@ -0,0 +1,201 @@
+package maxminddb
+
+import (
+	"container/list"
+	"sync"
+	"sync/atomic"
+
+	"github.com/3JoB/go-reflect"
+)
+
+// RecordCache is a cache of decoded records, set with WithRecordCache. It
+// may be implemented with any cache library, e.g., as a sharded map or an
+// adapter to a third-party cache. LRUCache is an implementation in this
+// package, and the cachetest package tests implementations for conformance.
+//
+// Implementations must be safe for concurrent use. Get must only return a
+// value passed to Set with the same key; it may miss at any time, e.g.,
+// because the value was evicted or Set dropped it. The values are owned by
+// the cache once they are passed to Set and must not be modified by it.
+type RecordCache interface {
+	// Get returns the value cached under key, if any.
+	Get(key CacheKey) (value any, ok bool)
+	// Set caches value under key. cost is the approximate size of the
+	// value in bytes, e.g., for caches bounded by the total cost of their
+	// values.
+	Set(key CacheKey, value any, cost int)
+}
+
+// CacheKey identifies a decoded record in a RecordCache. It is comparable
+// and may be used as a map key.
+type CacheKey struct {
+	// Type is the type the record was decoded into, as the same record
+	// decodes to different values for different types.
+	Type reflect.Type
+	// Offset is the offset of the record in the data section.
+	Offset uintptr
+	// Generation identifies the Reader that decoded the record. Every
+	// Reader has a different generation, so a cache shared across Readers,
+	// e.g., when reopening a database after an update, never returns a
+	// record of another database.
+	Generation uint64
+}
+
+// readerGenerations is the generation of the most recently created Reader.
+var readerGenerations atomic.Uint64
+
+// WithRecordCache is an option for Open, FromBytes, and FromReader that
+// caches the records decoded by lookups and decodes, keyed by their offset
+// and the type of the result. Many addresses share the same record, so this
+// avoids decoding the record again for each of them.
+//
+// The cached values are never shared with the caller: the value decoded
+// for the caller is deep copied before it is passed to Set and the value
+// returned by Get is deep copied into the result. With a cache, the result
+// is always replaced rather than merged with its existing contents.
+// Results implementing the deserializer interface are not cached, nor are
+// decodes with the MaxDecodedBytes lookup option or that fail.
+func WithRecordCache(cache RecordCache) ReaderOption {
+	return func(o *readerOptions) {
+		o.recordCache = cache
+	}
+}
+
+// decodeCached decodes the record at offset into v using r.recordCache.
+func (r *Reader) decodeCached(d *decoder, offset uintptr, v reflect.Value) error {
+	key := CacheKey{Type: v.Type(), Offset: offset, Generation: r.generation}
+	if value, ok := r.recordCache.Get(key); ok {
+		if cached, ok := cachedValue(value, v.Type()); ok {
+			if r.sharesCachedValue(v.Type()) {
+				v.Set(cached)
+			} else {
+				v.Set(deepCopy(cached))
+			}
+			return nil
+		}
+	}
+
+	// The value is zeroed so that the cached value does not depend on what
+	// it held before.
+	v.Set(reflect.Zero(v.Type()))
+	newOffset, err := d.decode(uint(offset), v, 0)
+	if err != nil {
+		return err
+	}
+	if len(d.fieldErrors) > 0 {
+		return d.fieldErrors
+	}
+	value := v
+	if !r.sharesCachedValue(v.Type()) {
+		value = deepCopy(v)
+	}
+	r.recordCache.Set(key, value.Interface(), int(newOffset-uint(offset)))
+	return nil
+}
+
+// sharesCachedValue reports whether results of type t share the cached
+// value rather than receiving a copy, as map[string]any results do with
+// WithDecodedCache.
+func (r *Reader) sharesCachedValue(t reflect.Type) bool {
+	return r.decodedCache != nil && t == anyMapType
+}
+
+// cachedValue returns value, as returned by a RecordCache, as a
+// reflect.Value assignable to a value of type t. A value that is not, e.g.,
+// from a misbehaving cache, is treated as a miss.
+func cachedValue(value any, t reflect.Type) (reflect.Value, bool) {
+	v := reflect.ValueOf(value)
+	if !v.IsValid() {
+		// A nil interface, e.g., an any result for a null record.
+		if t.Kind() == reflect.Interface {
+			return reflect.Zero(t), true
+		}
+		return reflect.Value{}, false
+	}
+	if v.Type() == t || (t.Kind() == reflect.Interface && v.Type().AssignableTo(t)) {
+		return v, true
+	}
+	return reflect.Value{}, false
+}
+
+// LRUCache is a RecordCache that evicts the least recently used records
+// once the total cost of the cached records exceeds its capacity. It is
+// safe for concurrent use.
+type LRUCache struct {
+	entries map[CacheKey]*list.Element
+	order   *list.List
+	cost    int
+	maxCost int
+	mu      sync.Mutex
+}
+
+type lruCacheEntry struct {
+	value any
+	key   CacheKey
+	cost  int
+}
+
+// NewLRUCache returns an LRUCache holding records with a total cost of up
+// to maxCost, i.e., of around maxCost bytes as costed by the Reader.
+func NewLRUCache(maxCost int) *LRUCache {
+	return &LRUCache{
+		entries: map[CacheKey]*list.Element{},
+		order:   list.New(),
+		maxCost: maxCost,
+	}
+}
+
+// Get returns the value cached under key, if any.
+func (c *LRUCache) Get(key CacheKey) (any, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+	c.order.MoveToFront(e)
+	return e.Value.(*lruCacheEntry).value, true
+}
+
+// Set caches value under key, evicting the least recently used values as
+// needed. A value whose cost exceeds the capacity of the cache is not
+// cached. A cost of less than 1 is counted as 1.
+func (c *LRUCache) Set(key CacheKey, value any, cost int) {
+	cost = max(cost, 1)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if e, ok := c.entries[key]; ok {
+		c.remove(e)
+	}
+	if cost > c.maxCost {
+		return
+	}
+	for c.cost+cost > c.maxCost {
+		c.remove(c.order.Back())
+	}
+	c.entries[key] = c.order.PushFront(&lruCacheEntry{key: key, value: value, cost: cost})
+	c.cost += cost
+}
+
+// remove removes e from the cache. c.mu must be held.
+func (c *LRUCache) remove(e *list.Element) {
+	entry := c.order.Remove(e).(*lruCacheEntry)
+	delete(c.entries, entry.key)
+	c.cost -= entry.cost
+}
+
+// clear removes all cached values.
+func (c *LRUCache) clear() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries = map[CacheKey]*list.Element{}
+	c.order.Init()
+	c.cost = 0
+}
+
+// Len returns the number of cached values.
+func (c *LRUCache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.order.Len()
+}
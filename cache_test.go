@@ -0,0 +1,199 @@
+package maxminddb
+
+import (
+	"net"
+	"sync"
+	"testing"
+
+	"github.com/3JoB/go-reflect"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// countingCache is a RecordCache recording its use.
+type countingCache struct {
+	values map[CacheKey]any
+	gets   int
+	hits   int
+	sets   int
+	mu     sync.Mutex
+}
+
+func newCountingCache() *countingCache {
+	return &countingCache{values: map[CacheKey]any{}}
+}
+
+func (c *countingCache) Get(key CacheKey) (any, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.gets++
+	value, ok := c.values[key]
+	if ok {
+		c.hits++
+	}
+	return value, ok
+}
+
+func (c *countingCache) Set(key CacheKey, value any, cost int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.sets++
+	c.values[key] = value
+}
+
+type cachedCity struct {
+	City struct {
+		Names map[string]string `maxminddb:"names"`
+	} `maxminddb:"city"`
+	Subdivisions []struct {
+		IsoCode string `maxminddb:"iso_code"`
+	} `maxminddb:"subdivisions"`
+}
+
+func TestWithRecordCache(t *testing.T) {
+	cache := newCountingCache()
+	reader, err := Open(testFile("GeoIP2-City-Test.mmdb"), WithRecordCache(cache))
+	require.NoError(t, err)
+	defer reader.Close()
+	uncached, err := Open(testFile("GeoIP2-City-Test.mmdb"))
+	require.NoError(t, err)
+	defer uncached.Close()
+
+	ip := net.ParseIP("81.2.69.160")
+	var expected cachedCity
+	require.NoError(t, uncached.Lookup(ip, &expected))
+
+	var first cachedCity
+	require.NoError(t, reader.Lookup(ip, &first))
+	assert.Equal(t, expected, first)
+	assert.Equal(t, 1, cache.sets)
+	assert.Equal(t, 0, cache.hits)
+
+	// The cached value is not shared with the results.
+	first.City.Names["en"] = "Changed"
+	first.Subdivisions[0].IsoCode = "XXX"
+
+	var second cachedCity
+	require.NoError(t, reader.Lookup(ip, &second))
+	assert.Equal(t, expected, second)
+	assert.Equal(t, 1, cache.sets)
+	assert.Equal(t, 1, cache.hits)
+	second.City.Names["en"] = "Changed"
+
+	// The result is replaced rather than merged.
+	third := cachedCity{Subdivisions: make([]struct {
+		IsoCode string `maxminddb:"iso_code"`
+	}, 5)}
+	require.NoError(t, reader.Lookup(ip, &third))
+	assert.Equal(t, expected, third)
+
+	// Other types and records have their own entries.
+	var record any
+	require.NoError(t, reader.Lookup(ip, &record))
+	var expectedRecord any
+	require.NoError(t, uncached.Lookup(ip, &expectedRecord))
+	assert.Equal(t, expectedRecord, record)
+	require.NoError(t, reader.Lookup(ip, &record))
+	assert.Equal(t, expectedRecord, record)
+	assert.Equal(t, 2, cache.sets)
+	assert.Equal(t, 3, cache.hits)
+
+	// Decodes, e.g., by DecodePath, use the cache too.
+	offset, err := reader.LookupOffset(ip)
+	require.NoError(t, err)
+	var names map[string]string
+	require.NoError(t, reader.DecodePath(offset, &names, "city", "names"))
+	assert.Equal(t, expected.City.Names, names)
+	assert.Equal(t, 3, cache.sets)
+}
+
+func TestRecordCacheGenerations(t *testing.T) {
+	// A cache shared by two Readers keeps their records apart, even for the
+	// same database.
+	cache := newCountingCache()
+	first, err := Open(testFile("MaxMind-DB-test-ipv4-24.mmdb"), WithRecordCache(cache))
+	require.NoError(t, err)
+	defer first.Close()
+	second, err := Open(testFile("MaxMind-DB-test-ipv4-24.mmdb"), WithRecordCache(cache))
+	require.NoError(t, err)
+	defer second.Close()
+	assert.NotEqual(t, first.generation, second.generation)
+
+	for _, reader := range []*Reader{first, second, first} {
+		var record map[string]string
+		require.NoError(t, reader.Lookup(net.ParseIP("1.1.1.1"), &record))
+		assert.Equal(t, map[string]string{"ip": "1.1.1.1"}, record)
+	}
+	assert.Equal(t, 2, cache.sets)
+	assert.Equal(t, 1, cache.hits)
+}
+
+func TestRecordCacheNotUsed(t *testing.T) {
+	cache := newCountingCache()
+	reader, err := Open(testFile("MaxMind-DB-test-decoder.mmdb"), WithRecordCache(cache))
+	require.NoError(t, err)
+	defer reader.Close()
+	ip := net.ParseIP("::1.1.1.0")
+
+	// Deserializers are not cached.
+	d := &testDeserializer{}
+	require.NoError(t, reader.Lookup(ip, d))
+
+	// Nor are decodes with a limit.
+	var record any
+	require.NoError(t, reader.With(MaxDecodedBytes(1<<20)).Lookup(ip, &record))
+
+	// Nor failing decodes.
+	var wrongType struct {
+		Utf8String int `maxminddb:"utf8_string"`
+	}
+	assert.Error(t, reader.Lookup(ip, &wrongType))
+	assert.Equal(t, 1, cache.gets, "only the failing decode checked the cache")
+	assert.Zero(t, cache.sets)
+
+	// A cache returning a value of the wrong type is treated as missing.
+	offset, err := reader.LookupOffset(ip)
+	require.NoError(t, err)
+	var str struct {
+		Utf8String string `maxminddb:"utf8_string"`
+	}
+	cache.values[CacheKey{
+		Type:       reflect.TypeOf(&str).Elem(),
+		Offset:     offset,
+		Generation: reader.generation,
+	}] = 42
+	require.NoError(t, reader.Lookup(ip, &str))
+	assert.Equal(t, "unicode! ☯ - ♫", str.Utf8String)
+}
+
+func TestLRUCacheEviction(t *testing.T) {
+	c := NewLRUCache(10)
+	key := func(offset uintptr) CacheKey {
+		return CacheKey{Type: reflect.TypeOf(""), Offset: offset}
+	}
+
+	c.Set(key(1), "a", 4)
+	c.Set(key(2), "b", 4)
+	_, ok := c.Get(key(1))
+	assert.True(t, ok)
+
+	// 2 is the least recently used.
+	c.Set(key(3), "c", 4)
+	_, ok = c.Get(key(2))
+	assert.False(t, ok)
+	assert.Equal(t, 2, c.Len())
+
+	// Values larger than the cache are not cached, and replacing a value
+	// releases its cost.
+	c.Set(key(4), "d", 11)
+	_, ok = c.Get(key(4))
+	assert.False(t, ok)
+	c.Set(key(1), "a2", 6)
+	value, ok := c.Get(key(1))
+	assert.True(t, ok)
+	assert.Equal(t, "a2", value)
+	value, ok = c.Get(key(3))
+	assert.True(t, ok)
+	assert.Equal(t, "c", value)
+	assert.Equal(t, 10, c.cost)
+}
@@ -0,0 +1,150 @@
+// Package cachetest tests implementations of maxminddb.RecordCache for
+// conformance with the requirements documented on the interface, e.g.,
+//
+//	func TestCache(t *testing.T) {
+//		cachetest.Run(t, func() maxminddb.RecordCache {
+//			return newMyCache(1 << 20)
+//		})
+//	}
+//
+// Run the tests with the race detector to check that the cache is safe for
+// concurrent use.
+package cachetest
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/3JoB/go-reflect"
+
+	"github.com/3JoB/maxminddb-golang"
+)
+
+// Run runs the conformance tests on caches returned by newCache, which is
+// called once per test and must return an empty cache with room for at
+// least 100 values of cost 1.
+//
+// Caches may miss at any time, e.g., because they drop values under
+// contention, so the tests only check the values that are returned. Use
+// RunRetaining for caches that are expected to keep every value while they
+// have room for it.
+func Run(t *testing.T, newCache func() maxminddb.RecordCache) {
+	t.Helper()
+	run(t, newCache, false)
+}
+
+// RunRetaining runs the tests of Run and additionally requires that the
+// caches returned by newCache return every value passed to Set while they
+// have room for it, as LRUCache does.
+func RunRetaining(t *testing.T, newCache func() maxminddb.RecordCache) {
+	t.Helper()
+	run(t, newCache, true)
+}
+
+func run(t *testing.T, newCache func() maxminddb.RecordCache, retaining bool) {
+	t.Run("Miss", func(t *testing.T) {
+		cache := newCache()
+		if value, ok := cache.Get(key(0)); ok {
+			t.Errorf("Get on an empty cache returned %v", value)
+		}
+	})
+
+	t.Run("SetGet", func(t *testing.T) {
+		cache := newCache()
+		for i := 0; i < 100; i++ {
+			cache.Set(key(i), value(i), 1)
+		}
+		for i := 0; i < 100; i++ {
+			got, ok := cache.Get(key(i))
+			if !ok {
+				if retaining {
+					t.Errorf("Get(%v) missed", key(i))
+				}
+				continue
+			}
+			if got != value(i) {
+				t.Errorf("Get(%v) = %v; want %v", key(i), got, value(i))
+			}
+		}
+	})
+
+	t.Run("Overwrite", func(t *testing.T) {
+		cache := newCache()
+		cache.Set(key(0), "first", 1)
+		cache.Set(key(0), "second", 1)
+		got, ok := cache.Get(key(0))
+		if ok && got != "second" {
+			t.Errorf("Get after overwriting = %v; want second", got)
+		}
+		if !ok && retaining {
+			t.Error("Get after overwriting missed")
+		}
+	})
+
+	// Keys differing in a single field must not collide.
+	t.Run("DistinctKeys", func(t *testing.T) {
+		cache := newCache()
+		keys := []maxminddb.CacheKey{
+			{Type: reflect.TypeOf(""), Offset: 1, Generation: 1},
+			{Type: reflect.TypeOf(0), Offset: 1, Generation: 1},
+			{Type: reflect.TypeOf(""), Offset: 2, Generation: 1},
+			{Type: reflect.TypeOf(""), Offset: 1, Generation: 2},
+		}
+		for i, k := range keys {
+			cache.Set(k, i, 1)
+		}
+		for i, k := range keys {
+			got, ok := cache.Get(k)
+			if ok && got != i {
+				t.Errorf("Get(%v) = %v; want %v", k, got, i)
+			}
+			if !ok && retaining {
+				t.Errorf("Get(%v) missed", k)
+			}
+		}
+		if got, ok := cache.Get(maxminddb.CacheKey{Type: reflect.TypeOf(""), Offset: 3, Generation: 1}); ok {
+			t.Errorf("Get of a key that was not set returned %v", got)
+		}
+	})
+
+	t.Run("NilValue", func(t *testing.T) {
+		cache := newCache()
+		cache.Set(key(0), nil, 1)
+		got, ok := cache.Get(key(0))
+		if ok && got != nil {
+			t.Errorf("Get = %v; want nil", got)
+		}
+		if !ok && retaining {
+			t.Error("Get missed")
+		}
+	})
+
+	t.Run("Concurrent", func(t *testing.T) {
+		cache := newCache()
+		var wg sync.WaitGroup
+		for g := 0; g < 8; g++ {
+			wg.Add(1)
+			go func(g int) {
+				defer wg.Done()
+				for i := 0; i < 1000; i++ {
+					k := (g*1000 + i) % 150
+					cache.Set(key(k), value(k), 1)
+					if got, ok := cache.Get(key(k)); ok && got != value(k) {
+						t.Errorf("Get(%v) = %v; want %v", key(k), got, value(k))
+						return
+					}
+				}
+			}(g)
+		}
+		wg.Wait()
+	})
+}
+
+func key(i int) maxminddb.CacheKey {
+	return maxminddb.CacheKey{Type: reflect.TypeOf(""), Offset: uintptr(i), Generation: 1}
+}
+
+func value(i int) string {
+	return fmt.Sprintf("value %d", i)
+}
@@ -0,0 +1,47 @@
+package cachetest
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/3JoB/maxminddb-golang"
+)
+
+func TestLRUCache(t *testing.T) {
+	RunRetaining(t, func() maxminddb.RecordCache {
+		return maxminddb.NewLRUCache(100)
+	})
+}
+
+// mapCache is a cache without eviction, as a minimal implementation.
+type mapCache struct {
+	values sync.Map
+}
+
+func (c *mapCache) Get(key maxminddb.CacheKey) (any, bool) {
+	return c.values.Load(key)
+}
+
+func (c *mapCache) Set(key maxminddb.CacheKey, value any, _ int) {
+	c.values.Store(key, value)
+}
+
+func TestMapCache(t *testing.T) {
+	RunRetaining(t, func() maxminddb.RecordCache {
+		return &mapCache{}
+	})
+}
+
+// nopCache never caches anything, which conforms unless the cache is
+// expected to retain its values.
+type nopCache struct{}
+
+func (nopCache) Get(maxminddb.CacheKey) (any, bool) { return nil, false }
+
+func (nopCache) Set(maxminddb.CacheKey, any, int) {}
+
+func TestNopCache(t *testing.T) {
+	Run(t, func() maxminddb.RecordCache {
+		return nopCache{}
+	})
+}
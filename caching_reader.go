@@ -0,0 +1,229 @@
+package maxminddb
+
+import (
+	"container/list"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/3JoB/go-reflect"
+)
+
+const (
+	defaultCacheTTL        = 5 * time.Minute
+	defaultCacheMaxEntries = 4096
+)
+
+// CachingReader wraps a Reader with a read-through cache of decoded lookup
+// results keyed by IP address and result type. Results are cached as deep
+// copies, so callers may modify the results they receive. It is meant for
+// workloads that look up the same addresses repeatedly within a short time.
+//
+// All of the methods on CachingReader are thread-safe.
+type CachingReader struct {
+	reader     *Reader
+	entries    map[cachingReaderKey]*list.Element
+	order      *list.List
+	now        func() time.Time
+	generation uint64
+	ttl        time.Duration
+	maxEntries int
+	hits       atomic.Uint64
+	misses     atomic.Uint64
+	mu         sync.Mutex
+}
+
+// CachingReaderOption are options for NewCachingReader.
+type CachingReaderOption func(*CachingReader)
+
+// WithCacheTTL is an option for NewCachingReader that sets how long a result
+// is served from the cache after it was looked up. The default is five
+// minutes. A ttl of 0 or less keeps results until they are evicted.
+func WithCacheTTL(ttl time.Duration) CachingReaderOption {
+	return func(c *CachingReader) {
+		c.ttl = ttl
+	}
+}
+
+// WithCacheMaxEntries is an option for NewCachingReader that sets the
+// maximum number of cached results. The least recently used result is
+// evicted when the cache is full. The default is 4096.
+func WithCacheMaxEntries(n int) CachingReaderOption {
+	return func(c *CachingReader) {
+		c.maxEntries = max(n, 1)
+	}
+}
+
+// CacheStats holds counters describing the use of a CachingReader's cache.
+// It is returned by CachingReader.Stats.
+type CacheStats struct {
+	// Hits is the number of lookups answered from the cache.
+	Hits uint64
+	// Misses is the number of lookups passed on to the Reader.
+	Misses uint64
+	// Entries is the number of results currently cached, including
+	// expired results that have not been evicted yet.
+	Entries int
+}
+
+type cachingReaderKey struct {
+	typ reflect.Type
+	ip  string
+}
+
+type cachingReaderEntry struct {
+	expires time.Time
+	value   reflect.Value
+	network *net.IPNet
+	key     cachingReaderKey
+	ok      bool
+}
+
+// NewCachingReader returns a CachingReader for reader. The CachingReader
+// does not take ownership of reader; it must still be closed by the caller,
+// e.g., after replacing it with Swap.
+func NewCachingReader(reader *Reader, options ...CachingReaderOption) *CachingReader {
+	c := &CachingReader{
+		reader:     reader,
+		entries:    map[cachingReaderKey]*list.Element{},
+		order:      list.New(),
+		now:        time.Now,
+		ttl:        defaultCacheTTL,
+		maxEntries: defaultCacheMaxEntries,
+	}
+	for _, option := range options {
+		option(c)
+	}
+	return c
+}
+
+// Lookup retrieves the database record for ip and stores it in the value
+// pointed to by result, from the cache if possible. It behaves like
+// Reader.Lookup except that result is always replaced rather than merged
+// with its existing contents. Results implementing the deserializer
+// interface are not cached.
+func (c *CachingReader) Lookup(ip net.IP, result any) error {
+	_, _, err := c.LookupNetwork(ip, result)
+	return err
+}
+
+// LookupNetwork retrieves the database record for ip and stores it in the
+// value pointed to by result, from the cache if possible. It behaves like
+// Reader.LookupNetwork except that result is always replaced rather than
+// merged with its existing contents. Results implementing the deserializer
+// interface are not cached. Lookups that return an error are not cached.
+func (c *CachingReader) LookupNetwork(
+	ip net.IP,
+	result any,
+) (network *net.IPNet, ok bool, err error) {
+	rv := reflect.ValueOf(result)
+	if _, isDeserializer := result.(deserializer); isDeserializer ||
+		ip == nil || rv.Kind() != reflect.Ptr || rv.IsNil() {
+		return c.Reader().LookupNetwork(ip, result)
+	}
+	if ipV4Address := ip.To4(); ipV4Address != nil {
+		ip = ipV4Address
+	}
+	key := cachingReaderKey{typ: rv.Type(), ip: string(ip)}
+
+	c.mu.Lock()
+	reader, generation := c.reader, c.generation
+	if e, found := c.entries[key]; found {
+		entry := e.Value.(*cachingReaderEntry)
+		if c.ttl <= 0 || c.now().Before(entry.expires) {
+			c.order.MoveToFront(e)
+			c.mu.Unlock()
+			c.hits.Add(1)
+			rv.Elem().Set(deepCopy(entry.value))
+			return copyIPNet(entry.network), entry.ok, nil
+		}
+		c.order.Remove(e)
+		delete(c.entries, key)
+	}
+	c.mu.Unlock()
+	c.misses.Add(1)
+
+	// The result is zeroed so that the cached value does not depend on what
+	// the result held before.
+	rv.Elem().Set(reflect.Zero(rv.Type().Elem()))
+	network, ok, err = reader.LookupNetwork(ip, result)
+	if err != nil {
+		return network, ok, err
+	}
+
+	entry := &cachingReaderEntry{
+		key:     key,
+		value:   deepCopy(rv.Elem()),
+		network: copyIPNet(network),
+		ok:      ok,
+	}
+	c.mu.Lock()
+	// Results from a Reader that has since been swapped out are not cached.
+	if c.generation == generation {
+		entry.expires = c.now().Add(c.ttl)
+		c.add(entry)
+	}
+	c.mu.Unlock()
+	return network, ok, nil
+}
+
+// add adds entry to the cache, evicting the least recently used entry if
+// the cache is full. c.mu must be held.
+func (c *CachingReader) add(entry *cachingReaderEntry) {
+	if e, ok := c.entries[entry.key]; ok {
+		e.Value = entry
+		c.order.MoveToFront(e)
+		return
+	}
+	if c.order.Len() >= c.maxEntries {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*cachingReaderEntry).key)
+	}
+	c.entries[entry.key] = c.order.PushFront(entry)
+}
+
+// Reader returns the Reader lookups are currently passed on to.
+func (c *CachingReader) Reader() *Reader {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.reader
+}
+
+// Swap replaces the Reader lookups are passed on to with reader, e.g.,
+// after a database update, and discards all cached results. It returns the
+// previous Reader, which the caller should close once lookups in progress
+// have finished.
+func (c *CachingReader) Swap(reader *Reader) *Reader {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	previous := c.reader
+	c.reader = reader
+	c.generation++
+	c.entries = map[cachingReaderKey]*list.Element{}
+	c.order.Init()
+	return previous
+}
+
+// Stats returns the current values of the cache's counters.
+func (c *CachingReader) Stats() CacheStats {
+	c.mu.Lock()
+	entries := c.order.Len()
+	c.mu.Unlock()
+	return CacheStats{
+		Hits:    c.hits.Load(),
+		Misses:  c.misses.Load(),
+		Entries: entries,
+	}
+}
+
+func copyIPNet(network *net.IPNet) *net.IPNet {
+	if network == nil {
+		return nil
+	}
+	return &net.IPNet{
+		IP:   append(net.IP(nil), network.IP...),
+		Mask: append(net.IPMask(nil), network.Mask...),
+	}
+}
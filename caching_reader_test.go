@@ -0,0 +1,173 @@
+package maxminddb
+
+import (
+	"math/rand"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCachingReader(t *testing.T) {
+	reader, err := Open(testFile("GeoIP2-City-Test.mmdb"))
+	require.NoError(t, err)
+	c := NewCachingReader(reader)
+
+	type city struct {
+		City struct {
+			Names map[string]string `maxminddb:"names"`
+		} `maxminddb:"city"`
+	}
+	ip := net.ParseIP("81.2.69.142")
+
+	var first city
+	network, ok, err := c.LookupNetwork(ip, &first)
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, "81.2.69.142/31", network.String())
+	assert.Equal(t, "London", first.City.Names["en"])
+	assert.Equal(t, CacheStats{Misses: 1, Entries: 1}, c.Stats())
+
+	// Modifying a result does not affect the cached value.
+	first.City.Names["en"] = "modified"
+	network.IP[0] = 0
+
+	second := city{}
+	second.City.Names = map[string]string{"xx": "replaced"}
+	network, ok, err = c.LookupNetwork(net.ParseIP("::ffff:81.2.69.142"), &second)
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, "81.2.69.142/31", network.String())
+	assert.Equal(t, "London", second.City.Names["en"])
+	assert.NotContains(t, second.City.Names, "xx")
+	assert.Equal(t, CacheStats{Hits: 1, Misses: 1, Entries: 1}, c.Stats())
+
+	// Results are cached per result type.
+	var record any
+	require.NoError(t, c.Lookup(ip, &record))
+	require.NoError(t, c.Lookup(ip, &record))
+	assert.Equal(t, CacheStats{Hits: 2, Misses: 2, Entries: 2}, c.Stats())
+
+	// Addresses without a record are cached as well.
+	network, ok, err = c.LookupNetwork(net.ParseIP("10.0.0.1"), &record)
+	require.NoError(t, err)
+	assert.False(t, ok)
+	cachedNetwork, ok, err := c.LookupNetwork(net.ParseIP("10.0.0.1"), &record)
+	require.NoError(t, err)
+	assert.False(t, ok)
+	assert.Equal(t, network, cachedNetwork)
+	assert.Nil(t, record)
+	assert.Equal(t, CacheStats{Hits: 3, Misses: 3, Entries: 3}, c.Stats())
+
+	// Errors are returned as from the Reader.
+	assert.EqualError(t, c.Lookup(nil, &record), "IP passed to Lookup cannot be nil")
+	assert.EqualError(t, c.Lookup(ip, record), "result param must be a pointer")
+
+	require.NoError(t, reader.Close())
+}
+
+func TestCachingReaderTTL(t *testing.T) {
+	reader, err := Open(testFile("GeoIP2-City-Test.mmdb"))
+	require.NoError(t, err)
+
+	now := time.Unix(0, 0)
+	c := NewCachingReader(reader, WithCacheTTL(time.Minute))
+	c.now = func() time.Time { return now }
+
+	var record any
+	ip := net.ParseIP("81.2.69.142")
+	require.NoError(t, c.Lookup(ip, &record))
+	now = now.Add(59 * time.Second)
+	require.NoError(t, c.Lookup(ip, &record))
+	assert.Equal(t, CacheStats{Hits: 1, Misses: 1, Entries: 1}, c.Stats())
+
+	now = now.Add(time.Second)
+	require.NoError(t, c.Lookup(ip, &record))
+	assert.Equal(t, CacheStats{Hits: 1, Misses: 2, Entries: 1}, c.Stats())
+
+	require.NoError(t, reader.Close())
+}
+
+func TestCachingReaderMaxEntries(t *testing.T) {
+	reader, err := Open(testFile("GeoIP2-City-Test.mmdb"))
+	require.NoError(t, err)
+	c := NewCachingReader(reader, WithCacheMaxEntries(2))
+
+	var record any
+	for _, ip := range []string{"81.2.69.142", "81.2.69.160", "81.2.69.142", "2.125.160.216", "81.2.69.160"} {
+		require.NoError(t, c.Lookup(net.ParseIP(ip), &record))
+	}
+	// 81.2.69.160 was evicted by 2.125.160.216 as 81.2.69.142 was used more
+	// recently.
+	assert.Equal(t, CacheStats{Hits: 1, Misses: 4, Entries: 2}, c.Stats())
+
+	require.NoError(t, reader.Close())
+}
+
+func TestCachingReaderSwap(t *testing.T) {
+	reader, err := Open(testFile("GeoIP2-City-Test.mmdb"))
+	require.NoError(t, err)
+	c := NewCachingReader(reader)
+
+	var record any
+	ip := net.ParseIP("81.2.69.142")
+	require.NoError(t, c.Lookup(ip, &record))
+	assert.NotNil(t, record)
+
+	newReader, err := Open(testFile("GeoIP2-Country-Test.mmdb"))
+	require.NoError(t, err)
+	assert.Same(t, reader, c.Swap(newReader))
+	assert.Same(t, newReader, c.Reader())
+	assert.Equal(t, 0, c.Stats().Entries)
+	require.NoError(t, reader.Close())
+
+	var country struct {
+		City map[string]any `maxminddb:"city"`
+	}
+	require.NoError(t, c.Lookup(ip, &country))
+	assert.Nil(t, country.City)
+	assert.Equal(t, CacheStats{Misses: 2, Entries: 1}, c.Stats())
+
+	require.NoError(t, newReader.Close())
+}
+
+func BenchmarkCachingReader(b *testing.B) {
+	db, err := Open("GeoLite2-City.mmdb")
+	require.NoError(b, err)
+
+	// Draw addresses from a pool with a Zipfian distribution so that a few
+	// addresses account for most lookups.
+	//nolint:gosec // this is a test
+	r := rand.New(rand.NewSource(0))
+	ips := make([]net.IP, 100000)
+	for i := range ips {
+		ips[i] = make(net.IP, 4)
+		randomIPv4Address(r, ips[i])
+	}
+	zipf := rand.NewZipf(r, 1.1, 1, uint64(len(ips)-1))
+	lookups := make([]net.IP, 1<<16)
+	for i := range lookups {
+		lookups[i] = ips[zipf.Uint64()]
+	}
+
+	b.Run("Reader", func(b *testing.B) {
+		var result fullCity
+		for i := 0; i < b.N; i++ {
+			if err := db.Lookup(lookups[i%len(lookups)], &result); err != nil {
+				b.Error(err)
+			}
+		}
+	})
+	b.Run("CachingReader", func(b *testing.B) {
+		c := NewCachingReader(db)
+		var result fullCity
+		for i := 0; i < b.N; i++ {
+			if err := c.Lookup(lookups[i%len(lookups)], &result); err != nil {
+				b.Error(err)
+			}
+		}
+	})
+	assert.NoError(b, db.Close(), "error on close")
+}
@@ -0,0 +1,200 @@
+package maxminddb
+
+import (
+	"encoding"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/3JoB/go-reflect"
+)
+
+// CheckType checks that t, a result type such as a struct, is one the
+// package can decode into, without a database. It is meant for init-time
+// assertions on result types. All struct types reachable from t are
+// checked for:
+//
+//   - fields with the same key in the same struct, or at the same depth of
+//     its inline structs, of which only one is decoded;
+//   - fields of types that cannot be decoded into, such as channels,
+//     functions, and complex numbers, and maps whose keys are neither
+//     strings nor integers;
+//   - malformed tag options, e.g., an unknown option or inline on a field
+//     that is not a struct;
+//   - embedded fields that are not structs; and
+//   - tags on unexported fields, which are not decoded.
+//
+// The problems are reported together, as errors joined with errors.Join. A
+// reflect.Type from the standard library's reflect package may be converted
+// with reflect.ToType.
+func CheckType(t reflect.Type) error {
+	if t == nil {
+		return errors.New("maxminddb: CheckType called with a nil type")
+	}
+	c := typeChecker{seen: map[reflect.Type]bool{}}
+	c.check(t, t.String())
+	return errors.Join(c.errs...)
+}
+
+var textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+
+type typeChecker struct {
+	seen map[reflect.Type]bool
+	errs []error
+}
+
+func (c *typeChecker) errorf(path, format string, args ...any) {
+	c.errs = append(c.errs, fmt.Errorf("maxminddb: %s: "+format, append([]any{path}, args...)...))
+}
+
+// check checks the type t of the value at path, e.g., "geoip2.City.Names".
+func (c *typeChecker) check(t reflect.Type, path string) {
+	switch t.Kind() {
+	case reflect.Ptr:
+		c.check(t.Elem(), path)
+	case reflect.Slice, reflect.Array:
+		c.check(t.Elem(), path+"[]")
+	case reflect.Map:
+		switch t.Key().Kind() {
+		case reflect.String,
+			reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+			reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		default:
+			if !reflect.PtrTo(t.Key()).Implements(textUnmarshalerType) {
+				c.errorf(
+					path,
+					"map key type %s is neither a string nor an integer and does not implement encoding.TextUnmarshaler",
+					t.Key(),
+				)
+			}
+		}
+		c.check(t.Elem(), path+"[*]")
+	case reflect.Chan, reflect.Func, reflect.Complex64, reflect.Complex128, reflect.UnsafePointer:
+		c.errorf(path, "type %s cannot be decoded into", t)
+	case reflect.Struct:
+		if c.seen[t] {
+			return
+		}
+		c.seen[t] = true
+		c.checkStruct(t, path)
+	}
+}
+
+func (c *typeChecker) checkStruct(t reflect.Type, path string) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		fieldPath := path + "." + field.Name
+		c.checkTag(field, fieldPath)
+		tag, tagged := field.Tag.Lookup("maxminddb")
+		if field.Anonymous && inlineStructType(field.Type) == nil && tag != "-" {
+			c.errorf(fieldPath, "embedded field of type %s is not a struct or a pointer to one", field.Type)
+			continue
+		}
+		if _, _, skip := parseFieldTag(field, ""); skip {
+			if tagged && tag != "-" && field.Name != "_" {
+				c.errorf(fieldPath, "tag on unexported field, which is not decoded")
+			}
+			continue
+		}
+		c.check(field.Type, fieldPath)
+	}
+
+	// The conflicts are found by the code that builds the fields the
+	// decoder uses so that the two agree on which field is decoded.
+	newFieldsType(t, "", func(ignored, decoded []int, key string) {
+		if key == "" {
+			c.errorf(
+				path+"."+fieldIndexPath(t, ignored),
+				"inline struct is ignored as %s inlines the same struct",
+				fieldIndexPath(t, decoded),
+			)
+			return
+		}
+		c.errorf(
+			path+"."+fieldIndexPath(t, ignored),
+			"key %q is also used by %s, which is decoded instead",
+			key,
+			fieldIndexPath(t, decoded),
+		)
+	})
+}
+
+// checkTag checks the options in the maxminddb tag of field.
+func (c *typeChecker) checkTag(field reflect.StructField, path string) {
+	tag, ok := field.Tag.Lookup("maxminddb")
+	if !ok || tag == "-" {
+		return
+	}
+	_, options, hasOptions := strings.Cut(tag, ",")
+	if hasOptions && options == "" && tag != "-," {
+		c.errorf(path, "tag %q has an empty option", tag)
+	}
+	var hasLang, hasFallback bool
+	for options != "" {
+		var option string
+		option, options, _ = strings.Cut(options, ",")
+		name, value, hasValue := strings.Cut(option, "=")
+		switch {
+		case field.Name == "_":
+			if name != "database" || value == "" {
+				c.errorf(path, "option %q is not valid on a blank field", option)
+			}
+		case name == "inline" && !hasValue:
+			if inlineStructType(field.Type) == nil {
+				c.errorf(path, "inline option on a field of type %s, which is not a struct", field.Type)
+			}
+		case name == "lang" || name == "fallback":
+			if len(splitLanguages(value)) != strings.Count(value, "|")+strings.Count(value, ";")+1 {
+				c.errorf(path, "option %q has an empty language", option)
+			}
+			hasLang = hasLang || name == "lang"
+			hasFallback = hasFallback || name == "fallback"
+		case name == "remain" && !hasValue:
+			if field.Type.Kind() != reflect.Map || field.Type.Key().Kind() != reflect.String {
+				c.errorf(path, "remain option on a field of type %s, which is not a map with string keys", field.Type)
+			}
+		case name == "required" && !hasValue:
+		case (name == "string" || name == "boolnum") && !hasValue:
+			if !convertible(field.Type, name) {
+				c.errorf(path, "%s option on a field of type %s, which it does not apply to", name, field.Type)
+			}
+		default:
+			c.errorf(path, "unknown tag option %q", option)
+		}
+	}
+	if hasFallback && !hasLang {
+		c.errorf(path, "fallback option without a lang option")
+	}
+}
+
+// convertible reports whether the string or boolnum option, named option,
+// applies to fields of type t.
+func convertible(t reflect.Type, option string) bool {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	switch t.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Bool:
+		return true
+	case reflect.Float32, reflect.Float64, reflect.String:
+		return option == "string"
+	}
+	return false
+}
+
+// fieldIndexPath returns the names of the nested fields of the struct type
+// t with the index sequence index, separated by ".".
+func fieldIndexPath(t reflect.Type, index []int) string {
+	names := make([]string, len(index))
+	for i, x := range index {
+		for t.Kind() == reflect.Ptr {
+			t = t.Elem()
+		}
+		field := t.Field(x)
+		names[i] = field.Name
+		t = field.Type
+	}
+	return strings.Join(names, ".")
+}
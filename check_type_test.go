@@ -0,0 +1,150 @@
+package maxminddb
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/3JoB/go-reflect"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type checkTypeNames struct {
+	Names map[string]string `maxminddb:"names"`
+}
+
+type checkTypeCity struct {
+	_ struct{} `maxminddb:",database=GeoIP2-City"`
+
+	City struct {
+		GeoNameID uint   `maxminddb:"geoname_id"`
+		Name      string `maxminddb:"names,lang=pt-BR|pt,fallback=en"`
+	} `maxminddb:"city"`
+	Country      *checkTypeNames  `maxminddb:"country,required"`
+	Subdivisions []checkTypeNames `maxminddb:"subdivisions"`
+	Extra        map[uint32]any   `maxminddb:"extra"`
+	Offset       uintptr          `maxminddb:"traits"`
+	Ignored      chan int         `maxminddb:"-"`
+	Inline       checkTypeInline  `maxminddb:",inline"`
+	Remain       map[string]any   `maxminddb:",remain"`
+	Dash         string           `maxminddb:"-,"`
+	Port         *uint16          `maxminddb:"port,string"`
+	IsTor        bool             `maxminddb:"is_tor,boolnum"`
+	internal     chan int
+}
+
+type checkTypeInline struct {
+	Latitude  float64 `maxminddb:"latitude"`
+	Longitude float64 `maxminddb:"longitude"`
+	// Shadowed by the field of the enclosing struct, as intended.
+	City any `maxminddb:"city"`
+}
+
+func TestCheckType(t *testing.T) {
+	assert.NoError(t, CheckType(reflect.TypeOf(checkTypeCity{})))
+	assert.NoError(t, CheckType(reflect.TypeOf(&checkTypeCity{})))
+	assert.NoError(t, CheckType(reflect.TypeOf(map[string]any{})))
+	assert.Error(t, CheckType(nil))
+}
+
+type checkTypeInvalidInlineA struct {
+	Name string `maxminddb:"name"`
+}
+
+type checkTypeInvalidInlineB struct {
+	Label string `maxminddb:"name"`
+}
+
+type checkTypeEmbedded string
+
+type checkTypeInvalid struct {
+	A        string `maxminddb:"key"`
+	B        string `maxminddb:"key"`
+	Callback func()
+	Channel  chan int           `maxminddb:"channel"`
+	Complex  complex128         `maxminddb:"complex"`
+	Keys     map[float64]string `maxminddb:"keys"`
+	Nested   []struct {
+		Values map[string]complex64 `maxminddb:"values"`
+	} `maxminddb:"nested"`
+	First     checkTypeInvalidInlineA  `maxminddb:",inline"`
+	Second    *checkTypeInvalidInlineB `maxminddb:",inline"`
+	Again     checkTypeInvalidInlineA  `maxminddb:",inline"`
+	NotStruct string                   `maxminddb:"not_struct,inline"`
+	Unknown   string                   `maxminddb:"unknown,omitempty"`
+	Empty     string                   `maxminddb:"empty,"`
+	Language  string                   `maxminddb:"names,lang="`
+	Fallback  string                   `maxminddb:"other_names,fallback=en"`
+	Separator string                   `maxminddb:"more_names,lang=en;|de"`
+	Rest      []any                    `maxminddb:",remain"`
+	Ratio     float64                  `maxminddb:"ratio,boolnum"`
+	List      []string                 `maxminddb:"list,string"`
+	checkTypeEmbedded
+	_      struct{} `maxminddb:",inline"`
+	hidden string   `maxminddb:"hidden"`
+}
+
+func TestCheckTypeProblems(t *testing.T) {
+	err := CheckType(reflect.TypeOf(checkTypeInvalid{}))
+	require.Error(t, err)
+
+	var joined interface{ Unwrap() []error }
+	require.True(t, errors.As(err, &joined))
+	var messages []string
+	for _, err := range joined.Unwrap() {
+		messages = append(messages, err.Error())
+	}
+
+	prefix := "maxminddb: maxminddb.checkTypeInvalid."
+	assert.ElementsMatch(
+		t,
+		[]string{
+			prefix + `Callback: type func() cannot be decoded into`,
+			prefix + `Channel: type chan int cannot be decoded into`,
+			prefix + `Complex: type complex128 cannot be decoded into`,
+			prefix + `Keys: map key type float64 is neither a string nor an integer ` +
+				`and does not implement encoding.TextUnmarshaler`,
+			prefix + `Nested[].Values[*]: type complex64 cannot be decoded into`,
+			prefix + `NotStruct: inline option on a field of type string, which is not a struct`,
+			prefix + `Unknown: unknown tag option "omitempty"`,
+			prefix + `Empty: tag "empty," has an empty option`,
+			prefix + `Language: option "lang=" has an empty language`,
+			prefix + `Fallback: fallback option without a lang option`,
+			prefix + `Separator: option "lang=en;|de" has an empty language`,
+			prefix + `Rest: remain option on a field of type []interface {}, which is not a map with string keys`,
+			prefix + `checkTypeEmbedded: embedded field of type maxminddb.checkTypeEmbedded ` +
+				`is not a struct or a pointer to one`,
+			prefix + `_: option "inline" is not valid on a blank field`,
+			prefix + `hidden: tag on unexported field, which is not decoded`,
+			prefix + `Ratio: boolnum option on a field of type float64, which it does not apply to`,
+			prefix + `List: string option on a field of type []string, which it does not apply to`,
+			prefix + `A: key "key" is also used by B, which is decoded instead`,
+			prefix + `Second.Label: key "name" is also used by First.Name, which is decoded instead`,
+			prefix + `Again: inline struct is ignored as First inlines the same struct`,
+		},
+		messages,
+	)
+}
+
+type checkTypeDuplicate struct {
+	A string `maxminddb:"key"`
+	B string `maxminddb:"key"`
+}
+
+// TestCheckTypeAgreesWithDecoder checks that the field CheckType reports as
+// decoded is the one the decoder fills.
+func TestCheckTypeAgreesWithDecoder(t *testing.T) {
+	assert.EqualError(
+		t,
+		CheckType(reflect.TypeOf(checkTypeDuplicate{})),
+		`maxminddb: maxminddb.checkTypeDuplicate.A: key "key" is also used by B, which is decoded instead`,
+	)
+
+	// {"key": "A"}
+	d := decoder{buffer: []byte{0xe1, 0x43, 'k', 'e', 'y', 0x41, 'A'}}
+	var result checkTypeDuplicate
+	_, err := d.decode(0, reflect.ValueOf(&result), 0)
+	require.NoError(t, err)
+	assert.Empty(t, result.A)
+	assert.Equal(t, "A", result.B)
+}
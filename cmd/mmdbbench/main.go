@@ -0,0 +1,381 @@
+// Command mmdbbench runs reproducible lookup benchmarks against a MaxMind DB
+// file. It is intended for comparing database builds and reader versions
+// outside of go test.
+//
+// Usage:
+//
+//	mmdbbench [flags] database.mmdb
+//
+// The workload is selected with -workload:
+//
+//	random4  random IPv4 addresses
+//	random6  random IPv6 addresses
+//	file     addresses read from the file given with -file, one per line
+//	scan     the first address of each network in the database, in order
+//
+// The result shape is selected with -shape:
+//
+//	interface  decode into an any value
+//	small      decode the country ISO code into a small struct
+//	city       decode into the full City struct from the structs package
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"math/rand"
+	"net"
+	"os"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/3JoB/maxminddb-golang"
+	"github.com/3JoB/maxminddb-golang/structs"
+)
+
+// The number of latency samples kept per worker.
+const reservoirSize = 100000
+
+type config struct {
+	database string
+	workload string
+	file     string
+	shape    string
+	parallel int
+	duration time.Duration
+	seed     int64
+	json     bool
+}
+
+// Report holds the results of a benchmark run.
+type Report struct {
+	Database      string           `json:"database"`
+	DatabaseType  string           `json:"database_type"`
+	BuildEpoch    uint             `json:"build_epoch"`
+	Workload      string           `json:"workload"`
+	Shape         string           `json:"shape"`
+	Parallel      int              `json:"parallel"`
+	Duration      time.Duration    `json:"duration_ns"`
+	Lookups       uint64           `json:"lookups"`
+	Found         uint64           `json:"found"`
+	Errors        uint64           `json:"errors"`
+	LookupsPerSec float64          `json:"lookups_per_sec"`
+	Latency       map[string]int64 `json:"latency_ns"`
+	AllocsPerOp   float64          `json:"allocs_per_op"`
+	BytesPerOp    float64          `json:"bytes_per_op"`
+}
+
+func main() {
+	var cfg config
+	flag.StringVar(&cfg.workload, "workload", "random4", "workload: random4, random6, file, or scan")
+	flag.StringVar(&cfg.file, "file", "", "file of addresses for the file workload")
+	flag.StringVar(&cfg.shape, "shape", "interface", "result shape: interface, small, or city")
+	flag.IntVar(&cfg.parallel, "parallel", 1, "number of concurrent workers")
+	flag.DurationVar(&cfg.duration, "duration", 10*time.Second, "how long to run the benchmark")
+	flag.Int64Var(&cfg.seed, "seed", 1, "seed for the random workloads")
+	flag.BoolVar(&cfg.json, "json", false, "output the report as JSON")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [flags] database.mmdb\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if flag.NArg() != 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	cfg.database = flag.Arg(0)
+
+	report, err := run(cfg)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if cfg.json {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(report); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+	printReport(report)
+}
+
+func run(cfg config) (*Report, error) {
+	if cfg.parallel < 1 {
+		return nil, errors.New("-parallel must be at least 1")
+	}
+	newResult, err := resultShape(cfg.shape)
+	if err != nil {
+		return nil, err
+	}
+
+	db, err := maxminddb.Open(cfg.database)
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	addresses, err := loadAddresses(cfg, db)
+	if err != nil {
+		return nil, err
+	}
+
+	workers := make([]*worker, cfg.parallel)
+	for i := range workers {
+		workers[i] = &worker{
+			db:        db,
+			workload:  cfg.workload,
+			addresses: addresses,
+			next:      i * len(addresses) / cfg.parallel,
+			result:    newResult(),
+			//nolint:gosec // not used for security
+			rand: rand.New(rand.NewSource(cfg.seed + int64(i))),
+		}
+	}
+
+	runtime.GC()
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+
+	var wg sync.WaitGroup
+	deadline := time.Now().Add(cfg.duration)
+	start := time.Now()
+	for _, w := range workers {
+		wg.Add(1)
+		go func(w *worker) {
+			defer wg.Done()
+			w.run(deadline)
+		}(w)
+	}
+	wg.Wait()
+	elapsed := time.Since(start)
+
+	runtime.ReadMemStats(&after)
+
+	report := &Report{
+		Database:     cfg.database,
+		DatabaseType: db.Metadata.DatabaseType,
+		BuildEpoch:   db.Metadata.BuildEpoch,
+		Workload:     cfg.workload,
+		Shape:        cfg.shape,
+		Parallel:     cfg.parallel,
+		Duration:     elapsed,
+		Latency:      map[string]int64{},
+	}
+	var samples []time.Duration
+	var maxLatency time.Duration
+	for _, w := range workers {
+		report.Lookups += w.lookups
+		report.Found += w.found
+		report.Errors += w.errors
+		samples = append(samples, w.samples...)
+		maxLatency = max(maxLatency, w.max)
+	}
+	if report.Lookups > 0 {
+		report.LookupsPerSec = float64(report.Lookups) / elapsed.Seconds()
+		report.AllocsPerOp = float64(after.Mallocs-before.Mallocs) / float64(report.Lookups)
+		report.BytesPerOp = float64(after.TotalAlloc-before.TotalAlloc) / float64(report.Lookups)
+	}
+
+	sort.Slice(samples, func(i, j int) bool { return samples[i] < samples[j] })
+	for _, p := range []struct {
+		name       string
+		percentile float64
+	}{
+		{"p50", 50},
+		{"p90", 90},
+		{"p99", 99},
+		{"p99.9", 99.9},
+	} {
+		report.Latency[p.name] = int64(percentile(samples, p.percentile))
+	}
+	// The samples are a subset of the lookups, so the maximum is tracked
+	// separately.
+	report.Latency["max"] = int64(maxLatency)
+	return report, nil
+}
+
+func resultShape(shape string) (func() any, error) {
+	switch shape {
+	case "interface":
+		return func() any { return new(any) }, nil
+	case "small":
+		return func() any {
+			return new(struct {
+				Country struct {
+					ISOCode string `maxminddb:"iso_code"`
+				} `maxminddb:"country"`
+			})
+		}, nil
+	case "city":
+		return func() any { return new(structs.MMDB_City) }, nil
+	default:
+		return nil, fmt.Errorf("unknown result shape: %q", shape)
+	}
+}
+
+func loadAddresses(cfg config, db *maxminddb.Reader) ([]net.IP, error) {
+	switch cfg.workload {
+	case "random4", "random6":
+		return nil, nil
+	case "file":
+		if cfg.file == "" {
+			return nil, errors.New("the file workload requires -file")
+		}
+		return readAddresses(cfg.file)
+	case "scan":
+		var addresses []net.IP
+		networks := db.Networks(maxminddb.SkipAliasedNetworks)
+		for networks.Next() {
+			var record struct{}
+			network, err := networks.Network(&record)
+			if err != nil {
+				return nil, err
+			}
+			addresses = append(addresses, network.IP)
+		}
+		if err := networks.Err(); err != nil {
+			return nil, err
+		}
+		if len(addresses) == 0 {
+			return nil, errors.New("the database contains no networks to scan")
+		}
+		return addresses, nil
+	default:
+		return nil, fmt.Errorf("unknown workload: %q", cfg.workload)
+	}
+}
+
+func readAddresses(file string) ([]net.IP, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var addresses []net.IP
+	scanner := bufio.NewScanner(f)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		ip := net.ParseIP(text)
+		if ip == nil {
+			return nil, fmt.Errorf("%s:%d: invalid IP address %q", file, line, text)
+		}
+		addresses = append(addresses, ip)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(addresses) == 0 {
+		return nil, fmt.Errorf("%s contains no addresses", file)
+	}
+	return addresses, nil
+}
+
+type worker struct {
+	db        *maxminddb.Reader
+	rand      *rand.Rand
+	result    any
+	workload  string
+	addresses []net.IP
+	samples   []time.Duration
+	max       time.Duration
+	next      int
+	lookups   uint64
+	found     uint64
+	errors    uint64
+}
+
+func (w *worker) run(deadline time.Time) {
+	ip4 := make(net.IP, net.IPv4len)
+	ip6 := make(net.IP, net.IPv6len)
+	w.samples = make([]time.Duration, 0, reservoirSize)
+
+	for {
+		// Checking the clock on every lookup would dominate the results for
+		// fast lookups, so we check it in batches.
+		now := time.Now()
+		if now.After(deadline) {
+			return
+		}
+		for i := 0; i < 64; i++ {
+			var ip net.IP
+			switch w.workload {
+			case "random4":
+				w.rand.Read(ip4)
+				ip = ip4
+			case "random6":
+				w.rand.Read(ip6)
+				ip = ip6
+			default:
+				ip = w.addresses[w.next]
+				w.next = (w.next + 1) % len(w.addresses)
+			}
+
+			start := time.Now()
+			offset, err := w.db.LookupOffset(ip)
+			if err == nil && offset != maxminddb.NotFound {
+				err = w.db.Decode(offset, w.result)
+				w.found++
+			}
+			w.sample(time.Since(start))
+			if err != nil {
+				w.errors++
+			}
+			w.lookups++
+		}
+	}
+}
+
+// sample adds a latency to the worker's reservoir of samples and updates
+// its maximum latency.
+func (w *worker) sample(d time.Duration) {
+	w.max = max(w.max, d)
+	if len(w.samples) < reservoirSize {
+		w.samples = append(w.samples, d)
+		return
+	}
+	if j := w.rand.Int63n(int64(w.lookups) + 1); j < reservoirSize {
+		w.samples[j] = d
+	}
+}
+
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+	i := int(float64(len(sorted)-1) * p / 100)
+	return sorted[i]
+}
+
+func printReport(r *Report) {
+	fmt.Printf("database:    %s (%s, built %s)\n",
+		r.Database,
+		r.DatabaseType,
+		time.Unix(int64(r.BuildEpoch), 0).UTC().Format(time.RFC3339),
+	)
+	fmt.Printf("workload:    %s, shape %s, %d worker(s)\n", r.Workload, r.Shape, r.Parallel)
+	fmt.Printf("duration:    %s\n", r.Duration.Round(time.Millisecond))
+	fmt.Printf("lookups:     %d (%d found, %d errors)\n", r.Lookups, r.Found, r.Errors)
+	fmt.Printf("throughput:  %.0f lookups/s\n", r.LookupsPerSec)
+	fmt.Printf("latency:     p50 %s, p90 %s, p99 %s, p99.9 %s, max %s\n",
+		time.Duration(r.Latency["p50"]),
+		time.Duration(r.Latency["p90"]),
+		time.Duration(r.Latency["p99"]),
+		time.Duration(r.Latency["p99.9"]),
+		time.Duration(r.Latency["max"]),
+	)
+	fmt.Printf("allocations: %.2f allocs/op, %.1f B/op\n", r.AllocsPerOp, r.BytesPerOp)
+}
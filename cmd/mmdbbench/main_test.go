@@ -0,0 +1,180 @@
+package main
+
+import (
+	"encoding/json"
+	"math/rand"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testFile(file string) string {
+	return filepath.Join("..", "..", "test-data", "test-data", file)
+}
+
+func TestResultShape(t *testing.T) {
+	for _, test := range []struct {
+		shape string
+		err   string
+	}{
+		{shape: "interface"},
+		{shape: "small"},
+		{shape: "city"},
+		{shape: "map", err: `unknown result shape: "map"`},
+		{shape: "", err: `unknown result shape: ""`},
+	} {
+		newResult, err := resultShape(test.shape)
+		if test.err != "" {
+			assert.EqualError(t, err, test.err, test.shape)
+			continue
+		}
+		require.NoError(t, err, test.shape)
+		assert.NotNil(t, newResult(), test.shape)
+	}
+}
+
+func TestLoadAddresses(t *testing.T) {
+	dir := t.TempDir()
+	writeFile := func(name, content string) string {
+		path := filepath.Join(dir, name)
+		require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+		return path
+	}
+	valid := writeFile("valid.txt", "# comment\n1.1.1.1\n\n  2001:218:: \n")
+	invalid := writeFile("invalid.txt", "1.1.1.1\nnot an address\n")
+	empty := writeFile("empty.txt", "# nothing\n")
+
+	for _, test := range []struct {
+		name     string
+		cfg      config
+		expected []net.IP
+		err      string
+	}{
+		{name: "random4", cfg: config{workload: "random4"}},
+		{name: "random6", cfg: config{workload: "random6"}},
+		{
+			name:     "file",
+			cfg:      config{workload: "file", file: valid},
+			expected: []net.IP{net.ParseIP("1.1.1.1"), net.ParseIP("2001:218::")},
+		},
+		{
+			name: "file without -file",
+			cfg:  config{workload: "file"},
+			err:  "the file workload requires -file",
+		},
+		{
+			name: "invalid address",
+			cfg:  config{workload: "file", file: invalid},
+			err:  invalid + `:2: invalid IP address "not an address"`,
+		},
+		{
+			name: "no addresses",
+			cfg:  config{workload: "file", file: empty},
+			err:  empty + " contains no addresses",
+		},
+		{
+			name: "unknown workload",
+			cfg:  config{workload: "sequential"},
+			err:  `unknown workload: "sequential"`,
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			addresses, err := loadAddresses(test.cfg, nil)
+			if test.err != "" {
+				assert.EqualError(t, err, test.err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, test.expected, addresses)
+		})
+	}
+}
+
+func TestPercentile(t *testing.T) {
+	sorted := []time.Duration{1, 2, 3, 4, 5, 6, 7, 8, 9, 10}
+	for _, test := range []struct {
+		samples    []time.Duration
+		percentile float64
+		expected   time.Duration
+	}{
+		{nil, 50, 0},
+		{[]time.Duration{7}, 99.9, 7},
+		{sorted, 0, 1},
+		{sorted, 50, 5},
+		{sorted, 90, 9},
+		{sorted, 99, 9},
+		{sorted, 100, 10},
+	} {
+		assert.Equal(
+			t,
+			test.expected,
+			percentile(test.samples, test.percentile),
+			"p%v of %v",
+			test.percentile,
+			test.samples,
+		)
+	}
+}
+
+func TestWorkerMax(t *testing.T) {
+	w := &worker{
+		//nolint:gosec // this is a test
+		rand: rand.New(rand.NewSource(1)),
+	}
+	// The maximum comes after the reservoir is full, so it is unlikely to
+	// be sampled.
+	for ; w.lookups < 2*reservoirSize; w.lookups++ {
+		d := time.Microsecond
+		if w.lookups == 2*reservoirSize-1 {
+			d = time.Second
+		}
+		w.sample(d)
+	}
+	assert.Len(t, w.samples, reservoirSize)
+	assert.Equal(t, time.Second, w.max)
+}
+
+func TestRun(t *testing.T) {
+	report, err := run(config{
+		database: testFile("GeoIP2-City-Test.mmdb"),
+		workload: "scan",
+		shape:    "small",
+		parallel: 2,
+		duration: 50 * time.Millisecond,
+		seed:     1,
+	})
+	require.NoError(t, err)
+
+	output, err := json.Marshal(report)
+	require.NoError(t, err)
+	var fields map[string]any
+	require.NoError(t, json.Unmarshal(output, &fields))
+
+	assert.Equal(t, testFile("GeoIP2-City-Test.mmdb"), fields["database"])
+	assert.NotEmpty(t, fields["database_type"])
+	assert.Equal(t, "scan", fields["workload"])
+	assert.Equal(t, "small", fields["shape"])
+	assert.InDelta(t, 2, fields["parallel"], 0)
+	assert.Positive(t, fields["duration_ns"])
+	assert.Positive(t, fields["lookups"])
+	assert.Positive(t, fields["found"])
+	assert.InDelta(t, 0, fields["errors"], 0)
+	assert.Positive(t, fields["lookups_per_sec"])
+	assert.Contains(t, fields, "allocs_per_op")
+	assert.Contains(t, fields, "bytes_per_op")
+
+	latency, ok := fields["latency_ns"].(map[string]any)
+	require.True(t, ok)
+	assert.Len(t, latency, 5)
+	for _, name := range []string{"p50", "p90", "p99", "p99.9"} {
+		assert.LessOrEqual(t, latency[name], latency["max"], name)
+	}
+
+	_, err = run(config{database: testFile("GeoIP2-City-Test.mmdb"), workload: "scan", shape: "small"})
+	assert.EqualError(t, err, "-parallel must be at least 1")
+}
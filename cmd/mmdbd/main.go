@@ -0,0 +1,115 @@
+// Command mmdbd serves lookups in MaxMind DB files over a Unix domain
+// socket, so that short-lived processes can share databases opened once.
+// The protocol is described in the contrib/mmdbd package, which also has a
+// Go client.
+//
+// Usage:
+//
+//	mmdbd [flags] name=database.mmdb...
+//
+// Each argument names a database that requests select by name, e.g.,
+//
+//	mmdbd -socket /run/mmdbd.sock city=GeoIP2-City.mmdb asn=GeoLite2-ASN.mmdb
+//
+// On SIGHUP, the database files are opened again, e.g., after an update.
+// Updates should replace the files rather than overwrite them in place. If
+// a file cannot be opened, the current databases are kept. On SIGINT or
+// SIGTERM, the daemon stops and removes the socket.
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+
+	"github.com/3JoB/maxminddb-golang/contrib/mmdbd"
+)
+
+func main() {
+	socket := flag.String("socket", "mmdbd.sock", "path of the Unix domain socket to listen on")
+	mode := flag.Uint("mode", 0o660, "file mode of the socket")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [flags] name=database.mmdb...\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	databases, err := parseDatabases(flag.Args())
+	if err != nil {
+		fmt.Fprintln(flag.CommandLine.Output(), err)
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	if err := run(*socket, os.FileMode(*mode), databases); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// parseDatabases parses the name=file arguments.
+func parseDatabases(args []string) (map[string]string, error) {
+	if len(args) == 0 {
+		return nil, errors.New("no databases given")
+	}
+	databases := make(map[string]string, len(args))
+	for _, arg := range args {
+		name, file, ok := strings.Cut(arg, "=")
+		if !ok || name == "" || file == "" {
+			return nil, fmt.Errorf("invalid database %q; expected name=file", arg)
+		}
+		if _, ok := databases[name]; ok {
+			return nil, fmt.Errorf("database %q given more than once", name)
+		}
+		databases[name] = file
+	}
+	return databases, nil
+}
+
+func run(socket string, mode os.FileMode, databases map[string]string) error {
+	server, err := mmdbd.NewServer(databases)
+	if err != nil {
+		return err
+	}
+
+	l, err := net.Listen("unix", socket)
+	if err != nil {
+		_ = server.Close()
+		return err
+	}
+	if err := os.Chmod(socket, mode); err != nil {
+		_ = l.Close()
+		_ = server.Close()
+		return err
+	}
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGHUP, syscall.SIGINT, syscall.SIGTERM)
+	go func() {
+		for sig := range signals {
+			if sig != syscall.SIGHUP {
+				log.Printf("received %s; stopping", sig)
+				_ = server.Close()
+				return
+			}
+			if err := server.Reload(); err != nil {
+				log.Printf("reloading: %v", err)
+				continue
+			}
+			log.Printf("reloaded %s", strings.Join(server.Databases(), ", "))
+		}
+	}()
+
+	log.Printf("serving %s on %s", strings.Join(server.Databases(), ", "), socket)
+	err = server.Serve(l)
+	if errors.Is(err, net.ErrClosed) {
+		return nil
+	}
+	_ = server.Close()
+	return err
+}
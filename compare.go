@@ -0,0 +1,262 @@
+package maxminddb
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"math"
+	"runtime"
+	"sort"
+)
+
+// CompareRecord reports whether the record at aOffset in a and the record at
+// bOffset in b have the same content, e.g., for offsets returned by
+// LookupOffset. If both records are maps, differingKeys lists, in sorted
+// order, the keys whose values differ or that are only in one of the
+// records.
+//
+// The records are compared without decoding them. Pointers are followed,
+// map entries are compared by key regardless of their order, and arrays are
+// compared element by element. Numbers are compared by value regardless of
+// their MaxMind DB type: a uint16 of 100 equals a uint32 or an int32 of 100,
+// and a float of 1.5 equals a double of 1.5. Integers never equal floats,
+// and strings never equal bytes.
+func CompareRecord(
+	a *Reader,
+	aOffset uintptr,
+	b *Reader,
+	bOffset uintptr,
+) (equal bool, differingKeys []string, err error) {
+	if a.buffer == nil || b.buffer == nil {
+		return false, nil, errors.New("cannot call CompareRecord on a closed database")
+	}
+	c := recordComparer{a: &a.decoder, b: &b.decoder}
+	defer runtime.KeepAlive(a)
+	defer runtime.KeepAlive(b)
+
+	aType, aSize, aDataOffset, err := c.a.resolveCtrlData(uint(aOffset))
+	if err != nil {
+		return false, nil, err
+	}
+	bType, bSize, bDataOffset, err := c.b.resolveCtrlData(uint(bOffset))
+	if err != nil {
+		return false, nil, err
+	}
+	if aType != _Map || bType != _Map {
+		equal, err = c.equal(uint(aOffset), uint(bOffset), 0)
+		return equal, nil, err
+	}
+
+	aEntries, err := c.a.mapEntries(aSize, aDataOffset)
+	if err != nil {
+		return false, nil, err
+	}
+	bEntries, err := c.b.mapEntries(bSize, bDataOffset)
+	if err != nil {
+		return false, nil, err
+	}
+	for key, aValue := range aEntries {
+		bValue, ok := bEntries[key]
+		if ok {
+			ok, err = c.equal(aValue, bValue, 1)
+			if err != nil {
+				return false, nil, err
+			}
+		}
+		if !ok {
+			differingKeys = append(differingKeys, key)
+		}
+	}
+	for key := range bEntries {
+		if _, ok := aEntries[key]; !ok {
+			differingKeys = append(differingKeys, key)
+		}
+	}
+	sort.Strings(differingKeys)
+	return len(differingKeys) == 0, differingKeys, nil
+}
+
+// recordComparer compares values in the data sections of two databases.
+type recordComparer struct {
+	a *decoder
+	b *decoder
+}
+
+// equal reports whether the value at aOffset in c.a has the same content as
+// the value at bOffset in c.b as described by CompareRecord.
+func (c recordComparer) equal(aOffset, bOffset uint, depth int) (bool, error) {
+	if depth > maximumDataStructureDepth {
+		return false, newInvalidDatabaseError(
+			"exceeded maximum data structure depth; database is likely corrupt",
+		)
+	}
+	aType, aSize, aOffset, err := c.a.resolveCtrlData(aOffset)
+	if err != nil {
+		return false, err
+	}
+	bType, bSize, bOffset, err := c.b.resolveCtrlData(bOffset)
+	if err != nil {
+		return false, err
+	}
+
+	switch {
+	case aType == _Map && bType == _Map:
+		if aSize != bSize {
+			return false, nil
+		}
+		aEntries, err := c.a.mapEntries(aSize, aOffset)
+		if err != nil {
+			return false, err
+		}
+		bEntries, err := c.b.mapEntries(bSize, bOffset)
+		if err != nil {
+			return false, err
+		}
+		if len(aEntries) != len(bEntries) {
+			return false, nil
+		}
+		for key, aValue := range aEntries {
+			bValue, ok := bEntries[key]
+			if !ok {
+				return false, nil
+			}
+			if ok, err := c.equal(aValue, bValue, depth+1); !ok || err != nil {
+				return false, err
+			}
+		}
+		return true, nil
+	case aType == _Slice && bType == _Slice:
+		if aSize != bSize {
+			return false, nil
+		}
+		for i := uint(0); i < aSize; i++ {
+			if ok, err := c.equal(aOffset, bOffset, depth+1); !ok || err != nil {
+				return false, err
+			}
+			if aOffset, err = c.a.nextValueOffset(aOffset, 1); err != nil {
+				return false, err
+			}
+			if bOffset, err = c.b.nextValueOffset(bOffset, 1); err != nil {
+				return false, err
+			}
+		}
+		return true, nil
+	case aType == _Bool && bType == _Bool:
+		return aSize == bSize, nil
+	case aType == _Map, bType == _Map, aType == _Slice, bType == _Slice, aType == _Bool, bType == _Bool:
+		return false, nil
+	}
+
+	aBytes, err := c.a.scalarBytes(aSize, aOffset)
+	if err != nil {
+		return false, err
+	}
+	bBytes, err := c.b.scalarBytes(bSize, bOffset)
+	if err != nil {
+		return false, err
+	}
+	switch {
+	case aType == bType && (aType == _String || aType == _Bytes):
+		return bytes.Equal(aBytes, bBytes), nil
+	case isIntegerType(aType) && isIntegerType(bType):
+		aNeg, aHi, aLo := integerValue(aType, aBytes)
+		bNeg, bHi, bLo := integerValue(bType, bBytes)
+		return aNeg == bNeg && aHi == bHi && aLo == bLo, nil
+	case isFloatType(aType) && isFloatType(bType):
+		return floatValue(aType, aBytes) == floatValue(bType, bBytes), nil
+	default:
+		return false, nil
+	}
+}
+
+// resolveCtrlData is decodeCtrlData following a pointer at offset.
+func (d *decoder) resolveCtrlData(offset uint) (dataType, uint, uint, error) {
+	typeNum, size, newOffset, err := d.decodeCtrlData(offset)
+	if err != nil || typeNum != _Pointer {
+		return typeNum, size, newOffset, err
+	}
+	pointer, _, err := d.decodePointer(size, newOffset)
+	if err != nil {
+		return 0, 0, 0, err
+	}
+	typeNum, size, newOffset, err = d.decodeCtrlData(pointer)
+	if err == nil && typeNum == _Pointer {
+		return 0, 0, 0, newInvalidDatabaseError("invalid pointer to a pointer at offset %d", pointer)
+	}
+	return typeNum, size, newOffset, err
+}
+
+// mapEntries returns the offsets of the values of the map of size entries at
+// offset keyed by their keys.
+func (d *decoder) mapEntries(size, offset uint) (map[string]uint, error) {
+	if err := d.checkContainerSize(size, offset); err != nil {
+		return nil, err
+	}
+	entries := make(map[string]uint, size)
+	for i := uint(0); i < size; i++ {
+		key, valueOffset, err := d.decodeKey(offset)
+		if err != nil {
+			return nil, err
+		}
+		entries[string(key)] = valueOffset
+		offset, err = d.nextValueOffset(valueOffset, 1)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return entries, nil
+}
+
+// scalarBytes returns the size bytes of a scalar value at offset.
+func (d *decoder) scalarBytes(size, offset uint) ([]byte, error) {
+	if offset+size > uint(len(d.buffer)) {
+		return nil, newOffsetError()
+	}
+	return d.buffer[offset : offset+size], nil
+}
+
+func isIntegerType(t dataType) bool {
+	switch t {
+	case _Uint16, _Uint32, _Uint64, _Uint128, _Int32:
+		return true
+	default:
+		return false
+	}
+}
+
+func isFloatType(t dataType) bool {
+	return t == _Float32 || t == _Float64
+}
+
+// integerValue returns the sign and the magnitude, split into the high and
+// low 64 bits, of the integer of type t encoded in b.
+func integerValue(t dataType, b []byte) (neg bool, hi, lo uint64) {
+	if t == _Int32 {
+		var v int32
+		for _, c := range b {
+			v = v<<8 | int32(c)
+		}
+		if v < 0 {
+			return true, 0, uint64(-int64(v))
+		}
+		return false, 0, uint64(v)
+	}
+	for _, c := range b {
+		hi = hi<<8 | lo>>56
+		lo = lo<<8 | uint64(c)
+	}
+	return false, hi, lo
+}
+
+// floatValue returns the float of type t encoded in b. Invalid sizes decode
+// as NaN, which equals nothing.
+func floatValue(t dataType, b []byte) float64 {
+	switch {
+	case t == _Float32 && len(b) == 4:
+		return float64(math.Float32frombits(binary.BigEndian.Uint32(b)))
+	case t == _Float64 && len(b) == 8:
+		return math.Float64frombits(binary.BigEndian.Uint64(b))
+	default:
+		return math.NaN()
+	}
+}
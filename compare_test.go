@@ -0,0 +1,136 @@
+package maxminddb
+
+import (
+	"encoding/hex"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func compareTestReader(t *testing.T, input string) *Reader {
+	buffer, err := hex.DecodeString(input)
+	require.NoError(t, err)
+	return &Reader{buffer: buffer, decoder: decoder{buffer: buffer}}
+}
+
+func TestCompareRecord(t *testing.T) {
+	// {"a": uint16(100), "b": "x", "c": [1.5]} with the double 1.5.
+	record := "e3" + "4161a164" + "41624178" + "4163" + "0104683ff8000000000000"
+	a := compareTestReader(t, record)
+
+	tests := []struct {
+		name          string
+		input         string
+		offset        uintptr
+		differingKeys []string
+	}{
+		{
+			name:  "same encoding",
+			input: record,
+		},
+		{
+			name:  "different key order",
+			input: "e3" + "4163" + "0104683ff8000000000000" + "41624178" + "4161a164",
+		},
+		{
+			name: "uint32 and float",
+			// {"a": uint32(100), "b": "x", "c": [float(1.5)]}
+			input: "e3" + "4161c164" + "41624178" + "4163" + "010404083fc00000",
+		},
+		{
+			name: "int32 and padded uint16",
+			// {"a": int32(100), "b": "x", "c": [1.5]}
+			input: "e3" + "4161010164" + "41624178" + "4163" + "0104683ff8000000000000",
+		},
+		{
+			name: "pointers",
+			// "x" at offset 0 and the key "a" at offset 2, then the record.
+			input:  "4178" + "4161" + "e3" + "2002a164" + "41622000" + "4163" + "0104683ff8000000000000",
+			offset: 4,
+		},
+		{
+			name: "different values",
+			// {"a": uint16(101), "b": "y", "c": [1.5]}
+			input:         "e3" + "4161a165" + "41624179" + "4163" + "0104683ff8000000000000",
+			differingKeys: []string{"a", "b"},
+		},
+		{
+			name: "integer and double",
+			// {"a": 100.0, "b": "x", "c": [1.5]}
+			input:         "e3" + "416168" + "4059000000000000" + "41624178" + "4163" + "0104683ff8000000000000",
+			differingKeys: []string{"a"},
+		},
+		{
+			name: "string and bytes",
+			// {"a": uint16(100), "b": bytes("x"), "c": [1.5]}
+			input:         "e3" + "4161a164" + "41628178" + "4163" + "0104683ff8000000000000",
+			differingKeys: []string{"b"},
+		},
+		{
+			name: "missing and extra keys",
+			// {"a": uint16(100), "c": [1.5, 1.5], "d": true}
+			input:         "e3" + "4161a164" + "4163" + "0204683ff8000000000000683ff8000000000000" + "41640107",
+			differingKeys: []string{"b", "c", "d"},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			b := compareTestReader(t, test.input)
+			equal, differingKeys, err := CompareRecord(a, 0, b, test.offset)
+			require.NoError(t, err)
+			assert.Equal(t, test.differingKeys == nil, equal)
+			assert.Equal(t, test.differingKeys, differingKeys)
+
+			equal, differingKeys, err = CompareRecord(b, test.offset, a, 0)
+			require.NoError(t, err)
+			assert.Equal(t, test.differingKeys == nil, equal)
+			assert.Equal(t, test.differingKeys, differingKeys)
+		})
+	}
+}
+
+func TestCompareRecordNotMaps(t *testing.T) {
+	a := compareTestReader(t, "a164") // uint16(100)
+	b := compareTestReader(t, "c164") // uint32(100)
+	c := compareTestReader(t, "4178") // "x"
+
+	equal, differingKeys, err := CompareRecord(a, 0, b, 0)
+	require.NoError(t, err)
+	assert.True(t, equal)
+	assert.Nil(t, differingKeys)
+
+	equal, differingKeys, err = CompareRecord(a, 0, c, 0)
+	require.NoError(t, err)
+	assert.False(t, equal)
+	assert.Nil(t, differingKeys)
+}
+
+func TestCompareRecordReaders(t *testing.T) {
+	a, err := Open(testFile("MaxMind-DB-test-ipv4-24.mmdb"))
+	require.NoError(t, err)
+	b, err := Open(testFile("MaxMind-DB-test-ipv4-32.mmdb"))
+	require.NoError(t, err)
+
+	aOffset, err := a.LookupOffset(net.ParseIP("1.1.1.1"))
+	require.NoError(t, err)
+	bOffset, err := b.LookupOffset(net.ParseIP("1.1.1.1"))
+	require.NoError(t, err)
+	equal, differingKeys, err := CompareRecord(a, aOffset, b, bOffset)
+	require.NoError(t, err)
+	assert.True(t, equal)
+	assert.Empty(t, differingKeys)
+
+	bOffset, err = b.LookupOffset(net.ParseIP("1.1.1.2"))
+	require.NoError(t, err)
+	equal, differingKeys, err = CompareRecord(a, aOffset, b, bOffset)
+	require.NoError(t, err)
+	assert.False(t, equal)
+	assert.Equal(t, []string{"ip"}, differingKeys)
+
+	require.NoError(t, b.Close())
+	_, _, err = CompareRecord(a, aOffset, b, bOffset)
+	assert.EqualError(t, err, "cannot call CompareRecord on a closed database")
+	require.NoError(t, a.Close())
+}
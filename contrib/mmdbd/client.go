@@ -0,0 +1,77 @@
+package mmdbd
+
+import (
+	"encoding/json"
+	"errors"
+	"net"
+	"sync"
+)
+
+// Client is a client for a Server listening on a Unix domain socket. It is
+// safe for concurrent use; requests are sent one at a time over a single
+// connection.
+type Client struct {
+	mu   sync.Mutex
+	conn net.Conn
+}
+
+// Dial connects to the server listening on the Unix domain socket at path.
+func Dial(path string) (*Client, error) {
+	conn, err := net.Dial("unix", path)
+	if err != nil {
+		return nil, err
+	}
+	return NewClient(conn), nil
+}
+
+// NewClient returns a Client sending requests over conn.
+func NewClient(conn net.Conn) *Client {
+	return &Client{conn: conn}
+}
+
+// Do sends req and returns the response. An error is returned if the
+// request could not be sent or the response could not be read, after which
+// the Client should be closed, or if the response reports an error.
+func (c *Client) Do(req Request) (Response, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err := writeMessage(c.conn, req); err != nil {
+		return Response{}, err
+	}
+	var resp Response
+	if err := readMessage(c.conn, &resp); err != nil {
+		return Response{}, err
+	}
+	if resp.Error != "" {
+		return resp, errors.New("mmdbd: " + resp.Error)
+	}
+	return resp, nil
+}
+
+// Lookup looks ip up in the named database and stores the record in the
+// value pointed to by result, as with json.Unmarshal. The network of the
+// record and whether there is one are returned as by Reader.LookupNetwork.
+// The database name may be empty if the server has a single database.
+func (c *Client) Lookup(database string, ip net.IP, result any) (network *net.IPNet, ok bool, err error) {
+	resp, err := c.Do(Request{Database: database, IP: ip.String()})
+	if err != nil {
+		return nil, false, err
+	}
+	if resp.Network != "" {
+		if _, network, err = net.ParseCIDR(resp.Network); err != nil {
+			return nil, false, err
+		}
+	}
+	if !resp.Found {
+		return network, false, nil
+	}
+	if err := json.Unmarshal(resp.Record, result); err != nil {
+		return nil, false, err
+	}
+	return network, true, nil
+}
+
+// Close closes the connection to the server.
+func (c *Client) Close() error {
+	return c.conn.Close()
+}
@@ -0,0 +1,242 @@
+package mmdbd
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testFile(file string) string {
+	return filepath.Join("..", "..", "test-data", "test-data", file)
+}
+
+// startServer starts a Server for databases on a Unix domain socket and
+// returns the path of the socket.
+func startServer(t *testing.T, databases map[string]string) (*Server, string) {
+	t.Helper()
+
+	// Socket paths are limited to around 100 bytes, which t.TempDir may
+	// exceed.
+	dir, err := os.MkdirTemp("", "mmdbd")
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = os.RemoveAll(dir) })
+	socket := filepath.Join(dir, "mmdbd.sock")
+
+	server, err := NewServer(databases)
+	require.NoError(t, err)
+	l, err := net.Listen("unix", socket)
+	require.NoError(t, err)
+
+	done := make(chan error, 1)
+	go func() { done <- server.Serve(l) }()
+	t.Cleanup(func() {
+		assert.NoError(t, server.Close())
+		assert.ErrorIs(t, <-done, net.ErrClosed)
+	})
+	return server, socket
+}
+
+func dial(t *testing.T, socket string) *Client {
+	t.Helper()
+	client, err := Dial(socket)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = client.Close() })
+	return client
+}
+
+func TestLookup(t *testing.T) {
+	_, socket := startServer(t, map[string]string{
+		"city": testFile("GeoIP2-City-Test.mmdb"),
+		"ipv4": testFile("MaxMind-DB-test-ipv4-24.mmdb"),
+	})
+	client := dial(t, socket)
+
+	var city struct {
+		City struct {
+			Names map[string]string `json:"names"`
+		} `json:"city"`
+		Country struct {
+			IsoCode string `json:"iso_code"`
+		} `json:"country"`
+	}
+	network, ok, err := client.Lookup("city", net.ParseIP("81.2.69.160"), &city)
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, "81.2.69.160/27", network.String())
+	assert.Equal(t, "London", city.City.Names["en"])
+	assert.Equal(t, "GB", city.Country.IsoCode)
+
+	var record struct {
+		IP string `json:"ip"`
+	}
+	network, ok, err = client.Lookup("ipv4", net.ParseIP("1.1.1.3"), &record)
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, "1.1.1.2/31", network.String())
+	assert.Equal(t, "1.1.1.2", record.IP)
+
+	var missing any
+	_, ok, err = client.Lookup("ipv4", net.ParseIP("10.0.0.1"), &missing)
+	require.NoError(t, err)
+	assert.False(t, ok)
+	assert.Nil(t, missing)
+}
+
+func TestLookupPaths(t *testing.T) {
+	_, socket := startServer(t, map[string]string{
+		"city": testFile("GeoIP2-City-Test.mmdb"),
+	})
+	client := dial(t, socket)
+
+	// The database may be omitted as the server has a single one.
+	resp, err := client.Do(Request{
+		IP: "81.2.69.160",
+		Paths: [][]any{
+			{"city", "names", "en"},
+			{"subdivisions", Wildcard, "iso_code"},
+			{"subdivisions", 0, "iso_code"},
+			{"city", "missing"},
+			{"missing"},
+		},
+	})
+	require.NoError(t, err)
+	assert.True(t, resp.Found)
+	assert.JSONEq(t, `["London", ["ENG"], "ENG", null, null]`, string(resp.Record))
+}
+
+func TestLookupErrors(t *testing.T) {
+	_, socket := startServer(t, map[string]string{
+		"city": testFile("GeoIP2-City-Test.mmdb"),
+		"ipv4": testFile("MaxMind-DB-test-ipv4-24.mmdb"),
+	})
+	client := dial(t, socket)
+
+	for _, test := range []struct {
+		req Request
+		err string
+	}{
+		{Request{Database: "asn", IP: "1.1.1.1"}, `mmdbd: unknown database "asn"`},
+		{Request{IP: "1.1.1.1"}, "mmdbd: no database given; the server has city, ipv4"},
+		{Request{Database: "city", IP: "not an ip"}, `mmdbd: invalid IP address "not an ip"`},
+		{
+			Request{Database: "city", IP: "1.1.1.1", Paths: [][]any{{"city", 1.5}}},
+			"mmdbd: invalid array index 1.5 in path [city 1.5]",
+		},
+		{
+			Request{Database: "ipv4", IP: "2001:db8::1"},
+			"mmdbd: error looking up '2001:db8::1': you attempted to look up an IPv6 address in an IPv4-only database",
+		},
+	} {
+		_, err := client.Do(test.req)
+		assert.EqualError(t, err, test.err)
+	}
+
+	// A malformed request is answered with an error and the connection
+	// remains usable.
+	conn, err := net.Dial("unix", socket)
+	require.NoError(t, err)
+	defer conn.Close()
+	body := []byte(`{"ip": 1}`)
+	header := make([]byte, 4)
+	binary.BigEndian.PutUint32(header, uint32(len(body)))
+	_, err = conn.Write(append(header, body...))
+	require.NoError(t, err)
+	var resp Response
+	require.NoError(t, readMessage(conn, &resp))
+	assert.Contains(t, resp.Error, "invalid request")
+
+	resp, err = NewClient(conn).Do(Request{Database: "ipv4", IP: "1.1.1.1"})
+	require.NoError(t, err)
+	assert.True(t, resp.Found)
+}
+
+func TestConcurrentClients(t *testing.T) {
+	_, socket := startServer(t, map[string]string{
+		"ipv4": testFile("MaxMind-DB-test-ipv4-24.mmdb"),
+	})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			client, err := Dial(socket)
+			if !assert.NoError(t, err) {
+				return
+			}
+			defer client.Close()
+			for j := 0; j < 100; j++ {
+				var record struct {
+					IP string `json:"ip"`
+				}
+				_, ok, err := client.Lookup("", net.ParseIP("1.1.1.1"), &record)
+				assert.NoError(t, err)
+				assert.True(t, ok)
+				assert.Equal(t, "1.1.1.1", record.IP)
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func TestReload(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "db.mmdb")
+	// The file is replaced rather than overwritten, as database updates
+	// should be, since the open database is memory mapped.
+	replaceFile := func(data []byte) {
+		require.NoError(t, os.WriteFile(file+".tmp", data, 0o600))
+		require.NoError(t, os.Rename(file+".tmp", file))
+	}
+	copyFile := func(src string) {
+		data, err := os.ReadFile(testFile(src))
+		require.NoError(t, err)
+		replaceFile(data)
+	}
+	copyFile("MaxMind-DB-test-ipv4-24.mmdb")
+
+	server, socket := startServer(t, map[string]string{"db": file})
+	client := dial(t, socket)
+
+	// 1.1.1.1 is in the IPv4 test database but not in the City one.
+	lookup := func() string {
+		resp, err := client.Do(Request{IP: "1.1.1.1", Paths: [][]any{{"ip"}}})
+		require.NoError(t, err)
+		return string(resp.Record)
+	}
+	assert.Equal(t, `["1.1.1.1"]`, lookup())
+
+	copyFile("GeoIP2-City-Test.mmdb")
+	assert.Equal(t, `["1.1.1.1"]`, lookup(), "the database is only replaced on Reload")
+	require.NoError(t, server.Reload())
+	assert.Empty(t, lookup())
+
+	// A file that cannot be opened keeps the current database.
+	replaceFile([]byte("not a database"))
+	assert.Error(t, server.Reload())
+	assert.Empty(t, lookup())
+	resp, err := client.Do(Request{IP: "81.2.69.160", Paths: [][]any{{"country", "iso_code"}}})
+	require.NoError(t, err)
+	assert.Equal(t, `["GB"]`, string(resp.Record))
+}
+
+func TestMessageSize(t *testing.T) {
+	var buf bytes.Buffer
+	header := make([]byte, 4)
+	binary.BigEndian.PutUint32(header, MaxMessageSize+1)
+	buf.Write(header)
+	var req Request
+	assert.EqualError(
+		t,
+		readMessage(&buf, &req),
+		fmt.Sprintf("mmdbd: message of %d bytes exceeds the limit of %d bytes", MaxMessageSize+1, MaxMessageSize),
+	)
+}
@@ -0,0 +1,110 @@
+// Package mmdbd serves MaxMind DB lookups over a Unix domain socket so that
+// short-lived processes, e.g., PHP workers or cron jobs, can share databases
+// opened once by a long-running daemon instead of opening them for every
+// invocation. The daemon is in cmd/mmdbd; Client is a Go client for it.
+//
+// # Protocol
+//
+// A client sends requests over a stream connection and the server answers
+// each with a response, in order. Every message is a JSON object preceded
+// by its length in bytes as a 4-byte big-endian unsigned integer. Messages
+// may be at most 16 MiB long.
+//
+// A request names the database, the IP address to look up, and optionally
+// the paths of the values to return rather than the whole record:
+//
+//	{"database": "city", "ip": "81.2.69.160", "paths": [["city", "names", "en"]]}
+//
+// The database may be omitted if the server has exactly one. Path elements
+// are map keys, array indexes, or "*" to match every element of an array or
+// value of a map, as with Reader.DecodePath and All.
+//
+// The response reports whether the database has a record for the address,
+// the network of the record, and the record:
+//
+//	{"found": true, "network": "81.2.69.160/27", "record": {"city": ...}}
+//
+// With paths, the record is an array holding the value at each path, or
+// null if the record has no value at the path. If the request fails, the
+// response holds only an error message:
+//
+//	{"error": "unknown database \"asn\""}
+package mmdbd
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// MaxMessageSize is the largest message, in bytes, that is read.
+const MaxMessageSize = 16 << 20
+
+// Wildcard is the path element matching every element of an array or value
+// of a map.
+const Wildcard = "*"
+
+// Request is a lookup request.
+type Request struct {
+	// Database is the name of the database to look the address up in. It
+	// may be empty if the server has a single database.
+	Database string `json:"database,omitempty"`
+	// IP is the address to look up, e.g., "81.2.69.160" or "2001:db8::1".
+	IP string `json:"ip"`
+	// Paths are the paths of the values to return. The whole record is
+	// returned if there are none. The elements of a path are strings, for
+	// map keys and Wildcard, or integers, for array indexes.
+	Paths [][]any `json:"paths,omitempty"`
+}
+
+// Response is the response to a Request.
+type Response struct {
+	// Error describes why the request failed. The other fields are unset if
+	// it is not empty.
+	Error string `json:"error,omitempty"`
+	// Found is true if the database has a record for the address.
+	Found bool `json:"found"`
+	// Network is the network of the record in CIDR notation. If there is no
+	// record, it is the network without one containing the address.
+	Network string `json:"network,omitempty"`
+	// Record is the record or, with paths, an array of the value at each
+	// path.
+	Record json.RawMessage `json:"record,omitempty"`
+}
+
+// writeMessage writes v as a length-prefixed JSON message.
+func writeMessage(w io.Writer, v any) error {
+	body, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	if len(body) > MaxMessageSize {
+		return fmt.Errorf("mmdbd: message of %d bytes exceeds the limit of %d bytes", len(body), MaxMessageSize)
+	}
+	message := make([]byte, 4, 4+len(body))
+	binary.BigEndian.PutUint32(message, uint32(len(body)))
+	_, err = w.Write(append(message, body...))
+	return err
+}
+
+// readMessage reads a length-prefixed JSON message into v. It returns
+// io.EOF if r is at its end before the message.
+func readMessage(r io.Reader, v any) error {
+	var header [4]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		return err
+	}
+	size := binary.BigEndian.Uint32(header[:])
+	if size > MaxMessageSize {
+		return fmt.Errorf("mmdbd: message of %d bytes exceeds the limit of %d bytes", size, MaxMessageSize)
+	}
+	body := make([]byte, size)
+	if _, err := io.ReadFull(r, body); err != nil {
+		if err == io.EOF {
+			return io.ErrUnexpectedEOF
+		}
+		return err
+	}
+	return json.Unmarshal(body, v)
+}
@@ -0,0 +1,319 @@
+package mmdbd
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/3JoB/maxminddb-golang"
+)
+
+// Server answers lookup requests from the databases it was created with.
+// It is safe for concurrent use; each connection is served by its own
+// goroutine.
+type Server struct {
+	files map[string]string
+
+	// mu guards readers. Lookups hold it for reading so that Reload can
+	// close the previous databases once it holds it for writing.
+	mu      sync.RWMutex
+	readers map[string]*maxminddb.Reader
+
+	connsMu   sync.Mutex
+	listeners map[net.Listener]bool
+	conns     map[net.Conn]bool
+	closed    bool
+	wg        sync.WaitGroup
+}
+
+// NewServer opens the databases, given as a map from name to file path,
+// and returns a Server for them.
+func NewServer(databases map[string]string) (*Server, error) {
+	if len(databases) == 0 {
+		return nil, errors.New("mmdbd: no databases given")
+	}
+	s := &Server{
+		files:     make(map[string]string, len(databases)),
+		listeners: map[net.Listener]bool{},
+		conns:     map[net.Conn]bool{},
+	}
+	for name, file := range databases {
+		s.files[name] = file
+	}
+	readers, err := s.open()
+	if err != nil {
+		return nil, err
+	}
+	s.readers = readers
+	return s, nil
+}
+
+// open opens the database files of s.
+func (s *Server) open() (map[string]*maxminddb.Reader, error) {
+	readers := make(map[string]*maxminddb.Reader, len(s.files))
+	for name, file := range s.files {
+		reader, err := maxminddb.Open(file)
+		if err != nil {
+			closeReaders(readers)
+			return nil, fmt.Errorf("mmdbd: opening the %s database: %w", name, err)
+		}
+		readers[name] = reader
+	}
+	return readers, nil
+}
+
+func closeReaders(readers map[string]*maxminddb.Reader) error {
+	var errs []error
+	for _, reader := range readers {
+		errs = append(errs, reader.Close())
+	}
+	return errors.Join(errs...)
+}
+
+// Reload opens the database files again, e.g., after they have been
+// updated, and replaces the databases with them once the lookups in
+// progress are done. If a file cannot be opened, the current databases are
+// kept and the error is returned.
+func (s *Server) Reload() error {
+	readers, err := s.open()
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	previous := s.readers
+	s.readers = readers
+	s.mu.Unlock()
+	return closeReaders(previous)
+}
+
+// Databases returns the names of the databases, sorted.
+func (s *Server) Databases() []string {
+	names := make([]string, 0, len(s.files))
+	for name := range s.files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Serve accepts connections on l and serves requests on them until l is
+// closed or Close is called. It always returns a non-nil error; after
+// Close, the error is net.ErrClosed.
+func (s *Server) Serve(l net.Listener) error {
+	s.connsMu.Lock()
+	if s.closed {
+		s.connsMu.Unlock()
+		return net.ErrClosed
+	}
+	s.listeners[l] = true
+	s.connsMu.Unlock()
+	defer func() {
+		s.connsMu.Lock()
+		delete(s.listeners, l)
+		s.connsMu.Unlock()
+	}()
+
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			return err
+		}
+		s.connsMu.Lock()
+		if s.closed {
+			s.connsMu.Unlock()
+			_ = conn.Close()
+			return net.ErrClosed
+		}
+		s.conns[conn] = true
+		s.wg.Add(1)
+		s.connsMu.Unlock()
+
+		go func() {
+			defer s.wg.Done()
+			s.serveConn(conn)
+			s.connsMu.Lock()
+			delete(s.conns, conn)
+			s.connsMu.Unlock()
+			_ = conn.Close()
+		}()
+	}
+}
+
+// Close stops the listeners passed to Serve, closes the open connections,
+// waits for the requests in progress, and closes the databases.
+func (s *Server) Close() error {
+	s.connsMu.Lock()
+	if s.closed {
+		s.connsMu.Unlock()
+		return nil
+	}
+	s.closed = true
+	for l := range s.listeners {
+		_ = l.Close()
+	}
+	for conn := range s.conns {
+		_ = conn.Close()
+	}
+	s.connsMu.Unlock()
+	s.wg.Wait()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return closeReaders(s.readers)
+}
+
+// serveConn answers the requests on conn until it is closed or a message
+// cannot be read.
+func (s *Server) serveConn(conn net.Conn) {
+	for {
+		var req Request
+		if err := readMessage(conn, &req); err != nil {
+			var syntaxErr *json.SyntaxError
+			var typeErr *json.UnmarshalTypeError
+			if errors.As(err, &syntaxErr) || errors.As(err, &typeErr) {
+				// The message was read in full, so the connection may
+				// still be used.
+				if writeMessage(conn, Response{Error: "invalid request: " + err.Error()}) == nil {
+					continue
+				}
+			} else if !errors.Is(err, io.EOF) {
+				_ = writeMessage(conn, Response{Error: err.Error()})
+			}
+			return
+		}
+		if err := writeMessage(conn, s.Lookup(req)); err != nil {
+			return
+		}
+	}
+}
+
+// Lookup answers req.
+func (s *Server) Lookup(req Request) Response {
+	ip := net.ParseIP(req.IP)
+	if ip == nil {
+		return Response{Error: fmt.Sprintf("invalid IP address %q", req.IP)}
+	}
+	paths := make([][]any, len(req.Paths))
+	for i, path := range req.Paths {
+		var err error
+		if paths[i], err = decodePath(path); err != nil {
+			return Response{Error: err.Error()}
+		}
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	reader, err := s.reader(req.Database)
+	if err != nil {
+		return Response{Error: err.Error()}
+	}
+
+	var offset uintptr
+	network, found, err := reader.LookupNetwork(ip, &offset)
+	if err != nil {
+		return Response{Error: err.Error()}
+	}
+	resp := Response{Found: found}
+	if network != nil {
+		resp.Network = network.String()
+	}
+	if !found {
+		return resp
+	}
+
+	var record any
+	if len(paths) == 0 {
+		err = reader.Decode(offset, &record)
+	} else {
+		record, err = decodePaths(reader, offset, paths)
+	}
+	if err == nil {
+		resp.Record, err = json.Marshal(record)
+	}
+	if err != nil {
+		return Response{Error: err.Error()}
+	}
+	return resp
+}
+
+// reader returns the database named name. s.mu must be held.
+func (s *Server) reader(name string) (*maxminddb.Reader, error) {
+	if name == "" {
+		if len(s.readers) != 1 {
+			return nil, fmt.Errorf(
+				"no database given; the server has %s",
+				strings.Join(s.Databases(), ", "),
+			)
+		}
+		for _, reader := range s.readers {
+			return reader, nil
+		}
+	}
+	reader, ok := s.readers[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown database %q", name)
+	}
+	return reader, nil
+}
+
+// decodePath converts a path from a request to the form taken by
+// Reader.DecodePath.
+func decodePath(path []any) ([]any, error) {
+	decoded := make([]any, len(path))
+	for i, elem := range path {
+		switch elem := elem.(type) {
+		case string:
+			if elem == Wildcard {
+				decoded[i] = maxminddb.All
+			} else {
+				decoded[i] = elem
+			}
+		case float64:
+			if elem != float64(int(elem)) {
+				return nil, fmt.Errorf("invalid array index %v in path %v", elem, path)
+			}
+			decoded[i] = int(elem)
+		default:
+			return nil, fmt.Errorf("invalid element %v in path %v", elem, path)
+		}
+	}
+	return decoded, nil
+}
+
+// decodePaths returns the values at paths in the record at offset, with nil
+// for paths without a value.
+func decodePaths(reader *maxminddb.Reader, offset uintptr, paths [][]any) ([]any, error) {
+	values := make([]any, len(paths))
+	for i, path := range paths {
+		var err error
+		if hasWildcard(path) {
+			var matches []any
+			err = reader.DecodePath(offset, &matches, path...)
+			values[i] = matches
+		} else {
+			err = reader.DecodePath(offset, &values[i], path...)
+		}
+		if errors.Is(err, maxminddb.ErrPathNotFound) {
+			values[i] = nil
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return values, nil
+}
+
+func hasWildcard(path []any) bool {
+	for _, elem := range path {
+		if elem == maxminddb.All {
+			return true
+		}
+	}
+	return false
+}
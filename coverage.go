@@ -0,0 +1,276 @@
+package maxminddb
+
+import (
+	"errors"
+	"math/big"
+	"net/netip"
+	"runtime"
+)
+
+// Coverage describes how much of the address space has a record in a
+// database. It is returned by Reader.Coverage.
+type Coverage struct {
+	// IPv4 is the coverage of the IPv4 address space. In an IPv6 database,
+	// this is the space looked up with IPv4 addresses, i.e., ::/96.
+	IPv4 AddressCoverage
+	// IPv6 is the coverage of the IPv6 address space, not including the
+	// IPv4 subtree, ::/96, or the networks aliased to it, such as
+	// ::ffff:0:0/96. It is empty for IPv4 databases.
+	IPv6 AddressCoverage
+}
+
+// AddressCoverage holds the coverage figures for one address family.
+type AddressCoverage struct {
+	// Covered is the number of addresses with a record.
+	Covered *big.Int
+	// Total is the number of addresses considered.
+	Total *big.Int
+	// Ratio is Covered divided by Total, or 0 if Total is 0. As it is a
+	// ratio, it is the same whether the space is measured in addresses or,
+	// for instance, in IPv6 /48 networks.
+	Ratio float64
+}
+
+// CoverageOption are options for Reader.Coverage.
+type CoverageOption func(*coverageOptions)
+
+type coverageOptions struct {
+	reserved []netip.Prefix
+}
+
+// ExcludeReserved is an option for Reader.Coverage that leaves the given
+// networks out of both the covered and the total address counts. If no
+// prefixes are given, those returned by ReservedPrefixes are used.
+func ExcludeReserved(prefixes ...netip.Prefix) CoverageOption {
+	return func(o *coverageOptions) {
+		if len(prefixes) == 0 {
+			prefixes = defaultReservedPrefixes
+		}
+		o.reserved = prefixes
+	}
+}
+
+// Coverage walks the search tree and returns the number of addresses with a
+// record, the number of addresses considered, and their ratio, separately
+// for IPv4 and IPv6.
+func (r *Reader) Coverage(options ...CoverageOption) (Coverage, error) {
+	if r.buffer == nil {
+		return Coverage{}, errors.New("cannot call Coverage on a closed database")
+	}
+	var opts coverageOptions
+	for _, option := range options {
+		option(&opts)
+	}
+	reserved := newReservedPrefixes(opts.reserved)
+
+	var coverage Coverage
+	var err error
+	if r.Metadata.IPVersion == 6 {
+		coverage.IPv4, err = r.familyCoverage(r.ipv4Start, 32, false, reserved.ipv4)
+		if err != nil {
+			return Coverage{}, err
+		}
+		coverage.IPv6, err = r.familyCoverage(0, 128, true, reserved.ipv6)
+	} else {
+		coverage.IPv4, err = r.familyCoverage(0, 32, false, reserved.ipv4)
+		coverage.IPv6 = AddressCoverage{Covered: new(big.Int), Total: new(big.Int)}
+	}
+	runtime.KeepAlive(r)
+	if err != nil {
+		return Coverage{}, err
+	}
+	return coverage, nil
+}
+
+// familyCoverage returns the coverage of the tree of bits bits rooted at
+// root, leaving out the reserved prefixes. If ipv6 is true, the tree is the
+// full tree of an IPv6 database and the IPv4 subtree and its aliases are
+// left out as well.
+func (r *Reader) familyCoverage(
+	root uint,
+	bits int,
+	ipv6 bool,
+	reserved []netip.Prefix,
+) (AddressCoverage, error) {
+	w := coverageWalker{
+		reader:   r,
+		bits:     bits,
+		ipv6:     ipv6,
+		covered:  new(big.Int),
+		excluded: new(big.Int),
+	}
+	if err := w.walk(root, root, 0, true); err != nil {
+		return AddressCoverage{}, err
+	}
+	covered := w.covered
+	total := new(big.Int).Sub(w.size(0), w.excluded)
+	if ipv6 {
+		total.Sub(total, w.size(96))
+	}
+
+	for _, prefix := range outermostPrefixes(reserved) {
+		rw := coverageWalker{
+			reader:   r,
+			bits:     bits,
+			ipv6:     ipv6,
+			covered:  new(big.Int),
+			excluded: new(big.Int),
+		}
+		if err := rw.walkPrefix(root, prefix); err != nil {
+			return AddressCoverage{}, err
+		}
+		size := new(big.Int).Sub(rw.size(prefix.Bits()), rw.excluded)
+		covered.Sub(covered, rw.covered)
+		total.Sub(total, size)
+	}
+
+	coverage := AddressCoverage{Covered: covered, Total: total}
+	if total.Sign() > 0 {
+		coverage.Ratio, _ = new(big.Rat).SetFrac(covered, total).Float64()
+	}
+	return coverage, nil
+}
+
+// outermostPrefixes returns the prefixes that are not contained in another
+// of the prefixes, without duplicates.
+func outermostPrefixes(prefixes []netip.Prefix) []netip.Prefix {
+	var outermost []netip.Prefix
+	for i, prefix := range prefixes {
+		contained := false
+		for j, other := range prefixes {
+			if i != j && other.Bits() <= prefix.Bits() && other.Contains(prefix.Addr()) &&
+				(other.Bits() < prefix.Bits() || j < i) {
+				contained = true
+				break
+			}
+		}
+		if !contained {
+			outermost = append(outermost, prefix)
+		}
+	}
+	return outermost
+}
+
+var ipv4Subtree = netip.MustParsePrefix("::/96")
+
+// coverageWalker adds up the sizes of the networks with a record in a
+// search tree.
+type coverageWalker struct {
+	reader *Reader
+	// covered is the number of addresses with a record.
+	covered *big.Int
+	// excluded is the number of addresses in aliases of the IPv4 subtree.
+	// The IPv4 subtree itself is always excluded from the IPv6 counts and
+	// is not included.
+	excluded *big.Int
+	bits     int
+	ipv6     bool
+}
+
+// size returns the number of addresses in a network at depth.
+func (w *coverageWalker) size(depth int) *big.Int {
+	return new(big.Int).Lsh(big.NewInt(1), uint(w.bits-depth))
+}
+
+// skip returns true if the node at depth, reached through zero bits only
+// if zeroPath is true, is not counted because it is the IPv4 subtree or
+// one of its aliases.
+func (w *coverageWalker) skip(node uint, depth int, zeroPath bool) bool {
+	if !w.ipv6 {
+		return false
+	}
+	if zeroPath && depth == 96 {
+		return true
+	}
+	r := w.reader
+	if r.ipv4StartBitDepth == 96 && node == r.ipv4Start && node != r.Metadata.NodeCount {
+		w.excluded.Add(w.excluded, w.size(depth))
+		return true
+	}
+	return false
+}
+
+// walk counts the networks below node, which is at depth and is held by
+// parent.
+func (w *coverageWalker) walk(node, parent uint, depth int, zeroPath bool) error {
+	if w.skip(node, depth, zeroPath) {
+		return nil
+	}
+	r := w.reader
+	nodeCount := r.Metadata.NodeCount
+	switch {
+	case node < nodeCount:
+		if depth >= w.bits {
+			return newInvalidDatabaseError("invalid search tree at depth %d", depth)
+		}
+		offset := node * r.nodeOffsetMult
+		if err := w.walk(r.nodeReader.readLeft(offset), node, depth+1, zeroPath); err != nil {
+			return err
+		}
+		return w.walk(r.nodeReader.readRight(offset), node, depth+1, false)
+	case node == nodeCount:
+		return nil
+	default:
+		if _, err := r.resolveDataPointer(parent, node); err != nil {
+			return err
+		}
+		size := w.size(depth)
+		if w.ipv6 && zeroPath {
+			// The record's network contains the IPv4 subtree.
+			size.Sub(size, w.size(96))
+		}
+		w.covered.Add(w.covered, size)
+		return nil
+	}
+}
+
+// walkPrefix counts the networks within prefix in the tree rooted at root.
+func (w *coverageWalker) walkPrefix(root uint, prefix netip.Prefix) error {
+	r := w.reader
+	if w.ipv6 && prefix.Bits() >= 96 && ipv4Subtree.Contains(prefix.Addr()) {
+		// The IPv4 subtree is already excluded from the total.
+		w.excluded.Set(w.size(prefix.Bits()))
+		return nil
+	}
+	ip := prefix.Addr().AsSlice()
+	node := root
+	parent := root
+	zeroPath := true
+	depth := 0
+	for ; depth < prefix.Bits() && node < r.Metadata.NodeCount; depth++ {
+		if w.skip(node, depth, zeroPath) {
+			// The prefix is within a network that is not counted.
+			w.excluded.Set(w.size(prefix.Bits()))
+			return nil
+		}
+		offset := node * r.nodeOffsetMult
+		parent = node
+		if ip[depth>>3]&(1<<(7-depth%8)) == 0 {
+			node = r.nodeReader.readLeft(offset)
+		} else {
+			node = r.nodeReader.readRight(offset)
+			zeroPath = false
+		}
+	}
+	if depth == prefix.Bits() {
+		if w.ipv6 && zeroPath && depth <= 96 {
+			// The prefix contains the IPv4 subtree, which is already
+			// excluded from the total.
+			w.excluded.Add(w.excluded, w.size(96))
+		}
+		return w.walk(node, parent, depth, zeroPath)
+	}
+
+	// The prefix is within a network in the search tree.
+	if w.skip(node, depth, zeroPath) {
+		w.excluded.Set(w.size(prefix.Bits()))
+		return nil
+	}
+	if node > r.Metadata.NodeCount {
+		if _, err := r.resolveDataPointer(parent, node); err != nil {
+			return err
+		}
+		w.covered.Add(w.covered, w.size(prefix.Bits()))
+	}
+	return nil
+}
@@ -0,0 +1,133 @@
+package maxminddb
+
+import (
+	"fmt"
+	"math/big"
+	"net/netip"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func pow2(n uint) *big.Int {
+	return new(big.Int).Lsh(big.NewInt(1), n)
+}
+
+func sum(values ...*big.Int) *big.Int {
+	s := new(big.Int)
+	for _, v := range values {
+		s.Add(s, v)
+	}
+	return s
+}
+
+func TestCoverage(t *testing.T) {
+	// 1.1.1.1/32, 1.1.1.2/31, 1.1.1.4/30, 1.1.1.8/29, 1.1.1.16/28, and
+	// 1.1.1.32/32.
+	ipv4Covered := big.NewInt(1 + 2 + 4 + 8 + 16 + 1)
+	// ::1:ffff:ffff/128, ::2:0:0/122, ::2:0:40/124, ::2:0:50/125, and
+	// ::2:0:58/127.
+	ipv6Covered := big.NewInt(1 + 64 + 16 + 8 + 2)
+	// The IPv4 subtree, ::/96.
+	ipv6Total := new(big.Int).Sub(pow2(128), pow2(32))
+	// The aliases ::ffff:0:0/96, 2001::/32, and 2002::/16.
+	mixedIPv6Total := new(big.Int).Sub(ipv6Total, sum(pow2(32), pow2(96), pow2(112)))
+
+	for _, recordSize := range []uint{24, 28, 32} {
+		tests := []struct {
+			database string
+			expected Coverage
+		}{
+			{
+				database: "ipv4",
+				expected: Coverage{
+					IPv4: AddressCoverage{Covered: ipv4Covered, Total: pow2(32)},
+					IPv6: AddressCoverage{Covered: new(big.Int), Total: new(big.Int)},
+				},
+			},
+			{
+				database: "ipv6",
+				expected: Coverage{
+					IPv4: AddressCoverage{Covered: new(big.Int), Total: pow2(32)},
+					IPv6: AddressCoverage{Covered: ipv6Covered, Total: ipv6Total},
+				},
+			},
+			{
+				database: "mixed",
+				expected: Coverage{
+					IPv4: AddressCoverage{Covered: ipv4Covered, Total: pow2(32)},
+					IPv6: AddressCoverage{Covered: ipv6Covered, Total: mixedIPv6Total},
+				},
+			},
+		}
+		for _, test := range tests {
+			fileName := fmt.Sprintf("MaxMind-DB-test-%s-%d.mmdb", test.database, recordSize)
+			t.Run(fileName, func(t *testing.T) {
+				reader, err := Open(testFile(fileName))
+				require.NoError(t, err)
+
+				coverage, err := reader.Coverage()
+				require.NoError(t, err)
+				for _, c := range []*AddressCoverage{&test.expected.IPv4, &test.expected.IPv6} {
+					if c.Total.Sign() > 0 {
+						c.Ratio, _ = new(big.Rat).SetFrac(c.Covered, c.Total).Float64()
+					}
+				}
+				assert.Equal(t, test.expected, coverage)
+
+				assert.NoError(t, reader.Close())
+			})
+		}
+	}
+}
+
+func TestCoverageExcludeReserved(t *testing.T) {
+	reader, err := Open(testFile("MaxMind-DB-test-mixed-24.mmdb"))
+	require.NoError(t, err)
+
+	coverage, err := reader.Coverage(ExcludeReserved(
+		netip.MustParsePrefix("1.1.1.0/28"),
+		// Contained in the previous prefix.
+		netip.MustParsePrefix("1.1.1.8/29"),
+		netip.MustParsePrefix("::2:0:0/120"),
+		// Within the IPv4 subtree, which is already left out.
+		netip.MustParsePrefix("::1/128"),
+	))
+	require.NoError(t, err)
+
+	// 1.1.1.1/32, 1.1.1.2/31, 1.1.1.4/30, and 1.1.1.8/29 are left out.
+	assert.Equal(t, big.NewInt(16+1), coverage.IPv4.Covered)
+	assert.Equal(t, new(big.Int).Sub(pow2(32), pow2(4)), coverage.IPv4.Total)
+
+	// ::1:ffff:ffff/128 is the only network left.
+	assert.Equal(t, big.NewInt(1), coverage.IPv6.Covered)
+	assert.Equal(
+		t,
+		new(big.Int).Sub(pow2(128), sum(pow2(32), pow2(32), pow2(96), pow2(112), pow2(8))),
+		coverage.IPv6.Total,
+	)
+
+	coverage, err = reader.Coverage(ExcludeReserved())
+	require.NoError(t, err)
+	assert.Equal(t, big.NewInt(32), coverage.IPv4.Covered)
+	assert.Equal(t, big.NewInt(91), coverage.IPv6.Covered)
+
+	assert.NoError(t, reader.Close())
+}
+
+func TestCoverageWithoutIPv4SearchTree(t *testing.T) {
+	reader, err := Open(testFile("MaxMind-DB-no-ipv4-search-tree.mmdb"))
+	require.NoError(t, err)
+
+	coverage, err := reader.Coverage()
+	require.NoError(t, err)
+
+	// All IPv4 addresses are looked up in ::/64.
+	assert.Equal(t, pow2(32), coverage.IPv4.Covered)
+	assert.Equal(t, 1.0, coverage.IPv4.Ratio)
+	assert.Equal(t, new(big.Int).Sub(pow2(128), pow2(32)), coverage.IPv6.Total)
+	assert.True(t, coverage.IPv6.Covered.Cmp(new(big.Int).Sub(pow2(64), pow2(32))) >= 0)
+
+	assert.NoError(t, reader.Close())
+}
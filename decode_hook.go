@@ -0,0 +1,172 @@
+package maxminddb
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/3JoB/go-reflect"
+)
+
+// Kind is the MaxMind DB data type of a value passed to a DecodeHook.
+type Kind int
+
+// The kinds of the values passed to a DecodeHook.
+const (
+	KindUTF8String = Kind(_String)
+	KindDouble     = Kind(_Float64)
+	KindBytes      = Kind(_Bytes)
+	KindUint16     = Kind(_Uint16)
+	KindUint32     = Kind(_Uint32)
+	KindInt32      = Kind(_Int32)
+	KindUint64     = Kind(_Uint64)
+	KindUint128    = Kind(_Uint128)
+	KindBoolean    = Kind(_Bool)
+	KindFloat      = Kind(_Float32)
+)
+
+// String returns the name of the type in the MaxMind DB specification, e.g.,
+// "utf8_string".
+func (k Kind) String() string {
+	return dataTypeName(dataType(k))
+}
+
+// DecodeHook converts a scalar value from the database before it is stored
+// in a value of type to. from is the type of the value in the database and
+// value is the value as it would be decoded into an any, e.g., a string for
+// KindUTF8String or a uint64 for KindUint32. If handled is true, the
+// returned value is stored instead of converting value as usual; it must be
+// assignable to to, or nil to store the zero value. If handled is false, the
+// returned value is ignored.
+type DecodeHook func(from Kind, to reflect.Type, value any) (result any, handled bool, err error)
+
+// WithDecodeHook is an option for Open and FromBytes that adds hook to the
+// decode hooks called for each scalar value, i.e., each value that is not a
+// map or an array, before it is stored. Hooks are called in the order they
+// were added until one handles the value. Errors returned by hooks abort the
+// decode and are returned as a FieldError with the path of the value.
+// LocationHook and EnumHook are hooks for common conversions.
+//
+// This does not apply to types implementing the deserializer interface.
+func WithDecodeHook(hook DecodeHook) ReaderOption {
+	return func(o *readerOptions) {
+		o.decodeHooks = append(o.decodeHooks, hook)
+	}
+}
+
+// decodeWithHooks calls the decode hooks for the scalar value of type dtype
+// at offset and stores the value returned by the first hook that handles it
+// in result. handled is false if no hook handled the value.
+func (d *decoder) decodeWithHooks(
+	dtype dataType,
+	size uint,
+	offset uint,
+	result reflect.Value,
+) (newOffset uint, handled bool, err error) {
+	// The value is decoded as usual with the hooks disabled. It is not
+	// charged against the decoded size limit as the result is charged for
+	// if no hook handles it.
+	hooks := d.decodeHooks
+	decodedBytes := d.decodedBytes
+	d.decodeHooks = nil
+	var value any
+	newOffset, err = d.decodeFromType(dtype, size, offset, reflect.ValueOf(&value).Elem(), 0)
+	d.decodeHooks = hooks
+	d.decodedBytes = decodedBytes
+	if err != nil {
+		return 0, false, err
+	}
+
+	to := result.Type()
+	for _, hook := range hooks {
+		v, ok, err := hook(Kind(dtype), to, value)
+		if err != nil {
+			if d.softFail {
+				return 0, false, err
+			}
+			return 0, false, FieldError{Err: err}
+		}
+		if !ok {
+			continue
+		}
+		if v == nil {
+			reflectSetZero(result)
+			return newOffset, true, nil
+		}
+		rv := reflect.ValueOf(v)
+		if !rv.Type().AssignableTo(to) {
+			return 0, false, newUnmarshalTypeError(v, to)
+		}
+		result.Set(rv)
+		return newOffset, true, nil
+	}
+	return newOffset, false, nil
+}
+
+var locationType = reflect.TypeOf(time.Location{})
+
+// LocationHook is a DecodeHook that decodes time zone names, e.g., the
+// "Europe/London" of a location's time_zone, into *time.Location and
+// time.Location values with time.LoadLocation. An empty name is stored as
+// the zero value, and an unknown name is an error.
+func LocationHook(from Kind, to reflect.Type, value any) (any, bool, error) {
+	if from != KindUTF8String || to != locationType {
+		return nil, false, nil
+	}
+	name := value.(string)
+	if name == "" {
+		return nil, true, nil
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return nil, true, err
+	}
+	return *loc, true, nil
+}
+
+// Enum is the constraint of the types EnumHook decodes into, integer types
+// with a String method such as those generated by stringer.
+type Enum interface {
+	~int | ~int8 | ~int16 | ~int32 | ~int64 |
+		~uint | ~uint8 | ~uint16 | ~uint32 | ~uint64
+	fmt.Stringer
+}
+
+// EnumHook returns a DecodeHook that decodes values into the enum type T,
+// restricted to values. A string is decoded into the value whose String
+// method returns it, ignoring case, e.g., "gb" or "GB" for a value whose
+// String returns "GB", and an integer into the value equal to it. Other
+// strings and integers are an error. Values of other kinds are decoded as
+// usual.
+func EnumHook[T Enum](values ...T) DecodeHook {
+	var zero T
+	enumType := reflect.TypeOf(zero)
+	return func(from Kind, to reflect.Type, value any) (any, bool, error) {
+		if to != enumType {
+			return nil, false, nil
+		}
+		switch value := value.(type) {
+		case string:
+			for _, v := range values {
+				if strings.EqualFold(v.String(), value) {
+					return v, true, nil
+				}
+			}
+		case uint64:
+			for _, v := range values {
+				if v >= 0 && uint64(v) == value {
+					return v, true, nil
+				}
+			}
+		case int:
+			for _, v := range values {
+				if int64(v) == int64(value) && (v < 0) == (value < 0) {
+					return v, true, nil
+				}
+			}
+		default:
+			return nil, false, nil
+		}
+		return nil, true, fmt.Errorf("maxminddb: %v is not a valid %s", value, enumType)
+	}
+}
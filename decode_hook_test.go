@@ -0,0 +1,251 @@
+package maxminddb
+
+import (
+	"encoding/hex"
+	"errors"
+	"net/netip"
+	"testing"
+	"time"
+
+	"github.com/3JoB/go-reflect"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// {"ip": "1.2.3.4", "ts": uint32(1700000000), "nested": {"list": ["a", "bad"]}}
+const decodeHookTestRecord = "e3" +
+	"426970" + "47312e322e332e34" +
+	"427473" + "c46553f100" +
+	"466e6573746564" + "e1446c697374" + "0204" + "4161" + "43626164"
+
+type decodeHookNested struct {
+	List []string `maxminddb:"list"`
+}
+
+type decodeHookRecord struct {
+	Time   time.Time        `maxminddb:"ts"`
+	IP     netip.Addr       `maxminddb:"ip"`
+	Nested decodeHookNested `maxminddb:"nested"`
+}
+
+var (
+	addrType = reflect.TypeOf(netip.Addr{})
+	timeType = reflect.TypeOf(time.Time{})
+)
+
+func parseAddrHook(from Kind, to reflect.Type, value any) (any, bool, error) {
+	if from != KindUTF8String || to != addrType {
+		return nil, false, nil
+	}
+	addr, err := netip.ParseAddr(value.(string))
+	return addr, true, err
+}
+
+func epochHook(from Kind, to reflect.Type, value any) (any, bool, error) {
+	if from != KindUint32 || to != timeType {
+		return nil, false, nil
+	}
+	return time.Unix(int64(value.(uint64)), 0).UTC(), true, nil
+}
+
+func decodeHookTestDecoder(t *testing.T, hooks ...DecodeHook) decoder {
+	buffer, err := hex.DecodeString(decodeHookTestRecord)
+	require.NoError(t, err)
+	return decoder{buffer: buffer, decodeHooks: hooks}
+}
+
+func TestDecodeHook(t *testing.T) {
+	d := decodeHookTestDecoder(t, parseAddrHook, epochHook)
+
+	var result decodeHookRecord
+	_, err := d.decode(0, reflect.ValueOf(&result), 0)
+	require.NoError(t, err)
+	assert.Equal(t, netip.MustParseAddr("1.2.3.4"), result.IP)
+	assert.Equal(t, time.Date(2023, 11, 14, 22, 13, 20, 0, time.UTC), result.Time)
+	assert.Equal(t, []string{"a", "bad"}, result.Nested.List)
+
+	// Values not handled by any hook are decoded as usual.
+	var record map[string]any
+	_, err = d.decode(0, reflect.ValueOf(&record), 0)
+	require.NoError(t, err)
+	assert.Equal(t, "1.2.3.4", record["ip"])
+	assert.Equal(t, uint64(1700000000), record["ts"])
+}
+
+func TestDecodeHookOrder(t *testing.T) {
+	var calls []string
+	hook := func(name string, handled bool) DecodeHook {
+		return func(from Kind, _ reflect.Type, value any) (any, bool, error) {
+			if from != KindUTF8String {
+				return nil, false, nil
+			}
+			calls = append(calls, name+":"+value.(string))
+			return name, handled, nil
+		}
+	}
+	d := decodeHookTestDecoder(t, hook("first", false), hook("second", true), hook("third", true))
+
+	var result struct {
+		IP string `maxminddb:"ip"`
+	}
+	_, err := d.decode(0, reflect.ValueOf(&result), 0)
+	require.NoError(t, err)
+	assert.Equal(t, "second", result.IP)
+	assert.Equal(t, []string{"first:1.2.3.4", "second:1.2.3.4"}, calls)
+}
+
+func TestDecodeHookResult(t *testing.T) {
+	d := decodeHookTestDecoder(t, func(from Kind, _ reflect.Type, _ any) (any, bool, error) {
+		if from != KindUTF8String {
+			return nil, false, nil
+		}
+		return nil, true, nil
+	})
+	var result struct {
+		IP     netip.Addr       `maxminddb:"ip"`
+		Nested decodeHookNested `maxminddb:"nested"`
+	}
+	result.IP = netip.MustParseAddr("::1")
+	_, err := d.decode(0, reflect.ValueOf(&result), 0)
+	require.NoError(t, err)
+	assert.Equal(t, netip.Addr{}, result.IP, "nil stores the zero value")
+	assert.Equal(t, []string{"", ""}, result.Nested.List)
+
+	d = decodeHookTestDecoder(t, func(from Kind, _ reflect.Type, _ any) (any, bool, error) {
+		return 1, from == KindUint32, nil
+	})
+	var record struct {
+		Time time.Time `maxminddb:"ts"`
+	}
+	err = decodeRecord(&d, &record)
+	var typeErr UnmarshalTypeError
+	require.ErrorAs(t, err, &typeErr)
+	assert.Equal(t, timeType, typeErr.Type)
+}
+
+var errBadValue = errors.New("bad value")
+
+func badValueHook(from Kind, _ reflect.Type, value any) (any, bool, error) {
+	if from == KindUTF8String && value == "bad" {
+		return nil, false, errBadValue
+	}
+	return nil, false, nil
+}
+
+func TestDecodeHookError(t *testing.T) {
+	d := decodeHookTestDecoder(t, badValueHook)
+
+	var result struct {
+		Nested decodeHookNested `maxminddb:"nested"`
+	}
+	_, err := d.decode(0, reflect.ValueOf(&result), 0)
+	require.ErrorIs(t, err, errBadValue)
+	var fieldErr FieldError
+	require.ErrorAs(t, err, &fieldErr)
+	assert.Equal(t, "nested.list[1]", fieldErr.Path)
+	assert.EqualError(t, err, "maxminddb: error decoding nested.list[1]: bad value")
+
+	var record any
+	_, err = d.decode(0, reflect.ValueOf(&record), 0)
+	require.ErrorAs(t, err, &fieldErr)
+	assert.Equal(t, "nested.list[1]", fieldErr.Path)
+}
+
+func TestDecodeHookSoftFail(t *testing.T) {
+	d := decodeHookTestDecoder(t, badValueHook)
+	d.softFail = true
+
+	var result struct {
+		Nested decodeHookNested `maxminddb:"nested"`
+	}
+	_, err := d.decode(0, reflect.ValueOf(&result), 0)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"a", ""}, result.Nested.List)
+	require.Len(t, d.fieldErrors, 1)
+	assert.Equal(t, "nested.list[1]", d.fieldErrors[0].Path)
+	assert.ErrorIs(t, d.fieldErrors[0], errBadValue)
+}
+
+// {"tz": "Europe/London", "code": "gb", "n": uint16(2), "ts": int32(-1)}
+const builtinHookTestRecord = "e4" +
+	"42747a" + "4d4575726f70652f4c6f6e646f6e" +
+	"44636f6465" + "426762" +
+	"416e" + "a102" +
+	"427473" + "0401ffffffff"
+
+type hookCountry uint8
+
+const (
+	hookCountryUnknown hookCountry = iota
+	hookCountryGB
+	hookCountryDE
+)
+
+func (c hookCountry) String() string {
+	switch c {
+	case hookCountryGB:
+		return "GB"
+	case hookCountryDE:
+		return "DE"
+	}
+	return "unknown"
+}
+
+type hookSign int32
+
+const hookSignNegative hookSign = -1
+
+func (s hookSign) String() string {
+	return "negative"
+}
+
+func TestBuiltinDecodeHooks(t *testing.T) {
+	buffer, err := hex.DecodeString(builtinHookTestRecord)
+	require.NoError(t, err)
+	d := decoder{
+		buffer: buffer,
+		decodeHooks: []DecodeHook{
+			LocationHook,
+			EnumHook(hookCountryGB, hookCountryDE),
+			EnumHook(hookSignNegative),
+		},
+	}
+
+	var result struct {
+		TimeZone *time.Location `maxminddb:"tz"`
+		Code     hookCountry    `maxminddb:"code"`
+		Number   hookCountry    `maxminddb:"n"`
+		Sign     hookSign       `maxminddb:"ts"`
+	}
+	_, err = d.decode(0, reflect.ValueOf(&result), 0)
+	require.NoError(t, err)
+	require.NotNil(t, result.TimeZone)
+	assert.Equal(t, "Europe/London", result.TimeZone.String())
+	assert.Equal(t, hookCountryGB, result.Code)
+	assert.Equal(t, hookCountryDE, result.Number)
+	assert.Equal(t, hookSignNegative, result.Sign)
+
+	// Values of other types are decoded as usual.
+	var record map[string]any
+	_, err = d.decode(0, reflect.ValueOf(&record), 0)
+	require.NoError(t, err)
+	assert.Equal(t, "gb", record["code"])
+	assert.Equal(t, uint64(2), record["n"])
+
+	// A value that is not one of the enum values is an error.
+	d.decodeHooks = []DecodeHook{EnumHook(hookCountryDE)}
+	var code struct {
+		Code hookCountry `maxminddb:"code"`
+	}
+	_, err = d.decode(0, reflect.ValueOf(&code), 0)
+	var fieldErr FieldError
+	require.ErrorAs(t, err, &fieldErr)
+	assert.Equal(t, "code", fieldErr.Path)
+	assert.EqualError(t, err, "maxminddb: error decoding code: maxminddb: gb is not a valid maxminddb.hookCountry")
+}
+
+func TestKindString(t *testing.T) {
+	assert.Equal(t, "utf8_string", KindUTF8String.String())
+	assert.Equal(t, "double", KindDouble.String())
+	assert.Equal(t, "boolean", KindBoolean.String())
+}
@@ -0,0 +1,157 @@
+package maxminddb
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/3JoB/go-reflect"
+)
+
+type pathWildcard struct{}
+
+func (pathWildcard) String() string {
+	return "All"
+}
+
+// All is a DecodePath path element that matches every element of an array
+// and every value of a map.
+var All = pathWildcard{}
+
+// DecodePath decodes the value at path in the record at offset into result,
+// without decoding the rest of the record. The elements of path select the
+// value to decode: a string selects the value with that key in a map and an
+// int selects the element at that index in an array. A negative index
+// counts from the end of the array. For instance,
+//
+//	r.DecodePath(offset, &isoCode, "country", "iso_code")
+//
+// decodes the ISO code of the country. A PathNotFoundError is returned if
+// the record has no value at path.
+//
+// If path contains All, result must point to a slice, and the value of each
+// match is decoded into an element of the slice, in the order the values
+// appear in the record. Matches of nested wildcards are flattened into the
+// same slice. For instance,
+//
+//	r.DecodePath(offset, &isoCodes, "subdivisions", All, "iso_code")
+//
+// decodes the ISO codes of all subdivisions into a []string. Parts of the
+// record without a value at the rest of path are skipped, so a path that
+// matches nothing results in an empty slice rather than an error.
+func (r *Reader) DecodePath(offset uintptr, result any, path ...any) error {
+	if r.buffer == nil {
+		return errors.New("cannot call DecodePath on a closed database")
+	}
+	wildcard := false
+	for _, elem := range path {
+		switch elem.(type) {
+		case string, int:
+		case pathWildcard:
+			wildcard = true
+		default:
+			return fmt.Errorf("invalid path element %v (%T); expected a string, an int or All", elem, elem)
+		}
+	}
+	rv := reflect.ValueOf(result)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() {
+		return errors.New("result param must be a pointer")
+	}
+
+	d := r.decoder
+	if !wildcard {
+		var match uint
+		missing, err := d.walkPath(uint(offset), path, 0, func(offset uint) error {
+			match = offset
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		if missing >= 0 {
+			return PathNotFoundError{Path: path, Element: missing}
+		}
+		return r.decodeValueWithOptions(uintptr(match), rv.Elem(), lookupOptions{})
+	}
+
+	slice := rv.Elem()
+	if slice.Kind() != reflect.Slice {
+		return fmt.Errorf("result param must be a pointer to a slice when path contains All, not %s", rv.Type())
+	}
+	slice.Set(reflect.MakeSlice(slice.Type(), 0, 0))
+	_, err := d.walkPath(uint(offset), path, 0, func(offset uint) error {
+		slice.Set(reflect.Append(slice, reflect.Zero(slice.Type().Elem())))
+		return r.decodeValueWithOptions(uintptr(offset), slice.Index(slice.Len()-1), lookupOptions{})
+	})
+	return err
+}
+
+// walkPath calls visit with the offset of each value at path[i:] below the
+// value at offset, in the order the values appear in the data section. It
+// returns the index of the first element of path without a match, or -1 if
+// there is none. Below a wildcard, values without a match are skipped
+// rather than reported.
+func (d *decoder) walkPath(offset uint, path []any, i int, visit func(uint) error) (int, error) {
+	if i == len(path) {
+		return -1, visit(offset)
+	}
+	typeNum, size, offset, err := d.resolveCtrlData(offset)
+	if err != nil {
+		return 0, err
+	}
+
+	switch elem := path[i].(type) {
+	case string:
+		if typeNum != _Map {
+			return i, nil
+		}
+		for j := uint(0); j < size; j++ {
+			var key []byte
+			key, offset, err = d.decodeKey(offset)
+			if err != nil {
+				return 0, err
+			}
+			if string(key) == elem {
+				return d.walkPath(offset, path, i+1, visit)
+			}
+			offset, err = d.nextValueOffset(offset, 1)
+			if err != nil {
+				return 0, err
+			}
+		}
+		return i, nil
+	case int:
+		if typeNum != _Slice {
+			return i, nil
+		}
+		if elem < 0 {
+			elem += int(size)
+		}
+		if elem < 0 || uint(elem) >= size {
+			return i, nil
+		}
+		offset, err = d.nextValueOffset(offset, uint(elem))
+		if err != nil {
+			return 0, err
+		}
+		return d.walkPath(offset, path, i+1, visit)
+	default:
+		if typeNum != _Map && typeNum != _Slice {
+			return i, nil
+		}
+		for j := uint(0); j < size; j++ {
+			if typeNum == _Map {
+				if _, offset, err = d.decodeKey(offset); err != nil {
+					return 0, err
+				}
+			}
+			if _, err := d.walkPath(offset, path, i+1, visit); err != nil {
+				return 0, err
+			}
+			offset, err = d.nextValueOffset(offset, 1)
+			if err != nil {
+				return 0, err
+			}
+		}
+		return -1, nil
+	}
+}
@@ -0,0 +1,142 @@
+package maxminddb
+
+import (
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// decodePathRecord is the key "iso_code" at offset 0 followed by the record
+//
+//	{
+//	  "subdivisions": [{"iso_code": "ENG"}, {"iso_code": "WBK"}, {"names": {}}],
+//	  "country": {"iso_code": "GB", "names": {"en": "UK"}}
+//	}
+//
+// at offset 9, with the later "iso_code" keys stored as pointers to the
+// first.
+const decodePathRecord = "4869736f5f636f6465" +
+	"e2" +
+	"4c7375626469766973696f6e73" + "0304" +
+	"e1" + "2000" + "43454e47" +
+	"e1" + "2000" + "4357424b" +
+	"e1" + "456e616d6573" + "e0" +
+	"47636f756e747279" +
+	"e2" + "2000" + "424742" + "456e616d6573" + "e1" + "42656e" + "42554b"
+
+func TestDecodePath(t *testing.T) {
+	reader := compareTestReader(t, decodePathRecord)
+	const offset = 9
+
+	var isoCode string
+	require.NoError(t, reader.DecodePath(offset, &isoCode, "country", "iso_code"))
+	assert.Equal(t, "GB", isoCode)
+
+	require.NoError(t, reader.DecodePath(offset, &isoCode, "subdivisions", 1, "iso_code"))
+	assert.Equal(t, "WBK", isoCode)
+
+	var names map[string]string
+	require.NoError(t, reader.DecodePath(offset, &names, "subdivisions", -1, "names"))
+	assert.Equal(t, map[string]string{}, names)
+
+	var record map[string]any
+	require.NoError(t, reader.DecodePath(offset, &record))
+	assert.Len(t, record, 2)
+
+	tests := []struct {
+		path    []any
+		element int
+	}{
+		{path: []any{"city"}, element: 0},
+		{path: []any{"country", "iso_code", "en"}, element: 2},
+		{path: []any{"subdivisions", 3}, element: 1},
+		{path: []any{"subdivisions", -4}, element: 1},
+		{path: []any{"subdivisions", "iso_code"}, element: 1},
+		{path: []any{"country", 0}, element: 1},
+		{path: []any{"subdivisions", 2, "iso_code"}, element: 2},
+	}
+	for _, test := range tests {
+		err := reader.DecodePath(offset, &isoCode, test.path...)
+		require.ErrorIs(t, err, ErrPathNotFound, test.path)
+		assert.Equal(t, PathNotFoundError{Path: test.path, Element: test.element}, err)
+	}
+
+	err := reader.DecodePath(offset, &isoCode, "country", 1.5)
+	assert.EqualError(t, err, "invalid path element 1.5 (float64); expected a string, an int or All")
+
+	assert.EqualError(t, reader.DecodePath(offset, isoCode, "country"), "result param must be a pointer")
+}
+
+func TestDecodePathWildcard(t *testing.T) {
+	reader := compareTestReader(t, decodePathRecord)
+	const offset = 9
+
+	var isoCodes []string
+	require.NoError(t, reader.DecodePath(offset, &isoCodes, "subdivisions", All, "iso_code"))
+	assert.Equal(t, []string{"ENG", "WBK"}, isoCodes)
+
+	// The values of maps are matched in document order, and the matches of
+	// nested wildcards are flattened.
+	var values []any
+	require.NoError(t, reader.DecodePath(offset, &values, "country", All))
+	assert.Equal(t, []any{"GB", map[string]any{"en": "UK"}}, values)
+
+	require.NoError(t, reader.DecodePath(offset, &isoCodes, All, All, "iso_code"))
+	assert.Equal(t, []string{"ENG", "WBK"}, isoCodes)
+
+	var names []string
+	require.NoError(t, reader.DecodePath(offset, &names, All, All, "en"))
+	assert.Equal(t, []string{"UK"}, names)
+
+	// A wildcard matching nothing results in an empty slice.
+	for _, path := range [][]any{
+		{"subdivisions", All, "geoname_id"},
+		{"city", All},
+		{"country", "iso_code", All},
+	} {
+		isoCodes = []string{"stale"}
+		require.NoError(t, reader.DecodePath(offset, &isoCodes, path...), path)
+		assert.NotNil(t, isoCodes, path)
+		assert.Empty(t, isoCodes, path)
+	}
+
+	var isoCode string
+	err := reader.DecodePath(offset, &isoCode, "subdivisions", All, "iso_code")
+	assert.EqualError(t, err, "result param must be a pointer to a slice when path contains All, not *string")
+}
+
+func TestDecodePathCity(t *testing.T) {
+	reader, err := Open(testFile("GeoIP2-City-Test.mmdb"))
+	require.NoError(t, err)
+
+	offset, err := reader.LookupOffset(net.ParseIP("2.125.160.216"))
+	require.NoError(t, err)
+
+	var isoCodes []string
+	require.NoError(t, reader.DecodePath(offset, &isoCodes, "subdivisions", All, "iso_code"))
+	assert.Equal(t, []string{"ENG", "WBK"}, isoCodes)
+
+	var names []string
+	require.NoError(t, reader.DecodePath(offset, &names, "subdivisions", All, "names", "en"))
+	assert.Len(t, names, 2)
+
+	var name string
+	require.NoError(t, reader.DecodePath(offset, &name, "subdivisions", 0, "names", "en"))
+	assert.Equal(t, names[0], name)
+	err = reader.DecodePath(offset, &name, "subdivisions", 2, "names", "en")
+	assert.Equal(t, PathNotFoundError{Path: []any{"subdivisions", 2, "names", "en"}, Element: 1}, err)
+
+	offset, err = reader.LookupOffset(net.ParseIP("81.2.69.142"))
+	require.NoError(t, err)
+	require.NoError(t, reader.DecodePath(offset, &isoCodes, "subdivisions", All, "iso_code"))
+	assert.Equal(t, []string{"ENG"}, isoCodes)
+
+	require.NoError(t, reader.Close())
+	assert.EqualError(
+		t,
+		reader.DecodePath(offset, &isoCodes, "subdivisions", All),
+		"cannot call DecodePath on a closed database",
+	)
+}
@@ -0,0 +1,68 @@
+package maxminddb
+
+import (
+	"sync/atomic"
+)
+
+// WithDecodedCache is an option for Open, FromBytes, and FromReader that
+// gives the Reader its own cache of up to maxEntries decoded records, keyed
+// by their offset and the type of the result. Popular networks account for
+// most lookups in typical traffic, so their records are decoded once rather
+// than on every lookup. The least recently used record is evicted when the
+// cache is full. The cache is emptied on Close, and its use is counted in
+// ReaderStats. It replaces a cache set with WithRecordCache.
+//
+// Records are cached as with WithRecordCache: the result is always replaced
+// rather than merged with its existing contents, and each result receives
+// a deep copy of the cached record, so it may be modified freely. The
+// exception are results of type map[string]any, which receive the cached
+// map itself. The map and the maps and slices it holds are shared with the
+// cache and with every other lookup of the same record, so they must not be
+// modified; copy a map before changing it. Decode into a struct or another
+// map type to get a result of your own.
+func WithDecodedCache(maxEntries int) ReaderOption {
+	return func(o *readerOptions) {
+		o.decodedCacheSize = maxEntries
+	}
+}
+
+// decodedCache is the RecordCache set with WithDecodedCache: an LRUCache
+// in which every record has a cost of 1, so that it holds up to maxEntries
+// records, and which counts its hits and misses.
+type decodedCache struct {
+	lru    *LRUCache
+	hits   atomic.Uint64
+	misses atomic.Uint64
+}
+
+func newDecodedCache(maxEntries int) *decodedCache {
+	return &decodedCache{lru: NewLRUCache(max(maxEntries, 1))}
+}
+
+// Get returns the value cached under key, if any, and counts the lookup as
+// a hit or a miss.
+func (c *decodedCache) Get(key CacheKey) (any, bool) {
+	value, ok := c.lru.Get(key)
+	if !ok {
+		c.misses.Add(1)
+		return nil, false
+	}
+	c.hits.Add(1)
+	return value, true
+}
+
+// Set caches value under key, evicting the least recently used value if
+// the cache is full.
+func (c *decodedCache) Set(key CacheKey, value any, _ int) {
+	c.lru.Set(key, value, 1)
+}
+
+// clear discards all cached values. The counters are kept.
+func (c *decodedCache) clear() {
+	c.lru.clear()
+}
+
+// len returns the number of cached values.
+func (c *decodedCache) len() int {
+	return c.lru.Len()
+}
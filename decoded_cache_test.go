@@ -0,0 +1,126 @@
+package maxminddb
+
+import (
+	"net"
+	"sync"
+	"testing"
+
+	"github.com/3JoB/go-reflect"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWithDecodedCache(t *testing.T) {
+	reader, err := Open(testFile("GeoIP2-City-Test.mmdb"), WithDecodedCache(16))
+	require.NoError(t, err)
+	uncached, err := Open(testFile("GeoIP2-City-Test.mmdb"))
+	require.NoError(t, err)
+	defer uncached.Close()
+
+	ip := net.ParseIP("81.2.69.160")
+	var expected cachedCity
+	require.NoError(t, uncached.Lookup(ip, &expected))
+
+	for i := 0; i < 3; i++ {
+		var city cachedCity
+		require.NoError(t, reader.Lookup(ip, &city))
+		assert.Equal(t, expected, city)
+		// Structs receive copies of the cached record.
+		city.City.Names["en"] = "Changed"
+	}
+	assert.Equal(t, ReaderStats{
+		DecodedCacheHits:    2,
+		DecodedCacheMisses:  1,
+		DecodedCacheEntries: 1,
+	}, reader.Stats())
+
+	// map[string]any results share the cached map.
+	var first, second map[string]any
+	require.NoError(t, reader.Lookup(ip, &first))
+	require.NoError(t, reader.Lookup(ip, &second))
+	var expectedRecord map[string]any
+	require.NoError(t, uncached.Lookup(ip, &expectedRecord))
+	assert.Equal(t, expectedRecord, second)
+	assert.Equal(t, reflect.ValueOf(first).Pointer(), reflect.ValueOf(second).Pointer())
+	assert.Equal(t, 2, reader.Stats().DecodedCacheEntries)
+
+	require.NoError(t, reader.Close())
+	assert.Equal(t, ReaderStats{
+		DecodedCacheHits:   3,
+		DecodedCacheMisses: 2,
+	}, reader.Stats())
+}
+
+func TestDecodedCacheRecords(t *testing.T) {
+	// {"a": uint16(100), "b": "x"} followed by {"c": "y"}.
+	reader := compareTestReader(t, "e2"+"4161a164"+"41624178"+"e1"+"41634179")
+	reader.decodedCache = newDecodedCache(1)
+	reader.recordCache = reader.decodedCache
+
+	var record map[string]any
+	require.NoError(t, reader.Decode(0, &record))
+	assert.Equal(t, map[string]any{"a": uint64(100), "b": "x"}, record)
+	var names map[string]string
+	require.NoError(t, reader.Decode(9, &names))
+	assert.Equal(t, map[string]string{"c": "y"}, names)
+	names["c"] = "z"
+	require.NoError(t, reader.Decode(9, &names))
+	assert.Equal(t, map[string]string{"c": "y"}, names)
+
+	// The first record was evicted.
+	var again map[string]any
+	require.NoError(t, reader.Decode(0, &again))
+	require.NoError(t, reader.Decode(0, &record))
+	assert.Equal(t, reflect.ValueOf(again).Pointer(), reflect.ValueOf(record).Pointer())
+	assert.Equal(t, ReaderStats{
+		DecodedCacheHits:    2,
+		DecodedCacheMisses:  3,
+		DecodedCacheEntries: 1,
+	}, reader.Stats())
+}
+
+func TestDecodedCacheEviction(t *testing.T) {
+	c := newDecodedCache(2)
+	keys := []CacheKey{{Offset: 1}, {Offset: 2}, {Offset: 3}}
+	c.Set(keys[0], "a", 1)
+	c.Set(keys[1], "b", 1)
+	_, ok := c.Get(keys[0])
+	assert.True(t, ok)
+	c.Set(keys[2], "c", 1)
+
+	_, ok = c.Get(keys[1])
+	assert.False(t, ok, "the least recently used value is evicted")
+	value, ok := c.Get(keys[0])
+	assert.True(t, ok)
+	assert.Equal(t, "a", value)
+	assert.Equal(t, 2, c.len())
+	assert.Equal(t, uint64(2), c.hits.Load())
+	assert.Equal(t, uint64(1), c.misses.Load())
+
+	c.clear()
+	assert.Equal(t, 0, c.len())
+	_, ok = c.Get(keys[0])
+	assert.False(t, ok)
+}
+
+// TestDecodedCacheConcurrent sets and gets the same key from several
+// goroutines, as concurrent misses on the same record do. Run with -race.
+func TestDecodedCacheConcurrent(t *testing.T) {
+	c := newDecodedCache(2)
+	key := CacheKey{Offset: 1}
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 1000; j++ {
+				if _, ok := c.Get(key); !ok || j%10 == 0 {
+					c.Set(key, i, 0)
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+	assert.Equal(t, 1, c.len())
+	assert.Equal(t, uint64(8000), c.hits.Load()+c.misses.Load())
+}
@@ -1,9 +1,14 @@
 package maxminddb
 
 import (
+	"encoding"
 	"encoding/binary"
+	"errors"
+	"fmt"
 	"math"
 	"math/big"
+	"strconv"
+	"strings"
 	"sync"
 
 	"github.com/3JoB/go-reflect"
@@ -11,6 +16,61 @@ import (
 
 type decoder struct {
 	buffer []byte
+
+	// maxDecodedBytes is the limit set with WithMaxDecodedBytes. Zero means
+	// unlimited. decodedBytes is the running total for a single decode and
+	// is only meaningful on a per-decode copy of the decoder.
+	maxDecodedBytes uint
+	decodedBytes    uint
+
+	// detachedResults is set with WithDetachedResults. When set, decoded
+	// values must never alias buffer.
+	detachedResults bool
+
+	// unsafeStrings is set with WithUnsafeStrings. It has no effect when
+	// detachedResults is set.
+	unsafeStrings bool
+
+	// strings interns decoded strings for Readers that own their buffer.
+	// It is nil otherwise.
+	strings *stringTable
+
+	// softFail is set with WithSoftFailDecode. When set, values that cannot
+	// be decoded are skipped and recorded in fieldErrors, which is only
+	// meaningful on a per-decode copy of the decoder.
+	softFail    bool
+	fieldErrors FieldErrors
+
+	// keyTransform is set with WithMapKeyTransform. parentKey is the
+	// transformed key of the map entry being decoded and is only maintained
+	// when keyTransform is set.
+	keyTransform func(parent, key string) string
+	parentKey    string
+
+	// decodeHooks are set with WithDecodeHook.
+	decodeHooks []DecodeHook
+
+	// weaklyTyped is set with WithWeaklyTypedDecode.
+	weaklyTyped bool
+
+	// tagFallback is set with WithTagFallback.
+	tagFallback string
+
+	// strict is set with WithStrictDecode.
+	strict bool
+
+	// truncateArrays is set with WithTruncateArrays.
+	truncateArrays bool
+
+	// reportCoercions is set with WithLenientDecode.
+	reportCoercions bool
+
+	// interfaceFactories are set with WithInterfaceFactory.
+	interfaceFactories map[reflect.Type]func() any
+
+	// index is the index of the record being decoded by Record.Decode, if
+	// any.
+	index *recordIndex
 }
 
 type dataType int
@@ -57,6 +117,11 @@ func (d *decoder) decode(offset uint, result reflect.Value, depth int) (uint, er
 		result.Set(reflect.ValueOf(uintptr(offset)))
 		return d.nextValueOffset(offset, 1)
 	}
+	if typeNum != _Pointer {
+		if u, ok := unmarshalerOf(result); ok {
+			return d.unmarshalCustom(offset, u, depth+1)
+		}
+	}
 	return d.decodeFromType(typeNum, size, newOffset, result, depth+1)
 }
 
@@ -152,18 +217,87 @@ func (d *decoder) decodeFromType(
 	offset uint,
 	result reflect.Value,
 	depth int,
+) (uint, error) {
+	newOffset, err := d.decodeValue(dtype, size, offset, result, depth)
+	if err != nil {
+		err = withDatabaseType(err, dtype)
+	}
+	return newOffset, err
+}
+
+// withDatabaseType sets the DatabaseType of an UnmarshalTypeError for the
+// value of type dtype, returned on its own or in a FieldError for the value
+// itself, unless it was already set for a value within it.
+func withDatabaseType(err error, dtype dataType) error {
+	switch e := err.(type) {
+	case UnmarshalTypeError:
+		if e.DatabaseType == 0 {
+			e.DatabaseType = Kind(dtype)
+		}
+		return e
+	case FieldError:
+		if typeErr, ok := e.Err.(UnmarshalTypeError); ok && e.Path == "" {
+			e.Err = withDatabaseType(typeErr, dtype)
+		}
+		return e
+	}
+	return err
+}
+
+// decodeValue stores the value of type dtype at offset in result.
+func (d *decoder) decodeValue(
+	dtype dataType,
+	size uint,
+	offset uint,
+	result reflect.Value,
+	depth int,
 ) (uint, error) {
 	result = indirect(result)
 
+	if d.interfaceFactories != nil && result.Kind() == reflect.Interface && result.IsNil() &&
+		d.newInterfaceValue(result) {
+		result = indirect(result)
+	}
+
+	if d.decodeHooks != nil && dtype != _Map && dtype != _Pointer && dtype != _Slice {
+		newOffset, handled, err := d.decodeWithHooks(dtype, size, offset, result)
+		if handled || err != nil {
+			return newOffset, err
+		}
+	}
+
+	if dtype == _Bytes && result.CanAddr() {
+		if u, ok := result.Addr().Interface().(encoding.BinaryUnmarshaler); ok {
+			return d.unmarshalBinary(size, offset, u)
+		}
+	}
+
+	if dtype == _String && result.CanAddr() {
+		if u, ok := result.Addr().Interface().(encoding.TextUnmarshaler); ok {
+			return d.unmarshalText(size, offset, u)
+		}
+	}
+
+	if d.weaklyTyped && result.Kind() == reflect.Slice && dtype != _Slice && dtype != _Pointer &&
+		result.Type().Elem().Kind() != reflect.Uint8 {
+		return d.unmarshalSingleElementSlice(dtype, size, offset, result, depth)
+	}
+
 	// For these types, size has a special meaning
 	switch dtype {
 	case _Bool:
 		return unmarshalBool(size, offset, result)
 	case _Map:
+		if err := d.checkContainerSize(size, offset); err != nil {
+			return 0, err
+		}
 		return d.unmarshalMap(size, offset, result, depth)
 	case _Pointer:
 		return d.unmarshalPointer(size, offset, result, depth)
 	case _Slice:
+		if err := d.checkContainerSize(size, offset); err != nil {
+			return 0, err
+		}
 		return d.unmarshalSlice(size, offset, result, depth)
 	}
 
@@ -171,6 +305,29 @@ func (d *decoder) decodeFromType(
 	if offset+size > uint(len(d.buffer)) {
 		return 0, newOffsetError()
 	}
+	if dtype == _Bytes || dtype == _String {
+		if err := d.charge(size); err != nil {
+			return 0, err
+		}
+	}
+	newOffset, err := d.unmarshalScalar(dtype, size, offset, result)
+	if err != nil && d.weaklyTyped {
+		newOffset, err = d.coerceScalar(dtype, size, offset, result, err)
+		if err == nil {
+			d.noteCoercion(dtype, result.Type())
+		}
+	}
+	return newOffset, err
+}
+
+// unmarshalScalar stores the value of type dtype, which is not a bool, map,
+// pointer, or array, at offset in result.
+func (d *decoder) unmarshalScalar(
+	dtype dataType,
+	size uint,
+	offset uint,
+	result reflect.Value,
+) (uint, error) {
 	switch dtype {
 	case _Bytes:
 		return d.unmarshalBytes(size, offset, result)
@@ -208,6 +365,9 @@ func (d *decoder) decodeFromTypeToDeserializer(
 		v, offset := decodeBool(size, offset)
 		return offset, dser.Bool(v)
 	case _Map:
+		if err := d.checkContainerSize(size, offset); err != nil {
+			return 0, err
+		}
 		return d.decodeMapToDeserializer(size, offset, dser, depth)
 	case _Pointer:
 		pointer, newOffset, err := d.decodePointer(size, offset)
@@ -217,6 +377,9 @@ func (d *decoder) decodeFromTypeToDeserializer(
 		_, err = d.decodeToDeserializer(pointer, dser, depth, false)
 		return newOffset, err
 	case _Slice:
+		if err := d.checkContainerSize(size, offset); err != nil {
+			return 0, err
+		}
 		return d.decodeSliceToDeserializer(size, offset, dser, depth)
 	}
 
@@ -319,6 +482,21 @@ func (d *decoder) unmarshalBytes(size, offset uint, result reflect.Value) (uint,
 			result.SetBytes(value)
 			return newOffset, nil
 		}
+	case reflect.Array:
+		if result.Type().Elem().Kind() != reflect.Uint8 {
+			break
+		}
+		if len(value) > result.Len() && !d.truncateArrays {
+			return 0, d.arrayLengthError(strconv.Itoa(len(value))+" bytes", result.Type())
+		}
+		for i := 0; i < result.Len(); i++ {
+			var b byte
+			if i < len(value) {
+				b = value[i]
+			}
+			result.Index(i).SetUint(uint64(b))
+		}
+		return newOffset, nil
 	case reflect.Interface:
 		if result.NumMethod() == 0 {
 			result.Set(reflect.ValueOf(value))
@@ -328,6 +506,46 @@ func (d *decoder) unmarshalBytes(size, offset uint, result reflect.Value) (uint,
 	return newOffset, newUnmarshalTypeError(value, result.Type())
 }
 
+// unmarshalBinary passes a copy of the bytes value at offset to the
+// UnmarshalBinary method of u. Errors from the method are returned as a
+// FieldError with the path of the value.
+func (d *decoder) unmarshalBinary(size, offset uint, u encoding.BinaryUnmarshaler) (uint, error) {
+	if offset+size > uint(len(d.buffer)) {
+		return 0, newOffsetError()
+	}
+	if err := d.charge(size); err != nil {
+		return 0, err
+	}
+	value, newOffset := d.decodeBytes(size, offset)
+	if err := u.UnmarshalBinary(value); err != nil {
+		if d.softFail {
+			return 0, err
+		}
+		return 0, FieldError{Err: err}
+	}
+	return newOffset, nil
+}
+
+// unmarshalText passes a copy of the UTF-8 string value at offset to the
+// UnmarshalText method of u. Errors from the method are returned as a
+// FieldError with the path of the value.
+func (d *decoder) unmarshalText(size, offset uint, u encoding.TextUnmarshaler) (uint, error) {
+	if offset+size > uint(len(d.buffer)) {
+		return 0, newOffsetError()
+	}
+	if err := d.charge(size); err != nil {
+		return 0, err
+	}
+	value, newOffset := d.decodeBytes(size, offset)
+	if err := u.UnmarshalText(value); err != nil {
+		if d.softFail {
+			return 0, err
+		}
+		return 0, FieldError{Err: err}
+	}
+	return newOffset, nil
+}
+
 func (d *decoder) unmarshalFloat32(size, offset uint, result reflect.Value) (uint, error) {
 	if size != 4 {
 		return 0, newInvalidDatabaseError(
@@ -458,6 +676,8 @@ func (d *decoder) unmarshalSlice(
 	switch result.Kind() {
 	case reflect.Slice:
 		return d.decodeSlice(size, offset, result, depth)
+	case reflect.Array:
+		return d.decodeArray(size, offset, result, depth)
 	case reflect.Interface:
 		if result.NumMethod() == 0 {
 			a := []any{}
@@ -527,7 +747,22 @@ func (d *decoder) unmarshalUint(
 	return newOffset, newUnmarshalTypeError(value, result.Type())
 }
 
-var bigIntType = reflect.TypeOf(big.Int{})
+var (
+	bigIntType       = reflect.TypeOf(big.Int{})
+	uint128BytesType = reflect.TypeOf([16]byte{})
+)
+
+// Uint128Setter is implemented by types that uint128 values are decoded
+// into, through a pointer to them, e.g., a struct with Hi and Lo uint64
+// fields. SetUint128 is called with the high and low 64 bits of the value.
+//
+// Besides such types, a uint128 is decoded into a big.Int, a [16]byte or
+// another array type with the same underlying type, holding the value in
+// big-endian order, an unsigned integer if the value fits, or a type
+// implementing Unmarshaler.
+type Uint128Setter interface {
+	SetUint128(hi, lo uint64)
+}
 
 func (d *decoder) unmarshalUint128(size, offset uint, result reflect.Value) (uint, error) {
 	if size > 16 {
@@ -536,20 +771,41 @@ func (d *decoder) unmarshalUint128(size, offset uint, result reflect.Value) (uin
 			size,
 		)
 	}
-	value, newOffset := d.decodeUint128(size, offset)
+	b, newOffset := d.decodeUint128Bytes(size, offset)
+	hi, lo := binary.BigEndian.Uint64(b[:8]), binary.BigEndian.Uint64(b[8:])
+
+	if result.CanAddr() {
+		if u, ok := result.Addr().Interface().(Uint128Setter); ok {
+			u.SetUint128(hi, lo)
+			return newOffset, nil
+		}
+	}
 
 	switch result.Kind() {
 	case reflect.Struct:
 		if result.Type() == bigIntType {
+			value, _ := d.decodeUint128(size, offset)
 			result.Set(reflect.ValueOf(*value))
 			return newOffset, nil
 		}
+	case reflect.Array:
+		if uint128BytesType.ConvertibleTo(result.Type()) {
+			result.Set(reflect.ValueOf(b).Convert(result.Type()))
+			return newOffset, nil
+		}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		if hi == 0 && !result.OverflowUint(lo) {
+			result.SetUint(lo)
+			return newOffset, nil
+		}
 	case reflect.Interface:
 		if result.NumMethod() == 0 {
+			value, _ := d.decodeUint128(size, offset)
 			result.Set(reflect.ValueOf(value))
 			return newOffset, nil
 		}
 	}
+	value, _ := d.decodeUint128(size, offset)
 	return newOffset, newUnmarshalTypeError(value, result.Type())
 }
 
@@ -591,21 +847,58 @@ func (d *decoder) decodeMap(
 	result reflect.Value,
 	depth int,
 ) (uint, error) {
+	mapType := result.Type()
+	keyType := mapType.Key()
+	keyKind := keyType.Kind()
+	keyValue := reflect.New(keyType).Elem()
+	// Keys implementing encoding.TextUnmarshaler take precedence over the
+	// kind of the key type, as with encoding/json.
+	textKey, _ := keyValue.Addr().Interface().(encoding.TextUnmarshaler)
+	switch keyKind {
+	case reflect.String,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+	default:
+		if textKey == nil {
+			return 0, newUnmarshalTypeError("map", mapType)
+		}
+	}
+	if err := d.charge(size * uint(keyType.Size()+mapType.Elem().Size())); err != nil {
+		return 0, err
+	}
 	if result.IsNil() {
-		result.Set(reflect.MakeMapWithSize(result.Type(), int(size)))
+		result.Set(reflect.MakeMapWithSize(mapType, int(size)))
+	}
+	if d.usesStringMapFastPath(result) {
+		return d.decodeStringMap(size, offset, result, depth)
 	}
 
-	mapType := result.Type()
-	keyValue := reflect.New(mapType.Key()).Elem()
 	elemType := mapType.Elem()
 	var elemValue reflect.Value
+	index := d.indexedMap(offset)
 	for i := uint(0); i < size; i++ {
 		var key []byte
 		var err error
-		key, offset, err = d.decodeKey(offset)
+		key, offset, err = d.mapEntry(index, i, offset)
 		if err != nil {
 			return 0, err
 		}
+		if d.keyTransform != nil {
+			key = []byte(d.keyTransform(d.parentKey, string(key)))
+		}
+
+		if textKey != nil || keyKind != reflect.String {
+			// Other keys than strings are parsed before the value is
+			// decoded so that an unparsable key can be skipped along with
+			// its value.
+			if err := setMapKey(keyValue, textKey, key); err != nil {
+				offset, err = d.skipFailedValue(err, offset, len(d.fieldErrors), string(key))
+				if err != nil {
+					return 0, err
+				}
+				continue
+			}
+		}
 
 		if elemValue.IsValid() {
 			// After 1.20 is the minimum supported version, this can just be
@@ -615,17 +908,87 @@ func (d *decoder) decodeMap(
 			elemValue = reflect.New(elemType).Elem()
 		}
 
-		offset, err = d.decode(offset, elemValue, depth)
+		numErrors := len(d.fieldErrors)
+		valueOffset := offset
+		offset, err = d.decodeMapValue(key, offset, elemValue, depth)
 		if err != nil {
-			return 0, err
+			if typeErr, ok := err.(UnmarshalTypeError); ok && !d.softFail {
+				// The path names the key of the mismatched value.
+				err = FieldError{Err: typeErr}
+			}
+			// The entry is left out of the map.
+			offset, err = d.skipFailedValue(err, valueOffset, numErrors, string(key))
+			if err != nil {
+				return 0, err
+			}
+			continue
 		}
+		d.fieldErrors.prefix(numErrors, string(key))
 
-		keyValue.SetString(string(key))
+		if textKey == nil && keyKind == reflect.String {
+			if err := d.charge(uint(len(key))); err != nil {
+				return 0, err
+			}
+			keyValue.SetString(d.bufferString(key))
+		}
 		result.SetMapIndex(keyValue, elemValue)
 	}
+	if index != nil {
+		return index.end, nil
+	}
 	return offset, nil
 }
 
+// decodeMapValue decodes the value of the map entry with key at offset into
+// result, tracking key as the parent key for WithMapKeyTransform.
+func (d *decoder) decodeMapValue(key []byte, offset uint, result reflect.Value, depth int) (uint, error) {
+	if d.keyTransform == nil {
+		return d.decode(offset, result, depth)
+	}
+	parentKey := d.parentKey
+	d.parentKey = string(key)
+	newOffset, err := d.decode(offset, result, depth)
+	d.parentKey = parentKey
+	return newOffset, err
+}
+
+// setMapKey sets keyValue to key by calling UnmarshalText on textKey, a
+// pointer to keyValue, with a copy of key or, if textKey is nil, as
+// described by setIntegerMapKey. An error from UnmarshalText is returned
+// wrapped in an error naming the key.
+func setMapKey(keyValue reflect.Value, textKey encoding.TextUnmarshaler, key []byte) error {
+	if textKey == nil {
+		return setIntegerMapKey(keyValue, key)
+	}
+	reflectSetZero(keyValue)
+	if err := textKey.UnmarshalText(append([]byte(nil), key...)); err != nil {
+		return fmt.Errorf("maxminddb: cannot unmarshal %q map key into type %s: %w", key, keyValue.Type(), err)
+	}
+	return nil
+}
+
+// setIntegerMapKey sets keyValue, which must be of an integer kind, to the
+// decimal integer in key. An UnmarshalTypeError naming the key is returned if
+// key is not a decimal integer or overflows the key type.
+func setIntegerMapKey(keyValue reflect.Value, key []byte) error {
+	keyType := keyValue.Type()
+	switch keyType.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(string(key), 10, keyType.Bits())
+		if err != nil {
+			return newUnmarshalTypeError(strconv.Quote(string(key))+" map key", keyType)
+		}
+		keyValue.SetInt(n)
+	default:
+		n, err := strconv.ParseUint(string(key), 10, keyType.Bits())
+		if err != nil {
+			return newUnmarshalTypeError(strconv.Quote(string(key))+" map key", keyType)
+		}
+		keyValue.SetUint(n)
+	}
+	return nil
+}
+
 func (d *decoder) decodeMapToDeserializer(
 	size uint,
 	offset uint,
@@ -696,17 +1059,78 @@ func (d *decoder) decodeSlice(
 	result reflect.Value,
 	depth int,
 ) (uint, error) {
+	if err := d.charge(size * uint(result.Type().Elem().Size())); err != nil {
+		return 0, err
+	}
 	result.Set(reflect.MakeSlice(result.Type(), int(size), int(size)))
 	for i := 0; i < int(size); i++ {
+		numErrors := len(d.fieldErrors)
+		valueOffset := offset
+		var err error
+		offset, err = d.decode(offset, result.Index(i), depth)
+		if err == nil {
+			d.fieldErrors.prefix(numErrors, sliceIndexPath(i))
+			continue
+		}
+		offset, err = d.skipFailedValue(err, valueOffset, numErrors, sliceIndexPath(i))
+		if err != nil {
+			return 0, err
+		}
+		reflectSetZero(result.Index(i))
+	}
+	return offset, nil
+}
+
+// decodeArray decodes the array of size elements at offset into the Go
+// array result. Elements of result beyond size are set to their zero
+// values. An array with more elements than result is an error unless
+// WithTruncateArrays is set, in which case the extra elements are skipped.
+func (d *decoder) decodeArray(
+	size uint,
+	offset uint,
+	result reflect.Value,
+	depth int,
+) (uint, error) {
+	length := uint(result.Len())
+	if size > length && !d.truncateArrays {
+		return 0, d.arrayLengthError("array of "+strconv.FormatUint(uint64(size), 10)+" elements", result.Type())
+	}
+	for i := 0; i < result.Len(); i++ {
+		if uint(i) >= size {
+			reflectSetZero(result.Index(i))
+			continue
+		}
+		numErrors := len(d.fieldErrors)
+		valueOffset := offset
 		var err error
 		offset, err = d.decode(offset, result.Index(i), depth)
+		if err == nil {
+			d.fieldErrors.prefix(numErrors, sliceIndexPath(i))
+			continue
+		}
+		offset, err = d.skipFailedValue(err, valueOffset, numErrors, sliceIndexPath(i))
 		if err != nil {
 			return 0, err
 		}
+		reflectSetZero(result.Index(i))
+	}
+	if size > length {
+		return d.nextValueOffset(offset, size-length)
 	}
 	return offset, nil
 }
 
+// arrayLengthError returns the error for a value, described by value, with
+// more elements than the Go array type t. Without WithSoftFailDecode, it is
+// a FieldError so that the path of the value is reported.
+func (d *decoder) arrayLengthError(value string, t reflect.Type) error {
+	err := newUnmarshalTypeError(value, t)
+	if d.softFail {
+		return err
+	}
+	return FieldError{Err: err}
+}
+
 func (d *decoder) decodeSliceToDeserializer(
 	size uint,
 	offset uint,
@@ -732,7 +1156,7 @@ func (d *decoder) decodeSliceToDeserializer(
 
 func (d *decoder) decodeString(size, offset uint) (string, uint) {
 	newOffset := offset + size
-	return string(d.buffer[offset:newOffset]), newOffset
+	return d.bufferString(d.buffer[offset:newOffset]), newOffset
 }
 
 func (d *decoder) decodeStruct(
@@ -741,30 +1165,90 @@ func (d *decoder) decodeStruct(
 	result reflect.Value,
 	depth int,
 ) (uint, error) {
-	fields := cachedFields(result)
+	if newOffset, ok, err := d.decodePlannedStruct(size, offset, result, depth); ok {
+		return newOffset, err
+	}
+	return d.decodeStructFields(size, offset, result, depth, false, nil)
+}
+
+// decodeStructFields decodes the map at offset into the struct result. If
+// embedded is set, result is an embedded struct, which is decoded from the
+// same map as the struct embedding it, so keys without a field are not
+// unknown keys. outer holds the types of the structs embedded through
+// pointers that result is embedded in, if any; they are not decoded again
+// so that recursive types, e.g., a struct embedding a pointer to itself, do
+// not recurse forever.
+func (d *decoder) decodeStructFields(
+	size uint,
+	offset uint,
+	result reflect.Value,
+	depth int,
+	embedded bool,
+	outer []reflect.Type,
+) (uint, error) {
+	fields := cachedFields(result, d.tagFallback)
 
 	// This fills in embedded structs
-	for _, i := range fields.anonymousFields {
-		_, err := d.unmarshalMap(size, offset, result.Field(i), depth)
+	for i, index := range fields.anonymousFields {
+		inner := outer
+		if t := fields.anonymousPointers[i]; t != nil {
+			if t == result.Type() || containsType(outer, t) {
+				continue
+			}
+			inner = append(outer[:len(outer):len(outer)], t)
+		}
+		field := indirect(fieldByIndex(result, index))
+		var err error
+		if field.Kind() == reflect.Struct {
+			_, err = d.decodeStructFields(size, offset, field, depth, true, inner)
+		} else {
+			_, err = d.unmarshalMap(size, offset, field, depth)
+		}
 		if err != nil {
 			return 0, err
 		}
 	}
 
+	var unknownKeys []string
+	var found map[string]bool
+	if fields.required != nil {
+		found = make(map[string]bool, len(fields.required))
+	}
+
 	// This handles named fields
+	index := d.indexedMap(offset)
 	for i := uint(0); i < size; i++ {
 		var (
 			err error
 			key []byte
 		)
-		key, offset, err = d.decodeKey(offset)
+		key, offset, err = d.mapEntry(index, i, offset)
 		if err != nil {
 			return 0, err
 		}
+		if d.keyTransform != nil {
+			key = []byte(d.keyTransform(d.parentKey, string(key)))
+		}
 		// The string() does not create a copy due to this compiler
 		// optimization: https://github.com/golang/go/issues/3512
-		j, ok := fields.namedFields[string(key)]
-		if !ok {
+		var field reflect.Value
+		if j, ok := fields.namedFields[string(key)]; ok {
+			field = result.Field(j)
+		} else if index, ok := fields.inlineFields[string(key)]; ok {
+			field = fieldByIndex(result, index)
+		} else if !embedded && fields.remain != nil && !fields.embeddedKeys[string(key)] {
+			offset, err = d.decodeRemain(key, offset, result, fields.remain, depth)
+			if err != nil {
+				return 0, err
+			}
+			continue
+		} else {
+			if d.strict && !embedded && !fields.embeddedKeys[string(key)] {
+				unknownKeys = append(unknownKeys, string(key))
+			}
+			if index != nil {
+				continue
+			}
 			offset, err = d.nextValueOffset(offset, 1)
 			if err != nil {
 				return 0, err
@@ -772,50 +1256,420 @@ func (d *decoder) decodeStruct(
 			continue
 		}
 
-		offset, err = d.decode(offset, result.Field(j), depth)
-		if err != nil {
+		if found != nil {
+			found[string(key)] = true
+		}
+
+		numErrors := len(d.fieldErrors)
+		valueOffset := offset
+		if sel, ok := fields.languages[string(key)]; ok {
+			offset, err = d.decodeLanguage(key, offset, field, sel, depth)
+		} else if conv, ok := fields.conversions[string(key)]; ok {
+			offset, err = d.decodeConverted(key, offset, field, conv, depth)
+		} else {
+			offset, err = d.decodeMapValue(key, offset, field, depth)
+		}
+		if err == nil {
+			d.fieldErrors.prefix(numErrors, string(key))
+			continue
+		}
+		if next, ok := d.skipMismatchedField(err, valueOffset, numErrors, string(key)); ok {
+			offset = next
+		} else {
+			offset, err = d.skipFailedValue(err, valueOffset, numErrors, string(key))
+			if err != nil {
+				return 0, err
+			}
+		}
+		reflectSetZero(field)
+	}
+	for _, key := range fields.required {
+		if !found[key] {
+			// Like type mismatches, missing keys do not prevent decoding the
+			// rest of the record.
+			d.fieldErrors = append(d.fieldErrors, FieldError{Err: ErrMissingRequiredKey, Path: key})
+		}
+	}
+	if unknownKeys != nil {
+		err := FieldError{Err: UnknownKeysError{Keys: unknownKeys}}
+		if !d.softFail {
 			return 0, err
 		}
+		d.fieldErrors = append(d.fieldErrors, err)
+	}
+	if index != nil {
+		return index.end, nil
 	}
 	return offset, nil
 }
 
+// decodeRemain decodes the value at offset into the entry with key of the
+// map field of result tagged ",remain", with the index sequence index.
+func (d *decoder) decodeRemain(
+	key []byte,
+	offset uint,
+	result reflect.Value,
+	index []int,
+	depth int,
+) (uint, error) {
+	remain := fieldByIndex(result, index)
+	mapType := remain.Type()
+	if remain.IsNil() {
+		remain.Set(reflect.MakeMap(mapType))
+	}
+	value := reflect.New(mapType.Elem()).Elem()
+	numErrors := len(d.fieldErrors)
+	newOffset, err := d.decodeMapValue(key, offset, value, depth)
+	if err != nil {
+		// The entry is left out of the map.
+		return d.skipFailedValue(err, offset, numErrors, string(key))
+	}
+	d.fieldErrors.prefix(numErrors, string(key))
+	if err := d.charge(uint(len(key))); err != nil {
+		return 0, err
+	}
+	remain.SetMapIndex(reflect.ValueOf(string(key)).Convert(mapType.Key()), value)
+	return newOffset, nil
+}
+
 type fieldsType struct {
-	namedFields     map[string]int
-	anonymousFields []int
+	namedFields map[string]int
+	// inlineFields maps the keys of the fields of structs tagged ",inline"
+	// to their index sequences. Keys in namedFields are not included.
+	inlineFields map[string][]int
+	// anonymousFields holds the index sequences of embedded structs,
+	// including those embedded in inline structs.
+	anonymousFields [][]int
+	// anonymousPointers holds, for each of anonymousFields, the struct type
+	// it points to if it is a pointer, or else nil.
+	anonymousPointers []reflect.Type
+	// languages holds the language selections of the named and inline
+	// fields tagged with the lang option, keyed like those fields.
+	languages map[string]*languageSelection
+	// remain is the index of the field tagged ",remain", if any.
+	remain []int
+	// required holds the keys of the named and inline fields tagged
+	// ",required".
+	required []string
+	// conversions holds the conversions of the named and inline fields
+	// tagged with the string or boolnum option, keyed like those fields.
+	conversions map[string]fieldConversion
+	// embeddedKeys holds the keys decoded into embedded structs, which are
+	// not unknown keys for remain and WithStrictDecode.
+	embeddedKeys map[string]bool
 }
 
 var fieldsMap sync.Map
 
-func cachedFields(result reflect.Value) *fieldsType {
-	resultType := result.Type()
+// fieldsKey is the key of fieldsMap.
+type fieldsKey struct {
+	typ         reflect.Type
+	tagFallback string
+}
 
-	if fields, ok := fieldsMap.Load(resultType); ok {
+// cachedFields returns the fields of the struct type of result keyed by the
+// database key they are decoded from.
+//
+// The fields of a struct field tagged ",inline" are matched against the keys
+// of the map the struct is decoded from, as if they were fields of the
+// struct itself. When several fields have the same key, a field of the
+// struct takes precedence over a field of an inline struct, a field of a
+// less deeply nested inline struct takes precedence over one of a more
+// deeply nested inline struct, and otherwise the field of the inline struct
+// declared first takes precedence. The other fields are not decoded.
+//
+// The fields are computed once per struct type and tag fallback and shared
+// by all Readers. Computing them does not look at the types of fields other
+// than those of inline and embedded structs, which are visited once each, so
+// recursive types are handled like any other. If several goroutines compute
+// the fields of a type at once, they all use those stored first.
+func cachedFields(result reflect.Value, tagFallback string) *fieldsType {
+	key := fieldsKey{typ: result.Type(), tagFallback: tagFallback}
+
+	if fields, ok := fieldsMap.Load(key); ok {
 		return fields.(*fieldsType)
 	}
+	fields := newFieldsType(key.typ, tagFallback, nil)
+	fields.embeddedKeys = embeddedFieldKeys(key.typ, fields.anonymousFields, tagFallback)
+	fields.anonymousPointers = make([]reflect.Type, len(fields.anonymousFields))
+	for i, index := range fields.anonymousFields {
+		if t := fieldTypeByIndex(key.typ, index); t.Kind() == reflect.Ptr {
+			fields.anonymousPointers[i] = inlineStructType(t)
+		}
+	}
+	stored, _ := fieldsMap.LoadOrStore(key, fields)
+
+	return stored.(*fieldsType)
+}
+
+// newFieldsType returns the fields of the struct type resultType as
+// described by cachedFields, with keys from tags as parsed by
+// parseFieldTag with tagFallback. If conflict is not nil, it is called with the
+// index sequence of each field that is not decoded because another field
+// with the same key, at the same depth, is decoded instead. The key is empty
+// if the ignored field is an inline struct of the same type as one already
+// inlined.
+func newFieldsType(
+	resultType reflect.Type,
+	tagFallback string,
+	conflict func(ignored, decoded []int, key string),
+) *fieldsType {
 	numFields := resultType.NumField()
-	namedFields := make(map[string]int, numFields)
-	var anonymous []int
+	fields := &fieldsType{namedFields: make(map[string]int, numFields)}
+
+	type inlineStruct struct {
+		typ   reflect.Type
+		index []int
+	}
+	var inline []inlineStruct
+	seen := map[reflect.Type][]int{resultType: nil}
 	for i := 0; i < numFields; i++ {
 		field := resultType.Field(i)
+		name, isInline, skip := parseFieldTag(field, tagFallback)
+		switch {
+		case skip:
+		case isRemainField(field):
+			fields.remain = []int{i}
+		case field.Anonymous:
+			fields.anonymousFields = append(fields.anonymousFields, []int{i})
+		case isInline:
+			inline = append(inline, inlineStruct{typ: inlineStructType(field.Type), index: []int{i}})
+		default:
+			if j, ok := fields.namedFields[name]; ok && conflict != nil {
+				conflict([]int{j}, []int{i}, name)
+			}
+			fields.namedFields[name] = i
+			fields.addLanguage(name, field)
+			fields.addRequired(name, field)
+			fields.addConversion(name, field)
+		}
+	}
+
+	// The inline structs are visited breadth first so that fields of less
+	// deeply nested structs take precedence.
+	for len(inline) > 0 {
+		s := inline[0]
+		inline = inline[1:]
+		if index, ok := seen[s.typ]; ok {
+			if conflict != nil && len(index) == len(s.index) {
+				conflict(s.index, index, "")
+			}
+			continue
+		}
+		seen[s.typ] = s.index
+		for i := 0; i < s.typ.NumField(); i++ {
+			field := s.typ.Field(i)
+			name, isInline, skip := parseFieldTag(field, tagFallback)
+			index := append(append(make([]int, 0, len(s.index)+1), s.index...), i)
+			switch {
+			case skip:
+			case field.Anonymous:
+				fields.anonymousFields = append(fields.anonymousFields, index)
+			case isInline:
+				inline = append(inline, inlineStruct{typ: inlineStructType(field.Type), index: index})
+			default:
+				if _, ok := fields.namedFields[name]; ok {
+					continue
+				}
+				if decoded, ok := fields.inlineFields[name]; ok {
+					if conflict != nil && len(decoded) == len(index) {
+						conflict(index, decoded, name)
+					}
+					continue
+				}
+				if fields.inlineFields == nil {
+					fields.inlineFields = map[string][]int{}
+				}
+				fields.inlineFields[name] = index
+				fields.addLanguage(name, field)
+				fields.addRequired(name, field)
+				fields.addConversion(name, field)
+			}
+		}
+	}
+	return fields
+}
 
-		fieldName := field.Name
-		if tag := field.Tag.Get("maxminddb"); tag != "" {
-			if tag == "-" {
+// embeddedFieldKeys returns the keys of the fields of the embedded structs
+// of the struct type t, with the index sequences anonymous, including those
+// of the structs they embed in turn.
+func embeddedFieldKeys(t reflect.Type, anonymous [][]int, tagFallback string) map[string]bool {
+	var keys map[string]bool
+	seen := map[reflect.Type]bool{t: true}
+	var add func(t reflect.Type, anonymous [][]int)
+	add = func(t reflect.Type, anonymous [][]int) {
+		for _, index := range anonymous {
+			embedded := inlineStructType(fieldTypeByIndex(t, index))
+			if embedded == nil || seen[embedded] {
 				continue
 			}
-			fieldName = tag
+			seen[embedded] = true
+			fields := newFieldsType(embedded, tagFallback, nil)
+			if keys == nil {
+				keys = map[string]bool{}
+			}
+			for name := range fields.namedFields {
+				keys[name] = true
+			}
+			for name := range fields.inlineFields {
+				keys[name] = true
+			}
+			add(embedded, fields.anonymousFields)
 		}
-		if field.Anonymous {
-			anonymous = append(anonymous, i)
-			continue
+	}
+	add(t, anonymous)
+	return keys
+}
+
+// containsType reports whether types contains t.
+func containsType(types []reflect.Type, t reflect.Type) bool {
+	for _, u := range types {
+		if u == t {
+			return true
 		}
-		namedFields[fieldName] = i
 	}
-	fields := &fieldsType{namedFields: namedFields, anonymousFields: anonymous}
-	fieldsMap.Store(resultType, fields)
+	return false
+}
 
-	return fields
+// fieldTypeByIndex returns the type of the nested field of the struct type
+// t with the index sequence index.
+func fieldTypeByIndex(t reflect.Type, index []int) reflect.Type {
+	for _, x := range index {
+		if t.Kind() == reflect.Ptr {
+			t = t.Elem()
+		}
+		t = t.Field(x).Type
+	}
+	return t
+}
+
+// addRequired records the key name of field if it is tagged ",required".
+func (fields *fieldsType) addRequired(name string, field reflect.StructField) {
+	if !hasTagOption(field, "required") {
+		return
+	}
+	for _, key := range fields.required {
+		if key == name {
+			return
+		}
+	}
+	fields.required = append(fields.required, name)
+}
+
+// addLanguage records the language selection of field, which has the key
+// name, if it is tagged with the lang option.
+func (fields *fieldsType) addLanguage(name string, field reflect.StructField) {
+	sel := parseLanguageOption(field)
+	if sel == nil {
+		return
+	}
+	if fields.languages == nil {
+		fields.languages = map[string]*languageSelection{}
+	}
+	fields.languages[name] = sel
+}
+
+// parseFieldTag returns the database key for field along with whether it is
+// tagged ",inline" and whether it is tagged "-" and should be skipped. The
+// inline option is ignored unless the field is a struct or a pointer to a
+// struct. Blank fields are always skipped as they cannot be set. As with
+// encoding/json, a tag of "-," names the key "-" rather than skipping the
+// field.
+//
+// Unexported fields are skipped as they cannot be set either, except for
+// embedded structs, whose exported fields are decoded. Embedded pointers to
+// unexported struct types are skipped as the struct cannot be allocated.
+//
+// If tagFallback is not empty and field has no maxminddb tag, the name in
+// the tag with that key is used instead, as described for WithTagFallback.
+func parseFieldTag(field reflect.StructField, tagFallback string) (name string, inline, skip bool) {
+	if field.Name == "_" {
+		return "", false, true
+	}
+	if field.PkgPath != "" && !(field.Anonymous && field.Type.Kind() == reflect.Struct) {
+		return "", false, true
+	}
+	tag, ok := field.Tag.Lookup("maxminddb")
+	if !ok && tagFallback != "" {
+		if fallback, ok := field.Tag.Lookup(tagFallback); ok {
+			if fallback == "-" {
+				return "", false, true
+			}
+			name, _, _ = strings.Cut(fallback, ",")
+			if name == "" {
+				name = field.Name
+			}
+			return name, false, false
+		}
+	}
+	if tag == "-" {
+		return "", false, true
+	}
+	name, options, _ := strings.Cut(tag, ",")
+	if name == "" {
+		name = field.Name
+	}
+	for options != "" {
+		var option string
+		option, options, _ = strings.Cut(options, ",")
+		if option == "inline" && inlineStructType(field.Type) != nil {
+			inline = true
+		}
+	}
+	return name, inline, false
+}
+
+// isRemainField reports whether field is tagged ",remain" and is a map with
+// string keys, which receives the keys of the map a struct is decoded from
+// that no other field is decoded from.
+func isRemainField(field reflect.StructField) bool {
+	return hasTagOption(field, "remain") &&
+		field.Type.Kind() == reflect.Map &&
+		field.Type.Key().Kind() == reflect.String
+}
+
+// hasTagOption reports whether the maxminddb tag of field has option.
+func hasTagOption(field reflect.StructField, option string) bool {
+	tag, ok := field.Tag.Lookup("maxminddb")
+	if !ok {
+		return false
+	}
+	_, options, _ := strings.Cut(tag, ",")
+	for options != "" {
+		var o string
+		o, options, _ = strings.Cut(options, ",")
+		if o == option {
+			return true
+		}
+	}
+	return false
+}
+
+// inlineStructType returns the struct type of an inline field of type t or
+// nil if t is neither a struct nor a pointer to a struct.
+func inlineStructType(t reflect.Type) reflect.Type {
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct {
+		return nil
+	}
+	return t
+}
+
+// fieldByIndex returns the nested field of the struct v with the index
+// sequence index, allocating nil pointers to inline structs along the way.
+func fieldByIndex(v reflect.Value, index []int) reflect.Value {
+	for i, x := range index {
+		if i > 0 && v.Kind() == reflect.Ptr {
+			if v.IsNil() {
+				v.Set(reflect.New(v.Type().Elem()))
+			}
+			v = v.Elem()
+		}
+		v = v.Field(x)
+	}
+	return v
 }
 
 func (d *decoder) decodeUint(size, offset uint) (uint64, uint) {
@@ -829,6 +1683,15 @@ func (d *decoder) decodeUint(size, offset uint) (uint64, uint) {
 	return val, newOffset
 }
 
+// decodeUint128Bytes returns the uint128 of size bytes at offset as 16
+// big-endian bytes.
+func (d *decoder) decodeUint128Bytes(size, offset uint) ([16]byte, uint) {
+	newOffset := offset + size
+	var b [16]byte
+	copy(b[16-size:], d.buffer[offset:newOffset])
+	return b, newOffset
+}
+
 func (d *decoder) decodeUint128(size, offset uint) (*big.Int, uint) {
 	newOffset := offset + size
 	val := new(big.Int)
@@ -837,6 +1700,99 @@ func (d *decoder) decodeUint128(size, offset uint) (*big.Int, uint) {
 	return val, newOffset
 }
 
+// skipFailedValue is called when decoding the value at offset failed with
+// err. If the decoder is soft-failing and the error does not prevent us from
+// finding the following value, the error is recorded under path and the
+// offset of the following value is returned. Otherwise, err is returned.
+// Errors recorded while decoding the value, i.e., those after the first
+// numErrors, are discarded as the whole value is skipped.
+func (d *decoder) skipFailedValue(
+	err error,
+	offset uint,
+	numErrors int,
+	path string,
+) (uint, error) {
+	if !d.softFail {
+		if fieldErr, ok := err.(FieldError); ok {
+			// A decode hook error, which carries the path of the value.
+			fieldErr.Path = joinFieldPath(path, fieldErr.Path)
+			return 0, fieldErr
+		}
+		return 0, err
+	}
+	var limitErr DecodedSizeLimitError
+	if errors.As(err, &limitErr) {
+		return 0, err
+	}
+	newOffset, skipErr := d.nextValueOffset(offset, 1)
+	if skipErr != nil {
+		return 0, err
+	}
+	d.fieldErrors = append(d.fieldErrors[:numErrors], FieldError{Path: path, Err: err})
+	return newOffset, nil
+}
+
+// skipMismatchedField is called when decoding the value at offset into the
+// struct field for path failed with err. Without WithSoftFailDecode, a type
+// mismatch does not prevent decoding the other fields of the record, so it
+// is recorded under path, with the path of the value within the field, and
+// the offset of the following value is returned with true. Otherwise, it
+// returns false and the error is left to skipFailedValue.
+func (d *decoder) skipMismatchedField(
+	err error,
+	offset uint,
+	numErrors int,
+	path string,
+) (uint, bool) {
+	if d.softFail {
+		return 0, false
+	}
+	var typeErr UnmarshalTypeError
+	if !errors.As(err, &typeErr) {
+		return 0, false
+	}
+	fieldErr, ok := err.(FieldError)
+	if !ok {
+		fieldErr = FieldError{Err: err}
+	}
+	newOffset, skipErr := d.nextValueOffset(offset, 1)
+	if skipErr != nil {
+		return 0, false
+	}
+	fieldErr.Path = joinFieldPath(path, fieldErr.Path)
+	d.fieldErrors = append(d.fieldErrors[:numErrors], fieldErr)
+	return newOffset, true
+}
+
+func sliceIndexPath(i int) string {
+	return "[" + strconv.Itoa(i) + "]"
+}
+
+// checkContainerSize returns an error if a map or slice of size entries
+// starting at offset could not possibly fit in the remaining buffer. Every
+// entry takes at least one byte, so this bounds the memory we preallocate
+// for a corrupt size before we discover the data is truncated.
+func (d *decoder) checkContainerSize(size, offset uint) error {
+	if offset > uint(len(d.buffer)) || size > uint(len(d.buffer))-offset {
+		return newOffsetError()
+	}
+	return nil
+}
+
+// charge adds n bytes to the approximate running total of decoded output and
+// returns a DecodedSizeLimitError if this exceeds the limit set with
+// WithMaxDecodedBytes.
+func (d *decoder) charge(n uint) error {
+	if d.maxDecodedBytes == 0 {
+		return nil
+	}
+	d.decodedBytes += n
+	if d.decodedBytes > d.maxDecodedBytes {
+		return DecodedSizeLimitError{Limit: d.maxDecodedBytes}
+	}
+	return nil
+}
+
 func uintFromBytes(prefix uint, uintBytes []byte) uint {
 	val := prefix
 	for _, b := range uintBytes {
@@ -859,8 +1815,18 @@ func (d *decoder) decodeKey(offset uint) ([]byte, uint, error) {
 		if err != nil {
 			return nil, 0, err
 		}
-		key, _, err := d.decodeKey(pointer)
-		return key, ptrOffset, err
+		typeNum, size, dataOffset, err = d.decodeCtrlData(pointer)
+		if err != nil {
+			return nil, 0, err
+		}
+		if typeNum != _String {
+			return nil, 0, newInvalidDatabaseError("unexpected type when decoding string: %v", typeNum)
+		}
+		newOffset := dataOffset + size
+		if newOffset > uint(len(d.buffer)) {
+			return nil, 0, newOffsetError()
+		}
+		return d.buffer[dataOffset:newOffset], ptrOffset, nil
 	}
 	if typeNum != _String {
 		return nil, 0, newInvalidDatabaseError("unexpected type when decoding string: %v", typeNum)
@@ -876,26 +1842,32 @@ func (d *decoder) decodeKey(offset uint) ([]byte, uint, error) {
 // the one at the offset passed in. The size bits have different meanings for
 // different data types.
 func (d *decoder) nextValueOffset(offset, numberToSkip uint) (uint, error) {
-	if numberToSkip == 0 {
-		return offset, nil
-	}
-	typeNum, size, offset, err := d.decodeCtrlData(offset)
-	if err != nil {
-		return 0, err
-	}
-	switch typeNum {
-	case _Pointer:
-		_, offset, err = d.decodePointer(size, offset)
+	for ; numberToSkip > 0; numberToSkip-- {
+		typeNum, size, newOffset, err := d.decodeCtrlData(offset)
 		if err != nil {
 			return 0, err
 		}
-	case _Map:
-		numberToSkip += 2 * size
-	case _Slice:
-		numberToSkip += size
-	case _Bool:
-	default:
-		offset += size
+		offset = newOffset
+		switch typeNum {
+		case _Pointer:
+			_, offset, err = d.decodePointer(size, offset)
+			if err != nil {
+				return 0, err
+			}
+		case _Map:
+			if err := d.checkContainerSize(2*size, offset); err != nil {
+				return 0, err
+			}
+			numberToSkip += 2 * size
+		case _Slice:
+			if err := d.checkContainerSize(size, offset); err != nil {
+				return 0, err
+			}
+			numberToSkip += size
+		case _Bool:
+		default:
+			offset += size
+		}
 	}
-	return d.nextValueOffset(offset, numberToSkip-1)
+	return offset, nil
 }
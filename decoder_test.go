@@ -1,10 +1,15 @@
 package maxminddb
 
 import (
+	"bytes"
 	"encoding/hex"
+	"errors"
+	"math"
 	"math/big"
 	"os"
+	"strconv"
 	"strings"
+	"sync"
 	"testing"
 
 	"github.com/3JoB/go-reflect"
@@ -194,6 +199,65 @@ func TestUint128(t *testing.T) {
 	validateDecoding(t, uints)
 }
 
+type hiLo struct {
+	Hi, Lo uint64
+}
+
+func (u *hiLo) SetUint128(hi, lo uint64) {
+	u.Hi, u.Lo = hi, lo
+}
+
+type ipv6Bytes [16]byte
+
+func TestUint128Targets(t *testing.T) {
+	decode := func(t *testing.T, input string, result any) error {
+		t.Helper()
+		buffer, err := hex.DecodeString(input)
+		require.NoError(t, err)
+		d := decoder{buffer: buffer}
+		_, err = d.decode(0, reflect.ValueOf(result), 0)
+		return err
+	}
+	twoTo64 := "0903" + "01" + strings.Repeat("00", 8)
+	max128 := "1003" + strings.Repeat("ff", 16)
+
+	var b [16]byte
+	require.NoError(t, decode(t, twoTo64, &b))
+	assert.Equal(t, [16]byte{7: 1}, b)
+	require.NoError(t, decode(t, max128, &b))
+	assert.Equal(t, [16]byte{0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff,
+		0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff}, b)
+	var named ipv6Bytes
+	require.NoError(t, decode(t, "0203"+"01f4", &named))
+	assert.Equal(t, ipv6Bytes{14: 0x01, 15: 0xf4}, named)
+
+	var u hiLo
+	require.NoError(t, decode(t, twoTo64, &u))
+	assert.Equal(t, hiLo{Hi: 1}, u)
+	require.NoError(t, decode(t, max128, &u))
+	assert.Equal(t, hiLo{Hi: math.MaxUint64, Lo: math.MaxUint64}, u)
+	var pointer *hiLo
+	require.NoError(t, decode(t, "0003", &pointer))
+	assert.Equal(t, &hiLo{}, pointer)
+
+	var n uint64
+	require.NoError(t, decode(t, "0803"+strings.Repeat("ff", 8), &n))
+	assert.Equal(t, uint64(math.MaxUint64), n)
+	var typeErr UnmarshalTypeError
+	require.ErrorAs(t, decode(t, twoTo64, &n), &typeErr)
+	assert.EqualError(t, typeErr, "maxminddb: cannot unmarshal 18446744073709551616 into type uint64")
+	var small uint16
+	require.NoError(t, decode(t, "0203"+"01f4", &small))
+	assert.Equal(t, uint16(500), small)
+	require.ErrorAs(t, decode(t, "0303"+"010000", &small), &typeErr)
+
+	// big.Int still works.
+	var bi big.Int
+	require.NoError(t, decode(t, max128, &bi))
+	expected := new(big.Int).Lsh(big.NewInt(1), 128)
+	assert.Equal(t, expected.Sub(expected, big.NewInt(1)), &bi)
+}
+
 // No pow or bit shifting for big int, apparently :-(
 // This is _not_ meant to be a comprehensive power function.
 func powBigInt(bi *big.Int, pow uint) *big.Int {
@@ -221,6 +285,42 @@ func validateDecoding(t *testing.T, tests map[string]any) {
 	}
 }
 
+// decodeRecord decodes the value at the start of the buffer of d into
+// result as a Reader does, returning the FieldErrors recorded, if any.
+func decodeRecord(d *decoder, result any) error {
+	d.fieldErrors = nil
+	_, err := d.decode(0, reflect.ValueOf(result), 0)
+	if err == nil && len(d.fieldErrors) > 0 {
+		return d.fieldErrors
+	}
+	return err
+}
+
+func TestDecodePointer(t *testing.T) {
+	// The size argument holds the two pointer size bits followed by the
+	// three value bits from the control byte. The expected values were
+	// computed by hand from the MaxMind DB specification.
+	tests := []struct {
+		buffer   []byte
+		size     uint
+		expected uint
+	}{
+		{size: 0x05, buffer: []byte{0x10}, expected: 0x0510},
+		{size: 0x0b, buffer: []byte{0x02, 0x04}, expected: 0x030204 + 2048},
+		{size: 0x11, buffer: []byte{0x00, 0x00, 0x01}, expected: 0x01000001 + 526336},
+		{size: 0x1f, buffer: []byte{0x01, 0x02, 0x03, 0x04}, expected: 0x01020304},
+		{size: 0x18, buffer: []byte{0xff, 0xff, 0xff, 0xff}, expected: 0xffffffff},
+	}
+
+	for _, test := range tests {
+		d := decoder{buffer: test.buffer}
+		pointer, newOffset, err := d.decodePointer(test.size, 0)
+		require.NoError(t, err)
+		assert.Equal(t, test.expected, pointer, "pointer for %x", test.buffer)
+		assert.Equal(t, uint(len(test.buffer)), newOffset)
+	}
+}
+
 func TestPointers(t *testing.T) {
 	bytes, err := os.ReadFile(testFile("maps-with-pointers.raw"))
 	require.NoError(t, err)
@@ -244,3 +344,713 @@ func TestPointers(t *testing.T) {
 		}
 	}
 }
+
+func TestIntegerMapKeys(t *testing.T) {
+	type asn uint32
+
+	// {"127": 1, "-128": 2}
+	signed := "e243313237a101442d313238a102"
+	// {"255": 1, "0": 2}
+	unsigned := "e243323535a1014130a102"
+	tests := []struct {
+		result   any
+		expected any
+		input    string
+	}{
+		{&map[int8]uint16{}, &map[int8]uint16{127: 1, -128: 2}, signed},
+		{&map[int16]uint16{}, &map[int16]uint16{127: 1, -128: 2}, signed},
+		{&map[int32]uint16{}, &map[int32]uint16{127: 1, -128: 2}, signed},
+		{&map[int64]uint16{}, &map[int64]uint16{127: 1, -128: 2}, signed},
+		{&map[int]uint16{}, &map[int]uint16{127: 1, -128: 2}, signed},
+		{&map[uint8]uint16{}, &map[uint8]uint16{255: 1, 0: 2}, unsigned},
+		{&map[uint16]uint16{}, &map[uint16]uint16{255: 1, 0: 2}, unsigned},
+		{&map[uint32]uint16{}, &map[uint32]uint16{255: 1, 0: 2}, unsigned},
+		{&map[uint64]uint16{}, &map[uint64]uint16{255: 1, 0: 2}, unsigned},
+		{&map[uint]uint16{}, &map[uint]uint16{255: 1, 0: 2}, unsigned},
+		// {"13335": 1}
+		{&map[asn]uint16{}, &map[asn]uint16{13335: 1}, "e1453133333335a101"},
+	}
+	for _, test := range tests {
+		input, err := hex.DecodeString(test.input)
+		require.NoError(t, err)
+		d := decoder{buffer: input}
+
+		_, err = d.decode(0, reflect.ValueOf(test.result), 0)
+		require.NoError(t, err)
+		assert.Equal(t, test.expected, test.result)
+	}
+}
+
+type testLang string
+
+func TestTypedMaps(t *testing.T) {
+	// {"names": {"en": "a", "de": "b"}, "flags": {"x": true, "y": false},
+	// "counts": {"a": uint64(7)}, "tags": {"k": ["p", "q"]}, "ratio": {"r": 1.5}}
+	buffer, err := hex.DecodeString("e5" +
+		"456e616d6573" + "e2" + "42656e" + "4161" + "426465" + "4162" +
+		"45666c616773" + "e2" + "4178" + "0107" + "4179" + "0007" +
+		"46636f756e7473" + "e1" + "4161" + "010207" +
+		"4474616773" + "e1" + "416b" + "0204" + "4170" + "4171" +
+		"45726174696f" + "e1" + "4172" + "683ff8000000000000")
+	require.NoError(t, err)
+	d := decoder{buffer: buffer}
+
+	var result struct {
+		Names  map[testLang]string `maxminddb:"names"`
+		Flags  map[string]bool     `maxminddb:"flags"`
+		Counts map[string]uint64   `maxminddb:"counts"`
+		Tags   map[string][]string `maxminddb:"tags"`
+		Ratio  map[string]float64  `maxminddb:"ratio"`
+	}
+	_, err = d.decode(0, reflect.ValueOf(&result), 0)
+	require.NoError(t, err)
+	assert.Equal(t, map[testLang]string{"en": "a", "de": "b"}, result.Names)
+	assert.Equal(t, map[string]bool{"x": true, "y": false}, result.Flags)
+	assert.Equal(t, map[string]uint64{"a": 7}, result.Counts)
+	assert.Equal(t, map[string][]string{"k": {"p", "q"}}, result.Tags)
+	assert.Equal(t, map[string]float64{"r": 1.5}, result.Ratio)
+
+	var nested map[testLang]map[string]any
+	_, err = d.decode(0, reflect.ValueOf(&nested), 0)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]any{"x": true, "y": false}, nested["flags"])
+
+	// A mismatched value is reported with its key.
+	var bad struct {
+		Flags map[string]uint64 `maxminddb:"flags"`
+	}
+	err = decodeRecord(&d, &bad)
+	var fieldErr FieldError
+	require.ErrorAs(t, err, &fieldErr)
+	assert.Equal(t, "flags.x", fieldErr.Path)
+	var typeErr UnmarshalTypeError
+	require.ErrorAs(t, err, &typeErr)
+	assert.EqualError(t, err, "maxminddb: error decoding flags.x: maxminddb: cannot unmarshal true into type uint64")
+
+	var badNested map[string]map[string][]uint16
+	_, err = d.decode(0, reflect.ValueOf(&badNested), 0)
+	require.ErrorAs(t, err, &fieldErr)
+	assert.Equal(t, "names.en", fieldErr.Path)
+
+	d.softFail = true
+	err = decodeRecord(&d, &bad)
+	var fieldErrs FieldErrors
+	require.ErrorAs(t, err, &fieldErrs)
+	require.Len(t, fieldErrs, 2)
+	assert.Equal(t, "flags.x", fieldErrs[0].Path)
+	assert.Equal(t, "flags.y", fieldErrs[1].Path)
+	assert.Empty(t, bad.Flags)
+}
+
+// prefixedID is an integer map key decoded with UnmarshalText, which takes
+// precedence over parsing the key as an integer.
+type prefixedID uint32
+
+func (id *prefixedID) UnmarshalText(text []byte) error {
+	n, err := strconv.ParseUint(strings.TrimPrefix(string(text), "id-"), 10, 32)
+	*id = prefixedID(n)
+	return err
+}
+
+func TestTextUnmarshalerMapKeys(t *testing.T) {
+	// {"id-5": "a", "7": "b"}
+	buffer, err := hex.DecodeString("e2" + "4469642d35" + "4161" + "4137" + "4162")
+	require.NoError(t, err)
+	d := decoder{buffer: buffer}
+
+	var result map[prefixedID]string
+	_, err = d.decode(0, reflect.ValueOf(&result), 0)
+	require.NoError(t, err)
+	assert.Equal(t, map[prefixedID]string{5: "a", 7: "b"}, result)
+
+	// {"id-x": "a"}
+	buffer, err = hex.DecodeString("e1" + "4469642d78" + "4161")
+	require.NoError(t, err)
+	d = decoder{buffer: buffer}
+	_, err = d.decode(0, reflect.ValueOf(&result), 0)
+	var numErr *strconv.NumError
+	require.ErrorAs(t, err, &numErr)
+	assert.EqualError(
+		t,
+		err,
+		`maxminddb: cannot unmarshal "id-x" map key into type maxminddb.prefixedID: `+numErr.Error(),
+	)
+
+	d = decoder{buffer: buffer, softFail: true}
+	result = nil
+	_, err = d.decode(0, reflect.ValueOf(&result), 0)
+	require.NoError(t, err)
+	assert.Empty(t, result)
+	require.Len(t, d.fieldErrors, 1)
+	assert.Equal(t, "id-x", d.fieldErrors[0].Path)
+}
+
+func TestIntegerMapKeysErrors(t *testing.T) {
+	tests := []struct {
+		result   any
+		expected string
+		input    string
+	}{
+		{
+			&map[int8]uint16{},
+			`maxminddb: cannot unmarshal "128" map key into type int8`,
+			// {"128": 1}
+			"e143313238a101",
+		},
+		{
+			&map[uint8]uint16{},
+			`maxminddb: cannot unmarshal "-1" map key into type uint8`,
+			// {"-1": 1}
+			"e1422d31a101",
+		},
+		{
+			&map[uint32]uint16{},
+			`maxminddb: cannot unmarshal "AS13335" map key into type uint32`,
+			// {"AS13335": 1}
+			"e14741533133333335a101",
+		},
+		{
+			&map[float64]uint16{},
+			"maxminddb: cannot unmarshal map into type map[float64]uint16",
+			// {"1": 1}
+			"e14131a101",
+		},
+	}
+	for _, test := range tests {
+		input, err := hex.DecodeString(test.input)
+		require.NoError(t, err)
+		d := decoder{buffer: input}
+
+		_, err = d.decode(0, reflect.ValueOf(test.result), 0)
+		assert.EqualError(t, err, test.expected)
+	}
+}
+
+func TestIntegerMapKeysSoftFail(t *testing.T) {
+	// {"256": 1, "7": 2}
+	input, err := hex.DecodeString("e243323536a1014137a102")
+	require.NoError(t, err)
+	d := decoder{buffer: input, softFail: true}
+
+	var result map[uint8]uint16
+	_, err = d.decode(0, reflect.ValueOf(&result), 0)
+	require.NoError(t, err)
+	assert.Equal(t, map[uint8]uint16{7: 2}, result)
+	require.Len(t, d.fieldErrors, 1)
+	assert.Equal(t, "256", d.fieldErrors[0].Path)
+}
+
+var errBadVector = errors.New("bad vector")
+
+// packedVector is a bit-packed payload stored as a bytes value.
+type packedVector struct {
+	data []byte
+}
+
+func (v *packedVector) UnmarshalBinary(data []byte) error {
+	if len(data) > 0 && data[0] == 0xff {
+		return errBadVector
+	}
+	v.data = data
+	return nil
+}
+
+// {"nums": [1, 2, 3], "ip": <bytes 01020304>, "points": [{"x": 1}, {"x": 2}]}
+const arrayTestRecord = "e3" +
+	"446e756d73" + "0304" + "a101" + "a102" + "a103" +
+	"426970" + "8401020304" +
+	"46706f696e7473" + "0204" + "e1" + "4178" + "a101" + "e1" + "4178" + "a102"
+
+type arrayPoint struct {
+	X uint `maxminddb:"x"`
+}
+
+func TestDecodeArray(t *testing.T) {
+	buffer, err := hex.DecodeString(arrayTestRecord)
+	require.NoError(t, err)
+	d := decoder{buffer: buffer}
+
+	var result struct {
+		Nums   [3]uint       `maxminddb:"nums"`
+		IP     [4]byte       `maxminddb:"ip"`
+		Points [2]arrayPoint `maxminddb:"points"`
+	}
+	_, err = d.decode(0, reflect.ValueOf(&result), 0)
+	require.NoError(t, err)
+	assert.Equal(t, [3]uint{1, 2, 3}, result.Nums)
+	assert.Equal(t, [4]byte{1, 2, 3, 4}, result.IP)
+	assert.Equal(t, [2]arrayPoint{{X: 1}, {X: 2}}, result.Points)
+
+	var pointers struct {
+		Points [2]*arrayPoint `maxminddb:"points"`
+		Nums   [3]any         `maxminddb:"nums"`
+	}
+	_, err = d.decode(0, reflect.ValueOf(&pointers), 0)
+	require.NoError(t, err)
+	assert.Equal(t, [2]*arrayPoint{{X: 1}, {X: 2}}, pointers.Points)
+	assert.Equal(t, [3]any{uint64(1), uint64(2), uint64(3)}, pointers.Nums)
+
+	// Shorter values leave the rest of the array zero.
+	longer := struct {
+		Nums [5]uint16 `maxminddb:"nums"`
+		IP   [6]byte   `maxminddb:"ip"`
+	}{
+		Nums: [5]uint16{9, 9, 9, 9, 9},
+		IP:   [6]byte{9, 9, 9, 9, 9, 9},
+	}
+	_, err = d.decode(0, reflect.ValueOf(&longer), 0)
+	require.NoError(t, err)
+	assert.Equal(t, [5]uint16{1, 2, 3, 0, 0}, longer.Nums)
+	assert.Equal(t, [6]byte{1, 2, 3, 4, 0, 0}, longer.IP)
+
+	var shortNums struct {
+		Nums [2]uint `maxminddb:"nums"`
+	}
+	err = decodeRecord(&d, &shortNums)
+	var fieldErr FieldError
+	require.ErrorAs(t, err, &fieldErr)
+	assert.Equal(t, "nums", fieldErr.Path)
+	assert.EqualError(
+		t,
+		err,
+		"maxminddb: error decoding nums: maxminddb: cannot unmarshal array of 3 elements into type [2]uint",
+	)
+
+	var shortIP struct {
+		IP [2]byte `maxminddb:"ip"`
+	}
+	err = decodeRecord(&d, &shortIP)
+	assert.EqualError(t, err, "maxminddb: error decoding ip: maxminddb: cannot unmarshal 4 bytes into type [2]uint8")
+
+	// Arrays of other element types are not decoded from bytes.
+	var wide struct {
+		IP [4]uint16 `maxminddb:"ip"`
+	}
+	err = decodeRecord(&d, &wide)
+	var typeErr UnmarshalTypeError
+	require.ErrorAs(t, err, &typeErr)
+}
+
+func TestDecodeArrayTruncateAndSoftFail(t *testing.T) {
+	buffer, err := hex.DecodeString(arrayTestRecord)
+	require.NoError(t, err)
+
+	type shortRecord struct {
+		Nums   [2]uint       `maxminddb:"nums"`
+		IP     [2]byte       `maxminddb:"ip"`
+		Points [1]arrayPoint `maxminddb:"points"`
+	}
+
+	d := decoder{buffer: buffer, truncateArrays: true}
+	var truncated shortRecord
+	_, err = d.decode(0, reflect.ValueOf(&truncated), 0)
+	require.NoError(t, err)
+	assert.Equal(t, shortRecord{
+		Nums:   [2]uint{1, 2},
+		IP:     [2]byte{1, 2},
+		Points: [1]arrayPoint{{X: 1}},
+	}, truncated)
+
+	d = decoder{buffer: buffer, softFail: true}
+	var soft struct {
+		Nums   [2]uint       `maxminddb:"nums"`
+		Points [2]arrayPoint `maxminddb:"points"`
+	}
+	_, err = d.decode(0, reflect.ValueOf(&soft), 0)
+	require.NoError(t, err)
+	require.Len(t, d.fieldErrors, 1)
+	assert.Equal(t, "nums", d.fieldErrors[0].Path)
+	var typeErr UnmarshalTypeError
+	assert.ErrorAs(t, d.fieldErrors[0], &typeErr)
+	assert.Equal(t, [2]uint{}, soft.Nums)
+	assert.Equal(t, [2]arrayPoint{{X: 1}, {X: 2}}, soft.Points)
+}
+
+func TestBinaryUnmarshaler(t *testing.T) {
+	// {"vec": <bytes 010203>, "name": "abc", "bad": <bytes ff>}
+	buffer, err := hex.DecodeString("e3" +
+		"43766563" + "83010203" +
+		"446e616d65" + "43616263" +
+		"43626164" + "81ff")
+	require.NoError(t, err)
+	d := decoder{buffer: buffer}
+
+	var result struct {
+		Vec packedVector `maxminddb:"vec"`
+	}
+	_, err = d.decode(0, reflect.ValueOf(&result), 0)
+	require.NoError(t, err)
+	assert.Equal(t, []byte{1, 2, 3}, result.Vec.data)
+
+	// The bytes passed to UnmarshalBinary are a copy.
+	copy(buffer[6:9], []byte{0, 0, 0})
+	assert.Equal(t, []byte{1, 2, 3}, result.Vec.data)
+	copy(buffer[6:9], []byte{1, 2, 3})
+
+	var pointer struct {
+		Vec *packedVector `maxminddb:"vec"`
+	}
+	_, err = d.decode(0, reflect.ValueOf(&pointer), 0)
+	require.NoError(t, err)
+	require.NotNil(t, pointer.Vec)
+	assert.Equal(t, []byte{1, 2, 3}, pointer.Vec.data)
+
+	// Strings are not passed to UnmarshalBinary.
+	var str struct {
+		Name packedVector `maxminddb:"name"`
+	}
+	err = decodeRecord(&d, &str)
+	var typeErr UnmarshalTypeError
+	require.ErrorAs(t, err, &typeErr)
+
+	var bad struct {
+		Bad packedVector `maxminddb:"bad"`
+	}
+	_, err = d.decode(0, reflect.ValueOf(&bad), 0)
+	require.ErrorIs(t, err, errBadVector)
+	var fieldErr FieldError
+	require.ErrorAs(t, err, &fieldErr)
+	assert.Equal(t, "bad", fieldErr.Path)
+	assert.EqualError(t, err, "maxminddb: error decoding bad: bad vector")
+
+	d = decoder{buffer: buffer, softFail: true}
+	_, err = d.decode(0, reflect.ValueOf(&bad), 0)
+	require.NoError(t, err)
+	require.Len(t, d.fieldErrors, 1)
+	assert.Equal(t, "bad", d.fieldErrors[0].Path)
+	assert.ErrorIs(t, d.fieldErrors[0], errBadVector)
+}
+
+var errBadCountryCode = errors.New("bad country code")
+
+type countryCode [2]byte
+
+func (c *countryCode) UnmarshalText(text []byte) error {
+	if len(text) != 2 {
+		return errBadCountryCode
+	}
+	copy(c[:], bytes.ToUpper(text))
+	return nil
+}
+
+func TestTextUnmarshaler(t *testing.T) {
+	// {"code": "gb", "codes": ["de", "fr"], "names": {"a": "us"}, "bad": "xyz", "num": uint16(1)}
+	buffer, err := hex.DecodeString("e5" +
+		"44636f6465" + "426762" +
+		"45636f646573" + "0204" + "426465" + "426672" +
+		"456e616d6573" + "e1" + "4161" + "427573" +
+		"43626164" + "4378797a" +
+		"436e756d" + "a101")
+	require.NoError(t, err)
+	d := decoder{buffer: buffer}
+
+	var result struct {
+		Code  countryCode            `maxminddb:"code"`
+		Codes []countryCode          `maxminddb:"codes"`
+		Names map[string]countryCode `maxminddb:"names"`
+	}
+	_, err = d.decode(0, reflect.ValueOf(&result), 0)
+	require.NoError(t, err)
+	assert.Equal(t, countryCode{'G', 'B'}, result.Code)
+	assert.Equal(t, []countryCode{{'D', 'E'}, {'F', 'R'}}, result.Codes)
+	assert.Equal(t, map[string]countryCode{"a": {'U', 'S'}}, result.Names)
+
+	var pointer struct {
+		Code *countryCode `maxminddb:"code"`
+	}
+	_, err = d.decode(0, reflect.ValueOf(&pointer), 0)
+	require.NoError(t, err)
+	require.NotNil(t, pointer.Code)
+	assert.Equal(t, countryCode{'G', 'B'}, *pointer.Code)
+
+	// Other types of values are not passed to UnmarshalText.
+	var num struct {
+		Num countryCode `maxminddb:"num"`
+	}
+	err = decodeRecord(&d, &num)
+	var typeErr UnmarshalTypeError
+	require.ErrorAs(t, err, &typeErr)
+
+	var bad struct {
+		Codes []countryCode `maxminddb:"codes"`
+		Bad   countryCode   `maxminddb:"bad"`
+	}
+	_, err = d.decode(0, reflect.ValueOf(&bad), 0)
+	require.ErrorIs(t, err, errBadCountryCode)
+	var fieldErr FieldError
+	require.ErrorAs(t, err, &fieldErr)
+	assert.Equal(t, "bad", fieldErr.Path)
+	assert.EqualError(t, err, "maxminddb: error decoding bad: bad country code")
+
+	d = decoder{buffer: buffer, softFail: true}
+	_, err = d.decode(0, reflect.ValueOf(&bad), 0)
+	require.NoError(t, err)
+	require.Len(t, d.fieldErrors, 1)
+	assert.Equal(t, "bad", d.fieldErrors[0].Path)
+	assert.ErrorIs(t, d.fieldErrors[0], errBadCountryCode)
+	assert.Equal(t, []countryCode{{'D', 'E'}, {'F', 'R'}}, bad.Codes)
+}
+
+func TestDecodeStructFieldMismatches(t *testing.T) {
+	// {"a": "x", "b": 42, "loc": {"lat": "y", "lon": 1.5}}
+	buffer, err := hex.DecodeString(
+		"e3416141784162a12a436c6f63e2436c61744179436c6f6e683ff8000000000000",
+	)
+	require.NoError(t, err)
+	d := decoder{buffer: buffer}
+
+	var result struct {
+		A   int    `maxminddb:"a"`
+		B   uint16 `maxminddb:"b"`
+		Loc struct {
+			Lat float64 `maxminddb:"lat"`
+			Lon float64 `maxminddb:"lon"`
+		} `maxminddb:"loc"`
+	}
+	result.A = 7
+	err = decodeRecord(&d, &result)
+	var fieldErrs FieldErrors
+	require.ErrorAs(t, err, &fieldErrs)
+	require.Len(t, fieldErrs, 2)
+	assert.Equal(t, "a", fieldErrs[0].Path)
+	assert.Equal(t, "loc.lat", fieldErrs[1].Path)
+	var typeErr UnmarshalTypeError
+	require.ErrorAs(t, err, &typeErr)
+	assert.EqualError(
+		t,
+		err,
+		"maxminddb: error decoding a: maxminddb: cannot unmarshal x into type int\n"+
+			"maxminddb: error decoding loc.lat: maxminddb: cannot unmarshal y into type float64",
+	)
+
+	// The other fields are decoded.
+	assert.Equal(t, 0, result.A)
+	assert.Equal(t, uint16(42), result.B)
+	assert.InDelta(t, 1.5, result.Loc.Lon, 0)
+
+	// Errors in reading the database still abort the decode.
+	d = decoder{buffer: buffer[:len(buffer)-4]}
+	err = decodeRecord(&d, &result)
+	var dbErr InvalidDatabaseError
+	require.ErrorAs(t, err, &dbErr)
+	assert.False(t, errors.As(err, &fieldErrs))
+}
+
+func TestRequiredFields(t *testing.T) {
+	// {"country": {"geoname_id": 1}, "subdivisions": [{"iso_code": "EN"}, {}]}
+	buffer, err := hex.DecodeString(
+		"e247636f756e747279e14a67656f6e616d655f6964c1014c7375626469766973696f6e73" +
+			"0204e14869736f5f636f646542454ee0",
+	)
+	require.NoError(t, err)
+	d := decoder{buffer: buffer}
+
+	type subdivision struct {
+		ISOCode string `maxminddb:"iso_code,required"`
+	}
+	var result struct {
+		Country struct {
+			ISOCode   string `maxminddb:"iso_code,required"`
+			GeoNameID uint32 `maxminddb:"geoname_id"`
+		} `maxminddb:"country,required"`
+		Subdivisions []subdivision `maxminddb:"subdivisions"`
+		// Not checked as the postal key is missing.
+		Postal struct {
+			Code string `maxminddb:"code,required"`
+		} `maxminddb:"postal"`
+		City struct{} `maxminddb:"city,required"`
+	}
+	err = decodeRecord(&d, &result)
+	var fieldErrs FieldErrors
+	require.ErrorAs(t, err, &fieldErrs)
+	var paths []string
+	for _, fieldErr := range fieldErrs {
+		assert.ErrorIs(t, fieldErr, ErrMissingRequiredKey)
+		paths = append(paths, fieldErr.Path)
+	}
+	assert.Equal(t, []string{"country.iso_code", "subdivisions[1].iso_code", "city"}, paths)
+	assert.Contains(
+		t,
+		err.Error(),
+		"maxminddb: error decoding country.iso_code: maxminddb: required key is missing",
+	)
+
+	// The rest of the record is decoded.
+	assert.Equal(t, uint32(1), result.Country.GeoNameID)
+	assert.Equal(t, []subdivision{{ISOCode: "EN"}, {}}, result.Subdivisions)
+
+	var optional struct {
+		Country struct {
+			ISOCode string `maxminddb:"iso_code"`
+		} `maxminddb:"country"`
+	}
+	require.NoError(t, decodeRecord(&d, &optional))
+}
+
+type mismatchEmbedded struct {
+	A *struct {
+		B *string `maxminddb:"b"`
+	} `maxminddb:"a"`
+}
+
+func TestUnmarshalTypeErrorPath(t *testing.T) {
+	// {"a": {"b": 1}, "subdivisions": [{"names": {"en": 42}}]}
+	buffer, err := hex.DecodeString(
+		"e24161e14162a1014c7375626469766973696f6e730104e1456e616d6573e142656ec12a",
+	)
+	require.NoError(t, err)
+	d := decoder{buffer: buffer}
+
+	var result struct {
+		mismatchEmbedded
+		Subdivisions []struct {
+			Names map[string]string `maxminddb:"names"`
+		} `maxminddb:"subdivisions"`
+	}
+	err = decodeRecord(&d, &result)
+	var fieldErrs FieldErrors
+	require.ErrorAs(t, err, &fieldErrs)
+	require.Len(t, fieldErrs, 2)
+
+	var typeErr UnmarshalTypeError
+	require.ErrorAs(t, fieldErrs[0], &typeErr)
+	assert.Equal(t, "a.b", typeErr.Path)
+	assert.Equal(t, KindUint16, typeErr.DatabaseType)
+	assert.Equal(t, reflect.TypeOf(""), typeErr.Type)
+	assert.EqualError(t, typeErr, "maxminddb: cannot unmarshal 1 into type string at a.b")
+
+	require.ErrorAs(t, fieldErrs[1], &typeErr)
+	assert.Equal(t, "subdivisions[0].names.en", typeErr.Path)
+	assert.Equal(t, KindUint32, typeErr.DatabaseType)
+	assert.Equal(t, reflect.TypeOf(""), typeErr.Type)
+
+	// A mismatch of the record itself has no path.
+	var number int
+	err = decodeRecord(&d, &number)
+	require.ErrorAs(t, err, &typeErr)
+	assert.Equal(t, "", typeErr.Path)
+	assert.Equal(t, KindMap, typeErr.DatabaseType)
+}
+
+type skipInner struct {
+	Inner  string `maxminddb:"inner"`
+	hidden string
+}
+
+type skipPointer struct {
+	X string `maxminddb:"x"`
+}
+
+type SkipPointer struct {
+	Y string `maxminddb:"y"`
+}
+
+func TestSkippedFields(t *testing.T) {
+	// {"-": "dash", "name": "n", "secret": "s", "inner": "i", "hidden": "h",
+	// "x": "x", "y": "y", "ptr": {"z": "z", "skip": "s"}}
+	buffer, err := hex.DecodeString(
+		"e8412d4464617368446e616d65416e46736563726574417345696e6e6572416946686964" +
+			"64656e4168417841784179417943707472e2417a417a44736b69704173",
+	)
+	require.NoError(t, err)
+	d := decoder{buffer: buffer}
+
+	type result struct {
+		skipInner
+		*skipPointer
+		*SkipPointer
+		Dash   string `maxminddb:"-,"`
+		Name   string `maxminddb:"-"`
+		secret string `maxminddb:"secret"`
+		Ptr    *struct {
+			Z    string  `maxminddb:"z"`
+			Skip *string `maxminddb:"-"`
+		} `maxminddb:"ptr"`
+	}
+	var r result
+	require.NoError(t, decodeRecord(&d, &r))
+	assert.Equal(t, "i", r.Inner)
+	assert.Equal(t, "", r.hidden)
+	assert.Nil(t, r.skipPointer)
+	require.NotNil(t, r.SkipPointer)
+	assert.Equal(t, "y", r.Y)
+	assert.Equal(t, "dash", r.Dash)
+	assert.Equal(t, "", r.Name)
+	assert.Equal(t, "", r.secret)
+	require.NotNil(t, r.Ptr)
+	assert.Equal(t, "z", r.Ptr.Z)
+	assert.Nil(t, r.Ptr.Skip)
+
+	// An allocated embedded pointer to an unexported struct is left as is.
+	r = result{skipPointer: &skipPointer{X: "set"}}
+	require.NoError(t, decodeRecord(&d, &r))
+	assert.Equal(t, "set", r.X)
+}
+
+type RecursiveNode struct {
+	*RecursiveNode
+	Name     string          `maxminddb:"name"`
+	Children []RecursiveNode `maxminddb:"children"`
+}
+
+type RecursiveA struct {
+	*RecursiveB
+	Name string `maxminddb:"name"`
+}
+
+type RecursiveB struct {
+	*RecursiveA
+	Children []RecursiveA `maxminddb:"children"`
+}
+
+func TestRecursiveStructTypes(t *testing.T) {
+	// {"name": "a", "children": [{"name": "b", "children": []}]}
+	buffer, err := hex.DecodeString(
+		"e2446e616d654161486368696c6472656e0104e2446e616d654162486368696c6472656e0004",
+	)
+	require.NoError(t, err)
+	d := decoder{buffer: buffer}
+
+	var node RecursiveNode
+	require.NoError(t, decodeRecord(&d, &node))
+	assert.Equal(t, "a", node.Name)
+	assert.Nil(t, node.RecursiveNode, "a struct embedding itself is not decoded again")
+	require.Len(t, node.Children, 1)
+	assert.Equal(t, "b", node.Children[0].Name)
+	assert.Empty(t, node.Children[0].Children)
+
+	var a RecursiveA
+	require.NoError(t, decodeRecord(&d, &a))
+	assert.Equal(t, "a", a.Name)
+	require.NotNil(t, a.RecursiveB)
+	require.Len(t, a.Children, 1)
+	assert.Equal(t, "b", a.Children[0].Name)
+	require.NotNil(t, a.RecursiveB.RecursiveA)
+	assert.Nil(t, a.RecursiveB.RecursiveA.RecursiveB)
+}
+
+func TestCachedFieldsConcurrent(t *testing.T) {
+	buffer, err := hex.DecodeString(
+		"e2446e616d654161486368696c6472656e0104e2446e616d654162486368696c6472656e0004",
+	)
+	require.NoError(t, err)
+	fieldsMap.Delete(fieldsKey{typ: reflect.TypeOf(RecursiveNode{})})
+
+	var wg sync.WaitGroup
+	results := make([]RecursiveNode, 8)
+	errs := make([]error, len(results))
+	for i := range results {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			d := decoder{buffer: buffer}
+			errs[i] = decodeRecord(&d, &results[i])
+		}(i)
+	}
+	wg.Wait()
+	for i, result := range results {
+		require.NoError(t, errs[i])
+		assert.Equal(t, "a", result.Name)
+		require.Len(t, result.Children, 1)
+	}
+}
@@ -0,0 +1,150 @@
+package maxminddb
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"runtime"
+
+	"github.com/3JoB/go-reflect"
+)
+
+// DistinctValues returns each distinct value found at path in the records of
+// the database along with the number of records that contain it. Each record
+// is counted once, no matter how many networks point to it.
+//
+// The elements of path select the value to extract: a string selects the
+// value with that key in a map and an int selects the element at that index
+// in an array. A negative index counts from the end of the array. For
+// instance, ("subdivisions", 0, "iso_code") selects the ISO code of the first
+// subdivision. Records without a value at path do not contribute.
+//
+// String values are returned as is. Other values are formatted as JSON, e.g.,
+// 51.5142 or {"en":"London"}.
+func (r *Reader) DistinctValues(path ...any) (map[string]int, error) {
+	if r.buffer == nil {
+		return nil, errors.New("cannot call DistinctValues on a closed database")
+	}
+	if err := checkPath(path); err != nil {
+		return nil, err
+	}
+
+	values := map[string]int{}
+	seen := map[uintptr]bool{}
+	n := r.Networks(SkipAliasedNetworks)
+	for n.Next() {
+		offset, err := r.resolveDataPointer(n.lastNode.parent, n.lastNode.pointer)
+		if err != nil {
+			return nil, err
+		}
+		if seen[offset] {
+			continue
+		}
+		seen[offset] = true
+
+		value, ok, err := r.valueAtPath(uint(offset), path)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			values[value]++
+		}
+	}
+	if err := n.Err(); err != nil {
+		return nil, err
+	}
+	runtime.KeepAlive(r)
+	return values, nil
+}
+
+// checkPath returns an error if path has an element that is neither a
+// string nor an int.
+func checkPath(path []any) error {
+	for _, elem := range path {
+		switch elem.(type) {
+		case string, int:
+		default:
+			return fmt.Errorf("invalid path element %v (%T); expected a string or an int", elem, elem)
+		}
+	}
+	return nil
+}
+
+// valueAtPath returns the value at path in the record at offset, formatted
+// as described by DistinctValues. ok is false if the record has no value at
+// path.
+func (r *Reader) valueAtPath(offset uint, path []any) (value string, ok bool, err error) {
+	d := r.decoder
+
+	for _, elem := range path {
+		typeNum, size, newOffset, err := d.decodeCtrlData(offset)
+		if err != nil {
+			return "", false, err
+		}
+		if typeNum == _Pointer {
+			pointer, _, err := d.decodePointer(size, newOffset)
+			if err != nil {
+				return "", false, err
+			}
+			typeNum, size, newOffset, err = d.decodeCtrlData(pointer)
+			if err != nil {
+				return "", false, err
+			}
+		}
+		offset = newOffset
+
+		switch elem := elem.(type) {
+		case string:
+			if typeNum != _Map {
+				return "", false, nil
+			}
+			found := false
+			for i := uint(0); i < size; i++ {
+				var key []byte
+				key, offset, err = d.decodeKey(offset)
+				if err != nil {
+					return "", false, err
+				}
+				if string(key) == elem {
+					found = true
+					break
+				}
+				offset, err = d.nextValueOffset(offset, 1)
+				if err != nil {
+					return "", false, err
+				}
+			}
+			if !found {
+				return "", false, nil
+			}
+		case int:
+			if typeNum != _Slice {
+				return "", false, nil
+			}
+			i := elem
+			if i < 0 {
+				i += int(size)
+			}
+			if i < 0 || uint(i) >= size {
+				return "", false, nil
+			}
+			offset, err = d.nextValueOffset(offset, uint(i))
+			if err != nil {
+				return "", false, err
+			}
+		}
+	}
+
+	var result any
+	if _, err := d.decode(offset, reflect.ValueOf(&result), 0); err != nil {
+		return "", false, err
+	}
+	if s, ok := result.(string); ok {
+		return s, true, nil
+	}
+	b, err := json.Marshal(result)
+	if err != nil {
+		return "", false, err
+	}
+	return string(b), true, nil
+}
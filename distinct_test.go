@@ -0,0 +1,49 @@
+package maxminddb
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDistinctValues(t *testing.T) {
+	reader, err := Open(testFile("GeoIP2-City-Test.mmdb"))
+	require.NoError(t, err)
+
+	countries, err := reader.DistinctValues("country", "iso_code")
+	require.NoError(t, err)
+	assert.Greater(t, countries["GB"], 0)
+	assert.Greater(t, countries["SE"], 0)
+	assert.NotContains(t, countries, "")
+
+	subdivisions, err := reader.DistinctValues("subdivisions", 0, "iso_code")
+	require.NoError(t, err)
+	assert.Greater(t, subdivisions["ENG"], 0)
+
+	last, err := reader.DistinctValues("subdivisions", -1, "iso_code")
+	require.NoError(t, err)
+	assert.Greater(t, last["ENG"], 0)
+
+	geonameIDs, err := reader.DistinctValues("country", "geoname_id")
+	require.NoError(t, err)
+	assert.Greater(t, geonameIDs["2635167"], 0)
+
+	names, err := reader.DistinctValues("city", "names")
+	require.NoError(t, err)
+	for name := range names {
+		assert.Equal(t, byte('{'), name[0], name)
+	}
+
+	missing, err := reader.DistinctValues("no", "such", "path")
+	require.NoError(t, err)
+	assert.Empty(t, missing)
+
+	_, err = reader.DistinctValues("country", 1.5)
+	assert.EqualError(t, err, "invalid path element 1.5 (float64); expected a string or an int")
+
+	require.NoError(t, reader.Close())
+
+	_, err = reader.DistinctValues("country")
+	assert.EqualError(t, err, "cannot call DistinctValues on a closed database")
+}
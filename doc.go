@@ -0,0 +1,87 @@
+// Package maxminddb provides a reader for the MaxMind DB file format.
+//
+// The package does not import unsafe when built with the purego build tag.
+// Otherwise, unsafe is used to memory map database files on Windows and for
+// WithUnsafeStrings. With the tag, Open reads the database into memory on
+// Windows, which makes Open slower and costs memory equal to the size of the
+// file, and WithUnsafeStrings has no effect. Lookups and decoding are
+// otherwise unaffected. Dependencies, such as github.com/3JoB/go-reflect and
+// golang.org/x/sys, are not covered by the tag.
+//
+// # Decoding
+//
+// Lookup, Decode, and the other methods that decode a record store it in
+// the value pointed to by their result argument as described here.
+//
+// A struct field whose value cannot be stored in it because of type
+// differences is left at its zero value and the other fields are still
+// decoded. The mismatches are then returned together as a FieldErrors, each
+// with the path of its value, e.g., "location.latitude". Use errors.As with
+// an UnmarshalTypeError to check for any of them; its Path and DatabaseType
+// describe the mismatched value.
+//
+// Map keys are matched to struct fields using the field's maxminddb tag or,
+// without one, its name. The fields of a struct or pointer to a struct field
+// tagged ",inline", e.g., `maxminddb:",inline"`, are matched against the
+// keys of the map the enclosing struct is decoded from. If several fields
+// match a key, only one is decoded: a field of the enclosing struct takes
+// precedence over those of inline structs, then a field of a less deeply
+// nested inline struct takes precedence, then that of the inline struct
+// declared first. A pointer to an inline struct is left nil if none of its
+// fields match.
+//
+// A field with the lang tag option is decoded from a single value of the
+// map it matches, e.g., a string field tagged `maxminddb:"names,lang=en"`
+// holds the English name. Alternatives are separated by "|", e.g.,
+// "lang=pt-BR|pt", and the fallback option lists the languages tried if none
+// of lang are in the map, or is "any" to use the first entry of the map. If
+// no entry matches, the field is set to its zero value. The other entries
+// are skipped without being decoded.
+//
+// A map field with string keys tagged ",remain", e.g., a map[string]any
+// tagged `maxminddb:",remain"`, receives the entries of the map the struct
+// is decoded from whose keys no other field is decoded from, including
+// fields of inline and embedded structs. Each nested struct may have its
+// own. The map is left nil if there are no such entries.
+//
+// A field tagged ",required", e.g., `maxminddb:"iso_code,required"`, must
+// have its key in the map the struct is decoded from. Otherwise, a
+// FieldError wrapping ErrMissingRequiredKey with the path of the key, e.g.,
+// "country.iso_code", is returned among the FieldErrors once the rest of
+// the record has been decoded. Structs that are not decoded, such as those
+// whose own key is missing, are not checked.
+//
+// A field tagged with the string option, e.g., `maxminddb:"port,string"`,
+// also accepts a string holding a number, or "true" or "false" for a bool
+// field, and a string field tagged with it accepts a number. Likewise, a
+// bool field tagged with the boolnum option accepts an integer of 0 or 1
+// and an integer field tagged with it accepts a bool. Values that do not
+// convert exactly, e.g., "8080x" or 2, are type mismatches.
+//
+// A bytes value is decoded into a value implementing
+// encoding.BinaryUnmarshaler, through a pointer to it, by calling
+// UnmarshalBinary with a copy of the bytes. An error from UnmarshalBinary
+// is returned as a FieldError with the path of the value. Other types of
+// values, including strings, are decoded as usual. Likewise, a UTF-8
+// string value is decoded into a value implementing
+// encoding.TextUnmarshaler by calling UnmarshalText, e.g., for a
+// CountryCode type that validates its input.
+//
+// Arrays may also be decoded into Go arrays, as may bytes values into
+// arrays of bytes, e.g., [4]byte. The elements beyond the end of the value
+// are set to their zero values. A value with more elements than the Go
+// array is an error returned as a FieldError with the path of the value,
+// unless WithTruncateArrays is set.
+//
+// Maps may have keys of any string or integer type, e.g., map[Lang]string
+// for a named string type Lang, and values of any type a value can be
+// decoded into. An UnmarshalTypeError for a map value is returned as a
+// FieldError with the path of the value, e.g., "names.en".
+//
+// For integer keys, the keys in the database must be decimal integers that
+// fit the key type, e.g., "13335" for a map[uint32]ASNInfo; otherwise, an
+// UnmarshalTypeError naming the key is returned. Keys of types implementing
+// encoding.TextUnmarshaler, through a pointer to them, are instead decoded
+// by calling UnmarshalText, whatever their kind. An error from it is
+// returned wrapped in an error naming the key.
+package maxminddb
@@ -0,0 +1,206 @@
+package maxminddb
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"runtime"
+	"strings"
+	"sync"
+)
+
+// enrichBatchSize is the number of lines looked up by a worker at a time.
+const enrichBatchSize = 256
+
+// EnrichOption are options for EnrichStream.
+type EnrichOption func(*enrichOptions)
+
+type enrichOptions struct {
+	workers int
+}
+
+// WithEnrichWorkers is an option for EnrichStream that sets the number of
+// goroutines looking up addresses. The default is runtime.GOMAXPROCS(0).
+func WithEnrichWorkers(n int) EnrichOption {
+	return func(o *enrichOptions) {
+		o.workers = max(n, 1)
+	}
+}
+
+// enrichedLine is the JSON object EnrichStream writes for a line.
+type enrichedLine struct {
+	IP     string `json:"ip"`
+	Found  bool   `json:"found"`
+	Record any    `json:"record,omitempty"`
+	Error  string `json:"error,omitempty"`
+}
+
+type enrichBatch struct {
+	lines []string
+	done  chan []byte
+}
+
+// EnrichStream reads newline-delimited IP addresses from in, looks them up
+// in r and writes a JSON object for each address to out, one per line and
+// in the order of the input, e.g.,
+//
+//	{"ip":"81.2.69.142","found":true,"record":{"country":{...},...}}
+//
+// Addresses are parsed with ParseIP, and blank lines are skipped. Lines
+// that cannot be parsed or looked up are reported in the output, e.g.,
+//
+//	{"ip":"not an address","found":false,"error":"maxminddb: invalid IP address: ..."}
+//
+// rather than stopping EnrichStream, which only returns an error if reading
+// in or writing out fails or ctx is done. The lookups are spread across
+// several goroutines; see WithEnrichWorkers. r must not be closed before
+// EnrichStream returns.
+func EnrichStream(ctx context.Context, r *Reader, in io.Reader, out io.Writer, options ...EnrichOption) error {
+	opts := enrichOptions{workers: runtime.GOMAXPROCS(0)}
+	for _, option := range options {
+		option(&opts)
+	}
+
+	ctx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+
+	// Batches are sent to the workers through jobs and, in the same order,
+	// to the writer through pending, which waits for each batch in turn.
+	jobs := make(chan enrichBatch)
+	pending := make(chan enrichBatch, 2*opts.workers)
+
+	var workers sync.WaitGroup
+	for i := 0; i < opts.workers; i++ {
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case batch, ok := <-jobs:
+					if !ok {
+						return
+					}
+					batch.done <- r.enrichLines(batch.lines)
+				}
+			}
+		}()
+	}
+	// The reading goroutine is not waited for as it may be blocked on in
+	// after ctx is done. It does not use r.
+	go func() {
+		defer close(pending)
+		defer close(jobs)
+		if err := readEnrichBatches(ctx, in, jobs, pending); err != nil {
+			cancel(err)
+		}
+	}()
+
+	w := bufio.NewWriter(out)
+	err := func() error {
+		for {
+			var batch enrichBatch
+			select {
+			case <-ctx.Done():
+				return context.Cause(ctx)
+			case next, ok := <-pending:
+				if !ok {
+					// ctx is canceled before pending is closed if reading
+					// in failed.
+					if err := context.Cause(ctx); err != nil {
+						return err
+					}
+					return w.Flush()
+				}
+				batch = next
+			}
+			select {
+			case <-ctx.Done():
+				return context.Cause(ctx)
+			case data := <-batch.done:
+				if _, err := w.Write(data); err != nil {
+					return err
+				}
+			}
+		}
+	}()
+	cancel(err)
+	workers.Wait()
+	return err
+}
+
+// readEnrichBatches reads the lines of in and sends them in batches to both
+// jobs and pending.
+func readEnrichBatches(ctx context.Context, in io.Reader, jobs, pending chan<- enrichBatch) error {
+	send := func(lines []string) bool {
+		batch := enrichBatch{lines: lines, done: make(chan []byte, 1)}
+		select {
+		case <-ctx.Done():
+			return false
+		case pending <- batch:
+		}
+		select {
+		case <-ctx.Done():
+			return false
+		case jobs <- batch:
+			return true
+		}
+	}
+
+	scanner := bufio.NewScanner(in)
+	lines := make([]string, 0, enrichBatchSize)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		lines = append(lines, line)
+		if len(lines) == enrichBatchSize {
+			if !send(lines) {
+				return nil
+			}
+			lines = make([]string, 0, enrichBatchSize)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	if len(lines) > 0 {
+		send(lines)
+	}
+	return nil
+}
+
+// enrichLines returns the JSON objects written by EnrichStream for lines.
+func (r *Reader) enrichLines(lines []string) []byte {
+	var data []byte
+	for _, line := range lines {
+		result := enrichedLine{IP: line}
+		if err := r.enrichLine(&result); err != nil {
+			result.Found = false
+			result.Record = nil
+			result.Error = err.Error()
+		}
+		encoded, err := json.Marshal(result)
+		if err != nil {
+			encoded, _ = json.Marshal(enrichedLine{IP: line, Error: err.Error()})
+		}
+		data = append(append(data, encoded...), '\n')
+	}
+	return data
+}
+
+func (r *Reader) enrichLine(result *enrichedLine) error {
+	ip, err := ParseIP(result.IP)
+	if err != nil {
+		return err
+	}
+	offset, err := r.LookupOffset(ip)
+	if err != nil || offset == NotFound {
+		return err
+	}
+	result.Found = true
+	return r.Decode(offset, &result.Record)
+}
@@ -0,0 +1,146 @@
+package maxminddb
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEnrichStream(t *testing.T) {
+	reader, err := Open(testFile("MaxMind-DB-test-ipv4-24.mmdb"))
+	require.NoError(t, err)
+
+	in := "1.1.1.1\n\n  1.1.1.3 \r\nnot an address\n10.0.0.1\n::1\n"
+	var out bytes.Buffer
+	require.NoError(t, EnrichStream(context.Background(), reader, strings.NewReader(in), &out))
+
+	lines := strings.Split(strings.TrimSuffix(out.String(), "\n"), "\n")
+	require.Len(t, lines, 5)
+	assert.JSONEq(t, `{"ip":"1.1.1.1","found":true,"record":{"ip":"1.1.1.1"}}`, lines[0])
+	assert.JSONEq(t, `{"ip":"1.1.1.3","found":true,"record":{"ip":"1.1.1.2"}}`, lines[1])
+	assert.Contains(t, lines[2], `"ip":"not an address","found":false,"error":"maxminddb: invalid IP address`)
+	assert.JSONEq(t, `{"ip":"10.0.0.1","found":false}`, lines[3])
+	assert.Contains(t, lines[4], `"ip":"::1","found":false,"error":`)
+
+	require.NoError(t, reader.Close())
+}
+
+func TestEnrichStreamOrder(t *testing.T) {
+	reader, err := Open(testFile("MaxMind-DB-test-ipv4-24.mmdb"))
+	require.NoError(t, err)
+
+	var in strings.Builder
+	for i := 0; i < 10*enrichBatchSize+3; i++ {
+		fmt.Fprintf(&in, "1.1.1.%d\n", i%64)
+	}
+	var out bytes.Buffer
+	err = EnrichStream(context.Background(), reader, strings.NewReader(in.String()), &out, WithEnrichWorkers(4))
+	require.NoError(t, err)
+
+	scanner := bufio.NewScanner(&out)
+	i := 0
+	for ; scanner.Scan(); i++ {
+		var line struct {
+			IP string `json:"ip"`
+		}
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &line))
+		require.Equal(t, fmt.Sprintf("1.1.1.%d", i%64), line.IP)
+	}
+	assert.Equal(t, 10*enrichBatchSize+3, i)
+
+	require.NoError(t, reader.Close())
+}
+
+type failingWriter struct{}
+
+func (failingWriter) Write([]byte) (int, error) {
+	return 0, errors.New("write failed")
+}
+
+type failingReader struct{}
+
+func (failingReader) Read([]byte) (int, error) {
+	return 0, errors.New("read failed")
+}
+
+func TestEnrichStreamErrors(t *testing.T) {
+	reader, err := Open(testFile("MaxMind-DB-test-ipv4-24.mmdb"))
+	require.NoError(t, err)
+
+	in := strings.Repeat("1.1.1.1\n", 10*enrichBatchSize)
+	err = EnrichStream(context.Background(), reader, strings.NewReader(in), failingWriter{})
+	assert.EqualError(t, err, "write failed")
+
+	err = EnrichStream(context.Background(), reader, failingReader{}, io.Discard)
+	assert.EqualError(t, err, "read failed")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err = EnrichStream(ctx, reader, strings.NewReader(in), io.Discard)
+	assert.ErrorIs(t, err, context.Canceled)
+
+	require.NoError(t, reader.Close())
+}
+
+func enrichBenchmarkInput(b *testing.B) string {
+	//nolint:gosec // this is a test
+	r := rand.New(rand.NewSource(time.Now().UnixNano()))
+	var in strings.Builder
+	ip := make(net.IP, 4)
+	for i := 0; i < b.N; i++ {
+		randomIPv4Address(r, ip)
+		in.WriteString(ip.String())
+		in.WriteByte('\n')
+	}
+	return in.String()
+}
+
+func BenchmarkEnrichStream(b *testing.B) {
+	db, err := Open("GeoLite2-City.mmdb")
+	require.NoError(b, err)
+	in := enrichBenchmarkInput(b)
+
+	b.ResetTimer()
+	require.NoError(b, EnrichStream(context.Background(), db, strings.NewReader(in), io.Discard))
+	b.StopTimer()
+	require.NoError(b, db.Close())
+}
+
+// BenchmarkEnrichNaive is the single-threaded loop EnrichStream replaces.
+func BenchmarkEnrichNaive(b *testing.B) {
+	db, err := Open("GeoLite2-City.mmdb")
+	require.NoError(b, err)
+	in := enrichBenchmarkInput(b)
+
+	b.ResetTimer()
+	scanner := bufio.NewScanner(strings.NewReader(in))
+	w := bufio.NewWriter(io.Discard)
+	for scanner.Scan() {
+		var record any
+		ip := net.ParseIP(scanner.Text())
+		if err := db.Lookup(ip, &record); err != nil {
+			b.Fatal(err)
+		}
+		data, err := json.Marshal(map[string]any{"ip": scanner.Text(), "found": record != nil, "record": record})
+		if err != nil {
+			b.Fatal(err)
+		}
+		_, _ = w.Write(data)
+		_ = w.WriteByte('\n')
+	}
+	require.NoError(b, w.Flush())
+	b.StopTimer()
+	require.NoError(b, db.Close())
+}
@@ -1,7 +1,12 @@
 package maxminddb
 
 import (
+	"errors"
 	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/3JoB/go-reflect"
 )
@@ -25,10 +30,18 @@ func (e InvalidDatabaseError) Error() string {
 }
 
 // UnmarshalTypeError is returned when the value in the database cannot be
-// assigned to the specified data type.
+// assigned to the specified data type. Within a record, it is wrapped in a
+// FieldError with the path of the value; when obtained from such an error
+// with errors.As, Path is set to that path.
 type UnmarshalTypeError struct {
+	// Type is the Go type the value could not be stored in.
 	Type  reflect.Type
 	Value string
+	// DatabaseType is the type of the value in the database.
+	DatabaseType Kind
+	// Path is the path of the value within the record, e.g.,
+	// "subdivisions[0].names.en", or empty for the record itself.
+	Path string
 }
 
 func newUnmarshalTypeError(value any, rType reflect.Type) UnmarshalTypeError {
@@ -39,5 +52,265 @@ func newUnmarshalTypeError(value any, rType reflect.Type) UnmarshalTypeError {
 }
 
 func (e UnmarshalTypeError) Error() string {
+	if e.Path != "" {
+		return fmt.Sprintf(
+			"maxminddb: cannot unmarshal %s into type %s at %s",
+			e.Value,
+			e.Type.String(),
+			e.Path,
+		)
+	}
 	return fmt.Sprintf("maxminddb: cannot unmarshal %s into type %s", e.Value, e.Type.String())
 }
+
+// ErrDatabaseTooOld may be used with errors.Is to check whether an error is
+// a DatabaseTooOldError.
+var ErrDatabaseTooOld = errors.New("maxminddb: database is too old")
+
+// DatabaseTooOldError is returned by Open and FromBytes when the database
+// was built before the threshold set with WithMinimumBuildTime or
+// WithMaxAge.
+type DatabaseTooOldError struct {
+	BuildTime        time.Time
+	MinimumBuildTime time.Time
+}
+
+func (e DatabaseTooOldError) Error() string {
+	return fmt.Sprintf(
+		"maxminddb: database built at %s is older than the minimum build time of %s",
+		e.BuildTime.UTC().Format(time.RFC3339),
+		e.MinimumBuildTime.UTC().Format(time.RFC3339),
+	)
+}
+
+// Is returns true if target is ErrDatabaseTooOld.
+func (DatabaseTooOldError) Is(target error) bool {
+	return target == ErrDatabaseTooOld
+}
+
+// ErrUnsupportedFormatVersion may be used with errors.Is to check whether an
+// error is an UnsupportedFormatVersionError.
+var ErrUnsupportedFormatVersion = errors.New("maxminddb: unsupported binary format version")
+
+// UnsupportedFormatVersionError is returned by Open and FromBytes when the
+// database uses a binary format major version other than 2.
+type UnsupportedFormatVersionError struct {
+	MajorVersion uint
+	MinorVersion uint
+}
+
+func (e UnsupportedFormatVersionError) Error() string {
+	return fmt.Sprintf(
+		"maxminddb: unsupported binary format version %d.%d",
+		e.MajorVersion,
+		e.MinorVersion,
+	)
+}
+
+// Is returns true if target is ErrUnsupportedFormatVersion.
+func (UnsupportedFormatVersionError) Is(target error) bool {
+	return target == ErrUnsupportedFormatVersion
+}
+
+// ErrDatabaseTooLarge may be used with errors.Is to check whether an error is
+// a DatabaseTooLargeError.
+var ErrDatabaseTooLarge = errors.New("maxminddb: database is too large")
+
+// DatabaseTooLargeError is returned by Open, FromBytes, and FromReader when
+// the database exceeds the limit set with WithMaxDatabaseSize.
+type DatabaseTooLargeError struct {
+	// Limit is the maximum size in bytes.
+	Limit int64
+	// Size is the size of the database in bytes. It is 0 if the size is not
+	// known because FromReader stopped reading at the limit.
+	Size int64
+}
+
+func (e DatabaseTooLargeError) Error() string {
+	if e.Size == 0 {
+		return fmt.Sprintf("maxminddb: database exceeds the limit of %d bytes", e.Limit)
+	}
+	return fmt.Sprintf("maxminddb: database of %d bytes exceeds the limit of %d bytes", e.Size, e.Limit)
+}
+
+// Is returns true if target is ErrDatabaseTooLarge.
+func (DatabaseTooLargeError) Is(target error) bool {
+	return target == ErrDatabaseTooLarge
+}
+
+// ErrMissingDatabase may be used with errors.Is to check whether an error is
+// a MissingDatabaseError.
+var ErrMissingDatabase = errors.New("maxminddb: missing database")
+
+// MissingDatabaseError is returned by Manager.Enrich when a target is routed
+// to a kind of database the Manager does not have.
+type MissingDatabaseError struct {
+	// Kind is the kind of database the target is routed to.
+	Kind string
+	// Target is the type of the target, e.g., "*main.ASN".
+	Target string
+}
+
+func (e MissingDatabaseError) Error() string {
+	return fmt.Sprintf("maxminddb: no %s database to look up %s in", e.Kind, e.Target)
+}
+
+// Is returns true if target is ErrMissingDatabase.
+func (MissingDatabaseError) Is(target error) bool {
+	return target == ErrMissingDatabase
+}
+
+// DecodedSizeLimitError is returned when the output of a single decode
+// exceeds the limit set with WithMaxDecodedBytes.
+type DecodedSizeLimitError struct {
+	Limit uint
+}
+
+func (e DecodedSizeLimitError) Error() string {
+	return fmt.Sprintf("maxminddb: decoded value exceeds the limit of %d bytes", e.Limit)
+}
+
+// FieldError describes a value in a record that could not be decoded, e.g.,
+// because it does not fit the type of the struct field it is decoded into,
+// or for which a DecodeHook returned an error.
+// Path identifies the value within the record, e.g., "location.latitude" or
+// "subdivisions[0].iso_code".
+type FieldError struct {
+	Err  error
+	Path string
+}
+
+func (e FieldError) Error() string {
+	if e.Path == "" {
+		return fmt.Sprintf("maxminddb: error decoding record: %v", e.Err)
+	}
+	return fmt.Sprintf("maxminddb: error decoding %s: %v", e.Path, e.Err)
+}
+
+func (e FieldError) Unwrap() error {
+	return e.Err
+}
+
+// As sets target to the UnmarshalTypeError wrapped by e, with its Path set
+// to the path of the value, if target is a *UnmarshalTypeError.
+func (e FieldError) As(target any) bool {
+	typeErr, ok := target.(*UnmarshalTypeError)
+	if !ok || !errors.As(e.Err, typeErr) {
+		return false
+	}
+	typeErr.Path = joinFieldPath(e.Path, typeErr.Path)
+	return true
+}
+
+// FieldErrors is returned when one or more struct fields of a record could
+// not be decoded because of type differences or, when WithSoftFailDecode is
+// set, when one or more values in a record could not be decoded. The rest
+// of the record was decoded successfully.
+type FieldErrors []FieldError
+
+func (e FieldErrors) Error() string {
+	messages := make([]string, len(e))
+	for i, err := range e {
+		messages[i] = err.Error()
+	}
+	return strings.Join(messages, "\n")
+}
+
+// Unwrap returns the individual FieldError values so that errors.Is and
+// errors.As match any of them.
+func (e FieldErrors) Unwrap() []error {
+	errs := make([]error, len(e))
+	for i, err := range e {
+		errs[i] = err
+	}
+	return errs
+}
+
+// prefix adds elem to the start of the paths of the errors starting at
+// index from.
+func (e FieldErrors) prefix(from int, elem string) {
+	for i := from; i < len(e); i++ {
+		e[i].Path = joinFieldPath(elem, e[i].Path)
+	}
+}
+
+// joinFieldPath returns the path of the value at path within the value at
+// elem.
+func joinFieldPath(elem, path string) string {
+	switch {
+	case path == "":
+		return elem
+	case strings.HasPrefix(path, "["):
+		return elem + path
+	default:
+		return elem + "." + path
+	}
+}
+
+// ErrMissingRequiredKey is the error of a FieldError returned when a map
+// decoded into a struct does not have the key of a field tagged
+// ",required". The path of the FieldError is that of the missing key.
+var ErrMissingRequiredKey = errors.New("maxminddb: required key is missing")
+
+// UnknownKeysError is the error of a FieldError returned when
+// WithStrictDecode is set and a map decoded into a struct has keys that no
+// field of the struct is decoded from.
+type UnknownKeysError struct {
+	// Keys are the unknown keys in database order.
+	Keys []string
+}
+
+func (e UnknownKeysError) Error() string {
+	quoted := make([]string, len(e.Keys))
+	for i, key := range e.Keys {
+		quoted[i] = strconv.Quote(key)
+	}
+	return "maxminddb: unknown keys " + strings.Join(quoted, ", ")
+}
+
+// NetworkError describes a network whose record could not be decoded while
+// iterating with the CollectErrors option.
+type NetworkError struct {
+	Network *net.IPNet
+	Err     error
+}
+
+func (e NetworkError) Error() string {
+	return fmt.Sprintf("maxminddb: error decoding record for %s: %v", e.Network, e.Err)
+}
+
+func (e NetworkError) Unwrap() error {
+	return e.Err
+}
+
+// ErrPathNotFound may be used with errors.Is to check whether an error is a
+// PathNotFoundError.
+var ErrPathNotFound = errors.New("maxminddb: no value at path")
+
+// PathNotFoundError is returned by DecodePath when the record has no value
+// at the path.
+type PathNotFoundError struct {
+	// Path is the path passed to DecodePath.
+	Path []any
+	// Element is the index in Path of the first element without a match,
+	// e.g., a key missing from a map or an index beyond the end of an array.
+	Element int
+}
+
+func (e PathNotFoundError) Error() string {
+	return fmt.Sprintf(
+		"maxminddb: no value at path %v: no match for element %d (%v)",
+		e.Path,
+		e.Element,
+		e.Path[e.Element],
+	)
+}
+
+// Is returns true if target is ErrPathNotFound.
+func (PathNotFoundError) Is(target error) bool {
+	return target == ErrPathNotFound
+}
+
+// ErrNotFound is returned by Lookup and LookupNetworkT when the database has
+// no record for the address.
+var ErrNotFound = errors.New("maxminddb: no record for address")
@@ -0,0 +1,153 @@
+package maxminddb
+
+import (
+	"github.com/3JoB/go-reflect"
+)
+
+// fieldConversion is a conversion selected for a struct field with the
+// string or boolnum tag option, which stores values of another type in the
+// field if they convert exactly, e.g.,
+//
+//	Port  uint16 `maxminddb:"port,string"`
+//	IsTor bool   `maxminddb:"is_tor,boolnum"`
+//
+// With the string option, a string holding a number is decoded into an
+// integer or float field, a string holding "true" or "false" into a bool
+// field, and a number into a string field, formatted in decimal. With the
+// boolnum option, an integer of 0 or 1 is decoded into a bool field and a
+// bool into an integer field as 0 or 1. Values of the type of the field are
+// decoded as usual, as are values into pointers to such fields.
+type fieldConversion uint8
+
+const (
+	convertString fieldConversion = iota + 1
+	convertBoolNum
+)
+
+// parseConversionOption returns the conversion of field or 0 if its tag has
+// neither the string nor the boolnum option.
+func parseConversionOption(field reflect.StructField) fieldConversion {
+	switch {
+	case hasTagOption(field, "string"):
+		return convertString
+	case hasTagOption(field, "boolnum"):
+		return convertBoolNum
+	}
+	return 0
+}
+
+// addConversion records the conversion of field, which has the key name, if
+// it is tagged with the string or boolnum option.
+func (fields *fieldsType) addConversion(name string, field reflect.StructField) {
+	conv := parseConversionOption(field)
+	if conv == 0 {
+		return
+	}
+	if fields.conversions == nil {
+		fields.conversions = map[string]fieldConversion{}
+	}
+	fields.conversions[name] = conv
+}
+
+// decodeConverted decodes the value at offset, the value of key, into
+// result, applying conv if the value is not of the type of result. If the
+// value does not convert exactly, the error from decoding it without
+// conversion is returned.
+func (d *decoder) decodeConverted(
+	key []byte,
+	offset uint,
+	result reflect.Value,
+	conv fieldConversion,
+	depth int,
+) (uint, error) {
+	newOffset, err := d.decodeMapValue(key, offset, result, depth)
+	if _, ok := err.(UnmarshalTypeError); !ok {
+		return newOffset, err
+	}
+
+	dtype, size, valueOffset, ctrlErr := d.decodeCtrlData(offset)
+	if ctrlErr != nil {
+		return 0, ctrlErr
+	}
+	if dtype == _Pointer {
+		pointer, _, ptrErr := d.decodePointer(size, valueOffset)
+		if ptrErr != nil {
+			return 0, ptrErr
+		}
+		dtype, size, valueOffset, ctrlErr = d.decodeCtrlData(pointer)
+		if ctrlErr != nil {
+			return 0, ctrlErr
+		}
+	}
+	if dtype != _Bool && valueOffset+size > uint(len(d.buffer)) {
+		return 0, newOffsetError()
+	}
+
+	ok, convErr := d.convertValue(conv, dtype, size, valueOffset, indirect(result))
+	if convErr != nil {
+		return 0, convErr
+	}
+	if !ok {
+		return 0, err
+	}
+	return d.nextValueOffset(offset, 1)
+}
+
+// convertValue stores the value of type dtype at offset in result, which
+// is not a pointer, with conv and reports whether it converted exactly.
+func (d *decoder) convertValue(
+	conv fieldConversion,
+	dtype dataType,
+	size uint,
+	offset uint,
+	result reflect.Value,
+) (bool, error) {
+	switch conv {
+	case convertString:
+		if dtype == _String {
+			s := string(d.buffer[offset : offset+size])
+			if result.Kind() != reflect.Bool {
+				return setNumericString(result, s), nil
+			}
+			if s != "true" && s != "false" {
+				return false, nil
+			}
+			result.SetBool(s == "true")
+			return true, nil
+		}
+		if result.Kind() != reflect.String {
+			return false, nil
+		}
+		s, ok := d.formatNumber(dtype, size, offset)
+		if !ok {
+			return false, nil
+		}
+		if err := d.charge(uint(len(s))); err != nil {
+			return false, err
+		}
+		result.SetString(s)
+		return true, nil
+	case convertBoolNum:
+		if dtype == _Bool {
+			switch result.Kind() {
+			case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+				result.SetInt(int64(size))
+			case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+				result.SetUint(uint64(size))
+			default:
+				return false, nil
+			}
+			return true, nil
+		}
+		if result.Kind() != reflect.Bool || dtype == _Float32 || dtype == _Float64 {
+			return false, nil
+		}
+		s, ok := d.formatNumber(dtype, size, offset)
+		if !ok || (s != "0" && s != "1") {
+			return false, nil
+		}
+		result.SetBool(s == "1")
+		return true, nil
+	}
+	return false, nil
+}
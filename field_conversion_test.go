@@ -0,0 +1,71 @@
+package maxminddb
+
+import (
+	"encoding/hex"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// conversionTestData is
+//
+//	{"port": "8080", "is_tor": 1, "asn": 13335, "flag": true, "ratio": "1.5",
+//	 "on": "true", "exact": 80, "bad_port": "8080x", "two": 2}
+const conversionTestData = "e944706f727444383038304669735f746f72a1014361736ec2341744666c6167010745726174" +
+	"696f43312e35426f6e4474727565456578616374a150486261645f706f72744538303830784374776fa102"
+
+func TestFieldConversions(t *testing.T) {
+	buffer, err := hex.DecodeString(conversionTestData)
+	require.NoError(t, err)
+	d := decoder{buffer: buffer}
+
+	var result struct {
+		Port  *uint16 `maxminddb:"port,string"`
+		IsTor bool    `maxminddb:"is_tor,boolnum"`
+		ASN   string  `maxminddb:"asn,string"`
+		Flag  *int    `maxminddb:"flag,boolnum"`
+		Ratio float64 `maxminddb:"ratio,string"`
+		On    bool    `maxminddb:"on,string"`
+		Exact uint16  `maxminddb:"exact,string"`
+	}
+	require.NoError(t, decodeRecord(&d, &result))
+	require.NotNil(t, result.Port)
+	assert.Equal(t, uint16(8080), *result.Port)
+	assert.True(t, result.IsTor)
+	assert.Equal(t, "13335", result.ASN)
+	require.NotNil(t, result.Flag)
+	assert.Equal(t, 1, *result.Flag)
+	assert.InDelta(t, 1.5, result.Ratio, 0)
+	assert.True(t, result.On)
+	assert.Equal(t, uint16(80), result.Exact)
+}
+
+func TestFieldConversionsExact(t *testing.T) {
+	buffer, err := hex.DecodeString(conversionTestData)
+	require.NoError(t, err)
+	d := decoder{buffer: buffer}
+
+	var result struct {
+		BadPort *uint16 `maxminddb:"bad_port,string"`
+		Two     bool    `maxminddb:"two,boolnum"`
+		Port    uint8   `maxminddb:"port,string"`
+		Ratio   int     `maxminddb:"ratio,string"`
+		IsTor   bool    `maxminddb:"is_tor,string"`
+		ASN     string  `maxminddb:"asn"`
+	}
+	err = decodeRecord(&d, &result)
+	var fieldErrs FieldErrors
+	require.ErrorAs(t, err, &fieldErrs)
+	var paths []string
+	for _, fieldErr := range fieldErrs {
+		var typeErr UnmarshalTypeError
+		require.ErrorAs(t, fieldErr, &typeErr)
+		paths = append(paths, typeErr.Path)
+	}
+	// "8080x" is not a number, 2 is not a bool, 8080 overflows uint8, "1.5"
+	// is not an integer, and the string option does not convert integers to
+	// bools. Fields without an option are not converted.
+	assert.ElementsMatch(t, []string{"bad_port", "two", "port", "ratio", "is_tor", "asn"}, paths)
+	assert.Nil(t, result.BadPort)
+}
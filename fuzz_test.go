@@ -0,0 +1,86 @@
+package maxminddb
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// The maximum number of networks visited when iterating over a fuzzed
+// database. A corrupt search tree may describe an enormous number of
+// networks.
+const fuzzMaxNetworks = 1000
+
+func addTestDatabases(f *testing.F) {
+	files, err := filepath.Glob(testFile("*.mmdb"))
+	if err != nil {
+		f.Fatal(err)
+	}
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			f.Fatal(err)
+		}
+		f.Add(data)
+	}
+}
+
+func FuzzOpen(f *testing.F) {
+	addTestDatabases(f)
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		reader, err := FromBytes(data)
+		if err != nil {
+			return
+		}
+		if err := reader.Close(); err != nil {
+			t.Fatal(err)
+		}
+	})
+}
+
+func FuzzLookupDecode(f *testing.F) {
+	addTestDatabases(f)
+
+	ips := []net.IP{
+		net.ParseIP("1.1.1.1"),
+		net.ParseIP("81.2.69.142"),
+		net.ParseIP("::1:ffff:ffff"),
+		net.ParseIP("2001:218::"),
+		net.ParseIP("ffff:ffff:ffff:ffff:ffff:ffff:ffff:ffff"),
+	}
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		reader, err := FromBytes(data)
+		if err != nil {
+			return
+		}
+
+		for _, ip := range ips {
+			var record any
+			_, _, _ = reader.LookupNetwork(ip, &record)
+
+			var result TestType
+			_ = reader.Lookup(ip, &result)
+
+			offset, err := reader.LookupOffset(ip)
+			if err == nil && offset != NotFound {
+				var record any
+				_ = reader.Decode(offset, &record)
+			}
+		}
+
+		n := reader.Networks()
+		for i := 0; i < fuzzMaxNetworks && n.Next(); i++ {
+			var record any
+			_, _ = n.Network(&record)
+		}
+
+		_ = reader.Verify()
+
+		if err := reader.Close(); err != nil {
+			t.Fatal(err)
+		}
+	})
+}
@@ -0,0 +1,114 @@
+package maxminddb
+
+import (
+	"net/netip"
+)
+
+// Gaps is an iterator over the parts of a network that have no record in
+// the database. It is returned by GapsWithin.
+type Gaps struct {
+	err      error
+	networks *Networks
+	pending  []netip.Prefix
+	current  netip.Prefix
+
+	// next is the first address not yet known to be covered or not, and
+	// last the last address of the network. Both are IPv6 addresses, with
+	// IPv4 addresses in the IPv4 subtree, ::/96. next is invalid once the
+	// whole network has been visited.
+	next netip.Addr
+	last netip.Addr
+	ipv4 bool
+}
+
+// GapsWithin returns an iterator over the largest networks within prefix
+// that have no record in the database, in order. Together with the networks
+// returned by NetworksWithinPrefix for prefix, they cover prefix without
+// overlapping. If prefix is within a network of the database, there are no
+// gaps.
+//
+// Prefixes are interpreted as they are by NetworksWithinPrefix, except that
+// the gaps within an IPv4 prefix in an IPv6 database are those of the IPv4
+// subtree, ::/96, rather than those of an alias of it. Aliases of the IPv4
+// subtree within an IPv6 prefix are not gaps.
+func (r *Reader) GapsWithin(prefix netip.Prefix) *Gaps {
+	if !prefix.IsValid() {
+		return &Gaps{err: r.NetworksWithinPrefix(prefix).Err()}
+	}
+	prefix = prefix.Masked()
+	g := &Gaps{ipv4: prefix.Addr().Is4()}
+	if g.ipv4 && r.Metadata.IPVersion == 6 {
+		g.networks = r.NetworksWithinPrefix(ipv4SubtreePrefix(prefix))
+	} else {
+		g.networks = r.NetworksWithinPrefix(prefix)
+	}
+	if g.ipv4 {
+		prefix = ipv4SubtreePrefix(prefix)
+	}
+	g.next = prefix.Addr()
+	g.last = lastAddr(prefix)
+	return g
+}
+
+// Next prepares the next gap for reading with the Prefix method. It returns
+// false if there are no more gaps or if there is an error.
+func (g *Gaps) Next() bool {
+	for len(g.pending) == 0 {
+		if g.err != nil || !g.next.IsValid() {
+			return false
+		}
+		if !g.networks.Next() {
+			if g.err = g.networks.Err(); g.err != nil {
+				return false
+			}
+			g.pending = rangeToPrefixes(g.next, g.last)
+			g.next = netip.Addr{}
+			continue
+		}
+
+		node := g.networks.lastNode
+		addr, _ := netip.AddrFromSlice(node.ip)
+		network := netip.PrefixFrom(addr, int(node.bit))
+		if addr.Is4() {
+			network = ipv4SubtreePrefix(network)
+		}
+		// The network may start before the gap, e.g., if it contains the
+		// whole prefix.
+		if g.next.Less(network.Addr()) {
+			g.pending = rangeToPrefixes(g.next, network.Addr().Prev())
+		}
+		end := lastAddr(network)
+		if !end.Less(g.last) {
+			g.next = netip.Addr{}
+		} else if g.next.Less(end.Next()) {
+			g.next = end.Next()
+		}
+	}
+
+	g.current = g.pending[0]
+	g.pending = g.pending[1:]
+	if g.ipv4 {
+		addr := g.current.Addr().As16()
+		g.current = netip.PrefixFrom(netip.AddrFrom4([4]byte(addr[12:])), g.current.Bits()-96)
+	}
+	return true
+}
+
+// Prefix returns the current gap.
+func (g *Gaps) Prefix() netip.Prefix {
+	return g.current
+}
+
+// Err returns an error, if any, that was encountered during iteration.
+func (g *Gaps) Err() error {
+	return g.err
+}
+
+// ipv4SubtreePrefix returns the prefix of the IPv4 subtree, ::/96, that
+// corresponds to the IPv4 prefix prefix.
+func ipv4SubtreePrefix(prefix netip.Prefix) netip.Prefix {
+	var addr [16]byte
+	ipv4 := prefix.Addr().As4()
+	copy(addr[12:], ipv4[:])
+	return netip.PrefixFrom(netip.AddrFrom16(addr), prefix.Bits()+96)
+}
@@ -0,0 +1,106 @@
+package maxminddb
+
+import (
+	"net/netip"
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGapsWithin(t *testing.T) {
+	for _, file := range []string{"MaxMind-DB-test-ipv4-24.mmdb", "MaxMind-DB-test-mixed-24.mmdb"} {
+		reader, err := Open(testFile(file))
+		require.NoError(t, err)
+
+		gaps := collectGaps(t, reader, netip.MustParsePrefix("1.1.1.0/24"))
+		assert.Equal(t, []string{
+			"1.1.1.0/32",
+			"1.1.1.33/32",
+			"1.1.1.34/31",
+			"1.1.1.36/30",
+			"1.1.1.40/29",
+			"1.1.1.48/28",
+			"1.1.1.64/26",
+			"1.1.1.128/25",
+		}, gaps, file)
+
+		for _, prefix := range []string{"0.0.0.0/0", "1.1.1.0/24", "1.1.1.0/27", "1.1.1.32/31"} {
+			assertTiled(t, reader, netip.MustParsePrefix(prefix))
+		}
+
+		// A prefix within a network of the database has no gaps.
+		assert.Empty(t, collectGaps(t, reader, netip.MustParsePrefix("1.1.1.20/30")))
+
+		require.NoError(t, reader.Close())
+	}
+}
+
+func TestGapsWithinIPv6(t *testing.T) {
+	reader, err := Open(testFile("MaxMind-DB-test-mixed-24.mmdb"))
+	require.NoError(t, err)
+
+	gaps := collectGaps(t, reader, netip.MustParsePrefix("::2:0:0/120"))
+	assert.Equal(t, []string{"::2:0:5a/127", "::2:0:5c/126", "::2:0:60/123", "::2:0:80/121"}, gaps)
+
+	for _, prefix := range []string{"::/0", "::/64", "::ffff:0:0/96", "2002::/16"} {
+		assertTiled(t, reader, netip.MustParsePrefix(prefix))
+	}
+
+	g := reader.GapsWithin(netip.Prefix{})
+	assert.False(t, g.Next())
+	assert.EqualError(t, g.Err(), "error getting networks with 'invalid Prefix': invalid prefix")
+
+	require.NoError(t, reader.Close())
+
+	g = reader.GapsWithin(netip.MustParsePrefix("::/0"))
+	assert.False(t, g.Next())
+	assert.EqualError(t, g.Err(), "cannot call Networks on a closed database")
+}
+
+func collectGaps(t *testing.T, reader *Reader, prefix netip.Prefix) []string {
+	t.Helper()
+	var gaps []string
+	g := reader.GapsWithin(prefix)
+	for g.Next() {
+		gaps = append(gaps, g.Prefix().String())
+	}
+	require.NoError(t, g.Err())
+	return gaps
+}
+
+// assertTiled checks that the gaps and the networks within prefix together
+// cover prefix without overlapping.
+func assertTiled(t *testing.T, reader *Reader, prefix netip.Prefix) {
+	t.Helper()
+	var tiles []netip.Prefix
+	g := reader.GapsWithin(prefix)
+	for g.Next() {
+		tiles = append(tiles, g.Prefix())
+	}
+	require.NoError(t, g.Err())
+
+	n := reader.NetworksWithinPrefix(prefix)
+	for n.Next() {
+		var record any
+		network, err := n.Network(&record)
+		require.NoError(t, err)
+		addr, _ := netip.AddrFromSlice(network.IP)
+		bits, _ := network.Mask.Size()
+		if prefix.Addr().Is4() {
+			bits -= addr.BitLen() - 32
+			addr = addr.Unmap()
+		}
+		tiles = append(tiles, netip.PrefixFrom(addr, bits))
+	}
+	require.NoError(t, n.Err())
+
+	sort.Slice(tiles, func(i, j int) bool { return tiles[i].Addr().Less(tiles[j].Addr()) })
+	next := prefix.Addr()
+	for _, tile := range tiles {
+		require.Equal(t, next, tile.Addr(), "%s in %s", tile, prefix)
+		next = lastAddr(tile).Next()
+	}
+	assert.Equal(t, lastAddr(prefix), lastAddr(tiles[len(tiles)-1]), prefix)
+}
@@ -0,0 +1,36 @@
+package maxminddb
+
+import "net/netip"
+
+// Lookup returns the record for ip in r decoded into a value of type T, as
+// Reader.LookupNetip does for a pointer to T. If r has no record for ip,
+// Lookup returns the zero T and ErrNotFound. On any other error, it returns
+// the zero T.
+//
+// Unlike with Reader.LookupNetip, T cannot be misused as a non-pointer
+// result, e.g.,
+//
+//	city, err := maxminddb.Lookup[City](reader, ip)
+//	if errors.Is(err, maxminddb.ErrNotFound) {
+//		// ...
+//	}
+func Lookup[T any](r *Reader, ip netip.Addr) (T, error) {
+	result, _, err := LookupNetworkT[T](r, ip)
+	return result, err
+}
+
+// LookupNetworkT is Lookup, additionally returning the network of the
+// record as Reader.LookupNetworkPrefix does. The network is returned with
+// ErrNotFound too, as the network without a record.
+func LookupNetworkT[T any](r *Reader, ip netip.Addr) (T, netip.Prefix, error) {
+	var result T
+	prefix, ok, err := r.LookupNetworkPrefix(ip, &result)
+	if err != nil {
+		var zero T
+		return zero, prefix, err
+	}
+	if !ok {
+		return result, prefix, ErrNotFound
+	}
+	return result, prefix, nil
+}
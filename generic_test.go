@@ -0,0 +1,101 @@
+package maxminddb
+
+import (
+	"net"
+	"net/netip"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type genericCity struct {
+	City struct {
+		Names map[string]string `maxminddb:"names"`
+	} `maxminddb:"city"`
+	Country struct {
+		IsoCode string `maxminddb:"iso_code"`
+	} `maxminddb:"country"`
+}
+
+func TestLookupGeneric(t *testing.T) {
+	reader, err := Open(testFile("GeoIP2-City-Test.mmdb"))
+	require.NoError(t, err)
+	defer reader.Close()
+	ip := "81.2.69.160"
+
+	var expectedCity genericCity
+	require.NoError(t, reader.Lookup(net.ParseIP(ip), &expectedCity))
+	city, err := Lookup[genericCity](reader, netip.MustParseAddr(ip))
+	require.NoError(t, err)
+	assert.Equal(t, expectedCity, city)
+	assert.Equal(t, "GB", city.Country.IsoCode)
+
+	cityPtr, err := Lookup[*genericCity](reader, netip.MustParseAddr(ip))
+	require.NoError(t, err)
+	require.NotNil(t, cityPtr)
+	assert.Equal(t, expectedCity, *cityPtr)
+
+	var expectedMap map[string]any
+	require.NoError(t, reader.Lookup(net.ParseIP(ip), &expectedMap))
+	m, err := Lookup[map[string]any](reader, netip.MustParseAddr(ip))
+	require.NoError(t, err)
+	assert.Equal(t, expectedMap, m)
+
+	var expectedAny any
+	require.NoError(t, reader.Lookup(net.ParseIP(ip), &expectedAny))
+	record, err := Lookup[any](reader, netip.MustParseAddr(ip))
+	require.NoError(t, err)
+	assert.Equal(t, expectedAny, record)
+}
+
+func TestLookupGenericScalar(t *testing.T) {
+	reader, err := Open(testFile("MaxMind-DB-test-ipv4-24.mmdb"))
+	require.NoError(t, err)
+	defer reader.Close()
+
+	m, err := Lookup[map[string]string](reader, netip.MustParseAddr("1.1.1.1"))
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"ip": "1.1.1.1"}, m)
+
+	// The records are maps, so a scalar result cannot hold them. The zero
+	// value is returned with the error.
+	n, err := Lookup[uint32](reader, netip.MustParseAddr("1.1.1.1"))
+	var typeErr UnmarshalTypeError
+	assert.ErrorAs(t, err, &typeErr)
+	assert.NotErrorIs(t, err, ErrNotFound)
+	assert.Zero(t, n)
+
+	s, err := Lookup[string](reader, netip.MustParseAddr("1.1.1.1"))
+	assert.ErrorAs(t, err, &typeErr)
+	assert.Empty(t, s)
+}
+
+func TestLookupGenericNotFound(t *testing.T) {
+	reader, err := Open(testFile("MaxMind-DB-test-ipv4-24.mmdb"))
+	require.NoError(t, err)
+
+	m, err := Lookup[map[string]string](reader, netip.MustParseAddr("10.0.0.1"))
+	assert.ErrorIs(t, err, ErrNotFound)
+	assert.Nil(t, m)
+
+	record, prefix, err := LookupNetworkT[any](reader, netip.MustParseAddr("10.0.0.1"))
+	assert.ErrorIs(t, err, ErrNotFound)
+	assert.Nil(t, record)
+	expectedNetwork, _, _ := reader.LookupNetwork(net.ParseIP("10.0.0.1"), &record)
+	assert.Equal(t, expectedNetwork.String(), prefix.String())
+
+	record, prefix, err = LookupNetworkT[any](reader, netip.MustParseAddr("1.1.1.3"))
+	require.NoError(t, err)
+	assert.Equal(t, map[string]any{"ip": "1.1.1.2"}, record)
+	assert.Equal(t, netip.MustParsePrefix("1.1.1.2/31"), prefix)
+
+	_, err = Lookup[any](reader, netip.MustParseAddr("2001:db8::1"))
+	assert.Error(t, err)
+	assert.NotErrorIs(t, err, ErrNotFound)
+
+	require.NoError(t, reader.Close())
+	_, err = Lookup[any](reader, netip.MustParseAddr("1.1.1.1"))
+	assert.Error(t, err)
+	assert.NotErrorIs(t, err, ErrNotFound)
+}
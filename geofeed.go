@@ -0,0 +1,279 @@
+package maxminddb
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"net/netip"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// GeofeedMapping describes how ExportGeofeed derives the columns of an RFC
+// 8805 geofeed from the records in a database.
+type GeofeedMapping struct {
+	// Country is the ISO 3166-1 alpha-2 country code, e.g.,
+	// "country.iso_code".
+	Country GeofeedField
+	// Region is the ISO 3166-2 subdivision code, e.g.,
+	// "subdivisions.0.iso_code". Codes without a country prefix, such as
+	// "AB", are prefixed with the country code, e.g., "SE-AB".
+	Region GeofeedField
+	// City is the city name, e.g., "city.names.en".
+	City GeofeedField
+	// PostalCode is the postal code, e.g., "postal.code". RFC 8805
+	// deprecates this column; leave it unset to keep it empty.
+	PostalCode GeofeedField
+	// Strict makes ExportGeofeed return an error for country and region
+	// codes that are not valid. Otherwise, codes are converted to upper case
+	// and those that are still not valid are left out of the row.
+	Strict bool
+}
+
+// GeofeedField locates a value in a record. Path is a dotted path of map
+// keys and array indexes, e.g., "subdivisions.0.iso_code". If the record
+// has no value at Path, or the value is a map or an array, Default is used.
+// An empty Path always uses Default.
+type GeofeedField struct {
+	Path    string
+	Default string
+}
+
+// ExportGeofeed writes an RFC 8805 geofeed with a row for each network in r
+// to w. The columns are derived from the records using fieldMap. Adjacent
+// networks with identical rows are merged and written as the smallest set
+// of prefixes covering them. The feed starts with comment lines describing
+// its source.
+func ExportGeofeed(w io.Writer, r *Reader, fieldMap GeofeedMapping) error {
+	_, err := fmt.Fprintf(
+		w,
+		"# RFC 8805 geofeed generated from %s built %s\n"+
+			"# prefix,country,region,city,postal_code\n",
+		r.Metadata.DatabaseType,
+		time.Unix(int64(r.Metadata.BuildEpoch), 0).UTC().Format(time.RFC3339),
+	)
+	if err != nil {
+		return err
+	}
+
+	e := &geofeedExporter{csv: csv.NewWriter(w), mapping: fieldMap}
+	n := r.Networks(SkipAliasedNetworks)
+	for n.Next() {
+		var record any
+		network, err := n.Network(&record)
+		if err != nil {
+			return err
+		}
+		addr, ok := netip.AddrFromSlice(network.IP)
+		if !ok {
+			return fmt.Errorf("invalid network: %v", network)
+		}
+		prefixLength, _ := network.Mask.Size()
+		prefix := netip.PrefixFrom(addr, prefixLength)
+
+		row, err := e.row(prefix, record)
+		if err != nil {
+			return err
+		}
+		if err := e.add(prefix, row); err != nil {
+			return err
+		}
+	}
+	if err := n.Err(); err != nil {
+		return err
+	}
+	if err := e.flush(); err != nil {
+		return err
+	}
+	e.csv.Flush()
+	return e.csv.Error()
+}
+
+type geofeedRow struct {
+	country    string
+	region     string
+	city       string
+	postalCode string
+}
+
+type geofeedExporter struct {
+	csv     *csv.Writer
+	mapping GeofeedMapping
+
+	// The pending range of addresses with identical rows.
+	first      netip.Addr
+	last       netip.Addr
+	pendingRow geofeedRow
+	pending    bool
+}
+
+func (e *geofeedExporter) row(prefix netip.Prefix, record any) (geofeedRow, error) {
+	row := geofeedRow{
+		country:    e.mapping.Country.value(record),
+		region:     e.mapping.Region.value(record),
+		city:       e.mapping.City.value(record),
+		postalCode: e.mapping.PostalCode.value(record),
+	}
+
+	if row.country != "" {
+		country := strings.ToUpper(row.country)
+		if !isISO3166Alpha2(country) || (e.mapping.Strict && country != row.country) {
+			if e.mapping.Strict {
+				return row, fmt.Errorf("invalid country code %q for %s", row.country, prefix)
+			}
+			country = ""
+		}
+		row.country = country
+	}
+
+	if row.region != "" {
+		region := strings.ToUpper(row.region)
+		if !strings.Contains(region, "-") && row.country != "" {
+			region = row.country + "-" + region
+		}
+		valid := isISO3166Subdivision(region) &&
+			row.country != "" && strings.HasPrefix(region, row.country+"-")
+		if !valid || (e.mapping.Strict && strings.ToUpper(row.region) != row.region) {
+			if e.mapping.Strict {
+				return row, fmt.Errorf("invalid region code %q for %s", row.region, prefix)
+			}
+			region = ""
+		}
+		row.region = region
+	}
+	return row, nil
+}
+
+// add adds the network prefix with row to the pending range if it is
+// adjacent and has the same row, and otherwise writes the pending range and
+// starts a new one.
+func (e *geofeedExporter) add(prefix netip.Prefix, row geofeedRow) error {
+	first := prefix.Masked().Addr()
+	if e.pending && row == e.pendingRow && e.last.Next() == first {
+		e.last = lastAddr(prefix)
+		return nil
+	}
+	if err := e.flush(); err != nil {
+		return err
+	}
+	e.first = first
+	e.last = lastAddr(prefix)
+	e.pendingRow = row
+	e.pending = true
+	return nil
+}
+
+func (e *geofeedExporter) flush() error {
+	if !e.pending {
+		return nil
+	}
+	e.pending = false
+	for _, prefix := range rangeToPrefixes(e.first, e.last) {
+		err := e.csv.Write([]string{
+			prefix.String(),
+			e.pendingRow.country,
+			e.pendingRow.region,
+			e.pendingRow.city,
+			e.pendingRow.postalCode,
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (f GeofeedField) value(record any) string {
+	if f.Path == "" {
+		return f.Default
+	}
+	value := record
+	for _, key := range strings.Split(f.Path, ".") {
+		switch v := value.(type) {
+		case map[string]any:
+			value = v[key]
+		case []any:
+			i, err := strconv.Atoi(key)
+			if err != nil || i < 0 || i >= len(v) {
+				return f.Default
+			}
+			value = v[i]
+		default:
+			return f.Default
+		}
+	}
+	switch v := value.(type) {
+	case nil, map[string]any, []any:
+		return f.Default
+	case string:
+		return v
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+func isISO3166Alpha2(code string) bool {
+	return len(code) == 2 && isUpperAlpha(code[0]) && isUpperAlpha(code[1])
+}
+
+// isISO3166Subdivision returns true if code has the form of an ISO 3166-2
+// subdivision code, i.e., a country code, a hyphen, and one to three
+// letters or digits.
+func isISO3166Subdivision(code string) bool {
+	country, subdivision, ok := strings.Cut(code, "-")
+	if !ok || !isISO3166Alpha2(country) || len(subdivision) < 1 || len(subdivision) > 3 {
+		return false
+	}
+	for i := 0; i < len(subdivision); i++ {
+		c := subdivision[i]
+		if !isUpperAlpha(c) && (c < '0' || c > '9') {
+			return false
+		}
+	}
+	return true
+}
+
+func isUpperAlpha(c byte) bool {
+	return c >= 'A' && c <= 'Z'
+}
+
+// lastAddr returns the last address in prefix.
+func lastAddr(prefix netip.Prefix) netip.Addr {
+	a := prefix.Masked().Addr().As16()
+	bits := prefix.Bits()
+	if prefix.Addr().Is4() {
+		bits += 96
+	}
+	for i := bits; i < 128; i++ {
+		a[i/8] |= 1 << (7 - i%8)
+	}
+	last := netip.AddrFrom16(a)
+	if prefix.Addr().Is4() {
+		return last.Unmap()
+	}
+	return last
+}
+
+// rangeToPrefixes returns the smallest set of prefixes that exactly covers
+// the addresses from first to last, inclusive, in order. first and last must
+// be of the same address family.
+func rangeToPrefixes(first, last netip.Addr) []netip.Prefix {
+	var prefixes []netip.Prefix
+	for first.IsValid() && first.Compare(last) <= 0 {
+		// Find the largest prefix starting at first that does not extend
+		// beyond last.
+		bits := first.BitLen()
+		for bits > 0 {
+			prefix := netip.PrefixFrom(first, bits-1)
+			if prefix.Masked().Addr() != first || lastAddr(prefix).Compare(last) > 0 {
+				break
+			}
+			bits--
+		}
+		prefix := netip.PrefixFrom(first, bits)
+		prefixes = append(prefixes, prefix)
+		first = lastAddr(prefix).Next()
+	}
+	return prefixes
+}
@@ -0,0 +1,197 @@
+package maxminddb
+
+import (
+	"bytes"
+	"encoding/csv"
+	"net"
+	"net/netip"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var cityGeofeedMapping = GeofeedMapping{
+	Country: GeofeedField{Path: "country.iso_code"},
+	Region:  GeofeedField{Path: "subdivisions.0.iso_code"},
+	City:    GeofeedField{Path: "city.names.en"},
+}
+
+func TestExportGeofeed(t *testing.T) {
+	reader, err := Open(testFile("GeoIP2-City-Test.mmdb"))
+	require.NoError(t, err)
+
+	var buf bytes.Buffer
+	require.NoError(t, ExportGeofeed(&buf, reader, cityGeofeedMapping))
+
+	lines := strings.SplitN(buf.String(), "\n", 3)
+	require.Len(t, lines, 3)
+	assert.True(t, strings.HasPrefix(lines[0], "# RFC 8805 geofeed generated from GeoIP2-City "))
+	assert.Equal(t, "# prefix,country,region,city,postal_code", lines[1])
+
+	rows, err := csv.NewReader(strings.NewReader(lines[2])).ReadAll()
+	require.NoError(t, err)
+	require.NotEmpty(t, rows)
+
+	var found bool
+	for _, row := range rows {
+		require.Len(t, row, 5)
+		prefix, err := netip.ParsePrefix(row[0])
+		require.NoError(t, err)
+
+		var record any
+		_, ok, err := reader.LookupNetwork(net.IP(prefix.Addr().AsSlice()), &record)
+		require.NoError(t, err)
+		require.True(t, ok)
+		assert.Equal(t, cityGeofeedMapping.Country.value(record), row[1], "country for %s", prefix)
+		assert.Equal(t, cityGeofeedMapping.City.value(record), row[3], "city for %s", prefix)
+		if region := cityGeofeedMapping.Region.value(record); region != "" && row[1] != "" {
+			assert.Equal(t, row[1]+"-"+region, row[2], "region for %s", prefix)
+		}
+
+		if prefix.Contains(netip.MustParseAddr("81.2.69.160")) {
+			found = true
+			assert.Equal(t, []string{"GB", "GB-ENG", "London", ""}, row[1:])
+		}
+	}
+	assert.True(t, found, "no row for 81.2.69.160")
+
+	assert.NoError(t, reader.Close())
+}
+
+func TestExportGeofeedValidation(t *testing.T) {
+	reader, err := Open(testFile("GeoIP2-City-Test.mmdb"))
+	require.NoError(t, err)
+
+	mapping := GeofeedMapping{
+		Country: GeofeedField{Path: "city.names.en"},
+		City:    GeofeedField{Path: "city.names.en"},
+		Strict:  true,
+	}
+	var buf bytes.Buffer
+	assert.ErrorContains(t, ExportGeofeed(&buf, reader, mapping), "invalid country code")
+
+	mapping.Strict = false
+	buf.Reset()
+	require.NoError(t, ExportGeofeed(&buf, reader, mapping))
+	assert.Contains(t, buf.String(), ",,,London,\n")
+
+	assert.NoError(t, reader.Close())
+}
+
+func TestGeofeedRow(t *testing.T) {
+	tests := []struct {
+		country  string
+		region   string
+		expected geofeedRow
+		strict   bool
+		err      bool
+	}{
+		{country: "SE", region: "AB", expected: geofeedRow{country: "SE", region: "SE-AB"}},
+		{country: "SE", region: "SE-AB", expected: geofeedRow{country: "SE", region: "SE-AB"}},
+		{country: "se", region: "ab", expected: geofeedRow{country: "SE", region: "SE-AB"}},
+		{country: "se", region: "AB", strict: true, err: true},
+		{country: "SE", region: "NO-03", expected: geofeedRow{country: "SE"}},
+		{country: "SE", region: "NO-03", strict: true, err: true},
+		{country: "SE", region: "ABCD", expected: geofeedRow{country: "SE"}},
+		{country: "SWE", region: "AB", expected: geofeedRow{}},
+		{country: "SWE", strict: true, err: true},
+		{region: "SE-AB", expected: geofeedRow{}},
+	}
+
+	for _, test := range tests {
+		e := &geofeedExporter{mapping: GeofeedMapping{
+			Country: GeofeedField{Default: test.country},
+			Region:  GeofeedField{Default: test.region},
+			Strict:  test.strict,
+		}}
+		row, err := e.row(netip.MustParsePrefix("1.1.1.0/24"), nil)
+		if test.err {
+			assert.Error(t, err, "%s %s", test.country, test.region)
+			continue
+		}
+		require.NoError(t, err)
+		assert.Equal(t, test.expected, row, "%s %s", test.country, test.region)
+	}
+}
+
+func TestGeofeedAggregation(t *testing.T) {
+	var buf bytes.Buffer
+	e := &geofeedExporter{csv: csv.NewWriter(&buf)}
+	gb := geofeedRow{country: "GB"}
+	fr := geofeedRow{country: "FR"}
+	for _, network := range []struct {
+		prefix string
+		row    geofeedRow
+	}{
+		{"1.1.1.0/25", gb},
+		{"1.1.1.128/25", gb},
+		{"1.1.2.0/24", gb},
+		{"1.1.4.0/24", gb},
+		{"1.1.5.0/24", fr},
+		{"2001:db8::/33", fr},
+		{"2001:db8:8000::/33", fr},
+	} {
+		require.NoError(t, e.add(netip.MustParsePrefix(network.prefix), network.row))
+	}
+	require.NoError(t, e.flush())
+	e.csv.Flush()
+
+	assert.Equal(
+		t,
+		"1.1.1.0/24,GB,,,\n"+
+			"1.1.2.0/24,GB,,,\n"+
+			"1.1.4.0/24,GB,,,\n"+
+			"1.1.5.0/24,FR,,,\n"+
+			"2001:db8::/32,FR,,,\n",
+		buf.String(),
+	)
+}
+
+func TestRangeToPrefixes(t *testing.T) {
+	tests := []struct {
+		first    string
+		last     string
+		expected []string
+	}{
+		{
+			first: "1.1.1.1",
+			last:  "1.1.1.32",
+			expected: []string{
+				"1.1.1.1/32",
+				"1.1.1.2/31",
+				"1.1.1.4/30",
+				"1.1.1.8/29",
+				"1.1.1.16/28",
+				"1.1.1.32/32",
+			},
+		},
+		{
+			first:    "0.0.0.0",
+			last:     "255.255.255.255",
+			expected: []string{"0.0.0.0/0"},
+		},
+		{
+			first:    "::",
+			last:     "ffff:ffff:ffff:ffff:ffff:ffff:ffff:ffff",
+			expected: []string{"::/0"},
+		},
+		{
+			first:    "2001:db8::ffff",
+			last:     "2001:db8::1:0",
+			expected: []string{"2001:db8::ffff/128", "2001:db8::1:0/128"},
+		},
+	}
+
+	for _, test := range tests {
+		var prefixes []string
+		for _, prefix := range rangeToPrefixes(
+			netip.MustParseAddr(test.first),
+			netip.MustParseAddr(test.last),
+		) {
+			prefixes = append(prefixes, prefix.String())
+		}
+		assert.Equal(t, test.expected, prefixes)
+	}
+}
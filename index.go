@@ -0,0 +1,287 @@
+package maxminddb
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net/netip"
+	"runtime"
+)
+
+// indexLeaf is set in the references of an Index that refer to a value
+// rather than to a node. The remaining bits hold the value ID, where 0
+// stands for no value.
+const indexLeaf = 1 << 31
+
+const indexFormat = "MMDBIDX1"
+
+// Index maps the networks of a database to the value at a single path in
+// their records. It is built by Reader.BuildIndex and answers lookups from a
+// copy of the search tree without touching the database. An Index is
+// immutable and safe for concurrent use. It does not refer to the Reader it
+// was built from, which may be closed.
+type Index struct {
+	// nodes holds the left and right reference of each node. Nodes are
+	// stored after their children, so a node only refers to nodes with a
+	// lower number.
+	nodes    []uint32
+	values   []string
+	root     uint32
+	ipv4Root uint32
+
+	ipVersion uint
+}
+
+// BuildIndex walks the search tree once and builds an Index of the value at
+// path in the record of each network. The elements of path are interpreted
+// as described by DistinctValues, and values that are not strings are
+// formatted as JSON in the same way. Each distinct value is stored once.
+// Subtrees whose networks all have the same value are collapsed into a
+// single entry, so the Index is usually much smaller than the search tree.
+//
+// Networks whose record has no value at path are not found in the Index.
+// The WithSkipReserved and WithNegativeCache options do not apply.
+func (r *Reader) BuildIndex(path ...any) (*Index, error) {
+	if r.buffer == nil {
+		return nil, errors.New("cannot call BuildIndex on a closed database")
+	}
+	if err := checkPath(path); err != nil {
+		return nil, err
+	}
+
+	b := indexBuilder{
+		reader:     r,
+		path:       path,
+		seenNodes:  make([]uint32, r.Metadata.NodeCount),
+		recordRefs: map[uintptr]uint32{},
+		valueIDs:   map[string]uint32{},
+		values:     []string{""},
+	}
+	bitCount := uint(128)
+	if r.Metadata.IPVersion == 4 {
+		bitCount = 32
+	}
+	root, err := b.build(0, 0, 0, bitCount)
+	if err != nil {
+		return nil, err
+	}
+	runtime.KeepAlive(r)
+
+	index := &Index{
+		nodes:     b.nodes,
+		values:    b.values,
+		root:      root,
+		ipv4Root:  root,
+		ipVersion: r.Metadata.IPVersion,
+	}
+	if index.ipVersion == 6 {
+		for i := 0; i < 96 && index.ipv4Root&indexLeaf == 0; i++ {
+			index.ipv4Root = index.nodes[2*index.ipv4Root]
+		}
+	}
+	return index, nil
+}
+
+type indexBuilder struct {
+	reader *Reader
+	path   []any
+
+	nodes []uint32
+	// seenNodes holds one more than the reference for each node of the
+	// search tree that has been visited, so that subtrees reachable through
+	// several paths, such as the IPv4 subtree, are only built once.
+	seenNodes  []uint32
+	recordRefs map[uintptr]uint32
+	valueIDs   map[string]uint32
+	values     []string
+}
+
+// build returns the reference for the search tree node at depth depth,
+// which is held by parent.
+func (b *indexBuilder) build(node, parent, depth, bitCount uint) (uint32, error) {
+	nodeCount := b.reader.Metadata.NodeCount
+	switch {
+	case node == nodeCount:
+		return indexLeaf, nil
+	case node > nodeCount:
+		return b.record(parent, node)
+	case depth >= bitCount:
+		return 0, newInvalidDatabaseError("the MaxMind DB file's search tree is corrupt")
+	case b.seenNodes[node] != 0:
+		return b.seenNodes[node] - 1, nil
+	}
+
+	offset := node * b.reader.nodeOffsetMult
+	left, err := b.build(b.reader.nodeReader.readLeft(offset), node, depth+1, bitCount)
+	if err != nil {
+		return 0, err
+	}
+	right, err := b.build(b.reader.nodeReader.readRight(offset), node, depth+1, bitCount)
+	if err != nil {
+		return 0, err
+	}
+
+	ref := left
+	if left != right || left&indexLeaf == 0 {
+		if uint(len(b.nodes)/2) >= indexLeaf {
+			return 0, errors.New("the search tree is too large for an index")
+		}
+		ref = uint32(len(b.nodes) / 2)
+		b.nodes = append(b.nodes, left, right)
+	}
+	b.seenNodes[node] = ref + 1
+	return ref, nil
+}
+
+// record returns the reference for the value at the builder's path in the
+// record pointed to by pointer in node.
+func (b *indexBuilder) record(node, pointer uint) (uint32, error) {
+	offset, err := b.reader.resolveDataPointer(node, pointer)
+	if err != nil {
+		return 0, err
+	}
+	if ref, ok := b.recordRefs[offset]; ok {
+		return ref, nil
+	}
+
+	value, ok, err := b.reader.valueAtPath(uint(offset), b.path)
+	if err != nil {
+		return 0, err
+	}
+	ref := uint32(indexLeaf)
+	if ok {
+		id, ok := b.valueIDs[value]
+		if !ok {
+			if len(b.values) >= indexLeaf-1 {
+				return 0, errors.New("too many distinct values for an index")
+			}
+			id = uint32(len(b.values))
+			b.valueIDs[value] = id
+			b.values = append(b.values, value)
+		}
+		ref |= id
+	}
+	b.recordRefs[offset] = ref
+	return ref, nil
+}
+
+// LookupString returns the value indexed for the network containing ip. ok
+// is false if the network is not in the database or its record has no
+// value at the indexed path. IPv4-mapped IPv6 addresses are looked up as
+// IPv4 addresses, and the zone of ip, if any, is ignored.
+func (x *Index) LookupString(ip netip.Addr) (value string, ok bool) {
+	if !ip.IsValid() {
+		return "", false
+	}
+	ref := x.root
+	start := 0
+	if ip.Is4() || ip.Is4In6() {
+		ref = x.ipv4Root
+		start = 96
+	} else if x.ipVersion == 4 {
+		return "", false
+	}
+
+	address := ip.As16()
+	for i := start; ref&indexLeaf == 0; i++ {
+		if i >= 128 {
+			return "", false
+		}
+		bit := uint32(address[i>>3]>>(7-i%8)) & 1
+		ref = x.nodes[2*ref+bit]
+	}
+	id := ref &^ indexLeaf
+	if id == 0 {
+		return "", false
+	}
+	return x.values[id], true
+}
+
+// MarshalBinary implements encoding.BinaryMarshaler. The result may be
+// passed to IndexFromBytes, e.g., to cache an Index across restarts.
+func (x *Index) MarshalBinary() ([]byte, error) {
+	size := len(indexFormat) + 1 + 4*(4+len(x.nodes))
+	for _, value := range x.values[1:] {
+		size += binary.MaxVarintLen64 + len(value)
+	}
+	data := make([]byte, 0, size)
+	data = append(data, indexFormat...)
+	data = append(data, byte(x.ipVersion))
+	data = binary.BigEndian.AppendUint32(data, x.root)
+	data = binary.BigEndian.AppendUint32(data, x.ipv4Root)
+	data = binary.BigEndian.AppendUint32(data, uint32(len(x.nodes)/2))
+	for _, ref := range x.nodes {
+		data = binary.BigEndian.AppendUint32(data, ref)
+	}
+	data = binary.BigEndian.AppendUint32(data, uint32(len(x.values)-1))
+	for _, value := range x.values[1:] {
+		data = binary.AppendUvarint(data, uint64(len(value)))
+		data = append(data, value...)
+	}
+	return data, nil
+}
+
+// IndexFromBytes returns the Index encoded in data by Index.MarshalBinary.
+// The Index does not refer to data after IndexFromBytes returns.
+func IndexFromBytes(data []byte) (*Index, error) {
+	if len(data) < len(indexFormat)+1+12 || string(data[:len(indexFormat)]) != indexFormat {
+		return nil, errors.New("maxminddb: invalid index: unknown format")
+	}
+	data = data[len(indexFormat):]
+	x := &Index{
+		ipVersion: uint(data[0]),
+		root:      binary.BigEndian.Uint32(data[1:]),
+		ipv4Root:  binary.BigEndian.Uint32(data[5:]),
+	}
+	if x.ipVersion != 4 && x.ipVersion != 6 {
+		return nil, fmt.Errorf("maxminddb: invalid index: invalid IP version %d", x.ipVersion)
+	}
+	nodeCount := uint64(binary.BigEndian.Uint32(data[9:]))
+	data = data[13:]
+	if uint64(len(data)) < 8*nodeCount+4 {
+		return nil, errors.New("maxminddb: invalid index: unexpected end of data")
+	}
+	x.nodes = make([]uint32, 2*nodeCount)
+	for i := range x.nodes {
+		x.nodes[i] = binary.BigEndian.Uint32(data[4*i:])
+	}
+	data = data[8*nodeCount:]
+
+	valueCount := binary.BigEndian.Uint32(data)
+	data = data[4:]
+	if uint64(valueCount) > uint64(len(data)) {
+		return nil, errors.New("maxminddb: invalid index: unexpected end of data")
+	}
+	x.values = make([]string, 1, valueCount+1)
+	for i := uint32(0); i < valueCount; i++ {
+		length, n := binary.Uvarint(data)
+		if n <= 0 || length > uint64(len(data)-n) {
+			return nil, errors.New("maxminddb: invalid index: unexpected end of data")
+		}
+		x.values = append(x.values, string(data[n:n+int(length)]))
+		data = data[n+int(length):]
+	}
+	if len(data) != 0 {
+		return nil, errors.New("maxminddb: invalid index: unexpected data after the values")
+	}
+
+	for i, ref := range x.nodes {
+		if !x.validRef(ref, uint32(i/2)) {
+			return nil, fmt.Errorf("maxminddb: invalid index: invalid reference in node %d", i/2)
+		}
+	}
+	if !x.validRef(x.root, uint32(nodeCount)) || !x.validRef(x.ipv4Root, uint32(nodeCount)) {
+		return nil, errors.New("maxminddb: invalid index: invalid root")
+	}
+	return x, nil
+}
+
+// validRef returns true if ref refers to a value of x or to a node with a
+// number lower than limit. As nodes only refer to lower-numbered nodes, this
+// ensures that lookups terminate.
+func (x *Index) validRef(ref, limit uint32) bool {
+	if ref&indexLeaf != 0 {
+		return ref&^indexLeaf < uint32(len(x.values))
+	}
+	return ref < limit
+}
@@ -0,0 +1,232 @@
+package maxminddb
+
+import (
+	"math/rand"
+	"net"
+	"net/netip"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBuildIndex(t *testing.T) {
+	for _, file := range []string{
+		"GeoIP2-City-Test.mmdb",
+		"GeoIP2-Country-Test.mmdb",
+		"MaxMind-DB-test-ipv4-24.mmdb",
+		"MaxMind-DB-no-ipv4-search-tree.mmdb",
+	} {
+		t.Run(file, func(t *testing.T) {
+			reader, err := Open(testFile(file))
+			require.NoError(t, err)
+
+			index, err := reader.BuildIndex("country", "iso_code")
+			require.NoError(t, err)
+
+			data, err := index.MarshalBinary()
+			require.NoError(t, err)
+			loaded, err := IndexFromBytes(data)
+			require.NoError(t, err)
+			assert.Equal(t, index, loaded)
+
+			n := reader.Networks()
+			for n.Next() {
+				var record struct {
+					Country struct {
+						IsoCode *string `maxminddb:"iso_code"`
+					} `maxminddb:"country"`
+				}
+				network, err := n.Network(&record)
+				require.NoError(t, err)
+
+				ip, ok := netip.AddrFromSlice(network.IP)
+				require.True(t, ok)
+				value, ok := index.LookupString(ip)
+				if record.Country.IsoCode == nil {
+					assert.False(t, ok, network.String())
+				} else {
+					assert.True(t, ok, network.String())
+					assert.Equal(t, *record.Country.IsoCode, value, network.String())
+				}
+			}
+			require.NoError(t, n.Err())
+			require.NoError(t, reader.Close())
+		})
+	}
+}
+
+func TestIndexLookupString(t *testing.T) {
+	reader, err := Open(testFile("GeoIP2-City-Test.mmdb"))
+	require.NoError(t, err)
+
+	country, err := reader.BuildIndex("country", "iso_code")
+	require.NoError(t, err)
+	subdivision, err := reader.BuildIndex("subdivisions", -1, "iso_code")
+	require.NoError(t, err)
+	geonameID, err := reader.BuildIndex("city", "geoname_id")
+	require.NoError(t, err)
+
+	tests := []struct {
+		ip       string
+		index    *Index
+		expected string
+		ok       bool
+	}{
+		{ip: "81.2.69.142", index: country, expected: "GB", ok: true},
+		{ip: "::ffff:81.2.69.142", index: country, expected: "GB", ok: true},
+		{ip: "::81.2.69.142", index: country, expected: "GB", ok: true},
+		{ip: "2001:218::", index: country, expected: "JP", ok: true},
+		{ip: "2001:218::%eth0", index: country, expected: "JP", ok: true},
+		{ip: "::ffff:81.2.69.142%eth0", index: country, expected: "GB", ok: true},
+		{ip: "81.2.69.142", index: subdivision, expected: "ENG", ok: true},
+		{ip: "81.2.69.142", index: geonameID, expected: "2643743", ok: true},
+		{ip: "::", index: country},
+	}
+	for _, test := range tests {
+		value, ok := test.index.LookupString(netip.MustParseAddr(test.ip))
+		assert.Equal(t, test.ok, ok, test.ip)
+		assert.Equal(t, test.expected, value, test.ip)
+	}
+
+	value, ok := country.LookupString(netip.Addr{})
+	assert.False(t, ok)
+	assert.Empty(t, value)
+
+	_, err = reader.BuildIndex("country", 1.5)
+	assert.EqualError(t, err, "invalid path element 1.5 (float64); expected a string or an int")
+
+	require.NoError(t, reader.Close())
+
+	// The index does not depend on the reader.
+	value, ok = country.LookupString(netip.MustParseAddr("81.2.69.142"))
+	assert.True(t, ok)
+	assert.Equal(t, "GB", value)
+
+	_, err = reader.BuildIndex("country", "iso_code")
+	assert.EqualError(t, err, "cannot call BuildIndex on a closed database")
+}
+
+func TestIndexIPv4Database(t *testing.T) {
+	reader, err := Open(testFile("MaxMind-DB-test-ipv4-24.mmdb"))
+	require.NoError(t, err)
+
+	index, err := reader.BuildIndex("ip")
+	require.NoError(t, err)
+
+	value, ok := index.LookupString(netip.MustParseAddr("1.1.1.3"))
+	assert.True(t, ok)
+	assert.Equal(t, "1.1.1.2", value)
+
+	value, ok = index.LookupString(netip.MustParseAddr("::1.1.1.3"))
+	assert.False(t, ok)
+	assert.Empty(t, value)
+
+	require.NoError(t, reader.Close())
+}
+
+func TestIndexFromBytesErrors(t *testing.T) {
+	index := &Index{
+		nodes:     []uint32{indexLeaf | 1, indexLeaf},
+		values:    []string{"", "a"},
+		root:      0,
+		ipv4Root:  0,
+		ipVersion: 4,
+	}
+	data, err := index.MarshalBinary()
+	require.NoError(t, err)
+	loaded, err := IndexFromBytes(data)
+	require.NoError(t, err)
+	assert.Equal(t, index, loaded)
+
+	value, ok := loaded.LookupString(netip.MustParseAddr("1.2.3.4"))
+	assert.True(t, ok)
+	assert.Equal(t, "a", value)
+	_, ok = loaded.LookupString(netip.MustParseAddr("128.2.3.4"))
+	assert.False(t, ok)
+
+	tests := []struct {
+		name     string
+		modify   func([]byte) []byte
+		expected string
+	}{
+		{
+			name:     "format",
+			modify:   func(b []byte) []byte { b[7] = '2'; return b },
+			expected: "maxminddb: invalid index: unknown format",
+		},
+		{
+			name:     "IP version",
+			modify:   func(b []byte) []byte { b[8] = 5; return b },
+			expected: "maxminddb: invalid index: invalid IP version 5",
+		},
+		{
+			name:     "truncated",
+			modify:   func(b []byte) []byte { return b[:len(b)-1] },
+			expected: "maxminddb: invalid index: unexpected end of data",
+		},
+		{
+			name:     "trailing data",
+			modify:   func(b []byte) []byte { return append(b, 0) },
+			expected: "maxminddb: invalid index: unexpected data after the values",
+		},
+		{
+			name: "self reference",
+			// The left reference of node 0.
+			modify:   func(b []byte) []byte { b[21] = 0; return b },
+			expected: "maxminddb: invalid index: invalid reference in node 0",
+		},
+		{
+			name:     "value ID",
+			modify:   func(b []byte) []byte { b[28] = 2; return b },
+			expected: "maxminddb: invalid index: invalid reference in node 0",
+		},
+		{
+			name:     "root",
+			modify:   func(b []byte) []byte { b[12] = 1; return b },
+			expected: "maxminddb: invalid index: invalid root",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			input := test.modify(append([]byte(nil), data...))
+			_, err := IndexFromBytes(input)
+			assert.EqualError(t, err, test.expected)
+		})
+	}
+}
+
+func BenchmarkIndexLookupString(b *testing.B) {
+	db, err := Open("GeoLite2-City.mmdb")
+	require.NoError(b, err)
+
+	index, err := db.BuildIndex("country", "iso_code")
+	require.NoError(b, err)
+
+	//nolint:gosec // this is a test
+	r := rand.New(rand.NewSource(0))
+	ip := make(net.IP, 4)
+
+	b.Run("Lookup", func(b *testing.B) {
+		type MinCountry struct {
+			Country struct {
+				IsoCode string `maxminddb:"iso_code"`
+			} `maxminddb:"country"`
+		}
+		var result MinCountry
+		for i := 0; i < b.N; i++ {
+			randomIPv4Address(r, ip)
+			err = db.Lookup(ip, &result)
+			if err != nil {
+				b.Error(err)
+			}
+		}
+	})
+	b.Run("Index", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			randomIPv4Address(r, ip)
+			index.LookupString(netip.AddrFrom4([4]byte(ip)))
+		}
+	})
+	assert.NoError(b, db.Close(), "error on close")
+}
@@ -0,0 +1,46 @@
+package maxminddb
+
+import (
+	"github.com/3JoB/go-reflect"
+)
+
+// WithInterfaceFactory is an option for Open, FromBytes, and FromReader that
+// makes the decoder call factory to create the value of a nil struct field,
+// map value, or slice element of the interface type T, e.g., a Record
+// interface, and decode into the value created. factory must return a
+// non-nil pointer, e.g., &CityRecord{}, so that the value it points to can
+// be decoded into. It may be given several times for different interface
+// types; a later factory for the same type replaces an earlier one.
+//
+// The option has no effect unless T is an interface type with methods, as
+// values are decoded into empty interfaces as maps, slices, and scalars.
+// Nil values of interface types without a factory still cannot be decoded
+// into and an UnmarshalTypeError is returned.
+func WithInterfaceFactory[T any](factory func() T) ReaderOption {
+	return func(o *readerOptions) {
+		iface := reflect.TypeOf((*T)(nil)).Elem()
+		if iface.Kind() != reflect.Interface || iface.NumMethod() == 0 {
+			return
+		}
+		if o.interfaceFactories == nil {
+			o.interfaceFactories = map[reflect.Type]func() any{}
+		}
+		o.interfaceFactories[iface] = func() any { return factory() }
+	}
+}
+
+// newInterfaceValue sets result, a nil interface, to the pointer returned by
+// the factory set with WithInterfaceFactory for its type. It reports whether
+// there was such a factory and it returned a non-nil pointer.
+func (d *decoder) newInterfaceValue(result reflect.Value) bool {
+	factory, ok := d.interfaceFactories[result.Type()]
+	if !ok {
+		return false
+	}
+	value := reflect.ValueOf(factory())
+	if value.Kind() != reflect.Ptr || value.IsNil() {
+		return false
+	}
+	result.Set(value)
+	return true
+}
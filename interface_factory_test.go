@@ -0,0 +1,79 @@
+package maxminddb
+
+import (
+	"encoding/hex"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type testShape interface {
+	Area() float64
+}
+
+type testSquare struct {
+	Side float64 `maxminddb:"side"`
+}
+
+func (s *testSquare) Area() float64 {
+	return s.Side * s.Side
+}
+
+type testOther interface {
+	Other()
+}
+
+// interfaceTestData is
+//
+//	{"shape": {"side": 2.0}, "shapes": [{"side": 3.0}],
+//	 "named": {"a": {"side": 1.0}}}
+const interfaceTestData = "e3457368617065e14473696465684000000000000000467368617065730104e144736964" +
+	"65684008000000000000456e616d6564e14161e14473696465683ff0000000000000"
+
+func TestInterfaceFactory(t *testing.T) {
+	buffer, err := hex.DecodeString(interfaceTestData)
+	require.NoError(t, err)
+
+	var opts readerOptions
+	WithInterfaceFactory(func() testShape { return &testSquare{} })(&opts)
+	// Not an interface with methods, so ignored.
+	WithInterfaceFactory(func() any { return &testSquare{} })(&opts)
+	WithInterfaceFactory(func() *testSquare { return &testSquare{} })(&opts)
+	require.Len(t, opts.interfaceFactories, 1)
+	d := decoder{buffer: buffer, interfaceFactories: opts.interfaceFactories}
+
+	var result struct {
+		Shape  testShape            `maxminddb:"shape"`
+		Shapes []testShape          `maxminddb:"shapes"`
+		Named  map[string]testShape `maxminddb:"named"`
+	}
+	require.NoError(t, decodeRecord(&d, &result))
+	assert.Equal(t, &testSquare{Side: 2}, result.Shape)
+	assert.Equal(t, []testShape{&testSquare{Side: 3}}, result.Shapes)
+	assert.Equal(t, map[string]testShape{"a": &testSquare{Side: 1}}, result.Named)
+	assert.InDelta(t, 4.0, result.Shape.Area(), 0)
+
+	// A value already in the interface is decoded into as before.
+	existing := &testSquare{}
+	result.Shape = existing
+	require.NoError(t, decodeRecord(&d, &result))
+	assert.Same(t, existing, result.Shape)
+	assert.InDelta(t, 2.0, existing.Side, 0)
+
+	// Interfaces without a factory cannot be decoded into.
+	var other struct {
+		Shape testOther `maxminddb:"shape"`
+	}
+	err = decodeRecord(&d, &other)
+	var typeErr UnmarshalTypeError
+	require.ErrorAs(t, err, &typeErr)
+	assert.Equal(t, "shape", typeErr.Path)
+
+	// Neither can those whose factory returns nil.
+	WithInterfaceFactory(func() testShape { return nil })(&opts)
+	d = decoder{buffer: buffer, interfaceFactories: opts.interfaceFactories}
+	result.Shape = nil
+	err = decodeRecord(&d, &result)
+	require.ErrorAs(t, err, &typeErr)
+}
@@ -0,0 +1,286 @@
+package maxminddb
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"math/big"
+	"net/netip"
+	"slices"
+	"strconv"
+	"unicode/utf8"
+)
+
+// jsonFlushSize is the size at which WriteRecordJSON writes the JSON
+// encoded so far to its writer.
+const jsonFlushSize = 32 << 10
+
+// LookupJSON returns the record for ip encoded as JSON, without decoding it
+// into Go values first. Addresses are looked up as by LookupNetip. The bool
+// is false if the database has no record for ip. See WriteRecordJSON for
+// the encoding.
+func (r *Reader) LookupJSON(ip netip.Addr) ([]byte, bool, error) {
+	if r.buffer == nil {
+		return nil, false, errors.New("cannot call LookupJSON on a closed database")
+	}
+	offset, _, _, err := r.lookupAddr(ip)
+	if offset == NotFound || err != nil {
+		return nil, false, err
+	}
+	e := jsonEncoder{d: r.decoder}
+	if _, err := e.encode(uint(offset), 0); err != nil {
+		return nil, false, err
+	}
+	return e.buf, true, nil
+}
+
+// WriteRecordJSON writes the record at offset, e.g., as returned by
+// LookupOffset, to w as JSON, without decoding it into Go values first. The
+// JSON is written as it is encoded, in chunks of up to around 32 KiB, so a
+// failed write may leave partial JSON in w.
+//
+// Maps are encoded as objects with their keys in database order, arrays as
+// arrays, and strings, booleans, and numbers as the JSON equivalent, as
+// json.Marshal would encode them when decoded into an any, except that HTML
+// characters are not escaped. Bytes are encoded as base64 strings, and
+// uint128 values, which JSON numbers cannot represent exactly, as strings
+// holding their decimal representation. NaN and infinite floats cannot be
+// encoded and return an error.
+func (r *Reader) WriteRecordJSON(w io.Writer, offset uintptr) error {
+	if r.buffer == nil {
+		return errors.New("cannot call WriteRecordJSON on a closed database")
+	}
+	e := jsonEncoder{d: r.decoder, w: w}
+	if _, err := e.encode(uint(offset), 0); err != nil {
+		return err
+	}
+	return e.flush()
+}
+
+// jsonEncoder encodes values of the data section as JSON. If w is set, the
+// JSON is written to it whenever buf grows beyond jsonFlushSize.
+type jsonEncoder struct {
+	w   io.Writer
+	buf []byte
+	d   decoder
+}
+
+func (e *jsonEncoder) flush() error {
+	if e.w == nil || len(e.buf) == 0 {
+		return nil
+	}
+	_, err := e.w.Write(e.buf)
+	e.buf = e.buf[:0]
+	return err
+}
+
+// encode appends the value at offset to e.buf and returns the offset
+// following it.
+func (e *jsonEncoder) encode(offset uint, depth int) (uint, error) {
+	if depth > maximumDataStructureDepth {
+		return 0, newInvalidDatabaseError(
+			"exceeded maximum data structure depth; database is likely corrupt",
+		)
+	}
+	d := &e.d
+	typeNum, size, offset, err := d.decodeCtrlData(offset)
+	if err != nil {
+		return 0, err
+	}
+
+	switch typeNum {
+	case _Pointer:
+		pointer, newOffset, err := d.decodePointer(size, offset)
+		if err != nil {
+			return 0, err
+		}
+		_, err = e.encode(pointer, depth+1)
+		return newOffset, err
+	case _Map:
+		if err := d.checkContainerSize(2*size, offset); err != nil {
+			return 0, err
+		}
+		e.buf = append(e.buf, '{')
+		for i := uint(0); i < size; i++ {
+			if i > 0 {
+				e.buf = append(e.buf, ',')
+			}
+			key, valueOffset, err := d.decodeKey(offset)
+			if err != nil {
+				return 0, err
+			}
+			e.buf = appendJSONString(e.buf, key)
+			e.buf = append(e.buf, ':')
+			offset, err = e.encode(valueOffset, depth+1)
+			if err != nil {
+				return 0, err
+			}
+		}
+		e.buf = append(e.buf, '}')
+		return offset, e.maybeFlush()
+	case _Slice:
+		if err := d.checkContainerSize(size, offset); err != nil {
+			return 0, err
+		}
+		e.buf = append(e.buf, '[')
+		for i := uint(0); i < size; i++ {
+			if i > 0 {
+				e.buf = append(e.buf, ',')
+			}
+			offset, err = e.encode(offset, depth+1)
+			if err != nil {
+				return 0, err
+			}
+		}
+		e.buf = append(e.buf, ']')
+		return offset, e.maybeFlush()
+	case _Bool:
+		if size > 1 {
+			return 0, newInvalidDatabaseError(
+				"the MaxMind DB file's data section contains bad data (bool size of %v)",
+				size,
+			)
+		}
+		e.buf = strconv.AppendBool(e.buf, size != 0)
+		return offset, nil
+	}
+
+	newOffset := offset + size
+	if newOffset > uint(len(d.buffer)) {
+		return 0, newOffsetError()
+	}
+	value := d.buffer[offset:newOffset]
+	switch typeNum {
+	case _String:
+		e.buf = appendJSONString(e.buf, value)
+	case _Bytes:
+		n := len(e.buf)
+		encodedLen := base64.StdEncoding.EncodedLen(len(value))
+		e.buf = slices.Grow(e.buf, encodedLen+2)[:n+encodedLen+2]
+		e.buf[n] = '"'
+		base64.StdEncoding.Encode(e.buf[n+1:], value)
+		e.buf[len(e.buf)-1] = '"'
+	case _Uint16, _Uint32, _Uint64:
+		if size > 8 {
+			return 0, newInvalidDatabaseError("invalid size for %s: %d", dataTypeName(typeNum), size)
+		}
+		n, _ := d.decodeUint(size, offset)
+		e.buf = strconv.AppendUint(e.buf, n, 10)
+	case _Int32:
+		if size > 4 {
+			return 0, newInvalidDatabaseError("invalid size for int32: %d", size)
+		}
+		n, _ := d.decodeInt(size, offset)
+		e.buf = strconv.AppendInt(e.buf, int64(n), 10)
+	case _Uint128:
+		if size > 16 {
+			return 0, newInvalidDatabaseError("invalid size for uint128: %d", size)
+		}
+		e.buf = append(e.buf, '"')
+		e.buf = new(big.Int).SetBytes(value).Append(e.buf, 10)
+		e.buf = append(e.buf, '"')
+	case _Float64:
+		if size != 8 {
+			return 0, newInvalidDatabaseError("invalid size for double: %d", size)
+		}
+		f, _ := d.decodeFloat64(size, offset)
+		if e.buf, err = appendJSONFloat(e.buf, f, 64); err != nil {
+			return 0, err
+		}
+	case _Float32:
+		if size != 4 {
+			return 0, newInvalidDatabaseError("invalid size for float: %d", size)
+		}
+		f, _ := d.decodeFloat32(size, offset)
+		if e.buf, err = appendJSONFloat(e.buf, float64(f), 32); err != nil {
+			return 0, err
+		}
+	default:
+		return 0, newInvalidDatabaseError("unknown type: %d", typeNum)
+	}
+	return newOffset, e.maybeFlush()
+}
+
+func (e *jsonEncoder) maybeFlush() error {
+	if len(e.buf) < jsonFlushSize {
+		return nil
+	}
+	return e.flush()
+}
+
+// appendJSONFloat appends f, a float of the given size in bits, formatted
+// as by encoding/json.
+func appendJSONFloat(dst []byte, f float64, bits int) ([]byte, error) {
+	if math.IsInf(f, 0) || math.IsNaN(f) {
+		return nil, fmt.Errorf("maxminddb: cannot encode %v as JSON", f)
+	}
+	format := byte('f')
+	if abs := math.Abs(f); abs != 0 {
+		if bits == 64 && (abs < 1e-6 || abs >= 1e21) ||
+			bits == 32 && (float32(abs) < 1e-6 || float32(abs) >= 1e21) {
+			format = 'e'
+		}
+	}
+	dst = strconv.AppendFloat(dst, f, format, -1, bits)
+	if format == 'e' {
+		// Clean up e-09 to e-9.
+		if n := len(dst); n >= 4 && dst[n-4] == 'e' && dst[n-3] == '-' && dst[n-2] == '0' {
+			dst[n-2] = dst[n-1]
+			dst = dst[:n-1]
+		}
+	}
+	return dst, nil
+}
+
+const hexDigits = "0123456789abcdef"
+
+// appendJSONString appends s as a JSON string. Invalid UTF-8 is replaced
+// with U+FFFD, and U+2028 and U+2029 are escaped, as by encoding/json.
+func appendJSONString(dst, s []byte) []byte {
+	dst = append(dst, '"')
+	start := 0
+	for i := 0; i < len(s); {
+		if b := s[i]; b < utf8.RuneSelf {
+			if b >= 0x20 && b != '"' && b != '\\' {
+				i++
+				continue
+			}
+			dst = append(dst, s[start:i]...)
+			switch b {
+			case '"', '\\':
+				dst = append(dst, '\\', b)
+			case '\n':
+				dst = append(dst, '\\', 'n')
+			case '\r':
+				dst = append(dst, '\\', 'r')
+			case '\t':
+				dst = append(dst, '\\', 't')
+			default:
+				dst = append(dst, '\\', 'u', '0', '0', hexDigits[b>>4], hexDigits[b&0xf])
+			}
+			i++
+			start = i
+			continue
+		}
+		c, size := utf8.DecodeRune(s[i:])
+		if c == utf8.RuneError && size == 1 {
+			dst = append(dst, s[start:i]...)
+			dst = append(dst, `\ufffd`...)
+			i += size
+			start = i
+			continue
+		}
+		if c == '\u2028' || c == '\u2029' {
+			dst = append(dst, s[start:i]...)
+			dst = append(dst, '\\', 'u', '2', '0', '2', hexDigits[c&0xf])
+			i += size
+			start = i
+			continue
+		}
+		i += size
+	}
+	dst = append(dst, s[start:]...)
+	return append(dst, '"')
+}
@@ -0,0 +1,189 @@
+package maxminddb
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"math"
+	"net"
+	"net/netip"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLookupJSON(t *testing.T) {
+	reader, err := Open(testFile("MaxMind-DB-test-decoder.mmdb"))
+	require.NoError(t, err)
+	defer reader.Close()
+
+	record, ok, err := reader.LookupJSON(netip.MustParseAddr("::1.1.1.0"))
+	require.NoError(t, err)
+	require.True(t, ok)
+	assert.JSONEq(t, `{
+		"array": [1, 2, 3],
+		"boolean": true,
+		"bytes": "AAAAKg==",
+		"double": 42.123456,
+		"float": 1.1,
+		"int32": -268435456,
+		"map": {"mapX": {"arrayX": [7, 8, 9], "utf8_stringX": "hello"}},
+		"uint16": 100,
+		"uint32": 268435456,
+		"uint64": 1152921504606846976,
+		"uint128": "1329227995784915872903807060280344576",
+		"utf8_string": "unicode! ☯ - ♫"
+	}`, string(record))
+	assert.Contains(t, string(record), `"float":1.1`)
+	assert.Contains(t, string(record), `"uint64":1152921504606846976`)
+
+	// The keys are in database order.
+	offset, err := reader.LookupOffset(net.ParseIP("::1.1.1.0"))
+	require.NoError(t, err)
+	var expectedKeys []string
+	require.NoError(t, reader.Decoder(offset).DecodeMap(func(key string, _ *Decoder) error {
+		expectedKeys = append(expectedKeys, key)
+		return nil
+	}))
+	dec := json.NewDecoder(bytes.NewReader(record))
+	var keys []string
+	_, err = dec.Token()
+	require.NoError(t, err)
+	for dec.More() {
+		key, err := dec.Token()
+		require.NoError(t, err)
+		keys = append(keys, key.(string))
+		var value json.RawMessage
+		require.NoError(t, dec.Decode(&value))
+	}
+	assert.Equal(t, expectedKeys, keys)
+
+	var buf bytes.Buffer
+	require.NoError(t, reader.WriteRecordJSON(&buf, offset))
+	assert.Equal(t, string(record), buf.String())
+
+	record, ok, err = reader.LookupJSON(netip.MustParseAddr("10.0.0.1"))
+	require.NoError(t, err)
+	assert.False(t, ok)
+	assert.Nil(t, record)
+}
+
+func TestLookupJSONCity(t *testing.T) {
+	reader, err := Open(testFile("GeoIP2-City-Test.mmdb"))
+	require.NoError(t, err)
+	defer reader.Close()
+
+	for _, ip := range []string{"81.2.69.160", "2.125.160.216", "2001:218::"} {
+		var record any
+		require.NoError(t, reader.Lookup(net.ParseIP(ip), &record))
+		expected, err := json.Marshal(record)
+		require.NoError(t, err)
+
+		got, ok, err := reader.LookupJSON(netip.MustParseAddr(ip))
+		require.NoError(t, err)
+		require.True(t, ok)
+		assert.JSONEq(t, string(expected), string(got), ip)
+	}
+}
+
+// countingFailingWriter fails every write and counts them.
+type countingFailingWriter struct {
+	writes int
+}
+
+var errWrite = errors.New("write failed")
+
+func (w *countingFailingWriter) Write(p []byte) (int, error) {
+	w.writes++
+	return 0, errWrite
+}
+
+func TestWriteRecordJSONErrors(t *testing.T) {
+	reader, err := Open(testFile("MaxMind-DB-test-decoder.mmdb"))
+	require.NoError(t, err)
+	offset, err := reader.LookupOffset(net.ParseIP("::1.1.1.0"))
+	require.NoError(t, err)
+
+	w := &countingFailingWriter{}
+	assert.ErrorIs(t, reader.WriteRecordJSON(w, offset), errWrite)
+	assert.Equal(t, 1, w.writes)
+
+	var buf bytes.Buffer
+	var invalidErr InvalidDatabaseError
+	assert.ErrorAs(t, reader.WriteRecordJSON(&buf, uintptr(len(reader.decoder.buffer))), &invalidErr)
+
+	require.NoError(t, reader.Close())
+	assert.EqualError(t, reader.WriteRecordJSON(&buf, offset), "cannot call WriteRecordJSON on a closed database")
+	_, _, err = reader.LookupJSON(netip.MustParseAddr("::1.1.1.0"))
+	assert.EqualError(t, err, "cannot call LookupJSON on a closed database")
+}
+
+func TestAppendJSONString(t *testing.T) {
+	for _, s := range []string{
+		"",
+		"plain",
+		`quote " backslash \ slash /`,
+		"control \x00 \x1f \n \r \t",
+		"unicode ☯ and \u2028 \u2029",
+		"html <&>",
+		"invalid \xff utf-8",
+	} {
+		got := appendJSONString(nil, []byte(s))
+		var decoded string
+		require.NoError(t, json.Unmarshal(got, &decoded), s)
+		assert.Equal(t, strings.ToValidUTF8(s, "\ufffd"), decoded)
+		assert.NotContains(t, string(got), "\u2028")
+	}
+	assert.Equal(t, `"html <&>"`, string(appendJSONString(nil, []byte("html <&>"))))
+}
+
+func TestAppendJSONFloat(t *testing.T) {
+	for _, f := range []float64{0, 1, -1.5, 42.123456, 1e-7, 1e21, 123456789, 1e20} {
+		got, err := appendJSONFloat(nil, f, 64)
+		require.NoError(t, err)
+		expected, err := json.Marshal(f)
+		require.NoError(t, err)
+		assert.Equal(t, string(expected), string(got))
+	}
+	for _, f := range []float32{1.1, 1e-7, 3.4e38} {
+		got, err := appendJSONFloat(nil, float64(f), 32)
+		require.NoError(t, err)
+		expected, err := json.Marshal(f)
+		require.NoError(t, err)
+		assert.Equal(t, string(expected), string(got))
+	}
+	_, err := appendJSONFloat(nil, math.NaN(), 64)
+	assert.EqualError(t, err, "maxminddb: cannot encode NaN as JSON")
+}
+
+func BenchmarkLookupJSON(b *testing.B) {
+	reader, err := Open(testFile("GeoIP2-City-Test.mmdb"))
+	require.NoError(b, err)
+	defer reader.Close()
+	ip := "81.2.69.160"
+
+	b.Run("Direct", func(b *testing.B) {
+		addr := netip.MustParseAddr(ip)
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			if _, _, err := reader.LookupJSON(addr); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	b.Run("Marshal", func(b *testing.B) {
+		netIP := net.ParseIP(ip)
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			var record any
+			if err := reader.Lookup(netIP, &record); err != nil {
+				b.Fatal(err)
+			}
+			if _, err := json.Marshal(record); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
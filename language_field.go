@@ -0,0 +1,158 @@
+package maxminddb
+
+import (
+	"strings"
+
+	"github.com/3JoB/go-reflect"
+)
+
+// languageSelection is the language selected for a struct field with the
+// lang tag option, which decodes a single value of a names map, e.g.,
+//
+//	City struct {
+//		Name string `maxminddb:"names,lang=pt-BR|pt,fallback=en"`
+//	} `maxminddb:"city"`
+//
+// The languages of lang and fallback are separated by "|" or ";", e.g.,
+// lang=zh-CN;en. The value of the first language of lang that is in the map
+// is decoded and then that of the first language of fallback. A fallback of "any" selects
+// the first entry of the map in the database if none of the languages are
+// in it. If no entry is selected, the field is set to its zero value. The
+// other entries are skipped without being decoded.
+type languageSelection struct {
+	languages []string
+	any       bool
+}
+
+// parseLanguageOption returns the language selection of field or nil if its
+// tag has no lang option.
+func parseLanguageOption(field reflect.StructField) *languageSelection {
+	_, options, _ := strings.Cut(field.Tag.Get("maxminddb"), ",")
+	var lang, fallback string
+	hasLang := false
+	for options != "" {
+		var option string
+		option, options, _ = strings.Cut(options, ",")
+		name, value, _ := strings.Cut(option, "=")
+		switch name {
+		case "lang":
+			lang, hasLang = value, true
+		case "fallback":
+			fallback = value
+		}
+	}
+	if !hasLang {
+		return nil
+	}
+
+	sel := &languageSelection{}
+	for i, list := range []string{lang, fallback} {
+		for _, language := range splitLanguages(list) {
+			switch {
+			case language == "":
+			case i == 1 && language == "any":
+				sel.any = true
+			default:
+				sel.languages = append(sel.languages, language)
+			}
+		}
+	}
+	return sel
+}
+
+// splitLanguages splits the languages of a lang or fallback option.
+func splitLanguages(list string) []string {
+	return strings.FieldsFunc(list, func(r rune) bool {
+		return r == '|' || r == ';'
+	})
+}
+
+// rank returns the position of key in the priority of sel, where lower is
+// preferred, and false if key is not selected at all.
+func (sel *languageSelection) rank(key []byte) (int, bool) {
+	for i, language := range sel.languages {
+		// The string() does not create a copy due to this compiler
+		// optimization: https://github.com/golang/go/issues/3512
+		if language == string(key) {
+			return i, true
+		}
+	}
+	return len(sel.languages), sel.any
+}
+
+// decodeLanguage decodes the value selected by sel from the map with key at
+// offset into result. A value that is not a map is decoded as if the field
+// had no lang option.
+func (d *decoder) decodeLanguage(
+	key []byte,
+	offset uint,
+	result reflect.Value,
+	sel *languageSelection,
+	depth int,
+) (uint, error) {
+	typeNum, size, dataOffset, err := d.decodeCtrlData(offset)
+	if err != nil {
+		return 0, err
+	}
+	var newOffset uint
+	if typeNum == _Pointer {
+		var pointer uint
+		pointer, newOffset, err = d.decodePointer(size, dataOffset)
+		if err != nil {
+			return 0, err
+		}
+		typeNum, size, dataOffset, err = d.decodeCtrlData(pointer)
+		if err != nil {
+			return 0, err
+		}
+	}
+	if typeNum != _Map {
+		return d.decodeMapValue(key, offset, result, depth)
+	}
+	if err := d.checkContainerSize(2*size, dataOffset); err != nil {
+		return 0, err
+	}
+
+	var (
+		selected uint
+		found    bool
+		bestRank int
+	)
+	index := d.indexedMap(dataOffset)
+	offset = dataOffset
+	for i := uint(0); i < size; i++ {
+		var entryKey []byte
+		entryKey, offset, err = d.mapEntry(index, i, offset)
+		if err != nil {
+			return 0, err
+		}
+		if d.keyTransform != nil {
+			entryKey = []byte(d.keyTransform(string(key), string(entryKey)))
+		}
+		if rank, ok := sel.rank(entryKey); ok && (!found || rank < bestRank) {
+			selected, found, bestRank = offset, true, rank
+		}
+		if index != nil {
+			continue
+		}
+		offset, err = d.nextValueOffset(offset, 1)
+		if err != nil {
+			return 0, err
+		}
+	}
+	if index != nil {
+		offset = index.end
+	}
+	if newOffset == 0 {
+		newOffset = offset
+	}
+
+	if !found {
+		reflectSetZero(result)
+		return newOffset, nil
+	}
+	if _, err := d.decode(selected, result, depth); err != nil {
+		return 0, err
+	}
+	return newOffset, nil
+}
@@ -0,0 +1,162 @@
+package maxminddb
+
+import (
+	"encoding/hex"
+	"net"
+	"testing"
+
+	"github.com/3JoB/go-reflect"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// languageTestData is {"de": "A", "en": "B", "pt-BR": "C"} at offset 0
+// followed at offset 19 by
+//
+//	{"city": {"names": <pointer to offset 0>},
+//	 "country": {"names": {"fr": "D"}}, "id": "x"}
+//
+// The pointer is at offset 32.
+const languageTestData = "e3426465414142656e41424570742d42524143" +
+	"e34463697479e1456e616d6573200047636f756e747279e1456e616d6573e142667241444269644178"
+
+func TestDecodeLanguage(t *testing.T) {
+	buffer, err := hex.DecodeString(languageTestData)
+	require.NoError(t, err)
+	d := decoder{buffer: buffer}
+
+	tests := map[string]string{
+		"lang=en":                   "B",
+		"lang=pt-BR":                "C",
+		"lang=fr":                   "",
+		"lang=fr|de":                "A",
+		"lang=fr,fallback=en":       "B",
+		"lang=fr,fallback=ja|pt-BR": "C",
+		"lang=fr,fallback=any":      "A",
+		"lang=en,fallback=any":      "B",
+		"lang=pt-BR|en|de":          "C",
+		"lang=fr;de":                "A",
+		"lang=ja;pt-BR|en":          "C",
+		"lang=fr,fallback=ja;en":    "B",
+		"lang=ja,fallback=ru":       "",
+	}
+	for options, expected := range tests {
+		t.Run(options, func(t *testing.T) {
+			sel := parseLanguageOption(reflect.StructField{
+				Tag: reflect.StructTag(`maxminddb:"names,` + options + `"`),
+			})
+			require.NotNil(t, sel)
+
+			// Directly and through a pointer.
+			for offset, end := range map[uint]uint{0: 19, 32: 34} {
+				result := "previous"
+				newOffset, err := d.decodeLanguage(
+					[]byte("names"), offset, reflect.ValueOf(&result).Elem(), sel, 0,
+				)
+				require.NoError(t, err)
+				assert.Equal(t, end, newOffset)
+				assert.Equal(t, expected, result)
+			}
+		})
+	}
+
+	assert.Nil(t, parseLanguageOption(reflect.StructField{Tag: `maxminddb:"names"`}))
+	assert.Nil(t, parseLanguageOption(reflect.StructField{Tag: `maxminddb:"names,fallback=any"`}))
+}
+
+func TestLanguageStructFields(t *testing.T) {
+	buffer, err := hex.DecodeString(languageTestData)
+	require.NoError(t, err)
+	d := decoder{buffer: buffer}
+
+	var record struct {
+		City struct {
+			Name string `maxminddb:"names,lang=pt-BR"`
+		} `maxminddb:"city"`
+		Country struct {
+			Name *string `maxminddb:"names,lang=en,fallback=any"`
+		} `maxminddb:"country"`
+		ID string `maxminddb:"id"`
+	}
+	_, err = d.decode(19, reflect.ValueOf(&record), 0)
+	require.NoError(t, err)
+	assert.Equal(t, "C", record.City.Name)
+	require.NotNil(t, record.Country.Name)
+	assert.Equal(t, "D", *record.Country.Name)
+	assert.Equal(t, "x", record.ID, "entries after the selected map are decoded")
+
+	// A names map without a matching entry clears the field.
+	var reused struct {
+		City struct {
+			Name string `maxminddb:"names,lang=ja"`
+		} `maxminddb:"city"`
+	}
+	reused.City.Name = "previous"
+	_, err = d.decode(19, reflect.ValueOf(&reused), 0)
+	require.NoError(t, err)
+	assert.Empty(t, reused.City.Name)
+}
+
+func TestLanguageWithMapKeyTransform(t *testing.T) {
+	// {"names": {"pt-br": "A"}}
+	buffer, err := hex.DecodeString("e1456e616d6573e14570742d62724141")
+	require.NoError(t, err)
+	d := decoder{buffer: buffer, keyTransform: NormalizeLanguageTags}
+
+	var record struct {
+		Name string `maxminddb:"names,lang=pt-BR"`
+	}
+	_, err = d.decode(0, reflect.ValueOf(&record), 0)
+	require.NoError(t, err)
+	assert.Equal(t, "A", record.Name)
+}
+
+func TestLanguageCity(t *testing.T) {
+	reader, err := Open(testFile("GeoIP2-City-Test.mmdb"))
+	require.NoError(t, err)
+	defer reader.Close()
+
+	ip := net.ParseIP("81.2.69.160")
+	var names struct {
+		City struct {
+			Names map[string]string `maxminddb:"names"`
+		} `maxminddb:"city"`
+		Continent struct {
+			Names map[string]string `maxminddb:"names"`
+		} `maxminddb:"continent"`
+	}
+	require.NoError(t, reader.Lookup(ip, &names))
+	require.NotEmpty(t, names.City.Names)
+
+	var record struct {
+		City struct {
+			Name string `maxminddb:"names,lang=xx|en,fallback=de"`
+		} `maxminddb:"city"`
+		Country struct {
+			Name string `maxminddb:"names,lang=xx"`
+		} `maxminddb:"country"`
+		Continent struct {
+			Name string `maxminddb:"names,lang=xx,fallback=any"`
+		} `maxminddb:"continent"`
+		Location struct {
+			TimeZone string `maxminddb:"time_zone"`
+		} `maxminddb:"location"`
+	}
+	require.NoError(t, reader.Lookup(ip, &record))
+	assert.Equal(t, names.City.Names["en"], record.City.Name)
+	assert.Empty(t, record.Country.Name)
+	continentNames := make([]string, 0, len(names.Continent.Names))
+	for _, name := range names.Continent.Names {
+		continentNames = append(continentNames, name)
+	}
+	assert.Contains(t, continentNames, record.Continent.Name)
+	assert.Equal(t, "Europe/London", record.Location.TimeZone)
+
+	var english struct {
+		City struct {
+			Name string `maxminddb:"names,lang=en"`
+		} `maxminddb:"city"`
+	}
+	require.NoError(t, reader.Lookup(ip, &english))
+	assert.Equal(t, "London", english.City.Name)
+}
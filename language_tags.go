@@ -0,0 +1,64 @@
+package maxminddb
+
+import "strings"
+
+// NormalizeLanguageTags is a transform for WithMapKeyTransform that makes the
+// keys of maps with the key "names" canonical if they have the form of a
+// BCP 47 language tag with an optional script and region: the language is
+// lower case, the script title case, and the region upper case, e.g., "pt-br"
+// becomes "pt-BR" and "ZH-HANS" becomes "zh-Hans". This matches the keys used
+// in MaxMind databases. Other keys are returned unchanged.
+func NormalizeLanguageTags(parent, key string) string {
+	if parent != "names" {
+		return key
+	}
+	return canonicalLanguageTag(key)
+}
+
+// canonicalLanguageTag returns tag with the case of its subtags made
+// canonical or tag unchanged if it is not a language tag of the form
+// language[-script][-region].
+func canonicalLanguageTag(tag string) string {
+	language, rest, _ := strings.Cut(tag, "-")
+	if len(language) < 2 || len(language) > 3 || !isAlpha(language) {
+		return tag
+	}
+	canonical := strings.ToLower(language)
+
+	subtag, rest, _ := strings.Cut(rest, "-")
+	if len(subtag) == 4 && isAlpha(subtag) {
+		canonical += "-" + strings.ToUpper(subtag[:1]) + strings.ToLower(subtag[1:])
+		subtag, rest, _ = strings.Cut(rest, "-")
+	}
+	switch {
+	case subtag == "":
+	case len(subtag) == 2 && isAlpha(subtag):
+		canonical += "-" + strings.ToUpper(subtag)
+	case len(subtag) == 3 && isDigits(subtag):
+		canonical += "-" + subtag
+	default:
+		return tag
+	}
+	if rest != "" || strings.HasSuffix(tag, "-") {
+		return tag
+	}
+	return canonical
+}
+
+func isAlpha(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if c := s[i] | 0x20; c < 'a' || c > 'z' {
+			return false
+		}
+	}
+	return true
+}
+
+func isDigits(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] < '0' || s[i] > '9' {
+			return false
+		}
+	}
+	return true
+}
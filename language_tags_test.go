@@ -0,0 +1,146 @@
+package maxminddb
+
+import (
+	"encoding/hex"
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/3JoB/go-reflect"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNormalizeLanguageTags(t *testing.T) {
+	tests := map[string]string{
+		"en":         "en",
+		"EN":         "en",
+		"pt-BR":      "pt-BR",
+		"pt-br":      "pt-BR",
+		"zh-cn":      "zh-CN",
+		"zh-hans":    "zh-Hans",
+		"ZH-HANS-CN": "zh-Hans-CN",
+		"es-419":     "es-419",
+		"fil":        "fil",
+		"":           "",
+		"e":          "e",
+		"english":    "english",
+		"en-":        "en-",
+		"en-u":       "en-u",
+		"en-us-x":    "en-us-x",
+		"de-1996":    "de-1996",
+		"en_us":      "en_us",
+		"12":         "12",
+	}
+	for key, expected := range tests {
+		assert.Equal(t, expected, NormalizeLanguageTags("names", key), key)
+	}
+	assert.Equal(t, "pt-br", NormalizeLanguageTags("", "pt-br"))
+	assert.Equal(t, "pt-br", NormalizeLanguageTags("city", "pt-br"))
+}
+
+func TestMapKeyTransform(t *testing.T) {
+	tests := map[string]string{
+		// {"names": {"pt-BR": "A", "zh-CN": "B"}, "region": {"pt-BR": "C"},
+		// "subdivisions": [{"names": {"pt-BR": "D"}}]}
+		"maxmind": "e345" + "6e616d6573" + "e24570742d42524141457a682d434e4142" +
+			"46726567696f6e" + "e14570742d42524143" +
+			"4c7375626469766973696f6e73" + "0104e1456e616d6573e14570742d42524144",
+		// The same with lower case regions.
+		"lowercase": "e345" + "6e616d6573" + "e24570742d62724141457a682d636e4142" +
+			"46726567696f6e" + "e14570742d62724143" +
+			"4c7375626469766973696f6e73" + "0104e1456e616d6573e14570742d62724144",
+	}
+	for name, input := range tests {
+		t.Run(name, func(t *testing.T) {
+			buffer, err := hex.DecodeString(input)
+			require.NoError(t, err)
+			d := decoder{buffer: buffer, keyTransform: NormalizeLanguageTags}
+
+			var result map[string]any
+			_, err = d.decode(0, reflect.ValueOf(&result), 0)
+			require.NoError(t, err)
+			regionKey := "pt-BR"
+			if name == "lowercase" {
+				// Only the keys of names maps are normalized.
+				regionKey = "pt-br"
+			}
+			assert.Equal(
+				t,
+				map[string]any{
+					"names":  map[string]any{"pt-BR": "A", "zh-CN": "B"},
+					"region": map[string]any{regionKey: "C"},
+					"subdivisions": []any{
+						map[string]any{"names": map[string]any{"pt-BR": "D"}},
+					},
+				},
+				result,
+			)
+
+			var record struct {
+				Names        map[string]string `maxminddb:"names"`
+				Subdivisions []struct {
+					Names map[string]string `maxminddb:"names"`
+				} `maxminddb:"subdivisions"`
+			}
+			_, err = d.decode(0, reflect.ValueOf(&record), 0)
+			require.NoError(t, err)
+			assert.Equal(t, map[string]string{"pt-BR": "A", "zh-CN": "B"}, record.Names)
+			require.Len(t, record.Subdivisions, 1)
+			assert.Equal(t, map[string]string{"pt-BR": "D"}, record.Subdivisions[0].Names)
+		})
+	}
+}
+
+func TestMapKeyTransformStructFields(t *testing.T) {
+	// {"NAMES": {"en": "A"}}
+	buffer, err := hex.DecodeString("e1454e414d4553e142656e4141")
+	require.NoError(t, err)
+	d := decoder{
+		buffer: buffer,
+		keyTransform: func(parent, key string) string {
+			if parent == "" {
+				return strings.ToLower(key)
+			}
+			return key
+		},
+	}
+
+	var record struct {
+		Names map[string]string `maxminddb:"names"`
+	}
+	_, err = d.decode(0, reflect.ValueOf(&record), 0)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"en": "A"}, record.Names)
+}
+
+func TestWithMapKeyTransform(t *testing.T) {
+	reader, err := Open(
+		testFile("GeoIP2-Country-Test.mmdb"),
+		WithMapKeyTransform(NormalizeLanguageTags),
+	)
+	require.NoError(t, err)
+
+	var record struct {
+		Continent struct {
+			Names map[string]string `maxminddb:"names"`
+		} `maxminddb:"continent"`
+	}
+	require.NoError(t, reader.Lookup(net.ParseIP("89.160.20.128"), &record))
+	assert.Equal(
+		t,
+		map[string]string{
+			"de":    "Europa",
+			"en":    "Europe",
+			"es":    "Europa",
+			"fr":    "Europe",
+			"ja":    "ヨーロッパ",
+			"pt-BR": "Europa",
+			"ru":    "Европа",
+			"zh-CN": "欧洲",
+		},
+		record.Continent.Names,
+	)
+
+	require.NoError(t, reader.Close())
+}
@@ -0,0 +1,142 @@
+package maxminddb
+
+import (
+	"errors"
+	"net"
+
+	"github.com/3JoB/go-reflect"
+)
+
+// LookupOption are options for Reader.With. Unlike ReaderOptions, they
+// apply to individual lookups.
+type LookupOption func(*lookupOptions)
+
+type lookupOptions struct {
+	maxDecodedBytes    uint
+	setMaxDecodedBytes bool
+	softFailDecode     bool
+	setSoftFailDecode  bool
+
+	// index is set by Record.Decode.
+	index *recordIndex
+}
+
+// apply overrides the reader-wide settings of d with the options.
+func (o *lookupOptions) apply(d *decoder) {
+	if o.setMaxDecodedBytes {
+		d.maxDecodedBytes = o.maxDecodedBytes
+	}
+	if o.setSoftFailDecode {
+		d.softFail = o.softFailDecode
+	}
+	d.index = o.index
+}
+
+// MaxDecodedBytes is a LookupOption that limits the approximate amount of
+// memory allocated for the output of a decode to n bytes, as
+// WithMaxDecodedBytes does for all decodes. It overrides the limit set when
+// opening the database; 0 removes the limit.
+func MaxDecodedBytes(n int) LookupOption {
+	return func(o *lookupOptions) {
+		o.maxDecodedBytes = uint(max(n, 0))
+		o.setMaxDecodedBytes = true
+	}
+}
+
+// SoftFailDecode is a LookupOption that turns soft-fail decoding, as
+// described for WithSoftFailDecode, on or off regardless of whether it was
+// enabled when opening the database.
+func SoftFailDecode(enabled bool) LookupOption {
+	return func(o *lookupOptions) {
+		o.softFailDecode = enabled
+		o.setSoftFailDecode = true
+	}
+}
+
+// Lookuper performs lookups in a Reader with a set of LookupOptions. It is
+// returned by Reader.With and is a small value that may be copied and
+// discarded freely. Its methods behave like the Reader methods of the same
+// name and are safe for concurrent use.
+type Lookuper struct {
+	reader  *Reader
+	options lookupOptions
+}
+
+// With returns a Lookuper that applies options to each lookup, e.g.,
+//
+//	err := reader.With(SoftFailDecode(true)).Lookup(ip, &record)
+//
+// Options given later override earlier ones. The Reader is not modified.
+func (r *Reader) With(options ...LookupOption) Lookuper {
+	l := Lookuper{reader: r}
+	for _, option := range options {
+		option(&l.options)
+	}
+	return l
+}
+
+// Lookup retrieves the database record for ip and stores it in the value
+// pointed to by result. See Reader.Lookup.
+func (l Lookuper) Lookup(ip net.IP, result any) error {
+	r := l.reader
+	if r.buffer == nil {
+		return errors.New("cannot call Lookup on a closed database")
+	}
+	offset, _, _, err := r.lookupRecord(ip)
+	if offset == NotFound || err != nil {
+		return err
+	}
+	return r.decodeWithOptions(offset, result, l.options)
+}
+
+// LookupNetwork retrieves the database record for ip and stores it in the
+// value pointed to by result. It also returns the network associated with
+// the record. See Reader.LookupNetwork.
+func (l Lookuper) LookupNetwork(
+	ip net.IP,
+	result any,
+) (network *net.IPNet, ok bool, err error) {
+	if l.reader.buffer == nil {
+		return nil, false, errors.New("cannot call Lookup on a closed database")
+	}
+	network = newIPNet()
+	ok, err = l.LookupNetworkInto(ip, network, result)
+	return network, ok, err
+}
+
+// LookupNetworkInto retrieves the database record for ip and stores it in
+// the value pointed to by result. It also stores the network associated
+// with the record in network if it is not nil. See
+// Reader.LookupNetworkInto.
+func (l Lookuper) LookupNetworkInto(ip net.IP, network *net.IPNet, result any) (ok bool, err error) {
+	r := l.reader
+	if r.buffer == nil {
+		return false, errors.New("cannot call Lookup on a closed database")
+	}
+	offset, prefixLength, ip, err := r.lookupRecord(ip)
+
+	if network != nil {
+		r.cidrInto(network, ip, prefixLength)
+	}
+	if offset == NotFound || err != nil {
+		return false, err
+	}
+
+	return true, r.decodeWithOptions(offset, result, l.options)
+}
+
+// Decode decodes the record at offset into result. See Reader.Decode.
+func (l Lookuper) Decode(offset uintptr, result any) error {
+	if l.reader.buffer == nil {
+		return errors.New("cannot call Decode on a closed database")
+	}
+	return l.reader.decodeWithOptions(offset, result, l.options)
+}
+
+// DecodeValue decodes the record at offset into v. See Reader.DecodeValue.
+func (l Lookuper) DecodeValue(offset uintptr, v reflect.Value) error {
+	if l.reader.buffer == nil {
+		return errors.New("cannot call DecodeValue on a closed database")
+	}
+	return l.reader.decodeValueWithOptions(offset, v, l.options)
+}
@@ -0,0 +1,102 @@
+package maxminddb
+
+import (
+	"errors"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLookuperMaxDecodedBytes(t *testing.T) {
+	reader, err := Open(testFile("MaxMind-DB-test-decoder.mmdb"))
+	require.NoError(t, err)
+
+	ip := net.ParseIP("::1.1.1.0")
+	var record any
+	err = reader.With(MaxDecodedBytes(10)).Lookup(ip, &record)
+	assert.Equal(t, DecodedSizeLimitError{Limit: 10}, err)
+
+	// The Reader itself is not affected.
+	require.NoError(t, reader.Lookup(ip, &record))
+	checkDecodingToInterface(t, record)
+	require.NoError(t, reader.Close())
+
+	reader, err = Open(testFile("MaxMind-DB-test-decoder.mmdb"), WithMaxDecodedBytes(10))
+	require.NoError(t, err)
+
+	record = nil
+	network, ok, err := reader.With(MaxDecodedBytes(0)).LookupNetwork(ip, &record)
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, "::101:100/120", network.String())
+	checkDecodingToInterface(t, record)
+
+	offset, err := reader.LookupOffset(ip)
+	require.NoError(t, err)
+	err = reader.With(MaxDecodedBytes(10), MaxDecodedBytes(1<<20)).Decode(offset, &record)
+	assert.NoError(t, err, "later options override earlier ones")
+	require.NoError(t, reader.Close())
+}
+
+func TestLookuperSoftFailDecode(t *testing.T) {
+	reader, err := Open(testFile("GeoIP2-City-Test-Broken-Double-Format.mmdb"))
+	require.NoError(t, err)
+
+	ip := net.ParseIP("2001:220::")
+	var result map[string]any
+	err = reader.Lookup(ip, &result)
+	var fieldErrors FieldErrors
+	assert.Error(t, err)
+	assert.False(t, errors.As(err, &fieldErrors))
+
+	result = nil
+	err = reader.With(SoftFailDecode(true)).Lookup(ip, &result)
+	require.ErrorAs(t, err, &fieldErrors)
+	assert.Equal(t, "KR", result["country"].(map[string]any)["iso_code"])
+	require.NoError(t, reader.Close())
+
+	reader, err = Open(
+		testFile("GeoIP2-City-Test-Broken-Double-Format.mmdb"),
+		WithSoftFailDecode(),
+	)
+	require.NoError(t, err)
+
+	err = reader.With(SoftFailDecode(false)).Lookup(ip, &result)
+	assert.Error(t, err)
+	assert.False(t, errors.As(err, &fieldErrors))
+	require.NoError(t, reader.Close())
+}
+
+func TestLookuperClosed(t *testing.T) {
+	reader, err := Open(testFile("MaxMind-DB-test-decoder.mmdb"))
+	require.NoError(t, err)
+	l := reader.With(SoftFailDecode(true))
+	require.NoError(t, reader.Close())
+
+	var record any
+	assert.EqualError(t, l.Lookup(net.ParseIP("::1.1.1.0"), &record), "cannot call Lookup on a closed database")
+	assert.EqualError(t, l.Decode(0, &record), "cannot call Decode on a closed database")
+}
+
+func TestLookuperAllocations(t *testing.T) {
+	reader, err := Open(testFile("GeoIP2-City-Test.mmdb"))
+	require.NoError(t, err)
+
+	ip := net.ParseIP("81.2.69.160")
+	var result struct {
+		Location struct {
+			Latitude float64 `maxminddb:"latitude"`
+		} `maxminddb:"location"`
+	}
+	allocs := testing.AllocsPerRun(100, func() {
+		require.NoError(t, reader.Lookup(ip, &result))
+	})
+	withAllocs := testing.AllocsPerRun(100, func() {
+		require.NoError(t, reader.With().Lookup(ip, &result))
+	})
+	assert.Equal(t, allocs, withAllocs)
+
+	require.NoError(t, reader.Close())
+}
@@ -0,0 +1,171 @@
+package maxminddb
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+
+	"github.com/3JoB/go-reflect"
+)
+
+// databaseTypePrefixes are the product prefixes removed from the database
+// type of a database to get its kind.
+var databaseTypePrefixes = []string{"GeoIP2-", "GeoLite2-", "GeoIP-"}
+
+// DatabaseKind returns the kind of a database with the given database type,
+// i.e., its type without a "GeoIP2-", "GeoLite2-" or "GeoIP-" product prefix.
+// For example, the kind of both "GeoIP2-City" and "GeoLite2-City" databases
+// is "City".
+func DatabaseKind(databaseType string) string {
+	for _, prefix := range databaseTypePrefixes {
+		if kind, ok := strings.CutPrefix(databaseType, prefix); ok {
+			return kind
+		}
+	}
+	return databaseType
+}
+
+// Manager routes lookups to one of several databases depending on the type
+// of the result, e.g., to look up the City, ASN and Anonymous-IP records of
+// an address with a single call to Enrich. It holds at most one database of
+// each kind, as returned by DatabaseKind.
+//
+// All of the methods on Manager are thread-safe.
+type Manager struct {
+	readers map[string]*Reader
+	targets map[reflect.Type]string
+	mu      sync.RWMutex
+}
+
+// NewManager returns a Manager for readers, each of which must be of a
+// different kind. The Manager does not take ownership of the readers; they
+// must still be closed by the caller, e.g., after replacing them with Swap.
+func NewManager(readers ...*Reader) (*Manager, error) {
+	m := &Manager{
+		readers: make(map[string]*Reader, len(readers)),
+		targets: map[reflect.Type]string{},
+	}
+	for _, reader := range readers {
+		if reader == nil {
+			return nil, errors.New("maxminddb: nil Reader passed to NewManager")
+		}
+		kind := DatabaseKind(reader.Metadata.DatabaseType)
+		if _, ok := m.readers[kind]; ok {
+			return nil, fmt.Errorf("maxminddb: more than one %s database passed to NewManager", kind)
+		}
+		m.readers[kind] = reader
+	}
+	return m, nil
+}
+
+// Register routes targets of the type of target, or of the type target
+// points to, to the database of the given kind. A database type may be
+// passed instead of a kind. Registrations take precedence over the
+// database a type names in its struct tags.
+//
+// A struct type names its database with the "database" option in the
+// maxminddb tag of a blank field:
+//
+//	type ASN struct {
+//		_ struct{} `maxminddb:",database=ASN"`
+//
+//		AutonomousSystemNumber uint `maxminddb:"autonomous_system_number"`
+//	}
+func (m *Manager) Register(target any, kind string) {
+	typ := reflect.TypeOf(target)
+	if typ != nil && typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.targets[typ] = DatabaseKind(kind)
+}
+
+// Swap replaces the database of the same kind as reader with reader, e.g.,
+// after a database update, or adds reader if the Manager has no database of
+// its kind. It returns the previous database of the kind or nil. Swap waits
+// for lookups in progress to finish, so the previous database may be closed
+// as soon as it returns.
+func (m *Manager) Swap(reader *Reader) *Reader {
+	kind := DatabaseKind(reader.Metadata.DatabaseType)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	previous := m.readers[kind]
+	m.readers[kind] = reader
+	return previous
+}
+
+// Reader returns the database of the given kind or nil if the Manager has
+// none. A database type may be passed instead of a kind.
+func (m *Manager) Reader(kind string) *Reader {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.readers[DatabaseKind(kind)]
+}
+
+// Enrich looks up ip in the database each of targets is routed to, as
+// described by Register, and stores the record in the value pointed to by
+// the target. All of the targets are looked up even if some of the lookups
+// fail, and the errors are joined. A MissingDatabaseError is returned for
+// targets routed to a kind of database the Manager does not have.
+func (m *Manager) Enrich(ip net.IP, targets ...any) error {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var errs []error
+	for _, target := range targets {
+		kind, ok := m.targetKind(target)
+		if !ok {
+			errs = append(errs, fmt.Errorf("maxminddb: no database is registered for %T", target))
+			continue
+		}
+		reader, ok := m.readers[kind]
+		if !ok {
+			errs = append(errs, MissingDatabaseError{Kind: kind, Target: fmt.Sprintf("%T", target)})
+			continue
+		}
+		if err := reader.Lookup(ip, target); err != nil {
+			errs = append(errs, fmt.Errorf("maxminddb: looking up %T in the %s database: %w", target, kind, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// targetKind returns the kind of database target is routed to. m.mu must be
+// held.
+func (m *Manager) targetKind(target any) (string, bool) {
+	typ := reflect.TypeOf(target)
+	if typ == nil || typ.Kind() != reflect.Ptr {
+		return "", false
+	}
+	typ = typ.Elem()
+	if kind, ok := m.targets[typ]; ok {
+		return kind, true
+	}
+	return taggedDatabaseKind(typ)
+}
+
+// taggedDatabaseKind returns the kind of database named by the "database"
+// option in the tag of a blank field of the struct type typ.
+func taggedDatabaseKind(typ reflect.Type) (string, bool) {
+	if typ.Kind() != reflect.Struct {
+		return "", false
+	}
+	for i := 0; i < typ.NumField(); i++ {
+		field := typ.Field(i)
+		if field.Name != "_" {
+			continue
+		}
+		_, options, _ := strings.Cut(field.Tag.Get("maxminddb"), ",")
+		for options != "" {
+			var option string
+			option, options, _ = strings.Cut(options, ",")
+			if kind, ok := strings.CutPrefix(option, "database="); ok {
+				return DatabaseKind(kind), true
+			}
+		}
+	}
+	return "", false
+}
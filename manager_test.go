@@ -0,0 +1,104 @@
+package maxminddb
+
+import (
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type managerCity struct {
+	_ struct{} `maxminddb:",database=GeoIP2-City"`
+
+	Country struct {
+		ISOCode string `maxminddb:"iso_code"`
+	} `maxminddb:"country"`
+}
+
+type managerISP struct {
+	AutonomousSystemNumber uint `maxminddb:"autonomous_system_number"`
+}
+
+type managerASN struct {
+	_ struct{} `maxminddb:",database=ASN"`
+
+	AutonomousSystemNumber uint `maxminddb:"autonomous_system_number"`
+}
+
+func TestDatabaseKind(t *testing.T) {
+	assert.Equal(t, "City", DatabaseKind("GeoIP2-City"))
+	assert.Equal(t, "City", DatabaseKind("GeoLite2-City"))
+	assert.Equal(t, "Anonymous-IP", DatabaseKind("GeoIP2-Anonymous-IP"))
+	assert.Equal(t, "Test", DatabaseKind("Test"))
+}
+
+func TestManagerEnrich(t *testing.T) {
+	city, err := Open(testFile("GeoIP2-City-Test.mmdb"))
+	require.NoError(t, err)
+	defer city.Close()
+	isp, err := Open(testFile("GeoIP2-ISP-Test.mmdb"))
+	require.NoError(t, err)
+	defer isp.Close()
+
+	m, err := NewManager(city, isp)
+	require.NoError(t, err)
+	m.Register(managerISP{}, "GeoIP2-ISP")
+	assert.Same(t, city, m.Reader("City"))
+	assert.Same(t, isp, m.Reader("GeoIP2-ISP"))
+
+	var cityResult managerCity
+	var ispResult managerISP
+	require.NoError(t, m.Enrich(net.ParseIP("89.160.20.128"), &cityResult, &ispResult))
+	assert.Equal(t, "SE", cityResult.Country.ISOCode)
+	assert.Zero(t, ispResult.AutonomousSystemNumber)
+
+	require.NoError(t, m.Enrich(net.ParseIP("1.128.0.0"), &ispResult))
+	assert.Equal(t, uint(1221), ispResult.AutonomousSystemNumber)
+
+	_, err = NewManager(city, city)
+	assert.Error(t, err)
+}
+
+func TestManagerSwap(t *testing.T) {
+	m, err := NewManager()
+	require.NoError(t, err)
+
+	first, err := Open(testFile("GeoIP2-City-Test.mmdb"))
+	require.NoError(t, err)
+	defer first.Close()
+	second, err := Open(testFile("GeoIP2-City-Test.mmdb"))
+	require.NoError(t, err)
+	defer second.Close()
+
+	assert.Nil(t, m.Swap(first))
+	assert.Same(t, first, m.Swap(second))
+	assert.Same(t, second, m.Reader("City"))
+}
+
+func TestManagerEnrichErrors(t *testing.T) {
+	m, err := NewManager()
+	require.NoError(t, err)
+
+	var asn managerASN
+	var isp managerISP
+	err = m.Enrich(net.ParseIP("1.128.0.0"), &asn, &isp)
+	require.ErrorIs(t, err, ErrMissingDatabase)
+	var missing MissingDatabaseError
+	require.ErrorAs(t, err, &missing)
+	assert.Equal(t, MissingDatabaseError{Kind: "ASN", Target: "*maxminddb.managerASN"}, missing)
+	assert.ErrorContains(t, err, "no database is registered for *maxminddb.managerISP")
+
+	m.Register(&isp, "ISP")
+	err = m.Enrich(net.ParseIP("1.128.0.0"), &isp)
+	require.ErrorAs(t, err, &missing)
+	assert.Equal(t, "ISP", missing.Kind)
+
+	// A registration takes precedence over the struct tag.
+	m.Register(managerASN{}, "GeoLite2-City")
+	err = m.Enrich(net.ParseIP("1.128.0.0"), &asn)
+	require.ErrorAs(t, err, &missing)
+	assert.Equal(t, "City", missing.Kind)
+
+	assert.Error(t, m.Enrich(net.ParseIP("1.128.0.0"), asn), "targets must be pointers")
+}
@@ -0,0 +1,73 @@
+package maxminddb
+
+import (
+	"errors"
+	"io"
+	"os"
+)
+
+// metadataMaxSize is the maximum size of the metadata section, including
+// the marker, allowed by the MaxMind DB format.
+const metadataMaxSize = 128 * 1024
+
+// ReadMetadata returns the metadata of the database at path without opening
+// it. Only the end of the file, which holds the metadata, is read, so this
+// is much cheaper than Open for large databases, e.g., to check the build
+// time of a database before loading it.
+//
+// The metadata is validated as it is by Open, and the WithMaxDatabaseSize,
+// WithMinimumBuildTime, WithMaxAge, WithStalenessHook and WithWarningHook
+// options are applied. Other options have no effect. The metadata section
+// must be within the last 128 KiB of the file, as the format requires.
+func ReadMetadata(path string, options ...ReaderOption) (Metadata, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return Metadata{}, err
+	}
+	defer f.Close()
+
+	stats, err := f.Stat()
+	if err != nil {
+		return Metadata{}, err
+	}
+	return ReadMetadataFrom(f, stats.Size(), options...)
+}
+
+// ReadMetadataFrom is like ReadMetadata, but reads the metadata from the
+// last bytes of src, which holds a database of size bytes.
+func ReadMetadataFrom(src io.ReaderAt, size int64, options ...ReaderOption) (Metadata, error) {
+	var opts readerOptions
+	for _, option := range options {
+		option(&opts)
+	}
+	if err := opts.checkDatabaseSize(size); err != nil {
+		return Metadata{}, err
+	}
+	if size < 0 {
+		return Metadata{}, errors.New("maxminddb: negative database size")
+	}
+
+	offset := max(size-metadataMaxSize, 0)
+	tail := make([]byte, size-offset)
+	if _, err := src.ReadAt(tail, offset); err != nil && !errors.Is(err, io.EOF) {
+		return Metadata{}, err
+	}
+	metadata, _, _, err := opts.parseMetadata(tail, uint(offset))
+	return metadata, err
+}
+
+// MetadataFromBytes is like ReadMetadata, but reads the metadata from buffer,
+// which holds a MaxMind DB file. The Metadata does not refer to buffer.
+func MetadataFromBytes(buffer []byte, options ...ReaderOption) (Metadata, error) {
+	var opts readerOptions
+	for _, option := range options {
+		option(&opts)
+	}
+	if err := opts.checkDatabaseSize(int64(len(buffer))); err != nil {
+		return Metadata{}, err
+	}
+
+	offset := max(len(buffer)-metadataMaxSize, 0)
+	metadata, _, _, err := opts.parseMetadata(buffer[offset:], uint(offset))
+	return metadata, err
+}
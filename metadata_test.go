@@ -0,0 +1,94 @@
+package maxminddb
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReadMetadata(t *testing.T) {
+	for _, recordSize := range []uint{24, 28, 32} {
+		for _, ipVersion := range []uint{4, 6} {
+			fileName := testFile(
+				fmt.Sprintf("MaxMind-DB-test-ipv%d-%d.mmdb", ipVersion, recordSize),
+			)
+			t.Run(fileName, func(t *testing.T) {
+				reader, err := Open(fileName)
+				require.NoError(t, err)
+				defer reader.Close()
+
+				metadata, err := ReadMetadata(fileName)
+				require.NoError(t, err)
+				assert.Equal(t, reader.Metadata, metadata)
+
+				buffer, err := os.ReadFile(fileName)
+				require.NoError(t, err)
+				metadata, err = MetadataFromBytes(buffer)
+				require.NoError(t, err)
+				assert.Equal(t, reader.Metadata, metadata)
+
+				metadata, err = ReadMetadataFrom(bytes.NewReader(buffer), int64(len(buffer)))
+				require.NoError(t, err)
+				assert.Equal(t, reader.Metadata, metadata)
+			})
+		}
+	}
+}
+
+func TestReadMetadataValidation(t *testing.T) {
+	_, err := ReadMetadata(testFile("GeoIP2-City-Test-Invalid-Node-Count.mmdb"))
+	assert.Equal(t, newInvalidDatabaseError("the MaxMind DB contains invalid metadata"), err)
+
+	_, err = ReadMetadata(
+		testFile("GeoIP2-City-Test.mmdb"),
+		WithMinimumBuildTime(time.Now()),
+	)
+	var tooOld DatabaseTooOldError
+	assert.ErrorAs(t, err, &tooOld)
+
+	_, err = ReadMetadata(testFile("GeoIP2-City-Test.mmdb"), WithMaxDatabaseSize(1))
+	var tooLarge DatabaseTooLargeError
+	assert.ErrorAs(t, err, &tooLarge)
+
+	_, err = ReadMetadata("file-does-not-exist.mmdb")
+	assert.ErrorIs(t, err, os.ErrNotExist)
+}
+
+func TestMetadataFromBytesInvalid(t *testing.T) {
+	_, err := MetadataFromBytes([]byte("not a database"))
+	assert.Equal(t, newInvalidDatabaseError("error opening database: invalid MaxMind DB file"), err)
+
+	_, err = ReadMetadataFrom(bytes.NewReader(nil), 0)
+	assert.Equal(t, newInvalidDatabaseError("error opening database: invalid MaxMind DB file"), err)
+
+	// The metadata must be within the last 128 KiB of the database.
+	buffer := append([]byte("\xAB\xCD\xEFMaxMind.com\xe0"), make([]byte, metadataMaxSize)...)
+	_, err = MetadataFromBytes(buffer)
+	assert.Equal(t, newInvalidDatabaseError("error opening database: invalid MaxMind DB file"), err)
+}
+
+func BenchmarkReadMetadata(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		if _, err := ReadMetadata("GeoLite2-City.mmdb"); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkOpenMetadata(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		db, err := Open("GeoLite2-City.mmdb")
+		if err != nil {
+			b.Fatal(err)
+		}
+		_ = db.Metadata
+		if err := db.Close(); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -1,9 +1,11 @@
-//go:build !windows && !appengine && !plan9 && !js && !wasip1 && !wasi
-// +build !windows,!appengine,!plan9,!js,!wasip1,!wasi
+//go:build unix && !appengine
+// +build unix,!appengine
 
 package maxminddb
 
 import (
+	"errors"
+
 	"golang.org/x/sys/unix"
 )
 
@@ -14,3 +16,14 @@ func mmap(fd, length int) (data []byte, err error) {
 func munmap(b []byte) (err error) {
 	return unix.Munmap(b)
 }
+
+// mmapUnsupported returns true if err, returned by mmap, indicates that the
+// file cannot be memory mapped rather than that it cannot be read, e.g., as
+// it is on a file system without memory map support or is empty.
+func mmapUnsupported(err error) bool {
+	return errors.Is(err, unix.ENODEV) ||
+		errors.Is(err, unix.EINVAL) ||
+		errors.Is(err, unix.ENOTSUP) ||
+		errors.Is(err, unix.EOPNOTSUPP) ||
+		errors.Is(err, unix.ENOSYS)
+}
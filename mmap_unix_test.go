@@ -0,0 +1,46 @@
+//go:build unix && !appengine
+// +build unix,!appengine
+
+package maxminddb
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/sys/unix"
+)
+
+func TestOpenLoadMode(t *testing.T) {
+	reader, err := Open(testFile("MaxMind-DB-test-ipv4-24.mmdb"))
+	require.NoError(t, err)
+	assert.Equal(t, LoadModeMmap, reader.LoadMode())
+	require.NoError(t, reader.Close())
+}
+
+func TestOpenFallsBackToHeap(t *testing.T) {
+	defer func(f func(int, int) ([]byte, error)) { mapFile = f }(mapFile)
+
+	for _, errno := range []unix.Errno{unix.ENODEV, unix.EINVAL, unix.ENOTSUP, unix.ENOSYS} {
+		t.Run(errno.Error(), func(t *testing.T) {
+			mapFile = func(int, int) ([]byte, error) {
+				return nil, fmt.Errorf("mmap: %w", errno)
+			}
+
+			reader, err := Open(testFile("MaxMind-DB-test-ipv4-24.mmdb"))
+			require.NoError(t, err)
+			assert.Equal(t, LoadModeHeap, reader.LoadMode())
+			checkMetadata(t, reader, 4, 24)
+			checkIpv4(t, reader)
+			require.NoError(t, reader.Close())
+		})
+	}
+
+	mapFile = func(int, int) ([]byte, error) {
+		return nil, unix.ENOMEM
+	}
+	_, err := Open(testFile("MaxMind-DB-test-ipv4-24.mmdb"))
+	assert.True(t, errors.Is(err, unix.ENOMEM))
+}
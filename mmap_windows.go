@@ -1,5 +1,5 @@
-//go:build windows && !appengine
-// +build windows,!appengine
+//go:build windows && !appengine && !purego
+// +build windows,!appengine,!purego
 
 package maxminddb
 
@@ -84,3 +84,10 @@ func munmap(b []byte) (err error) {
 	e := windows.CloseHandle(windows.Handle(handle))
 	return os.NewSyscallError("CloseHandle", e)
 }
+
+// mmapUnsupported returns true if err, returned by mmap, indicates that the
+// file cannot be memory mapped rather than that it cannot be read. Errors from
+// mmap are always returned on Windows.
+func mmapUnsupported(err error) bool {
+	return false
+}
@@ -0,0 +1,92 @@
+package maxminddb
+
+import (
+	"math/bits"
+	"net"
+	"sync/atomic"
+)
+
+// negativeCache is a bounded, direct-mapped cache of networks that have no
+// record in the database. Networks are keyed by their address masked to
+// their prefix length, so the empty networks within a larger prefix have
+// slots of their own. Each slot holds at most one network and is replaced
+// when another empty network maps to the same slot. Slots are updated
+// atomically so the cache may be shared across goroutines without locking.
+type negativeCache struct {
+	slots []atomic.Pointer[negativeEntry]
+	// prefixLengths is a bitmap of the prefix lengths of the networks that
+	// have been added. A lookup probes the slot of each of them.
+	prefixLengths [3]atomic.Uint64
+}
+
+type negativeEntry struct {
+	ip           [net.IPv6len]byte
+	ipLen        int
+	prefixLength int
+}
+
+func newNegativeCache(size int) *negativeCache {
+	return &negativeCache{slots: make([]atomic.Pointer[negativeEntry], size)}
+}
+
+// slot returns the slot of the network of prefixLength bits containing ip.
+func (c *negativeCache) slot(ip net.IP, prefixLength int) *atomic.Pointer[negativeEntry] {
+	// FNV-1a over the address masked to the prefix length.
+	h := uint32(2166136261)
+	n := prefixLength >> 3
+	for _, b := range ip[:n] {
+		h ^= uint32(b)
+		h *= 16777619
+	}
+	if rem := prefixLength % 8; rem != 0 {
+		h ^= uint32(ip[n] & (0xff << (8 - rem)))
+		h *= 16777619
+	}
+	h ^= uint32(prefixLength)<<8 | uint32(len(ip))
+	h *= 16777619
+	return &c.slots[h%uint32(len(c.slots))]
+}
+
+// get returns the prefix length of the cached empty network containing ip,
+// if there is one.
+func (c *negativeCache) get(ip net.IP) (int, bool) {
+	for i := range c.prefixLengths {
+		for lengths := c.prefixLengths[i].Load(); lengths != 0; lengths &= lengths - 1 {
+			prefixLength := i*64 + bits.TrailingZeros64(lengths)
+			if prefixLength > len(ip)*8 {
+				return 0, false
+			}
+			e := c.slot(ip, prefixLength).Load()
+			if e != nil && e.ipLen == len(ip) && e.prefixLength == prefixLength &&
+				prefixEqual(e.ip[:e.ipLen], ip, uint(prefixLength)) {
+				return prefixLength, true
+			}
+		}
+	}
+	return 0, false
+}
+
+// add records that the network of prefixLength bits containing ip has no
+// record in the database.
+func (c *negativeCache) add(ip net.IP, prefixLength int) {
+	e := &negativeEntry{ipLen: len(ip), prefixLength: prefixLength}
+	copy(e.ip[:], ip)
+	c.slot(ip, prefixLength).Store(e)
+	bit := uint64(1) << (prefixLength % 64)
+	lengths := &c.prefixLengths[prefixLength/64]
+	for {
+		old := lengths.Load()
+		if old&bit != 0 || lengths.CompareAndSwap(old, old|bit) {
+			return
+		}
+	}
+}
+
+func (c *negativeCache) clear() {
+	for i := range c.slots {
+		c.slots[i].Store(nil)
+	}
+	for i := range c.prefixLengths {
+		c.prefixLengths[i].Store(0)
+	}
+}
@@ -0,0 +1,165 @@
+package maxminddb
+
+import (
+	"errors"
+	"fmt"
+	"net/netip"
+)
+
+// neighborLeaf is a record found by Neighbors: the network with the first
+// bits bits of ip and the record's pointer in node.
+type neighborLeaf struct {
+	ip      [16]byte
+	bits    uint
+	node    uint
+	pointer uint
+}
+
+// Neighbors returns the closest networks with a record before and after the
+// network containing ip, along with the offsets of their records, e.g., to
+// judge whether the network of an address that is not found is a gap in the
+// data or unassigned space. prev is the network with a record that ends
+// closest below the network containing ip and next the one that starts
+// closest above it. If there is no such network, the prefix is the zero
+// netip.Prefix and the offset is NotFound.
+//
+// IPv4 addresses, including IPv4-mapped IPv6 addresses, are looked up in
+// the IPv4 part of the database, and their neighbors are IPv4 networks. For
+// IPv6 addresses, aliases of the IPv4 subtree such as ::ffff:0:0/96 are
+// skipped. The zone of ip, if any, is ignored.
+func (r *Reader) Neighbors(ip netip.Addr) (prev, next netip.Prefix, prevOffset, nextOffset uintptr, err error) {
+	if r.buffer == nil {
+		return prev, next, NotFound, NotFound, errors.New("cannot call Neighbors on a closed database")
+	}
+	if !ip.IsValid() {
+		return prev, next, NotFound, NotFound, errors.New("invalid IP address passed to Neighbors")
+	}
+	ip = ip.Unmap().WithZone("")
+	if ip.Is6() && r.Metadata.IPVersion == 4 {
+		return prev, next, NotFound, NotFound, fmt.Errorf(
+			"error looking up '%s': you attempted to look up an IPv6 address in an IPv4-only database",
+			ip,
+		)
+	}
+
+	// IPv4 addresses are handled as the last 32 bits of the IPv6 address
+	// space, starting at the root of the IPv4 subtree.
+	root, start := uint(0), uint(0)
+	if ip.Is4() {
+		start = 96
+		if r.Metadata.IPVersion == 6 {
+			if r.ipv4StartBitDepth != 96 {
+				// The database has no IPv4 subtree, so the IPv4 address
+				// space is part of a single network.
+				return prev, next, NotFound, NotFound, nil
+			}
+			root = r.ipv4Start
+		}
+	}
+	address := ip.As16()
+
+	// The path holds the nodes from the root down to the record for ip.
+	nodeCount := r.Metadata.NodeCount
+	var path []uint
+	node := root
+	for depth := start; node < nodeCount; depth++ {
+		if depth >= 128 {
+			return prev, next, NotFound, NotFound, newInvalidDatabaseError(
+				"invalid node in search tree",
+			)
+		}
+		path = append(path, node)
+		if addressBit(&address, depth) == 0 {
+			node = r.nodeReader.readLeft(node * r.nodeOffsetMult)
+		} else {
+			node = r.nodeReader.readRight(node * r.nodeOffsetMult)
+		}
+	}
+
+	prevOffset, nextOffset = NotFound, NotFound
+	for _, right := range []bool{false, true} {
+		for i := len(path) - 1; i >= 0; i-- {
+			depth := start + uint(i)
+			// Only the subtrees on the other side of the path are before or
+			// after the network of ip.
+			if (addressBit(&address, depth) == 1) == right {
+				continue
+			}
+			sibling := address
+			clearBitsFrom(sibling[:], depth)
+			offset := path[i] * r.nodeOffsetMult
+			child := r.nodeReader.readLeft(offset)
+			if right {
+				setAddressBit(&sibling, depth)
+				child = r.nodeReader.readRight(offset)
+			}
+
+			leaf, ok, err := r.edgeRecord(child, path[i], sibling, depth+1, !right)
+			if err != nil {
+				return netip.Prefix{}, netip.Prefix{}, NotFound, NotFound, err
+			}
+			if !ok {
+				continue
+			}
+			recordOffset, err := r.resolveDataPointer(leaf.node, leaf.pointer)
+			if err != nil {
+				return netip.Prefix{}, netip.Prefix{}, NotFound, NotFound, err
+			}
+			prefix := netip.PrefixFrom(netip.AddrFrom16(leaf.ip), int(leaf.bits))
+			if ip.Is4() {
+				prefix = netip.PrefixFrom(prefix.Addr().Unmap(), int(leaf.bits-96))
+			}
+			if right {
+				next, nextOffset = prefix, recordOffset
+			} else {
+				prev, prevOffset = prefix, recordOffset
+			}
+			break
+		}
+	}
+	return prev, next, prevOffset, nextOffset, nil
+}
+
+// edgeRecord returns the last record in the subtree at node if last is true
+// and the first one otherwise. node is reached from parent after the first
+// depth bits of ip. Empty parts of the tree and aliases of the IPv4 subtree
+// are skipped.
+func (r *Reader) edgeRecord(node, parent uint, ip [16]byte, depth uint, last bool) (neighborLeaf, bool, error) {
+	nodeCount := r.Metadata.NodeCount
+	switch {
+	case node == nodeCount:
+		return neighborLeaf{}, false, nil
+	case node > nodeCount:
+		return neighborLeaf{ip: ip, bits: depth, node: parent, pointer: node}, true, nil
+	case depth >= 128:
+		return neighborLeaf{}, false, newInvalidDatabaseError("invalid node in search tree")
+	case r.ipv4StartBitDepth == 96 && node == r.ipv4Start && !isInIPv4Subtree(ip[:]):
+		return neighborLeaf{}, false, nil
+	}
+
+	offset := node * r.nodeOffsetMult
+	children := [2]uint{r.nodeReader.readLeft(offset), r.nodeReader.readRight(offset)}
+	for i := 0; i < 2; i++ {
+		bit := i
+		if last {
+			bit = 1 - i
+		}
+		child := ip
+		if bit == 1 {
+			setAddressBit(&child, depth)
+		}
+		leaf, ok, err := r.edgeRecord(children[bit], node, child, depth+1, last)
+		if err != nil || ok {
+			return leaf, ok, err
+		}
+	}
+	return neighborLeaf{}, false, nil
+}
+
+func addressBit(ip *[16]byte, i uint) byte {
+	return (ip[i>>3] >> (7 - i%8)) & 1
+}
+
+func setAddressBit(ip *[16]byte, i uint) {
+	ip[i>>3] |= 1 << (7 - i%8)
+}
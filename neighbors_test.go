@@ -0,0 +1,100 @@
+package maxminddb
+
+import (
+	"net/netip"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNeighbors(t *testing.T) {
+	for _, file := range []string{"MaxMind-DB-test-ipv4-24.mmdb", "MaxMind-DB-test-mixed-24.mmdb"} {
+		reader, err := Open(testFile(file))
+		require.NoError(t, err)
+
+		tests := []struct {
+			ip   string
+			prev string
+			next string
+		}{
+			{ip: "0.0.0.0", next: "1.1.1.1/32"},
+			{ip: "1.1.1.0", next: "1.1.1.1/32"},
+			{ip: "1.1.1.1", next: "1.1.1.2/31"},
+			{ip: "1.1.1.5", prev: "1.1.1.2/31", next: "1.1.1.8/29"},
+			{ip: "1.1.1.33", prev: "1.1.1.32/32"},
+			{ip: "::ffff:1.1.1.33", prev: "1.1.1.32/32"},
+			{ip: "255.255.255.255", prev: "1.1.1.32/32"},
+		}
+		for _, test := range tests {
+			prev, next, prevOffset, nextOffset, err := reader.Neighbors(netip.MustParseAddr(test.ip))
+			require.NoError(t, err, test.ip)
+			assertNeighbor(t, reader, test.prev, prev, prevOffset)
+			assertNeighbor(t, reader, test.next, next, nextOffset)
+		}
+
+		require.NoError(t, reader.Close())
+	}
+}
+
+func TestNeighborsIPv6(t *testing.T) {
+	reader, err := Open(testFile("MaxMind-DB-test-mixed-24.mmdb"))
+	require.NoError(t, err)
+
+	tests := []struct {
+		ip   string
+		prev string
+		next string
+	}{
+		// The IPv4 subtree, ::/96, is part of the IPv6 address space, but
+		// its aliases, such as ::ffff:0:0/96, are skipped.
+		{ip: "::1:ffff:fffe", prev: "::101:120/128", next: "::1:ffff:ffff/128"},
+		{ip: "::2:0:48%eth0", prev: "::2:0:0/122", next: "::2:0:50/125"},
+		{ip: "::2:0:60", prev: "::2:0:58/127"},
+		{ip: "ffff::", prev: "::2:0:58/127"},
+	}
+	for _, test := range tests {
+		prev, next, prevOffset, nextOffset, err := reader.Neighbors(netip.MustParseAddr(test.ip))
+		require.NoError(t, err, test.ip)
+		assertNeighbor(t, reader, test.prev, prev, prevOffset)
+		assertNeighbor(t, reader, test.next, next, nextOffset)
+	}
+
+	_, _, _, _, err = reader.Neighbors(netip.Addr{})
+	assert.EqualError(t, err, "invalid IP address passed to Neighbors")
+
+	require.NoError(t, reader.Close())
+
+	_, _, _, _, err = reader.Neighbors(netip.MustParseAddr("1.1.1.1"))
+	assert.EqualError(t, err, "cannot call Neighbors on a closed database")
+}
+
+func TestNeighborsIPv6InIPv4Database(t *testing.T) {
+	reader, err := Open(testFile("MaxMind-DB-test-ipv4-24.mmdb"))
+	require.NoError(t, err)
+
+	_, _, _, _, err = reader.Neighbors(netip.MustParseAddr("2001::"))
+	assert.EqualError(
+		t,
+		err,
+		"error looking up '2001::': you attempted to look up an IPv6 address in an IPv4-only database",
+	)
+
+	require.NoError(t, reader.Close())
+}
+
+// assertNeighbor checks a network returned by Neighbors against expected,
+// with "" standing for no network, and checks that offset is the offset of
+// its record.
+func assertNeighbor(t *testing.T, reader *Reader, expected string, network netip.Prefix, offset uintptr) {
+	t.Helper()
+	if expected == "" {
+		assert.False(t, network.IsValid(), "unexpected network %s", network)
+		assert.Equal(t, NotFound, offset)
+		return
+	}
+	assert.Equal(t, expected, network.String())
+	recordOffset, err := reader.LookupOffset(network.Addr().AsSlice())
+	require.NoError(t, err)
+	assert.Equal(t, recordOffset, offset, expected)
+}
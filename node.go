@@ -1,10 +1,50 @@
 package maxminddb
 
+import "net"
+
+// The records in the search tree are stored in network byte order. The
+// readers below assemble them a byte at a time rather than loading machine
+// words from the buffer so that the result does not depend on the byte
+// order of the host. This also keeps the reads alignment-safe: nodes start
+// at arbitrary byte offsets, e.g., every 7 bytes for 28-bit records, and
+// unaligned word loads fault on strict-alignment architectures such as some
+// MIPS and ARMv5 processors. Multi-byte values elsewhere, e.g., floats in the
+// data section, are read with encoding/binary for the same reason.
 type nodeReader interface {
 	readLeft(uint) uint
 	readRight(uint) uint
 }
 
+// treeTraversal follows the bits of ip from bit i to bitCount, starting at
+// node, until it reaches a record that is not a node, i.e., one that is not
+// less than nodeCount. It returns the record and the number of bits
+// followed. Each nodeReader has its own so that the records are read
+// without an interface call per node, which the compiler cannot inline.
+type treeTraversal func(ip net.IP, node, i, bitCount, nodeCount uint) (uint, uint)
+
+// newNodeReader returns the reader of the nodes in buffer, whose records are
+// recordSize bits long, and its treeTraversal. It returns false if there are
+// no nodes of that size.
+func newNodeReader(buffer []byte, recordSize uint) (nodeReader, treeTraversal, bool) {
+	switch recordSize {
+	case 24:
+		n := nodeReader24{buffer: buffer}
+		return n, n.traverse, true
+	case 28:
+		n := nodeReader28{buffer: buffer}
+		return n, n.traverse, true
+	case 32:
+		n := nodeReader32{buffer: buffer}
+		return n, n.traverse, true
+	}
+	return nil, nil, false
+}
+
+// ipBit returns bit i of ip, counting from its most significant bit.
+func ipBit(ip net.IP, i uint) uint {
+	return uint(ip[i>>3]>>(7-i%8)) & 1
+}
+
 type nodeReader24 struct {
 	buffer []byte
 }
@@ -21,6 +61,20 @@ func (n nodeReader24) readRight(nodeNumber uint) uint {
 		uint(n.buffer[nodeNumber+5])
 }
 
+func (n nodeReader24) traverse(ip net.IP, node, i, bitCount, nodeCount uint) (uint, uint) {
+	for ; i < bitCount && node < nodeCount; i++ {
+		if ipBit(ip, i) == 0 {
+			node = n.readLeft(node * 6)
+		} else {
+			node = n.readRight(node * 6)
+		}
+	}
+	return node, i
+}
+
+// In a 28-bit node, the fourth byte holds the most significant bits of both
+// records: the high nibble belongs to the left record and the low nibble to
+// the right record.
 type nodeReader28 struct {
 	buffer []byte
 }
@@ -39,6 +93,17 @@ func (n nodeReader28) readRight(nodeNumber uint) uint {
 		uint(n.buffer[nodeNumber+6])
 }
 
+func (n nodeReader28) traverse(ip net.IP, node, i, bitCount, nodeCount uint) (uint, uint) {
+	for ; i < bitCount && node < nodeCount; i++ {
+		if ipBit(ip, i) == 0 {
+			node = n.readLeft(node * 7)
+		} else {
+			node = n.readRight(node * 7)
+		}
+	}
+	return node, i
+}
+
 type nodeReader32 struct {
 	buffer []byte
 }
@@ -56,3 +121,14 @@ func (n nodeReader32) readRight(nodeNumber uint) uint {
 		(uint(n.buffer[nodeNumber+6]) << 8) |
 		uint(n.buffer[nodeNumber+7])
 }
+
+func (n nodeReader32) traverse(ip net.IP, node, i, bitCount, nodeCount uint) (uint, uint) {
+	for ; i < bitCount && node < nodeCount; i++ {
+		if ipBit(ip, i) == 0 {
+			node = n.readLeft(node * 8)
+		} else {
+			node = n.readRight(node * 8)
+		}
+	}
+	return node, i
+}
@@ -0,0 +1,232 @@
+package maxminddb
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math/rand"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// The expected values below were computed by hand from the record layouts
+// in the MaxMind DB specification. Every byte in a node is distinct so that
+// reading any byte from the wrong position or in the wrong order changes the
+// result.
+func TestNodeReaders(t *testing.T) {
+	tests := []struct {
+		reader     nodeReader
+		left       []uint
+		right      []uint
+		recordSize uint
+	}{
+		{
+			recordSize: 24,
+			reader: nodeReader24{buffer: []byte{
+				0x01, 0x02, 0x03, 0x04, 0x05, 0x06,
+				0xf1, 0xf2, 0xf3, 0xf4, 0xf5, 0xf6,
+			}},
+			left:  []uint{0x010203, 0xf1f2f3},
+			right: []uint{0x040506, 0xf4f5f6},
+		},
+		{
+			// The middle byte holds the most significant nibble of the left
+			// record in its high bits and that of the right record in its
+			// low bits.
+			recordSize: 28,
+			reader: nodeReader28{buffer: []byte{
+				0x12, 0x34, 0x56, 0xab, 0x78, 0x9a, 0xbc,
+				0xf1, 0xf2, 0xf3, 0x4e, 0xf5, 0xf6, 0xf7,
+			}},
+			left:  []uint{0xa123456, 0x4f1f2f3},
+			right: []uint{0xb789abc, 0xef5f6f7},
+		},
+		{
+			recordSize: 32,
+			reader: nodeReader32{buffer: []byte{
+				0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08,
+				0xf1, 0xf2, 0xf3, 0xf4, 0xf5, 0xf6, 0xf7, 0xf8,
+			}},
+			left:  []uint{0x01020304, 0xf1f2f3f4},
+			right: []uint{0x05060708, 0xf5f6f7f8},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(fmt.Sprintf("%d", test.recordSize), func(t *testing.T) {
+			nodeOffsetMult := test.recordSize / 4
+			for node := range test.left {
+				offset := uint(node) * nodeOffsetMult
+				assert.Equal(t, test.left[node], test.reader.readLeft(offset),
+					"left record of node %d", node)
+				assert.Equal(t, test.right[node], test.reader.readRight(offset),
+					"right record of node %d", node)
+			}
+		})
+	}
+}
+
+// The readers are used at every byte offset: nodes are 6, 7, or 8 bytes
+// long and the buffer itself may start anywhere, e.g., when the database
+// is embedded in a larger file. Shifting the same node to each offset
+// within a machine word checks that no read depends on its alignment.
+func TestNodeReadersUnaligned(t *testing.T) {
+	node := []byte{0x12, 0x34, 0x56, 0xab, 0x78, 0x9a, 0xbc, 0xde}
+	tests := []struct {
+		newReader  func([]byte) nodeReader
+		left       uint
+		right      uint
+		recordSize uint
+	}{
+		{
+			recordSize: 24,
+			newReader:  func(b []byte) nodeReader { return nodeReader24{buffer: b} },
+			left:       0x123456,
+			right:      0xab789a,
+		},
+		{
+			recordSize: 28,
+			newReader:  func(b []byte) nodeReader { return nodeReader28{buffer: b} },
+			left:       0xa123456,
+			right:      0xb789abc,
+		},
+		{
+			recordSize: 32,
+			newReader:  func(b []byte) nodeReader { return nodeReader32{buffer: b} },
+			left:       0x123456ab,
+			right:      0x789abcde,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(fmt.Sprintf("%d", test.recordSize), func(t *testing.T) {
+			for shift := uint(0); shift < 8; shift++ {
+				buffer := make([]byte, 16)
+				copy(buffer[shift:], node[:test.recordSize/4])
+
+				// Reading at the node's offset and reading at offset zero
+				// from a buffer that starts at the node must agree.
+				reader := test.newReader(buffer)
+				assert.Equal(t, test.left, reader.readLeft(shift), "left record at offset %d", shift)
+				assert.Equal(t, test.right, reader.readRight(shift), "right record at offset %d", shift)
+
+				reader = test.newReader(buffer[shift:])
+				assert.Equal(t, test.left, reader.readLeft(0), "left record in buffer at %d", shift)
+				assert.Equal(t, test.right, reader.readRight(0), "right record in buffer at %d", shift)
+			}
+		})
+	}
+}
+
+// writeNode stores the records left and right of node in buffer, the
+// search tree of a database with records of recordSize bits.
+func writeNode(buffer []byte, recordSize, node, left, right uint) {
+	b := buffer[node*recordSize/4:]
+	switch recordSize {
+	case 24:
+		copy(b, []byte{byte(left >> 16), byte(left >> 8), byte(left)})
+		copy(b[3:], []byte{byte(right >> 16), byte(right >> 8), byte(right)})
+	case 28:
+		copy(b, []byte{byte(left >> 16), byte(left >> 8), byte(left)})
+		b[3] = byte(left>>24&0x0f)<<4 | byte(right>>24&0x0f)
+		copy(b[4:], []byte{byte(right >> 16), byte(right >> 8), byte(right)})
+	case 32:
+		binary.BigEndian.PutUint32(b, uint32(left))
+		binary.BigEndian.PutUint32(b[4:], uint32(right))
+	}
+}
+
+// newChainReader returns a Reader whose search tree of records of
+// recordSize bits is a chain of 128 nodes, both records of each pointing to
+// the next, so that every lookup of an IPv6 address visits all of them.
+func newChainReader(recordSize uint) *Reader {
+	const nodeCount = 128
+	buffer := make([]byte, nodeCount*recordSize/4)
+	for node := uint(0); node < nodeCount; node++ {
+		record := node + 1
+		if record == nodeCount {
+			record = nodeCount + dataSectionSeparatorSize
+		}
+		writeNode(buffer, recordSize, node, record, record)
+	}
+
+	nodeReader, traverse, _ := newNodeReader(buffer, recordSize)
+	return &Reader{
+		Metadata:       Metadata{NodeCount: nodeCount, RecordSize: recordSize, IPVersion: 6},
+		nodeReader:     nodeReader,
+		traverse:       traverse,
+		nodeOffsetMult: recordSize / 4,
+	}
+}
+
+func TestChainReader(t *testing.T) {
+	ip := net.ParseIP("2001:db8::1")
+	for _, recordSize := range []uint{24, 28, 32} {
+		reader := newChainReader(recordSize)
+		node, bits := reader.traverseTree(ip, 0, 128)
+		assert.Equal(t, uint(128+dataSectionSeparatorSize), node, "%d-bit records", recordSize)
+		assert.Equal(t, 128, bits, "%d-bit records", recordSize)
+	}
+}
+
+// TestTreeTraversals checks each treeTraversal against following the
+// records one at a time with its nodeReader. The records of the random tree
+// point further down the tree or, for about one in eight, past its nodes,
+// and use all of the bits of the record size.
+func TestTreeTraversals(t *testing.T) {
+	const nodeCount = 1000
+	//nolint:gosec // this is a test
+	r := rand.New(rand.NewSource(0))
+	for _, recordSize := range []uint{24, 28, 32} {
+		buffer := make([]byte, nodeCount*recordSize/4)
+		record := func(node uint) uint {
+			if r.Intn(8) == 0 {
+				return nodeCount + uint(r.Int63n(1<<recordSize-nodeCount))
+			}
+			return node + 1 + uint(r.Intn(nodeCount-int(node)))
+		}
+		for node := uint(0); node < nodeCount; node++ {
+			writeNode(buffer, recordSize, node, record(node), record(node))
+		}
+		nodeReader, traverse, ok := newNodeReader(buffer, recordSize)
+		require.True(t, ok)
+
+		ip := make(net.IP, net.IPv6len)
+		for i := 0; i < 1000; i++ {
+			r.Read(ip)
+			start := uint(r.Intn(nodeCount))
+			first := uint(r.Intn(129))
+			bitCount := first + uint(r.Intn(129-int(first)))
+
+			expectedNode, expectedBits := start, first
+			for ; expectedBits < bitCount && expectedNode < nodeCount; expectedBits++ {
+				offset := expectedNode * recordSize / 4
+				if ipBit(ip, expectedBits) == 0 {
+					expectedNode = nodeReader.readLeft(offset)
+				} else {
+					expectedNode = nodeReader.readRight(offset)
+				}
+			}
+
+			node, bits := traverse(ip, start, first, bitCount, nodeCount)
+			assert.Equal(t, expectedNode, node, "%s from node %d, bits %d to %d", ip, start, first, bitCount)
+			assert.Equal(t, expectedBits, bits, "%s from node %d, bits %d to %d", ip, start, first, bitCount)
+		}
+	}
+}
+
+// BenchmarkTraverseTree measures the traversal of the search tree alone,
+// 128 nodes per lookup, for each record size.
+func BenchmarkTraverseTree(b *testing.B) {
+	ip := net.ParseIP("2001:db8::1")
+	for _, recordSize := range []uint{24, 28, 32} {
+		b.Run(fmt.Sprintf("%d", recordSize), func(b *testing.B) {
+			reader := newChainReader(recordSize)
+			for i := 0; i < b.N; i++ {
+				reader.traverseTree(ip, 0, 128)
+			}
+		})
+	}
+}
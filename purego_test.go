@@ -0,0 +1,43 @@
+package maxminddb
+
+import (
+	"go/build"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestPuregoImports checks that no file built with the purego tag imports
+// unsafe, on every platform that has a file of its own.
+func TestPuregoImports(t *testing.T) {
+	for _, platform := range []struct{ goos, goarch string }{
+		{"linux", "amd64"},
+		{"darwin", "arm64"},
+		{"freebsd", "amd64"},
+		{"illumos", "amd64"},
+		{"aix", "ppc64"},
+		{"windows", "amd64"},
+		{"plan9", "amd64"},
+		{"js", "wasm"},
+		{"wasip1", "wasm"},
+	} {
+		t.Run(platform.goos, func(t *testing.T) {
+			ctx := build.Default
+			ctx.GOOS = platform.goos
+			ctx.GOARCH = platform.goarch
+			ctx.BuildTags = []string{"purego"}
+
+			pkg, err := ctx.ImportDir(".", 0)
+			require.NoError(t, err)
+			assert.NotContains(t, pkg.Imports, "unsafe")
+		})
+	}
+
+	ctx := build.Default
+	ctx.GOOS = "windows"
+	ctx.GOARCH = "amd64"
+	pkg, err := ctx.ImportDir(".", 0)
+	require.NoError(t, err)
+	assert.Contains(t, pkg.Imports, "unsafe", "the test detects unsafe imports")
+}
@@ -0,0 +1,140 @@
+package maxminddb
+
+import (
+	"errors"
+	"net/netip"
+
+	"github.com/3JoB/go-reflect"
+)
+
+// LookupRaw returns the serialized record for ip, e.g., to forward it to
+// another process or to hash it, without decoding it. Addresses are looked
+// up as by LookupNetip. The bool is false if the database has no record for
+// ip.
+//
+// The bytes are self-contained: they are the record as encoded in the data
+// section with every pointer replaced by the value it points to, so they
+// may be decoded with DecodeBytes by any Reader. Values shared by several
+// parts of the record, e.g., a names map referenced from both the city and
+// a subdivision, are therefore repeated. The limit set with
+// WithMaxDecodedBytes applies to the size of the bytes.
+func (r *Reader) LookupRaw(ip netip.Addr) ([]byte, bool, error) {
+	if r.buffer == nil {
+		return nil, false, errors.New("cannot call LookupRaw on a closed database")
+	}
+	offset, _, _, err := r.lookupAddr(ip)
+	if offset == NotFound || err != nil {
+		return nil, false, err
+	}
+	d := r.decoder
+	raw, _, err := d.appendRaw(nil, uint(offset), 0)
+	if err != nil {
+		return nil, false, err
+	}
+	return raw, true, nil
+}
+
+// DecodeBytes decodes a record serialized as by LookupRaw into the value
+// pointed to by result, as Decode does for a record in the database. The
+// decoding options of r, e.g., WithDecodeHook, apply. Unless the database
+// was opened with WithDetachedResults, decoded []byte values may share
+// memory with data.
+//
+// data must not contain pointers, and only its first value is decoded.
+func (r *Reader) DecodeBytes(data []byte, result any) error {
+	rv := reflect.ValueOf(result)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() {
+		return errors.New("result param must be a pointer")
+	}
+	v := rv.Elem()
+
+	d := r.decoder
+	d.buffer = data
+	// The strings of data are not those of the database.
+	d.strings = nil
+	if dser, ok := v.Addr().Interface().(deserializer); ok {
+		_, err := d.decodeToDeserializer(0, dser, 0, false)
+		return err
+	}
+	_, err := d.decode(0, v, 0)
+	if err == nil && len(d.fieldErrors) > 0 {
+		return d.fieldErrors
+	}
+	return err
+}
+
+// RawValue holds a value captured verbatim, e.g., a part of a record to
+// decode later or to forward elsewhere. When a value is decoded into a
+// RawValue, the value is not decoded; instead, its bytes are captured in
+// the self-contained form described for LookupRaw. Decoding a RawValue with
+// DecodeBytes produces the same result as decoding the value in place.
+type RawValue []byte
+
+// UnmarshalMaxMindDB captures the value d is positioned at.
+func (v *RawValue) UnmarshalMaxMindDB(d *Decoder) error {
+	raw, err := d.ReadRaw()
+	if err != nil {
+		return err
+	}
+	*v = raw
+	return nil
+}
+
+// appendRaw appends the value at offset to dst with pointers resolved, as
+// described for Reader.LookupRaw. It returns the offset following the value
+// at offset, i.e., following the pointer rather than its target for a
+// pointer.
+func (d *decoder) appendRaw(dst []byte, offset uint, depth int) ([]byte, uint, error) {
+	if depth > maximumDataStructureDepth {
+		return nil, 0, newInvalidDatabaseError(
+			"exceeded maximum data structure depth; database is likely corrupt",
+		)
+	}
+	typeNum, size, dataOffset, err := d.decodeCtrlData(offset)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	var end uint
+	switch typeNum {
+	case _Pointer:
+		pointer, next, err := d.decodePointer(size, dataOffset)
+		if err != nil {
+			return nil, 0, err
+		}
+		dst, _, err = d.appendRaw(dst, pointer, depth+1)
+		return dst, next, err
+	case _Map, _Slice:
+		n := size
+		if typeNum == _Map {
+			n *= 2
+		}
+		if err := d.checkContainerSize(n, dataOffset); err != nil {
+			return nil, 0, err
+		}
+		if err := d.charge(dataOffset - offset); err != nil {
+			return nil, 0, err
+		}
+		// The control bytes hold the number of entries, which does not
+		// change when the entries are copied.
+		dst = append(dst, d.buffer[offset:dataOffset]...)
+		for i := uint(0); i < n; i++ {
+			dst, dataOffset, err = d.appendRaw(dst, dataOffset, depth+1)
+			if err != nil {
+				return nil, 0, err
+			}
+		}
+		return dst, dataOffset, nil
+	case _Bool:
+		end = dataOffset
+	default:
+		end = dataOffset + size
+		if end > uint(len(d.buffer)) {
+			return nil, 0, newOffsetError()
+		}
+	}
+	if err := d.charge(end - offset); err != nil {
+		return nil, 0, err
+	}
+	return append(dst, d.buffer[offset:end]...), end, nil
+}
@@ -0,0 +1,181 @@
+package maxminddb
+
+import (
+	"net"
+	"net/netip"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLookupRaw(t *testing.T) {
+	reader, err := Open(testFile("GeoIP2-City-Test.mmdb"))
+	require.NoError(t, err)
+	defer reader.Close()
+	other, err := Open(testFile("MaxMind-DB-test-ipv4-24.mmdb"))
+	require.NoError(t, err)
+	defer other.Close()
+
+	for _, ip := range []string{"81.2.69.160", "2.125.160.216", "::ffff:81.2.69.142", "2001:218::"} {
+		raw, ok, err := reader.LookupRaw(netip.MustParseAddr(ip))
+		require.NoError(t, err, ip)
+		require.True(t, ok, ip)
+		assertSelfContained(t, raw)
+
+		var expected any
+		require.NoError(t, reader.Lookup(net.ParseIP(ip), &expected))
+		var record any
+		require.NoError(t, reader.DecodeBytes(raw, &record), ip)
+		assert.Equal(t, expected, record, ip)
+
+		// The bytes do not depend on the database they came from.
+		record = nil
+		require.NoError(t, other.DecodeBytes(raw, &record), ip)
+		assert.Equal(t, expected, record, ip)
+
+		var expectedCity genericCity
+		require.NoError(t, reader.Lookup(net.ParseIP(ip), &expectedCity))
+		var city genericCity
+		require.NoError(t, other.DecodeBytes(raw, &city), ip)
+		assert.Equal(t, expectedCity, city, ip)
+	}
+
+	raw, ok, err := reader.LookupRaw(netip.MustParseAddr("10.0.0.1"))
+	require.NoError(t, err)
+	assert.False(t, ok)
+	assert.Nil(t, raw)
+}
+
+func TestLookupRawDecoder(t *testing.T) {
+	reader, err := Open(testFile("MaxMind-DB-test-decoder.mmdb"))
+	require.NoError(t, err)
+
+	raw, ok, err := reader.LookupRaw(netip.MustParseAddr("::1.1.1.0"))
+	require.NoError(t, err)
+	require.True(t, ok)
+	assertSelfContained(t, raw)
+	var record any
+	require.NoError(t, reader.DecodeBytes(raw, &record))
+	checkDecodingToInterface(t, record)
+
+	d := &testDeserializer{}
+	require.NoError(t, reader.DecodeBytes(raw, d))
+	checkDecodingToInterface(t, d.rv)
+
+	assert.EqualError(t, reader.DecodeBytes(raw, record), "result param must be a pointer")
+	assert.Error(t, reader.DecodeBytes(nil, &record))
+
+	require.NoError(t, reader.Close())
+	_, _, err = reader.LookupRaw(netip.MustParseAddr("::1.1.1.0"))
+	assert.EqualError(t, err, "cannot call LookupRaw on a closed database")
+}
+
+func TestLookupRawMaxDecodedBytes(t *testing.T) {
+	reader, err := Open(testFile("MaxMind-DB-test-decoder.mmdb"), WithMaxDecodedBytes(10))
+	require.NoError(t, err)
+	defer reader.Close()
+
+	_, _, err = reader.LookupRaw(netip.MustParseAddr("::1.1.1.0"))
+	assert.Equal(t, DecodedSizeLimitError{Limit: 10}, err)
+}
+
+// assertSelfContained asserts that raw holds a single value without
+// pointers.
+func assertSelfContained(t *testing.T, raw []byte) {
+	t.Helper()
+	d := decoder{buffer: raw}
+	offset := uint(0)
+	for remaining := 1; remaining > 0; remaining-- {
+		typeNum, size, next, err := d.decodeCtrlData(offset)
+		require.NoError(t, err)
+		require.NotEqual(t, _Pointer, typeNum, "pointer at offset %d", offset)
+		offset = next
+		switch typeNum {
+		case _Map:
+			remaining += 2 * int(size)
+		case _Slice:
+			remaining += int(size)
+		case _Bool:
+		default:
+			offset += size
+		}
+	}
+	assert.Equal(t, uint(len(raw)), offset)
+}
+
+func TestRawValue(t *testing.T) {
+	reader, err := Open(testFile("GeoIP2-City-Test.mmdb"))
+	require.NoError(t, err)
+	defer reader.Close()
+	ip := "2.125.160.216"
+
+	var result struct {
+		City         RawValue   `maxminddb:"city"`
+		Subdivisions []RawValue `maxminddb:"subdivisions"`
+		Country      struct {
+			IsoCode string   `maxminddb:"iso_code"`
+			Names   RawValue `maxminddb:"names"`
+		} `maxminddb:"country"`
+	}
+	require.NoError(t, reader.Lookup(net.ParseIP(ip), &result))
+	assert.Equal(t, "GB", result.Country.IsoCode)
+
+	var expected map[string]any
+	require.NoError(t, reader.Lookup(net.ParseIP(ip), &expected))
+	for _, test := range []struct {
+		raw      RawValue
+		expected any
+	}{
+		{result.City, expected["city"]},
+		{result.Subdivisions[0], expected["subdivisions"].([]any)[0]},
+		{result.Subdivisions[1], expected["subdivisions"].([]any)[1]},
+		{result.Country.Names, expected["country"].(map[string]any)["names"]},
+	} {
+		assertSelfContained(t, test.raw)
+		var value any
+		require.NoError(t, reader.DecodeBytes(test.raw, &value))
+		assert.Equal(t, test.expected, value)
+	}
+
+	var names struct {
+		Names map[string]string `maxminddb:"names"`
+	}
+	require.NoError(t, reader.DecodeBytes(result.City, &names))
+	assert.Equal(t, "Boxford", names.Names["en"])
+
+	// A whole record may be captured, as by LookupRaw.
+	var record RawValue
+	require.NoError(t, reader.Lookup(net.ParseIP(ip), &record))
+	raw, ok, err := reader.LookupRaw(netip.MustParseAddr(ip))
+	require.NoError(t, err)
+	require.True(t, ok)
+	assert.Equal(t, RawValue(raw), record)
+
+	var entries map[string]RawValue
+	require.NoError(t, reader.Lookup(net.ParseIP(ip), &entries))
+	assert.Len(t, entries, len(expected))
+	for key, raw := range entries {
+		var value any
+		require.NoError(t, reader.DecodeBytes(raw, &value))
+		assert.Equal(t, expected[key], value, key)
+	}
+}
+
+func TestDecoderReadRaw(t *testing.T) {
+	reader, err := Open(testFile("MaxMind-DB-test-decoder.mmdb"))
+	require.NoError(t, err)
+	defer reader.Close()
+	offset, err := reader.LookupOffset(net.ParseIP("::1.1.1.0"))
+	require.NoError(t, err)
+
+	dec := reader.Decoder(offset)
+	raw, err := dec.ReadRaw()
+	require.NoError(t, err)
+	expected, _, err := reader.LookupRaw(netip.MustParseAddr("::1.1.1.0"))
+	require.NoError(t, err)
+	assert.Equal(t, expected, raw)
+	end, err := reader.decoder.nextValueOffset(uint(offset), 1)
+	require.NoError(t, err)
+	assert.Equal(t, uintptr(end), dec.Offset())
+}
@@ -1,11 +1,14 @@
-// Package maxminddb provides a reader for the MaxMind DB file format.
 package maxminddb
 
 import (
 	"bytes"
 	"errors"
 	"fmt"
+	"math/bits"
 	"net"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/3JoB/go-reflect"
 )
@@ -16,6 +19,13 @@ const (
 	NotFound = ^uintptr(0)
 
 	dataSectionSeparatorSize = 16
+
+	// The binary format versions this package implements. Databases with a
+	// different major version cannot be read. Databases with a newer minor
+	// version are read, but may use features this package does not know
+	// about.
+	supportedMajorVersion = 2
+	supportedMinorVersion = 0
 )
 
 var metadataStartMarker = []byte("\xAB\xCD\xEFMaxMind.com")
@@ -27,13 +37,104 @@ var metadataStartMarker = []byte("\xAB\xCD\xEFMaxMind.com")
 // shared across goroutines.
 type Reader struct {
 	nodeReader        nodeReader
+	traverse          treeTraversal
 	buffer            []byte
 	decoder           decoder
 	Metadata          Metadata
 	ipv4Start         uint
 	ipv4StartBitDepth int
+	negativeCache     *negativeCache
+	reservedPrefixes  *reservedPrefixes
+	reservedLookups   atomic.Uint64
+	recordHashes      recordHashCache
+	ipv4TreeCache     treeCache
+	ipv6TreeCache     treeCache
 	nodeOffsetMult    uint
-	hasMappedFile     bool
+	// spoolFile is the path of the temporary file backing a Reader created
+	// by FromReader if it could not be removed while mapped.
+	spoolFile     string
+	loadMode      LoadMode
+	hasMappedFile bool
+	poisonOnClose bool
+	// verification is the verification started with WithBackgroundVerify,
+	// if any.
+	verification *backgroundVerify
+	// recordCache is set with WithRecordCache. generation identifies the
+	// Reader in its keys.
+	recordCache RecordCache
+	generation  uint64
+	// decodedCache is set with WithDecodedCache, which also makes it the
+	// recordCache.
+	decodedCache *decodedCache
+	// closeMu is held by Close and read-held by Networks iterators while
+	// they read the database, so that it is not unmapped under them.
+	closeMu sync.RWMutex
+}
+
+// LoadMode describes how the database of a Reader is held in memory.
+type LoadMode int
+
+const (
+	// LoadModeBytes is used by Readers created with FromBytes, which use
+	// the buffer passed by the caller.
+	LoadModeBytes LoadMode = iota
+	// LoadModeMmap is used by Readers whose database file is memory mapped.
+	LoadModeMmap
+	// LoadModeHeap is used by Readers whose database was read into memory,
+	// e.g., by Open on platforms without memory map support, on Windows
+	// when built with the purego tag, or for files on file systems that do
+	// not support memory maps.
+	LoadModeHeap
+)
+
+// String returns the name of the mode, e.g., "mmap".
+func (m LoadMode) String() string {
+	switch m {
+	case LoadModeBytes:
+		return "bytes"
+	case LoadModeMmap:
+		return "mmap"
+	case LoadModeHeap:
+		return "heap"
+	default:
+		return fmt.Sprintf("LoadMode(%d)", int(m))
+	}
+}
+
+// LoadMode returns how the database of the Reader is held in memory.
+func (r *Reader) LoadMode() LoadMode {
+	return r.loadMode
+}
+
+// ReaderStats holds counters describing the lookups performed by a Reader.
+// It is returned by Reader.Stats.
+type ReaderStats struct {
+	// ReservedLookups is the number of lookups that returned without a
+	// record because the address is in a network skipped with
+	// WithSkipReserved.
+	ReservedLookups uint64
+	// DecodedCacheHits is the number of records taken from the cache set
+	// with WithDecodedCache.
+	DecodedCacheHits uint64
+	// DecodedCacheMisses is the number of records decoded because they
+	// were not in the cache set with WithDecodedCache.
+	DecodedCacheMisses uint64
+	// DecodedCacheEntries is the number of records currently in the cache
+	// set with WithDecodedCache.
+	DecodedCacheEntries int
+}
+
+// Stats returns the current values of the Reader's lookup counters.
+func (r *Reader) Stats() ReaderStats {
+	stats := ReaderStats{
+		ReservedLookups: r.reservedLookups.Load(),
+	}
+	if r.decodedCache != nil {
+		stats.DecodedCacheHits = r.decodedCache.hits.Load()
+		stats.DecodedCacheMisses = r.decodedCache.misses.Load()
+		stats.DecodedCacheEntries = r.decodedCache.len()
+	}
+	return stats
 }
 
 // Metadata holds the metadata decoded from the MaxMind DB file. In particular
@@ -50,65 +151,388 @@ type Metadata struct {
 	IPVersion                uint              `maxminddb:"ip_version"`
 	NodeCount                uint              `maxminddb:"node_count"`
 	RecordSize               uint              `maxminddb:"record_size"`
+
+	// Warnings holds problems found when opening the database that did not
+	// prevent it from being read, e.g., a binary format minor version newer
+	// than this package supports. It is not part of the database.
+	Warnings []string `maxminddb:"-"`
 }
 
-// FromBytes takes a byte slice corresponding to a MaxMind DB file and returns
-// a Reader structure or an error.
-func FromBytes(buffer []byte) (*Reader, error) {
-	metadataStart := bytes.LastIndex(buffer, metadataStartMarker)
+// ReaderOption are options for Open, FromBytes, and FromReader.
+type ReaderOption func(*readerOptions)
+
+type readerOptions struct {
+	minimumBuildTime time.Time
+	maxAge           time.Duration
+	stalenessHook    func(DatabaseTooOldError)
+	warningHook      func(string)
+	maxDecodedBytes  uint
+	negativeCache    int
+	reservedPrefixes *reservedPrefixes
+	detachedResults  bool
+	poisonOnClose    bool
+	softFailDecode   bool
+	keyTransform     func(parent, key string) string
+	decodeHooks      []DecodeHook
+	weaklyTyped      bool
+	tagFallback      string
+	strictDecode     bool
+	truncateArrays   bool
+	reportCoercions  bool
+	spoolThreshold   int64
+	maxDatabaseSize  int64
+	treeCacheBits    int
+
+	backgroundVerify  bool
+	verifyCallback    func(VerifyResult)
+	failOnVerifyError bool
+	recordCache       RecordCache
+
+	interfaceFactories map[reflect.Type]func() any
+	unsafeStrings      bool
+	decodedCacheSize   int
+	internStrings      bool
+}
+
+// WithMinimumBuildTime is an option for Open and FromBytes that makes them
+// return a DatabaseTooOldError if the database was built before t.
+func WithMinimumBuildTime(t time.Time) ReaderOption {
+	return func(o *readerOptions) {
+		o.minimumBuildTime = t
+	}
+}
+
+// WithMaxAge is an option for Open and FromBytes that makes them return a
+// DatabaseTooOldError if the database was built more than d before the time
+// it is opened.
+func WithMaxAge(d time.Duration) ReaderOption {
+	return func(o *readerOptions) {
+		o.maxAge = d
+	}
+}
 
-	if metadataStart == -1 {
-		return nil, newInvalidDatabaseError("error opening database: invalid MaxMind DB file")
+// WithStalenessHook is an option for Open and FromBytes that changes the
+// behavior of WithMinimumBuildTime and WithMaxAge. Rather than failing, the
+// database is opened and hook is called with the DatabaseTooOldError that
+// would otherwise have been returned.
+func WithStalenessHook(hook func(DatabaseTooOldError)) ReaderOption {
+	return func(o *readerOptions) {
+		o.stalenessHook = hook
 	}
+}
 
-	metadataStart += len(metadataStartMarker)
-	metadataDecoder := decoder{buffer: buffer[metadataStart:]}
+// WithWarningHook is an option for Open and FromBytes that calls hook with
+// each warning added to Metadata.Warnings when the database is opened.
+func WithWarningHook(hook func(warning string)) ReaderOption {
+	return func(o *readerOptions) {
+		o.warningHook = hook
+	}
+}
 
-	var metadata Metadata
+// WithMaxDecodedBytes is an option for Open and FromBytes that limits the
+// approximate amount of memory allocated for the output of a single decode to
+// n bytes. The total counts string and bytes values as well as the entries
+// of maps and slices. If the limit is exceeded, the decode is aborted with a
+// DecodedSizeLimitError. This guards against crafted databases that expand a
+// single record into a very large value by reusing pointers. By default, no
+// limit is enforced.
+func WithMaxDecodedBytes(n int) ReaderOption {
+	return func(o *readerOptions) {
+		o.maxDecodedBytes = uint(max(n, 0))
+	}
+}
 
-	rvMetdata := reflect.ValueOf(&metadata)
-	_, err := metadataDecoder.decode(0, rvMetdata, 0)
-	if err != nil {
+// WithMaxDatabaseSize is an option for Open, FromBytes, and FromReader that
+// makes them return a DatabaseTooLargeError for databases larger than n
+// bytes. Open checks the size of the file before mapping or reading it, and
+// FromReader stops reading once the input exceeds n bytes. By default, no
+// limit is enforced.
+func WithMaxDatabaseSize(n int64) ReaderOption {
+	return func(o *readerOptions) {
+		o.maxDatabaseSize = max(n, 0)
+	}
+}
+
+// checkDatabaseSize returns a DatabaseTooLargeError if size exceeds the limit
+// set with WithMaxDatabaseSize.
+func (o *readerOptions) checkDatabaseSize(size int64) error {
+	if o.maxDatabaseSize > 0 && size > o.maxDatabaseSize {
+		return DatabaseTooLargeError{Limit: o.maxDatabaseSize, Size: size}
+	}
+	return nil
+}
+
+// WithNegativeCache is an option for Open and FromBytes that enables a cache
+// of networks without a record in the database. Once a lookup finds that an
+// address is not in the database, later lookups of addresses in the same
+// empty network return without traversing the search tree. The cache holds
+// at most size networks and may be shared across goroutines. It is cleared
+// when the Reader is closed.
+//
+// This is useful for workloads where most lookups are of addresses that are
+// not in the database, such as scans of unallocated address space.
+func WithNegativeCache(size int) ReaderOption {
+	return func(o *readerOptions) {
+		o.negativeCache = size
+	}
+}
+
+// WithDetachedResults is an option for Open and FromBytes that guarantees
+// that every decoded value is copied out of the database buffer, overriding
+// any option that would otherwise make decoded values alias it.
+//
+// The lifetime guarantees of decoded values are as follows:
+//
+//   - By default, strings, []byte values, and all other decoded values are
+//     copies and remain valid after Close.
+//   - Options that decode without copying return values that alias the
+//     database buffer. These are only valid until Close is called and, for
+//     a Reader created with FromBytes, while the buffer is not modified.
+//   - With WithDetachedResults, all decoded values are copies regardless of
+//     other options and remain valid after Close.
+//
+// Offsets returned by LookupOffset or captured in uintptr fields are only
+// meaningful for the Reader that produced them.
+func WithDetachedResults() ReaderOption {
+	return func(o *readerOptions) {
+		o.detachedResults = true
+	}
+}
+
+// WithSoftFailDecode is an option for Open and FromBytes that makes decoding
+// continue past values that cannot be decoded, e.g., because they are
+// malformed or do not fit the type of the struct field they are decoded
+// into. Such struct fields and slice elements are left at their zero values
+// and such map entries are left out. Once the rest of the record has been
+// decoded, a FieldErrors listing each failure is returned. Errors that
+// prevent decoding the rest of the record, such as a DecodedSizeLimitError,
+// still abort the decode.
+//
+// This does not apply to types implementing the deserializer interface.
+func WithSoftFailDecode() ReaderOption {
+	return func(o *readerOptions) {
+		o.softFailDecode = true
+	}
+}
+
+// WithTruncateArrays is an option for Open, FromBytes, and FromReader that
+// makes decoding an array or bytes value with more elements than the Go
+// array it is decoded into keep the leading elements and skip the rest.
+// Without it, such values are an error.
+func WithTruncateArrays() ReaderOption {
+	return func(o *readerOptions) {
+		o.truncateArrays = true
+	}
+}
+
+// WithMapKeyTransform is an option for Open and FromBytes that passes each
+// map key in a record through transform before it is stored in a map or
+// matched to a struct field. parent is the transformed key of the innermost
+// map entry containing the map, e.g., "names" for the keys of both
+// city.names and subdivisions[0].names, or "" for the keys of the record
+// itself. transform may be called concurrently and should return key
+// unchanged for keys it does not handle. For instance, this makes the
+// language keys of names maps canonical:
+//
+//	maxminddb.WithMapKeyTransform(maxminddb.NormalizeLanguageTags)
+//
+// This does not apply to types implementing the deserializer interface.
+func WithMapKeyTransform(transform func(parent, key string) string) ReaderOption {
+	return func(o *readerOptions) {
+		o.keyTransform = transform
+	}
+}
+
+// WithPoisonOnClose is a debugging option for Open and FromBytes that
+// overwrites the database buffer with a poison pattern when the Reader is
+// closed. This only applies to heap-backed buffers, i.e., those passed to
+// FromBytes or read into memory by Open on platforms without memory map
+// support; memory-mapped files are unmapped on Close and accessing them
+// afterward faults. It is intended for tests that check that no decoded
+// value is used after Close. The buffer passed to FromBytes is modified.
+func WithPoisonOnClose() ReaderOption {
+	return func(o *readerOptions) {
+		o.poisonOnClose = true
+	}
+}
+
+// FromBytes takes a byte slice corresponding to a MaxMind DB file and returns
+// a Reader structure or an error.
+func FromBytes(buffer []byte, options ...ReaderOption) (*Reader, error) {
+	opts := readerOptions{treeCacheBits: defaultTreeCacheBits}
+	for _, option := range options {
+		option(&opts)
+	}
+	if err := opts.checkDatabaseSize(int64(len(buffer))); err != nil {
 		return nil, err
 	}
 
-	searchTreeSize := metadata.NodeCount * metadata.RecordSize / 4
-	dataSectionStart := searchTreeSize + dataSectionSeparatorSize
-	dataSectionEnd := uint(metadataStart - len(metadataStartMarker))
-	if dataSectionStart > dataSectionEnd {
-		return nil, newInvalidDatabaseError("the MaxMind DB contains invalid metadata")
+	metadata, searchTreeSize, dataSectionEnd, err := opts.parseMetadata(buffer, 0)
+	if err != nil {
+		return nil, err
 	}
 	d := decoder{
-		buffer: buffer[searchTreeSize+dataSectionSeparatorSize : metadataStart-len(metadataStartMarker)],
+		buffer:             buffer[searchTreeSize+dataSectionSeparatorSize : dataSectionEnd],
+		maxDecodedBytes:    opts.maxDecodedBytes,
+		detachedResults:    opts.detachedResults,
+		softFail:           opts.softFailDecode,
+		keyTransform:       opts.keyTransform,
+		decodeHooks:        opts.decodeHooks,
+		weaklyTyped:        opts.weaklyTyped,
+		tagFallback:        opts.tagFallback,
+		strict:             opts.strictDecode,
+		truncateArrays:     opts.truncateArrays,
+		reportCoercions:    opts.reportCoercions,
+		unsafeStrings:      opts.unsafeStrings,
+		interfaceFactories: opts.interfaceFactories,
+	}
+	if opts.internStrings {
+		d.strings = newStringTable()
 	}
 
 	nodeBuffer := buffer[:searchTreeSize]
-	var nodeReader nodeReader
-	switch metadata.RecordSize {
-	case 24:
-		nodeReader = nodeReader24{buffer: nodeBuffer}
-	case 28:
-		nodeReader = nodeReader28{buffer: nodeBuffer}
-	case 32:
-		nodeReader = nodeReader32{buffer: nodeBuffer}
-	default:
+	nodeReader, traverse, ok := newNodeReader(nodeBuffer, metadata.RecordSize)
+	if !ok {
 		return nil, newInvalidDatabaseError("unknown record size: %d", metadata.RecordSize)
 	}
 
 	reader := &Reader{
-		buffer:         buffer,
-		nodeReader:     nodeReader,
-		decoder:        d,
-		Metadata:       metadata,
-		ipv4Start:      0,
-		nodeOffsetMult: metadata.RecordSize / 4,
+		buffer:           buffer,
+		nodeReader:       nodeReader,
+		traverse:         traverse,
+		decoder:          d,
+		Metadata:         metadata,
+		ipv4Start:        0,
+		reservedPrefixes: opts.reservedPrefixes,
+		nodeOffsetMult:   metadata.RecordSize / 4,
+		poisonOnClose:    opts.poisonOnClose,
+		recordCache:      opts.recordCache,
+		generation:       readerGenerations.Add(1),
+	}
+	if opts.negativeCache > 0 {
+		reader.negativeCache = newNegativeCache(opts.negativeCache)
+	}
+	if opts.decodedCacheSize > 0 {
+		reader.decodedCache = newDecodedCache(opts.decodedCacheSize)
+		reader.recordCache = reader.decodedCache
 	}
 
 	reader.setIPv4Start()
+	reader.ipv4TreeCache.root = reader.ipv4Start
+	reader.ipv4TreeCache.bits = uint(opts.treeCacheBits)
+	reader.ipv6TreeCache.bits = uint(opts.treeCacheBits)
+
+	if opts.backgroundVerify {
+		reader.startBackgroundVerify(opts.verifyCallback, opts.failOnVerifyError)
+	}
 
 	return reader, err
 }
 
+// poisonByte is the value written over heap-backed buffers on Close when
+// WithPoisonOnClose is set.
+const poisonByte = 0xA5
+
+func (r *Reader) poison() {
+	for i := range r.buffer {
+		r.buffer[i] = poisonByte
+	}
+}
+
+// parseMetadata decodes and validates the metadata section of a database
+// whose last bytes are in tail, which starts at offset within the database.
+// It returns the metadata along with the size of the search tree and the
+// offset at which the data section ends, both relative to the start of the
+// database.
+func (o *readerOptions) parseMetadata(
+	tail []byte,
+	offset uint,
+) (metadata Metadata, searchTreeSize, dataSectionEnd uint, err error) {
+	markerStart := bytes.LastIndex(tail, metadataStartMarker)
+	if markerStart == -1 {
+		return metadata, 0, 0, newInvalidDatabaseError("error opening database: invalid MaxMind DB file")
+	}
+
+	metadataDecoder := decoder{buffer: tail[markerStart+len(metadataStartMarker):]}
+	if _, err := metadataDecoder.decode(0, reflect.ValueOf(&metadata), 0); err != nil {
+		return metadata, 0, 0, err
+	}
+
+	if err := o.checkFormatVersion(&metadata); err != nil {
+		return metadata, 0, 0, err
+	}
+
+	if err := o.checkBuildTime(metadata); err != nil {
+		return metadata, 0, 0, err
+	}
+
+	switch metadata.RecordSize {
+	case 24, 28, 32:
+	default:
+		return metadata, 0, 0, newInvalidDatabaseError("unknown record size: %d", metadata.RecordSize)
+	}
+
+	// A corrupt node count could otherwise overflow the search tree size
+	// and wrap around to a value that passes the bounds check below.
+	hi, lo := bits.Mul(metadata.NodeCount, metadata.RecordSize)
+	searchTreeSize = lo / 4
+	dataSectionStart := searchTreeSize + dataSectionSeparatorSize
+	dataSectionEnd = offset + uint(markerStart)
+	if hi != 0 || dataSectionStart < searchTreeSize || dataSectionStart > dataSectionEnd {
+		return metadata, 0, 0, newInvalidDatabaseError("the MaxMind DB contains invalid metadata")
+	}
+	return metadata, searchTreeSize, dataSectionEnd, nil
+}
+
+func (o *readerOptions) checkFormatVersion(metadata *Metadata) error {
+	if metadata.BinaryFormatMajorVersion != supportedMajorVersion {
+		return UnsupportedFormatVersionError{
+			MajorVersion: metadata.BinaryFormatMajorVersion,
+			MinorVersion: metadata.BinaryFormatMinorVersion,
+		}
+	}
+	if metadata.BinaryFormatMinorVersion > supportedMinorVersion {
+		o.warn(metadata, fmt.Sprintf(
+			"binary format version %d.%d is newer than the supported version %d.%d",
+			metadata.BinaryFormatMajorVersion,
+			metadata.BinaryFormatMinorVersion,
+			supportedMajorVersion,
+			supportedMinorVersion,
+		))
+	}
+	return nil
+}
+
+func (o *readerOptions) warn(metadata *Metadata, warning string) {
+	metadata.Warnings = append(metadata.Warnings, warning)
+	if o.warningHook != nil {
+		o.warningHook(warning)
+	}
+}
+
+func (o *readerOptions) checkBuildTime(metadata Metadata) error {
+	threshold := o.minimumBuildTime
+	if o.maxAge > 0 {
+		if maxAgeThreshold := time.Now().Add(-o.maxAge); maxAgeThreshold.After(threshold) {
+			threshold = maxAgeThreshold
+		}
+	}
+	if threshold.IsZero() {
+		return nil
+	}
+
+	buildTime := time.Unix(int64(metadata.BuildEpoch), 0)
+	if !buildTime.Before(threshold) {
+		return nil
+	}
+	err := DatabaseTooOldError{BuildTime: buildTime, MinimumBuildTime: threshold}
+	if o.stalenessHook != nil {
+		o.stalenessHook(err)
+		return nil
+	}
+	return err
+}
+
 func (r *Reader) setIPv4Start() {
 	if r.Metadata.IPVersion != 6 {
 		return
@@ -131,15 +555,14 @@ func (r *Reader) setIPv4Start() {
 // because of type differences, an UnmarshalTypeError is returned. If the
 // database is invalid or otherwise cannot be read, an InvalidDatabaseError
 // is returned.
+//
+// The rules for decoding a record into result, including the struct tag
+// options, are described in the Decoding section of the package
+// documentation.
+//
+// Use With to look up ip with LookupOptions.
 func (r *Reader) Lookup(ip net.IP, result any) error {
-	if r.buffer == nil {
-		return errors.New("cannot call Lookup on a closed database")
-	}
-	pointer, _, _, err := r.lookupPointer(ip)
-	if pointer == 0 || err != nil {
-		return err
-	}
-	return r.retrieveData(pointer, result)
+	return r.With().Lookup(ip, result)
 }
 
 // LookupNetwork retrieves the database record for ip and stores it in the
@@ -151,21 +574,52 @@ func (r *Reader) Lookup(ip net.IP, result any) error {
 // database record cannot be stored in result because of type differences, an
 // UnmarshalTypeError is returned. If the database is invalid or otherwise
 // cannot be read, an InvalidDatabaseError is returned.
+//
+// Use With to look up ip with LookupOptions.
 func (r *Reader) LookupNetwork(
 	ip net.IP,
 	result any,
 ) (network *net.IPNet, ok bool, err error) {
+	return r.With().LookupNetwork(ip, result)
+}
+
+// LookupNetworkInto retrieves the database record for ip and stores it in
+// the value pointed to by result, as LookupNetwork does, and stores the
+// network of the record in network rather than returning a new *net.IPNet.
+// The memory of the address and mask of network is reused if it is large
+// enough, so looking up many addresses with the same network does not
+// allocate for it. If network is nil, only the record is retrieved.
+//
+// Use With to look up ip with LookupOptions.
+func (r *Reader) LookupNetworkInto(ip net.IP, network *net.IPNet, result any) (ok bool, err error) {
+	return r.With().LookupNetworkInto(ip, network, result)
+}
+
+// LookupMulti retrieves the database record for ip and decodes it into each
+// of the values pointed to by targets. The search tree is only traversed
+// once and the record offset is shared between the decodes. Nil targets are
+// skipped. The ok return value indicates whether the database contained a
+// record for the ip.
+//
+// If decoding into a target fails, the returned error identifies the index
+// of that target and no further targets are decoded.
+func (r *Reader) LookupMulti(ip net.IP, targets ...any) (ok bool, err error) {
 	if r.buffer == nil {
-		return nil, false, errors.New("cannot call Lookup on a closed database")
+		return false, errors.New("cannot call LookupMulti on a closed database")
 	}
-	pointer, prefixLength, ip, err := r.lookupPointer(ip)
-
-	network = r.cidr(ip, prefixLength)
-	if pointer == 0 || err != nil {
-		return network, false, err
+	offset, _, _, err := r.lookupRecord(ip)
+	if offset == NotFound || err != nil {
+		return false, err
 	}
-
-	return network, true, r.retrieveData(pointer, result)
+	for i, target := range targets {
+		if target == nil {
+			continue
+		}
+		if err := r.decode(offset, target); err != nil {
+			return true, fmt.Errorf("error decoding into target %d: %w", i, err)
+		}
+	}
+	return true, nil
 }
 
 // LookupOffset maps an argument net.IP to a corresponding record offset in the
@@ -177,29 +631,72 @@ func (r *Reader) LookupOffset(ip net.IP) (uintptr, error) {
 	if r.buffer == nil {
 		return 0, errors.New("cannot call LookupOffset on a closed database")
 	}
-	pointer, _, _, err := r.lookupPointer(ip)
-	if pointer == 0 || err != nil {
-		return NotFound, err
-	}
-	return r.resolveDataPointer(pointer)
+	offset, _, _, err := r.lookupRecord(ip)
+	return offset, err
+}
+
+// ipNetStorage holds a net.IPNet along with the memory for its address and
+// mask so that the network returned by LookupNetwork is a single
+// allocation.
+type ipNetStorage struct {
+	network net.IPNet
+	buf     [2 * net.IPv6len]byte
 }
 
-func (r *Reader) cidr(ip net.IP, prefixLength int) *net.IPNet {
+func newIPNet() *net.IPNet {
+	s := new(ipNetStorage)
+	s.network.IP = s.buf[:0:net.IPv6len]
+	s.network.Mask = s.buf[net.IPv6len:net.IPv6len]
+	return &s.network
+}
+
+// cidrInto stores the network of prefixLength bits containing ip in
+// network, reusing the memory of its address and mask if they are large
+// enough.
+func (r *Reader) cidrInto(network *net.IPNet, ip net.IP, prefixLength int) {
 	// This is necessary as the node that the IPv4 start is at may
 	// be at a bit depth that is less that 96, i.e., ipv4Start points
 	// to a leaf node. For instance, if a record was inserted at ::/8,
 	// the ipv4Start would point directly at the leaf node for the
 	// record and would have a bit depth of 8. This would not happen
 	// with databases currently distributed by MaxMind as all of them
-	// have an IPv4 subtree that is greater than a single node.
+	// have an IPv4 subtree that is greater than a single node. Such lookups
+	// have a prefix length of 0 as the search tree is not traversed; other
+	// prefix lengths come from WithSkipReserved.
 	if r.Metadata.IPVersion == 6 &&
 		len(ip) == net.IPv4len &&
-		r.ipv4StartBitDepth != 96 {
-		return &net.IPNet{IP: net.ParseIP("::"), Mask: net.CIDRMask(r.ipv4StartBitDepth, 128)}
+		r.ipv4StartBitDepth != 96 &&
+		prefixLength == 0 {
+		ip, prefixLength = net.IPv6unspecified, r.ipv4StartBitDepth
 	}
 
-	mask := net.CIDRMask(prefixLength, len(ip)*8)
-	return &net.IPNet{IP: ip.Mask(mask), Mask: mask}
+	// As with net.CIDRMask, there is no mask for an invalid length.
+	if (len(ip) != net.IPv4len && len(ip) != net.IPv6len) ||
+		prefixLength < 0 || prefixLength > len(ip)*8 {
+		network.IP, network.Mask = nil, nil
+		return
+	}
+	network.IP = resize(network.IP, len(ip))
+	network.Mask = resize(network.Mask, len(ip))
+	ones := prefixLength
+	for i := range network.Mask {
+		if ones >= 8 {
+			network.Mask[i] = 0xff
+			ones -= 8
+		} else {
+			network.Mask[i] = ^byte(0xff >> ones)
+			ones = 0
+		}
+		network.IP[i] = ip[i] & network.Mask[i]
+	}
+}
+
+// resize returns b with length n, reusing its memory if it is large enough.
+func resize[S ~[]byte](b S, n int) S {
+	if cap(b) < n {
+		return make(S, n)
+	}
+	return b[:n]
 }
 
 // Decode the record at |offset| into |result|. The result value pointed to
@@ -225,24 +722,76 @@ func (r *Reader) Decode(offset uintptr, result any) error {
 	return r.decode(offset, result)
 }
 
+// DecodeValue decodes the record at offset into v as Decode does for a
+// pointer to v. It is meant for result types only known at run time, e.g.,
+// structs created with reflect.StructOf. v must be settable, e.g., a value
+// returned by reflect.New(t).Elem(). A reflect.Value from the standard
+// library's reflect package may be converted with reflect.ToValue.
+func (r *Reader) DecodeValue(offset uintptr, v reflect.Value) error {
+	if r.buffer == nil {
+		return errors.New("cannot call DecodeValue on a closed database")
+	}
+	return r.decodeValueWithOptions(offset, v, lookupOptions{})
+}
+
 func (r *Reader) decode(offset uintptr, result any) error {
+	return r.decodeWithOptions(offset, result, lookupOptions{})
+}
+
+func (r *Reader) decodeWithOptions(offset uintptr, result any, options lookupOptions) error {
 	rv := reflect.ValueOf(result)
 	if rv.Kind() != reflect.Ptr || rv.IsNil() {
 		return errors.New("result param must be a pointer")
 	}
+	return r.decodeValueWithOptions(offset, rv.Elem(), options)
+}
+
+func (r *Reader) decodeValueWithOptions(offset uintptr, v reflect.Value, options lookupOptions) error {
+	// The decoder is copied so that per-decode state, such as the running
+	// total for WithMaxDecodedBytes, is not shared between goroutines.
+	d := r.decoder
+	return r.decodeValueWithDecoder(&d, offset, v, options)
+}
+
+// decodeValueWithDecoder is decodeValueWithOptions with d, a copy of
+// r.decoder owned by the caller, e.g., a Session.
+func (r *Reader) decodeValueWithDecoder(
+	d *decoder,
+	offset uintptr,
+	v reflect.Value,
+	options lookupOptions,
+) error {
+	if !v.CanSet() {
+		return errors.New(
+			"value passed to DecodeValue must be settable, e.g., obtained from reflect.New(t).Elem()",
+		)
+	}
+	options.apply(d)
 
-	if dser, ok := result.(deserializer); ok {
-		_, err := r.decoder.decodeToDeserializer(uint(offset), dser, 0, false)
+	if dser, ok := v.Addr().Interface().(deserializer); ok {
+		_, err := d.decodeToDeserializer(uint(offset), dser, 0, false)
 		return err
 	}
+	if r.recordCache != nil && !options.setMaxDecodedBytes {
+		return r.decodeCached(d, offset, v)
+	}
 
-	_, err := r.decoder.decode(uint(offset), rv, 0)
+	_, err := d.decode(uint(offset), v, 0)
+	if err == nil && len(d.fieldErrors) > 0 {
+		return d.fieldErrors
+	}
 	return err
 }
 
-func (r *Reader) lookupPointer(ip net.IP) (uint, int, net.IP, error) {
+// lookupRecord returns the offset in the data section of the record for ip
+// or NotFound if there is none, along with the prefix length of the
+// network and ip in the form used for the lookup.
+func (r *Reader) lookupRecord(ip net.IP) (uintptr, int, net.IP, error) {
 	if ip == nil {
-		return 0, 0, nil, errors.New("IP passed to Lookup cannot be nil")
+		return NotFound, 0, nil, errors.New("IP passed to Lookup cannot be nil")
+	}
+	if err := r.verification.lookupError(); err != nil {
+		return NotFound, 0, ip, err
 	}
 
 	ipV4Address := ip.To4()
@@ -250,62 +799,140 @@ func (r *Reader) lookupPointer(ip net.IP) (uint, int, net.IP, error) {
 		ip = ipV4Address
 	}
 	if len(ip) == 16 && r.Metadata.IPVersion == 4 {
-		return 0, 0, ip, fmt.Errorf(
-			"error looking up '%s': you attempted to look up an IPv6 address in an IPv4-only database",
-			ip.String(),
-		)
+		return NotFound, 0, ip, ipv6AddressInIPv4DatabaseError(ip.String())
+	}
+
+	offset, prefixLength, err := r.lookupNormalized(ip)
+	return offset, prefixLength, ip, err
+}
+
+func ipv6AddressInIPv4DatabaseError(ip string) error {
+	return fmt.Errorf(
+		"error looking up '%s': you attempted to look up an IPv6 address in an IPv4-only database",
+		ip,
+	)
+}
+
+// lookupNormalized is lookupRecord for ip in the form used for the lookup,
+// i.e., a 4-byte IPv4 address or, in an IPv6 database, a 16-byte IPv6
+// address. ip is not retained.
+func (r *Reader) lookupNormalized(ip net.IP) (uintptr, int, error) {
+	if r.reservedPrefixes != nil {
+		if prefixLength, ok := r.reservedPrefixes.match(ip); ok {
+			r.reservedLookups.Add(1)
+			return NotFound, prefixLength, nil
+		}
+	}
+
+	if r.negativeCache != nil {
+		if prefixLength, ok := r.negativeCache.get(ip); ok {
+			return NotFound, prefixLength, nil
+		}
 	}
 
 	bitCount := uint(len(ip) * 8)
 
-	var node uint
+	cache := &r.ipv6TreeCache
 	if bitCount == 32 {
-		node = r.ipv4Start
+		cache = &r.ipv4TreeCache
 	}
-	node, prefixLength := r.traverseTree(ip, node, bitCount)
+	node, prefixLength := r.traverseTreeCached(cache, ip, bitCount)
 
 	nodeCount := r.Metadata.NodeCount
 	if node == nodeCount {
 		// Record is empty
-		return 0, prefixLength, ip, nil
+		if r.negativeCache != nil {
+			r.negativeCache.add(ip, prefixLength)
+		}
+		return NotFound, prefixLength, nil
 	} else if node > nodeCount {
-		return node, prefixLength, ip, nil
+		offset, ok := r.dataSectionOffset(node)
+		if !ok {
+			return NotFound, prefixLength, r.dataPointerError(r.recordNode(ip, prefixLength), node)
+		}
+		return offset, prefixLength, nil
 	}
 
-	return 0, prefixLength, ip, newInvalidDatabaseError("invalid node in search tree")
+	return NotFound, prefixLength, newInvalidDatabaseError("invalid node in search tree")
 }
 
-func (r *Reader) traverseTree(ip net.IP, node, bitCount uint) (uint, int) {
-	nodeCount := r.Metadata.NodeCount
-
-	i := uint(0)
-	for ; i < bitCount && node < nodeCount; i++ {
-		bit := uint(1) & (uint(ip[i>>3]) >> (7 - (i % 8)))
-
-		offset := node * r.nodeOffsetMult
-		if bit == 0 {
-			node = r.nodeReader.readLeft(offset)
-		} else {
-			node = r.nodeReader.readRight(offset)
-		}
+// recordNode returns the node holding the record found by a lookup of ip,
+// as returned by lookupRecord, with prefix length prefixLength.
+func (r *Reader) recordNode(ip net.IP, prefixLength int) uint {
+	if r.Metadata.IPVersion == 6 && len(ip) == net.IPv4len {
+		ip = append(make(net.IP, 12, net.IPv6len), ip...)
+		prefixLength += r.ipv4StartBitDepth
 	}
+	return r.parentNode(ip, prefixLength)
+}
+
+func (r *Reader) traverseTree(ip net.IP, node, bitCount uint) (uint, int) {
+	return r.traverseTreeFrom(ip, node, 0, bitCount)
+}
 
+// traverseTreeFrom is traverseTree starting at node, which is reached after
+// the first i bits of ip.
+func (r *Reader) traverseTreeFrom(ip net.IP, node, i, bitCount uint) (uint, int) {
+	node, i = r.traverse(ip, node, i, bitCount, r.Metadata.NodeCount)
 	return node, int(i)
 }
 
-func (r *Reader) retrieveData(pointer uint, result any) error {
-	offset, err := r.resolveDataPointer(pointer)
+func (r *Reader) retrieveData(node, pointer uint, result any) error {
+	offset, err := r.resolveDataPointer(node, pointer)
 	if err != nil {
 		return err
 	}
 	return r.decode(offset, result)
 }
 
-func (r *Reader) resolveDataPointer(pointer uint) (uintptr, error) {
-	resolved := uintptr(pointer - r.Metadata.NodeCount - dataSectionSeparatorSize)
+// resolveDataPointer returns the offset in the data section of the record
+// with value pointer in node. It returns an InvalidDatabaseError if the
+// offset is outside of the data section, e.g., because the record of a
+// corrupt database points into the search tree.
+func (r *Reader) resolveDataPointer(node, pointer uint) (uintptr, error) {
+	offset, ok := r.dataSectionOffset(pointer)
+	if !ok {
+		return 0, r.dataPointerError(node, pointer)
+	}
+	return offset, nil
+}
+
+// dataSectionOffset returns the offset in the data section of the record
+// with value pointer. ok is false if the offset is outside of the data
+// section.
+func (r *Reader) dataSectionOffset(pointer uint) (offset uintptr, ok bool) {
+	start := r.Metadata.NodeCount + dataSectionSeparatorSize
+	if pointer < start || pointer-start >= uint(len(r.decoder.buffer)) {
+		return 0, false
+	}
+	return uintptr(pointer - start), true
+}
+
+func (r *Reader) dataPointerError(node, pointer uint) InvalidDatabaseError {
+	offset := int64(pointer) - int64(r.Metadata.NodeCount) - dataSectionSeparatorSize
+	return newInvalidDatabaseError(
+		"the MaxMind DB file's search tree is corrupt: the record value %d in node %d "+
+			"resolves to offset %d, outside of the data section of %d bytes",
+		pointer,
+		node,
+		offset,
+		len(r.decoder.buffer),
+	)
+}
 
-	if resolved >= uintptr(len(r.buffer)) {
-		return 0, newInvalidDatabaseError("the MaxMind DB file's search tree is corrupt")
+// parentNode returns the node holding the record reached after the first
+// depth bits of ip, which has the length of the addresses in the search
+// tree. It is only used to describe errors as lookups do not keep track of
+// the nodes they traverse.
+func (r *Reader) parentNode(ip net.IP, depth int) uint {
+	node := uint(0)
+	for i := 0; i < depth-1 && node < r.Metadata.NodeCount; i++ {
+		offset := node * r.nodeOffsetMult
+		if ip[i>>3]&(1<<(7-i%8)) == 0 {
+			node = r.nodeReader.readLeft(offset)
+		} else {
+			node = r.nodeReader.readRight(offset)
+		}
 	}
-	return resolved, nil
+	return node
 }
@@ -1,28 +1,81 @@
-//go:build appengine || plan9 || js || wasip1 || wasi
-// +build appengine plan9 js wasip1 wasi
+//go:build appengine || !(unix || windows) || (windows && purego)
+// +build appengine !unix,!windows windows,purego
 
 package maxminddb
 
 import (
+	"io"
 	"os"
 )
 
 // Open takes a string path to a MaxMind DB file and returns a Reader
 // structure or an error. The database file is opened using a memory map
 // on supported platforms. On platforms without memory map support, such
-// as WebAssembly or Google App Engine, the database is loaded into memory.
+// as WebAssembly or Google App Engine, and on Windows when built with the
+// purego tag, the database is loaded into memory.
 // Use the Close method on the Reader object to return the resources to the system.
-func Open(file string) (*Reader, error) {
+// The behavior of the Reader may be customized by passing ReaderOption values.
+func Open(file string, options ...ReaderOption) (*Reader, error) {
+	var opts readerOptions
+	for _, option := range options {
+		option(&opts)
+	}
+	if opts.maxDatabaseSize > 0 {
+		stats, err := os.Stat(file)
+		if err != nil {
+			return nil, err
+		}
+		if err := opts.checkDatabaseSize(stats.Size()); err != nil {
+			return nil, err
+		}
+	}
+
 	bytes, err := os.ReadFile(file)
 	if err != nil {
 		return nil, err
 	}
 
-	return FromBytes(bytes)
+	reader, err := fromOwnedBytes(bytes, options)
+	if err != nil {
+		return nil, err
+	}
+	reader.loadMode = LoadModeHeap
+	return reader, nil
+}
+
+// fromSpooled reads all of src into memory as memory maps are not supported
+// on this platform.
+func fromSpooled(src io.Reader, options []ReaderOption) (*Reader, error) {
+	bytes, err := io.ReadAll(src)
+	if err != nil {
+		return nil, err
+	}
+
+	reader, err := fromOwnedBytes(bytes, options)
+	if err != nil {
+		return nil, err
+	}
+	reader.loadMode = LoadModeHeap
+	return reader, nil
 }
 
-// Close returns the resources used by the database to the system.
+// Close returns the resources used by the database to the system. Networks
+// iterators created from the Reader return an error once it has been closed,
+// and Close may be called while they are in use in other goroutines. Close
+// must not be called concurrently with other methods of the Reader.
 func (r *Reader) Close() error {
+	r.closeMu.Lock()
+	defer r.closeMu.Unlock()
+	r.stopBackgroundVerify()
+	if r.negativeCache != nil {
+		r.negativeCache.clear()
+	}
+	if r.decodedCache != nil {
+		r.decodedCache.clear()
+	}
+	if r.poisonOnClose {
+		r.poison()
+	}
 	r.buffer = nil
 	return nil
 }
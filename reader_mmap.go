@@ -1,63 +1,173 @@
-//go:build !appengine && !plan9 && !js && !wasip1 && !wasi
-// +build !appengine,!plan9,!js,!wasip1,!wasi
+//go:build (unix || (windows && !purego)) && !appengine
+// +build unix windows,!purego
+// +build !appengine
 
 package maxminddb
 
 import (
+	"errors"
+	"io"
 	"os"
 	"runtime"
 )
 
+// mapFile memory maps length bytes of the file fd. It is a variable so that
+// tests can simulate file systems without memory map support.
+var mapFile = mmap
+
 // Open takes a string path to a MaxMind DB file and returns a Reader
 // structure or an error. The database file is opened using a memory map
 // on supported platforms. On platforms without memory map support, such
-// as WebAssembly or Google App Engine, the database is loaded into memory.
+// as WebAssembly or Google App Engine, and for files that cannot be memory
+// mapped, e.g., as they are on a file system without memory map support,
+// the database is loaded into memory. Reader.LoadMode reports which was used.
 // Use the Close method on the Reader object to return the resources to the system.
-func Open(file string) (*Reader, error) {
-	mapFile, err := os.Open(file)
+// The behavior of the Reader may be customized by passing ReaderOption values.
+func Open(file string, options ...ReaderOption) (*Reader, error) {
+	f, err := os.Open(file)
 	if err != nil {
-		_ = mapFile.Close()
+		_ = f.Close()
 		return nil, err
 	}
 
-	stats, err := mapFile.Stat()
+	stats, err := f.Stat()
 	if err != nil {
-		_ = mapFile.Close()
+		_ = f.Close()
+		return nil, err
+	}
+
+	var opts readerOptions
+	for _, option := range options {
+		option(&opts)
+	}
+	if err := opts.checkDatabaseSize(stats.Size()); err != nil {
+		_ = f.Close()
 		return nil, err
 	}
 
 	fileSize := int(stats.Size())
-	mmap, err := mmap(int(mapFile.Fd()), fileSize)
+	mmap, err := mapFile(int(f.Fd()), fileSize)
 	if err != nil {
-		_ = mapFile.Close()
+		if !mmapUnsupported(err) {
+			_ = f.Close()
+			return nil, err
+		}
+		reader, err := openHeap(f, fileSize, options)
+		_ = f.Close()
+		return reader, err
+	}
+
+	if err := f.Close(); err != nil {
+		//nolint:errcheck // we prefer to return the original error
+		munmap(mmap)
 		return nil, err
 	}
 
-	if err := mapFile.Close(); err != nil {
+	reader, err := fromOwnedBytes(mmap, options)
+	if err != nil {
 		//nolint:errcheck // we prefer to return the original error
 		munmap(mmap)
 		return nil, err
 	}
 
-	reader, err := FromBytes(mmap)
+	reader.loadMode = LoadModeMmap
+	reader.hasMappedFile = true
+	runtime.SetFinalizer(reader, (*Reader).Close)
+	return reader, nil
+}
+
+// openHeap reads the size bytes of f into memory and returns a Reader for
+// them. It is used by Open for files that cannot be memory mapped.
+func openHeap(f *os.File, size int, options []ReaderOption) (*Reader, error) {
+	buffer := make([]byte, size)
+	if _, err := io.ReadFull(f, buffer); err != nil {
+		return nil, err
+	}
+	reader, err := fromOwnedBytes(buffer, options)
+	if err != nil {
+		return nil, err
+	}
+	reader.loadMode = LoadModeHeap
+	return reader, nil
+}
+
+// fromSpooled writes src to a temporary file, memory maps it, and returns a
+// Reader for it. The file is removed right away where open files may be
+// removed and otherwise when the Reader is closed.
+func fromSpooled(src io.Reader, options []ReaderOption) (*Reader, error) {
+	f, err := os.CreateTemp("", "maxminddb-*.mmdb")
+	if err != nil {
+		return nil, err
+	}
+	cleanup := func() {
+		_ = f.Close()
+		_ = os.Remove(f.Name())
+	}
+
+	size, err := io.Copy(f, src)
+	if err != nil {
+		cleanup()
+		return nil, err
+	}
+
+	mmap, err := mmap(int(f.Fd()), int(size))
+	if err != nil {
+		cleanup()
+		return nil, err
+	}
+	if err := f.Close(); err != nil {
+		//nolint:errcheck // we prefer to return the original error
+		munmap(mmap)
+		_ = os.Remove(f.Name())
+		return nil, err
+	}
+	spoolFile := ""
+	if err := os.Remove(f.Name()); err != nil {
+		spoolFile = f.Name()
+	}
+
+	reader, err := fromOwnedBytes(mmap, options)
 	if err != nil {
 		//nolint:errcheck // we prefer to return the original error
 		munmap(mmap)
+		if spoolFile != "" {
+			_ = os.Remove(spoolFile)
+		}
 		return nil, err
 	}
 
+	reader.loadMode = LoadModeMmap
 	reader.hasMappedFile = true
+	reader.spoolFile = spoolFile
 	runtime.SetFinalizer(reader, (*Reader).Close)
 	return reader, nil
 }
 
-// Close returns the resources used by the database to the system.
+// Close returns the resources used by the database to the system. Networks
+// iterators created from the Reader return an error once it has been closed,
+// and Close may be called while they are in use in other goroutines. Close
+// must not be called concurrently with other methods of the Reader.
 func (r *Reader) Close() error {
+	r.closeMu.Lock()
+	defer r.closeMu.Unlock()
+	r.stopBackgroundVerify()
 	var err error
 	if r.hasMappedFile {
 		runtime.SetFinalizer(r, nil)
 		r.hasMappedFile = false
 		err = munmap(r.buffer)
+		if r.spoolFile != "" {
+			err = errors.Join(err, os.Remove(r.spoolFile))
+			r.spoolFile = ""
+		}
+	} else if r.poisonOnClose {
+		r.poison()
+	}
+	if r.negativeCache != nil {
+		r.negativeCache.clear()
+	}
+	if r.decodedCache != nil {
+		r.decodedCache.clear()
 	}
 	r.buffer = nil
 	return err
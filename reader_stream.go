@@ -0,0 +1,81 @@
+package maxminddb
+
+import (
+	"bytes"
+	"io"
+)
+
+// defaultSpoolThreshold is the size above which FromReader spools the input
+// to a temporary file unless WithSpoolThreshold is used.
+const defaultSpoolThreshold = 32 << 20
+
+// WithSpoolThreshold is an option for FromReader that sets the size in bytes
+// up to which the input is kept in memory. Larger inputs are written to a
+// temporary file that is memory mapped and removed when the Reader is
+// closed. A threshold of 0 always spools the input. The default is 32 MiB.
+// On platforms without memory map support, the input is always kept in
+// memory.
+func WithSpoolThreshold(n int64) ReaderOption {
+	return func(o *readerOptions) {
+		o.spoolThreshold = max(n, 0)
+	}
+}
+
+// FromReader reads a MaxMind DB file from r and returns a Reader structure or
+// an error. Unlike reading all of r and passing the result to FromBytes, it
+// does not keep more than one copy of the database in memory: inputs larger
+// than the threshold set with WithSpoolThreshold are written to a temporary
+// file and memory mapped. Use the Close method on the Reader object to return
+// the resources, including the temporary file, to the system.
+//
+// To bound the size of untrusted input, use WithMaxDatabaseSize: FromReader
+// stops reading and returns a DatabaseTooLargeError once r exceeds the limit.
+func FromReader(r io.Reader, options ...ReaderOption) (*Reader, error) {
+	opts := readerOptions{spoolThreshold: defaultSpoolThreshold}
+	for _, option := range options {
+		option(&opts)
+	}
+
+	if limit := opts.maxDatabaseSize; limit > 0 {
+		r = &sizeLimitedReader{r: r, remaining: limit, limit: limit}
+	}
+
+	buffer, err := io.ReadAll(io.LimitReader(r, opts.spoolThreshold+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(buffer)) <= opts.spoolThreshold {
+		reader, err := fromOwnedBytes(buffer, options)
+		if err != nil {
+			return nil, err
+		}
+		reader.loadMode = LoadModeHeap
+		return reader, nil
+	}
+	return fromSpooled(io.MultiReader(bytes.NewReader(buffer), r), options)
+}
+
+// sizeLimitedReader reads from r and returns a DatabaseTooLargeError once
+// more than limit bytes have been read.
+type sizeLimitedReader struct {
+	r         io.Reader
+	remaining int64
+	limit     int64
+}
+
+func (l *sizeLimitedReader) Read(p []byte) (int, error) {
+	if l.remaining < 0 {
+		return 0, DatabaseTooLargeError{Limit: l.limit}
+	}
+	// Read one byte past the limit to tell inputs of exactly limit bytes
+	// from larger ones.
+	if int64(len(p)) > l.remaining+1 {
+		p = p[:l.remaining+1]
+	}
+	n, err := l.r.Read(p)
+	l.remaining -= int64(n)
+	if l.remaining < 0 {
+		return 0, DatabaseTooLargeError{Limit: l.limit}
+	}
+	return n, err
+}
@@ -0,0 +1,90 @@
+package maxminddb
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFromReader(t *testing.T) {
+	for _, threshold := range []int64{0, 1024, defaultSpoolThreshold} {
+		for _, ipVersion := range []uint{4, 6} {
+			t.Run(fmt.Sprintf("ipv%d threshold %d", ipVersion, threshold), func(t *testing.T) {
+				spoolDir := t.TempDir()
+				t.Setenv("TMPDIR", spoolDir)
+
+				buffer, err := os.ReadFile(testFile(fmt.Sprintf("MaxMind-DB-test-ipv%d-24.mmdb", ipVersion)))
+				require.NoError(t, err)
+				reader, err := FromReader(bytes.NewReader(buffer), WithSpoolThreshold(threshold))
+				require.NoError(t, err)
+
+				checkMetadata(t, reader, ipVersion, 24)
+				if ipVersion == 4 {
+					checkIpv4(t, reader)
+				} else {
+					checkIpv6(t, reader)
+				}
+
+				require.NoError(t, reader.Close())
+				entries, err := os.ReadDir(spoolDir)
+				require.NoError(t, err)
+				assert.Empty(t, entries, "temporary file removed")
+			})
+		}
+	}
+}
+
+func TestFromReaderMaxSize(t *testing.T) {
+	buffer, err := os.ReadFile(testFile("MaxMind-DB-test-ipv4-24.mmdb"))
+	require.NoError(t, err)
+	size := int64(len(buffer))
+
+	for _, threshold := range []int64{0, defaultSpoolThreshold} {
+		spoolDir := t.TempDir()
+		t.Setenv("TMPDIR", spoolDir)
+
+		reader, err := FromReader(
+			bytes.NewReader(buffer),
+			WithSpoolThreshold(threshold),
+			WithMaxDatabaseSize(size),
+		)
+		require.NoError(t, err, "input of exactly the limit")
+		require.NoError(t, reader.Close())
+
+		_, err = FromReader(
+			bytes.NewReader(buffer),
+			WithSpoolThreshold(threshold),
+			WithMaxDatabaseSize(size-1),
+		)
+		require.ErrorIs(t, err, ErrDatabaseTooLarge)
+		var tooLarge DatabaseTooLargeError
+		require.True(t, errors.As(err, &tooLarge))
+		assert.Equal(t, size-1, tooLarge.Limit)
+		assert.Equal(
+			t,
+			fmt.Sprintf("maxminddb: database exceeds the limit of %d bytes", size-1),
+			err.Error(),
+		)
+
+		entries, err := os.ReadDir(spoolDir)
+		require.NoError(t, err)
+		assert.Empty(t, entries, "temporary file removed")
+	}
+}
+
+func TestFromReaderInvalid(t *testing.T) {
+	spoolDir := t.TempDir()
+	t.Setenv("TMPDIR", spoolDir)
+
+	_, err := FromReader(bytes.NewReader([]byte("not a database")), WithSpoolThreshold(0))
+	require.Error(t, err)
+
+	entries, err := os.ReadDir(spoolDir)
+	require.NoError(t, err)
+	assert.Empty(t, entries, "temporary file removed")
+}
@@ -1,16 +1,20 @@
 package maxminddb
 
 import (
+	"bytes"
 	"errors"
 	"fmt"
 	"math/big"
 	"math/rand"
 	"net"
+	"net/netip"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 	"time"
 
+	"github.com/3JoB/go-reflect"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -48,6 +52,7 @@ func TestReaderBytes(t *testing.T) {
 			require.NoError(t, err)
 			reader, err := FromBytes(bytes)
 			require.NoError(t, err, "unexpected error while opening bytes: %v", err)
+			assert.Equal(t, LoadModeBytes, reader.LoadMode())
 
 			checkMetadata(t, reader, ipVersion, recordSize)
 
@@ -60,6 +65,49 @@ func TestReaderBytes(t *testing.T) {
 	}
 }
 
+// TestReaderUnalignedBytes checks lookups and decoding with the database at
+// every offset within a machine word so that the tree and the data section,
+// including its floats and wide integers, are read from unaligned addresses.
+func TestReaderUnalignedBytes(t *testing.T) {
+	for _, recordSize := range []uint{24, 28, 32} {
+		for _, ipVersion := range []uint{4, 6} {
+			fileName := fmt.Sprintf(
+				testFile("MaxMind-DB-test-ipv%d-%d.mmdb"),
+				ipVersion,
+				recordSize,
+			)
+			buffer, err := os.ReadFile(fileName)
+			require.NoError(t, err)
+			for shift := 1; shift < 8; shift++ {
+				shifted := make([]byte, len(buffer)+shift)[shift:]
+				copy(shifted, buffer)
+				reader, err := FromBytes(shifted)
+				require.NoError(t, err)
+
+				checkMetadata(t, reader, ipVersion, recordSize)
+				if ipVersion == 4 {
+					checkIpv4(t, reader)
+				} else {
+					checkIpv6(t, reader)
+				}
+			}
+		}
+	}
+
+	buffer, err := os.ReadFile(testFile("MaxMind-DB-test-decoder.mmdb"))
+	require.NoError(t, err)
+	for shift := 1; shift < 8; shift++ {
+		shifted := make([]byte, len(buffer)+shift)[shift:]
+		copy(shifted, buffer)
+		reader, err := FromBytes(shifted)
+		require.NoError(t, err)
+
+		var recordInterface any
+		require.NoError(t, reader.Lookup(net.ParseIP("::1.1.1.0"), &recordInterface))
+		checkDecodingToInterface(t, recordInterface)
+	}
+}
+
 func TestLookupNetwork(t *testing.T) {
 	bigInt := new(big.Int)
 	bigInt.SetString("1329227995784915872903807060280344576", 10)
@@ -204,6 +252,168 @@ func TestLookupNetwork(t *testing.T) {
 	}
 }
 
+func TestLookupNetworkInto(t *testing.T) {
+	tests := []struct {
+		DBFile string
+		IPs    []string
+	}{
+		{
+			DBFile: "MaxMind-DB-test-ipv4-24.mmdb",
+			IPs:    []string{"1.1.1.1", "1.1.1.3", "::ffff:1.1.1.3", "2.2.2.2"},
+		},
+		{
+			// The IPv4-mapped, 6to4, and Teredo networks alias the IPv4
+			// subtree.
+			DBFile: "MaxMind-DB-test-decoder.mmdb",
+			IPs: []string{
+				"1.1.1.3", "::ffff:1.1.1.128", "::1.1.1.128",
+				"2002:101:101::", "2001:0:101:101::", "::2:0:1",
+			},
+		},
+		{
+			DBFile: "MaxMind-DB-no-ipv4-search-tree.mmdb",
+			IPs:    []string{"200.0.2.1", "::200.0.2.1", "ef00::", "1.1.1.1"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.DBFile, func(t *testing.T) {
+			reader, err := Open(testFile(test.DBFile))
+			require.NoError(t, err)
+			defer reader.Close()
+
+			// The network is reused across lookups of IPv4 and IPv6
+			// addresses.
+			var network net.IPNet
+			for _, ip := range test.IPs {
+				var expectedRecord, record any
+				expected, expectedOK, err := reader.LookupNetwork(net.ParseIP(ip), &expectedRecord)
+				require.NoError(t, err)
+				ok, err := reader.LookupNetworkInto(net.ParseIP(ip), &network, &record)
+				require.NoError(t, err)
+				assert.Equal(t, expectedOK, ok, ip)
+				assert.Equal(t, expectedRecord, record, ip)
+				assert.Equal(t, expected.String(), network.String(), ip)
+				assert.Equal(t, expected.Mask, network.Mask, ip)
+
+				prefix, _, err := reader.LookupNetworkPrefix(netip.MustParseAddr(ip), &record)
+				require.NoError(t, err)
+				assert.Equal(t, prefix.String(), network.String(), ip)
+			}
+
+			ok, err := reader.LookupNetworkInto(net.ParseIP("1.1.1.1"), nil, nil)
+			assert.False(t, ok)
+			assert.NoError(t, err)
+		})
+	}
+}
+
+func TestLookupNetworkIntoAllocations(t *testing.T) {
+	reader, err := Open(testFile("GeoIP2-City-Test.mmdb"))
+	require.NoError(t, err)
+	defer reader.Close()
+
+	var record struct {
+		Country struct {
+			IsoCode string `maxminddb:"iso_code"`
+		} `maxminddb:"country"`
+	}
+	ip := net.ParseIP("81.2.69.160")
+	lookupAllocs := testing.AllocsPerRun(100, func() {
+		_ = reader.Lookup(ip, &record)
+	})
+	var network net.IPNet
+	intoAllocs := testing.AllocsPerRun(100, func() {
+		_, _ = reader.LookupNetworkInto(ip, &network, &record)
+	})
+	networkAllocs := testing.AllocsPerRun(100, func() {
+		_, _, _ = reader.LookupNetwork(ip, &record)
+	})
+	assert.Equal(t, lookupAllocs, intoAllocs)
+	assert.Equal(t, lookupAllocs+1, networkAllocs)
+}
+
+func TestCIDRInto(t *testing.T) {
+	reader := &Reader{Metadata: Metadata{IPVersion: 6}, ipv4StartBitDepth: 96}
+	ips := []net.IP{
+		net.ParseIP("2001:db8:ffff:ffff:ffff:ffff:ffff:ffff"),
+		net.ParseIP("255.255.255.255").To4(),
+		net.ParseIP("81.2.69.160").To4(),
+	}
+
+	network := newIPNet()
+	addr := &network.IP[:1][0]
+	for _, ip := range ips {
+		bits := len(ip) * 8
+		for prefixLength := 0; prefixLength <= bits; prefixLength++ {
+			reader.cidrInto(network, ip, prefixLength)
+			mask := net.CIDRMask(prefixLength, bits)
+			assert.Equal(t, net.IPNet{IP: ip.Mask(mask), Mask: mask}, *network, "%s/%d", ip, prefixLength)
+		}
+	}
+	assert.Same(t, addr, &network.IP[0], "the memory of the address is reused")
+
+	// IPv4 lookups that end at the IPv4 start return its network.
+	reader.ipv4StartBitDepth = 8
+	reader.cidrInto(network, ips[1], 0)
+	assert.Equal(t, "::/8", network.String())
+	reader.cidrInto(network, ips[1], 24)
+	assert.Equal(t, "255.255.255.0/24", network.String())
+
+	reader.cidrInto(network, nil, 0)
+	assert.Equal(t, net.IPNet{}, *network)
+}
+
+func TestIPv4Start(t *testing.T) {
+	tests := []struct {
+		DBFile           string
+		ExpectedBitDepth int
+		ExpectedRecord   bool
+	}{
+		{DBFile: "MaxMind-DB-test-ipv4-24.mmdb", ExpectedBitDepth: 0},
+		{DBFile: "MaxMind-DB-test-ipv6-24.mmdb", ExpectedBitDepth: 96},
+		{DBFile: "MaxMind-DB-test-mixed-24.mmdb", ExpectedBitDepth: 96},
+		{DBFile: "GeoIP2-City-Test.mmdb", ExpectedBitDepth: 96},
+		// The record for ::/64 is reached before the IPv4 subtree.
+		{DBFile: "MaxMind-DB-no-ipv4-search-tree.mmdb", ExpectedBitDepth: 64, ExpectedRecord: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.DBFile, func(t *testing.T) {
+			reader, err := Open(testFile(test.DBFile), WithTreeCacheBits(0))
+			require.NoError(t, err)
+
+			assert.Equal(t, test.ExpectedBitDepth, reader.ipv4StartBitDepth)
+			assert.Equal(t, test.ExpectedRecord, reader.ipv4Start > reader.Metadata.NodeCount)
+
+			// Lookups of IPv4 addresses starting at the IPv4 start must
+			// match lookups of the same addresses in the IPv4 subtree.
+			if reader.Metadata.IPVersion == 6 {
+				for _, ip := range []string{"1.1.1.1", "1.1.1.3", "81.2.69.142", "200.0.2.1"} {
+					ipv4 := net.ParseIP(ip).To4()
+					ipv6 := append(make(net.IP, 12, net.IPv6len), ipv4...)
+
+					var ipv4Record, ipv6Record any
+					ipv4Network, ipv4OK, err := reader.LookupNetwork(ipv4, &ipv4Record)
+					require.NoError(t, err)
+					ipv6Network, ipv6OK, err := reader.LookupNetwork(ipv6, &ipv6Record)
+					require.NoError(t, err)
+
+					assert.Equal(t, ipv6OK, ipv4OK, ip)
+					assert.Equal(t, ipv6Record, ipv4Record, ip)
+					ones, _ := ipv4Network.Mask.Size()
+					ipv6Ones, _ := ipv6Network.Mask.Size()
+					if len(ipv4Network.IP) == net.IPv4len {
+						ones += 96
+					}
+					assert.Equal(t, ipv6Ones, ones, ip)
+				}
+			}
+			require.NoError(t, reader.Close())
+		})
+	}
+}
+
 func TestDecodingToInterface(t *testing.T) {
 	reader, err := Open(testFile("MaxMind-DB-test-decoder.mmdb"))
 	require.NoError(t, err, "unexpected error while opening database: %v", err)
@@ -332,6 +542,55 @@ func TestStructInterface(t *testing.T) {
 	assert.Equal(t, true, result.method())
 }
 
+type decoderKey string
+
+// upperKey is a map key decoded with UnmarshalText.
+type upperKey struct {
+	name string
+}
+
+var errKeyWithDigit = errors.New("key has a digit")
+
+func (k *upperKey) UnmarshalText(text []byte) error {
+	if bytes.ContainsAny(text, "0123456789") {
+		return errKeyWithDigit
+	}
+	k.name = strings.ToUpper(string(text))
+	return nil
+}
+
+func TestDecodeMapKeyTypes(t *testing.T) {
+	reader, err := Open(testFile("MaxMind-DB-test-decoder.mmdb"))
+	require.NoError(t, err)
+	defer reader.Close()
+	ip := net.ParseIP("::1.1.1.0")
+
+	var named map[decoderKey]any
+	require.NoError(t, reader.Lookup(ip, &named))
+	assert.Equal(t, "unicode! \u262f - \u266b", named["utf8_string"])
+	assert.Equal(t, uint64(100), named["uint16"])
+
+	var text struct {
+		Map map[upperKey]map[upperKey]any `maxminddb:"map"`
+	}
+	require.NoError(t, reader.Lookup(ip, &text))
+	assert.Equal(
+		t,
+		map[upperKey]map[upperKey]any{
+			{name: "MAPX"}: {
+				{name: "ARRAYX"}:       []any{uint64(7), uint64(8), uint64(9)},
+				{name: "UTF8_STRINGX"}: "hello",
+			},
+		},
+		text.Map,
+	)
+
+	var bad map[upperKey]any
+	err = reader.Lookup(ip, &bad)
+	require.ErrorIs(t, err, errKeyWithDigit)
+	assert.Regexp(t, `^maxminddb: cannot unmarshal "[a-z0-9_]*[0-9][a-z0-9_]*" map key into type maxminddb.upperKey: `, err.Error())
+}
+
 func TestNonEmptyNilInterface(t *testing.T) {
 	var result TestInterface
 
@@ -364,6 +623,73 @@ func TestEmbeddedStructAsInterface(t *testing.T) {
 	assert.NoError(t, db.Lookup(net.ParseIP("1.128.0.0"), &result))
 }
 
+type inlineNumbers struct {
+	Boolean bool   `maxminddb:"boolean"`
+	Uint16  uint16 `maxminddb:"uint16"`
+	Uint32  uint32 `maxminddb:"uint32"`
+}
+
+type inlineNested struct {
+	Double float64 `maxminddb:"double"`
+	Int32  int32   `maxminddb:"int32"`
+}
+
+type inlineFlags struct {
+	Nested  *inlineNested `maxminddb:",inline"`
+	Boolean bool          `maxminddb:"boolean"`
+	Double  float64       `maxminddb:"double"`
+	Uint16  uint16        `maxminddb:"uint16"`
+}
+
+type inlineUnused struct {
+	NotInDatabase string `maxminddb:"not_in_database"`
+}
+
+type inlineRecord struct {
+	Unused  *inlineUnused `maxminddb:",inline"`
+	Flags   *inlineFlags  `maxminddb:"flags,inline"`
+	Numbers inlineNumbers `maxminddb:",inline"`
+	Boolean bool          `maxminddb:"boolean"`
+}
+
+func TestInlineStruct(t *testing.T) {
+	reader, err := Open(testFile("MaxMind-DB-test-decoder.mmdb"))
+	require.NoError(t, err)
+
+	var result inlineRecord
+	require.NoError(t, reader.Lookup(net.ParseIP("::1.1.1.0"), &result))
+
+	assert.True(t, result.Boolean)
+	assert.Nil(t, result.Unused, "no keys for the inline struct")
+	assert.Equal(
+		t,
+		inlineNumbers{
+			// A field of the struct takes precedence over fields of inline
+			// structs.
+			Boolean: false,
+			// The field of the inline struct declared first takes precedence.
+			Uint16: 0,
+			Uint32: 268435456,
+		},
+		result.Numbers,
+	)
+	require.NotNil(t, result.Flags)
+	assert.Equal(
+		t,
+		inlineFlags{
+			// A less deeply nested field takes precedence.
+			Nested: &inlineNested{Int32: -268435456},
+			// A field of the struct takes precedence.
+			Boolean: false,
+			Double:  42.123456,
+			Uint16:  100,
+		},
+		*result.Flags,
+	)
+
+	assert.NoError(t, reader.Close())
+}
+
 type BoolInterface interface {
 	true() bool
 }
@@ -568,6 +894,41 @@ func TestDecodingUint16IntoInt(t *testing.T) {
 	assert.Equal(t, 100, result.Uint16)
 }
 
+func TestLookupMulti(t *testing.T) {
+	reader, err := Open(testFile("MaxMind-DB-test-decoder.mmdb"))
+	require.NoError(t, err)
+
+	var small struct {
+		Uint16 uint16 `maxminddb:"uint16"`
+	}
+	var full TestType
+	ok, err := reader.LookupMulti(net.ParseIP("::1.1.1.0"), &small, nil, &full)
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, uint16(100), small.Uint16)
+	assert.Equal(t, "unicode! ☯ - ♫", full.Utf8String)
+
+	var bad struct {
+		Utf8String int `maxminddb:"utf8_string"`
+	}
+	ok, err = reader.LookupMulti(net.ParseIP("::1.1.1.0"), &small, &bad)
+	assert.True(t, ok)
+	var typeErr UnmarshalTypeError
+	assert.ErrorAs(t, err, &typeErr)
+	assert.Contains(t, err.Error(), "target 1")
+
+	assert.NoError(t, reader.Close())
+
+	reader, err = Open(testFile("MaxMind-DB-test-ipv6-32.mmdb"))
+	require.NoError(t, err)
+
+	ok, err = reader.LookupMulti(net.ParseIP("1.1.1.1"), &small)
+	require.NoError(t, err)
+	assert.False(t, ok)
+
+	assert.NoError(t, reader.Close())
+}
+
 func TestIpv6inIpv4(t *testing.T) {
 	reader, err := Open(testFile("MaxMind-DB-test-ipv4-24.mmdb"))
 	require.NoError(t, err, "unexpected error while opening database: %v", err)
@@ -599,6 +960,53 @@ func TestBrokenDoubleDatabase(t *testing.T) {
 	assert.NoError(t, reader.Close(), "error on close")
 }
 
+func TestSoftFailDecode(t *testing.T) {
+	reader, err := Open(
+		testFile("GeoIP2-City-Test-Broken-Double-Format.mmdb"),
+		WithSoftFailDecode(),
+	)
+	require.NoError(t, err, "unexpected error while opening database: %v", err)
+
+	var result map[string]any
+	err = reader.Lookup(net.ParseIP("2001:220::"), &result)
+
+	var fieldErrors FieldErrors
+	require.ErrorAs(t, err, &fieldErrors)
+	require.NotEmpty(t, fieldErrors)
+	for _, fieldError := range fieldErrors {
+		assert.NotEmpty(t, fieldError.Path)
+		assert.Equal(
+			t,
+			newInvalidDatabaseError(
+				"the MaxMind DB file's data section contains bad data (float 64 size of 2)",
+			),
+			fieldError.Err,
+		)
+	}
+	var invalidDatabaseError InvalidDatabaseError
+	assert.ErrorAs(t, err, &invalidDatabaseError)
+
+	// The rest of the record is still decoded.
+	assert.Equal(t, "KR", result["country"].(map[string]any)["iso_code"])
+
+	var city struct {
+		Country struct {
+			ISOCode string `maxminddb:"iso_code"`
+		} `maxminddb:"country"`
+		Location struct {
+			Latitude  float64 `maxminddb:"latitude"`
+			Longitude float64 `maxminddb:"longitude"`
+			TimeZone  string  `maxminddb:"time_zone"`
+		} `maxminddb:"location"`
+	}
+	err = reader.Lookup(net.ParseIP("2001:220::"), &city)
+	require.ErrorAs(t, err, &fieldErrors)
+	assert.Equal(t, "KR", city.Country.ISOCode)
+
+	assert.Error(t, reader.Verify(), "verification is not affected by WithSoftFailDecode")
+	assert.NoError(t, reader.Close(), "error on close")
+}
+
 func TestInvalidNodeCountDatabase(t *testing.T) {
 	_, err := Open(testFile("GeoIP2-City-Test-Invalid-Node-Count.mmdb"))
 
@@ -618,6 +1026,340 @@ func TestNonDatabase(t *testing.T) {
 	assert.Equal(t, "error opening database: invalid MaxMind DB file", err.Error())
 }
 
+func TestMinimumBuildTime(t *testing.T) {
+	fileName := testFile("MaxMind-DB-test-decoder.mmdb")
+	reader, err := Open(fileName)
+	require.NoError(t, err)
+	buildTime := time.Unix(int64(reader.Metadata.BuildEpoch), 0)
+	require.NoError(t, reader.Close())
+
+	reader, err = Open(fileName, WithMinimumBuildTime(buildTime))
+	require.NoError(t, err)
+	require.NoError(t, reader.Close())
+
+	threshold := buildTime.Add(time.Hour)
+	reader, err = Open(fileName, WithMinimumBuildTime(threshold))
+	assert.Nil(t, reader)
+	assert.ErrorIs(t, err, ErrDatabaseTooOld)
+	var tooOld DatabaseTooOldError
+	require.ErrorAs(t, err, &tooOld)
+	assert.True(t, buildTime.Equal(tooOld.BuildTime))
+	assert.True(t, threshold.Equal(tooOld.MinimumBuildTime))
+
+	_, err = Open(fileName, WithMaxAge(time.Since(buildTime)-time.Hour))
+	assert.ErrorIs(t, err, ErrDatabaseTooOld)
+
+	reader, err = Open(fileName, WithMaxAge(time.Since(buildTime)+time.Hour))
+	require.NoError(t, err)
+	require.NoError(t, reader.Close())
+
+	var reported []DatabaseTooOldError
+	reader, err = Open(
+		fileName,
+		WithMinimumBuildTime(threshold),
+		WithStalenessHook(func(err DatabaseTooOldError) {
+			reported = append(reported, err)
+		}),
+	)
+	require.NoError(t, err)
+	require.Len(t, reported, 1)
+	assert.True(t, threshold.Equal(reported[0].MinimumBuildTime))
+	require.NoError(t, reader.Close())
+}
+
+func TestFormatVersion(t *testing.T) {
+	majorVersion := func(value ...byte) []byte {
+		return append(append([]byte{0x5b}, "binary_format_major_version"...), value...)
+	}
+	minorVersion := func(value ...byte) []byte {
+		return append(append([]byte{0x5b}, "binary_format_minor_version"...), value...)
+	}
+
+	buffer := patchedMetadata(t, majorVersion(0xa1, 0x02), majorVersion(0xa1, 0x03))
+	reader, err := FromBytes(buffer)
+	assert.Nil(t, reader)
+	assert.ErrorIs(t, err, ErrUnsupportedFormatVersion)
+	assert.Equal(t, UnsupportedFormatVersionError{MajorVersion: 3, MinorVersion: 0}, err)
+
+	buffer = patchedMetadata(t, minorVersion(0xa0), minorVersion(0xa1, 0x01))
+	var warnings []string
+	reader, err = FromBytes(buffer, WithWarningHook(func(warning string) {
+		warnings = append(warnings, warning)
+	}))
+	require.NoError(t, err)
+	assert.Equal(t, uint(1), reader.Metadata.BinaryFormatMinorVersion)
+	expected := []string{"binary format version 2.1 is newer than the supported version 2.0"}
+	assert.Equal(t, expected, reader.Metadata.Warnings)
+	assert.Equal(t, expected, warnings)
+	checkIpv4(t, reader)
+	require.NoError(t, reader.Close())
+
+	reader, err = Open(testFile("MaxMind-DB-test-ipv4-24.mmdb"))
+	require.NoError(t, err)
+	assert.Empty(t, reader.Metadata.Warnings)
+	require.NoError(t, reader.Close())
+}
+
+// patchedMetadata returns the contents of MaxMind-DB-test-ipv4-24.mmdb with
+// from, which must occur once in its metadata, replaced by to.
+func patchedMetadata(t *testing.T, from, to []byte) []byte {
+	buffer, err := os.ReadFile(testFile("MaxMind-DB-test-ipv4-24.mmdb"))
+	require.NoError(t, err)
+
+	metadataStart := bytes.LastIndex(buffer, metadataStartMarker)
+	require.NotEqual(t, -1, metadataStart)
+	metadata := buffer[metadataStart:]
+	require.Equal(t, 1, bytes.Count(metadata, from))
+
+	patched := append([]byte{}, buffer[:metadataStart]...)
+	return append(patched, bytes.Replace(metadata, from, to, 1)...)
+}
+
+// patchedRecord returns a copy of buffer, a database with 24-bit records,
+// with the record found for ip replaced by value.
+func patchedRecord(t *testing.T, buffer []byte, ip net.IP, value uint) []byte {
+	reader, err := FromBytes(buffer)
+	require.NoError(t, err)
+	_, prefixLength, ip, err := reader.lookupRecord(ip)
+	require.NoError(t, err)
+	require.Equal(t, uint(24), reader.Metadata.RecordSize)
+
+	offset := reader.recordNode(ip, prefixLength) * reader.nodeOffsetMult
+	if ip[(prefixLength-1)/8]&(1<<(7-(prefixLength-1)%8)) != 0 {
+		offset += 3
+	}
+	patched := append([]byte{}, buffer...)
+	patched[offset] = byte(value >> 16)
+	patched[offset+1] = byte(value >> 8)
+	patched[offset+2] = byte(value)
+	return patched
+}
+
+func TestInvalidDataPointer(t *testing.T) {
+	buffer, err := os.ReadFile(testFile("MaxMind-DB-test-ipv4-24.mmdb"))
+	require.NoError(t, err)
+	reader, err := FromBytes(buffer)
+	require.NoError(t, err)
+	nodeCount := reader.Metadata.NodeCount
+	dataSectionSize := len(reader.decoder.buffer)
+	ip := net.ParseIP("1.1.1.1")
+	_, prefixLength, ip4, err := reader.lookupRecord(ip)
+	require.NoError(t, err)
+	node := reader.recordNode(ip4, prefixLength)
+
+	tests := []struct {
+		name   string
+		value  uint
+		offset int
+	}{
+		{name: "into the separator", value: nodeCount + 1, offset: -15},
+		{
+			name:   "past the data section",
+			value:  nodeCount + dataSectionSeparatorSize + uint(dataSectionSize),
+			offset: dataSectionSize,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			reader, err := FromBytes(patchedRecord(t, buffer, ip, test.value))
+			require.NoError(t, err)
+
+			expected := fmt.Sprintf(
+				"the MaxMind DB file's search tree is corrupt: the record value %d in node %d "+
+					"resolves to offset %d, outside of the data section of %d bytes",
+				test.value,
+				node,
+				test.offset,
+				dataSectionSize,
+			)
+
+			var record any
+			err = reader.Lookup(ip, &record)
+			var invalidErr InvalidDatabaseError
+			require.ErrorAs(t, err, &invalidErr)
+			assert.EqualError(t, err, expected)
+
+			_, _, err = reader.LookupNetwork(ip, &record)
+			assert.EqualError(t, err, expected)
+
+			_, err = reader.LookupOffset(ip)
+			assert.EqualError(t, err, expected)
+
+			_, err = reader.LookupMulti(ip, &record)
+			assert.EqualError(t, err, expected)
+
+			n := reader.Networks()
+			found := false
+			for n.Next() {
+				network, err := n.Network(&record)
+				if err != nil {
+					assert.EqualError(t, err, expected)
+					found = true
+					continue
+				}
+				assert.False(t, network.Contains(ip))
+			}
+			require.NoError(t, n.Err())
+			assert.True(t, found)
+
+			assert.EqualError(t, reader.Verify(), expected)
+		})
+	}
+}
+
+func TestMaxDatabaseSize(t *testing.T) {
+	file := testFile("MaxMind-DB-test-ipv4-24.mmdb")
+	buffer, err := os.ReadFile(file)
+	require.NoError(t, err)
+	size := int64(len(buffer))
+
+	reader, err := Open(file, WithMaxDatabaseSize(size))
+	require.NoError(t, err)
+	require.NoError(t, reader.Close())
+	reader, err = FromBytes(buffer, WithMaxDatabaseSize(size))
+	require.NoError(t, err)
+	require.NoError(t, reader.Close())
+
+	expected := DatabaseTooLargeError{Limit: size - 1, Size: size}
+	_, err = Open(file, WithMaxDatabaseSize(size-1))
+	assert.Equal(t, expected, err)
+	assert.ErrorIs(t, err, ErrDatabaseTooLarge)
+	assert.EqualError(
+		t,
+		err,
+		fmt.Sprintf("maxminddb: database of %d bytes exceeds the limit of %d bytes", size, size-1),
+	)
+
+	_, err = FromBytes(buffer, WithMaxDatabaseSize(size-1))
+	assert.Equal(t, expected, err)
+
+	// FromReader stops reading at the limit, so the size is not known.
+	_, err = FromReader(bytes.NewReader(buffer), WithMaxDatabaseSize(size-1))
+	assert.Equal(t, DatabaseTooLargeError{Limit: size - 1}, err)
+}
+
+func TestMaxDecodedBytes(t *testing.T) {
+	reader, err := Open(testFile("MaxMind-DB-test-decoder.mmdb"), WithMaxDecodedBytes(10))
+	require.NoError(t, err)
+
+	var record any
+	err = reader.Lookup(net.ParseIP("::1.1.1.0"), &record)
+	assert.Equal(t, DecodedSizeLimitError{Limit: 10}, err)
+
+	var result struct {
+		Uint16 uint16 `maxminddb:"uint16"`
+	}
+	require.NoError(t, reader.Lookup(net.ParseIP("::1.1.1.0"), &result))
+	assert.Equal(t, uint16(100), result.Uint16)
+	require.NoError(t, reader.Close())
+
+	reader, err = Open(testFile("MaxMind-DB-test-decoder.mmdb"), WithMaxDecodedBytes(1<<20))
+	require.NoError(t, err)
+
+	require.NoError(t, reader.Lookup(net.ParseIP("::1.1.1.0"), &record))
+	checkDecodingToInterface(t, record)
+	require.NoError(t, reader.Close())
+}
+
+func TestNegativeCache(t *testing.T) {
+	fileName := testFile("GeoIP2-City-Test.mmdb")
+	reader, err := Open(fileName)
+	require.NoError(t, err)
+	cachedReader, err := Open(fileName, WithNegativeCache(64))
+	require.NoError(t, err)
+
+	//nolint:gosec // this is a test
+	r := rand.New(rand.NewSource(0))
+	ip := make(net.IP, 4)
+	for i := 0; i < 10000; i++ {
+		randomIPv4Address(r, ip)
+
+		var expected, actual any
+		expectedNetwork, expectedOK, err := reader.LookupNetwork(ip, &expected)
+		require.NoError(t, err)
+		for j := 0; j < 2; j++ {
+			network, ok, err := cachedReader.LookupNetwork(ip, &actual)
+			require.NoError(t, err)
+			assert.Equal(t, expectedOK, ok)
+			assert.Equal(t, expectedNetwork.String(), network.String())
+			assert.Equal(t, expected, actual)
+		}
+	}
+
+	assert.NoError(t, reader.Close())
+	assert.NoError(t, cachedReader.Close())
+}
+
+func TestNegativeCacheFragmented(t *testing.T) {
+	c := newNegativeCache(1 << 16)
+
+	// Empty networks of different sizes within one /16 and one /48 do not
+	// evict each other.
+	var networks []*net.IPNet
+	for i := 0; i < 32; i++ {
+		for _, network := range []string{
+			fmt.Sprintf("10.1.%d.0/24", 2*i),
+			fmt.Sprintf("10.1.%d.128/%d", 2*i+1, 25+i%7),
+			fmt.Sprintf("2001:db8:1:%x::/64", i),
+			fmt.Sprintf("2001:db8:1:%x:8000::/%d", i+256, 65+i),
+		} {
+			_, ipNet, err := net.ParseCIDR(network)
+			require.NoError(t, err)
+			if ip4 := ipNet.IP.To4(); ip4 != nil {
+				ipNet.IP = ip4
+			}
+			prefixLength, _ := ipNet.Mask.Size()
+			c.add(ipNet.IP, prefixLength)
+			networks = append(networks, ipNet)
+		}
+	}
+	for _, network := range networks {
+		expected, _ := network.Mask.Size()
+		ip := make(net.IP, len(network.IP))
+		copy(ip, network.IP)
+		ip[len(ip)-1] |= 1
+		prefixLength, ok := c.get(ip)
+		if assert.True(t, ok, network.String()) {
+			assert.Equal(t, expected, prefixLength, network.String())
+		}
+	}
+
+	for _, ip := range []string{"10.1.1.1", "10.2.0.0", "2001:db8:1:ff::", "2001:db8:2::"} {
+		parsed := net.ParseIP(ip)
+		if ip4 := parsed.To4(); ip4 != nil {
+			parsed = ip4
+		}
+		_, ok := c.get(parsed)
+		assert.False(t, ok, ip)
+	}
+
+	c.clear()
+	_, ok := c.get(networks[0].IP)
+	assert.False(t, ok)
+}
+
+func TestResultsOutliveClose(t *testing.T) {
+	buffer, err := os.ReadFile(testFile("MaxMind-DB-test-decoder.mmdb"))
+	require.NoError(t, err)
+
+	reader, err := FromBytes(buffer, WithDetachedResults(), WithPoisonOnClose())
+	require.NoError(t, err)
+
+	var result TestType
+	require.NoError(t, reader.Lookup(net.ParseIP("::1.1.1.0"), &result))
+	var record any
+	require.NoError(t, reader.Lookup(net.ParseIP("::1.1.1.0"), &record))
+	require.NoError(t, reader.Close())
+
+	for _, b := range buffer {
+		require.Equal(t, byte(poisonByte), b)
+	}
+
+	assert.Equal(t, []byte{0x00, 0x00, 0x00, 0x2a}, result.Bytes)
+	assert.Equal(t, "unicode! ☯ - ♫", result.Utf8String)
+	checkDecodingToInterface(t, record)
+}
+
 func TestDecodingToNonPointer(t *testing.T) {
 	reader, err := Open(testFile("MaxMind-DB-test-decoder.mmdb"))
 	require.NoError(t, err)
@@ -628,6 +1370,68 @@ func TestDecodingToNonPointer(t *testing.T) {
 	assert.NoError(t, reader.Close(), "error on close")
 }
 
+func TestDecodeValue(t *testing.T) {
+	reader, err := Open(testFile("MaxMind-DB-test-decoder.mmdb"))
+	require.NoError(t, err)
+
+	offset, err := reader.LookupOffset(net.ParseIP("::1.1.1.0"))
+	require.NoError(t, err)
+
+	mapType := reflect.StructOf([]reflect.StructField{
+		{
+			Name: "UTF8StringX",
+			Type: reflect.TypeOf(""),
+			Tag:  `maxminddb:"utf8_stringX"`,
+		},
+	})
+	recordType := reflect.StructOf([]reflect.StructField{
+		{
+			Name: "Uint16",
+			Type: reflect.TypeOf(uint16(0)),
+			Tag:  `maxminddb:"uint16"`,
+		},
+		{
+			Name: "Array",
+			Type: reflect.TypeOf([]uint{}),
+			Tag:  `maxminddb:"array"`,
+		},
+		{
+			Name: "Map",
+			Type: reflect.MapOf(reflect.TypeOf(""), mapType),
+			Tag:  `maxminddb:"map"`,
+		},
+	})
+
+	// Decoding twice also exercises the cached fields of the run-time type.
+	for i := 0; i < 2; i++ {
+		v := reflect.New(recordType).Elem()
+		require.NoError(t, reader.DecodeValue(offset, v))
+
+		assert.Equal(t, uint16(100), v.Field(0).Interface())
+		assert.Equal(t, []uint{1, 2, 3}, v.Field(1).Interface())
+		mapX := v.Field(2).MapIndex(reflect.ValueOf("mapX"))
+		require.True(t, mapX.IsValid())
+		assert.Equal(t, "hello", mapX.Field(0).Interface())
+	}
+
+	var result TestType
+	require.NoError(t, reader.DecodeValue(offset, reflect.ValueOf(&result).Elem()))
+	assert.Equal(t, uint16(100), result.Uint16)
+
+	err = reader.DecodeValue(offset, reflect.ValueOf(result))
+	assert.EqualError(
+		t,
+		err,
+		"value passed to DecodeValue must be settable, e.g., obtained from reflect.New(t).Elem()",
+	)
+	err = reader.DecodeValue(offset, reflect.Value{})
+	assert.Error(t, err)
+
+	require.NoError(t, reader.Close())
+	err = reader.DecodeValue(offset, reflect.New(recordType).Elem())
+	assert.EqualError(t, err, "cannot call DecodeValue on a closed database")
+}
+
 func TestNilLookup(t *testing.T) {
 	reader, err := Open(testFile("MaxMind-DB-test-decoder.mmdb"))
 	require.NoError(t, err)
@@ -871,6 +1675,53 @@ func BenchmarkCityLookup(b *testing.B) {
 	r := rand.New(rand.NewSource(time.Now().UnixNano()))
 	var result fullCity
 
+	b.ReportAllocs()
+	ip := make(net.IP, 4)
+	for i := 0; i < b.N; i++ {
+		randomIPv4Address(r, ip)
+		err = db.Lookup(ip, &result)
+		if err != nil {
+			b.Error(err)
+		}
+	}
+	assert.NoError(b, db.Close(), "error on close")
+}
+
+// BenchmarkCityLookupSession is BenchmarkCityLookup with the lookups made
+// through a Session.
+func BenchmarkCityLookupSession(b *testing.B) {
+	db, err := Open("GeoLite2-City.mmdb")
+	require.NoError(b, err)
+	session := db.NewSession()
+
+	//nolint:gosec // this is a test
+	r := rand.New(rand.NewSource(time.Now().UnixNano()))
+	var result fullCity
+
+	b.ReportAllocs()
+	ip := make(net.IP, 4)
+	for i := 0; i < b.N; i++ {
+		randomIPv4Address(r, ip)
+		err = session.Lookup(ip, &result)
+		if err != nil {
+			b.Error(err)
+		}
+	}
+	assert.NoError(b, db.Close(), "error on close")
+}
+
+// BenchmarkCityLookupWithoutInterning is BenchmarkCityLookup with the
+// strings interned by Readers from Open copied on every lookup instead.
+func BenchmarkCityLookupWithoutInterning(b *testing.B) {
+	db, err := Open("GeoLite2-City.mmdb")
+	require.NoError(b, err)
+	db.decoder.strings = nil
+
+	//nolint:gosec // this is a test
+	r := rand.New(rand.NewSource(time.Now().UnixNano()))
+	var result fullCity
+
+	b.ReportAllocs()
 	ip := make(net.IP, 4)
 	for i := 0; i < b.N; i++ {
 		randomIPv4Address(r, ip)
@@ -890,6 +1741,7 @@ func BenchmarkCityLookupNetwork(b *testing.B) {
 	r := rand.New(rand.NewSource(time.Now().UnixNano()))
 	var result fullCity
 
+	b.ReportAllocs()
 	ip := make(net.IP, 4)
 	for i := 0; i < b.N; i++ {
 		randomIPv4Address(r, ip)
@@ -901,6 +1753,70 @@ func BenchmarkCityLookupNetwork(b *testing.B) {
 	assert.NoError(b, db.Close(), "error on close")
 }
 
+func BenchmarkCityLookupNetworkInto(b *testing.B) {
+	db, err := Open("GeoLite2-City.mmdb")
+	require.NoError(b, err)
+
+	//nolint:gosec // this is a test
+	r := rand.New(rand.NewSource(time.Now().UnixNano()))
+	var result fullCity
+	var network net.IPNet
+
+	b.ReportAllocs()
+	ip := make(net.IP, 4)
+	for i := 0; i < b.N; i++ {
+		randomIPv4Address(r, ip)
+		_, err = db.LookupNetworkInto(ip, &network, &result)
+		if err != nil {
+			b.Error(err)
+		}
+	}
+	assert.NoError(b, db.Close(), "error on close")
+}
+
+func BenchmarkNegativeCache(b *testing.B) {
+	fileName := testFile("GeoIP2-City-Test.mmdb")
+	db, err := Open(fileName)
+	require.NoError(b, err)
+
+	// Draw addresses from the unpopulated space of the database.
+	//nolint:gosec // this is a test
+	r := rand.New(rand.NewSource(0))
+	var ips []net.IP
+	for len(ips) < 1000 {
+		ip := make(net.IP, 4)
+		randomIPv4Address(r, ip)
+		offset, err := db.LookupOffset(ip)
+		require.NoError(b, err)
+		if offset == NotFound {
+			ips = append(ips, ip)
+		}
+	}
+	assert.NoError(b, db.Close(), "error on close")
+
+	for _, test := range []struct {
+		name    string
+		options []ReaderOption
+	}{
+		{name: "without cache"},
+		{name: "with cache", options: []ReaderOption{WithNegativeCache(4096)}},
+	} {
+		b.Run(test.name, func(b *testing.B) {
+			db, err := Open(fileName, test.options...)
+			require.NoError(b, err)
+
+			var result any
+			for i := 0; i < b.N; i++ {
+				err = db.Lookup(ips[i%len(ips)], &result)
+				if err != nil {
+					b.Error(err)
+				}
+			}
+			assert.NoError(b, db.Close(), "error on close")
+		})
+	}
+}
+
 func BenchmarkCountryCode(b *testing.B) {
 	db, err := Open("GeoLite2-City.mmdb")
 	require.NoError(b, err)
@@ -926,6 +1842,70 @@ func BenchmarkCountryCode(b *testing.B) {
 	assert.NoError(b, db.Close(), "error on close")
 }
 
+// BenchmarkCountryCodeDecodePath extracts the same value as
+// BenchmarkCountryCode without decoding the record into a struct.
+func BenchmarkCountryCodeDecodePath(b *testing.B) {
+	db, err := Open("GeoLite2-City.mmdb")
+	require.NoError(b, err)
+
+	//nolint:gosec // this is a test
+	r := rand.New(rand.NewSource(0))
+	var isoCode string
+
+	ip := make(net.IP, 4)
+	for i := 0; i < b.N; i++ {
+		randomIPv4Address(r, ip)
+		offset, err := db.LookupOffset(ip)
+		if err != nil {
+			b.Error(err)
+		}
+		if offset == NotFound {
+			continue
+		}
+		err = db.DecodePath(offset, &isoCode, "country", "iso_code")
+		if err != nil && !errors.Is(err, ErrPathNotFound) {
+			b.Error(err)
+		}
+	}
+	assert.NoError(b, db.Close(), "error on close")
+}
+
+func BenchmarkIPv4LookupOffset(b *testing.B) {
+	// IPv4 lookups start at the root of the IPv4 subtree. Looking up the
+	// same addresses in their IPv4-compatible IPv6 form, e.g., ::1.2.3.4,
+	// traverses the 96 bits leading to it.
+	for _, test := range []struct {
+		name string
+		ip   func(net.IP) net.IP
+	}{
+		{name: "IPv4", ip: func(ip net.IP) net.IP { return ip }},
+		{
+			name: "IPv4-compatible IPv6",
+			ip: func(ip net.IP) net.IP {
+				return append(make(net.IP, 12, net.IPv6len), ip...)
+			},
+		},
+	} {
+		b.Run(test.name, func(b *testing.B) {
+			db, err := Open("GeoLite2-City.mmdb", WithTreeCacheBits(0))
+			require.NoError(b, err)
+
+			//nolint:gosec // this is a test
+			r := rand.New(rand.NewSource(0))
+
+			ip := make(net.IP, 4)
+			for i := 0; i < b.N; i++ {
+				randomIPv4Address(r, ip)
+				_, err = db.LookupOffset(test.ip(ip))
+				if err != nil {
+					b.Error(err)
+				}
+			}
+			assert.NoError(b, db.Close(), "error on close")
+		})
+	}
+}
+
 func randomIPv4Address(r *rand.Rand, ip []byte) {
 	num := r.Uint32()
 	ip[0] = byte(num >> 24)
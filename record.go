@@ -0,0 +1,141 @@
+package maxminddb
+
+import "errors"
+
+// maxRecordIndexEntries is the largest number of entries of a record that a
+// Record indexes. The index of a larger record would use more memory than
+// re-reading the record saves, and such records are decoded as by Decode.
+const maxRecordIndexEntries = 256
+
+// Record is a record in the data section that may be decoded several times,
+// e.g., into a struct for processing and into a map[string]any for logging.
+// It is returned by Reader.Record.
+//
+// The first Decode of a record that is a map retains an index of the keys
+// of the map and the offsets of their values, with pointers resolved, so
+// that later decodes do not re-read the keys or follow the pointers again.
+// Values that are not decoded into a struct field are also not skipped
+// over. The index holds at most 256 entries; larger records are decoded as
+// by Reader.Decode.
+//
+// A Record is not safe for concurrent use by multiple goroutines.
+type Record struct {
+	reader  *Reader
+	index   *recordIndex
+	offset  uintptr
+	indexed bool
+}
+
+// recordIndex is the index of the entries of the map at the start of a
+// record.
+type recordIndex struct {
+	entries []recordIndexEntry
+	// start is the offset of the first entry of the map and end the offset
+	// following the last entry.
+	start uint
+	end   uint
+}
+
+type recordIndexEntry struct {
+	key   []byte
+	value uint
+}
+
+// Record returns a Record for the record at offset, e.g., as returned by
+// LookupOffset. No data is read until the Record is decoded.
+func (r *Reader) Record(offset uintptr) *Record {
+	return &Record{reader: r, offset: offset}
+}
+
+// Offset returns the offset of the record in the data section.
+func (rec *Record) Offset() uintptr {
+	return rec.offset
+}
+
+// Decode decodes the record into the value pointed to by result. See
+// Reader.Decode.
+func (rec *Record) Decode(result any) error {
+	r := rec.reader
+	if r.buffer == nil {
+		return errors.New("cannot call Decode on a closed database")
+	}
+	if !rec.indexed {
+		rec.index = r.decoder.indexRecord(uint(rec.offset))
+		rec.indexed = true
+	}
+	return r.decodeWithOptions(rec.offset, result, lookupOptions{index: rec.index})
+}
+
+// indexRecord returns the index of the map at offset or nil if the value at
+// offset is not a map, has more than maxRecordIndexEntries entries, or
+// cannot be read. The decode of a record without an index reports why it
+// cannot be read.
+func (d *decoder) indexRecord(offset uint) *recordIndex {
+	typeNum, size, offset, err := d.decodeCtrlData(offset)
+	if err == nil && typeNum == _Pointer {
+		var pointer uint
+		pointer, _, err = d.decodePointer(size, offset)
+		if err != nil {
+			return nil
+		}
+		typeNum, size, offset, err = d.decodeCtrlData(pointer)
+	}
+	if err != nil || typeNum != _Map || size > maxRecordIndexEntries {
+		return nil
+	}
+	if d.checkContainerSize(2*size, offset) != nil {
+		return nil
+	}
+
+	index := &recordIndex{
+		entries: make([]recordIndexEntry, size),
+		start:   offset,
+	}
+	for i := range index.entries {
+		key, valueOffset, err := d.decodeKey(offset)
+		if err != nil {
+			return nil
+		}
+		typeNum, size, dataOffset, err := d.decodeCtrlData(valueOffset)
+		if err != nil {
+			return nil
+		}
+		if typeNum == _Pointer {
+			var pointer uint
+			pointer, offset, err = d.decodePointer(size, dataOffset)
+			if err != nil {
+				return nil
+			}
+			valueOffset = pointer
+		} else {
+			offset, err = d.nextValueOffset(valueOffset, 1)
+			if err != nil {
+				return nil
+			}
+		}
+		index.entries[i] = recordIndexEntry{key: key, value: valueOffset}
+	}
+	index.end = offset
+	return index
+}
+
+// indexedMap returns the index of the map whose entries start at offset, if
+// there is one.
+func (d *decoder) indexedMap(offset uint) *recordIndex {
+	if d.index != nil && d.index.start == offset {
+		return d.index
+	}
+	return nil
+}
+
+// mapEntry returns the key and value offset of the i-th entry of a map,
+// which is at offset, from index or, if index is nil, from the buffer. As
+// the value offsets in index have their pointers resolved, the offset
+// following such a value is not that of the next entry.
+func (d *decoder) mapEntry(index *recordIndex, i, offset uint) ([]byte, uint, error) {
+	if index != nil {
+		e := index.entries[i]
+		return e.key, e.value, nil
+	}
+	return d.decodeKey(offset)
+}
@@ -0,0 +1,147 @@
+package maxminddb
+
+import (
+	"container/list"
+	"maps"
+	"math/big"
+
+	"github.com/3JoB/go-reflect"
+)
+
+// recordCache is a least-recently-used cache of decoded records used by
+// Networks when the CacheDecodedRecords option is set. It is not safe for
+// concurrent use.
+type recordCache struct {
+	entries map[recordCacheKey]*list.Element
+	order   *list.List
+	size    int
+}
+
+// Records are cached per result type as the same record decodes to
+// different values for different types.
+type recordCacheKey struct {
+	typ    reflect.Type
+	offset uintptr
+}
+
+type recordCacheEntry struct {
+	value reflect.Value
+	key   recordCacheKey
+}
+
+func newRecordCache(size int) *recordCache {
+	return &recordCache{
+		entries: make(map[recordCacheKey]*list.Element, size),
+		order:   list.New(),
+		size:    size,
+	}
+}
+
+func (c *recordCache) get(key recordCacheKey) (reflect.Value, bool) {
+	e, ok := c.entries[key]
+	if !ok {
+		return reflect.Value{}, false
+	}
+	c.order.MoveToFront(e)
+	return e.Value.(*recordCacheEntry).value, true
+}
+
+func (c *recordCache) add(key recordCacheKey, value reflect.Value) {
+	if e, ok := c.entries[key]; ok {
+		e.Value.(*recordCacheEntry).value = value
+		c.order.MoveToFront(e)
+		return
+	}
+	if c.order.Len() >= c.size {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*recordCacheEntry).key)
+	}
+	c.entries[key] = c.order.PushFront(&recordCacheEntry{key: key, value: value})
+}
+
+var bigIntPtrType = reflect.TypeOf((*big.Int)(nil))
+
+// deepCopy returns a copy of v that shares no maps, slices, or pointers
+// with it. Unexported struct fields, which the decoder never sets, are
+// copied shallowly.
+func deepCopy(v reflect.Value) reflect.Value {
+	switch v.Kind() {
+	case reflect.Map:
+		if v.IsNil() {
+			return v
+		}
+		if m, ok := v.Interface().(map[string]string); ok {
+			// The common case for the names in GeoIP2 records.
+			return reflect.ValueOf(maps.Clone(m))
+		}
+		c := reflect.MakeMapWithSize(v.Type(), v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			c.SetMapIndex(reflect.ToValue(iter.Key()), deepCopy(reflect.ToValue(iter.Value())))
+		}
+		return c
+	case reflect.Slice:
+		if v.IsNil() {
+			return v
+		}
+		c := reflect.MakeSlice(v.Type(), v.Len(), v.Len())
+		if !mayShareMemory(v.Type().Elem().Kind()) {
+			reflect.Copy(c, v)
+			return c
+		}
+		for i := 0; i < v.Len(); i++ {
+			c.Index(i).Set(deepCopy(v.Index(i)))
+		}
+		return c
+	case reflect.Array:
+		c := reflect.New(v.Type()).Elem()
+		for i := 0; i < v.Len(); i++ {
+			c.Index(i).Set(deepCopy(v.Index(i)))
+		}
+		return c
+	case reflect.Ptr:
+		if v.IsNil() {
+			return v
+		}
+		if v.Type() == bigIntPtrType {
+			return reflect.ValueOf(new(big.Int).Set(v.Interface().(*big.Int)))
+		}
+		c := reflect.New(v.Type().Elem())
+		c.Elem().Set(deepCopy(v.Elem()))
+		return c
+	case reflect.Interface:
+		if v.IsNil() {
+			return v
+		}
+		c := reflect.New(v.Type()).Elem()
+		c.Set(deepCopy(v.Elem()))
+		return c
+	case reflect.Struct:
+		if v.Type() == bigIntType {
+			i := v.Interface().(big.Int)
+			return reflect.ValueOf(new(big.Int).Set(&i)).Elem()
+		}
+		c := reflect.New(v.Type()).Elem()
+		c.Set(v)
+		for i := 0; i < v.NumField(); i++ {
+			if field := c.Field(i); field.CanSet() && mayShareMemory(field.Kind()) {
+				field.Set(deepCopy(v.Field(i)))
+			}
+		}
+		return c
+	default:
+		return v
+	}
+}
+
+// mayShareMemory returns true if values of kind k may refer to memory that
+// deepCopy must copy.
+func mayShareMemory(k reflect.Kind) bool {
+	switch k {
+	case reflect.Map, reflect.Slice, reflect.Array, reflect.Ptr, reflect.Interface, reflect.Struct:
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,212 @@
+package maxminddb
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"hash"
+	"hash/fnv"
+	"sort"
+	"sync/atomic"
+)
+
+// RecordHash returns a fingerprint of the content of the record at offset,
+// e.g., as returned by LookupOffset. The fingerprint does not depend on how
+// the record is laid out in the database: pointers are followed, map entries
+// are taken in key order, and integers are compared by value. Records with
+// the same content therefore have the same fingerprint in different
+// databases, e.g., in different builds of the same database. Values of
+// different types, such as a uint16 and a uint32 holding the same number,
+// are different content.
+//
+// The fingerprint is a 64-bit FNV-1a hash and is stable across versions of
+// this package. Fingerprints are cached per offset in a bounded cache of
+// the Reader.
+func (r *Reader) RecordHash(offset uintptr) (uint64, error) {
+	if r.buffer == nil {
+		return 0, errors.New("cannot call RecordHash on a closed database")
+	}
+	if sum, ok := r.recordHashes.get(offset); ok {
+		return sum, nil
+	}
+
+	sum, err := r.decoder.recordHash(uint(offset))
+	if err != nil {
+		return 0, err
+	}
+	r.recordHashes.add(offset, sum)
+	return sum, nil
+}
+
+// recordHashCacheSize is the number of slots of a recordHashCache.
+const recordHashCacheSize = 4096
+
+// recordHashCache is a bounded, direct-mapped cache of record fingerprints
+// by offset. Like negativeCache, each slot holds at most one fingerprint and
+// is updated atomically. The slots are allocated by the first add.
+type recordHashCache struct {
+	slots atomic.Pointer[[recordHashCacheSize]atomic.Pointer[recordHashEntry]]
+}
+
+type recordHashEntry struct {
+	offset uintptr
+	sum    uint64
+}
+
+func (c *recordHashCache) slot(offset uintptr) int {
+	// Fibonacci hashing spreads the offsets of nearby records.
+	return int((uint64(offset) * 0x9e3779b97f4a7c15) >> 52)
+}
+
+func (c *recordHashCache) get(offset uintptr) (uint64, bool) {
+	slots := c.slots.Load()
+	if slots == nil {
+		return 0, false
+	}
+	e := slots[c.slot(offset)].Load()
+	if e == nil || e.offset != offset {
+		return 0, false
+	}
+	return e.sum, true
+}
+
+func (c *recordHashCache) add(offset uintptr, sum uint64) {
+	slots := c.slots.Load()
+	if slots == nil {
+		c.slots.CompareAndSwap(nil, new([recordHashCacheSize]atomic.Pointer[recordHashEntry]))
+		slots = c.slots.Load()
+	}
+	slots[c.slot(offset)].Store(&recordHashEntry{offset: offset, sum: sum})
+}
+
+// recordHash returns the fingerprint of the value at offset as described by
+// Reader.RecordHash.
+func (d *decoder) recordHash(offset uint) (uint64, error) {
+	h := fnv.New64a()
+	if err := d.hashValue(h, offset, 0); err != nil {
+		return 0, err
+	}
+	return h.Sum64(), nil
+}
+
+// hashValue writes the canonical encoding of the value at offset to h. Each
+// value is encoded as its type followed by, for maps and arrays, the number
+// of entries and the entries, for strings and bytes, the length and the
+// content, and for other types, a fixed-size big-endian encoding of the
+// value.
+func (d *decoder) hashValue(h hash.Hash64, offset uint, depth int) error {
+	if depth > maximumDataStructureDepth {
+		return newInvalidDatabaseError(
+			"exceeded maximum data structure depth; database is likely corrupt",
+		)
+	}
+	typeNum, size, offset, err := d.decodeCtrlData(offset)
+	if err != nil {
+		return err
+	}
+
+	var scratch [1 + 16]byte
+	scratch[0] = byte(typeNum)
+	switch typeNum {
+	case _Pointer:
+		pointer, _, err := d.decodePointer(size, offset)
+		if err != nil {
+			return err
+		}
+		return d.hashValue(h, pointer, depth+1)
+	case _Map:
+		if err := d.checkContainerSize(size, offset); err != nil {
+			return err
+		}
+		type entry struct {
+			key    []byte
+			offset uint
+		}
+		entries := make([]entry, size)
+		for i := range entries {
+			key, valueOffset, err := d.decodeKey(offset)
+			if err != nil {
+				return err
+			}
+			entries[i] = entry{key: key, offset: valueOffset}
+			offset, err = d.nextValueOffset(valueOffset, 1)
+			if err != nil {
+				return err
+			}
+		}
+		sort.Slice(entries, func(i, j int) bool {
+			return bytes.Compare(entries[i].key, entries[j].key) < 0
+		})
+
+		h.Write(binary.AppendUvarint(scratch[:1], uint64(size)))
+		for _, e := range entries {
+			h.Write(binary.AppendUvarint(scratch[:0], uint64(len(e.key))))
+			h.Write(e.key)
+			if err := d.hashValue(h, e.offset, depth+1); err != nil {
+				return err
+			}
+		}
+		return nil
+	case _Slice:
+		if err := d.checkContainerSize(size, offset); err != nil {
+			return err
+		}
+		h.Write(binary.AppendUvarint(scratch[:1], uint64(size)))
+		for i := uint(0); i < size; i++ {
+			if err := d.hashValue(h, offset, depth+1); err != nil {
+				return err
+			}
+			offset, err = d.nextValueOffset(offset, 1)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	case _Bool:
+		if size > 1 {
+			return newInvalidDatabaseError(
+				"the MaxMind DB file's data section contains bad data (bool size of %v)",
+				size,
+			)
+		}
+		scratch[1] = byte(size)
+		h.Write(scratch[:2])
+		return nil
+	}
+
+	if offset+size > uint(len(d.buffer)) {
+		return newOffsetError()
+	}
+	switch typeNum {
+	case _String, _Bytes:
+		h.Write(binary.AppendUvarint(scratch[:1], uint64(size)))
+		h.Write(d.buffer[offset : offset+size])
+	case _Uint16, _Uint32, _Uint64:
+		if size > 8 {
+			return newInvalidDatabaseError("invalid size for %s: %d", dataTypeName(typeNum), size)
+		}
+		value, _ := d.decodeUint(size, offset)
+		h.Write(binary.BigEndian.AppendUint64(scratch[:1], value))
+	case _Int32:
+		if size > 4 {
+			return newInvalidDatabaseError("invalid size for int32: %d", size)
+		}
+		value, _ := d.decodeInt(size, offset)
+		h.Write(binary.BigEndian.AppendUint64(scratch[:1], uint64(int64(value))))
+	case _Uint128:
+		if size > 16 {
+			return newInvalidDatabaseError("invalid size for uint128: %d", size)
+		}
+		copy(scratch[1+16-size:], d.buffer[offset:offset+size])
+		h.Write(scratch[:])
+	case _Float32, _Float64:
+		if (typeNum == _Float32 && size != 4) || (typeNum == _Float64 && size != 8) {
+			return newInvalidDatabaseError("invalid size for %s: %d", dataTypeName(typeNum), size)
+		}
+		h.Write(scratch[:1])
+		h.Write(d.buffer[offset : offset+size])
+	default:
+		return newInvalidDatabaseError("unknown type: %d", typeNum)
+	}
+	return nil
+}
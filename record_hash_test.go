@@ -0,0 +1,142 @@
+package maxminddb
+
+import (
+	"encoding/hex"
+	"fmt"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRecordHashLayout(t *testing.T) {
+	hashOf := func(input string, offset uint) uint64 {
+		buffer, err := hex.DecodeString(input)
+		require.NoError(t, err)
+		d := decoder{buffer: buffer}
+		sum, err := d.recordHash(offset)
+		require.NoError(t, err)
+		return sum
+	}
+
+	// {"a": uint16(1), "b": "x"}
+	expected := hashOf("e24161a10141624178", 0)
+
+	// The keys in a different order.
+	assert.Equal(t, expected, hashOf("e2416241784161a101", 0))
+	// "x" reached through a pointer.
+	assert.Equal(t, expected, hashOf("4178"+"e24161a10141622000", 2))
+	// The key "b" reached through a pointer.
+	assert.Equal(t, expected, hashOf("4162"+"e24161a10120004178", 2))
+	// The uint16 encoded with a leading zero byte.
+	assert.Equal(t, expected, hashOf("e24161a2000141624178", 0))
+
+	// {"a": uint32(1), "b": "x"}
+	assert.NotEqual(t, expected, hashOf("e24161c10141624178", 0))
+	// {"a": uint16(2), "b": "x"}
+	assert.NotEqual(t, expected, hashOf("e24161a10241624178", 0))
+	// {"a": uint16(1), "b": "y"}
+	assert.NotEqual(t, expected, hashOf("e24161a10141624179", 0))
+	// {"a": uint16(1)}
+	assert.NotEqual(t, expected, hashOf("e14161a101", 0))
+	// ["a", "b"] and ["b", "a"]
+	assert.NotEqual(t, hashOf("020441614162", 0), hashOf("020441624161", 0))
+	// "ab" and ["a", "b"]
+	assert.NotEqual(t, hashOf("426162", 0), hashOf("020441614162", 0))
+}
+
+func TestRecordHashCache(t *testing.T) {
+	var c recordHashCache
+	_, ok := c.get(10)
+	assert.False(t, ok)
+
+	c.add(10, 1)
+	sum, ok := c.get(10)
+	assert.True(t, ok)
+	assert.Equal(t, uint64(1), sum)
+	_, ok = c.get(11)
+	assert.False(t, ok)
+
+	// The cache is bounded: adding more offsets than it has slots evicts
+	// earlier ones.
+	for offset := uintptr(0); offset < 4*recordHashCacheSize; offset++ {
+		c.add(offset, uint64(offset))
+	}
+	hits := 0
+	for offset := uintptr(0); offset < 4*recordHashCacheSize; offset++ {
+		if sum, ok := c.get(offset); ok {
+			assert.Equal(t, uint64(offset), sum)
+			hits++
+		}
+	}
+	assert.LessOrEqual(t, hits, recordHashCacheSize)
+	assert.Positive(t, hits)
+}
+
+func TestRecordHash(t *testing.T) {
+	for _, ipVersion := range []uint{4, 6} {
+		readers := map[uint]*Reader{}
+		for _, recordSize := range []uint{24, 28, 32} {
+			reader, err := Open(
+				testFile(fmt.Sprintf("MaxMind-DB-test-ipv%d-%d.mmdb", ipVersion, recordSize)),
+			)
+			require.NoError(t, err)
+			readers[recordSize] = reader
+		}
+
+		seen := map[uint64]string{}
+		n := readers[24].Networks(SkipAliasedNetworks)
+		for n.Next() {
+			var record any
+			network, err := n.Network(&record)
+			require.NoError(t, err)
+
+			var hashes []uint64
+			for _, recordSize := range []uint{24, 28, 32} {
+				offset, err := readers[recordSize].LookupOffset(network.IP)
+				require.NoError(t, err)
+				sum, err := readers[recordSize].RecordHash(offset)
+				require.NoError(t, err)
+				hashes = append(hashes, sum)
+
+				again, err := readers[recordSize].RecordHash(offset)
+				require.NoError(t, err)
+				assert.Equal(t, sum, again)
+			}
+			assert.Equal(t, hashes[0], hashes[1], network.String())
+			assert.Equal(t, hashes[0], hashes[2], network.String())
+
+			// The test databases have a different record for each network.
+			other, ok := seen[hashes[0]]
+			assert.False(t, ok, "%s has the same hash as %s", network, other)
+			seen[hashes[0]] = network.String()
+		}
+		require.NoError(t, n.Err())
+		assert.NotEmpty(t, seen)
+
+		for _, reader := range readers {
+			require.NoError(t, reader.Close())
+		}
+	}
+
+	reader, err := Open(testFile("MaxMind-DB-test-ipv4-24.mmdb"))
+	require.NoError(t, err)
+	offset, err := reader.LookupOffset(net.ParseIP("1.1.1.1"))
+	require.NoError(t, err)
+
+	// A second call is served from the cache.
+	sum, err := reader.RecordHash(offset)
+	require.NoError(t, err)
+	cached, ok := reader.recordHashes.get(offset)
+	require.True(t, ok)
+	assert.Equal(t, sum, cached)
+	reader.recordHashes.add(offset, sum+1)
+	again, err := reader.RecordHash(offset)
+	require.NoError(t, err)
+	assert.Equal(t, sum+1, again)
+
+	require.NoError(t, reader.Close())
+	_, err = reader.RecordHash(offset)
+	assert.EqualError(t, err, "cannot call RecordHash on a closed database")
+}
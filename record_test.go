@@ -0,0 +1,137 @@
+package maxminddb
+
+import (
+	"encoding/hex"
+	"net"
+	"testing"
+
+	"github.com/3JoB/go-reflect"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRecord(t *testing.T) {
+	reader, err := Open(testFile("GeoIP2-City-Test.mmdb"))
+	require.NoError(t, err)
+
+	offset, err := reader.LookupOffset(net.ParseIP("81.2.69.160"))
+	require.NoError(t, err)
+
+	record := reader.Record(offset)
+	assert.Equal(t, offset, record.Offset())
+
+	var city fullCity
+	require.NoError(t, record.Decode(&city))
+	require.NotNil(t, record.index)
+	assert.Equal(t, uint(2643743), city.City.GeoNameID)
+	assert.Equal(t, "GB", city.Country.IsoCode)
+
+	var expectedCity fullCity
+	require.NoError(t, reader.Decode(offset, &expectedCity))
+	assert.Equal(t, expectedCity, city)
+
+	var m map[string]any
+	require.NoError(t, record.Decode(&m))
+	var expectedMap map[string]any
+	require.NoError(t, reader.Decode(offset, &expectedMap))
+	assert.Equal(t, expectedMap, m)
+
+	var country struct {
+		Country struct {
+			IsoCode string `maxminddb:"iso_code"`
+		} `maxminddb:"country"`
+	}
+	require.NoError(t, record.Decode(&country))
+	assert.Equal(t, "GB", country.Country.IsoCode)
+
+	require.NoError(t, reader.Close())
+	assert.EqualError(t, record.Decode(&m), "cannot call Decode on a closed database")
+}
+
+func TestRecordIndex(t *testing.T) {
+	// The map at offset 20 has a key and a value that are pointers, a value
+	// that is not, and a value that is a map.
+	buffer, err := hex.DecodeString(
+		"4a6c6f6e675f76616c7565" + // 0: "long_value"
+			"486c6f6e675f6b6579" + // 11: "long_key"
+			"e3" + // 20: map with 3 entries
+			"200b2000" + // "long_key": "long_value"
+			"4161a107" + // "a": uint16(7)
+			"4162e141634164", // "b": {"c": "d"}
+	)
+	require.NoError(t, err)
+	d := decoder{buffer: buffer}
+
+	index := d.indexRecord(20)
+	require.NotNil(t, index)
+	assert.Equal(t, &recordIndex{
+		entries: []recordIndexEntry{
+			{key: []byte("long_key"), value: 0},
+			{key: []byte("a"), value: 27},
+			{key: []byte("b"), value: 31},
+		},
+		start: 21,
+		end:   uint(len(buffer)),
+	}, index)
+
+	d.index = index
+	var m map[string]any
+	newOffset, err := d.decode(20, reflect.ValueOf(&m), 0)
+	require.NoError(t, err)
+	assert.Equal(t, uint(len(buffer)), newOffset)
+	assert.Equal(t, map[string]any{
+		"long_key": "long_value",
+		"a":        uint64(7),
+		"b":        map[string]any{"c": "d"},
+	}, m)
+
+	var s struct {
+		B struct {
+			C string `maxminddb:"c"`
+		} `maxminddb:"b"`
+		LongKey string `maxminddb:"long_key"`
+	}
+	newOffset, err = d.decode(20, reflect.ValueOf(&s), 0)
+	require.NoError(t, err)
+	assert.Equal(t, uint(len(buffer)), newOffset)
+	assert.Equal(t, "long_value", s.LongKey)
+	assert.Equal(t, "d", s.B.C)
+
+	// Values other than maps are not indexed.
+	assert.Nil(t, d.indexRecord(0))
+}
+
+func BenchmarkRecordDecode(b *testing.B) {
+	db, err := Open("GeoLite2-City.mmdb")
+	require.NoError(b, err)
+
+	offset, err := db.LookupOffset(net.ParseIP("81.2.69.160"))
+	require.NoError(b, err)
+
+	b.Run("Decode", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			var city fullCity
+			var m map[string]any
+			if err := db.Decode(offset, &city); err != nil {
+				b.Error(err)
+			}
+			if err := db.Decode(offset, &m); err != nil {
+				b.Error(err)
+			}
+		}
+	})
+	b.Run("Record", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			var city fullCity
+			var m map[string]any
+			record := db.Record(offset)
+			if err := record.Decode(&city); err != nil {
+				b.Error(err)
+			}
+			if err := record.Decode(&m); err != nil {
+				b.Error(err)
+			}
+		}
+	})
+	assert.NoError(b, db.Close(), "error on close")
+}
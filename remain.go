@@ -0,0 +1,17 @@
+package maxminddb
+
+// WithStrictDecode is an option for Open, FromBytes, and FromReader that
+// makes decoding a map into a struct fail if the map has keys that no field
+// of the struct is decoded from. The error is a FieldError wrapping an
+// UnknownKeysError listing those keys, with the path of the struct within
+// the record. With WithSoftFailDecode, it is returned among the FieldErrors
+// once the rest of the record has been decoded.
+//
+// Keys decoded into embedded structs are not unknown, and structs with a
+// field tagged ",remain", which receives all other keys, never have unknown
+// keys. Maps decoded into maps or an any are not checked.
+func WithStrictDecode() ReaderOption {
+	return func(o *readerOptions) {
+		o.strictDecode = true
+	}
+}
@@ -0,0 +1,208 @@
+package maxminddb
+
+import (
+	"errors"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type remainCountry struct {
+	ISOCode string         `maxminddb:"iso_code"`
+	Other   map[string]any `maxminddb:",remain"`
+}
+
+type remainCity struct {
+	Country remainCountry  `maxminddb:"country"`
+	Other   map[string]any `maxminddb:",remain"`
+}
+
+type remainLocation struct {
+	Location map[string]any `maxminddb:"location"`
+}
+
+type remainEmbedded struct {
+	remainLocation
+	Postal struct {
+		Code string `maxminddb:"code"`
+	} `maxminddb:"postal"`
+	Other map[string]any `maxminddb:",remain"`
+}
+
+// withoutKeys returns a copy of m without keys.
+func withoutKeys(m map[string]any, keys ...string) map[string]any {
+	rest := map[string]any{}
+	for key, value := range m {
+		rest[key] = value
+	}
+	for _, key := range keys {
+		delete(rest, key)
+	}
+	return rest
+}
+
+// keysOf returns the keys of m.
+func keysOf(m map[string]any) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	return keys
+}
+
+func TestRemain(t *testing.T) {
+	reader, err := Open(testFile("GeoIP2-City-Test.mmdb"))
+	require.NoError(t, err)
+	defer reader.Close()
+
+	ip := net.ParseIP("2.125.160.216")
+	var expected map[string]any
+	require.NoError(t, reader.Lookup(ip, &expected))
+	expectedCountry := expected["country"].(map[string]any)
+
+	var city remainCity
+	require.NoError(t, reader.Lookup(ip, &city))
+	assert.Equal(t, "GB", city.Country.ISOCode)
+	assert.Equal(t, withoutKeys(expectedCountry, "iso_code"), city.Country.Other)
+	assert.Equal(t, withoutKeys(expected, "country"), city.Other)
+
+	var embedded remainEmbedded
+	require.NoError(t, reader.Lookup(ip, &embedded))
+	assert.Equal(t, "Europe/London", embedded.Location["time_zone"])
+	assert.Equal(t, "OX1", embedded.Postal.Code)
+	assert.Equal(t, withoutKeys(expected, "location", "postal"), embedded.Other)
+
+	// The map is left nil without unknown keys.
+	var names struct {
+		Country struct {
+			GeoNameID uint              `maxminddb:"geoname_id"`
+			ISOCode   string            `maxminddb:"iso_code"`
+			Names     map[string]string `maxminddb:"names"`
+			Other     map[string]any    `maxminddb:",remain"`
+		} `maxminddb:"country"`
+	}
+	require.NoError(t, reader.Lookup(net.ParseIP("81.2.69.160"), &names))
+	assert.Equal(t, "GB", names.Country.ISOCode)
+	assert.Nil(t, names.Country.Other)
+}
+
+func TestRemainTypeMismatch(t *testing.T) {
+	reader, err := Open(testFile("GeoIP2-City-Test.mmdb"), WithSoftFailDecode())
+	require.NoError(t, err)
+	defer reader.Close()
+
+	var record struct {
+		Country struct {
+			Other map[string]string `maxminddb:",remain"`
+		} `maxminddb:"country"`
+	}
+	err = reader.Lookup(net.ParseIP("81.2.69.160"), &record)
+	var fieldErrs FieldErrors
+	require.True(t, errors.As(err, &fieldErrs), "%v", err)
+	var paths []string
+	for _, fieldErr := range fieldErrs {
+		paths = append(paths, fieldErr.Path)
+	}
+	assert.Contains(t, paths, "country.geoname_id")
+	assert.Contains(t, paths, "country.names")
+	assert.Equal(t, "GB", record.Country.Other["iso_code"])
+	assert.NotContains(t, record.Country.Other, "geoname_id")
+}
+
+func TestStrictDecode(t *testing.T) {
+	plain, err := Open(testFile("GeoIP2-City-Test.mmdb"))
+	require.NoError(t, err)
+	defer plain.Close()
+	reader, err := Open(testFile("GeoIP2-City-Test.mmdb"), WithStrictDecode())
+	require.NoError(t, err)
+	defer reader.Close()
+
+	ip := net.ParseIP("2.125.160.216")
+	var expected map[string]any
+	require.NoError(t, plain.Lookup(ip, &expected))
+	expectedCountry := expected["country"].(map[string]any)
+	expectedSubdivision := expected["subdivisions"].([]any)[0].(map[string]any)
+
+	unknownKeys := func(t *testing.T, err error, path string) []string {
+		t.Helper()
+		var fieldErr FieldError
+		require.True(t, errors.As(err, &fieldErr), "%v", err)
+		assert.Equal(t, path, fieldErr.Path)
+		var keysErr UnknownKeysError
+		require.True(t, errors.As(err, &keysErr), "%v", err)
+		return keysErr.Keys
+	}
+
+	var root struct {
+		City any `maxminddb:"city"`
+	}
+	err = reader.Lookup(ip, &root)
+	assert.ElementsMatch(t, keysOf(withoutKeys(expected, "city")), unknownKeys(t, err, ""))
+	assert.Contains(t, err.Error(), "maxminddb: error decoding record: maxminddb: unknown keys ")
+
+	var country struct {
+		Country struct {
+			ISOCode string `maxminddb:"iso_code"`
+		} `maxminddb:"country"`
+		Other map[string]any `maxminddb:",remain"`
+	}
+	err = reader.Lookup(ip, &country)
+	assert.ElementsMatch(t, keysOf(withoutKeys(expectedCountry, "iso_code")), unknownKeys(t, err, "country"))
+
+	var subdivisions struct {
+		Subdivisions []struct {
+			ISOCode string `maxminddb:"iso_code"`
+		} `maxminddb:"subdivisions"`
+		Other map[string]any `maxminddb:",remain"`
+	}
+	err = reader.Lookup(ip, &subdivisions)
+	assert.ElementsMatch(
+		t,
+		keysOf(withoutKeys(expectedSubdivision, "iso_code")),
+		unknownKeys(t, err, "subdivisions[0]"),
+	)
+
+	// Keys of embedded structs are known.
+	var embedded struct {
+		remainLocation
+		City any `maxminddb:"city"`
+	}
+	err = reader.Lookup(ip, &embedded)
+	assert.ElementsMatch(t, keysOf(withoutKeys(expected, "city", "location")), unknownKeys(t, err, ""))
+
+	// Remain takes all the other keys.
+	var city remainCity
+	require.NoError(t, reader.Lookup(ip, &city))
+	assert.Equal(t, "GB", city.Country.ISOCode)
+}
+
+func TestStrictDecodeSoftFail(t *testing.T) {
+	reader, err := Open(
+		testFile("GeoIP2-City-Test.mmdb"),
+		WithStrictDecode(),
+		WithSoftFailDecode(),
+	)
+	require.NoError(t, err)
+	defer reader.Close()
+
+	var record struct {
+		Country struct {
+			ISOCode string `maxminddb:"iso_code"`
+		} `maxminddb:"country"`
+	}
+	err = reader.Lookup(net.ParseIP("2.125.160.216"), &record)
+	var fieldErrs FieldErrors
+	require.True(t, errors.As(err, &fieldErrs), "%v", err)
+	require.Len(t, fieldErrs, 2)
+	assert.Equal(t, "country", fieldErrs[0].Path)
+	assert.Equal(t, "", fieldErrs[1].Path)
+	for _, fieldErr := range fieldErrs {
+		var keysErr UnknownKeysError
+		assert.True(t, errors.As(fieldErr, &keysErr))
+		assert.NotEmpty(t, keysErr.Keys)
+	}
+	// The rest of the record is decoded.
+	assert.Equal(t, "GB", record.Country.ISOCode)
+}
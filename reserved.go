@@ -0,0 +1,112 @@
+package maxminddb
+
+import (
+	"net"
+	"net/netip"
+)
+
+// The prefixes used by WithSkipReserved by default. They are the entries of
+// the IANA IPv4 and IPv6 Special-Purpose Address Registries that are not
+// globally reachable. Prefixes that MaxMind DBs use to alias the IPv4
+// subtree, such as the 6to4 and Teredo prefixes, are left out.
+var defaultReservedPrefixes = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),       // "This network"
+	netip.MustParsePrefix("10.0.0.0/8"),      // Private-Use
+	netip.MustParsePrefix("100.64.0.0/10"),   // Shared Address Space
+	netip.MustParsePrefix("127.0.0.0/8"),     // Loopback
+	netip.MustParsePrefix("169.254.0.0/16"),  // Link Local
+	netip.MustParsePrefix("172.16.0.0/12"),   // Private-Use
+	netip.MustParsePrefix("192.0.0.0/24"),    // IETF Protocol Assignments
+	netip.MustParsePrefix("192.0.2.0/24"),    // Documentation (TEST-NET-1)
+	netip.MustParsePrefix("192.168.0.0/16"),  // Private-Use
+	netip.MustParsePrefix("198.18.0.0/15"),   // Benchmarking
+	netip.MustParsePrefix("198.51.100.0/24"), // Documentation (TEST-NET-2)
+	netip.MustParsePrefix("203.0.113.0/24"),  // Documentation (TEST-NET-3)
+	netip.MustParsePrefix("240.0.0.0/4"),     // Reserved, including Limited Broadcast
+	netip.MustParsePrefix("::/128"),          // Unspecified Address
+	netip.MustParsePrefix("::1/128"),         // Loopback Address
+	netip.MustParsePrefix("64:ff9b:1::/48"),  // IPv4-IPv6 Translation
+	netip.MustParsePrefix("100::/64"),        // Discard-Only Address Block
+	netip.MustParsePrefix("2001:db8::/32"),   // Documentation
+	netip.MustParsePrefix("3fff::/20"),       // Documentation
+	netip.MustParsePrefix("5f00::/16"),       // Segment Routing (SRv6) SIDs
+	netip.MustParsePrefix("fc00::/7"),        // Unique-Local
+	netip.MustParsePrefix("fe80::/10"),       // Link-Local Unicast
+}
+
+// ReservedPrefixes returns the prefixes that WithSkipReserved uses when no
+// prefixes are given: the reserved, private, loopback, link-local, and
+// documentation networks listed as not globally reachable in the IANA
+// special-purpose address registries. The returned slice may be modified,
+// e.g., to extend the list before passing it to WithSkipReserved.
+func ReservedPrefixes() []netip.Prefix {
+	return append([]netip.Prefix(nil), defaultReservedPrefixes...)
+}
+
+// WithSkipReserved is an option for Open and FromBytes that makes lookups of
+// addresses in reserved networks return without a record and without
+// traversing the search tree. If no prefixes are given, those returned by
+// ReservedPrefixes are used. LookupNetwork returns the reserved prefix
+// containing the address as the network. The number of lookups skipped this
+// way is reported by Reader.Stats.
+//
+// This is useful when many lookups are of addresses, such as private
+// addresses, that cannot have a record in the database. Iterating over the
+// networks in the database is not affected.
+func WithSkipReserved(prefixes ...netip.Prefix) ReaderOption {
+	return func(o *readerOptions) {
+		if len(prefixes) == 0 {
+			prefixes = defaultReservedPrefixes
+		}
+		o.reservedPrefixes = newReservedPrefixes(prefixes)
+	}
+}
+
+// reservedPrefixes holds the prefixes for WithSkipReserved split by address
+// family. IPv4-mapped IPv6 prefixes are stored as IPv4 prefixes as lookups
+// of IPv4-mapped addresses are treated as IPv4 lookups.
+type reservedPrefixes struct {
+	ipv4 []netip.Prefix
+	ipv6 []netip.Prefix
+}
+
+func newReservedPrefixes(prefixes []netip.Prefix) *reservedPrefixes {
+	var p reservedPrefixes
+	for _, prefix := range prefixes {
+		if !prefix.IsValid() {
+			continue
+		}
+		addr := prefix.Addr()
+		bits := prefix.Bits()
+		if addr.Is4In6() && bits >= 96 {
+			addr = addr.Unmap()
+			bits -= 96
+		}
+		prefix = netip.PrefixFrom(addr, bits).Masked()
+		if addr.Is4() {
+			p.ipv4 = append(p.ipv4, prefix)
+		} else {
+			p.ipv6 = append(p.ipv6, prefix)
+		}
+	}
+	return &p
+}
+
+// match returns the length of the reserved prefix containing ip, which must
+// be a 4 or 16 byte address with IPv4 addresses in their 4 byte form.
+func (p *reservedPrefixes) match(ip net.IP) (int, bool) {
+	addr, ok := netip.AddrFromSlice(ip)
+	if !ok {
+		return 0, false
+	}
+	prefixes := p.ipv6
+	if len(ip) == net.IPv4len {
+		prefixes = p.ipv4
+	}
+	for _, prefix := range prefixes {
+		if prefix.Contains(addr) {
+			return prefix.Bits(), true
+		}
+	}
+	return 0, false
+}
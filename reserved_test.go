@@ -0,0 +1,102 @@
+package maxminddb
+
+import (
+	"net"
+	"net/netip"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSkipReserved(t *testing.T) {
+	reader, err := Open(testFile("GeoIP2-City-Test.mmdb"), WithSkipReserved())
+	require.NoError(t, err)
+
+	tests := []struct {
+		ip      string
+		network string
+	}{
+		{ip: "10.1.2.3", network: "10.0.0.0/8"},
+		{ip: "127.0.0.1", network: "127.0.0.0/8"},
+		{ip: "192.168.1.1", network: "192.168.0.0/16"},
+		{ip: "::ffff:172.16.0.1", network: "172.16.0.0/12"},
+		{ip: "::1", network: "::1/128"},
+		{ip: "fe80::1", network: "fe80::/10"},
+		{ip: "2001:db8::1", network: "2001:db8::/32"},
+	}
+	for _, test := range tests {
+		var record any
+		network, ok, err := reader.LookupNetwork(net.ParseIP(test.ip), &record)
+		require.NoError(t, err)
+		assert.False(t, ok, test.ip)
+		assert.Nil(t, record, test.ip)
+		assert.Equal(t, test.network, network.String(), test.ip)
+
+		offset, err := reader.LookupOffset(net.ParseIP(test.ip))
+		require.NoError(t, err)
+		assert.Equal(t, NotFound, offset, test.ip)
+	}
+	assert.Equal(t, ReaderStats{ReservedLookups: 2 * uint64(len(tests))}, reader.Stats())
+
+	var record any
+	network, ok, err := reader.LookupNetwork(net.ParseIP("81.2.69.160"), &record)
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, "81.2.69.160/27", network.String())
+	assert.Equal(t, ReaderStats{ReservedLookups: 2 * uint64(len(tests))}, reader.Stats())
+
+	assert.NoError(t, reader.Close())
+}
+
+func TestSkipReservedAliases(t *testing.T) {
+	reader, err := Open(testFile("MaxMind-DB-test-mixed-24.mmdb"), WithSkipReserved())
+	require.NoError(t, err)
+
+	// The Teredo and 6to4 prefixes alias the IPv4 subtree and are not
+	// reserved.
+	for _, ip := range []string{"2001:0:101:101::", "2002:101:101::"} {
+		var record any
+		_, ok, err := reader.LookupNetwork(net.ParseIP(ip), &record)
+		require.NoError(t, err)
+		assert.True(t, ok, ip)
+	}
+	assert.Equal(t, ReaderStats{}, reader.Stats())
+
+	assert.NoError(t, reader.Close())
+}
+
+func TestSkipReservedCustomPrefixes(t *testing.T) {
+	for _, prefix := range []string{"81.2.69.0/24", "::ffff:81.2.69.0/120"} {
+		reader, err := Open(
+			testFile("GeoIP2-City-Test.mmdb"),
+			WithSkipReserved(netip.MustParsePrefix(prefix)),
+		)
+		require.NoError(t, err)
+
+		var record any
+		network, ok, err := reader.LookupNetwork(net.ParseIP("81.2.69.160"), &record)
+		require.NoError(t, err)
+		assert.False(t, ok, prefix)
+		assert.Equal(t, "81.2.69.0/24", network.String(), prefix)
+
+		// The default prefixes are replaced.
+		network, _, err = reader.LookupNetwork(net.ParseIP("10.1.2.3"), &record)
+		require.NoError(t, err)
+		assert.NotEqual(t, "10.0.0.0/8", network.String(), prefix)
+		assert.Equal(t, ReaderStats{ReservedLookups: 1}, reader.Stats())
+
+		assert.NoError(t, reader.Close())
+	}
+}
+
+func TestReservedPrefixes(t *testing.T) {
+	prefixes := ReservedPrefixes()
+	require.NotEmpty(t, prefixes)
+	for _, prefix := range prefixes {
+		assert.Equal(t, prefix.Masked(), prefix)
+	}
+
+	prefixes[0] = netip.Prefix{}
+	assert.NotEqual(t, netip.Prefix{}, ReservedPrefixes()[0])
+}
@@ -0,0 +1,89 @@
+package maxminddb
+
+import (
+	"errors"
+	"net/netip"
+)
+
+// Result is the result of a lookup by LookupResult. It holds the network
+// and the offset of the record found by a single traversal of the search
+// tree, and decodes the record only if Decode is called. It is a small value
+// that may be copied freely, e.g., to cache records by Offset.
+type Result struct {
+	lookuper Lookuper
+	err      error
+	prefix   netip.Prefix
+	offset   uintptr
+}
+
+// LookupResult looks up ip and returns the Result, e.g.,
+//
+//	result := reader.LookupResult(ip)
+//	if err := result.Err(); err != nil {
+//		return err
+//	}
+//	if result.Found() {
+//		err = result.Decode(&record)
+//	}
+//
+// Errors from the lookup are carried by the Result and returned by Err and
+// Decode. Addresses are looked up as by LookupNetip, and the network is the
+// one returned by LookupNetworkPrefix.
+func (r *Reader) LookupResult(ip netip.Addr) Result {
+	return r.With().LookupResult(ip)
+}
+
+// LookupResult looks up ip and returns the Result, whose Decode applies the
+// options of l. See Reader.LookupResult.
+func (l Lookuper) LookupResult(ip netip.Addr) Result {
+	result := Result{lookuper: l, offset: NotFound}
+	r := l.reader
+	if r.buffer == nil {
+		result.err = errors.New("cannot call LookupResult on a closed database")
+		return result
+	}
+	offset, prefixLength, ip, err := r.lookupAddr(ip)
+	if ip.IsValid() {
+		result.prefix = r.prefix(ip, prefixLength)
+	}
+	result.offset = offset
+	result.err = err
+	return result
+}
+
+// Err returns the error of the lookup, if any.
+func (res Result) Err() error {
+	return res.err
+}
+
+// Found reports whether the database has a record for the address. It is
+// false if the lookup failed.
+func (res Result) Found() bool {
+	return res.err == nil && res.offset != NotFound
+}
+
+// Network returns the network of the record, or of the part of the search
+// tree without a record, containing the address. It is the zero Prefix if
+// the address could not be looked up.
+func (res Result) Network() netip.Prefix {
+	return res.prefix
+}
+
+// Offset returns the offset of the record in the data section, as returned
+// by LookupOffset, or NotFound if there is no record.
+func (res Result) Offset() uintptr {
+	return res.offset
+}
+
+// Decode decodes the record into the value pointed to by result. It
+// returns the error of the lookup, if any, and leaves result unchanged if
+// there is no record, as Lookup does.
+func (res Result) Decode(result any) error {
+	if res.err != nil {
+		return res.err
+	}
+	if res.offset == NotFound {
+		return nil
+	}
+	return res.lookuper.Decode(res.offset, result)
+}
@@ -0,0 +1,83 @@
+package maxminddb
+
+import (
+	"net"
+	"net/netip"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLookupResult(t *testing.T) {
+	for _, file := range []string{
+		"GeoIP2-City-Test.mmdb",
+		"MaxMind-DB-test-ipv4-24.mmdb",
+		"MaxMind-DB-test-mixed-24.mmdb",
+	} {
+		reader, err := Open(testFile(file))
+		require.NoError(t, err)
+
+		for _, s := range []string{"1.1.1.1", "1.1.1.3", "81.2.69.160", "::ffff:1.1.1.1", "10.0.0.1", "2001:218::"} {
+			var expected any
+			network, ok, expectedErr := reader.LookupNetwork(net.ParseIP(s), &expected)
+
+			result := reader.LookupResult(netip.MustParseAddr(s))
+			if expectedErr != nil {
+				assert.EqualError(t, result.Err(), expectedErr.Error(), "%s in %s", s, file)
+				assert.False(t, result.Found())
+				continue
+			}
+			require.NoError(t, result.Err(), "%s in %s", s, file)
+			assert.Equal(t, ok, result.Found(), "%s in %s", s, file)
+			assert.Equal(t, network.String(), result.Network().String(), "%s in %s", s, file)
+			offset, err := reader.LookupOffset(net.ParseIP(s))
+			require.NoError(t, err)
+			assert.Equal(t, offset, result.Offset(), "%s in %s", s, file)
+
+			var record any
+			require.NoError(t, result.Decode(&record))
+			assert.Equal(t, expected, record, "%s in %s", s, file)
+		}
+		require.NoError(t, reader.Close())
+	}
+}
+
+func TestLookupResultErrors(t *testing.T) {
+	reader, err := Open(testFile("MaxMind-DB-test-ipv4-24.mmdb"))
+	require.NoError(t, err)
+
+	result := reader.LookupResult(netip.MustParseAddr("2001:db8::1"))
+	assert.EqualError(
+		t,
+		result.Err(),
+		"error looking up '2001:db8::1': you attempted to look up an IPv6 address in an IPv4-only database",
+	)
+	assert.False(t, result.Found())
+	assert.Equal(t, NotFound, result.Offset())
+	assert.Equal(t, netip.Prefix{}, result.Network())
+	var record any
+	assert.Equal(t, result.Err(), result.Decode(&record))
+
+	result = reader.LookupResult(netip.Addr{})
+	assert.EqualError(t, result.Err(), "IP passed to LookupNetip is invalid")
+
+	// A missing record decodes to nothing.
+	record = "unchanged"
+	result = reader.LookupResult(netip.MustParseAddr("10.0.0.1"))
+	require.NoError(t, result.Err())
+	assert.False(t, result.Found())
+	require.NoError(t, result.Decode(&record))
+	assert.Equal(t, "unchanged", record)
+
+	// Lookup options apply to Decode.
+	result = reader.With(MaxDecodedBytes(1)).LookupResult(netip.MustParseAddr("1.1.1.1"))
+	require.True(t, result.Found())
+	assert.Equal(t, DecodedSizeLimitError{Limit: 1}, result.Decode(&record))
+
+	result = reader.LookupResult(netip.MustParseAddr("1.1.1.1"))
+	require.NoError(t, reader.Close())
+	assert.EqualError(t, result.Decode(&record), "cannot call Decode on a closed database")
+	result = reader.LookupResult(netip.MustParseAddr("1.1.1.1"))
+	assert.EqualError(t, result.Err(), "cannot call LookupResult on a closed database")
+}
@@ -0,0 +1,88 @@
+package maxminddb
+
+import (
+	"errors"
+	"net"
+
+	"github.com/3JoB/go-reflect"
+)
+
+// Session performs lookups in a Reader reusing its scratch memory, e.g.,
+// the decoder state that Reader methods allocate for each lookup. It is
+// meant for loops looking up many addresses in one goroutine.
+//
+// A Session is not safe for concurrent use. Each goroutine should create
+// its own; the Sessions of a Reader may be used concurrently with each
+// other and with the Reader's methods. A Session must not be used after
+// the Reader is closed.
+type Session struct {
+	reader  *Reader
+	network *net.IPNet
+	options lookupOptions
+	decoder decoder
+}
+
+// NewSession returns a Session for lookups in r that applies options to
+// each lookup as With does.
+func (r *Reader) NewSession(options ...LookupOption) *Session {
+	s := &Session{reader: r}
+	for _, option := range options {
+		option(&s.options)
+	}
+	return s
+}
+
+// Lookup retrieves the database record for ip and stores it in the value
+// pointed to by result. See Reader.Lookup.
+func (s *Session) Lookup(ip net.IP, result any) error {
+	r := s.reader
+	if r.buffer == nil {
+		return errors.New("cannot call Lookup on a closed database")
+	}
+	offset, _, _, err := r.lookupRecord(ip)
+	if offset == NotFound || err != nil {
+		return err
+	}
+	return s.decode(offset, result)
+}
+
+// LookupNetwork retrieves the database record for ip and stores it in the
+// value pointed to by result. It also returns the network associated with
+// the record. See Reader.LookupNetwork.
+//
+// The network is owned by the Session and is overwritten by its next call
+// to LookupNetwork. Copy it to keep it longer.
+func (s *Session) LookupNetwork(ip net.IP, result any) (network *net.IPNet, ok bool, err error) {
+	r := s.reader
+	if r.buffer == nil {
+		return nil, false, errors.New("cannot call Lookup on a closed database")
+	}
+	offset, prefixLength, ip, err := r.lookupRecord(ip)
+
+	if s.network == nil {
+		s.network = newIPNet()
+	}
+	r.cidrInto(s.network, ip, prefixLength)
+	if offset == NotFound || err != nil {
+		return s.network, false, err
+	}
+	return s.network, true, s.decode(offset, result)
+}
+
+// Decode decodes the record at offset into result. See Reader.Decode.
+func (s *Session) Decode(offset uintptr, result any) error {
+	if s.reader.buffer == nil {
+		return errors.New("cannot call Decode on a closed database")
+	}
+	return s.decode(offset, result)
+}
+
+func (s *Session) decode(offset uintptr, result any) error {
+	rv := reflect.ValueOf(result)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() {
+		return errors.New("result param must be a pointer")
+	}
+	// Copying the decoder resets its per-decode state without allocating.
+	s.decoder = s.reader.decoder
+	return s.reader.decodeValueWithDecoder(&s.decoder, offset, rv.Elem(), s.options)
+}
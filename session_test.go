@@ -0,0 +1,125 @@
+package maxminddb
+
+import (
+	"errors"
+	"net"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSession(t *testing.T) {
+	reader, err := Open(testFile("GeoIP2-City-Test.mmdb"))
+	require.NoError(t, err)
+	session := reader.NewSession()
+
+	var previous *net.IPNet
+	for _, ip := range []string{"81.2.69.160", "::ffff:81.2.69.142", "2001:218::", "10.0.0.1"} {
+		var expected, record fullCity
+		expectedNetwork, expectedOK, err := reader.LookupNetwork(net.ParseIP(ip), &expected)
+		require.NoError(t, err)
+		network, ok, err := session.LookupNetwork(net.ParseIP(ip), &record)
+		require.NoError(t, err)
+		assert.Equal(t, expectedOK, ok, ip)
+		assert.Equal(t, expectedNetwork, network, ip)
+		assert.Equal(t, expected, record, ip)
+		if previous != nil {
+			assert.Same(t, previous, network, "the network is reused")
+		}
+		previous = network
+
+		record = fullCity{}
+		require.NoError(t, session.Lookup(net.ParseIP(ip), &record))
+		assert.Equal(t, expected, record, ip)
+
+		offset, err := reader.LookupOffset(net.ParseIP(ip))
+		require.NoError(t, err)
+		if offset != NotFound {
+			record = fullCity{}
+			require.NoError(t, session.Decode(offset, &record))
+			assert.Equal(t, expected, record, ip)
+		}
+	}
+
+	require.NoError(t, reader.Close())
+	var record any
+	assert.EqualError(t, session.Lookup(net.ParseIP("81.2.69.160"), &record),
+		"cannot call Lookup on a closed database")
+	_, _, err = session.LookupNetwork(net.ParseIP("81.2.69.160"), &record)
+	assert.EqualError(t, err, "cannot call Lookup on a closed database")
+	assert.EqualError(t, session.Decode(0, &record), "cannot call Decode on a closed database")
+}
+
+type sessionRecord struct {
+	A uint16 `maxminddb:"a"`
+	B string `maxminddb:"b"`
+}
+
+// {"a": uint16(100), "b": "x"}
+const sessionTestData = "e2" + "4161a164" + "41624178"
+
+func TestSessionDecode(t *testing.T) {
+	reader := compareTestReader(t, sessionTestData)
+	session := reader.NewSession()
+
+	var record sessionRecord
+	require.NoError(t, session.Decode(0, &record))
+	assert.Equal(t, sessionRecord{A: 100, B: "x"}, record)
+	assert.EqualError(t, session.Decode(0, record), "result param must be a pointer")
+
+	// Per-decode state, e.g., the errors of mismatched fields, does not
+	// carry over between decodes.
+	var mismatched struct {
+		B uint16 `maxminddb:"b"`
+	}
+	for i := 0; i < 2; i++ {
+		var fieldErrs FieldErrors
+		require.True(t, errors.As(session.Decode(0, &mismatched), &fieldErrs))
+		assert.Len(t, fieldErrs, 1)
+	}
+	require.NoError(t, session.Decode(0, &record))
+
+	limited := reader.NewSession(MaxDecodedBytes(1))
+	var names map[string]string
+	var limitErr DecodedSizeLimitError
+	assert.True(t, errors.As(limited.Decode(0, &names), &limitErr))
+}
+
+func TestSessionAllocations(t *testing.T) {
+	reader := compareTestReader(t, sessionTestData)
+	session := reader.NewSession()
+
+	var record struct {
+		A uint16 `maxminddb:"a"`
+	}
+	readerAllocs := testing.AllocsPerRun(100, func() {
+		_ = reader.Decode(0, &record)
+	})
+	sessionAllocs := testing.AllocsPerRun(100, func() {
+		_ = session.Decode(0, &record)
+	})
+	assert.Less(t, sessionAllocs, readerAllocs)
+}
+
+func TestSessionsConcurrent(t *testing.T) {
+	reader := compareTestReader(t, sessionTestData)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			session := reader.NewSession()
+			for j := 0; j < 1000; j++ {
+				var record sessionRecord
+				if err := session.Decode(0, &record); err != nil || record.A != 100 || record.B != "x" {
+					t.Errorf("unexpected result %v, %v", record, err)
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+}
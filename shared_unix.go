@@ -0,0 +1,125 @@
+//go:build unix && !appengine
+// +build unix,!appengine
+
+package maxminddb
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// SharedDir is the directory holding the databases created with
+// CreateShared. It is /dev/shm, which is backed by shared memory, on Linux
+// and the default directory for temporary files elsewhere.
+var SharedDir = defaultSharedDir()
+
+func defaultSharedDir() string {
+	if runtime.GOOS == "linux" {
+		return "/dev/shm"
+	}
+	return os.TempDir()
+}
+
+// CreateShared copies the MaxMind DB file to a shared memory segment named
+// name, replacing the segment if it exists, so that processes on the host
+// can open it with FromShared and share one copy of the database in memory.
+// The database is verified, as by Reader.Verify, before it is published, so
+// the segment never holds a database that fails verification. name must
+// not contain a slash.
+//
+// The segment is read-only and is replaced rather than modified by later
+// calls, so Readers opened from the previous segment keep using it until
+// they are closed. It is not removed when the process exits; use
+// RemoveShared.
+func CreateShared(name, file string) (err error) {
+	path, err := sharedPath(name)
+	if err != nil {
+		return err
+	}
+	src, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	dst, err := os.CreateTemp(SharedDir, "."+name+".*")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			_ = dst.Close()
+			_ = os.Remove(dst.Name())
+		}
+	}()
+	if _, err := io.Copy(dst, src); err != nil {
+		return err
+	}
+	if err := dst.Chmod(0o444); err != nil {
+		return err
+	}
+	if err := dst.Close(); err != nil {
+		return err
+	}
+
+	reader, err := Open(dst.Name())
+	if err != nil {
+		return fmt.Errorf("maxminddb: not creating the shared database %q: %w", name, err)
+	}
+	verifyErr := reader.Verify()
+	if err := errors.Join(verifyErr, reader.Close()); err != nil {
+		return fmt.Errorf("maxminddb: not creating the shared database %q: %w", name, err)
+	}
+
+	return os.Rename(dst.Name(), path)
+}
+
+// FromShared returns a Reader for the shared memory segment named name, as
+// created with CreateShared. The segment is memory mapped read-only, so the
+// Reader never writes to it, and every process opening it shares the same
+// memory. Close unmaps the segment from the process but does not remove it.
+//
+// The database is checked as by Open, so a segment that is not a database,
+// e.g., because it was modified by something other than CreateShared,
+// results in an error. Reading the rest of the database is bounds checked,
+// so invalid data results in InvalidDatabaseErrors rather than crashes, but
+// the segment must not be truncated while it is mapped. Use
+// WithBackgroundVerify for a full verification.
+func FromShared(name string, options ...ReaderOption) (*Reader, error) {
+	path, err := sharedPath(name)
+	if err != nil {
+		return nil, err
+	}
+	reader, err := Open(path, options...)
+	if err != nil {
+		var invalidErr InvalidDatabaseError
+		if errors.As(err, &invalidErr) {
+			return nil, fmt.Errorf("maxminddb: invalid shared database %q: %w", name, err)
+		}
+		return nil, err
+	}
+	return reader, nil
+}
+
+// RemoveShared removes the shared memory segment named name. Readers opened
+// from it remain usable until they are closed, after which the memory is
+// released.
+func RemoveShared(name string) error {
+	path, err := sharedPath(name)
+	if err != nil {
+		return err
+	}
+	return os.Remove(path)
+}
+
+func sharedPath(name string) (string, error) {
+	if name == "" || name == "." || name == ".." || strings.ContainsRune(name, '/') {
+		return "", fmt.Errorf("maxminddb: invalid shared database name %q", name)
+	}
+	return filepath.Join(SharedDir, name), nil
+}
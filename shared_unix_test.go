@@ -0,0 +1,120 @@
+//go:build unix && !appengine
+// +build unix,!appengine
+
+package maxminddb
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func useSharedDir(t *testing.T) string {
+	t.Helper()
+	previous := SharedDir
+	t.Cleanup(func() { SharedDir = previous })
+	SharedDir = t.TempDir()
+	return SharedDir
+}
+
+func TestShared(t *testing.T) {
+	dir := useSharedDir(t)
+
+	require.NoError(t, CreateShared("ipv4", testFile("MaxMind-DB-test-ipv4-24.mmdb")))
+	stat, err := os.Stat(filepath.Join(dir, "ipv4"))
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0o444), stat.Mode().Perm())
+
+	first, err := FromShared("ipv4")
+	require.NoError(t, err)
+	second, err := FromShared("ipv4")
+	require.NoError(t, err)
+	assert.Equal(t, LoadModeMmap, first.LoadMode())
+	checkMetadata(t, first, 4, 24)
+	checkIpv4(t, first)
+	checkIpv4(t, second)
+
+	// Closing a Reader leaves the segment to the others.
+	require.NoError(t, first.Close())
+	checkIpv4(t, second)
+	_, err = os.Stat(filepath.Join(dir, "ipv4"))
+	require.NoError(t, err)
+
+	// Replacing the segment does not affect the Readers using it.
+	require.NoError(t, CreateShared("ipv4", testFile("MaxMind-DB-test-ipv6-24.mmdb")))
+	checkIpv4(t, second)
+	replaced, err := FromShared("ipv4")
+	require.NoError(t, err)
+	assert.Equal(t, uint(6), replaced.Metadata.IPVersion)
+	require.NoError(t, replaced.Close())
+
+	require.NoError(t, RemoveShared("ipv4"))
+	checkIpv4(t, second)
+	require.NoError(t, second.Close())
+	_, err = FromShared("ipv4")
+	assert.ErrorIs(t, err, os.ErrNotExist)
+
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	assert.Empty(t, entries)
+}
+
+func TestCreateSharedInvalidDatabase(t *testing.T) {
+	dir := useSharedDir(t)
+
+	err := CreateShared("broken", testFile("MaxMind-DB-test-broken-pointers-24.mmdb"))
+	assert.ErrorContains(t, err, `maxminddb: not creating the shared database "broken": `)
+	notDatabase := filepath.Join(t.TempDir(), "not-a-database")
+	require.NoError(t, os.WriteFile(notDatabase, []byte("not a database"), 0o644))
+	err = CreateShared("broken", notDatabase)
+	var invalidErr InvalidDatabaseError
+	assert.ErrorAs(t, err, &invalidErr)
+
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	assert.Empty(t, entries, "no segment or temporary file is left behind")
+}
+
+func TestFromSharedCorrupted(t *testing.T) {
+	dir := useSharedDir(t)
+
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "corrupted"), []byte("not a database"), 0o644))
+	_, err := FromShared("corrupted")
+	assert.EqualError(
+		t,
+		err,
+		`maxminddb: invalid shared database "corrupted": error opening database: invalid MaxMind DB file`,
+	)
+
+	// A segment with valid metadata but a corrupted search tree opens, and
+	// lookups report the corruption. Both records of the root node point
+	// past the end of the data section.
+	data, err := os.ReadFile(testFile("MaxMind-DB-test-ipv4-24.mmdb"))
+	require.NoError(t, err)
+	copy(data, []byte{0xff, 0xff, 0xff, 0xff, 0xff, 0xff})
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "corrupted"), data, 0o644))
+	reader, err := FromShared("corrupted")
+	require.NoError(t, err)
+	var record any
+	err = reader.Lookup(net.ParseIP("1.1.1.1"), &record)
+	var invalidErr InvalidDatabaseError
+	assert.ErrorAs(t, err, &invalidErr)
+	assert.Error(t, reader.Verify())
+	require.NoError(t, reader.Close())
+}
+
+func TestSharedNames(t *testing.T) {
+	useSharedDir(t)
+
+	for _, name := range []string{"", ".", "..", "a/b", "/etc/passwd"} {
+		assert.EqualError(t, CreateShared(name, testFile("MaxMind-DB-test-ipv4-24.mmdb")),
+			`maxminddb: invalid shared database name "`+name+`"`)
+		_, err := FromShared(name)
+		assert.Error(t, err)
+		assert.Error(t, RemoveShared(name))
+	}
+}
@@ -0,0 +1,225 @@
+package maxminddb
+
+import (
+	"errors"
+	"runtime"
+	"sort"
+)
+
+// DataStats holds figures describing the composition of the data section of
+// a MaxMind DB file. It is returned by Reader.DataStats.
+type DataStats struct {
+	// BytesByType is the number of bytes, including control bytes, used by
+	// each MaxMind DB data type. The keys are the type names used in the
+	// MaxMind DB specification, e.g., "utf8_string", "map", or "double".
+	// Maps and arrays are only charged for their own control bytes; their
+	// contents are counted under the types of the contained values.
+	BytesByType map[string]uint
+	// CountByType is the number of values of each MaxMind DB data type.
+	CountByType map[string]uint
+	// LargestRecords holds the largest top-level records in the data
+	// section, ordered from largest to smallest.
+	LargestRecords []RecordStats
+	// DataSectionSize is the total size of the data section in bytes.
+	DataSectionSize uint
+	// Records is the number of top-level records in the data section.
+	Records uint
+	// Pointers is the number of pointers in the data section.
+	Pointers uint
+	// PointerBytesSaved is an estimate of the number of bytes saved by
+	// pointer deduplication. For each pointer, it is the encoded size of the
+	// value pointed to less the size of the pointer itself.
+	PointerBytesSaved uint
+}
+
+// RecordStats describes a single top-level record in the data section.
+type RecordStats struct {
+	// Offset is the offset of the record in the data section. It may be
+	// passed to Decode.
+	Offset uintptr
+	// Size is the encoded size of the record in bytes, not including any
+	// values reached through pointers.
+	Size uint
+}
+
+var dataTypeNames = [...]string{
+	_Extended:  "extended",
+	_Pointer:   "pointer",
+	_String:    "utf8_string",
+	_Float64:   "double",
+	_Bytes:     "bytes",
+	_Uint16:    "uint16",
+	_Uint32:    "uint32",
+	_Map:       "map",
+	_Int32:     "int32",
+	_Uint64:    "uint64",
+	_Uint128:   "uint128",
+	_Slice:     "array",
+	_Container: "data_cache_container",
+	_Marker:    "end_marker",
+	_Bool:      "boolean",
+	_Float32:   "float",
+}
+
+func dataTypeName(t dataType) string {
+	if int(t) < len(dataTypeNames) {
+		return dataTypeNames[t]
+	}
+	return "unknown"
+}
+
+type statsWalker struct {
+	reader       *Reader
+	stats        *DataStats
+	pointerSizes map[uint]uint
+	topN         int
+}
+
+// DataStats performs a single linear walk of the data section and returns
+// figures on its composition: the bytes used by each data type, the number
+// of pointers and the bytes they save, and the topN largest records. Pointers
+// are not followed during the walk so that no value is counted twice.
+func (r *Reader) DataStats(topN int) (*DataStats, error) {
+	if r.buffer == nil {
+		return nil, errors.New("cannot call DataStats on a closed database")
+	}
+	w := statsWalker{
+		reader: r,
+		stats: &DataStats{
+			BytesByType: map[string]uint{},
+			CountByType: map[string]uint{},
+		},
+		pointerSizes: map[uint]uint{},
+		topN:         topN,
+	}
+
+	err := w.walkDataSection()
+	runtime.KeepAlive(w.reader)
+	if err != nil {
+		return nil, err
+	}
+	return w.stats, nil
+}
+
+func (w *statsWalker) walkDataSection() error {
+	d := &w.reader.decoder
+	bufferLen := uint(len(d.buffer))
+	w.stats.DataSectionSize = bufferLen
+
+	var offset uint
+	for offset < bufferLen {
+		newOffset, err := w.walk(offset, 0)
+		if err != nil {
+			return err
+		}
+		if newOffset <= offset {
+			return newInvalidDatabaseError(
+				"data section offset unexpectedly went from %v to %v",
+				offset,
+				newOffset,
+			)
+		}
+		w.stats.Records++
+		w.addRecord(RecordStats{Offset: uintptr(offset), Size: newOffset - offset})
+		offset = newOffset
+	}
+	return nil
+}
+
+func (w *statsWalker) addRecord(record RecordStats) {
+	if w.topN <= 0 {
+		return
+	}
+	largest := w.stats.LargestRecords
+	if len(largest) == w.topN && largest[len(largest)-1].Size >= record.Size {
+		return
+	}
+	i := sort.Search(len(largest), func(i int) bool {
+		return largest[i].Size < record.Size
+	})
+	if len(largest) < w.topN {
+		largest = append(largest, RecordStats{})
+	}
+	copy(largest[i+1:], largest[i:])
+	largest[i] = record
+	w.stats.LargestRecords = largest
+}
+
+func (w *statsWalker) walk(offset uint, depth int) (uint, error) {
+	if depth > maximumDataStructureDepth {
+		return 0, newInvalidDatabaseError(
+			"exceeded maximum data structure depth; database is likely corrupt",
+		)
+	}
+	d := &w.reader.decoder
+	typeNum, size, newOffset, err := d.decodeCtrlData(offset)
+	if err != nil {
+		return 0, err
+	}
+
+	name := dataTypeName(typeNum)
+	w.stats.CountByType[name]++
+
+	switch typeNum {
+	case _Pointer:
+		pointer, ptrOffset, err := d.decodePointer(size, newOffset)
+		if err != nil {
+			return 0, err
+		}
+		w.stats.BytesByType[name] += ptrOffset - offset
+		w.stats.Pointers++
+
+		targetSize, err := w.pointerTargetSize(pointer)
+		if err != nil {
+			return 0, err
+		}
+		if targetSize > ptrOffset-offset {
+			w.stats.PointerBytesSaved += targetSize - (ptrOffset - offset)
+		}
+		return ptrOffset, nil
+	case _Map:
+		w.stats.BytesByType[name] += newOffset - offset
+		for i := uint(0); i < 2*size; i++ {
+			newOffset, err = w.walk(newOffset, depth+1)
+			if err != nil {
+				return 0, err
+			}
+		}
+		return newOffset, nil
+	case _Slice:
+		w.stats.BytesByType[name] += newOffset - offset
+		for i := uint(0); i < size; i++ {
+			newOffset, err = w.walk(newOffset, depth+1)
+			if err != nil {
+				return 0, err
+			}
+		}
+		return newOffset, nil
+	case _Bool:
+		w.stats.BytesByType[name] += newOffset - offset
+		return newOffset, nil
+	default:
+		newOffset += size
+		if newOffset > uint(len(d.buffer)) {
+			return 0, newOffsetError()
+		}
+		w.stats.BytesByType[name] += newOffset - offset
+		return newOffset, nil
+	}
+}
+
+// pointerTargetSize returns the encoded size of the value at pointer. The
+// sizes are memoized as MaxMind DBs typically contain many pointers to the
+// same values.
+func (w *statsWalker) pointerTargetSize(pointer uint) (uint, error) {
+	if size, ok := w.pointerSizes[pointer]; ok {
+		return size, nil
+	}
+	next, err := w.reader.decoder.nextValueOffset(pointer, 1)
+	if err != nil {
+		return 0, err
+	}
+	size := next - pointer
+	w.pointerSizes[pointer] = size
+	return size, nil
+}
@@ -0,0 +1,52 @@
+package maxminddb
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDataStats(t *testing.T) {
+	reader, err := Open(testFile("GeoIP2-City-Test.mmdb"))
+	require.NoError(t, err)
+
+	stats, err := reader.DataStats(5)
+	require.NoError(t, err)
+
+	assert.Equal(t, uint(len(reader.decoder.buffer)), stats.DataSectionSize)
+
+	var total uint
+	for _, size := range stats.BytesByType {
+		total += size
+	}
+	assert.Equal(t, stats.DataSectionSize, total)
+
+	assert.NotZero(t, stats.Pointers)
+	assert.Equal(t, stats.Pointers, stats.CountByType["pointer"])
+	assert.NotZero(t, stats.PointerBytesSaved)
+
+	offsets := map[uintptr]bool{}
+	n := reader.Networks()
+	for n.Next() {
+		offset, err := reader.resolveDataPointer(n.lastNode.parent, n.lastNode.pointer)
+		require.NoError(t, err)
+		offsets[offset] = true
+	}
+	require.NoError(t, n.Err())
+	assert.Equal(t, uint(len(offsets)), stats.Records)
+
+	require.Len(t, stats.LargestRecords, 5)
+	for i, record := range stats.LargestRecords {
+		if i > 0 {
+			assert.GreaterOrEqual(t, stats.LargestRecords[i-1].Size, record.Size)
+		}
+		var result any
+		assert.NoError(t, reader.Decode(record.Offset, &result))
+	}
+
+	require.NoError(t, reader.Close())
+
+	_, err = reader.DataStats(5)
+	assert.EqualError(t, err, "cannot call DataStats on a closed database")
+}
@@ -0,0 +1,463 @@
+package maxminddb
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+
+	"github.com/3JoB/go-reflect"
+)
+
+// The kinds of the containers read by a Decoder, in addition to the kinds
+// of scalar values listed for DecodeHook.
+const (
+	KindMap   = Kind(_Map)
+	KindArray = Kind(_Slice)
+)
+
+// Decoder reads the values of the data section one at a time, as a
+// lower-level alternative to decoding with reflection, e.g., for
+// hand-written decoders of hot paths. It is returned by Reader.Decoder and
+// passed to Unmarshaler implementations.
+//
+// A Decoder is positioned at a value. Each Read method reads the value of
+// the expected kind and moves to the following value. ReadMap and ReadArray
+// read only the header of a container and move to its first entry or
+// element; the entries of a map are its keys and values in turn, i.e.,
+// ReadString followed by a read of the value. After the last entry of a
+// container, the Decoder is positioned after the container. Pointers are
+// followed transparently, and the data is checked as by the reflection
+// decoder.
+//
+// For instance, reading the country ISO code of a record:
+//
+//	d := reader.Decoder(offset)
+//	n, err := d.ReadMap()
+//	...
+//	for i := uint(0); i < n; i++ {
+//		key, err := d.ReadString()
+//		...
+//		if key != "country" {
+//			err = d.SkipValue()
+//			...
+//			continue
+//		}
+//		...
+//	}
+//
+// A Decoder returned by Reader.Decoder reads on past the value it started
+// at, e.g., into the following record. Other Decoders are limited to the
+// value they were positioned at and return an error when reading past it.
+//
+// A Decoder is not safe for concurrent use by multiple goroutines.
+type Decoder struct {
+	d *decoder
+	// reader is the Reader of a Decoder returned by Reader.Decoder. It
+	// keeps the Reader, and so its mapped file, from being finalized while
+	// the Decoder is in use, and is checked for having been closed.
+	reader *Reader
+	// stack holds the containers being read, innermost last.
+	stack  []decoderFrame
+	offset uint
+	// depth is the depth of the value the Decoder started at.
+	depth int
+	// root is set for Decoders returned by Reader.Decoder, which report
+	// the errors of a soft-failing Decode themselves.
+	root bool
+	// single is set for Decoders limited to a single value, and done once
+	// that value was read.
+	single bool
+	done   bool
+}
+
+type decoderFrame struct {
+	// remaining is the number of values left to read in the container,
+	// counting map keys and values separately.
+	remaining uint
+	// returnTo is the offset following the pointer the container was
+	// reached through, if pointer is set.
+	returnTo uint
+	pointer  bool
+}
+
+// decoderValue is the header of the value a Decoder is positioned at.
+type decoderValue struct {
+	dtype dataType
+	size  uint
+	// offset is the offset of the data of the value.
+	offset uint
+	// returnTo is the offset following the pointer the value was reached
+	// through, if pointer is set.
+	returnTo uint
+	pointer  bool
+}
+
+// Decoder returns a Decoder positioned at the value at offset, e.g., a
+// record offset as returned by LookupOffset or Result.Offset. The decoding
+// options of r apply to Decode.
+func (r *Reader) Decoder(offset uintptr) *Decoder {
+	d := r.decoder
+	return &Decoder{d: &d, reader: r, offset: uint(offset), root: true}
+}
+
+// Offset returns the offset of the value the Decoder is positioned at. For a
+// value reached through a pointer, this is the offset of the pointer.
+func (dec *Decoder) Offset() uintptr {
+	return uintptr(dec.offset)
+}
+
+// PeekKind returns the kind of the value without reading it.
+func (dec *Decoder) PeekKind() (Kind, error) {
+	v, err := dec.peek()
+	if err != nil {
+		return 0, err
+	}
+	return Kind(v.dtype), nil
+}
+
+// ReadMap reads the header of a map and returns its number of entries.
+func (dec *Decoder) ReadMap() (uint, error) {
+	v, err := dec.peekKind(_Map)
+	if err != nil {
+		return 0, err
+	}
+	return v.size, dec.enter(v, 2*v.size)
+}
+
+// ReadArray reads the header of an array and returns its number of
+// elements.
+func (dec *Decoder) ReadArray() (uint, error) {
+	v, err := dec.peekKind(_Slice)
+	if err != nil {
+		return 0, err
+	}
+	return v.size, dec.enter(v, v.size)
+}
+
+// ReadString reads a UTF-8 string, e.g., a map key.
+func (dec *Decoder) ReadString() (string, error) {
+	v, err := dec.peekKind(_String)
+	if err != nil {
+		return "", err
+	}
+	if err := dec.d.charge(v.size); err != nil {
+		return "", err
+	}
+	s, end := dec.d.decodeString(v.size, v.offset)
+	return s, dec.finish(v, end)
+}
+
+// ReadBytes reads a bytes value. The returned slice is a copy.
+func (dec *Decoder) ReadBytes() ([]byte, error) {
+	v, err := dec.peekKind(_Bytes)
+	if err != nil {
+		return nil, err
+	}
+	if err := dec.d.charge(v.size); err != nil {
+		return nil, err
+	}
+	b, end := dec.d.decodeBytes(v.size, v.offset)
+	return b, dec.finish(v, end)
+}
+
+// ReadBool reads a boolean.
+func (dec *Decoder) ReadBool() (bool, error) {
+	v, err := dec.peekKind(_Bool)
+	if err != nil {
+		return false, err
+	}
+	return v.size != 0, dec.finish(v, v.offset)
+}
+
+// ReadUint64 reads an unsigned integer of at most 64 bits, i.e., a uint16,
+// a uint32, or a uint64.
+func (dec *Decoder) ReadUint64() (uint64, error) {
+	v, err := dec.peek()
+	if err != nil {
+		return 0, err
+	}
+	switch v.dtype {
+	case _Uint16, _Uint32, _Uint64:
+	default:
+		return 0, kindError(v.dtype, KindUint64)
+	}
+	if v.size > 8 {
+		return 0, newInvalidDatabaseError("invalid size for %s: %d", dataTypeName(v.dtype), v.size)
+	}
+	n, end := dec.d.decodeUint(v.size, v.offset)
+	return n, dec.finish(v, end)
+}
+
+// ReadUint128 reads a uint128 as its high and low 64 bits.
+func (dec *Decoder) ReadUint128() (hi, lo uint64, err error) {
+	v, err := dec.peekKind(_Uint128)
+	if err != nil {
+		return 0, 0, err
+	}
+	if v.size > 16 {
+		return 0, 0, newInvalidDatabaseError("invalid size for uint128: %d", v.size)
+	}
+	b, end := dec.d.decodeUint128Bytes(v.size, v.offset)
+	return binary.BigEndian.Uint64(b[:8]), binary.BigEndian.Uint64(b[8:]), dec.finish(v, end)
+}
+
+// ReadInt32 reads an int32.
+func (dec *Decoder) ReadInt32() (int32, error) {
+	v, err := dec.peekKind(_Int32)
+	if err != nil {
+		return 0, err
+	}
+	if v.size > 4 {
+		return 0, newInvalidDatabaseError("invalid size for int32: %d", v.size)
+	}
+	n, end := dec.d.decodeInt(v.size, v.offset)
+	return int32(n), dec.finish(v, end)
+}
+
+// ReadFloat64 reads a double.
+func (dec *Decoder) ReadFloat64() (float64, error) {
+	v, err := dec.peekKind(_Float64)
+	if err != nil {
+		return 0, err
+	}
+	if v.size != 8 {
+		return 0, newInvalidDatabaseError("invalid size for double: %d", v.size)
+	}
+	f, end := dec.d.decodeFloat64(v.size, v.offset)
+	return f, dec.finish(v, end)
+}
+
+// ReadFloat32 reads a float.
+func (dec *Decoder) ReadFloat32() (float32, error) {
+	v, err := dec.peekKind(_Float32)
+	if err != nil {
+		return 0, err
+	}
+	if v.size != 4 {
+		return 0, newInvalidDatabaseError("invalid size for float: %d", v.size)
+	}
+	f, end := dec.d.decodeFloat32(v.size, v.offset)
+	return f, dec.finish(v, end)
+}
+
+// SkipValue moves past the value, including all of its entries or elements
+// if it is a container, without reading it.
+func (dec *Decoder) SkipValue() error {
+	if _, err := dec.peek(); err != nil {
+		return err
+	}
+	end, err := dec.d.nextValueOffset(dec.offset, 1)
+	if err != nil {
+		return err
+	}
+	dec.offset = end
+	dec.next()
+	return nil
+}
+
+// ReadRaw reads the value, including all of its entries or elements if it
+// is a container, and returns it in the self-contained form described for
+// Reader.LookupRaw.
+func (dec *Decoder) ReadRaw() ([]byte, error) {
+	if _, err := dec.peek(); err != nil {
+		return nil, err
+	}
+	raw, end, err := dec.d.appendRaw(nil, dec.offset, dec.depth+len(dec.stack))
+	if err != nil {
+		return nil, err
+	}
+	dec.offset = end
+	dec.next()
+	return raw, nil
+}
+
+// Decode decodes the value into the value pointed to by result as the
+// Reader would, e.g., into a map[string]any, a *big.Int for a uint128, or
+// a type implementing Unmarshaler, and moves past it.
+func (dec *Decoder) Decode(result any) error {
+	rv := reflect.ValueOf(result)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() {
+		return errors.New("result param must be a pointer")
+	}
+	if _, err := dec.peek(); err != nil {
+		return err
+	}
+	d := dec.d
+	numErrors := len(d.fieldErrors)
+	end, err := d.decode(dec.offset, rv.Elem(), dec.depth+len(dec.stack))
+	if err != nil {
+		return err
+	}
+	dec.offset = end
+	dec.next()
+	if dec.root && len(d.fieldErrors) > numErrors {
+		return d.fieldErrors[numErrors:]
+	}
+	return nil
+}
+
+// DecodeMap reads a map and calls f with the key and a Decoder positioned
+// at the value of each entry, in the order the entries appear in the
+// database. f may read the value, or any part of it, or not read it at all.
+// DecodeMap stops and returns the error if f returns an error.
+func (dec *Decoder) DecodeMap(f func(key string, value *Decoder) error) error {
+	n, err := dec.ReadMap()
+	if err != nil {
+		return err
+	}
+	for i := uint(0); i < n; i++ {
+		key, err := dec.ReadString()
+		if err != nil {
+			return err
+		}
+		if err := f(key, dec.valueDecoder()); err != nil {
+			return err
+		}
+		if err := dec.SkipValue(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// DecodeArray reads an array and calls f with the index of and a Decoder
+// positioned at each element. f may read the element, or any part of it, or
+// not read it at all. DecodeArray stops and returns the error if f returns
+// an error.
+func (dec *Decoder) DecodeArray(f func(i int, value *Decoder) error) error {
+	n, err := dec.ReadArray()
+	if err != nil {
+		return err
+	}
+	for i := uint(0); i < n; i++ {
+		if err := f(int(i), dec.valueDecoder()); err != nil {
+			return err
+		}
+		if err := dec.SkipValue(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// valueDecoder returns a Decoder limited to the value dec is positioned at.
+func (dec *Decoder) valueDecoder() *Decoder {
+	return &Decoder{
+		d:      dec.d,
+		reader: dec.reader,
+		offset: dec.offset,
+		depth:  dec.depth + len(dec.stack),
+		root:   dec.root,
+		single: true,
+	}
+}
+
+// peek returns the header of the value, following a pointer, and checks
+// that the value is within the buffer.
+func (dec *Decoder) peek() (decoderValue, error) {
+	if dec.done {
+		return decoderValue{}, errors.New("maxminddb: the value of the Decoder was already read")
+	}
+	if dec.reader != nil && dec.reader.buffer == nil {
+		return decoderValue{}, errors.New("cannot read from a Decoder of a closed database")
+	}
+	d := dec.d
+	dtype, size, offset, err := d.decodeCtrlData(dec.offset)
+	if err != nil {
+		return decoderValue{}, err
+	}
+	var v decoderValue
+	if dtype == _Pointer {
+		pointer, returnTo, err := d.decodePointer(size, offset)
+		if err != nil {
+			return decoderValue{}, err
+		}
+		dtype, size, offset, err = d.decodeCtrlData(pointer)
+		if err != nil {
+			return decoderValue{}, err
+		}
+		if dtype == _Pointer {
+			return decoderValue{}, newInvalidDatabaseError("invalid pointer to a pointer")
+		}
+		v.returnTo = returnTo
+		v.pointer = true
+	}
+	v.dtype, v.size, v.offset = dtype, size, offset
+
+	switch dtype {
+	case _Map:
+		err = d.checkContainerSize(2*size, offset)
+	case _Slice:
+		err = d.checkContainerSize(size, offset)
+	case _Bool:
+		if size > 1 {
+			err = newInvalidDatabaseError(
+				"the MaxMind DB file's data section contains bad data (bool size of %v)",
+				size,
+			)
+		}
+	default:
+		if offset+size > uint(len(d.buffer)) {
+			err = newOffsetError()
+		}
+	}
+	return v, err
+}
+
+// peekKind is peek for a value of type dtype.
+func (dec *Decoder) peekKind(dtype dataType) (decoderValue, error) {
+	v, err := dec.peek()
+	if err != nil {
+		return decoderValue{}, err
+	}
+	if v.dtype != dtype {
+		return decoderValue{}, kindError(v.dtype, Kind(dtype))
+	}
+	return v, nil
+}
+
+// enter moves into the container v holding n values.
+func (dec *Decoder) enter(v decoderValue, n uint) error {
+	if n == 0 {
+		return dec.finish(v, v.offset)
+	}
+	if dec.depth+len(dec.stack) >= maximumDataStructureDepth {
+		return newInvalidDatabaseError(
+			"exceeded maximum data structure depth; database is likely corrupt",
+		)
+	}
+	dec.stack = append(dec.stack, decoderFrame{remaining: n, returnTo: v.returnTo, pointer: v.pointer})
+	dec.offset = v.offset
+	return nil
+}
+
+// finish moves past v, whose data ends at end.
+func (dec *Decoder) finish(v decoderValue, end uint) error {
+	if v.pointer {
+		end = v.returnTo
+	}
+	dec.offset = end
+	dec.next()
+	return nil
+}
+
+// next moves out of the containers whose last value was read.
+func (dec *Decoder) next() {
+	for len(dec.stack) > 0 {
+		top := &dec.stack[len(dec.stack)-1]
+		top.remaining--
+		if top.remaining > 0 {
+			return
+		}
+		if top.pointer {
+			dec.offset = top.returnTo
+		}
+		dec.stack = dec.stack[:len(dec.stack)-1]
+	}
+	dec.done = dec.single
+}
+
+// kindError returns the error for reading a value of type dtype as want.
+func kindError(dtype dataType, want Kind) error {
+	return fmt.Errorf("maxminddb: cannot read %s as %s", dataTypeName(dtype), want)
+}
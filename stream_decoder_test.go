@@ -0,0 +1,195 @@
+package maxminddb
+
+import (
+	"math/big"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// readAny reads the value at dec as decoding into an any would.
+func readAny(t *testing.T, dec *Decoder) any {
+	t.Helper()
+	kind, err := dec.PeekKind()
+	require.NoError(t, err)
+	switch kind {
+	case KindMap:
+		n, err := dec.ReadMap()
+		require.NoError(t, err)
+		m := make(map[string]any, n)
+		for i := uint(0); i < n; i++ {
+			key, err := dec.ReadString()
+			require.NoError(t, err)
+			m[key] = readAny(t, dec)
+		}
+		return m
+	case KindArray:
+		n, err := dec.ReadArray()
+		require.NoError(t, err)
+		s := make([]any, n)
+		for i := range s {
+			s[i] = readAny(t, dec)
+		}
+		return s
+	case KindUTF8String:
+		v, err := dec.ReadString()
+		require.NoError(t, err)
+		return v
+	case KindBytes:
+		v, err := dec.ReadBytes()
+		require.NoError(t, err)
+		return v
+	case KindBoolean:
+		v, err := dec.ReadBool()
+		require.NoError(t, err)
+		return v
+	case KindUint16, KindUint32, KindUint64:
+		v, err := dec.ReadUint64()
+		require.NoError(t, err)
+		return v
+	case KindUint128:
+		hi, lo, err := dec.ReadUint128()
+		require.NoError(t, err)
+		v := new(big.Int).SetUint64(hi)
+		v.Lsh(v, 64)
+		return v.Or(v, new(big.Int).SetUint64(lo))
+	case KindInt32:
+		v, err := dec.ReadInt32()
+		require.NoError(t, err)
+		return int(v)
+	case KindDouble:
+		v, err := dec.ReadFloat64()
+		require.NoError(t, err)
+		return v
+	case KindFloat:
+		v, err := dec.ReadFloat32()
+		require.NoError(t, err)
+		return v
+	}
+	t.Fatalf("unexpected kind %v", kind)
+	return nil
+}
+
+func TestStreamDecoder(t *testing.T) {
+	for _, test := range []struct {
+		file string
+		ips  []string
+	}{
+		{file: "MaxMind-DB-test-decoder.mmdb", ips: []string{"::1.1.1.0"}},
+		// The records of this database share values through pointers.
+		{file: "GeoIP2-City-Test.mmdb", ips: []string{"81.2.69.160", "2.125.160.216", "2001:218::"}},
+	} {
+		reader, err := Open(testFile(test.file))
+		require.NoError(t, err)
+
+		for _, ip := range test.ips {
+			offset, err := reader.LookupOffset(net.ParseIP(ip))
+			require.NoError(t, err)
+			var expected any
+			require.NoError(t, reader.Decode(offset, &expected))
+
+			dec := reader.Decoder(offset)
+			assert.Equal(t, expected, readAny(t, dec), "%s in %s", ip, test.file)
+			end, err := reader.decoder.nextValueOffset(uint(offset), 1)
+			require.NoError(t, err)
+			assert.Equal(t, uintptr(end), dec.Offset(), "%s in %s", ip, test.file)
+
+			// Decode and SkipValue move past the record too.
+			dec = reader.Decoder(offset)
+			var record any
+			require.NoError(t, dec.Decode(&record))
+			assert.Equal(t, expected, record)
+			assert.Equal(t, uintptr(end), dec.Offset())
+			dec = reader.Decoder(offset)
+			require.NoError(t, dec.SkipValue())
+			assert.Equal(t, uintptr(end), dec.Offset())
+		}
+		require.NoError(t, reader.Close())
+	}
+}
+
+func TestDecoderPartialReads(t *testing.T) {
+	reader, err := Open(testFile("GeoIP2-City-Test.mmdb"))
+	require.NoError(t, err)
+	defer reader.Close()
+	offset, err := reader.LookupOffset(net.ParseIP("2.125.160.216"))
+	require.NoError(t, err)
+
+	var isoCodes []string
+	dec := reader.Decoder(offset)
+	require.NoError(t, dec.DecodeMap(func(key string, value *Decoder) error {
+		if key != "subdivisions" {
+			return nil
+		}
+		return value.DecodeArray(func(_ int, value *Decoder) error {
+			return value.DecodeMap(func(key string, value *Decoder) error {
+				if key != "iso_code" {
+					return nil
+				}
+				isoCode, err := value.ReadString()
+				isoCodes = append(isoCodes, isoCode)
+				return err
+			})
+		})
+	}))
+	assert.Equal(t, []string{"ENG", "WBK"}, isoCodes)
+
+	// The Decoders passed to the functions are limited to their value.
+	dec = reader.Decoder(offset)
+	require.NoError(t, dec.DecodeMap(func(key string, value *Decoder) error {
+		if key != "location" {
+			return nil
+		}
+		require.NoError(t, value.SkipValue())
+		_, err := value.PeekKind()
+		assert.EqualError(t, err, "maxminddb: the value of the Decoder was already read")
+		return nil
+	}))
+}
+
+func TestDecoderErrors(t *testing.T) {
+	reader, err := Open(testFile("MaxMind-DB-test-decoder.mmdb"))
+	require.NoError(t, err)
+	defer reader.Close()
+	offset, err := reader.LookupOffset(net.ParseIP("::1.1.1.0"))
+	require.NoError(t, err)
+
+	dec := reader.Decoder(offset)
+	_, err = dec.ReadString()
+	assert.EqualError(t, err, "maxminddb: cannot read map as utf8_string")
+	_, err = dec.ReadArray()
+	assert.EqualError(t, err, "maxminddb: cannot read map as array")
+	_, _, err = dec.ReadUint128()
+	assert.EqualError(t, err, "maxminddb: cannot read map as uint128")
+	// Failed reads do not move the Decoder.
+	assert.Equal(t, offset, dec.Offset())
+	_, err = dec.ReadMap()
+	require.NoError(t, err)
+	_, err = dec.ReadUint64()
+	assert.EqualError(t, err, "maxminddb: cannot read utf8_string as uint64")
+	assert.EqualError(t, dec.Decode(offset), "result param must be a pointer")
+
+	dec = reader.Decoder(uintptr(len(reader.decoder.buffer)))
+	_, err = dec.PeekKind()
+	var invalidErr InvalidDatabaseError
+	assert.ErrorAs(t, err, &invalidErr)
+	assert.ErrorAs(t, dec.SkipValue(), &invalidErr)
+}
+
+func TestDecoderClosedReader(t *testing.T) {
+	reader := compareTestReader(t, sessionTestData)
+	dec := reader.Decoder(0)
+	n, err := dec.ReadMap()
+	require.NoError(t, err)
+	assert.Equal(t, uint(2), n)
+
+	require.NoError(t, reader.Close())
+	const closedErr = "cannot read from a Decoder of a closed database"
+	_, err = dec.ReadString()
+	assert.EqualError(t, err, closedErr)
+	assert.EqualError(t, dec.SkipValue(), closedErr)
+	var record sessionRecord
+	assert.EqualError(t, reader.Decoder(0).Decode(&record), closedErr)
+}
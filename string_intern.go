@@ -0,0 +1,72 @@
+package maxminddb
+
+import (
+	"sync/atomic"
+)
+
+const (
+	// stringTableSize is the number of slots in a stringTable. It is a
+	// power of two.
+	stringTableSize = 4096
+	// maxInternedLen is the length of the longest string interned. Map keys
+	// and the values repeated across records, e.g., ISO codes, are short,
+	// while longer strings are mostly unique.
+	maxInternedLen = 64
+)
+
+// stringTable interns the strings decoded from a database whose buffer
+// never changes, i.e., one opened with Open or FromReader rather than
+// FromBytes. The same key, e.g., "names", and many values are stored once
+// in the data section and referenced by pointers, so the offset of a string
+// identifies it exactly, and decoding it again returns the string decoded
+// before rather than a new copy.
+//
+// The table is direct-mapped: each slot holds the most recent string whose
+// offset maps to it. Slots are updated atomically so the table may be
+// shared across goroutines without locking.
+type stringTable struct {
+	slots [stringTableSize]atomic.Pointer[internedString]
+}
+
+type internedString struct {
+	s      string
+	offset uint
+}
+
+func newStringTable() *stringTable {
+	return &stringTable{}
+}
+
+// intern returns b, a slice of buffer, as a string, which is shared with
+// earlier calls for the same slice. It returns false if b is not a slice of
+// buffer, e.g., because it is a transformed map key, or is too long to be
+// interned.
+func (t *stringTable) intern(buffer, b []byte) (string, bool) {
+	if len(b) == 0 || len(b) > maxInternedLen {
+		return "", false
+	}
+	// b shares the end of the memory of buffer, so the difference of their
+	// capacities is its offset if it is a slice of buffer.
+	offset := uint(cap(buffer) - cap(b))
+	if offset >= uint(len(buffer)) || &buffer[offset] != &b[0] {
+		return "", false
+	}
+
+	slot := &t.slots[offset&(stringTableSize-1)]
+	if e := slot.Load(); e != nil && e.offset == offset && len(e.s) == len(b) {
+		return e.s, true
+	}
+	s := string(b)
+	slot.Store(&internedString{s: s, offset: offset})
+	return s, true
+}
+
+// fromOwnedBytes is FromBytes for a buffer owned by the Reader, e.g., one
+// read from a file, whose strings are interned. Strings are not interned
+// for Readers created with FromBytes as the caller may modify their buffer.
+func fromOwnedBytes(buffer []byte, options []ReaderOption) (*Reader, error) {
+	options = append(options[:len(options):len(options)], func(o *readerOptions) {
+		o.internStrings = true
+	})
+	return FromBytes(buffer, options...)
+}
@@ -0,0 +1,75 @@
+package maxminddb
+
+import (
+	"encoding/hex"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/3JoB/go-reflect"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStringTable(t *testing.T) {
+	buffer := []byte("xnamesnames" + strings.Repeat("y", maxInternedLen+1))
+	table := newStringTable()
+
+	s, ok := table.intern(buffer, buffer[1:6])
+	require.True(t, ok)
+	assert.Equal(t, "names", s)
+	allocs := testing.AllocsPerRun(100, func() {
+		s, ok = table.intern(buffer, buffer[1:6])
+	})
+	assert.Zero(t, allocs, "interned strings are not copied again")
+	assert.Equal(t, "names", s)
+
+	// The same bytes at another offset and a shorter string at the same
+	// offset are interned separately.
+	s, ok = table.intern(buffer, buffer[6:11])
+	require.True(t, ok)
+	assert.Equal(t, "names", s)
+	s, ok = table.intern(buffer, buffer[1:3])
+	require.True(t, ok)
+	assert.Equal(t, "na", s)
+
+	_, ok = table.intern(buffer, []byte("names"))
+	assert.False(t, ok, "not a slice of the buffer")
+	_, ok = table.intern(buffer, buffer[11:])
+	assert.False(t, ok, "too long")
+	_, ok = table.intern(buffer, buffer[1:1])
+	assert.False(t, ok, "empty")
+}
+
+func TestInternedStrings(t *testing.T) {
+	buffer, err := hex.DecodeString(languageTestData)
+	require.NoError(t, err)
+	d := decoder{buffer: buffer, strings: newStringTable()}
+
+	for i := 0; i < 2; i++ {
+		var names map[string]string
+		_, err = d.decode(0, reflect.ValueOf(&names), 0)
+		require.NoError(t, err)
+		assert.Equal(t, map[string]string{"de": "A", "en": "B", "pt-BR": "C"}, names)
+	}
+
+	// Transformed keys are not slices of the buffer.
+	d.keyTransform = func(_, key string) string { return strings.ToUpper(key) }
+	var names map[string]any
+	_, err = d.decode(0, reflect.ValueOf(&names), 0)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]any{"DE": "A", "EN": "B", "PT-BR": "C"}, names)
+}
+
+func TestInternedStringsOnlyForOwnedBuffers(t *testing.T) {
+	reader, err := Open(testFile("GeoIP2-City-Test.mmdb"))
+	require.NoError(t, err)
+	defer reader.Close()
+	assert.NotNil(t, reader.decoder.strings)
+
+	buffer, err := os.ReadFile(testFile("GeoIP2-City-Test.mmdb"))
+	require.NoError(t, err)
+	reader, err = FromBytes(buffer)
+	require.NoError(t, err)
+	assert.Nil(t, reader.decoder.strings)
+}
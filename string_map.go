@@ -0,0 +1,131 @@
+package maxminddb
+
+import (
+	"github.com/3JoB/go-reflect"
+)
+
+var (
+	stringMapType = reflect.TypeOf(map[string]string(nil))
+	anyMapType    = reflect.TypeOf(map[string]any(nil))
+
+	// stringMapFastPath may be cleared by tests to decode all maps with
+	// decodeMap.
+	stringMapFastPath = true
+)
+
+// usesStringMapFastPath reports whether the map result, which is not nil,
+// may be decoded with decodeStringMap.
+func (d *decoder) usesStringMapFastPath(result reflect.Value) bool {
+	if !stringMapFastPath || d.decodeHooks != nil || !result.CanInterface() {
+		return false
+	}
+	t := result.Type()
+	return t == stringMapType || t == anyMapType
+}
+
+// decodeStringMap is decodeMap for results of type map[string]string and
+// map[string]any, by far the most common maps, e.g., for names. Their keys
+// and string values are stored without reflection; other values are decoded
+// as by decodeMap, with the same results and errors.
+func (d *decoder) decodeStringMap(
+	size uint,
+	offset uint,
+	result reflect.Value,
+	depth int,
+) (uint, error) {
+	stringValues, _ := result.Interface().(map[string]string)
+	anys, _ := result.Interface().(map[string]any)
+
+	var elemValue reflect.Value
+	index := d.indexedMap(offset)
+	for i := uint(0); i < size; i++ {
+		var key []byte
+		var err error
+		key, offset, err = d.mapEntry(index, i, offset)
+		if err != nil {
+			return 0, err
+		}
+		if d.keyTransform != nil {
+			key = []byte(d.keyTransform(d.parentKey, string(key)))
+		}
+
+		numErrors := len(d.fieldErrors)
+		valueOffset := offset
+		value, isString, newOffset, err := d.decodeStringValue(offset, depth)
+		if err == nil && !isString {
+			if !elemValue.IsValid() {
+				elemValue = reflect.New(result.Type().Elem()).Elem()
+			} else {
+				reflectSetZero(elemValue)
+			}
+			newOffset, err = d.decodeMapValue(key, offset, elemValue, depth)
+		}
+		offset = newOffset
+		if err != nil {
+			if typeErr, ok := err.(UnmarshalTypeError); ok && !d.softFail {
+				// The path names the key of the mismatched value.
+				err = FieldError{Err: typeErr}
+			}
+			// The entry is left out of the map.
+			offset, err = d.skipFailedValue(err, valueOffset, numErrors, string(key))
+			if err != nil {
+				return 0, err
+			}
+			continue
+		}
+		d.fieldErrors.prefix(numErrors, string(key))
+
+		if err := d.charge(uint(len(key))); err != nil {
+			return 0, err
+		}
+		k := d.bufferString(key)
+		switch {
+		case !isString:
+			result.SetMapIndex(reflect.ValueOf(k), elemValue)
+		case stringValues != nil:
+			stringValues[k] = value
+		default:
+			anys[k] = value
+		}
+	}
+	if index != nil {
+		return index.end, nil
+	}
+	return offset, nil
+}
+
+// decodeStringValue returns the string at offset, following a pointer, and
+// the offset of the following value. It returns false, without an error,
+// if the value is not a string or is too deeply nested, so that it is
+// decoded as usual.
+func (d *decoder) decodeStringValue(offset uint, depth int) (string, bool, uint, error) {
+	if depth+1 > maximumDataStructureDepth {
+		return "", false, offset, nil
+	}
+	typeNum, size, dataOffset, err := d.decodeCtrlData(offset)
+	if err != nil {
+		return "", false, 0, err
+	}
+	newOffset := dataOffset + size
+	if typeNum == _Pointer {
+		var pointer uint
+		pointer, newOffset, err = d.decodePointer(size, dataOffset)
+		if err != nil {
+			return "", false, 0, err
+		}
+		typeNum, size, dataOffset, err = d.decodeCtrlData(pointer)
+		if err != nil {
+			return "", false, 0, err
+		}
+	}
+	if typeNum != _String {
+		return "", false, offset, nil
+	}
+	if dataOffset+size > uint(len(d.buffer)) {
+		return "", false, 0, newOffsetError()
+	}
+	if err := d.charge(size); err != nil {
+		return "", false, 0, err
+	}
+	return d.bufferString(d.buffer[dataOffset : dataOffset+size]), true, newOffset, nil
+}
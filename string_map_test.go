@@ -0,0 +1,116 @@
+package maxminddb
+
+import (
+	"encoding/hex"
+	"testing"
+
+	"github.com/3JoB/go-reflect"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// withoutStringMapFastPath runs test with all maps decoded by decodeMap.
+func withoutStringMapFastPath(t *testing.T, test func(*testing.T)) {
+	stringMapFastPath = false
+	defer func() { stringMapFastPath = true }()
+	test(t)
+}
+
+// TestStringMapFastPathTests runs the tests that decode map[string]string
+// and map[string]any with the fast path turned off, as it is on otherwise.
+func TestStringMapFastPathTests(t *testing.T) {
+	for name, test := range map[string]func(*testing.T){
+		"Map":                 TestMap,
+		"TypedMaps":           TestTypedMaps,
+		"DecodeLanguage":      TestDecodeLanguage,
+		"UnsafeStrings":       TestUnsafeStrings,
+		"WeaklyTypedToString": TestWeaklyTypedNumberToString,
+		"Lenient":             TestLenientDecode,
+		"FieldMismatches":     TestDecodeStructFieldMismatches,
+		"TypeErrorPath":       TestUnmarshalTypeErrorPath,
+	} {
+		t.Run(name, func(t *testing.T) {
+			withoutStringMapFastPath(t, test)
+		})
+	}
+}
+
+func TestStringMapFastPathMatchesReflection(t *testing.T) {
+	type decoded struct {
+		result      any
+		err         error
+		fieldErrors FieldErrors
+	}
+	decode := func(input string, offset uint, d decoder, newResult func() any) decoded {
+		buffer, err := hex.DecodeString(input)
+		require.NoError(t, err)
+		d.buffer = buffer
+		result := newResult()
+		_, err = d.decode(offset, reflect.ValueOf(result), 0)
+		return decoded{result, err, d.fieldErrors}
+	}
+
+	newStrings := func() any { return new(map[string]string) }
+	newAnys := func() any { return new(map[string]any) }
+	for _, test := range []struct {
+		name      string
+		input     string
+		offset    uint
+		d         decoder
+		newResult func() any
+	}{
+		{"names", languageTestData, 0, decoder{}, newStrings},
+		{"names any", languageTestData, 0, decoder{}, newAnys},
+		{"pointers", languageTestData, 19, decoder{}, newAnys},
+		{"mismatches", conversionTestData, 0, decoder{}, newStrings},
+		{"soft fail", conversionTestData, 0, decoder{softFail: true}, newStrings},
+		{"weakly typed", conversionTestData, 0, decoder{weaklyTyped: true}, newStrings},
+		{"lenient", conversionTestData, 0, decoder{weaklyTyped: true, softFail: true, reportCoercions: true}, newStrings},
+		{"mixed", conversionTestData, 0, decoder{}, newAnys},
+		{"limit", languageTestData, 0, decoder{maxDecodedBytes: 100}, newStrings},
+		{"key transform", languageTestData, 19, decoder{keyTransform: func(parent, key string) string {
+			return parent + "/" + key
+		}}, newAnys},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			fast := decode(test.input, test.offset, test.d, test.newResult)
+			var slow decoded
+			withoutStringMapFastPath(t, func(*testing.T) {
+				slow = decode(test.input, test.offset, test.d, test.newResult)
+			})
+			assert.Equal(t, slow, fast)
+		})
+	}
+}
+
+func BenchmarkStringMaps(b *testing.B) {
+	buffer, err := hex.DecodeString(languageTestData)
+	require.NoError(b, err)
+	d := decoder{buffer: buffer}
+	for _, bench := range []struct {
+		name   string
+		result any
+	}{
+		{"map[string]string", new(map[string]string)},
+		{"map[string]any", new(map[string]any)},
+	} {
+		for _, fastPath := range []bool{true, false} {
+			name := bench.name + "/reflection"
+			if fastPath {
+				name = bench.name + "/fast"
+			}
+			b.Run(name, func(b *testing.B) {
+				stringMapFastPath = fastPath
+				defer func() { stringMapFastPath = true }()
+				b.ReportAllocs()
+				result := reflect.ValueOf(bench.result)
+				for i := 0; i < b.N; i++ {
+					result.Elem().Set(reflect.Zero(result.Elem().Type()))
+					if _, err := d.decode(0, result, 0); err != nil {
+						b.Fatal(err)
+					}
+				}
+			})
+		}
+	}
+}
@@ -0,0 +1,396 @@
+//go:build !purego
+// +build !purego
+
+package maxminddb
+
+import (
+	"encoding"
+	"math"
+	"sync"
+	"unsafe"
+
+	"github.com/3JoB/go-reflect"
+)
+
+// structPlans may be cleared by tests to decode all structs with
+// decodeStructFields.
+var structPlans = true
+
+// structPlan is the precomputed plan for decoding a map into a struct type
+// whose fields are all booleans, strings, integers, floats, or structs of
+// such fields, e.g., a struct holding just a country's ISO code. The fields
+// are set through their offsets rather than with reflection. Values that do
+// not fit their field exactly, e.g., because they are of another type, are
+// decoded as decodeStructFields does, with the same results and errors.
+type structPlan struct {
+	// fields holds the plans of the fields, keyed by their database key.
+	fields map[string]*fieldPlan
+}
+
+type fieldPlan struct {
+	// nested is the plan of a struct field.
+	nested *structPlan
+	offset uintptr
+	// index is the index of the field, which is decoded with reflection if
+	// its value does not fit it exactly.
+	index int
+	kind  reflect.Kind
+	// bits is the size of a numeric field in bits.
+	bits int
+}
+
+var (
+	unmarshalerType       = reflect.TypeOf((*Unmarshaler)(nil)).Elem()
+	binaryUnmarshalerType = reflect.TypeOf((*encoding.BinaryUnmarshaler)(nil)).Elem()
+)
+
+// structPlansMap holds the *structPlan of each struct type and tag fallback,
+// or nil if the type cannot be decoded with a plan.
+var structPlansMap sync.Map
+
+// cachedStructPlan returns the plan of the struct type t, or nil if it has
+// fields that cannot be set without reflection or that need more than a
+// key lookup, e.g., inline or required fields.
+func cachedStructPlan(t reflect.Type, tagFallback string) *structPlan {
+	key := fieldsKey{typ: t, tagFallback: tagFallback}
+	if plan, ok := structPlansMap.Load(key); ok {
+		return plan.(*structPlan)
+	}
+	plan := newStructPlan(t, tagFallback)
+	stored, _ := structPlansMap.LoadOrStore(key, plan)
+	return stored.(*structPlan)
+}
+
+func newStructPlan(t reflect.Type, tagFallback string) *structPlan {
+	if hasCustomDecoding(t) {
+		return nil
+	}
+	fields := cachedFields(reflect.Zero(t), tagFallback)
+	if fields.inlineFields != nil || fields.anonymousFields != nil || fields.remain != nil ||
+		fields.languages != nil || fields.required != nil || fields.conversions != nil {
+		return nil
+	}
+
+	plan := &structPlan{fields: make(map[string]*fieldPlan, len(fields.namedFields))}
+	for key, i := range fields.namedFields {
+		field := t.Field(i)
+		if hasCustomDecoding(field.Type) {
+			return nil
+		}
+		fp := &fieldPlan{
+			offset: field.Offset,
+			index:  i,
+			kind:   field.Type.Kind(),
+			bits:   int(field.Type.Size()) * 8,
+		}
+		switch fp.kind {
+		case reflect.Bool, reflect.String,
+			reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+			reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+			reflect.Float32, reflect.Float64:
+		case reflect.Struct:
+			// Struct fields cannot hold their own type, so this ends.
+			fp.nested = cachedStructPlan(field.Type, tagFallback)
+			if fp.nested == nil {
+				return nil
+			}
+		default:
+			return nil
+		}
+		plan.fields[key] = fp
+	}
+	return plan
+}
+
+// hasCustomDecoding returns true if values of type t, or pointers to them,
+// decode themselves.
+func hasCustomDecoding(t reflect.Type) bool {
+	p := reflect.PtrTo(t)
+	return p.Implements(unmarshalerType) || p.Implements(textUnmarshalerType) ||
+		p.Implements(binaryUnmarshalerType)
+}
+
+// decodePlannedStruct decodes the map of size entries at offset into the
+// struct result with its plan, if it has one and the decoder's options do
+// not change how structs are decoded. It returns false if the struct must
+// be decoded with decodeStructFields.
+func (d *decoder) decodePlannedStruct(
+	size uint,
+	offset uint,
+	result reflect.Value,
+	depth int,
+) (uint, bool, error) {
+	if !structPlans || d.decodeHooks != nil || d.weaklyTyped || d.keyTransform != nil || d.strict ||
+		!result.CanAddr() {
+		return 0, false, nil
+	}
+	plan := cachedStructPlan(result.Type(), d.tagFallback)
+	if plan == nil {
+		return 0, false, nil
+	}
+	base := reflect.ToReflectValue(result).Addr().UnsafePointer()
+	newOffset, err := d.runStructPlan(plan, size, offset, result, base, depth)
+	return newOffset, true, err
+}
+
+// runStructPlan is decodeStructFields for a struct with plan, which is
+// result and is stored at base.
+func (d *decoder) runStructPlan(
+	plan *structPlan,
+	size uint,
+	offset uint,
+	result reflect.Value,
+	base unsafe.Pointer,
+	depth int,
+) (uint, error) {
+	index := d.indexedMap(offset)
+	for i := uint(0); i < size; i++ {
+		var (
+			err error
+			key []byte
+		)
+		key, offset, err = d.mapEntry(index, i, offset)
+		if err != nil {
+			return 0, err
+		}
+		field, ok := plan.fields[string(key)]
+		if !ok {
+			if index != nil {
+				continue
+			}
+			offset, err = d.nextValueOffset(offset, 1)
+			if err != nil {
+				return 0, err
+			}
+			continue
+		}
+
+		numErrors := len(d.fieldErrors)
+		valueOffset := offset
+		offset, err = d.runFieldPlan(field, key, offset, result, base, depth)
+		if err == nil {
+			// Converting the key allocates, so it is only done for errors.
+			if len(d.fieldErrors) > numErrors {
+				d.fieldErrors.prefix(numErrors, string(key))
+			}
+			continue
+		}
+		if next, ok := d.skipMismatchedField(err, valueOffset, numErrors, string(key)); ok {
+			offset = next
+		} else {
+			offset, err = d.skipFailedValue(err, valueOffset, numErrors, string(key))
+			if err != nil {
+				return 0, err
+			}
+		}
+		reflectSetZero(result.Field(field.index))
+	}
+	if index != nil {
+		return index.end, nil
+	}
+	return offset, nil
+}
+
+// runFieldPlan decodes the value at offset, the value of key, into the
+// field of result with plan field. Values that do not fit the field exactly
+// and anything unusual, e.g., a corrupt value, are left to
+// decodeMapValue.
+func (d *decoder) runFieldPlan(
+	field *fieldPlan,
+	key []byte,
+	offset uint,
+	result reflect.Value,
+	base unsafe.Pointer,
+	depth int,
+) (uint, error) {
+	// decodeMapValue checks the depth of the value and of the value a
+	// pointer points to.
+	if depth+2 > maximumDataStructureDepth {
+		return d.decodeMapValue(key, offset, result.Field(field.index), depth)
+	}
+	dtype, size, dataOffset, err := d.decodeCtrlData(offset)
+	if err != nil {
+		return d.decodeMapValue(key, offset, result.Field(field.index), depth)
+	}
+	var newOffset uint
+	isPointer := dtype == _Pointer
+	if isPointer {
+		var pointer uint
+		pointer, newOffset, err = d.decodePointer(size, dataOffset)
+		if err == nil {
+			dtype, size, dataOffset, err = d.decodeCtrlData(pointer)
+		}
+		if err != nil || dtype == _Pointer {
+			return d.decodeMapValue(key, offset, result.Field(field.index), depth)
+		}
+	}
+
+	p := unsafe.Add(base, field.offset)
+	if field.kind == reflect.Struct {
+		if dtype != _Map || d.checkContainerSize(size, dataOffset) != nil {
+			return d.decodeMapValue(key, offset, result.Field(field.index), depth)
+		}
+		// As in decodeMapValue, the map is one level deeper than the value,
+		// or two if the value is a pointer to it.
+		mapDepth := depth + 1
+		if isPointer {
+			mapDepth++
+		}
+		end, err := d.runStructPlan(field.nested, size, dataOffset, result.Field(field.index), p, mapDepth)
+		if err != nil {
+			return 0, withDatabaseType(err, _Map)
+		}
+		if isPointer {
+			return newOffset, nil
+		}
+		return end, nil
+	}
+
+	end, ok, err := d.storeScalar(field, dtype, size, dataOffset, p)
+	if err != nil {
+		return 0, err
+	}
+	if !ok {
+		return d.decodeMapValue(key, offset, result.Field(field.index), depth)
+	}
+	if isPointer {
+		return newOffset, nil
+	}
+	return end, nil
+}
+
+// storeScalar stores the value of type dtype at offset in the field with
+// plan field at p and returns the offset following the value. It returns
+// false if the value does not fit the field exactly.
+func (d *decoder) storeScalar(
+	field *fieldPlan,
+	dtype dataType,
+	size uint,
+	offset uint,
+	p unsafe.Pointer,
+) (uint, bool, error) {
+	if dtype == _Bool {
+		if field.kind != reflect.Bool || size > 1 {
+			return 0, false, nil
+		}
+		*(*bool)(p) = size != 0
+		return offset, true, nil
+	}
+	if dtype == _Map || dtype == _Slice || offset+size > uint(len(d.buffer)) {
+		return 0, false, nil
+	}
+
+	switch field.kind {
+	case reflect.String:
+		if dtype != _String {
+			return 0, false, nil
+		}
+		if err := d.charge(size); err != nil {
+			return 0, false, err
+		}
+		value, newOffset := d.decodeString(size, offset)
+		*(*string)(p) = value
+		return newOffset, true, nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		var n int64
+		switch {
+		case isUintType(dtype, size):
+			value, _ := d.decodeUint(size, offset)
+			n = int64(value)
+		case dtype == _Int32 && size <= 4:
+			value, _ := d.decodeInt(size, offset)
+			n = int64(value)
+		default:
+			return 0, false, nil
+		}
+		if field.bits < 64 {
+			if trunc := (n << (64 - field.bits)) >> (64 - field.bits); n != trunc {
+				return 0, false, nil
+			}
+		}
+		storeInt(p, field.kind, n)
+		return offset + size, true, nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		var n uint64
+		switch {
+		case isUintType(dtype, size):
+			n, _ = d.decodeUint(size, offset)
+		case dtype == _Int32 && size <= 4:
+			value, _ := d.decodeInt(size, offset)
+			n = uint64(value)
+		default:
+			return 0, false, nil
+		}
+		if field.bits < 64 && n>>field.bits != 0 {
+			return 0, false, nil
+		}
+		storeUint(p, field.kind, n)
+		return offset + size, true, nil
+	case reflect.Float32, reflect.Float64:
+		var value float64
+		switch {
+		case dtype == _Float32 && size == 4:
+			f, _ := d.decodeFloat32(size, offset)
+			value = float64(f)
+		case dtype == _Float64 && size == 8:
+			value, _ = d.decodeFloat64(size, offset)
+			if field.kind == reflect.Float32 && math.Abs(value) > math.MaxFloat32 &&
+				!math.IsInf(value, 0) {
+				return 0, false, nil
+			}
+		default:
+			return 0, false, nil
+		}
+		if field.kind == reflect.Float32 {
+			*(*float32)(p) = float32(value)
+		} else {
+			*(*float64)(p) = value
+		}
+		return offset + size, true, nil
+	}
+	return 0, false, nil
+}
+
+// isUintType returns true if dtype is an unsigned integer type of at most
+// 64 bits that is no larger than its size allows.
+func isUintType(dtype dataType, size uint) bool {
+	switch dtype {
+	case _Uint16:
+		return size <= 2
+	case _Uint32:
+		return size <= 4
+	case _Uint64:
+		return size <= 8
+	}
+	return false
+}
+
+func storeInt(p unsafe.Pointer, kind reflect.Kind, n int64) {
+	switch kind {
+	case reflect.Int:
+		*(*int)(p) = int(n)
+	case reflect.Int8:
+		*(*int8)(p) = int8(n)
+	case reflect.Int16:
+		*(*int16)(p) = int16(n)
+	case reflect.Int32:
+		*(*int32)(p) = int32(n)
+	case reflect.Int64:
+		*(*int64)(p) = n
+	}
+}
+
+func storeUint(p unsafe.Pointer, kind reflect.Kind, n uint64) {
+	switch kind {
+	case reflect.Uint:
+		*(*uint)(p) = uint(n)
+	case reflect.Uint8:
+		*(*uint8)(p) = uint8(n)
+	case reflect.Uint16:
+		*(*uint16)(p) = uint16(n)
+	case reflect.Uint32:
+		*(*uint32)(p) = uint32(n)
+	case reflect.Uint64:
+		*(*uint64)(p) = n
+	}
+}
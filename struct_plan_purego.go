@@ -0,0 +1,15 @@
+//go:build purego
+// +build purego
+
+package maxminddb
+
+import "github.com/3JoB/go-reflect"
+
+// structPlans has no effect as decoding structs with plans requires unsafe.
+var structPlans = true
+
+// decodePlannedStruct returns false as decoding structs with plans requires
+// unsafe.
+func (d *decoder) decodePlannedStruct(uint, uint, reflect.Value, int) (uint, bool, error) {
+	return 0, false, nil
+}
@@ -0,0 +1,238 @@
+//go:build !purego
+// +build !purego
+
+package maxminddb
+
+import (
+	"encoding/hex"
+	"net"
+	"testing"
+
+	"github.com/3JoB/go-reflect"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// structPlanTestData is a country map, {"iso_code": "GB", "geoname_id":
+// uint32(2635167), "is_in_european_union": true, "names": {"en": "United
+// Kingdom"}}, followed at offset 76 by a record with a pointer to it, a
+// copy of it, and values that fit some fields and not others:
+//
+//	{
+//	  "country": <pointer to offset 0>,
+//	  "registered_country": <the same country map>,
+//	  "location": {"latitude": 51.5, "accuracy_radius": uint16(100), "tiny": uint16(100)},
+//	  "neg": int32(-5),
+//	  "big": uint64(1<<63 + 1),
+//	  "f32": float32(1.5),
+//	  "f64big": 1e300,
+//	  "name": <pointer to offset 1, the string "iso_code">,
+//	  "overflow": uint16(300),
+//	  "text": "abc",
+//	  "list": [uint16(1)],
+//	}
+const (
+	structPlanTestData = "e44869736f5f636f64654247424a67656f6e616d655f6964c328359f5469735f696e5f65" +
+		"75726f7065616e5f756e696f6e0107456e616d6573e142656e4e556e69746564204b696e67" +
+		"646f6deb47636f756e747279200052726567697374657265645f636f756e747279e4486973" +
+		"6f5f636f64654247424a67656f6e616d655f6964c328359f5469735f696e5f6575726f7065" +
+		"616e5f756e696f6e0107456e616d6573e142656e4e556e69746564204b696e67646f6d486c" +
+		"6f636174696f6ee3486c61746974756465684049c000000000004f61636375726163795f72" +
+		"6164697573a1644474696e79a164436e65670401fffffffb43626967080280000000000000" +
+		"014366333204083fc0000046663634626967687e37e43c8800759c446e616d652001486f76" +
+		"6572666c6f77a2012c447465787443616263446c6973740104a101"
+	structPlanTestRecord = 76
+)
+
+type planCountry struct {
+	ISOCode   string `maxminddb:"iso_code"`
+	GeoNameID uint32 `maxminddb:"geoname_id"`
+	InEU      bool   `maxminddb:"is_in_european_union"`
+}
+
+type planRecord struct {
+	Country    planCountry `maxminddb:"country"`
+	Registered planCountry `maxminddb:"registered_country"`
+	Location   struct {
+		Latitude float64 `maxminddb:"latitude"`
+		Radius   uint16  `maxminddb:"accuracy_radius"`
+		Tiny     int8    `maxminddb:"tiny"`
+	} `maxminddb:"location"`
+	Neg  int32   `maxminddb:"neg"`
+	Big  int64   `maxminddb:"big"`
+	F32  float32 `maxminddb:"f32"`
+	Name string  `maxminddb:"name"`
+}
+
+// planMismatches has fields that none of their values fit.
+type planMismatches struct {
+	Country  string      `maxminddb:"country"`
+	Location planCountry `maxminddb:"text"`
+	Neg      uint8       `maxminddb:"neg"`
+	Big      int8        `maxminddb:"big"`
+	F64      float32     `maxminddb:"f64big"`
+	Overflow uint8       `maxminddb:"overflow"`
+	Name     bool        `maxminddb:"name"`
+	List     int         `maxminddb:"list"`
+}
+
+// withoutStructPlans runs test with all structs decoded by
+// decodeStructFields.
+func withoutStructPlans(t *testing.T, test func(*testing.T)) {
+	structPlans = false
+	defer func() { structPlans = true }()
+	test(t)
+}
+
+// TestStructPlanTests runs the tests that decode structs with plans turned
+// off, as they are on otherwise.
+func TestStructPlanTests(t *testing.T) {
+	for name, test := range map[string]func(*testing.T){
+		"FieldMismatches":  TestDecodeStructFieldMismatches,
+		"TypeErrorPath":    TestUnmarshalTypeErrorPath,
+		"SkippedFields":    TestSkippedFields,
+		"RecursiveStructs": TestRecursiveStructTypes,
+		"RequiredFields":   TestRequiredFields,
+		"FieldConversions": TestFieldConversions,
+	} {
+		t.Run(name, func(t *testing.T) {
+			withoutStructPlans(t, test)
+		})
+	}
+}
+
+func TestStructPlanMatchesReflection(t *testing.T) {
+	type decoded struct {
+		result      any
+		err         error
+		fieldErrors FieldErrors
+		offset      uint
+	}
+	buffer, err := hex.DecodeString(structPlanTestData)
+	require.NoError(t, err)
+	decode := func(d decoder, offset uint, newResult func() any) decoded {
+		d.buffer = buffer
+		result := newResult()
+		newOffset, err := d.decode(offset, reflect.ValueOf(result), 0)
+		return decoded{result, err, d.fieldErrors, newOffset}
+	}
+
+	newRecord := func() any { return new(planRecord) }
+	newMismatches := func() any { return new(planMismatches) }
+	newCountry := func() any { return new(planCountry) }
+	for _, test := range []struct {
+		name      string
+		d         decoder
+		offset    uint
+		newResult func() any
+	}{
+		{"record", decoder{}, structPlanTestRecord, newRecord},
+		{"country", decoder{}, 0, newCountry},
+		{"mismatches", decoder{}, structPlanTestRecord, newMismatches},
+		{"soft fail", decoder{softFail: true}, structPlanTestRecord, newMismatches},
+		{"limit", decoder{maxDecodedBytes: 20}, structPlanTestRecord, newRecord},
+		{"unsafe strings", decoder{unsafeStrings: true}, structPlanTestRecord, newRecord},
+		{"not a map", decoder{}, 1, newCountry},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			planned := decode(test.d, test.offset, test.newResult)
+			var reflected decoded
+			withoutStructPlans(t, func(*testing.T) {
+				reflected = decode(test.d, test.offset, test.newResult)
+			})
+			assert.Equal(t, reflected, planned)
+		})
+	}
+
+	record := decode(decoder{}, structPlanTestRecord, newRecord)
+	require.NoError(t, record.err)
+	country := planCountry{ISOCode: "GB", GeoNameID: 2635167, InEU: true}
+	expected := &planRecord{Country: country, Registered: country, Neg: -5, Big: -1<<63 + 1, F32: 1.5, Name: "iso_code"}
+	expected.Location.Latitude = 51.5
+	expected.Location.Radius = 100
+	expected.Location.Tiny = 100
+	assert.Equal(t, expected, record.result)
+}
+
+func TestStructPlanEligibility(t *testing.T) {
+	for _, test := range []struct {
+		value   any
+		planned bool
+	}{
+		{planRecord{}, true},
+		{planCountry{}, true},
+		{planMismatches{}, true},
+		{struct{}{}, true},
+		{struct{ Names map[string]string }{}, false},
+		{struct{ Country *planCountry }{}, false},
+		{struct{ Codes []string }{}, false},
+		{struct{ Value any }{}, false},
+		{struct{ IP net.IP }{}, false},
+		{struct {
+			Country struct{ Names map[string]string }
+		}{}, false},
+		{struct {
+			Country planCountry `maxminddb:",inline"`
+		}{}, false},
+		{struct {
+			Code string `maxminddb:"iso_code,required"`
+		}{}, false},
+		{struct {
+			Name string `maxminddb:"names,lang=en"`
+		}{}, false},
+		{struct {
+			Remain map[string]any `maxminddb:",remain"`
+		}{}, false},
+		{struct{ Value textUnmarshalerValue }{}, false},
+	} {
+		typ := reflect.TypeOf(test.value)
+		assert.Equal(t, test.planned, cachedStructPlan(typ, "") != nil, "%v", typ)
+	}
+}
+
+// textUnmarshalerValue is a string decoded with UnmarshalText.
+type textUnmarshalerValue string
+
+func (v *textUnmarshalerValue) UnmarshalText(text []byte) error {
+	*v = textUnmarshalerValue(text)
+	return nil
+}
+
+func TestStructPlanAllocations(t *testing.T) {
+	buffer, err := hex.DecodeString(structPlanTestData)
+	require.NoError(t, err)
+	d := decoder{buffer: buffer}
+	var result planRecord
+	value := reflect.ValueOf(&result)
+	allocs := testing.AllocsPerRun(100, func() {
+		if _, err := d.decode(structPlanTestRecord, value, 0); err != nil {
+			t.Fatal(err)
+		}
+	})
+	// Only the strings are allocated.
+	assert.LessOrEqual(t, allocs, 3.0)
+}
+
+func BenchmarkStructPlans(b *testing.B) {
+	buffer, err := hex.DecodeString(structPlanTestData)
+	require.NoError(b, err)
+	d := decoder{buffer: buffer}
+	for _, plans := range []bool{true, false} {
+		name := "reflection"
+		if plans {
+			name = "plan"
+		}
+		b.Run(name, func(b *testing.B) {
+			structPlans = plans
+			defer func() { structPlans = true }()
+			b.ReportAllocs()
+			var result planRecord
+			value := reflect.ValueOf(&result)
+			for i := 0; i < b.N; i++ {
+				if _, err := d.decode(structPlanTestRecord, value, 0); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,16 @@
+package maxminddb
+
+// WithTagFallback is an option for Open, FromBytes, and FromReader that
+// decodes the struct fields without a maxminddb tag using the tag with the
+// given key instead, e.g., WithTagFallback("json") for structs already
+// annotated for encoding/json. Only the name in the fallback tag is used;
+// options such as omitempty are ignored, and an empty name means the field
+// name as usual. A field whose fallback tag is "-" is not decoded.
+//
+// A maxminddb tag always takes precedence, even if its name is empty, e.g.,
+// `maxminddb:",inline"`. CheckType does not apply the fallback.
+func WithTagFallback(key string) ReaderOption {
+	return func(o *readerOptions) {
+		o.tagFallback = key
+	}
+}
@@ -0,0 +1,113 @@
+package maxminddb
+
+import (
+	"net"
+	"testing"
+
+	"github.com/3JoB/go-reflect"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type jsonTaggedCity struct {
+	City struct {
+		GeoNameID uint              `json:"geoname_id,omitempty"`
+		Names     map[string]string `json:"names"`
+	} `json:"city"`
+	Country struct {
+		// The maxminddb tag wins over the json tag.
+		ISOCode string `json:"country_code" maxminddb:"iso_code"`
+		Skipped string `json:"-"`
+		IsInEU  bool   `json:"is_in_european_union,omitempty"`
+		Names   map[string]string
+	} `json:"country"`
+	Subdivisions []struct {
+		IsoCode string `json:"iso_code"`
+	} `json:"subdivisions"`
+	// A maxminddb tag without a name means the field name.
+	Location struct {
+		TimeZone string `json:"time_zone"`
+	} `json:"location" maxminddb:""`
+}
+
+func TestWithTagFallback(t *testing.T) {
+	reader, err := Open(testFile("GeoIP2-City-Test.mmdb"), WithTagFallback("json"))
+	require.NoError(t, err)
+	defer reader.Close()
+	ip := net.ParseIP("2.125.160.216")
+
+	var record map[string]any
+	require.NoError(t, reader.Lookup(ip, &record))
+	country := record["country"].(map[string]any)
+
+	var result jsonTaggedCity
+	require.NoError(t, reader.Lookup(ip, &result))
+	assert.NotZero(t, result.City.GeoNameID)
+	assert.Equal(t, "Boxford", result.City.Names["en"])
+	assert.Equal(t, country["iso_code"], result.Country.ISOCode)
+	assert.Empty(t, result.Country.Skipped)
+	assert.Equal(t, country["is_in_european_union"] == true, result.Country.IsInEU)
+	// Without any tag, the field name is the key as usual.
+	assert.Nil(t, result.Country.Names)
+	assert.Equal(t, []struct {
+		IsoCode string `json:"iso_code"`
+	}{{"ENG"}, {"WBK"}}, result.Subdivisions)
+	assert.Empty(t, result.Location.TimeZone)
+
+	// Without the option, the json tags are ignored.
+	plain, err := Open(testFile("GeoIP2-City-Test.mmdb"))
+	require.NoError(t, err)
+	defer plain.Close()
+	var ignored jsonTaggedCity
+	require.NoError(t, plain.Lookup(ip, &ignored))
+	assert.Zero(t, ignored.City.GeoNameID)
+	assert.Empty(t, ignored.Subdivisions)
+	assert.Equal(t, country["iso_code"], ignored.Country.ISOCode, "the maxminddb tag still applies")
+}
+
+func TestParseFieldTagFallback(t *testing.T) {
+	type tagged struct {
+		Plain    string
+		JSON     string `json:"json_name,omitempty"`
+		Empty    string `json:",omitempty"`
+		Dash     string `json:"-"`
+		DashName string `json:"-,"`
+		Both     string `json:"both_name" maxminddb:"db_name"`
+		Skipped  string `json:"skipped_name" maxminddb:"-"`
+		Inline   struct {
+			A string
+		} `json:"inline" maxminddb:",inline"`
+		Other string `yaml:"yaml_name"`
+	}
+	typ := reflect.TypeOf(tagged{})
+
+	for _, test := range []struct {
+		field    string
+		name     string
+		inline   bool
+		skip     bool
+		fallback string
+	}{
+		{field: "Plain", name: "Plain"},
+		{field: "JSON", name: "JSON"},
+		{field: "JSON", name: "json_name", fallback: "json"},
+		{field: "Empty", name: "Empty", fallback: "json"},
+		{field: "Dash", name: "Dash"},
+		{field: "Dash", skip: true, fallback: "json"},
+		{field: "DashName", name: "-", fallback: "json"},
+		{field: "Both", name: "db_name", fallback: "json"},
+		{field: "Skipped", skip: true, fallback: "json"},
+		{field: "Inline", name: "Inline", inline: true, fallback: "json"},
+		{field: "Other", name: "Other", fallback: "json"},
+		{field: "Other", name: "yaml_name", fallback: "yaml"},
+	} {
+		field, ok := typ.FieldByName(test.field)
+		require.True(t, ok)
+		name, inline, skip := parseFieldTag(field, test.fallback)
+		assert.Equal(t, test.skip, skip, "%s with %q", test.field, test.fallback)
+		if !test.skip {
+			assert.Equal(t, test.name, name, "%s with %q", test.field, test.fallback)
+			assert.Equal(t, test.inline, inline, "%s with %q", test.field, test.fallback)
+		}
+	}
+}
@@ -1,15 +1,23 @@
 package maxminddb
 
 import (
+	"errors"
 	"fmt"
 	"net"
+	"net/netip"
+	"strings"
+
+	"github.com/3JoB/go-reflect"
 )
 
 // Internal structure used to keep track of nodes we still need to visit.
+// parent is the node holding pointer, which is used to describe invalid
+// records.
 type netNode struct {
 	ip      net.IP
 	bit     uint
 	pointer uint
+	parent  uint
 }
 
 // Networks represents a set of subnets that we are iterating over.
@@ -17,8 +25,41 @@ type Networks struct {
 	err                 error
 	reader              *Reader
 	nodes               []netNode
+	resumeFrom          *Cursor
 	lastNode            netNode
 	skipAliasedNetworks bool
+	unmapIPv4Networks   bool
+	projectIPv4         bool
+	resumeMismatch      bool
+	skipIPv4Subtree     bool
+
+	// recordCache holds recently decoded records when the
+	// CacheDecodedRecords option is set.
+	recordCache        *recordCache
+	shareCachedRecords bool
+
+	// With the CollectErrors option, checkedRecords holds the result of
+	// decoding each record offset and recordErrors the skipped networks.
+	collectErrors  bool
+	checkedRecords map[uintptr]error
+	recordErrors   []NetworkError
+
+	// target is the result bound with the DecodeInto option.
+	target any
+
+	// pendingIPv4Projection is set when the network most recently prepared
+	// by Next should be followed by its IPv4 projection, and ipv4Projection
+	// is set while that projection is the current network.
+	pendingIPv4Projection bool
+	ipv4Projection        bool
+}
+
+// Cursor is an opaque value capturing a position within a network
+// iteration. It is returned by Networks.Cursor and may be passed to the
+// ResumeFrom option to continue an iteration after that position.
+type Cursor struct {
+	ip  net.IP
+	bit uint
 }
 
 var (
@@ -39,6 +80,143 @@ func SkipAliasedNetworks(networks *Networks) {
 	networks.skipAliasedNetworks = true
 }
 
+// UnmapIPv4Networks is an option for Networks and NetworksWithin that makes
+// them return networks within the IPv4-mapped range, ::ffff:0:0/96, of an
+// IPv6 database as IPv4 networks, i.e., with a 4-byte IP and mask. For
+// instance, NetworksWithin 1.1.1.0/24 walks ::ffff:1.1.1.0/120 in an IPv6
+// database and, without this option, returns networks such as
+// ::ffff:1.1.1.0/120 that only print like IPv4 networks. Networks that
+// extend beyond the IPv4-mapped range are returned unchanged.
+func UnmapIPv4Networks(networks *Networks) {
+	networks.unmapIPv4Networks = true
+}
+
+// ProjectIPv4Networks is an option for Networks and NetworksWithin that
+// makes them additionally yield 0.0.0.0/0 after a network in an IPv6
+// database that contains the whole IPv4 subtree, ::/96. Databases without
+// an IPv4 search tree, for instance, answer IPv4 lookups with a record for
+// a network such as ::/64, which otherwise only appears as that IPv6
+// network. The IPv4 network is yielded exactly once, directly after the
+// IPv6 network, and has the same record.
+func ProjectIPv4Networks(networks *Networks) {
+	networks.projectIPv4 = true
+}
+
+// CacheDecodedRecords is an option for Networks and NetworksWithin that
+// makes Network keep the size most recently decoded records, keyed by their
+// offset and the type of the result. Many networks share the same record,
+// so this avoids decoding the record again for each of them when walking the
+// database in order.
+//
+// On a cache hit, Network sets the result to a deep copy of the cached value
+// so that the caller may modify it. Use ShareCachedRecords to skip the copy.
+// With a cache, the result is always replaced rather than merged with its
+// existing contents. Results implementing the deserializer interface are not
+// cached.
+func CacheDecodedRecords(size int) NetworksOption {
+	return func(networks *Networks) {
+		if size > 0 {
+			networks.recordCache = newRecordCache(size)
+		}
+	}
+}
+
+// ShareCachedRecords is an option for Networks and NetworksWithin that makes
+// Network set the result to the cached value itself on a cache hit rather
+// than a copy of it. Maps, slices, and pointers in the result are then
+// shared with the results of other calls and must not be modified. It has
+// no effect without the CacheDecodedRecords option.
+func ShareCachedRecords(networks *Networks) {
+	networks.shareCachedRecords = true
+}
+
+// CollectErrors is an option for Networks and NetworksWithin that makes
+// records that cannot be decoded non-fatal. Next decodes the record of each
+// network before yielding it and skips the network if that fails. The
+// skipped networks and their errors are available from RecordErrors, and
+// Err reports them together with any other error once the iteration is done.
+// Each record is checked only once, however many networks share it.
+//
+// Errors in the search tree, e.g., a node pointing outside of the data
+// section, still end the iteration. Network may still return an error, e.g.,
+// if the record cannot be stored in the result's type.
+func CollectErrors(networks *Networks) {
+	networks.collectErrors = true
+	networks.checkedRecords = map[uintptr]error{}
+}
+
+// DecodeInto is an option for Networks and NetworksWithin that binds result,
+// a pointer, to the iterator. Next then decodes the record of each network
+// into result before returning true, and Network is called with nil or with
+// result itself to get the network. result is zeroed before each record is
+// decoded so that no field of the previous record remains; with
+// CacheDecodedRecords, the cache applies as it does for Network. If the
+// record cannot be decoded, Next returns false and Err returns the error.
+//
+// Without DecodeInto, Network called with nil returns the network without
+// decoding its record.
+func DecodeInto(result any) NetworksOption {
+	return func(networks *Networks) {
+		networks.target = result
+	}
+}
+
+const cursorFormatVersion = "v1"
+
+// MarshalText implements encoding.TextMarshaler. The text form consists of
+// a format version followed by the last network yielded in CIDR form, e.g.,
+// "v1:1.1.1.0/24". IPv4 networks in an IPv6 database are encoded in their
+// IPv6 form, e.g., "v1:::101:100/120". The zero Cursor is encoded as an
+// empty string.
+func (c Cursor) MarshalText() ([]byte, error) {
+	if c.ip == nil {
+		return []byte{}, nil
+	}
+	addr, ok := netip.AddrFromSlice(c.ip)
+	if !ok {
+		return nil, fmt.Errorf("invalid cursor IP: %v", c.ip)
+	}
+	prefix := netip.PrefixFrom(addr, int(c.bit))
+	return []byte(cursorFormatVersion + ":" + prefix.String()), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler. It accepts the text
+// form produced by MarshalText.
+func (c *Cursor) UnmarshalText(text []byte) error {
+	if len(text) == 0 {
+		*c = Cursor{}
+		return nil
+	}
+	version, network, ok := strings.Cut(string(text), ":")
+	if !ok || version != cursorFormatVersion {
+		return fmt.Errorf("unsupported cursor format: %q", text)
+	}
+	prefix, err := netip.ParsePrefix(network)
+	if err != nil {
+		return fmt.Errorf("invalid cursor network: %w", err)
+	}
+	*c = Cursor{
+		ip:  prefix.Masked().Addr().AsSlice(),
+		bit: uint(prefix.Bits()),
+	}
+	return nil
+}
+
+// ResumeFrom is an option for Networks and NetworksWithin that makes the
+// iteration continue with the network following the position captured by
+// cursor. The iteration should use the same options as the one that
+// produced the cursor. The zero Cursor resumes from the beginning.
+//
+// The cursor may have been produced from a different build of the database.
+// If the cursor does not correspond to a network in the database being
+// iterated over, the iteration resumes at the nearest network following the
+// cursor's network and Networks.ResumeMismatch reports true.
+func ResumeFrom(cursor Cursor) NetworksOption {
+	return func(networks *Networks) {
+		networks.resumeFrom = &cursor
+	}
+}
+
 // Networks returns an iterator that can be used to traverse all networks in
 // the database.
 //
@@ -57,6 +235,40 @@ func (r *Reader) Networks(options ...NetworksOption) *Networks {
 	return networks
 }
 
+// NetworksV4 returns an iterator over the IPv4 networks in the database. In
+// an IPv6 database, it descends directly into the IPv4 subtree, ::/96, and
+// returns the networks found there as IPv4 networks. The SkipAliasedNetworks
+// option is always set. If the IPv4 subtree is part of a larger IPv6
+// network, e.g., because the database has no IPv4 search tree, there are no
+// IPv4 networks; the larger network is returned by NetworksV6.
+//
+// Together, NetworksV4 and NetworksV6 return exactly the networks returned by
+// Networks with the SkipAliasedNetworks option.
+func (r *Reader) NetworksV4(options ...NetworksOption) *Networks {
+	if r.Metadata.IPVersion == 6 && r.ipv4StartBitDepth < 96 {
+		return &Networks{reader: r}
+	}
+	options = append(options[:len(options):len(options)], SkipAliasedNetworks)
+	return r.NetworksWithin(allIPv4, options...)
+}
+
+// NetworksV6 returns an iterator over the IPv6 networks in the database,
+// i.e., all networks outside of the IPv4 subtree, ::/96, and its aliases. The
+// SkipAliasedNetworks option is always set. An IPv4-only database has no
+// IPv6 networks.
+//
+// Together, NetworksV4 and NetworksV6 return exactly the networks returned by
+// Networks with the SkipAliasedNetworks option.
+func (r *Reader) NetworksV6(options ...NetworksOption) *Networks {
+	if r.Metadata.IPVersion != 6 {
+		return &Networks{reader: r}
+	}
+	options = append(options[:len(options):len(options)], SkipAliasedNetworks)
+	networks := r.NetworksWithin(allIPv6, options...)
+	networks.skipIPv4Subtree = true
+	return networks
+}
+
 // NetworksWithin returns an iterator that can be used to traverse all networks
 // in the database which are contained in a given network.
 //
@@ -67,26 +279,66 @@ func (r *Reader) Networks(options ...NetworksOption) *Networks {
 //
 // If the provided network is contained within a network in the database, the
 // iterator will iterate over exactly one network, the containing network.
+//
+// NetworksWithinPrefix is the equivalent that takes a netip.Prefix.
 func (r *Reader) NetworksWithin(network *net.IPNet, options ...NetworksOption) *Networks {
-	if r.Metadata.IPVersion == 4 && network.IP.To4() == nil {
+	prefixLength, _ := network.Mask.Size()
+	return r.networksWithin(network.IP, prefixLength, options)
+}
+
+// NetworksWithinPrefix returns an iterator that can be used to traverse all
+// networks in the database which are contained in prefix. It behaves like
+// NetworksWithin. Any bits of the prefix's address beyond its length are
+// ignored, e.g., 81.2.69.142/24 is treated as 81.2.69.0/24. IPv4-mapped
+// IPv6 prefixes such as ::ffff:81.2.69.0/120 are treated like the IPv6
+// networks they are.
+func (r *Reader) NetworksWithinPrefix(prefix netip.Prefix, options ...NetworksOption) *Networks {
+	if !prefix.IsValid() {
 		return &Networks{
-			err: fmt.Errorf(
-				"error getting networks with '%s': you attempted to use an IPv6 network in an IPv4-only database",
-				network.String(),
-			),
+			err: fmt.Errorf("error getting networks with '%s': invalid prefix", prefix),
 		}
 	}
+	prefix = prefix.Masked()
+	if r.Metadata.IPVersion == 4 && !prefix.Addr().Is4() {
+		return &Networks{err: ipv6NetworkInIPv4DatabaseError(prefix.String())}
+	}
+	return r.networksWithin(prefix.Addr().AsSlice(), prefix.Bits(), options)
+}
+
+func (r *Reader) networksWithin(ip net.IP, prefixLength int, options []NetworksOption) *Networks {
+	if r.buffer == nil {
+		return &Networks{err: errors.New("cannot call Networks on a closed database")}
+	}
+	if r.Metadata.IPVersion == 4 && ip.To4() == nil {
+		network := &net.IPNet{IP: ip, Mask: net.CIDRMask(prefixLength, len(ip)*8)}
+		return &Networks{err: ipv6NetworkInIPv4DatabaseError(network.String())}
+	}
 
 	networks := &Networks{reader: r}
 	for _, option := range options {
 		option(networks)
 	}
 
-	ip := network.IP
-	prefixLength, _ := network.Mask.Size()
+	node := r.startNode(ip, prefixLength, networks.skipAliasedNetworks)
+	if networks.resumeFrom != nil && networks.resumeFrom.ip != nil {
+		networks.nodes, networks.resumeMismatch, networks.err = r.resumeNodes(
+			*networks.resumeFrom,
+			node.ip,
+			node.bit,
+			node.pointer,
+		)
+		return networks
+	}
+	networks.nodes = []netNode{node}
+
+	return networks
+}
 
+// startNode returns the node at which the iteration over the networks
+// within ip/prefixLength starts.
+func (r *Reader) startNode(ip net.IP, prefixLength int, skipAliasedNetworks bool) netNode {
 	if r.Metadata.IPVersion == 6 && len(ip) == net.IPv4len {
-		if networks.skipAliasedNetworks {
+		if skipAliasedNetworks {
 			ip = net.IP{0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, ip[0], ip[1], ip[2], ip[3]}
 		} else {
 			ip = ip.To16()
@@ -95,38 +347,247 @@ func (r *Reader) NetworksWithin(network *net.IPNet, options ...NetworksOption) *
 	}
 
 	pointer, bit := r.traverseTree(ip, 0, uint(prefixLength))
-	networks.nodes = []netNode{
-		{
-			ip:      ip,
-			bit:     uint(bit),
-			pointer: pointer,
-		},
+
+	// If the network is contained in a network in the database, the tree
+	// traversal stops before prefixLength and we need to mask off the
+	// remaining bits to get the containing network.
+	ip = ip.Mask(net.CIDRMask(bit, len(ip)*8))
+
+	return netNode{
+		ip:      ip,
+		bit:     uint(bit),
+		pointer: pointer,
+		parent:  r.parentNode(ip, bit),
+	}
+}
+
+// NetworksInRange returns an iterator over the networks in the database
+// that overlap the range of addresses from first to last, inclusive, e.g.,
+// 81.2.69.100 to 81.2.70.30. The networks are returned in order and each
+// only once. A network that is only partially within the range is returned
+// whole, e.g., a network 81.2.69.96/27 in the database for the range above,
+// as with a network given to NetworksWithin that is contained in a network
+// in the database.
+//
+// first and last must both be IPv4 or both be IPv6 addresses, with
+// IPv4-mapped IPv6 addresses being IPv6 addresses. The ResumeFrom option is
+// not supported.
+func (r *Reader) NetworksInRange(first, last netip.Addr, options ...NetworksOption) *Networks {
+	if !first.IsValid() || !last.IsValid() {
+		return &Networks{
+			err: fmt.Errorf("error getting networks in range %s-%s: invalid address", first, last),
+		}
+	}
+	first = first.WithZone("")
+	last = last.WithZone("")
+	if first.Is4() != last.Is4() {
+		return &Networks{
+			err: fmt.Errorf(
+				"error getting networks in range %s-%s: the addresses are of different families",
+				first,
+				last,
+			),
+		}
+	}
+	if last.Less(first) {
+		return &Networks{
+			err: fmt.Errorf("error getting networks in range %s-%s: the range is empty", first, last),
+		}
+	}
+	if r.buffer == nil {
+		return &Networks{err: errors.New("cannot call Networks on a closed database")}
+	}
+	if r.Metadata.IPVersion == 4 && !first.Is4() {
+		return &Networks{err: ipv6NetworkInIPv4DatabaseError(fmt.Sprintf("%s-%s", first, last))}
+	}
+
+	networks := &Networks{reader: r}
+	for _, option := range options {
+		option(networks)
+	}
+	if networks.resumeFrom != nil && networks.resumeFrom.ip != nil {
+		networks.err = errors.New("the ResumeFrom option is not supported by NetworksInRange")
+		return networks
+	}
+
+	// The networks within each prefix covering the range are visited in
+	// order, so the nodes are pushed in reverse. Adjacent prefixes within
+	// the same network in the database start at the same node, which is
+	// only pushed once.
+	prefixes := rangePrefixes(first, last)
+	for i := len(prefixes) - 1; i >= 0; i-- {
+		prefix := prefixes[i]
+		node := r.startNode(prefix.Addr().AsSlice(), prefix.Bits(), networks.skipAliasedNetworks)
+		if len(networks.nodes) > 0 {
+			previous := networks.nodes[len(networks.nodes)-1]
+			if previous.bit == node.bit && previous.ip.Equal(node.ip) {
+				continue
+			}
+		}
+		networks.nodes = append(networks.nodes, node)
 	}
 
 	return networks
 }
 
+// rangePrefixes returns the smallest set of prefixes covering the addresses
+// from first to last, inclusive, in order.
+func rangePrefixes(first, last netip.Addr) []netip.Prefix {
+	var prefixes []netip.Prefix
+	for {
+		// The prefix is the largest one starting at first and ending at or
+		// before last.
+		bits := first.BitLen()
+		for bits > 0 {
+			prefix := netip.PrefixFrom(first, bits-1)
+			if prefix.Masked().Addr() != first || last.Less(prefixLast(prefix)) {
+				break
+			}
+			bits--
+		}
+		prefix := netip.PrefixFrom(first, bits)
+		prefixes = append(prefixes, prefix)
+
+		end := prefixLast(prefix)
+		if !end.Less(last) {
+			return prefixes
+		}
+		first = end.Next()
+	}
+}
+
+// prefixLast returns the last address of prefix, whose bits beyond its
+// length must be zero.
+func prefixLast(prefix netip.Prefix) netip.Addr {
+	bytes := prefix.Addr().AsSlice()
+	for i := prefix.Bits(); i < len(bytes)*8; i++ {
+		bytes[i/8] |= 1 << (7 - i%8)
+	}
+	addr, _ := netip.AddrFromSlice(bytes)
+	return addr
+}
+
+func ipv6NetworkInIPv4DatabaseError(network string) error {
+	return fmt.Errorf(
+		"error getting networks with '%s': you attempted to use an IPv6 network in an IPv4-only database",
+		network,
+	)
+}
+
+// resumeNodes rebuilds the stack of nodes that remain to be visited after
+// the network captured by cursor. Starting at the node for the network being
+// iterated over, it follows the bits of the cursor down the tree and, for
+// each left branch taken, adds the right sibling to the stack.
+//
+// If the cursor does not end at a data record, e.g., because it was produced
+// from a different build of the database, the networks overlapping the
+// cursor are skipped and the mismatch is reported through the returned bool.
+func (r *Reader) resumeNodes(
+	cursor Cursor,
+	ip net.IP,
+	bit,
+	pointer uint,
+) ([]netNode, bool, error) {
+	cursorIP := cursor.ip
+	cursorBit := cursor.bit
+	if r.Metadata.IPVersion == 6 && len(cursorIP) == net.IPv4len {
+		cursorIP = append(make(net.IP, 12, net.IPv6len), cursorIP...)
+		cursorBit += 96
+	}
+	if len(cursorIP) != len(ip) || !prefixEqual(cursorIP, ip, min(bit, cursorBit)) {
+		return nil, false, fmt.Errorf(
+			"error resuming networks: cursor %v/%v is not within the network being iterated over",
+			cursor.ip,
+			cursor.bit,
+		)
+	}
+
+	var nodes []netNode
+	node := pointer
+	for i := bit; i < cursorBit; i++ {
+		if node >= r.Metadata.NodeCount {
+			// The cursor is within a record or an empty part of the tree.
+			return nodes, true, nil
+		}
+		offset := node * r.nodeOffsetMult
+		if cursorIP[i>>3]&(1<<(7-(i%8))) != 0 {
+			node = r.nodeReader.readRight(offset)
+			continue
+		}
+
+		ipRight := make(net.IP, len(cursorIP))
+		copy(ipRight, cursorIP)
+		clearBitsFrom(ipRight, i)
+		ipRight[i>>3] |= 1 << (7 - (i % 8))
+		nodes = append(nodes, netNode{
+			ip:      ipRight,
+			bit:     i + 1,
+			pointer: r.nodeReader.readRight(offset),
+			parent:  node,
+		})
+		node = r.nodeReader.readLeft(offset)
+	}
+	return nodes, node <= r.Metadata.NodeCount, nil
+}
+
 // Next prepares the next network for reading with the Network method. It
 // returns true if there is another network to be processed and false if there
 // are no more networks or if there is an error.
+//
+// Once the Reader has been closed, Next returns false and Err returns an
+// error. The Reader may be closed concurrently with Next.
 func (n *Networks) Next() bool {
 	if n.err != nil {
 		return false
 	}
+	n.reader.closeMu.RLock()
+	defer n.reader.closeMu.RUnlock()
+	if n.reader.buffer == nil {
+		n.err = errors.New("cannot call Next on a closed database")
+		return false
+	}
+	n.ipv4Projection = n.pendingIPv4Projection
+	n.pendingIPv4Projection = false
+	if n.ipv4Projection {
+		return true
+	}
 	for len(n.nodes) > 0 {
 		node := n.nodes[len(n.nodes)-1]
 		n.nodes = n.nodes[:len(n.nodes)-1]
 
 		for node.pointer != n.reader.Metadata.NodeCount {
 			// This skips IPv4 aliases without hardcoding the networks that the writer
-			// currently aliases.
-			if n.skipAliasedNetworks && n.reader.ipv4Start != 0 &&
+			// currently aliases. If the database has no IPv4 subtree, ipv4Start
+			// is the record for a network containing ::/96 and other networks
+			// sharing that record are not aliases.
+			if n.skipAliasedNetworks && n.reader.ipv4StartBitDepth == 96 &&
 				node.pointer == n.reader.ipv4Start && !isInIPv4Subtree(node.ip) {
 				break
 			}
+			if n.skipIPv4Subtree && node.bit >= 96 && isInIPv4Subtree(node.ip) {
+				break
+			}
 
 			if node.pointer > n.reader.Metadata.NodeCount {
+				if n.collectErrors {
+					skip, err := n.checkRecord(node)
+					if err != nil {
+						n.err = err
+						return false
+					}
+					if skip {
+						break
+					}
+				}
 				n.lastNode = node
+				if n.target != nil {
+					if err := n.decodeTarget(); err != nil {
+						n.err = err
+						return false
+					}
+				}
+				n.pendingIPv4Projection = n.projectIPv4 && node.bit < 96 &&
+					isInIPv4Subtree(node.ip)
 				return true
 			}
 			ipRight := make(net.IP, len(node.ip))
@@ -146,8 +607,10 @@ func (n *Networks) Next() bool {
 				pointer: rightPointer,
 				ip:      ipRight,
 				bit:     node.bit,
+				parent:  node.pointer,
 			})
 
+			node.parent = node.pointer
 			node.pointer = n.reader.nodeReader.readLeft(offset)
 		}
 	}
@@ -157,23 +620,58 @@ func (n *Networks) Next() bool {
 
 // Network returns the current network or an error if there is a problem
 // decoding the data for the network. It takes a pointer to a result value to
-// decode the network's data into.
+// decode the network's data into. If result is nil, the record is not
+// decoded. With the DecodeInto option, the record has already been decoded
+// by Next and result must be nil or the bound result.
+//
+// Once the Reader has been closed, Network returns an error, which is also
+// returned by Err. The Reader may be closed concurrently with Network.
 func (n *Networks) Network(result any) (*net.IPNet, error) {
 	if n.err != nil {
 		return nil, n.err
 	}
-	if err := n.reader.retrieveData(n.lastNode.pointer, result); err != nil {
-		return nil, err
+	n.reader.closeMu.RLock()
+	defer n.reader.closeMu.RUnlock()
+	if n.reader.buffer == nil {
+		n.err = errors.New("cannot call Network on a closed database")
+		return nil, n.err
+	}
+	switch {
+	case result == nil:
+	case n.target != nil:
+		if !samePointer(result, n.target) {
+			return nil, errors.New("the result passed to Network is not the one bound with DecodeInto")
+		}
+	default:
+		if err := n.retrieveData(result); err != nil {
+			return nil, err
+		}
 	}
 
-	ip := n.lastNode.ip
-	prefixLength := int(n.lastNode.bit)
+	if n.ipv4Projection {
+		return &net.IPNet{
+			IP:   make(net.IP, net.IPv4len),
+			Mask: net.CIDRMask(0, 8*net.IPv4len),
+		}, nil
+	}
+	return n.ipNet(n.lastNode), nil
+}
+
+// ipNet returns the network of node.
+func (n *Networks) ipNet(node netNode) *net.IPNet {
+	ip := node.ip
+	prefixLength := int(node.bit)
 
 	// We do this because uses of SkipAliasedNetworks expect the IPv4 networks
 	// to be returned as IPv4 networks. If we are not skipping aliased
 	// networks, then the user will get IPv4 networks from the ::FFFF:0:0/96
-	// network as Go automatically converts those.
-	if n.skipAliasedNetworks && isInIPv4Subtree(ip) {
+	// network as Go automatically converts those. Networks shorter than /96
+	// contain more than the IPv4 subtree and are returned as IPv6 networks.
+	if n.skipAliasedNetworks && prefixLength >= 96 && isInIPv4Subtree(ip) {
+		ip = ip[12:]
+		prefixLength -= 96
+	}
+	if n.unmapIPv4Networks && prefixLength >= 96 && isIPv4Mapped(ip) {
 		ip = ip[12:]
 		prefixLength -= 96
 	}
@@ -181,12 +679,137 @@ func (n *Networks) Network(result any) (*net.IPNet, error) {
 	return &net.IPNet{
 		IP:   ip,
 		Mask: net.CIDRMask(prefixLength, len(ip)*8),
-	}, nil
+	}
+}
+
+// checkRecord decodes the record of node for the CollectErrors option. It
+// returns true if the record cannot be decoded, after adding the network to
+// the record errors. The error is returned only if the search tree itself
+// is invalid.
+func (n *Networks) checkRecord(node netNode) (bool, error) {
+	offset, err := n.reader.resolveDataPointer(node.parent, node.pointer)
+	if err != nil {
+		return false, err
+	}
+	recordErr, ok := n.checkedRecords[offset]
+	if !ok {
+		var record any
+		recordErr = n.reader.decode(offset, &record)
+		n.checkedRecords[offset] = recordErr
+	}
+	if recordErr == nil {
+		return false, nil
+	}
+	n.recordErrors = append(n.recordErrors, NetworkError{
+		Network: n.ipNet(node),
+		Err:     recordErr,
+	})
+	return true, nil
 }
 
-// Err returns an error, if any, that was encountered during iteration.
+// retrieveData decodes the record of the current network into result,
+// using the record cache if there is one.
+func (n *Networks) retrieveData(result any) error {
+	if n.recordCache == nil {
+		return n.reader.retrieveData(n.lastNode.parent, n.lastNode.pointer, result)
+	}
+	rv := reflect.ValueOf(result)
+	if _, ok := result.(deserializer); ok || rv.Kind() != reflect.Ptr || rv.IsNil() {
+		return n.reader.retrieveData(n.lastNode.parent, n.lastNode.pointer, result)
+	}
+
+	offset, err := n.reader.resolveDataPointer(n.lastNode.parent, n.lastNode.pointer)
+	if err != nil {
+		return err
+	}
+	key := recordCacheKey{offset: offset, typ: rv.Type()}
+	if cached, ok := n.recordCache.get(key); ok {
+		if !n.shareCachedRecords {
+			cached = deepCopy(cached)
+		}
+		rv.Elem().Set(cached)
+		return nil
+	}
+
+	// The result is zeroed so that the cached value does not depend on what
+	// the result held before.
+	rv.Elem().Set(reflect.Zero(rv.Type().Elem()))
+	if err := n.reader.decode(offset, result); err != nil {
+		return err
+	}
+	// The cached value must not refer to the result's own memory, which the
+	// caller may reuse.
+	cached := reflect.New(rv.Type().Elem()).Elem()
+	if n.shareCachedRecords {
+		cached.Set(rv.Elem())
+	} else {
+		cached.Set(deepCopy(rv.Elem()))
+	}
+	n.recordCache.add(key, cached)
+	return nil
+}
+
+// decodeTarget decodes the record of the current network into the result
+// bound with DecodeInto.
+func (n *Networks) decodeTarget() error {
+	rv := reflect.ValueOf(n.target)
+	if _, ok := n.target.(deserializer); !ok && rv.Kind() == reflect.Ptr && !rv.IsNil() {
+		reflectSetZero(rv.Elem())
+	}
+	return n.retrieveData(n.target)
+}
+
+// samePointer returns true if a and b are the same pointer.
+func samePointer(a, b any) bool {
+	va, vb := reflect.ValueOf(a), reflect.ValueOf(b)
+	return va.Kind() == reflect.Ptr && vb.Kind() == reflect.Ptr &&
+		va.Type() == vb.Type() && va.Pointer() == vb.Pointer()
+}
+
+// Cursor returns a Cursor capturing the position of the network most
+// recently prepared by Next. Passing it to the ResumeFrom option continues
+// the iteration with the following network. The cursor for an IPv4 network
+// yielded because of ProjectIPv4Networks is that of the IPv6 network
+// containing it.
+func (n *Networks) Cursor() Cursor {
+	if n.lastNode.ip == nil {
+		return Cursor{}
+	}
+	ip := make(net.IP, len(n.lastNode.ip))
+	copy(ip, n.lastNode.ip)
+	return Cursor{ip: ip, bit: n.lastNode.bit}
+}
+
+// ResumeMismatch returns true if the iterator was created with the
+// ResumeFrom option and the cursor did not correspond to a network in the
+// database, e.g., because it was produced from a different build of the
+// database. The iteration then resumed at the nearest network following the
+// cursor's network.
+func (n *Networks) ResumeMismatch() bool {
+	return n.resumeMismatch
+}
+
+// RecordErrors returns the networks skipped so far because of the
+// CollectErrors option, in the order they were encountered.
+func (n *Networks) RecordErrors() []NetworkError {
+	return n.recordErrors
+}
+
+// Err returns an error, if any, that was encountered during iteration. With
+// the CollectErrors option, the error also includes the errors of the
+// skipped networks, each as a NetworkError.
 func (n *Networks) Err() error {
-	return n.err
+	if len(n.recordErrors) == 0 {
+		return n.err
+	}
+	errs := make([]error, 0, len(n.recordErrors)+1)
+	if n.err != nil {
+		errs = append(errs, n.err)
+	}
+	for _, err := range n.recordErrors {
+		errs = append(errs, err)
+	}
+	return errors.Join(errs...)
 }
 
 // isInIPv4Subtree returns true if the IP is an IPv6 address in the database's
@@ -202,3 +825,37 @@ func isInIPv4Subtree(ip net.IP) bool {
 	}
 	return true
 }
+
+// isIPv4Mapped returns true if ip is in the IPv4-mapped range,
+// ::ffff:0:0/96.
+func isIPv4Mapped(ip net.IP) bool {
+	if len(ip) != 16 {
+		return false
+	}
+	for i := 0; i < 10; i++ {
+		if ip[i] != 0 {
+			return false
+		}
+	}
+	return ip[10] == 0xff && ip[11] == 0xff
+}
+
+// prefixEqual returns true if the first bits bits of a and b are equal.
+func prefixEqual(a, b net.IP, bits uint) bool {
+	n := bits >> 3
+	if string(a[:n]) != string(b[:n]) {
+		return false
+	}
+	if rem := bits % 8; rem != 0 {
+		mask := byte(0xff) << (8 - rem)
+		return a[n]&mask == b[n]&mask
+	}
+	return true
+}
+
+// clearBitsFrom sets all bits of ip starting at bit to zero.
+func clearBitsFrom(ip net.IP, bit uint) {
+	for i := bit; i < uint(len(ip)*8); i++ {
+		ip[i>>3] &^= 1 << (7 - (i % 8))
+	}
+}
@@ -2,9 +2,13 @@ package maxminddb
 
 import (
 	"fmt"
+	"math/big"
+	"math/rand"
 	"net"
+	"net/netip"
 	"testing"
 
+	"github.com/3JoB/go-reflect"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -51,6 +55,52 @@ func TestNetworksWithInvalidSearchTree(t *testing.T) {
 	assert.NoError(t, reader.Close())
 }
 
+func TestNetworksCollectErrors(t *testing.T) {
+	reader, err := Open(testFile("GeoIP2-City-Test-Broken-Double-Format.mmdb"))
+	require.NoError(t, err)
+
+	n := reader.Networks(SkipAliasedNetworks, CollectErrors)
+	count := 0
+	for n.Next() {
+		var record any
+		_, err := n.Network(&record)
+		require.NoError(t, err)
+		count++
+	}
+	assert.NotZero(t, count)
+
+	recordErrors := n.RecordErrors()
+	require.NotEmpty(t, recordErrors)
+	for _, recordErr := range recordErrors {
+		assert.EqualError(
+			t,
+			recordErr.Err,
+			"the MaxMind DB file's data section contains bad data (float 64 size of 2)",
+		)
+	}
+
+	err = n.Err()
+	require.Error(t, err)
+	var networkErr NetworkError
+	require.ErrorAs(t, err, &networkErr)
+	assert.Equal(t, recordErrors[0], networkErr)
+
+	assert.NoError(t, reader.Close())
+}
+
+func TestNetworksCollectErrorsInvalidSearchTree(t *testing.T) {
+	reader, err := Open(testFile("MaxMind-DB-test-broken-search-tree-24.mmdb"))
+	require.NoError(t, err)
+
+	n := reader.Networks(CollectErrors)
+	for n.Next() {
+	}
+	assert.EqualError(t, n.Err(), "invalid search tree at 128.128.128.128/32")
+	assert.Empty(t, n.RecordErrors())
+
+	assert.NoError(t, reader.Close())
+}
+
 type networkTest struct {
 	Network  string
 	Database string
@@ -261,6 +311,76 @@ func TestNetworksWithin(t *testing.T) {
 	}
 }
 
+func TestNetworksWithinPrefix(t *testing.T) {
+	for _, v := range tests {
+		for _, recordSize := range []uint{24, 28, 32} {
+			fileName := testFile(fmt.Sprintf("MaxMind-DB-test-%s-%d.mmdb", v.Database, recordSize))
+			reader, err := Open(fileName)
+			require.Nil(t, err, "unexpected error while opening database: %v", err)
+
+			prefix, err := netip.ParsePrefix(v.Network)
+			require.NoError(t, err)
+			n := reader.NetworksWithinPrefix(prefix, v.Options...)
+			var innerIPs []string
+
+			for n.Next() {
+				var record any
+				network, err := n.Network(&record)
+				assert.Nil(t, err)
+				innerIPs = append(innerIPs, network.String())
+			}
+
+			assert.Equal(t, v.Expected, innerIPs)
+			assert.Nil(t, n.Err())
+
+			assert.NoError(t, reader.Close())
+		}
+	}
+}
+
+func TestNetworksWithinUnmaskedPrefix(t *testing.T) {
+	reader, err := Open(testFile("GeoIP2-Country-Test.mmdb"))
+	require.NoError(t, err)
+
+	networks := func(prefix string) []string {
+		n := reader.NetworksWithinPrefix(netip.MustParsePrefix(prefix))
+		var networks []string
+		for n.Next() {
+			var record any
+			network, err := n.Network(&record)
+			require.NoError(t, err)
+			networks = append(networks, network.String())
+		}
+		require.NoError(t, n.Err())
+		return networks
+	}
+
+	expected := networks("81.2.69.0/24")
+	assert.NotEmpty(t, expected)
+	assert.Equal(t, expected, networks("81.2.69.142/24"))
+
+	n := reader.NetworksWithinPrefix(netip.Prefix{})
+	assert.False(t, n.Next())
+	assert.Error(t, n.Err())
+
+	assert.NoError(t, reader.Close())
+}
+
+func TestNetworksWithinPrefixIPv6InIPv4Database(t *testing.T) {
+	reader, err := Open(testFile("MaxMind-DB-test-ipv4-24.mmdb"))
+	require.NoError(t, err)
+
+	n := reader.NetworksWithinPrefix(netip.MustParsePrefix("::ffff:1.1.1.0/120"))
+	assert.False(t, n.Next())
+	assert.EqualError(
+		t,
+		n.Err(),
+		"error getting networks with '::ffff:1.1.1.0/120': you attempted to use an IPv6 network in an IPv4-only database",
+	)
+
+	assert.NoError(t, reader.Close())
+}
+
 var geoipTests = []networkTest{
 	{
 		Network:  "81.2.69.128/26",
@@ -299,3 +419,859 @@ func TestGeoIPNetworksWithin(t *testing.T) {
 		assert.NoError(t, reader.Close())
 	}
 }
+
+func TestNetworksWithoutIPv4SearchTree(t *testing.T) {
+	tests := []struct {
+		Network  string
+		Expected []string
+		Options  []NetworksOption
+	}{
+		{
+			Network:  "::/0",
+			Expected: []string{"::/64"},
+		},
+		{
+			Network:  "::/0",
+			Expected: []string{"::/64"},
+			Options:  []NetworksOption{SkipAliasedNetworks},
+		},
+		{
+			Network:  "::/0",
+			Expected: []string{"::/64", "0.0.0.0/0"},
+			Options:  []NetworksOption{ProjectIPv4Networks},
+		},
+		{
+			Network:  "::/0",
+			Expected: []string{"::/64", "0.0.0.0/0"},
+			Options:  []NetworksOption{SkipAliasedNetworks, ProjectIPv4Networks},
+		},
+		{
+			Network:  "200.0.2.0/24",
+			Expected: []string{"::/64"},
+		},
+		{
+			Network:  "200.0.2.0/24",
+			Expected: []string{"::/64"},
+			Options:  []NetworksOption{SkipAliasedNetworks},
+		},
+		{
+			Network:  "200.0.2.0/24",
+			Expected: []string{"::/64", "0.0.0.0/0"},
+			Options:  []NetworksOption{SkipAliasedNetworks, ProjectIPv4Networks},
+		},
+		{
+			Network:  "8000::/1",
+			Expected: []string(nil),
+			Options:  []NetworksOption{ProjectIPv4Networks},
+		},
+	}
+
+	reader, err := Open(testFile("MaxMind-DB-no-ipv4-search-tree.mmdb"))
+	require.NoError(t, err)
+
+	for _, test := range tests {
+		t.Run(fmt.Sprintf("%s %d", test.Network, len(test.Options)), func(t *testing.T) {
+			_, network, err := net.ParseCIDR(test.Network)
+			require.NoError(t, err)
+
+			n := reader.NetworksWithin(network, test.Options...)
+			var networks []string
+			for n.Next() {
+				var record any
+				network, err := n.Network(&record)
+				require.NoError(t, err)
+				networks = append(networks, network.String())
+
+				// Lookups of the first address in the network should agree
+				// with the iteration.
+				var lookupRecord any
+				lookupNetwork, ok, err := reader.LookupNetwork(network.IP, &lookupRecord)
+				require.NoError(t, err)
+				assert.True(t, ok)
+				assert.Equal(t, record, lookupRecord)
+				assert.Equal(t, "::/64", lookupNetwork.String())
+			}
+			require.NoError(t, n.Err())
+			assert.Equal(t, test.Expected, networks)
+		})
+	}
+
+	assert.NoError(t, reader.Close())
+}
+
+func TestNetworksResumeFrom(t *testing.T) {
+	for _, database := range []string{
+		"GeoIP2-City-Test.mmdb",
+		"MaxMind-DB-test-ipv4-24.mmdb",
+		"MaxMind-DB-test-ipv6-28.mmdb",
+		"MaxMind-DB-test-mixed-32.mmdb",
+	} {
+		t.Run(database, func(t *testing.T) {
+			reader, err := Open(testFile(database))
+			require.NoError(t, err)
+
+			var expected []string
+			n := reader.Networks(SkipAliasedNetworks)
+			for n.Next() {
+				var record any
+				network, err := n.Network(&record)
+				require.NoError(t, err)
+				expected = append(expected, network.String())
+			}
+			require.NoError(t, n.Err())
+
+			r := rand.New(rand.NewSource(0))
+			for i := 0; i < 10; i++ {
+				var (
+					actual []string
+					cursor Cursor
+				)
+				for done := false; !done; {
+					n := reader.Networks(SkipAliasedNetworks, ResumeFrom(cursor))
+					stopAfter := r.Intn(len(expected)/4 + 1)
+					done = true
+					for n.Next() {
+						var record any
+						network, err := n.Network(&record)
+						require.NoError(t, err)
+						actual = append(actual, network.String())
+						if stopAfter == 0 {
+							cursor = n.Cursor()
+							done = false
+							break
+						}
+						stopAfter--
+					}
+					require.NoError(t, n.Err())
+				}
+				assert.Equal(t, expected, actual)
+			}
+
+			assert.NoError(t, reader.Close())
+		})
+	}
+}
+
+func TestCursorText(t *testing.T) {
+	reader, err := Open(testFile("MaxMind-DB-test-mixed-24.mmdb"))
+	require.NoError(t, err)
+
+	n := reader.Networks(SkipAliasedNetworks)
+	require.True(t, n.Next())
+
+	text, err := n.Cursor().MarshalText()
+	require.NoError(t, err)
+	assert.Equal(t, "v1:::101:101/128", string(text))
+
+	var cursor Cursor
+	require.NoError(t, cursor.UnmarshalText(text))
+	assert.Equal(t, n.Cursor(), cursor)
+
+	text, err = Cursor{}.MarshalText()
+	require.NoError(t, err)
+	assert.Equal(t, "", string(text))
+	require.NoError(t, cursor.UnmarshalText(text))
+	assert.Equal(t, Cursor{}, cursor)
+
+	assert.Error(t, cursor.UnmarshalText([]byte("v0:1.1.1.1/32")))
+	assert.Error(t, cursor.UnmarshalText([]byte("v1:1.1.1.1")))
+
+	assert.NoError(t, reader.Close())
+}
+
+func TestNetworksResumeAcrossBuilds(t *testing.T) {
+	tests := []struct {
+		Cursor   string
+		Database string
+		Expected []string
+		Mismatch bool
+	}{
+		{
+			// A cursor from the IPv4 database applied to the mixed one.
+			Cursor:   "v1:1.1.1.4/30",
+			Database: "MaxMind-DB-test-mixed-24.mmdb",
+			Expected: []string{
+				"1.1.1.8/29",
+				"1.1.1.16/28",
+				"1.1.1.32/32",
+				"::1:ffff:ffff/128",
+				"::2:0:0/122",
+				"::2:0:40/124",
+				"::2:0:50/125",
+				"::2:0:58/127",
+			},
+		},
+		{
+			// A cursor from the mixed database whose network does not exist
+			// in the IPv6 one.
+			Cursor:   "v1:::1.1.1.32/128",
+			Database: "MaxMind-DB-test-ipv6-24.mmdb",
+			Expected: []string{
+				"::1:ffff:ffff/128",
+				"::2:0:0/122",
+				"::2:0:40/124",
+				"::2:0:50/125",
+				"::2:0:58/127",
+			},
+			Mismatch: true,
+		},
+		{
+			// A cursor for a network that has since been split.
+			Cursor:   "v1:1.1.1.0/28",
+			Database: "MaxMind-DB-test-ipv4-24.mmdb",
+			Expected: []string{
+				"1.1.1.16/28",
+				"1.1.1.32/32",
+			},
+			Mismatch: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Cursor+" "+test.Database, func(t *testing.T) {
+			reader, err := Open(testFile(test.Database))
+			require.NoError(t, err)
+
+			var cursor Cursor
+			require.NoError(t, cursor.UnmarshalText([]byte(test.Cursor)))
+
+			n := reader.Networks(SkipAliasedNetworks, ResumeFrom(cursor))
+			var networks []string
+			for n.Next() {
+				var record any
+				network, err := n.Network(&record)
+				require.NoError(t, err)
+				networks = append(networks, network.String())
+			}
+			require.NoError(t, n.Err())
+
+			assert.Equal(t, test.Expected, networks)
+			assert.Equal(t, test.Mismatch, n.ResumeMismatch())
+
+			assert.NoError(t, reader.Close())
+		})
+	}
+}
+
+func TestNetworksCacheDecodedRecords(t *testing.T) {
+	reader, err := Open(testFile("GeoIP2-City-Test.mmdb"))
+	require.NoError(t, err)
+
+	var expected []fullCity
+	n := reader.Networks(SkipAliasedNetworks)
+	for n.Next() {
+		var record fullCity
+		_, err := n.Network(&record)
+		require.NoError(t, err)
+		expected = append(expected, record)
+	}
+	require.NoError(t, n.Err())
+
+	for _, options := range [][]NetworksOption{
+		{SkipAliasedNetworks, CacheDecodedRecords(16)},
+		{SkipAliasedNetworks, CacheDecodedRecords(1), ShareCachedRecords},
+	} {
+		// The result is reused to check that fields are not carried over
+		// from previous records.
+		var record fullCity
+		var i int
+		n := reader.Networks(options...)
+		for n.Next() {
+			_, err := n.Network(&record)
+			require.NoError(t, err)
+			require.Less(t, i, len(expected))
+			assert.Equal(t, expected[i], record)
+			i++
+		}
+		require.NoError(t, n.Err())
+		assert.Equal(t, len(expected), i)
+	}
+
+	assert.NoError(t, reader.Close())
+}
+
+func TestNetworksDecodeInto(t *testing.T) {
+	reader, err := Open(testFile("GeoIP2-City-Test.mmdb"))
+	require.NoError(t, err)
+
+	var expected []fullCity
+	var expectedNetworks []string
+	n := reader.Networks(SkipAliasedNetworks)
+	for n.Next() {
+		var record fullCity
+		network, err := n.Network(&record)
+		require.NoError(t, err)
+		expected = append(expected, record)
+		expectedNetworks = append(expectedNetworks, network.String())
+	}
+	require.NoError(t, n.Err())
+
+	for _, options := range [][]NetworksOption{
+		{SkipAliasedNetworks},
+		{SkipAliasedNetworks, CacheDecodedRecords(16)},
+	} {
+		// The bound result is reused for every network, so fields must not
+		// be carried over from previous records.
+		var record fullCity
+		var i int
+		n := reader.Networks(append(options, DecodeInto(&record))...)
+		for n.Next() {
+			require.Less(t, i, len(expected))
+			assert.Equal(t, expected[i], record)
+
+			network, err := n.Network(nil)
+			require.NoError(t, err)
+			assert.Equal(t, expectedNetworks[i], network.String())
+			network, err = n.Network(&record)
+			require.NoError(t, err)
+			assert.Equal(t, expectedNetworks[i], network.String())
+
+			var other fullCity
+			_, err = n.Network(&other)
+			assert.EqualError(t, err, "the result passed to Network is not the one bound with DecodeInto")
+			i++
+		}
+		require.NoError(t, n.Err())
+		assert.Equal(t, len(expected), i)
+	}
+
+	// Without a bound result, Network(nil) returns the networks without
+	// decoding.
+	var networks []string
+	n = reader.Networks(SkipAliasedNetworks)
+	for n.Next() {
+		network, err := n.Network(nil)
+		require.NoError(t, err)
+		networks = append(networks, network.String())
+	}
+	require.NoError(t, n.Err())
+	assert.Equal(t, expectedNetworks, networks)
+
+	assert.NoError(t, reader.Close())
+}
+
+func TestNetworksDecodeIntoError(t *testing.T) {
+	reader, err := Open(testFile("MaxMind-DB-test-ipv4-24.mmdb"))
+	require.NoError(t, err)
+
+	// Every record is {"ip": "<string>"}.
+	var record struct {
+		IP int `maxminddb:"ip"`
+	}
+	n := reader.Networks(DecodeInto(&record))
+	var count int
+	for n.Next() {
+		count++
+	}
+	assert.Zero(t, count)
+	var typeErr UnmarshalTypeError
+	assert.ErrorAs(t, n.Err(), &typeErr)
+
+	assert.NoError(t, reader.Close())
+}
+
+func TestNetworksUnmapIPv4Networks(t *testing.T) {
+	reader, err := Open(testFile("MaxMind-DB-test-decoder.mmdb"))
+	require.NoError(t, err)
+
+	networks := func(options ...NetworksOption) []*net.IPNet {
+		_, network, err := net.ParseCIDR("1.1.1.0/24")
+		require.NoError(t, err)
+		n := reader.NetworksWithin(network, options...)
+		var networks []*net.IPNet
+		for n.Next() {
+			var record any
+			network, err := n.Network(&record)
+			require.NoError(t, err)
+			networks = append(networks, network)
+		}
+		require.NoError(t, n.Err())
+		return networks
+	}
+
+	mapped := networks()
+	require.Len(t, mapped, 1)
+	assert.Equal(t, "1.1.1.0/24", mapped[0].String())
+	assert.Len(t, mapped[0].IP, net.IPv6len)
+	assert.Equal(t, "::ffff:1.1.1.0/120", ipNetToPrefix(t, mapped[0]).String())
+
+	unmapped := networks(UnmapIPv4Networks)
+	require.Len(t, unmapped, 1)
+	assert.Equal(t, "1.1.1.0/24", unmapped[0].String())
+	assert.Len(t, unmapped[0].IP, net.IPv4len)
+	assert.Len(t, unmapped[0].Mask, net.IPv4len)
+	assert.Equal(t, "1.1.1.0/24", ipNetToPrefix(t, unmapped[0]).String())
+
+	// Networks outside of the IPv4-mapped range are not changed.
+	var prefixes []string
+	n := reader.Networks(UnmapIPv4Networks)
+	for n.Next() {
+		var record any
+		network, err := n.Network(&record)
+		require.NoError(t, err)
+		prefixes = append(prefixes, ipNetToPrefix(t, network).String())
+	}
+	require.NoError(t, n.Err())
+	assert.Contains(t, prefixes, "::101:100/120")
+	assert.Contains(t, prefixes, "1.1.1.0/24")
+	assert.NotContains(t, prefixes, "::ffff:1.1.1.0/120")
+
+	assert.NoError(t, reader.Close())
+}
+
+func ipNetToPrefix(t *testing.T, network *net.IPNet) netip.Prefix {
+	t.Helper()
+	addr, ok := netip.AddrFromSlice(network.IP)
+	require.True(t, ok)
+	bits, _ := network.Mask.Size()
+	return netip.PrefixFrom(addr, bits)
+}
+
+func TestNetworksCacheDecodedRecordsCopies(t *testing.T) {
+	reader, err := Open(testFile("MaxMind-DB-test-decoder.mmdb"))
+	require.NoError(t, err)
+
+	for _, shared := range []bool{false, true} {
+		options := []NetworksOption{CacheDecodedRecords(16)}
+		if shared {
+			options = append(options, ShareCachedRecords)
+		}
+		n := reader.Networks(options...)
+		var first map[string]any
+		for n.Next() {
+			var record map[string]any
+			_, err := n.Network(&record)
+			require.NoError(t, err)
+			if _, ok := record["array"]; !ok {
+				continue
+			}
+			if first == nil {
+				first = record
+				first["array"].([]any)[0] = "modified"
+				continue
+			}
+			if shared {
+				assert.Equal(t, "modified", record["array"].([]any)[0])
+			} else {
+				assert.Equal(t, uint64(1), record["array"].([]any)[0])
+			}
+		}
+		require.NoError(t, n.Err())
+	}
+
+	assert.NoError(t, reader.Close())
+}
+
+func TestRecordCache(t *testing.T) {
+	c := newRecordCache(2)
+	key := func(offset uintptr) recordCacheKey {
+		return recordCacheKey{offset: offset, typ: reflect.TypeOf(0)}
+	}
+	c.add(key(1), reflect.ValueOf(1))
+	c.add(key(2), reflect.ValueOf(2))
+	_, ok := c.get(key(1))
+	assert.True(t, ok)
+
+	// 2 is now the least recently used entry.
+	c.add(key(3), reflect.ValueOf(3))
+	_, ok = c.get(key(2))
+	assert.False(t, ok)
+	v, ok := c.get(key(1))
+	assert.True(t, ok)
+	assert.Equal(t, 1, v.Interface())
+
+	_, ok = c.get(recordCacheKey{offset: 1, typ: reflect.TypeOf("")})
+	assert.False(t, ok)
+}
+
+func TestDeepCopy(t *testing.T) {
+	type inner struct {
+		Names map[string]string
+		Bytes []byte
+	}
+	original := struct {
+		Any     any
+		Ptr     *inner
+		Slice   []inner
+		Uint128 *big.Int
+	}{
+		Any:     map[string]any{"a": []any{"b"}},
+		Ptr:     &inner{Names: map[string]string{"en": "London"}},
+		Slice:   []inner{{Bytes: []byte{1, 2}}},
+		Uint128: big.NewInt(42),
+	}
+
+	c := deepCopy(reflect.ValueOf(original)).Interface().(struct {
+		Any     any
+		Ptr     *inner
+		Slice   []inner
+		Uint128 *big.Int
+	})
+	assert.Equal(t, original, c)
+
+	c.Any.(map[string]any)["a"].([]any)[0] = "c"
+	c.Ptr.Names["en"] = "Paris"
+	c.Slice[0].Bytes[0] = 3
+	c.Uint128.SetInt64(1)
+	assert.Equal(t, "b", original.Any.(map[string]any)["a"].([]any)[0])
+	assert.Equal(t, "London", original.Ptr.Names["en"])
+	assert.Equal(t, byte(1), original.Slice[0].Bytes[0])
+	assert.Equal(t, int64(42), original.Uint128.Int64())
+}
+
+func BenchmarkNetworksCacheDecodedRecords(b *testing.B) {
+	db, err := Open("GeoLite2-City.mmdb")
+	require.NoError(b, err)
+
+	for _, test := range []struct {
+		name    string
+		options []NetworksOption
+	}{
+		{name: "without cache"},
+		{name: "with cache", options: []NetworksOption{CacheDecodedRecords(1024)}},
+		{
+			name:    "with shared cache",
+			options: []NetworksOption{CacheDecodedRecords(1024), ShareCachedRecords},
+		},
+	} {
+		b.Run(test.name, func(b *testing.B) {
+			options := append([]NetworksOption{SkipAliasedNetworks}, test.options...)
+			for i := 0; i < b.N; i++ {
+				n := db.Networks(options...)
+				for n.Next() {
+					var result fullCity
+					if _, err := n.Network(&result); err != nil {
+						b.Error(err)
+					}
+				}
+				if err := n.Err(); err != nil {
+					b.Error(err)
+				}
+			}
+		})
+	}
+	assert.NoError(b, db.Close(), "error on close")
+}
+
+func TestNetworksV4V6(t *testing.T) {
+	networkStrings := func(n *Networks) []string {
+		var networks []string
+		for n.Next() {
+			var record any
+			network, err := n.Network(&record)
+			require.NoError(t, err)
+			networks = append(networks, network.String())
+		}
+		require.NoError(t, n.Err())
+		return networks
+	}
+
+	for _, file := range []string{
+		"GeoIP2-City-Test.mmdb",
+		"MaxMind-DB-test-ipv4-24.mmdb",
+		"MaxMind-DB-test-ipv6-24.mmdb",
+		"MaxMind-DB-test-ipv6-32.mmdb",
+		"MaxMind-DB-test-mixed-24.mmdb",
+		"MaxMind-DB-test-mixed-32.mmdb",
+		"MaxMind-DB-no-ipv4-search-tree.mmdb",
+	} {
+		t.Run(file, func(t *testing.T) {
+			reader, err := Open(testFile(file))
+			require.NoError(t, err)
+
+			all := networkStrings(reader.Networks(SkipAliasedNetworks))
+			v4 := networkStrings(reader.NetworksV4())
+			v6 := networkStrings(reader.NetworksV6())
+
+			// The IPv4 subtree, ::/96, comes first in the iteration order.
+			assert.Equal(t, all, append(append([]string{}, v4...), v6...))
+			for _, network := range v4 {
+				assert.True(t, netip.MustParsePrefix(network).Addr().Is4(), network)
+			}
+			for _, network := range v6 {
+				assert.True(t, netip.MustParsePrefix(network).Addr().Is6(), network)
+			}
+			if reader.Metadata.IPVersion == 4 {
+				assert.Empty(t, v6)
+			}
+
+			assert.NoError(t, reader.Close())
+		})
+	}
+}
+
+func TestNetworksAfterClose(t *testing.T) {
+	reader, err := Open(testFile("GeoIP2-City-Test.mmdb"))
+	require.NoError(t, err)
+
+	n := reader.Networks(SkipAliasedNetworks)
+	cached := reader.Networks(CacheDecodedRecords(16))
+	require.True(t, n.Next())
+	require.True(t, cached.Next())
+	var record any
+	_, err = cached.Network(&record)
+	require.NoError(t, err)
+
+	// Close the Reader from another goroutine between calls to Next.
+	closed := make(chan error)
+	go func() {
+		closed <- reader.Close()
+	}()
+	require.NoError(t, <-closed)
+
+	_, err = n.Network(&record)
+	assert.EqualError(t, err, "cannot call Network on a closed database")
+	assert.False(t, n.Next())
+	assert.EqualError(t, n.Err(), "cannot call Network on a closed database")
+
+	// The record cache is not consulted after Close.
+	_, err = cached.Network(&record)
+	assert.EqualError(t, err, "cannot call Network on a closed database")
+
+	n = reader.NetworksV4()
+	assert.False(t, n.Next())
+	assert.EqualError(t, n.Err(), "cannot call Networks on a closed database")
+
+	n = reader.NetworksV6()
+	assert.False(t, n.Next())
+	assert.EqualError(t, n.Err(), "cannot call Networks on a closed database")
+}
+
+func TestNetworksNextAfterClose(t *testing.T) {
+	reader, err := Open(testFile("MaxMind-DB-test-ipv4-24.mmdb"))
+	require.NoError(t, err)
+
+	n := reader.Networks()
+	require.True(t, n.Next())
+	require.NoError(t, reader.Close())
+
+	assert.False(t, n.Next())
+	assert.EqualError(t, n.Err(), "cannot call Next on a closed database")
+	var record any
+	_, err = n.Network(&record)
+	assert.EqualError(t, err, "cannot call Next on a closed database")
+}
+
+func TestNetworksConcurrentClose(t *testing.T) {
+	for i := 0; i < 20; i++ {
+		reader, err := Open(testFile("GeoIP2-City-Test.mmdb"))
+		require.NoError(t, err)
+
+		// Close the Reader while another goroutine is iterating over its
+		// networks.
+		n := reader.Networks()
+		started := make(chan struct{})
+		done := make(chan int)
+		go func() {
+			count := 0
+			for n.Next() {
+				if count == 0 {
+					close(started)
+				}
+				count++
+				var record any
+				if _, err := n.Network(&record); err != nil {
+					break
+				}
+			}
+			done <- count
+		}()
+		<-started
+		require.NoError(t, reader.Close())
+		assert.Positive(t, <-done)
+
+		if err := n.Err(); err != nil {
+			assert.Contains(t, err.Error(), "on a closed database")
+		}
+		assert.False(t, n.Next())
+	}
+}
+
+func TestNetworksInRange(t *testing.T) {
+	for _, test := range []struct {
+		first, last string
+		expected    []string
+	}{
+		{
+			// 1.1.1.3 and 1.1.1.8 are in networks extending beyond the
+			// range.
+			first:    "1.1.1.3",
+			last:     "1.1.1.9",
+			expected: []string{"1.1.1.2/31", "1.1.1.4/30", "1.1.1.8/29"},
+		},
+		{
+			// The range is covered by six prefixes within 1.1.1.16/28.
+			first:    "1.1.1.17",
+			last:     "1.1.1.30",
+			expected: []string{"1.1.1.16/28"},
+		},
+		{
+			first:    "1.1.1.0",
+			last:     "1.1.1.255",
+			expected: []string{"1.1.1.1/32", "1.1.1.2/31", "1.1.1.4/30", "1.1.1.8/29", "1.1.1.16/28", "1.1.1.32/32"},
+		},
+		{
+			first:    "1.1.1.33",
+			last:     "255.255.255.255",
+			expected: nil,
+		},
+	} {
+		for _, database := range []string{"ipv4", "mixed"} {
+			reader, err := Open(testFile(fmt.Sprintf("MaxMind-DB-test-%s-24.mmdb", database)))
+			require.NoError(t, err)
+
+			n := reader.NetworksInRange(
+				netip.MustParseAddr(test.first),
+				netip.MustParseAddr(test.last),
+				SkipAliasedNetworks,
+			)
+			var networks []string
+			for n.Next() {
+				network, err := n.Network(nil)
+				require.NoError(t, err)
+				networks = append(networks, network.String())
+			}
+			require.NoError(t, n.Err())
+			assert.Equal(t, test.expected, networks, "%s-%s in %s", test.first, test.last, database)
+
+			require.NoError(t, reader.Close())
+		}
+	}
+}
+
+func TestNetworksInRangeMatchesPrefix(t *testing.T) {
+	reader, err := Open(testFile("GeoIP2-City-Test.mmdb"))
+	require.NoError(t, err)
+	defer reader.Close()
+
+	networks := func(n *Networks) []string {
+		var networks []string
+		for n.Next() {
+			network, err := n.Network(nil)
+			require.NoError(t, err)
+			networks = append(networks, network.String())
+		}
+		require.NoError(t, n.Err())
+		return networks
+	}
+
+	for _, prefix := range []string{"81.2.69.0/24", "81.2.69.128/26", "2001:218::/32", "::/0"} {
+		prefix := netip.MustParsePrefix(prefix)
+		assert.Equal(
+			t,
+			networks(reader.NetworksWithinPrefix(prefix, SkipAliasedNetworks)),
+			networks(reader.NetworksInRange(prefix.Addr(), prefixLast(prefix), SkipAliasedNetworks)),
+			prefix.String(),
+		)
+	}
+
+	// Splitting a range does not change the networks, except for the
+	// network spanning the split, which is returned by both halves.
+	all := networks(reader.NetworksInRange(
+		netip.MustParseAddr("81.2.69.100"),
+		netip.MustParseAddr("81.2.70.30"),
+		SkipAliasedNetworks,
+	))
+	assert.NotEmpty(t, all)
+	lower := networks(reader.NetworksInRange(
+		netip.MustParseAddr("81.2.69.100"),
+		netip.MustParseAddr("81.2.69.200"),
+		SkipAliasedNetworks,
+	))
+	upper := networks(reader.NetworksInRange(
+		netip.MustParseAddr("81.2.69.201"),
+		netip.MustParseAddr("81.2.70.30"),
+		SkipAliasedNetworks,
+	))
+	if len(lower) > 0 && len(upper) > 0 && lower[len(lower)-1] == upper[0] {
+		upper = upper[1:]
+	}
+	assert.Equal(t, all, append(lower, upper...))
+}
+
+func TestNetworksInRangeErrors(t *testing.T) {
+	reader, err := Open(testFile("MaxMind-DB-test-ipv4-24.mmdb"))
+	require.NoError(t, err)
+	defer reader.Close()
+
+	for _, test := range []struct {
+		first, last netip.Addr
+		err         string
+	}{
+		{
+			netip.MustParseAddr("1.1.1.1"),
+			netip.MustParseAddr("::2"),
+			"error getting networks in range 1.1.1.1-::2: the addresses are of different families",
+		},
+		{
+			netip.MustParseAddr("1.1.1.1"),
+			netip.MustParseAddr("::ffff:1.1.1.2"),
+			"error getting networks in range 1.1.1.1-::ffff:1.1.1.2: the addresses are of different families",
+		},
+		{
+			netip.MustParseAddr("1.1.1.2"),
+			netip.MustParseAddr("1.1.1.1"),
+			"error getting networks in range 1.1.1.2-1.1.1.1: the range is empty",
+		},
+		{
+			netip.Addr{},
+			netip.MustParseAddr("1.1.1.1"),
+			"error getting networks in range invalid IP-1.1.1.1: invalid address",
+		},
+		{
+			netip.MustParseAddr("::1"),
+			netip.MustParseAddr("::2"),
+			"error getting networks with '::1-::2': you attempted to use an IPv6 network in an IPv4-only database",
+		},
+	} {
+		n := reader.NetworksInRange(test.first, test.last)
+		assert.False(t, n.Next())
+		assert.EqualError(t, n.Err(), test.err)
+	}
+
+	n := reader.NetworksInRange(
+		netip.MustParseAddr("1.1.1.1"),
+		netip.MustParseAddr("1.1.1.2"),
+		ResumeFrom(Cursor{ip: net.IP{1, 1, 1, 1}, bit: 32}),
+	)
+	assert.False(t, n.Next())
+	assert.EqualError(t, n.Err(), "the ResumeFrom option is not supported by NetworksInRange")
+}
+
+func TestRangePrefixes(t *testing.T) {
+	for _, test := range []struct {
+		first, last string
+		expected    []string
+	}{
+		{"1.1.1.1", "1.1.1.1", []string{"1.1.1.1/32"}},
+		{"0.0.0.0", "255.255.255.255", []string{"0.0.0.0/0"}},
+		{"81.2.69.100", "81.2.70.30", []string{
+			"81.2.69.100/30",
+			"81.2.69.104/29",
+			"81.2.69.112/28",
+			"81.2.69.128/25",
+			"81.2.70.0/28",
+			"81.2.70.16/29",
+			"81.2.70.24/30",
+			"81.2.70.28/31",
+			"81.2.70.30/32",
+		}},
+		{"255.255.255.254", "255.255.255.255", []string{"255.255.255.254/31"}},
+	} {
+		var prefixes []string
+		for _, prefix := range rangePrefixes(netip.MustParseAddr(test.first), netip.MustParseAddr(test.last)) {
+			prefixes = append(prefixes, prefix.String())
+		}
+		assert.Equal(t, test.expected, prefixes)
+	}
+
+	// All but the last two addresses are covered by ::/1, 8000::/2, and so on up
+	// to a /127, and the one before the last by a /128.
+	prefixes := rangePrefixes(
+		netip.MustParseAddr("::"),
+		netip.MustParseAddr("ffff:ffff:ffff:ffff:ffff:ffff:ffff:fffe"),
+	)
+	require.Len(t, prefixes, 128)
+	assert.Equal(t, "::/1", prefixes[0].String())
+	assert.Equal(t, "ffff:ffff:ffff:ffff:ffff:ffff:ffff:fffe/128", prefixes[127].String())
+}
@@ -0,0 +1,86 @@
+package maxminddb
+
+import (
+	"net"
+	"sync"
+)
+
+const (
+	defaultTreeCacheBits = 16
+	maxTreeCacheBits     = 20
+)
+
+// WithTreeCacheBits is an option for Open and FromBytes that sets the number
+// of leading address bits resolved through a lookup table rather than by
+// traversing the search tree. The table maps each combination of the first
+// bits bits to the node reached after them, so that lookups start that far
+// down the tree and avoid the scattered reads of its top levels.
+//
+// The table for IPv6 addresses starts at the root of the tree and the one
+// for IPv4 addresses at the root of the IPv4 subtree. Each is built on the
+// first lookup that uses it and takes 8 bytes per entry, i.e., 8 * 2^bits
+// bytes, so at most 16 * 2^bits bytes are used per Reader: 1 MiB with the
+// default of 16 bits. bits is capped at 20, and 0 disables the tables.
+func WithTreeCacheBits(bits int) ReaderOption {
+	return func(o *readerOptions) {
+		o.treeCacheBits = min(max(bits, 0), maxTreeCacheBits)
+	}
+}
+
+// treeCache maps the first bits bits of an address to the node the search
+// tree traversal reaches after them, starting at root.
+type treeCache struct {
+	once    sync.Once
+	entries []treeCacheEntry
+	root    uint
+	bits    uint
+}
+
+// treeCacheEntry is the node reached for a prefix and the number of bits
+// traversed to reach it. The latter is less than the table's bits if the
+// traversal ended in a record or an empty part of the tree.
+type treeCacheEntry struct {
+	node uint32
+	bits uint8
+}
+
+// traverseTreeCached is traverseTree for bitCount bits of ip starting at the
+// root of cache, using cache for the first bits.
+func (r *Reader) traverseTreeCached(cache *treeCache, ip net.IP, bitCount uint) (uint, int) {
+	if cache.bits == 0 || cache.bits > bitCount {
+		return r.traverseTree(ip, cache.root, bitCount)
+	}
+	cache.once.Do(func() { r.fillTreeCache(cache) })
+
+	key := (uint(ip[0])<<24 | uint(ip[1])<<16 | uint(ip[2])<<8 | uint(ip[3])) >> (32 - cache.bits)
+	entry := cache.entries[key]
+	if uint(entry.bits) < cache.bits {
+		return uint(entry.node), int(entry.bits)
+	}
+	return r.traverseTreeFrom(ip, uint(entry.node), cache.bits, bitCount)
+}
+
+// fillTreeCache builds the entries of cache with a depth-first walk of the
+// top of the tree. A prefix ending in a record or an empty part of the tree
+// fills all of the entries it covers at once.
+func (r *Reader) fillTreeCache(cache *treeCache) {
+	cache.entries = make([]treeCacheEntry, 1<<cache.bits)
+	nodeCount := r.Metadata.NodeCount
+
+	var fill func(node, depth, key uint)
+	fill = func(node, depth, key uint) {
+		if node >= nodeCount || depth == cache.bits {
+			entry := treeCacheEntry{node: uint32(node), bits: uint8(depth)}
+			first := key << (cache.bits - depth)
+			last := (key + 1) << (cache.bits - depth)
+			for i := first; i < last; i++ {
+				cache.entries[i] = entry
+			}
+			return
+		}
+		offset := node * r.nodeOffsetMult
+		fill(r.nodeReader.readLeft(offset), depth+1, key<<1)
+		fill(r.nodeReader.readRight(offset), depth+1, key<<1|1)
+	}
+	fill(cache.root, 0, 0)
+}
@@ -0,0 +1,177 @@
+package maxminddb
+
+import (
+	"fmt"
+	"math/rand"
+	"net"
+	"net/netip"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTreeCache(t *testing.T) {
+	//nolint:gosec // this is a test
+	r := rand.New(rand.NewSource(0))
+	var ips []net.IP
+	for i := 0; i < 1000; i++ {
+		ip := make(net.IP, 4)
+		randomIPv4Address(r, ip)
+		ips = append(ips, ip)
+		ip = make(net.IP, 16)
+		r.Read(ip)
+		ips = append(ips, ip)
+	}
+	ips = append(ips,
+		net.ParseIP("1.1.1.1"),
+		net.ParseIP("81.2.69.142"),
+		net.ParseIP("::1.1.1.1"),
+		net.ParseIP("::ffff:1.1.1.1"),
+		net.ParseIP("2001:218::"),
+		net.ParseIP("::"),
+	)
+
+	for _, recordSize := range []int{24, 28, 32} {
+		for _, ipVersion := range []int{4, 6} {
+			file := fmt.Sprintf("MaxMind-DB-test-ipv%d-%d.mmdb", ipVersion, recordSize)
+			testTreeCache(t, file, ips)
+		}
+		file := fmt.Sprintf("MaxMind-DB-test-mixed-%d.mmdb", recordSize)
+		testTreeCache(t, file, ips)
+	}
+	testTreeCache(t, "GeoIP2-City-Test.mmdb", ips)
+	testTreeCache(t, "MaxMind-DB-no-ipv4-search-tree.mmdb", ips)
+}
+
+func testTreeCache(t *testing.T, file string, ips []net.IP) {
+	t.Run(file, func(t *testing.T) {
+		uncached, err := Open(testFile(file), WithTreeCacheBits(0))
+		require.NoError(t, err)
+
+		for _, bits := range []int{1, 8, 16, 20} {
+			cached, err := Open(testFile(file), WithTreeCacheBits(bits))
+			require.NoError(t, err)
+
+			for _, ip := range ips {
+				var expected, actual any
+				expectedNetwork, expectedOK, expectedErr := uncached.LookupNetwork(ip, &expected)
+				network, ok, err := cached.LookupNetwork(ip, &actual)
+				assert.Equal(t, expectedErr, err, "%s with %d bits", ip, bits)
+				assert.Equal(t, expectedOK, ok, "%s with %d bits", ip, bits)
+				assert.Equal(t, expectedNetwork, network, "%s with %d bits", ip, bits)
+				assert.Equal(t, expected, actual, "%s with %d bits", ip, bits)
+			}
+			require.NoError(t, cached.Close())
+		}
+		require.NoError(t, uncached.Close())
+	})
+}
+
+// TestTreeCachePrefixLengths checks that the tree cache, which is on by
+// default, does not change the prefix lengths of the networks returned for
+// IPv4 and IPv6 lookups.
+func TestTreeCachePrefixLengths(t *testing.T) {
+	ips := []string{
+		"1.1.1.1",
+		"1.1.1.3",
+		"1.1.1.32",
+		"::ffff:1.1.1.1",
+		"::1.1.1.1",
+		"::1:ffff:ffff",
+		"::2:0:1",
+		"::2:0:40",
+		"2001:218::",
+		"8.8.8.8",
+		"ffff::",
+	}
+	for _, file := range []string{
+		"MaxMind-DB-test-ipv4-24.mmdb",
+		"MaxMind-DB-test-ipv6-24.mmdb",
+		"MaxMind-DB-test-mixed-24.mmdb",
+		"GeoIP2-City-Test.mmdb",
+	} {
+		t.Run(file, func(t *testing.T) {
+			uncached, err := Open(testFile(file), WithTreeCacheBits(0))
+			require.NoError(t, err)
+			defer uncached.Close()
+			cached, err := Open(testFile(file))
+			require.NoError(t, err)
+			defer cached.Close()
+
+			for _, s := range ips {
+				ip := netip.MustParseAddr(s)
+				var record any
+				expectedNetwork, _, expectedErr := uncached.LookupNetwork(net.IP(ip.AsSlice()), &record)
+				network, _, err := cached.LookupNetwork(net.IP(ip.AsSlice()), &record)
+				assert.Equal(t, expectedErr, err, s)
+				if expectedErr == nil {
+					expectedOnes, expectedBits := expectedNetwork.Mask.Size()
+					ones, bits := network.Mask.Size()
+					assert.Equal(t, expectedOnes, ones, s)
+					assert.Equal(t, expectedBits, bits, s)
+				}
+
+				expectedPrefix, _, expectedErr := uncached.LookupNetworkPrefix(ip, &record)
+				prefix, _, err := cached.LookupNetworkPrefix(ip, &record)
+				assert.Equal(t, expectedErr, err, s)
+				assert.Equal(t, expectedPrefix.Bits(), prefix.Bits(), s)
+				assert.Equal(t, expectedPrefix, prefix, s)
+			}
+		})
+	}
+}
+
+func BenchmarkTreeCache(b *testing.B) {
+	for _, test := range []struct {
+		name    string
+		options []ReaderOption
+	}{
+		{name: "without cache", options: []ReaderOption{WithTreeCacheBits(0)}},
+		{name: "with cache", options: []ReaderOption{WithTreeCacheBits(defaultTreeCacheBits)}},
+	} {
+		b.Run(test.name, func(b *testing.B) {
+			db, err := Open("GeoLite2-City.mmdb", test.options...)
+			require.NoError(b, err)
+
+			//nolint:gosec // this is a test
+			r := rand.New(rand.NewSource(0))
+			var result fullCity
+
+			ip := make(net.IP, 4)
+			for i := 0; i < b.N; i++ {
+				randomIPv4Address(r, ip)
+				err = db.Lookup(ip, &result)
+				if err != nil {
+					b.Error(err)
+				}
+			}
+			assert.NoError(b, db.Close(), "error on close")
+		})
+	}
+}
+
+// BenchmarkTreeCacheTraversal is BenchmarkTreeCache without decoding the
+// records, i.e., it measures the traversal of the tree alone.
+func BenchmarkTreeCacheTraversal(b *testing.B) {
+	for _, bits := range []int{0, 8, defaultTreeCacheBits, maxTreeCacheBits} {
+		b.Run(fmt.Sprintf("%d bits", bits), func(b *testing.B) {
+			db, err := Open("GeoLite2-City.mmdb", WithTreeCacheBits(bits))
+			require.NoError(b, err)
+
+			//nolint:gosec // this is a test
+			r := rand.New(rand.NewSource(0))
+
+			ip := make(net.IP, 4)
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				randomIPv4Address(r, ip)
+				_, err = db.LookupOffset(ip)
+				if err != nil {
+					b.Error(err)
+				}
+			}
+			assert.NoError(b, db.Close(), "error on close")
+		})
+	}
+}
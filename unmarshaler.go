@@ -0,0 +1,65 @@
+package maxminddb
+
+import (
+	"errors"
+
+	"github.com/3JoB/go-reflect"
+)
+
+// Unmarshaler is implemented by types that decode themselves from a value
+// in the database, as json.Unmarshaler is for JSON. It is honored for
+// results and for the struct fields, array elements, and map values below
+// them, including when the method has a pointer receiver and the value is
+// not a pointer. It takes precedence over decode hooks and
+// encoding.BinaryUnmarshaler.
+//
+// The Decoder passed to UnmarshalMaxMindDB is positioned at the value. The
+// method may read the value, or any part of it, or not read it at all; the
+// decode continues after the value either way. For instance, a type holding
+// the English name from a names map:
+//
+//	type englishName string
+//
+//	func (n *englishName) UnmarshalMaxMindDB(d *maxminddb.Decoder) error {
+//		return d.DecodeMap(func(key string, value *maxminddb.Decoder) error {
+//			if key != "en" {
+//				return nil
+//			}
+//			return value.Decode((*string)(n))
+//		})
+//	}
+//
+// Errors returned by UnmarshalMaxMindDB abort the decode and are returned as
+// a FieldError with the path of the value.
+type Unmarshaler interface {
+	UnmarshalMaxMindDB(d *Decoder) error
+}
+
+// unmarshalerOf returns the Unmarshaler implemented by result or a pointer
+// to it, if any.
+func unmarshalerOf(result reflect.Value) (Unmarshaler, bool) {
+	result = indirect(result)
+	if !result.CanAddr() {
+		return nil, false
+	}
+	u, ok := result.Addr().Interface().(Unmarshaler)
+	return u, ok
+}
+
+// unmarshalCustom passes a Decoder positioned at the value at offset to the
+// UnmarshalMaxMindDB method of u. Errors from the method are returned as a
+// FieldError with the path of the value.
+func (d *decoder) unmarshalCustom(offset uint, u Unmarshaler, depth int) (uint, error) {
+	newOffset, err := d.nextValueOffset(offset, 1)
+	if err != nil {
+		return 0, err
+	}
+	if err := u.UnmarshalMaxMindDB(&Decoder{d: d, offset: offset, depth: depth, single: true}); err != nil {
+		var fieldErr FieldError
+		if d.softFail || errors.As(err, &fieldErr) {
+			return 0, err
+		}
+		return 0, FieldError{Err: err}
+	}
+	return newOffset, nil
+}
@@ -0,0 +1,197 @@
+package maxminddb
+
+import (
+	"encoding/binary"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// uint128Bytes holds a uint128 as big-endian bytes.
+type uint128Bytes [16]byte
+
+func (u *uint128Bytes) UnmarshalMaxMindDB(d *Decoder) error {
+	hi, lo, err := d.ReadUint128()
+	if err != nil {
+		return err
+	}
+	binary.BigEndian.PutUint64(u[:8], hi)
+	binary.BigEndian.PutUint64(u[8:], lo)
+	return nil
+}
+
+// doubled holds twice the integer it is decoded from.
+type doubled uint64
+
+func (v *doubled) UnmarshalMaxMindDB(d *Decoder) error {
+	var n uint64
+	if err := d.Decode(&n); err != nil {
+		return err
+	}
+	*v = doubled(2 * n)
+	return nil
+}
+
+// kindName holds the kind of the value it is decoded from.
+type kindName string
+
+func (k *kindName) UnmarshalMaxMindDB(d *Decoder) error {
+	kind, err := d.PeekKind()
+	*k = kindName(kind.String())
+	return err
+}
+
+// englishName holds the English name from a names map.
+type englishName string
+
+func (n *englishName) UnmarshalMaxMindDB(d *Decoder) error {
+	return d.DecodeMap(func(key string, value *Decoder) error {
+		if key != "en" {
+			return nil
+		}
+		return value.Decode((*string)(n))
+	})
+}
+
+// mapKeys holds the keys of the map it is decoded from.
+type mapKeys []string
+
+func (k *mapKeys) UnmarshalMaxMindDB(d *Decoder) error {
+	*k = nil
+	return d.DecodeMap(func(key string, _ *Decoder) error {
+		*k = append(*k, key)
+		return nil
+	})
+}
+
+func TestUnmarshaler(t *testing.T) {
+	reader, err := Open(testFile("MaxMind-DB-test-decoder.mmdb"))
+	require.NoError(t, err)
+	defer reader.Close()
+	ip := net.ParseIP("::1.1.1.0")
+
+	var result struct {
+		Uint128    uint128Bytes        `maxminddb:"uint128"`
+		Uint128Ptr *uint128Bytes       `maxminddb:"uint128"`
+		Array      []doubled           `maxminddb:"array"`
+		Map        map[string]kindName `maxminddb:"map"`
+		Boolean    kindName            `maxminddb:"boolean"`
+		Utf8String string              `maxminddb:"utf8_string"`
+	}
+	require.NoError(t, reader.Lookup(ip, &result))
+	expected := uint128Bytes{0x01}
+	assert.Equal(t, expected, result.Uint128)
+	require.NotNil(t, result.Uint128Ptr)
+	assert.Equal(t, expected, *result.Uint128Ptr)
+	assert.Equal(t, []doubled{2, 4, 6}, result.Array)
+	assert.Equal(t, map[string]kindName{"mapX": "map"}, result.Map)
+	assert.Equal(t, kindName("boolean"), result.Boolean)
+	// The values following those decoded by an Unmarshaler are decoded.
+	assert.Equal(t, "unicode! ☯ - ♫", result.Utf8String)
+
+	// Results themselves may implement Unmarshaler.
+	var keys mapKeys
+	require.NoError(t, reader.Lookup(ip, &keys))
+	assert.ElementsMatch(t, []string{
+		"array", "boolean", "bytes", "double", "float", "int32", "map",
+		"uint16", "uint32", "uint64", "uint128", "utf8_string",
+	}, keys)
+
+	var kind kindName
+	require.NoError(t, reader.Lookup(ip, &kind))
+	assert.Equal(t, kindName("map"), kind)
+}
+
+func TestUnmarshalerCity(t *testing.T) {
+	reader, err := Open(testFile("GeoIP2-City-Test.mmdb"))
+	require.NoError(t, err)
+	defer reader.Close()
+
+	var result struct {
+		City struct {
+			Name englishName `maxminddb:"names"`
+		} `maxminddb:"city"`
+		Subdivisions []struct {
+			Name englishName `maxminddb:"names"`
+		} `maxminddb:"subdivisions"`
+	}
+	require.NoError(t, reader.Lookup(net.ParseIP("2.125.160.216"), &result))
+	assert.Equal(t, englishName("Boxford"), result.City.Name)
+	require.Len(t, result.Subdivisions, 2)
+	assert.Equal(t, englishName("England"), result.Subdivisions[0].Name)
+	assert.Equal(t, englishName("West Berkshire"), result.Subdivisions[1].Name)
+}
+
+func TestUnmarshalerArray(t *testing.T) {
+	reader, err := Open(testFile("MaxMind-DB-test-decoder.mmdb"))
+	require.NoError(t, err)
+	defer reader.Close()
+
+	var result struct {
+		Map struct {
+			MapX struct {
+				ArrayX arraySum `maxminddb:"arrayX"`
+			} `maxminddb:"mapX"`
+		} `maxminddb:"map"`
+	}
+	require.NoError(t, reader.Lookup(net.ParseIP("::1.1.1.0"), &result))
+	assert.Equal(t, arraySum(24), result.Map.MapX.ArrayX)
+}
+
+// arraySum holds the sum of the integers in the array it is decoded from.
+type arraySum uint64
+
+func (s *arraySum) UnmarshalMaxMindDB(d *Decoder) error {
+	*s = 0
+	return d.DecodeArray(func(_ int, value *Decoder) error {
+		var n uint64
+		if err := value.Decode(&n); err != nil {
+			return err
+		}
+		*s += arraySum(n)
+		return nil
+	})
+}
+
+func TestUnmarshalerErrors(t *testing.T) {
+	reader, err := Open(testFile("MaxMind-DB-test-decoder.mmdb"))
+	require.NoError(t, err)
+	defer reader.Close()
+	ip := net.ParseIP("::1.1.1.0")
+
+	var wrongKind struct {
+		Uint128 uint128Bytes `maxminddb:"uint64"`
+	}
+	err = reader.Lookup(ip, &wrongKind)
+	assert.EqualError(t, err, "maxminddb: error decoding uint64: maxminddb: cannot read uint64 as uint128")
+
+	var notMap struct {
+		Name englishName `maxminddb:"array"`
+	}
+	err = reader.Lookup(ip, &notMap)
+	assert.EqualError(t, err, "maxminddb: error decoding array: maxminddb: cannot read array as map")
+
+	var notArray struct {
+		Sum arraySum `maxminddb:"map"`
+	}
+	err = reader.Lookup(ip, &notArray)
+	assert.EqualError(t, err, "maxminddb: error decoding map: maxminddb: cannot read map as array")
+
+	// Errors of the decodes by the method are returned.
+	var nested struct {
+		Map struct {
+			MapX doubled `maxminddb:"mapX"`
+		} `maxminddb:"map"`
+	}
+	err = reader.Lookup(ip, &nested)
+	var typeErr UnmarshalTypeError
+	assert.ErrorAs(t, err, &typeErr)
+
+	err = reader.With(SoftFailDecode(true)).Lookup(ip, &wrongKind)
+	var fieldErrs FieldErrors
+	require.ErrorAs(t, err, &fieldErrs)
+	require.Len(t, fieldErrs, 1)
+	assert.Equal(t, "uint64", fieldErrs[0].Path)
+}
@@ -0,0 +1,32 @@
+package maxminddb
+
+// WithUnsafeStrings is an option for Open, FromBytes, and FromReader that
+// makes decoded strings, including map keys, alias the database buffer
+// rather than being copied out of it. This avoids an allocation for each
+// string, which may be most of those made by a lookup, e.g., for names maps.
+//
+// The strings are only valid until Close is called. For a memory-mapped
+// database, using one afterwards may crash the program. For a Reader
+// created with FromBytes, the strings alias the caller's buffer and change
+// if it is modified. The option has no effect with WithDetachedResults or
+// when built with the purego tag, as the aliasing requires unsafe.
+func WithUnsafeStrings() ReaderOption {
+	return func(o *readerOptions) {
+		o.unsafeStrings = true
+	}
+}
+
+// bufferString returns b, a slice of the buffer, as a string, which aliases
+// the buffer if WithUnsafeStrings is set and is otherwise interned if
+// possible.
+func (d *decoder) bufferString(b []byte) string {
+	if d.unsafeStrings && !d.detachedResults {
+		return aliasString(b)
+	}
+	if d.strings != nil {
+		if s, ok := d.strings.intern(d.buffer, b); ok {
+			return s
+		}
+	}
+	return string(b)
+}
@@ -0,0 +1,83 @@
+package maxminddb
+
+import (
+	"encoding/hex"
+	"net"
+	"os"
+	"testing"
+
+	"github.com/3JoB/go-reflect"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUnsafeStrings(t *testing.T) {
+	buffer, err := hex.DecodeString(languageTestData)
+	require.NoError(t, err)
+	d := decoder{buffer: buffer, unsafeStrings: true}
+
+	var names map[string]string
+	_, err = d.decode(0, reflect.ValueOf(&names), 0)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"de": "A", "en": "B", "pt-BR": "C"}, names)
+
+	detached := decoder{buffer: buffer, unsafeStrings: true, detachedResults: true}
+	var copied map[string]string
+	_, err = detached.decode(0, reflect.ValueOf(&copied), 0)
+	require.NoError(t, err)
+
+	// The value of "de" is the byte at offset 5.
+	buffer[5] = 'Z'
+	if aliasesStrings {
+		assert.Equal(t, "Z", names["de"])
+	} else {
+		assert.Equal(t, "A", names["de"])
+	}
+	assert.Equal(t, "A", copied["de"])
+}
+
+func TestUnsafeStringsFromBytes(t *testing.T) {
+	buffer, err := os.ReadFile(testFile("GeoIP2-City-Test.mmdb"))
+	require.NoError(t, err)
+	reader, err := FromBytes(buffer, WithUnsafeStrings())
+	require.NoError(t, err)
+	expected, err := Open(testFile("GeoIP2-City-Test.mmdb"))
+	require.NoError(t, err)
+	defer expected.Close()
+
+	for _, ip := range []string{"2.125.160.216", "81.2.69.160", "2001:218::"} {
+		var got, want map[string]any
+		require.NoError(t, reader.Lookup(net.ParseIP(ip), &got))
+		require.NoError(t, expected.Lookup(net.ParseIP(ip), &want))
+		assert.Equal(t, want, got, ip)
+	}
+	require.NoError(t, reader.Close())
+}
+
+func BenchmarkUnsafeStrings(b *testing.B) {
+	buffer, err := hex.DecodeString(languageTestData)
+	require.NoError(b, err)
+	for _, bench := range []struct {
+		name string
+		d    decoder
+	}{
+		{"copy", decoder{buffer: buffer}},
+		{"unsafe", decoder{buffer: buffer, unsafeStrings: true}},
+	} {
+		b.Run(bench.name, func(b *testing.B) {
+			b.ReportAllocs()
+			var names struct {
+				City struct {
+					Names map[string]string `maxminddb:"names"`
+				} `maxminddb:"city"`
+				ID string `maxminddb:"id"`
+			}
+			for i := 0; i < b.N; i++ {
+				names.City.Names = nil
+				if _, err := bench.d.decode(19, reflect.ValueOf(&names), 0); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,403 @@
+package maxminddb
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"sort"
+
+	"github.com/3JoB/go-reflect"
+)
+
+// The maximum number of records checked by ValidateStruct when no IPs are
+// given.
+const validateSampleSize = 1000
+
+// ValidationReport is returned by ValidateStruct. Paths use "." to separate
+// map keys, "[]" for the elements of a slice, and "*" for the values of a Go
+// map, e.g., "subdivisions[].names.*". All slices are sorted so that reports
+// for different databases may be compared with a plain diff.
+type ValidationReport struct {
+	// MissingFields holds the paths of struct fields whose keys did not
+	// appear in any of the records checked. Fields within a missing field
+	// are not listed.
+	MissingFields []string
+	// UnusedKeys holds the paths of keys in the records checked that do not
+	// correspond to any struct field.
+	UnusedKeys []string
+	// TypeMismatches holds the values that could not be decoded into the
+	// corresponding struct field.
+	TypeMismatches []TypeMismatch
+	// Records is the number of records checked.
+	Records int
+}
+
+// TypeMismatch describes a struct field that a value in the database could
+// not be decoded into.
+type TypeMismatch struct {
+	// Path is the path of the field.
+	Path string
+	// DatabaseType is the MaxMind DB type of the value, e.g., "utf8_string".
+	DatabaseType string
+	// GoType is the type of the field.
+	GoType string
+	// Count is the number of values with this mismatch in the records
+	// checked.
+	Count int
+}
+
+// ValidateStruct checks that the struct type of sample matches the records
+// in the database. The records for ips are checked or, if no IPs are given,
+// up to 1,000 distinct records spread across the database. Each record is
+// decoded into a new value of the struct type and the fields and keys that
+// do not line up are reported.
+//
+// An error is returned if the database cannot be read or if sample is not a
+// struct or a pointer to one. Type mismatches are not errors; they are
+// listed in the report.
+func ValidateStruct(r *Reader, sample any, ips ...net.IP) (ValidationReport, error) {
+	if r.buffer == nil {
+		return ValidationReport{}, errors.New("cannot call ValidateStruct on a closed database")
+	}
+	structType := reflect.TypeOf(sample)
+	for structType != nil && structType.Kind() == reflect.Ptr {
+		structType = structType.Elem()
+	}
+	if structType == nil || structType.Kind() != reflect.Struct {
+		return ValidationReport{}, fmt.Errorf(
+			"sample must be a struct or a pointer to one, got %T",
+			sample,
+		)
+	}
+
+	offsets, err := validationOffsets(r, ips)
+	if err != nil {
+		return ValidationReport{}, err
+	}
+
+	v := &structValidator{
+		seen:       map[string]bool{"": true},
+		unused:     map[string]bool{},
+		mismatches: map[TypeMismatch]int{},
+	}
+	for _, offset := range offsets {
+		if err := v.checkRecord(r, structType, offset); err != nil {
+			return ValidationReport{}, err
+		}
+	}
+
+	report := ValidationReport{Records: len(offsets)}
+	fieldPaths := map[string]string{}
+	collectFieldPaths(structType, "", r.decoder.tagFallback, fieldPaths, map[reflect.Type]bool{})
+	for path, parent := range fieldPaths {
+		if !v.seen[path] && v.seen[parent] {
+			report.MissingFields = append(report.MissingFields, path)
+		}
+	}
+	for path := range v.unused {
+		report.UnusedKeys = append(report.UnusedKeys, path)
+	}
+	for mismatch, count := range v.mismatches {
+		mismatch.Count = count
+		report.TypeMismatches = append(report.TypeMismatches, mismatch)
+	}
+	sort.Strings(report.MissingFields)
+	sort.Strings(report.UnusedKeys)
+	sort.Slice(report.TypeMismatches, func(i, j int) bool {
+		a, b := report.TypeMismatches[i], report.TypeMismatches[j]
+		if a.Path != b.Path {
+			return a.Path < b.Path
+		}
+		if a.DatabaseType != b.DatabaseType {
+			return a.DatabaseType < b.DatabaseType
+		}
+		return a.GoType < b.GoType
+	})
+	return report, nil
+}
+
+// validationOffsets returns the data section offsets of the records to
+// check. Without ips, the distinct records in the database are gathered and
+// up to validateSampleSize of them are picked at even intervals.
+func validationOffsets(r *Reader, ips []net.IP) ([]uintptr, error) {
+	seen := map[uintptr]bool{}
+	var offsets []uintptr
+	if len(ips) > 0 {
+		for _, ip := range ips {
+			offset, err := r.LookupOffset(ip)
+			if err != nil {
+				return nil, err
+			}
+			if offset != NotFound && !seen[offset] {
+				seen[offset] = true
+				offsets = append(offsets, offset)
+			}
+		}
+		return offsets, nil
+	}
+
+	n := r.Networks(SkipAliasedNetworks)
+	for n.Next() {
+		offset, err := r.resolveDataPointer(n.lastNode.parent, n.lastNode.pointer)
+		if err != nil {
+			return nil, err
+		}
+		if !seen[offset] {
+			seen[offset] = true
+			offsets = append(offsets, offset)
+		}
+	}
+	if err := n.Err(); err != nil {
+		return nil, err
+	}
+	sort.Slice(offsets, func(i, j int) bool { return offsets[i] < offsets[j] })
+	if len(offsets) <= validateSampleSize {
+		return offsets, nil
+	}
+	sample := make([]uintptr, validateSampleSize)
+	for i := range sample {
+		sample[i] = offsets[i*len(offsets)/validateSampleSize]
+	}
+	return sample, nil
+}
+
+type structValidator struct {
+	// seen and unused are keyed by normalized path.
+	seen       map[string]bool
+	unused     map[string]bool
+	mismatches map[TypeMismatch]int
+
+	// recordPaths maps the paths used in FieldErrors, which include slice
+	// indexes and map keys, to their normalized path and the MaxMind DB type
+	// of the value. It is reset for each record.
+	recordPaths map[string]pathInfo
+}
+
+type pathInfo struct {
+	path         string
+	databaseType string
+}
+
+func (v *structValidator) checkRecord(r *Reader, structType reflect.Type, offset uintptr) error {
+	d := r.decoder
+	d.softFail = true
+	d.strict = false
+	d.reportCoercions = false
+	d.maxDecodedBytes = 0
+
+	v.recordPaths = map[string]pathInfo{}
+	if err := v.walk(&d, structType, uint(offset), "", "", 0); err != nil {
+		return err
+	}
+
+	if _, err := d.decode(uint(offset), reflect.New(structType), 0); err != nil {
+		var typeErr UnmarshalTypeError
+		if errors.As(err, &typeErr) {
+			// The record itself does not match the struct.
+			v.mismatches[TypeMismatch{
+				DatabaseType: v.recordPaths[""].databaseType,
+				GoType:       typeErr.Type.String(),
+			}]++
+			return nil
+		}
+		return err
+	}
+	for _, fieldError := range d.fieldErrors {
+		if errors.Is(fieldError.Err, ErrMissingRequiredKey) {
+			// Reported as a missing field.
+			continue
+		}
+		var typeErr UnmarshalTypeError
+		if !errors.As(fieldError.Err, &typeErr) {
+			return fmt.Errorf("error decoding %s: %w", fieldError.Path, fieldError.Err)
+		}
+		info := v.recordPaths[fieldError.Path]
+		v.mismatches[TypeMismatch{
+			Path:         info.path,
+			DatabaseType: info.databaseType,
+			GoType:       typeErr.Type.String(),
+		}]++
+	}
+	return nil
+}
+
+// walk follows the value at offset alongside t, recording the keys seen and
+// the type of each value. recordPath is the path of the value as used in
+// FieldErrors and path is its normalized form.
+func (v *structValidator) walk(
+	d *decoder,
+	t reflect.Type,
+	offset uint,
+	recordPath,
+	path string,
+	depth int,
+) error {
+	if depth > maximumDataStructureDepth {
+		return newInvalidDatabaseError(
+			"exceeded maximum data structure depth; database is likely corrupt",
+		)
+	}
+	typeNum, size, offset, err := d.decodeCtrlData(offset)
+	if err != nil {
+		return err
+	}
+	if typeNum == _Pointer {
+		pointer, _, err := d.decodePointer(size, offset)
+		if err != nil {
+			return err
+		}
+		typeNum, size, offset, err = d.decodeCtrlData(pointer)
+		if err != nil {
+			return err
+		}
+	}
+	v.recordPaths[recordPath] = pathInfo{path: path, databaseType: dataTypeName(typeNum)}
+
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	switch {
+	case t.Kind() == reflect.Struct && typeNum == _Map:
+		fields := validationFields(t, d.tagFallback)
+		for i := uint(0); i < size; i++ {
+			var key []byte
+			key, offset, err = d.decodeKey(offset)
+			if err != nil {
+				return err
+			}
+			keyPath := joinValidationPath(path, string(key))
+			if field, ok := fields[string(key)]; ok {
+				v.seen[keyPath] = true
+				elemPath := joinValidationPath(recordPath, string(key))
+				if err := v.walk(d, field, offset, elemPath, keyPath, depth+1); err != nil {
+					return err
+				}
+			} else {
+				v.unused[keyPath] = true
+			}
+			offset, err = d.nextValueOffset(offset, 1)
+			if err != nil {
+				return err
+			}
+		}
+	case t.Kind() == reflect.Map && typeNum == _Map:
+		for i := uint(0); i < size; i++ {
+			var key []byte
+			key, offset, err = d.decodeKey(offset)
+			if err != nil {
+				return err
+			}
+			elemPath := joinValidationPath(recordPath, string(key))
+			if err := v.walk(d, t.Elem(), offset, elemPath, path+".*", depth+1); err != nil {
+				return err
+			}
+			offset, err = d.nextValueOffset(offset, 1)
+			if err != nil {
+				return err
+			}
+		}
+	case (t.Kind() == reflect.Slice || t.Kind() == reflect.Array) && typeNum == _Slice:
+		for i := uint(0); i < size; i++ {
+			elemPath := recordPath + sliceIndexPath(int(i))
+			if err := v.walk(d, t.Elem(), offset, elemPath, path+"[]", depth+1); err != nil {
+				return err
+			}
+			offset, err = d.nextValueOffset(offset, 1)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// validationFields returns the types of the fields of the struct type t
+// keyed by the database key they are decoded from. The fields of embedded
+// and inline structs are included as the decoder fills them from the same
+// map.
+func validationFields(t reflect.Type, tagFallback string) map[string]reflect.Type {
+	fields := map[string]reflect.Type{}
+	var inline []reflect.Type
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name, isInline, skip := parseFieldTag(field, tagFallback)
+		if skip || isRemainField(field) {
+			continue
+		}
+		if field.Anonymous {
+			embedded := field.Type
+			for embedded.Kind() == reflect.Ptr {
+				embedded = embedded.Elem()
+			}
+			if embedded.Kind() == reflect.Struct {
+				for key, fieldType := range validationFields(embedded, tagFallback) {
+					if _, ok := fields[key]; !ok {
+						fields[key] = fieldType
+					}
+				}
+			}
+			continue
+		}
+		if isInline {
+			if s := inlineStructType(field.Type); s != t {
+				inline = append(inline, s)
+			}
+			continue
+		}
+		if parseLanguageOption(field) != nil {
+			// The field holds one value of a names map, so the map is
+			// checked as if it were decoded in full.
+			fields[name] = reflect.MapOf(reflect.TypeOf(""), field.Type)
+			continue
+		}
+		fields[name] = field.Type
+	}
+	// The fields of the struct take precedence over those of inline
+	// structs.
+	for _, s := range inline {
+		for key, fieldType := range validationFields(s, tagFallback) {
+			if _, ok := fields[key]; !ok {
+				fields[key] = fieldType
+			}
+		}
+	}
+	return fields
+}
+
+// collectFieldPaths adds the normalized paths of all fields reachable from t
+// to paths, mapped to the path of their parent field.
+func collectFieldPaths(
+	t reflect.Type,
+	path string,
+	tagFallback string,
+	paths map[string]string,
+	visiting map[reflect.Type]bool,
+) {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	switch t.Kind() {
+	case reflect.Struct:
+		if visiting[t] {
+			return
+		}
+		visiting[t] = true
+		for key, fieldType := range validationFields(t, tagFallback) {
+			fieldPath := joinValidationPath(path, key)
+			paths[fieldPath] = path
+			collectFieldPaths(fieldType, fieldPath, tagFallback, paths, visiting)
+		}
+		delete(visiting, t)
+	case reflect.Map:
+		collectFieldPaths(t.Elem(), path+".*", tagFallback, paths, visiting)
+	case reflect.Slice, reflect.Array:
+		collectFieldPaths(t.Elem(), path+"[]", tagFallback, paths, visiting)
+	}
+}
+
+func joinValidationPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
@@ -0,0 +1,58 @@
+package maxminddb
+
+import (
+	"net"
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type validationCity struct {
+	City struct {
+		Names     map[string]string `maxminddb:"names"`
+		GeoNameID uint              `maxminddb:"geoname_id"`
+	} `maxminddb:"city"`
+	Country struct {
+		ISOCode uint `maxminddb:"iso_code"`
+	} `maxminddb:"country"`
+	NotInDatabase string `maxminddb:"not_in_database"`
+}
+
+func TestValidateStruct(t *testing.T) {
+	reader, err := Open(testFile("GeoIP2-City-Test.mmdb"))
+	require.NoError(t, err)
+
+	report, err := ValidateStruct(reader, validationCity{}, net.ParseIP("81.2.69.160"))
+	require.NoError(t, err)
+
+	assert.Equal(t, 1, report.Records)
+	assert.Equal(t, []string{"not_in_database"}, report.MissingFields)
+	assert.Contains(t, report.UnusedKeys, "continent")
+	assert.Contains(t, report.UnusedKeys, "country.geoname_id")
+	assert.NotContains(t, report.UnusedKeys, "city.names")
+	assert.Equal(
+		t,
+		[]TypeMismatch{
+			{
+				Path:         "country.iso_code",
+				DatabaseType: "utf8_string",
+				GoType:       "uint",
+				Count:        1,
+			},
+		},
+		report.TypeMismatches,
+	)
+
+	report, err = ValidateStruct(reader, &validationCity{})
+	require.NoError(t, err)
+	assert.Greater(t, report.Records, 1)
+	assert.True(t, sort.StringsAreSorted(report.MissingFields))
+	assert.True(t, sort.StringsAreSorted(report.UnusedKeys))
+
+	_, err = ValidateStruct(reader, "not a struct")
+	assert.Error(t, err)
+
+	assert.NoError(t, reader.Close())
+}
@@ -0,0 +1,243 @@
+package maxminddb
+
+import (
+	"errors"
+	"math"
+	"net"
+)
+
+// Value is a value in the data section that is read on demand rather than
+// decoded into a Go value. It is returned by LookupValue and allows looking
+// up single values of a record without reflection or decoding the rest of
+// the record, e.g.,
+//
+//	v, err := reader.LookupValue(ip)
+//	isoCode := v.Get("country").Get("iso_code").String()
+//
+// The accessors never fail: one that does not apply to the value, such as
+// Get on an array, a missing map key or array index, or data that cannot be
+// read return a Value for which Exists is false, and the accessors of such
+// a Value return zero values. Only String allocates.
+//
+// A Value must not be used after the Reader is closed.
+type Value struct {
+	reader  *Reader
+	typeNum dataType
+	size    uint
+	// offset is the offset of the data of the value, following its control
+	// bytes.
+	offset uint
+}
+
+// LookupValue returns the record for ip as a Value. If the database has no
+// record for ip, a Value for which Exists is false is returned.
+func (r *Reader) LookupValue(ip net.IP) (Value, error) {
+	if r.buffer == nil {
+		return Value{}, errors.New("cannot call LookupValue on a closed database")
+	}
+	offset, _, _, err := r.lookupRecord(ip)
+	if err != nil || offset == NotFound {
+		return Value{}, err
+	}
+	return r.value(uint(offset)), nil
+}
+
+// value returns the Value at offset, following a pointer, or the zero Value
+// if it cannot be read.
+func (r *Reader) value(offset uint) Value {
+	d := &r.decoder
+	typeNum, size, offset, err := d.resolveCtrlData(offset)
+	if err != nil {
+		return Value{}
+	}
+	switch typeNum {
+	case _Map:
+		err = d.checkContainerSize(2*size, offset)
+	case _Slice:
+		err = d.checkContainerSize(size, offset)
+	case _Bool:
+	default:
+		if offset > uint(len(d.buffer)) || size > uint(len(d.buffer))-offset {
+			err = newOffsetError()
+		}
+	}
+	if err != nil {
+		return Value{}
+	}
+	return Value{reader: r, typeNum: typeNum, size: size, offset: offset}
+}
+
+// decoder returns the decoder of the Reader of v or nil if v does not exist
+// or the Reader is closed.
+func (v Value) decoder() *decoder {
+	if v.reader == nil || v.reader.buffer == nil {
+		return nil
+	}
+	return &v.reader.decoder
+}
+
+// Exists reports whether there is a value, i.e., whether v is not the
+// result of looking up a missing record, key or index.
+func (v Value) Exists() bool {
+	return v.decoder() != nil
+}
+
+// Get returns the value with the given key if v is a map.
+func (v Value) Get(key string) Value {
+	d := v.decoder()
+	if d == nil || v.typeNum != _Map {
+		return Value{}
+	}
+	offset := v.offset
+	for i := uint(0); i < v.size; i++ {
+		k, valueOffset, err := d.decodeKey(offset)
+		if err != nil {
+			return Value{}
+		}
+		if string(k) == key {
+			return v.reader.value(valueOffset)
+		}
+		offset, err = d.nextValueOffset(valueOffset, 1)
+		if err != nil {
+			return Value{}
+		}
+	}
+	return Value{}
+}
+
+// Index returns the element at index i if v is an array. A negative index
+// counts from the end of the array, as with DecodePath.
+func (v Value) Index(i int) Value {
+	d := v.decoder()
+	if d == nil || v.typeNum != _Slice {
+		return Value{}
+	}
+	if i < 0 {
+		i += int(v.size)
+	}
+	if i < 0 || uint(i) >= v.size {
+		return Value{}
+	}
+	offset, err := d.nextValueOffset(v.offset, uint(i))
+	if err != nil {
+		return Value{}
+	}
+	return v.reader.value(offset)
+}
+
+// Len returns the number of entries if v is a map, the number of elements
+// if v is an array, and 0 otherwise.
+func (v Value) Len() int {
+	if v.decoder() == nil || (v.typeNum != _Map && v.typeNum != _Slice) {
+		return 0
+	}
+	return int(v.size)
+}
+
+// String returns a copy of the value if v is a string and "" otherwise.
+func (v Value) String() string {
+	d := v.decoder()
+	if d == nil || v.typeNum != _String {
+		return ""
+	}
+	s, _ := d.decodeString(v.size, v.offset)
+	return s
+}
+
+// Bool returns the value if v is a boolean and false otherwise.
+func (v Value) Bool() bool {
+	if v.decoder() == nil || v.typeNum != _Bool {
+		return false
+	}
+	b, _ := decodeBool(v.size, v.offset)
+	return b
+}
+
+// Uint returns the value if v is an unsigned integer, or a non-negative
+// signed integer, that fits in a uint64 and 0 otherwise.
+func (v Value) Uint() uint64 {
+	d := v.decoder()
+	if d == nil {
+		return 0
+	}
+	switch v.typeNum {
+	case _Uint16, _Uint32, _Uint64, _Uint128:
+		if v.size > maxUintSize(v.typeNum) {
+			return 0
+		}
+		// The leading bytes of a uint128 must be zero for it to fit.
+		size := v.size
+		for ; size > 8; size-- {
+			if d.buffer[v.offset+v.size-size] != 0 {
+				return 0
+			}
+		}
+		n, _ := d.decodeUint(size, v.offset+v.size-size)
+		return n
+	case _Int32:
+		if v.size > 4 {
+			return 0
+		}
+		n, _ := d.decodeInt(v.size, v.offset)
+		if n < 0 {
+			return 0
+		}
+		return uint64(n)
+	default:
+		return 0
+	}
+}
+
+// Int returns the value if v is a signed integer, or an unsigned integer
+// that fits in an int64, and 0 otherwise.
+func (v Value) Int() int64 {
+	d := v.decoder()
+	if d == nil {
+		return 0
+	}
+	if v.typeNum == _Int32 {
+		if v.size > 4 {
+			return 0
+		}
+		n, _ := d.decodeInt(v.size, v.offset)
+		return int64(n)
+	}
+	n := v.Uint()
+	if n > math.MaxInt64 {
+		return 0
+	}
+	return int64(n)
+}
+
+// Float returns the value if v is a floating-point number and 0 otherwise.
+func (v Value) Float() float64 {
+	d := v.decoder()
+	if d == nil {
+		return 0
+	}
+	switch {
+	case v.typeNum == _Float64 && v.size == 8:
+		f, _ := d.decodeFloat64(v.size, v.offset)
+		return f
+	case v.typeNum == _Float32 && v.size == 4:
+		f, _ := d.decodeFloat32(v.size, v.offset)
+		return float64(f)
+	default:
+		return 0
+	}
+}
+
+// maxUintSize returns the largest size in bytes of a value of the unsigned
+// integer type typeNum.
+func maxUintSize(typeNum dataType) uint {
+	switch typeNum {
+	case _Uint16:
+		return 2
+	case _Uint32:
+		return 4
+	case _Uint64:
+		return 8
+	default:
+		return 16
+	}
+}
@@ -0,0 +1,114 @@
+package maxminddb
+
+import (
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLookupValue(t *testing.T) {
+	reader, err := Open(testFile("MaxMind-DB-test-decoder.mmdb"))
+	require.NoError(t, err)
+	defer reader.Close()
+
+	v, err := reader.LookupValue(net.ParseIP("::1.1.1.0"))
+	require.NoError(t, err)
+	require.True(t, v.Exists())
+
+	assert.Equal(t, "unicode! ☯ - ♫", v.Get("utf8_string").String())
+	assert.Equal(t, uint64(100), v.Get("uint16").Uint())
+	assert.Equal(t, uint64(268435456), v.Get("uint32").Uint())
+	assert.Equal(t, uint64(1152921504606846976), v.Get("uint64").Uint())
+	assert.Equal(t, int64(-268435456), v.Get("int32").Int())
+	assert.Equal(t, int64(268435456), v.Get("uint32").Int())
+	assert.Equal(t, 42.123456, v.Get("double").Float())
+	assert.Equal(t, float64(float32(1.1)), v.Get("float").Float())
+	assert.True(t, v.Get("boolean").Bool())
+
+	// The uint128 is 2**120, which does not fit in a uint64.
+	assert.True(t, v.Get("uint128").Exists())
+	assert.Zero(t, v.Get("uint128").Uint())
+	assert.Equal(t, int64(1152921504606846976), v.Get("uint64").Int())
+	assert.Zero(t, v.Get("int32").Uint())
+
+	array := v.Get("array")
+	assert.Equal(t, 3, array.Len())
+	assert.Equal(t, uint64(1), array.Index(0).Uint())
+	assert.Equal(t, uint64(3), array.Index(2).Uint())
+	assert.Equal(t, uint64(3), array.Index(-1).Uint())
+	assert.False(t, array.Index(3).Exists())
+	assert.False(t, array.Index(-4).Exists())
+
+	mapX := v.Get("map").Get("mapX")
+	assert.Equal(t, 2, mapX.Len())
+	assert.Equal(t, "hello", mapX.Get("utf8_stringX").String())
+	assert.Equal(t, uint64(9), mapX.Get("arrayX").Index(2).Uint())
+
+	// Accessors that do not apply return zero values.
+	missing := v.Get("missing")
+	assert.False(t, missing.Exists())
+	assert.False(t, missing.Get("key").Get("key").Exists())
+	assert.False(t, missing.Index(0).Exists())
+	assert.Zero(t, missing.Len())
+	assert.Empty(t, missing.String())
+	assert.False(t, v.Get("utf8_string").Get("key").Exists())
+	assert.False(t, v.Get("map").Index(0).Exists())
+	assert.Zero(t, v.Get("utf8_string").Len())
+	assert.Empty(t, v.Get("uint16").String())
+	assert.Zero(t, v.Get("utf8_string").Uint())
+	assert.Zero(t, v.Get("uint16").Float())
+	assert.False(t, v.Get("uint16").Bool())
+
+	v, err = reader.LookupValue(net.ParseIP("::2.0.0.0"))
+	require.NoError(t, err)
+	assert.False(t, v.Exists())
+	assert.Empty(t, v.Get("utf8_string").String())
+}
+
+func TestLookupValueCity(t *testing.T) {
+	reader, err := Open(testFile("GeoIP2-City-Test.mmdb"))
+	require.NoError(t, err)
+	defer reader.Close()
+
+	// The country of the record is a pointer to a value shared with other
+	// records.
+	v, err := reader.LookupValue(net.ParseIP("81.2.69.160"))
+	require.NoError(t, err)
+	assert.Equal(t, "GB", v.Get("country").Get("iso_code").String())
+	assert.Equal(t, "London", v.Get("city").Get("names").Get("en").String())
+	assert.Equal(t, "ENG", v.Get("subdivisions").Index(0).Get("iso_code").String())
+	assert.Equal(t, uint64(2635167), v.Get("country").Get("geoname_id").Uint())
+	assert.InDelta(t, 51.5142, v.Get("location").Get("latitude").Float(), 1e-9)
+}
+
+func TestLookupValueAllocations(t *testing.T) {
+	reader, err := Open(testFile("GeoIP2-City-Test.mmdb"))
+	require.NoError(t, err)
+	defer reader.Close()
+
+	v, err := reader.LookupValue(net.ParseIP("81.2.69.160"))
+	require.NoError(t, err)
+	allocs := testing.AllocsPerRun(100, func() {
+		_ = v.Get("country").Get("geoname_id").Uint()
+		_ = v.Get("location").Get("latitude").Float()
+		_ = v.Get("subdivisions").Index(0).Get("names").Len()
+		_ = v.Get("missing").Exists()
+	})
+	assert.Zero(t, allocs)
+}
+
+func TestLookupValueClosed(t *testing.T) {
+	reader, err := Open(testFile("GeoIP2-City-Test.mmdb"))
+	require.NoError(t, err)
+
+	v, err := reader.LookupValue(net.ParseIP("81.2.69.160"))
+	require.NoError(t, err)
+	require.NoError(t, reader.Close())
+
+	assert.False(t, v.Exists())
+	assert.Empty(t, v.Get("country").Get("iso_code").String())
+	_, err = reader.LookupValue(net.ParseIP("81.2.69.160"))
+	assert.EqualError(t, err, "cannot call LookupValue on a closed database")
+}
@@ -8,13 +8,20 @@ import (
 
 type verifier struct {
 	reader *Reader
+	// stop, if not nil, is closed to cancel the verification.
+	stop <-chan struct{}
 }
 
 // Verify checks that the database is valid. It validates the search tree,
 // the data section, and the metadata section. This verifier is stricter than
 // the specification and may return errors on databases that are readable.
 func (r *Reader) Verify() error {
-	v := verifier{reader: r}
+	return r.verify(nil)
+}
+
+// verify is Verify returning errVerifyCanceled once stop is closed.
+func (r *Reader) verify(stop <-chan struct{}) error {
+	v := verifier{reader: r, stop: stop}
 	if err := v.verifyMetadata(); err != nil {
 		return err
 	}
@@ -100,16 +107,19 @@ func (v *verifier) verifyDatabase() error {
 	return v.verifyDataSection(offsets)
 }
 
-func (v *verifier) verifySearchTree() (map[uint]bool, error) {
-	offsets := make(map[uint]bool)
+func (v *verifier) verifySearchTree() (*offsetSet, error) {
+	offsets := newOffsetSet(uint(len(v.reader.decoder.buffer)))
 
 	it := v.reader.Networks()
 	for it.Next() {
-		offset, err := v.reader.resolveDataPointer(it.lastNode.pointer)
+		if v.canceled() {
+			return nil, errVerifyCanceled
+		}
+		offset, err := v.reader.resolveDataPointer(it.lastNode.parent, it.lastNode.pointer)
 		if err != nil {
 			return nil, err
 		}
-		offsets[uint(offset)] = true
+		offsets.add(uint(offset))
 	}
 	if err := it.Err(); err != nil {
 		return nil, err
@@ -130,14 +140,21 @@ func (v *verifier) verifyDataSectionSeparator() error {
 	return nil
 }
 
-func (v *verifier) verifyDataSection(offsets map[uint]bool) error {
-	pointerCount := len(offsets)
+func (v *verifier) verifyDataSection(offsets *offsetSet) error {
+	pointerCount := offsets.len()
 
+	// Verification is always strict and is not subject to the decoding
+	// options of the Reader.
 	decoder := v.reader.decoder
+	decoder.maxDecodedBytes = 0
+	decoder.softFail = false
 
 	var offset uint
 	bufferLen := uint(len(decoder.buffer))
 	for offset < bufferLen {
+		if v.canceled() {
+			return errVerifyCanceled
+		}
 		var data any
 		rv := reflect.ValueOf(&data)
 		newOffset, err := decoder.decode(offset, rv, 0)
@@ -158,14 +175,13 @@ func (v *verifier) verifyDataSection(offsets map[uint]bool) error {
 
 		pointer := offset
 
-		if _, ok := offsets[pointer]; !ok {
+		if !offsets.remove(pointer) {
 			return newInvalidDatabaseError(
 				"found data (%v) at %v that the search tree does not point to",
 				data,
 				pointer,
 			)
 		}
-		delete(offsets, pointer)
 
 		offset = newOffset
 	}
@@ -178,16 +194,26 @@ func (v *verifier) verifyDataSection(offsets map[uint]bool) error {
 		)
 	}
 
-	if len(offsets) != 0 {
+	if offsets.len() != 0 {
 		return newInvalidDatabaseError(
 			"found %v pointers (of %v) in the search tree that we did not see in the data section",
-			len(offsets),
+			offsets.len(),
 			pointerCount,
 		)
 	}
 	return nil
 }
 
+// canceled reports whether the verification has been canceled.
+func (v *verifier) canceled() bool {
+	select {
+	case <-v.stop:
+		return true
+	default:
+		return false
+	}
+}
+
 func testError(
 	field string,
 	expected any,
@@ -200,3 +226,75 @@ func testError(
 		actual,
 	)
 }
+
+// offsetSetPageSize is the number of offsets covered by a page of an
+// offsetSet.
+const offsetSetPageSize = 1 << 15
+
+// offsetSet is a set of offsets in the data section, used by the verifier to
+// track the records the search tree points to. It is a two-level bitset with
+// one bit per offset: the bits of each 32 KiB of the data section are kept
+// in a 4 KiB page that is only allocated once an offset in it is added.
+//
+// Its memory use is therefore bounded by an eighth of the size of the data
+// section, rounded up to 32 KiB, plus 24 bytes of page table per 32 KiB,
+// regardless of the number of records, whereas a hash set uses tens of bytes
+// per record.
+type offsetSet struct {
+	pages [][]uint64
+	count int
+}
+
+// newOffsetSet returns an empty offsetSet for a data section of size bytes.
+func newOffsetSet(size uint) *offsetSet {
+	return &offsetSet{
+		pages: make([][]uint64, (size+offsetSetPageSize-1)/offsetSetPageSize),
+	}
+}
+
+// add adds offset, which must be less than the size of the data section, to
+// the set.
+func (s *offsetSet) add(offset uint) {
+	page := s.pages[offset/offsetSetPageSize]
+	if page == nil {
+		page = make([]uint64, offsetSetPageSize/64)
+		s.pages[offset/offsetSetPageSize] = page
+	}
+	word, bit := (offset%offsetSetPageSize)/64, uint64(1)<<(offset%64)
+	if page[word]&bit == 0 {
+		page[word] |= bit
+		s.count++
+	}
+}
+
+// remove removes offset from the set and reports whether it was in the set.
+func (s *offsetSet) remove(offset uint) bool {
+	if offset/offsetSetPageSize >= uint(len(s.pages)) {
+		return false
+	}
+	page := s.pages[offset/offsetSetPageSize]
+	if page == nil {
+		return false
+	}
+	word, bit := (offset%offsetSetPageSize)/64, uint64(1)<<(offset%64)
+	if page[word]&bit == 0 {
+		return false
+	}
+	page[word] &^= bit
+	s.count--
+	return true
+}
+
+// len returns the number of offsets in the set.
+func (s *offsetSet) len() int {
+	return s.count
+}
+
+// memoryUsage returns the approximate number of bytes used by the set.
+func (s *offsetSet) memoryUsage() int {
+	usage := len(s.pages) * int(reflect.TypeOf([]uint64(nil)).Size())
+	for _, page := range s.pages {
+		usage += 8 * len(page)
+	}
+	return usage
+}
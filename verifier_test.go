@@ -62,3 +62,69 @@ func TestVerifyOnBrokenDatabases(t *testing.T) {
 		)
 	}
 }
+
+func TestOffsetSet(t *testing.T) {
+	const size = 3*offsetSetPageSize + 100
+	s := newOffsetSet(size)
+	assert.Len(t, s.pages, 4)
+	assert.Equal(t, 4*24, s.memoryUsage())
+
+	for _, offset := range []uint{0, 63, 64, offsetSetPageSize, size - 1, 63} {
+		s.add(offset)
+	}
+	assert.Equal(t, 5, s.len())
+	// Only the pages of the first, second, and last 32 KiB are allocated.
+	assert.Equal(t, 4*24+3*offsetSetPageSize/8, s.memoryUsage())
+
+	assert.True(t, s.remove(63))
+	assert.False(t, s.remove(63))
+	assert.False(t, s.remove(62))
+	assert.False(t, s.remove(2*offsetSetPageSize))
+	assert.False(t, s.remove(size+offsetSetPageSize))
+	assert.True(t, s.remove(size-1))
+	assert.Equal(t, 3, s.len())
+}
+
+func TestVerifySearchTreeOffsets(t *testing.T) {
+	for _, database := range []string{
+		"GeoIP2-City-Test.mmdb",
+		"GeoIP2-Precision-Enterprise-Test.mmdb",
+		"MaxMind-DB-no-ipv4-search-tree.mmdb",
+		"MaxMind-DB-test-decoder.mmdb",
+		"MaxMind-DB-test-mixed-24.mmdb",
+	} {
+		t.Run(database, func(t *testing.T) {
+			reader, err := Open(testFile(database))
+			require.NoError(t, err)
+
+			v := verifier{reader: reader}
+			offsets, err := v.verifySearchTree()
+			require.NoError(t, err)
+
+			expected := map[uint]bool{}
+			n := reader.Networks()
+			for n.Next() {
+				offset, err := reader.resolveDataPointer(n.lastNode.parent, n.lastNode.pointer)
+				require.NoError(t, err)
+				expected[uint(offset)] = true
+			}
+			require.NoError(t, n.Err())
+
+			dataSize := len(reader.decoder.buffer)
+			assert.LessOrEqual(
+				t,
+				offsets.memoryUsage(),
+				dataSize/8+24*(dataSize/offsetSetPageSize+1)+offsetSetPageSize/8,
+			)
+
+			assert.Equal(t, len(expected), offsets.len())
+			for offset := range expected {
+				assert.True(t, offsets.remove(offset), "offset %d", offset)
+			}
+			assert.Equal(t, 0, offsets.len())
+
+			assert.NoError(t, reader.Verify())
+			assert.NoError(t, reader.Close())
+		})
+	}
+}
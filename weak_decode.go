@@ -0,0 +1,261 @@
+package maxminddb
+
+import (
+	"math"
+	"strconv"
+
+	"github.com/3JoB/go-reflect"
+)
+
+// WithWeaklyTypedDecode is an option for Open and FromBytes that makes the
+// decoder convert values whose type does not match the type they are decoded
+// into where there is a reasonable conversion. It is meant for databases
+// that store the same logical field with different types in different
+// records. The conversions are:
+//
+//   - A utf8_string holding a decimal integer, e.g., "42" or "-7", into an
+//     integer type, if it fits.
+//   - A utf8_string holding a finite number, e.g., "1.5" or "1e3", into a
+//     float type.
+//   - An integer or float value into a string type, formatted as by
+//     strconv, e.g., 42 as "42" and 1.5 as "1.5".
+//   - An integer value of 0 or 1 into a bool, as false or true.
+//   - A float value holding an integer, e.g., 3.0, into an integer type, if
+//     it fits, and an integer value into a float type, if the float holds
+//     it exactly.
+//   - Any value other than an array into a slice type other than []byte, as
+//     a slice holding the value as its only element. The conversions above
+//     apply to the element.
+//
+// Values that cannot be converted result in an UnmarshalTypeError as usual.
+// Values whose type already matches are not affected. By default, no
+// conversions are performed.
+//
+// This does not apply to types implementing the deserializer interface.
+func WithWeaklyTypedDecode() ReaderOption {
+	return func(o *readerOptions) {
+		o.weaklyTyped = true
+	}
+}
+
+// WithLenientDecode is an option for Open, FromBytes, and FromReader for
+// databases whose types drift between releases. It combines
+// WithWeaklyTypedDecode and WithSoftFailDecode, and also records each
+// conversion made, as a FieldError wrapping a CoercionError, among the
+// FieldErrors returned once the rest of the record has been decoded. A
+// record whose values were all stored without conversion or failure
+// decodes without error. Use errors.As on the Err of each FieldError to
+// tell conversions from values that were skipped.
+func WithLenientDecode() ReaderOption {
+	return func(o *readerOptions) {
+		o.weaklyTyped = true
+		o.softFailDecode = true
+		o.reportCoercions = true
+	}
+}
+
+// CoercionError is the error of a FieldError recorded with
+// WithLenientDecode for a value that was converted from its type in the
+// database to the type it was decoded into. The value was stored.
+type CoercionError struct {
+	// From is the type of the value in the database.
+	From Kind
+	// To is the type the value was converted to.
+	To reflect.Type
+}
+
+func (e CoercionError) Error() string {
+	return "maxminddb: converted " + e.From.String() + " into type " + e.To.String()
+}
+
+// noteCoercion records the conversion of a value of type dtype into the
+// type to, if WithLenientDecode is set.
+func (d *decoder) noteCoercion(dtype dataType, to reflect.Type) {
+	if d.reportCoercions {
+		d.fieldErrors = append(d.fieldErrors, FieldError{Err: CoercionError{From: Kind(dtype), To: to}})
+	}
+}
+
+// unmarshalSingleElementSlice stores the value of type dtype at offset as the
+// only element of a new slice in result.
+func (d *decoder) unmarshalSingleElementSlice(
+	dtype dataType,
+	size uint,
+	offset uint,
+	result reflect.Value,
+	depth int,
+) (uint, error) {
+	if err := d.charge(uint(result.Type().Elem().Size())); err != nil {
+		return 0, err
+	}
+	slice := reflect.MakeSlice(result.Type(), 1, 1)
+	newOffset, err := d.decodeFromType(dtype, size, offset, slice.Index(0), depth)
+	if err != nil {
+		return 0, err
+	}
+	result.Set(slice)
+	d.noteCoercion(dtype, result.Type())
+	return newOffset, nil
+}
+
+// coerceScalar stores the value of type dtype at offset in result using one
+// of the conversions of WithWeaklyTypedDecode. err is the error from storing
+// the value without conversion and is returned if there is no conversion.
+func (d *decoder) coerceScalar(
+	dtype dataType,
+	size uint,
+	offset uint,
+	result reflect.Value,
+	err error,
+) (uint, error) {
+	if _, ok := err.(UnmarshalTypeError); !ok {
+		return 0, err
+	}
+	newOffset := offset + size
+
+	if dtype == _String {
+		if setNumericString(result, string(d.buffer[offset:newOffset])) {
+			return newOffset, nil
+		}
+		return 0, err
+	}
+
+	if d.setLosslessNumber(dtype, size, offset, result) {
+		return newOffset, nil
+	}
+
+	switch result.Kind() {
+	case reflect.String:
+		s, ok := d.formatNumber(dtype, size, offset)
+		if !ok {
+			return 0, err
+		}
+		if err := d.charge(uint(len(s))); err != nil {
+			return 0, err
+		}
+		result.SetString(s)
+		return newOffset, nil
+	case reflect.Bool:
+		s, ok := d.formatNumber(dtype, size, offset)
+		if !ok || dtype == _Float32 || dtype == _Float64 || (s != "0" && s != "1") {
+			return 0, err
+		}
+		result.SetBool(s == "1")
+		return newOffset, nil
+	}
+	return 0, err
+}
+
+// setNumericString sets result, which must be settable, to the number in s
+// if result is of an integer or float kind and s holds a number that fits.
+func setNumericString(result reflect.Value, s string) bool {
+	switch result.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(s, 10, result.Type().Bits())
+		if err != nil {
+			return false
+		}
+		result.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(s, 10, result.Type().Bits())
+		if err != nil {
+			return false
+		}
+		result.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(s, result.Type().Bits())
+		if err != nil || math.IsNaN(f) || math.IsInf(f, 0) {
+			return false
+		}
+		result.SetFloat(f)
+	default:
+		return false
+	}
+	return true
+}
+
+// setLosslessNumber sets result to the number of type dtype at offset if
+// one is a float and the other an integer, and the number converts exactly.
+func (d *decoder) setLosslessNumber(dtype dataType, size, offset uint, result reflect.Value) bool {
+	switch dtype {
+	case _Float32, _Float64:
+		var f float64
+		if dtype == _Float32 {
+			f32, _ := d.decodeFloat32(size, offset)
+			f = float64(f32)
+		} else {
+			f, _ = d.decodeFloat64(size, offset)
+		}
+		if f != math.Trunc(f) {
+			return false
+		}
+		switch result.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			if f < -(1<<63) || f >= 1<<63 || result.OverflowInt(int64(f)) {
+				return false
+			}
+			result.SetInt(int64(f))
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			if f < 0 || f >= 1<<64 || result.OverflowUint(uint64(f)) {
+				return false
+			}
+			result.SetUint(uint64(f))
+		default:
+			return false
+		}
+		return true
+	case _Int32:
+		if result.Kind() != reflect.Float32 && result.Kind() != reflect.Float64 {
+			return false
+		}
+		n, _ := d.decodeInt(size, offset)
+		f := float64(n)
+		if result.Kind() == reflect.Float32 {
+			f = float64(float32(n))
+		}
+		if int64(f) != int64(n) {
+			return false
+		}
+		result.SetFloat(f)
+		return true
+	case _Uint16, _Uint32, _Uint64:
+		if result.Kind() != reflect.Float32 && result.Kind() != reflect.Float64 {
+			return false
+		}
+		n, _ := d.decodeUint(size, offset)
+		f := float64(n)
+		if result.Kind() == reflect.Float32 {
+			f = float64(float32(n))
+		}
+		if f >= 1<<64 || uint64(f) != n {
+			return false
+		}
+		result.SetFloat(f)
+		return true
+	}
+	return false
+}
+
+// formatNumber returns the number of type dtype at offset formatted in
+// decimal. ok is false if dtype is not a numeric type.
+func (d *decoder) formatNumber(dtype dataType, size, offset uint) (s string, ok bool) {
+	switch dtype {
+	case _Uint16, _Uint32, _Uint64:
+		n, _ := d.decodeUint(size, offset)
+		return strconv.FormatUint(n, 10), true
+	case _Int32:
+		n, _ := d.decodeInt(size, offset)
+		return strconv.Itoa(n), true
+	case _Uint128:
+		n, _ := d.decodeUint128(size, offset)
+		return n.String(), true
+	case _Float32:
+		f, _ := d.decodeFloat32(size, offset)
+		return strconv.FormatFloat(float64(f), 'g', -1, 32), true
+	case _Float64:
+		f, _ := d.decodeFloat64(size, offset)
+		return strconv.FormatFloat(f, 'g', -1, 64), true
+	default:
+		return "", false
+	}
+}
@@ -0,0 +1,172 @@
+package maxminddb
+
+import (
+	"encoding/hex"
+	"testing"
+
+	"github.com/3JoB/go-reflect"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func decodeWeakly(t *testing.T, input string, result any) error {
+	buffer, err := hex.DecodeString(input)
+	require.NoError(t, err)
+	d := decoder{buffer: buffer, weaklyTyped: true}
+	_, err = d.decode(0, reflect.ValueOf(result), 0)
+	return err
+}
+
+type weakTest struct {
+	result   any
+	expected any
+	input    string
+}
+
+func checkWeakTests(t *testing.T, tests []weakTest) {
+	for _, test := range tests {
+		err := decodeWeakly(t, test.input, test.result)
+		if test.expected == nil {
+			var typeErr UnmarshalTypeError
+			assert.ErrorAs(t, err, &typeErr, test.input)
+			continue
+		}
+		require.NoError(t, err, test.input)
+		assert.Equal(t, test.expected, reflect.ValueOf(test.result).Elem().Interface(), test.input)
+	}
+}
+
+func TestWeaklyTypedStringToInteger(t *testing.T) {
+	checkWeakTests(t, []weakTest{
+		{new(int), 42, "423432"},                     // "42"
+		{new(int8), int8(42), "423432"},              // "42"
+		{new(uint16), uint16(42), "423432"},          // "42"
+		{new(uint64), uint64(42), "423432"},          // "42"
+		{new(int32), int32(-7), "422d37"},            // "-7"
+		{new(uint8), nil, "43333030"},                // "300" overflows
+		{new(uint), nil, "422d37"},                   // "-7" is negative
+		{new(int), nil, "43616263"},                  // "abc"
+		{new(int), nil, "43312e35"},                  // "1.5"
+		{new(int), nil, "40"},                        // ""
+		{new(int64), int64(0), "4130"},               // "0"
+		{new(uint32), uint32(13335), "453133333335"}, // "13335"
+	})
+}
+
+func TestWeaklyTypedStringToFloat(t *testing.T) {
+	checkWeakTests(t, []weakTest{
+		{new(float64), 1.5, "43312e35"},           // "1.5"
+		{new(float32), float32(1000), "43316533"}, // "1e3"
+		{new(float64), float64(-7), "422d37"},     // "-7"
+		{new(float64), nil, "434e614e"},           // "NaN"
+		{new(float64), nil, "43496e66"},           // "Inf"
+		{new(float64), nil, "43616263"},           // "abc"
+	})
+}
+
+func TestWeaklyTypedNumberToString(t *testing.T) {
+	checkWeakTests(t, []weakTest{
+		{new(string), "42", "a12a"},                // uint16
+		{new(string), "42", "c12a"},                // uint32
+		{new(string), "42", "01022a"},              // uint64
+		{new(string), "42", "01032a"},              // uint128
+		{new(string), "-7", "0401fffffff9"},        // int32
+		{new(string), "1.5", "683ff8000000000000"}, // double
+		{new(string), "1.5", "04083fc00000"},       // float
+		{new(string), nil, "0107"},                 // boolean
+	})
+}
+
+func TestWeaklyTypedIntegerToBool(t *testing.T) {
+	checkWeakTests(t, []weakTest{
+		{new(bool), false, "c0"},               // uint32 0
+		{new(bool), true, "c101"},              // uint32 1
+		{new(bool), true, "a101"},              // uint16 1
+		{new(bool), true, "010101"},            // int32 1
+		{new(bool), nil, "c102"},               // uint32 2
+		{new(bool), nil, "0401fffffff9"},       // int32 -7
+		{new(bool), nil, "683ff0000000000000"}, // double 1.0
+		{new(bool), nil, "4131"},               // "1"
+	})
+}
+
+func TestWeaklyTypedSingleElementSlice(t *testing.T) {
+	type entry struct {
+		A string `maxminddb:"a"`
+	}
+	checkWeakTests(t, []weakTest{
+		{new([]string), []string{"x"}, "4178"},          // "x"
+		{new([]any), []any{"x"}, "4178"},                // "x"
+		{new([]uint16), []uint16{42}, "a12a"},           // uint16
+		{new([]string), []string{"42"}, "a12a"},         // uint16
+		{new([]int), []int{42}, "423432"},               // "42"
+		{new([]entry), []entry{{A: "x"}}, "e141614178"}, // {"a": "x"}
+		{new([]string), []string{"x"}, "01044178"},      // ["x"]
+		{new([]byte), []byte{1, 2}, "820102"},           // bytes
+		{new([]byte), nil, "4178"},                      // "x"
+		{new([]int), nil, "4178"},                       // "x"
+	})
+}
+
+func TestWeaklyTypedDecodeOff(t *testing.T) {
+	buffer, err := hex.DecodeString("423432") // "42"
+	require.NoError(t, err)
+	d := decoder{buffer: buffer}
+
+	var result int
+	_, err = d.decode(0, reflect.ValueOf(&result), 0)
+	var typeErr UnmarshalTypeError
+	require.ErrorAs(t, err, &typeErr)
+
+	var slice []string
+	_, err = d.decode(0, reflect.ValueOf(&slice), 0)
+	require.ErrorAs(t, err, &typeErr)
+}
+
+func TestWeaklyTypedLosslessNumbers(t *testing.T) {
+	checkWeakTests(t, []weakTest{
+		{new(int), 1, "683ff0000000000000"},                    // double 1.0
+		{new(uint8), uint8(1), "683ff0000000000000"},           // double 1.0
+		{new(int64), int64(-2), "68c000000000000000"},          // double -2.0
+		{new(uint16), uint16(1), "04083f800000"},               // float 1.0
+		{new(int), nil, "683ff8000000000000"},                  // double 1.5
+		{new(uint), nil, "68c000000000000000"},                 // double -2.0
+		{new(int8), nil, "68406fe00000000000"},                 // double 255.0
+		{new(float64), float64(42), "a12a"},                    // uint16
+		{new(float32), float32(-7), "0401fffffff9"},            // int32
+		{new(float64), float64(1 << 53), "070220000000000000"}, // uint64 2^53
+		{new(float64), nil, "070220000000000001"},              // uint64 2^53+1
+		{new(float32), nil, "c401000001"},                      // uint32 2^24+1
+	})
+}
+
+func TestLenientDecode(t *testing.T) {
+	// {"a": "42", "b": "x", "c": 42, "d": 1.0}
+	buffer, err := hex.DecodeString("e44161423432416241784163a12a4164683ff0000000000000")
+	require.NoError(t, err)
+	d := decoder{buffer: buffer, weaklyTyped: true, softFail: true, reportCoercions: true}
+
+	var result struct {
+		A int    `maxminddb:"a"`
+		B int    `maxminddb:"b"`
+		C string `maxminddb:"c"`
+		D uint   `maxminddb:"d"`
+	}
+	_, err = d.decode(0, reflect.ValueOf(&result), 0)
+	require.NoError(t, err)
+	assert.Equal(t, 42, result.A)
+	assert.Equal(t, 0, result.B)
+	assert.Equal(t, "42", result.C)
+	assert.Equal(t, uint(1), result.D)
+
+	require.Len(t, d.fieldErrors, 4)
+	paths := map[string]error{}
+	for _, fieldErr := range d.fieldErrors {
+		paths[fieldErr.Path] = fieldErr.Err
+	}
+	assert.Equal(t, CoercionError{From: KindUTF8String, To: reflect.TypeOf(0)}, paths["a"])
+	assert.ErrorAs(t, paths["b"], new(UnmarshalTypeError))
+	assert.Equal(t, CoercionError{From: KindUint16, To: reflect.TypeOf("")}, paths["c"])
+	assert.Equal(t, CoercionError{From: KindDouble, To: reflect.TypeOf(uint(0))}, paths["d"])
+	assert.Equal(t, "maxminddb: converted utf8_string into type int", paths["a"].Error())
+}